@@ -0,0 +1,213 @@
+package publickey
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io"
+)
+
+/*
+ * Options for RSA-PSS signatures.
+ *
+ * The salt has the same length as the hash.
+ */
+var g_pssOptions = rsa.PSSOptions{
+	SaltLength: rsa.PSSSaltLengthEqualsHash,
+	Hash:       crypto.SHA512,
+}
+
+/*
+ * Parses an RSA private key in PEM format.
+ *
+ * Both PKCS #1 ("RSA PRIVATE KEY") and PKCS #8 ("PRIVATE KEY") encodings are
+ * supported.
+ */
+func ParsePrivateKey(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+
+	/*
+	 * Check if PEM block was found.
+	 */
+	if block == nil {
+		return nil, fmt.Errorf("%s", "No PEM block found.")
+	} else {
+		blockType := block.Type
+		blockBytes := block.Bytes
+
+		/*
+		 * Decide on encoding of private key.
+		 */
+		switch blockType {
+		case "RSA PRIVATE KEY":
+			key, err := x509.ParsePKCS1PrivateKey(blockBytes)
+
+			/*
+			 * Check if private key could be parsed.
+			 */
+			if err != nil {
+				msg := err.Error()
+				return nil, fmt.Errorf("Failed to parse private key: %s", msg)
+			} else {
+				return key, nil
+			}
+
+		case "PRIVATE KEY":
+			key, err := x509.ParsePKCS8PrivateKey(blockBytes)
+
+			/*
+			 * Check if private key could be parsed.
+			 */
+			if err != nil {
+				msg := err.Error()
+				return nil, fmt.Errorf("Failed to parse private key: %s", msg)
+			} else {
+				keyRSA, ok := key.(*rsa.PrivateKey)
+
+				/*
+				 * Check if this is an RSA key.
+				 */
+				if !ok {
+					return nil, fmt.Errorf("%s", "Private key is not an RSA key.")
+				} else {
+					return keyRSA, nil
+				}
+
+			}
+
+		default:
+			return nil, fmt.Errorf("Unsupported PEM block type: '%s'", blockType)
+		}
+
+	}
+
+}
+
+/*
+ * Parses an RSA public key in PEM format.
+ *
+ * Both PKCS #1 ("RSA PUBLIC KEY") and PKIX ("PUBLIC KEY") encodings are
+ * supported.
+ */
+func ParsePublicKey(data []byte) (*rsa.PublicKey, error) {
+	block, rest := pem.Decode(data)
+	rest = bytes.TrimSpace(rest)
+	restSize := len(rest)
+
+	/*
+	 * Check if exactly one PEM block was found.
+	 */
+	if block == nil {
+		return nil, fmt.Errorf("%s", "No PEM block found.")
+	} else if restSize != 0 {
+		return nil, fmt.Errorf("%s", "Unexpected data after PEM block.")
+	} else {
+		blockType := block.Type
+		blockBytes := block.Bytes
+
+		/*
+		 * Decide on encoding of public key.
+		 */
+		switch blockType {
+		case "RSA PUBLIC KEY":
+			key, err := x509.ParsePKCS1PublicKey(blockBytes)
+
+			/*
+			 * Check if public key could be parsed.
+			 */
+			if err != nil {
+				msg := err.Error()
+				return nil, fmt.Errorf("Failed to parse public key: %s", msg)
+			} else {
+				return key, nil
+			}
+
+		case "PUBLIC KEY":
+			key, err := x509.ParsePKIXPublicKey(blockBytes)
+
+			/*
+			 * Check if public key could be parsed.
+			 */
+			if err != nil {
+				msg := err.Error()
+				return nil, fmt.Errorf("Failed to parse public key: %s", msg)
+			} else {
+				keyRSA, ok := key.(*rsa.PublicKey)
+
+				/*
+				 * Check if this is an RSA key.
+				 */
+				if !ok {
+					return nil, fmt.Errorf("%s", "Public key is not an RSA key.")
+				} else {
+					return keyRSA, nil
+				}
+
+			}
+
+		default:
+			return nil, fmt.Errorf("Unsupported PEM block type: '%s'", blockType)
+		}
+
+	}
+
+}
+
+/*
+ * Signs a message using RSA-PSS with SHA-512.
+ */
+func SignPSS(prng io.Reader, key *rsa.PrivateKey, message []byte) ([]byte, error) {
+
+	/*
+	 * Check if key was provided.
+	 */
+	if key == nil {
+		return nil, fmt.Errorf("%s", "Private key must not be nil!")
+	} else {
+		digest := sha512.Sum512(message)
+		signature, err := rsa.SignPSS(prng, key, crypto.SHA512, digest[:], &g_pssOptions)
+
+		/*
+		 * Check if message was signed.
+		 */
+		if err != nil {
+			msg := err.Error()
+			return nil, fmt.Errorf("Failed to sign message: %s", msg)
+		} else {
+			return signature, nil
+		}
+
+	}
+
+}
+
+/*
+ * Verifies an RSA-PSS signature with SHA-512 over a message.
+ */
+func VerifyPSS(key *rsa.PublicKey, message []byte, signature []byte) error {
+
+	/*
+	 * Check if key was provided.
+	 */
+	if key == nil {
+		return fmt.Errorf("%s", "Public key must not be nil!")
+	} else {
+		digest := sha512.Sum512(message)
+		err := rsa.VerifyPSS(key, crypto.SHA512, digest[:], signature, &g_pssOptions)
+
+		/*
+		 * Check if signature is valid.
+		 */
+		if err != nil {
+			return fmt.Errorf("%s", "Signature verification failed.")
+		} else {
+			return nil
+		}
+
+	}
+
+}
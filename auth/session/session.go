@@ -8,6 +8,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/andrepxx/location-visualizer/auth/publickey"
 	"github.com/andrepxx/location-visualizer/auth/user"
 )
 
@@ -78,7 +79,9 @@ type managerStruct struct {
 type Manager interface {
 	CreateToken(token []byte) Token
 	Challenge(name string) (Challenge, error)
-	Response(name string, hash []byte) (Token, error)
+	Response(name string, hash []byte, code string) (Token, error)
+	ResponsePublicKey(name string, signature []byte) (Token, error)
+	SetExpiry(expiry time.Duration)
 	Terminate(token Token) error
 	UserName(token Token) (string, error)
 }
@@ -188,6 +191,55 @@ func (this *managerStruct) refreshOrExpire(id int64) (bool, time.Time) {
 	}
 }
 
+/*
+ * Creates a new session for an authenticated user.
+ */
+func (this *managerStruct) createSession(name string) (Token, error) {
+	token := [LENGTH]byte{}
+	rng := this.prng
+	numBytes, err := rng.Read(token[:])
+
+	/*
+	 * Check if token was generated and associate it to session.
+	 */
+	if err != nil {
+		msg := err.Error()
+		return nil, fmt.Errorf("Failed to generate session token: %s", msg)
+	} else if numBytes != LENGTH {
+		return nil, fmt.Errorf("Failed to generate session token: Incorrect number of bytes read from PRNG: Expected %d, got %d.", LENGTH, numBytes)
+	} else {
+		now := time.Now()
+
+		/*
+		 * Create session.
+		 */
+		s := sessionStruct{
+			token:      [LENGTH]byte{},
+			name:       name,
+			lastAccess: now,
+		}
+
+		copy(s.token[:], token[:])
+		this.mutex.Lock()
+		mgr := this.userManager
+		mgr.RegenerateNonce(name)
+		sessions := this.sessions
+		sessions = append(sessions, s)
+		this.sessions = sessions
+		this.mutex.Unlock()
+
+		/*
+		 * Create session token.
+		 */
+		t := tokenStruct{
+			token: token,
+		}
+
+		return &t, nil
+	}
+
+}
+
 /*
  * Creates a session token from a byte slice.
  */
@@ -237,19 +289,24 @@ func (this *managerStruct) Challenge(name string) (Challenge, error) {
 
 /*
  * Verify an authentication response for a user, given his / her name and the response hash.
+ *
+ * If two-factor authentication is enabled for the user, a valid time-based
+ * one-time password must be provided as well. This only applies to password
+ * logins, see ResponsePublicKey.
  */
-func (this *managerStruct) Response(name string, response []byte) (Token, error) {
+func (this *managerStruct) Response(name string, response []byte, code string) (Token, error) {
 	this.mutex.RLock()
 	mgr := this.userManager
 	nonce, errNonce := mgr.Nonce(name)
 	hash, errHash := mgr.Hash(name)
+	totpEnabled, errTotp := mgr.TOTPEnabled(name)
 	this.mutex.RUnlock()
 	hashSize := len(hash)
 
 	/*
 	 * If user does not exist or has no hash set, abort with failure.
 	 */
-	if (errNonce != nil) || (errHash != nil) {
+	if (errNonce != nil) || (errHash != nil) || (errTotp != nil) {
 		return nil, fmt.Errorf("User '%s' not found.", name)
 	} else if hashSize == 0 {
 		return nil, fmt.Errorf("%s", "Authentication failed.")
@@ -257,61 +314,84 @@ func (this *managerStruct) Response(name string, response []byte) (Token, error)
 		nonceAndHash := append(nonce[:], hash...)
 		expected := sha512.Sum512(nonceAndHash)
 		c := subtle.ConstantTimeCompare(response, expected[:])
+		codeValid := true
 
 		/*
-		 * Check if the response matches.
+		 * Verify the one-time password if two-factor authentication is
+		 * enabled. Since each code can only be used once, it is only
+		 * verified along with a matching response, so that it cannot be
+		 * used up without knowing the password.
 		 */
-		if c != CTC_EQUAL {
+		if totpEnabled && (c == CTC_EQUAL) {
+			valid, err := mgr.VerifyTOTP(name, code)
+			codeValid = valid && (err == nil)
+		}
+
+		/*
+		 * Check if the response and the one-time password match.
+		 */
+		if (c != CTC_EQUAL) || !codeValid {
 			return nil, fmt.Errorf("%s", "Authentication failed.")
 		} else {
-			token := [LENGTH]byte{}
-			rng := this.prng
-			numBytes, err := rng.Read(token[:])
+			t, err := this.createSession(name)
+			return t, err
+		}
 
-			/*
-			 * Check if token was generated and associate it to session.
-			 */
-			if err != nil {
-				msg := err.Error()
-				return nil, fmt.Errorf("Failed to generate session token: %s", msg)
-			} else if numBytes != LENGTH {
-				return nil, fmt.Errorf("Failed to generate session token: Incorrect number of bytes read from PRNG: Expected %d, got %d.", LENGTH, numBytes)
-			} else {
-				now := time.Now()
-
-				/*
-				 * Create session.
-				 */
-				s := sessionStruct{
-					token:      [LENGTH]byte{},
-					name:       name,
-					lastAccess: now,
-				}
-
-				copy(s.token[:], token[:])
-				this.mutex.Lock()
-				mgr.RegenerateNonce(name)
-				sessions := this.sessions
-				sessions = append(sessions, s)
-				this.sessions = sessions
-				this.mutex.Unlock()
-
-				/*
-				 * Create session token.
-				 */
-				t := tokenStruct{
-					token: token,
-				}
-
-				return &t, nil
-			}
+	}
+
+}
+
+/*
+ * Verify an authentication response for a user, given his / her name and a
+ * signature over the nonce created with his / her private key.
+ *
+ * Two-factor authentication does not apply here. The private key is already
+ * something the user has, and logging in with a key is meant for unattended
+ * clients, like scheduled backups, which cannot provide one-time passwords.
+ * Only register public keys for users if this is acceptable.
+ */
+func (this *managerStruct) ResponsePublicKey(name string, signature []byte) (Token, error) {
+	this.mutex.RLock()
+	mgr := this.userManager
+	nonce, errNonce := mgr.Nonce(name)
+	key, errKey := mgr.PublicKey(name)
+	this.mutex.RUnlock()
+
+	/*
+	 * If user does not exist or has no public key set, abort with failure.
+	 */
+	if errNonce != nil {
+		return nil, fmt.Errorf("User '%s' not found.", name)
+	} else if errKey != nil {
+		return nil, fmt.Errorf("%s", "Authentication failed.")
+	} else {
+		err := publickey.VerifyPSS(key, nonce[:], signature)
 
+		/*
+		 * Check if the signature is valid.
+		 */
+		if err != nil {
+			return nil, fmt.Errorf("%s", "Authentication failed.")
+		} else {
+			t, err := this.createSession(name)
+			return t, err
 		}
 
 	}
 
 }
 
+/*
+ * Sets the time of inactivity after which sessions expire.
+ *
+ * This also applies to existing sessions.
+ */
+func (this *managerStruct) SetExpiry(expiry time.Duration) {
+	this.mutex.Lock()
+	this.expiry = expiry
+	this.mutex.Unlock()
+}
+
 /*
  * Terminate a session given a session token, logging out the corresponding user.
  */
@@ -1,24 +1,45 @@
 package user
 
 import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha1"
 	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base32"
 	"encoding/base64"
+	"encoding/binary"
 	"encoding/json"
 	"fmt"
 	"io"
 	"regexp"
 	"sync"
+	"time"
 	"unicode/utf8"
+
+	"github.com/andrepxx/location-visualizer/auth/publickey"
 )
 
 /*
  * Global constants.
  */
 const (
-	LENGTH        = 64
-	UNAME_L_LIMIT = 3
-	UNAME_U_LIMIT = 16
-	UNAME_REX     = "^[A-Za-z0-9\\-_\\.]+$"
+	CTC_EQUAL           = 1
+	ENCRYPTION_HEADER   = "LOCVIZ-USERDB-AES256GCM-V1\n"
+	ENCRYPTION_KEY_SIZE = 32
+	LENGTH              = 64
+	PUBLIC_KEY_BITS     = 2048
+	TOTP_DIGITS         = 6
+	TOTP_MODULUS        = 1000000
+	TOTP_PERIOD         = 30
+	TOTP_SECRET_SIZE    = 20
+	TOTP_SKEW           = 1
+	UNAME_L_LIMIT       = 3
+	UNAME_U_LIMIT       = 16
+	UNAME_REX           = "^[A-Za-z0-9\\-_\\.]+$"
 )
 
 /*
@@ -30,6 +51,9 @@ type userStruct struct {
 	hash        []byte
 	nonce       [LENGTH]byte
 	permissions []string
+	publicKey   []byte
+	totpSecret  []byte
+	totpCounter uint64
 }
 
 /*
@@ -40,12 +64,16 @@ type persistedUserStruct struct {
 	Salt        string
 	Hash        string
 	Permissions []string
+	PublicKey   string
+	TOTPSecret  string
+	TOTPCounter uint64
 }
 
 /*
  * Data structure representing a user manager.
  */
 type managerStruct struct {
+	key   []byte
 	prng  io.Reader
 	rex   *regexp.Regexp
 	mutex sync.RWMutex
@@ -57,20 +85,149 @@ type managerStruct struct {
  */
 type Manager interface {
 	AddPermission(name string, permission string) error
+	ClearPublicKey(name string) error
 	CreateUser(name string) error
+	DisableTOTP(name string) error
+	EnableTOTP(name string) (string, error)
 	Export() ([]byte, error)
 	Hash(name string) ([]byte, error)
 	HasPermission(name string, permission string) (bool, error)
 	Import(buf []byte) error
 	Nonce(name string) ([LENGTH]byte, error)
 	Permissions(name string) ([]string, error)
+	PublicKey(name string) (*rsa.PublicKey, error)
 	RegenerateNonce(name string) error
 	RemovePermission(name string, permission string) error
 	RemoveUser(name string) error
 	Salt(name string) ([LENGTH]byte, error)
+	SetKey(key []byte) error
 	SetPassword(name string, password string) error
+	SetPublicKey(name string, pem []byte) error
+	TOTPEnabled(name string) (bool, error)
 	UserExists(name string) bool
 	Users() []string
+	VerifyTOTP(name string, code string) (bool, error)
+}
+
+/*
+ * Creates an AES-GCM cipher from an encryption key.
+ */
+func (this *managerStruct) createCipher(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+
+	/*
+	 * Check if block cipher could be created.
+	 */
+	if err != nil {
+		msg := err.Error()
+		return nil, fmt.Errorf("Failed to create block cipher: %s", msg)
+	} else {
+		aead, err := cipher.NewGCM(block)
+
+		/*
+		 * Check if AEAD cipher could be created.
+		 */
+		if err != nil {
+			msg := err.Error()
+			return nil, fmt.Errorf("Failed to create AEAD cipher: %s", msg)
+		} else {
+			return aead, nil
+		}
+
+	}
+
+}
+
+/*
+ * Decrypts an encrypted user database.
+ *
+ * The encrypted database consists of the header, followed by the nonce and
+ * the ciphertext. The header is authenticated as additional data.
+ */
+func (this *managerStruct) decrypt(buf []byte, key []byte) ([]byte, error) {
+
+	/*
+	 * Check if a key is available.
+	 */
+	if key == nil {
+		return nil, fmt.Errorf("%s", "User database is encrypted, but no key was provided.")
+	} else {
+		aead, err := this.createCipher(key)
+
+		/*
+		 * Check if cipher could be created.
+		 */
+		if err != nil {
+			return nil, err
+		} else {
+			header := []byte(ENCRYPTION_HEADER)
+			headerSize := len(header)
+			nonceSize := aead.NonceSize()
+			bufSize := len(buf)
+			offsetCiphertext := headerSize + nonceSize
+
+			/*
+			 * Check if buffer holds a complete nonce.
+			 */
+			if bufSize < offsetCiphertext {
+				return nil, fmt.Errorf("%s", "Encrypted user database is truncated.")
+			} else {
+				nonce := buf[headerSize:offsetCiphertext]
+				ciphertext := buf[offsetCiphertext:]
+				plaintext, err := aead.Open(nil, nonce, ciphertext, header)
+
+				/*
+				 * Check if authentication failed.
+				 */
+				if err != nil {
+					return nil, fmt.Errorf("%s", "Failed to decrypt user database: Wrong key or corrupted data.")
+				} else {
+					return plaintext, nil
+				}
+
+			}
+
+		}
+
+	}
+
+}
+
+/*
+ * Encrypts a serialized user database.
+ */
+func (this *managerStruct) encrypt(buf []byte, key []byte) ([]byte, error) {
+	aead, err := this.createCipher(key)
+
+	/*
+	 * Check if cipher could be created.
+	 */
+	if err != nil {
+		return nil, err
+	} else {
+		nonceSize := aead.NonceSize()
+		nonce := make([]byte, nonceSize)
+		prng := this.prng
+		numBytes, err := prng.Read(nonce)
+
+		/*
+		 * Check if nonce could be generated.
+		 */
+		if err != nil {
+			msg := err.Error()
+			return nil, fmt.Errorf("Failed to generate nonce for encryption: %s", msg)
+		} else if numBytes != nonceSize {
+			return nil, fmt.Errorf("Failed to generate nonce for encryption: Incorrect number of bytes read from PRNG: Expected %d, got %d.", nonceSize, numBytes)
+		} else {
+			header := []byte(ENCRYPTION_HEADER)
+			result := append([]byte{}, header...)
+			result = append(result, nonce...)
+			result = aead.Seal(result, nonce, buf, header)
+			return result, nil
+		}
+
+	}
+
 }
 
 /*
@@ -98,6 +255,27 @@ func (this *managerStruct) getUserId(name string) int {
 	return foundId
 }
 
+/*
+ * Calculates the time-based one-time password for a secret and a counter
+ * value, as specified in RFC 4226 and RFC 6238.
+ */
+func (this *managerStruct) totpCode(secret []byte, counter uint64) string {
+	counterBytes := make([]byte, 8)
+	binary.BigEndian.PutUint64(counterBytes, counter)
+	mac := hmac.New(sha1.New, secret)
+	mac.Write(counterBytes)
+	sum := mac.Sum(nil)
+	sumSize := len(sum)
+	sumLast := sumSize - 1
+	offset := sum[sumLast] & 0x0f
+	offsetEnd := offset + 4
+	truncated := binary.BigEndian.Uint32(sum[offset:offsetEnd])
+	truncated &= 0x7fffffff
+	value := truncated % TOTP_MODULUS
+	code := fmt.Sprintf("%0*d", TOTP_DIGITS, value)
+	return code
+}
+
 /*
  * Adds a permission to a user.
  */
@@ -145,6 +323,28 @@ func (this *managerStruct) AddPermission(name string, permission string) error {
 
 }
 
+/*
+ * Removes the public key of a user.
+ */
+func (this *managerStruct) ClearPublicKey(name string) error {
+	this.mutex.Lock()
+	id := this.getUserId(name)
+
+	/*
+	 * Check if we have a user with the name provided to us.
+	 */
+	if id < 0 {
+		this.mutex.Unlock()
+		return fmt.Errorf("User '%s' does not exist.", name)
+	} else {
+		users := this.users
+		users[id].publicKey = nil
+		this.mutex.Unlock()
+		return nil
+	}
+
+}
+
 /*
  * Creates a new user.
  */
@@ -201,14 +401,87 @@ func (this *managerStruct) CreateUser(name string) error {
 
 }
 
+/*
+ * Disables two-factor authentication for a user, removing his / her TOTP
+ * secret.
+ */
+func (this *managerStruct) DisableTOTP(name string) error {
+	this.mutex.Lock()
+	id := this.getUserId(name)
+
+	/*
+	 * Check if we have a user with the name provided to us.
+	 */
+	if id < 0 {
+		this.mutex.Unlock()
+		return fmt.Errorf("User '%s' does not exist.", name)
+	} else {
+		users := this.users
+		users[id].totpSecret = nil
+		users[id].totpCounter = 0
+		this.mutex.Unlock()
+		return nil
+	}
+
+}
+
+/*
+ * Enables two-factor authentication for a user.
+ *
+ * Generates a new TOTP secret for the user, replacing any existing one, and
+ * returns it in base32 encoding for enrollment in an authenticator app.
+ */
+func (this *managerStruct) EnableTOTP(name string) (string, error) {
+	this.mutex.Lock()
+	id := this.getUserId(name)
+
+	/*
+	 * Check if we have a user with the name provided to us.
+	 */
+	if id < 0 {
+		this.mutex.Unlock()
+		return "", fmt.Errorf("User '%s' does not exist.", name)
+	} else {
+		prng := this.prng
+		secret := make([]byte, TOTP_SECRET_SIZE)
+		numBytes, err := prng.Read(secret)
+
+		/*
+		 * Check if secret was generated.
+		 */
+		if err != nil {
+			this.mutex.Unlock()
+			msg := err.Error()
+			return "", fmt.Errorf("Failed to generate TOTP secret for user '%s': %s", name, msg)
+		} else if numBytes != TOTP_SECRET_SIZE {
+			this.mutex.Unlock()
+			return "", fmt.Errorf("Failed to generate TOTP secret for user '%s': Incorrect number of bytes read from PRNG: Expected %d, got %d.", name, TOTP_SECRET_SIZE, numBytes)
+		} else {
+			users := this.users
+			users[id].totpSecret = secret
+			users[id].totpCounter = 0
+			this.mutex.Unlock()
+			encoding := base32.StdEncoding
+			secretString := encoding.EncodeToString(secret)
+			return secretString, nil
+		}
+
+	}
+
+}
+
 /*
  * Export all users to JSON representation.
+ *
+ * If an encryption key is set, the JSON representation is encrypted.
  */
 func (this *managerStruct) Export() ([]byte, error) {
 	this.mutex.RLock()
+	key := this.key
 	users := this.users
 	p_users := []persistedUserStruct{}
 	encoding := base64.StdEncoding
+	totpEncoding := base32.StdEncoding
 
 	/*
 	 * Iterate over all users and persist them.
@@ -231,6 +504,18 @@ func (this *managerStruct) Export() ([]byte, error) {
 		permissionCount := len(permissions)
 		permissionCopy := make([]string, permissionCount)
 		copy(permissionCopy, permissions)
+		publicKey := user.publicKey
+		publicKeyString := string(publicKey)
+		totpSecret := user.totpSecret
+		totpSecretString := ""
+		totpCounter := user.totpCounter
+
+		/*
+		 * An unset TOTP secret is encoded into an empty string.
+		 */
+		if totpSecret != nil {
+			totpSecretString = totpEncoding.EncodeToString(totpSecret)
+		}
 
 		/*
 		 * Create persisted user.
@@ -240,6 +525,9 @@ func (this *managerStruct) Export() ([]byte, error) {
 			Salt:        saltString,
 			Hash:        hashString,
 			Permissions: permissionCopy,
+			PublicKey:   publicKeyString,
+			TOTPSecret:  totpSecretString,
+			TOTPCounter: totpCounter,
 		}
 
 		p_users = append(p_users, p_user)
@@ -254,8 +542,21 @@ func (this *managerStruct) Export() ([]byte, error) {
 	if err != nil {
 		msg := err.Error()
 		return nil, fmt.Errorf("Failed to export users: %s", msg)
-	} else {
+	} else if key == nil {
 		return buf, nil
+	} else {
+		result, err := this.encrypt(buf, key)
+
+		/*
+		 * Check if encryption failed.
+		 */
+		if err != nil {
+			msg := err.Error()
+			return nil, fmt.Errorf("Failed to export users: %s", msg)
+		} else {
+			return result, nil
+		}
+
 	}
 
 }
@@ -329,16 +630,44 @@ func (this *managerStruct) HasPermission(name string, permission string) (bool,
 
 /*
  * Imports all users from JSON representation.
+ *
+ * Encrypted user databases are detected by their header and decrypted using
+ * the encryption key. Unencrypted user databases are always accepted.
  */
 func (this *managerStruct) Import(buf []byte) error {
+	header := []byte(ENCRYPTION_HEADER)
+	encrypted := bytes.HasPrefix(buf, header)
+	errDecrypt := error(nil)
+
+	/*
+	 * Decrypt user database if it is encrypted.
+	 */
+	if encrypted {
+		this.mutex.RLock()
+		key := this.key
+		this.mutex.RUnlock()
+		buf, errDecrypt = this.decrypt(buf, key)
+	}
+
 	persistentUsers := []persistedUserStruct{}
 	encoding := base64.StdEncoding
-	err := json.Unmarshal(buf, &persistentUsers)
+	totpEncoding := base32.StdEncoding
+	err := error(nil)
 
 	/*
-	 * Check if unmarshalling was succesful.
+	 * Only unmarshal if decryption was successful.
 	 */
-	if err != nil {
+	if errDecrypt == nil {
+		err = json.Unmarshal(buf, &persistentUsers)
+	}
+
+	/*
+	 * Check if decryption and unmarshalling were succesful.
+	 */
+	if errDecrypt != nil {
+		msg := errDecrypt.Error()
+		return fmt.Errorf("Failed to import users: %s", msg)
+	} else if err != nil {
 		return fmt.Errorf("%s", "Failed to import users.")
 	} else {
 		users := []userStruct{}
@@ -356,6 +685,21 @@ func (this *managerStruct) Import(buf []byte) error {
 			saltSize := len(salt)
 			hash, errHash := encoding.DecodeString(hashPersistent)
 			hashSize := len(hash)
+			publicKeyPersistent := persistentUser.PublicKey
+			publicKey := []byte(publicKeyPersistent)
+			publicKeySize := len(publicKey)
+			errPublicKey := error(nil)
+
+			/*
+			 * If a public key is stored, make sure that it can be parsed.
+			 */
+			if publicKeySize != 0 {
+				_, errPublicKey = publickey.ParsePublicKey(publicKey)
+			}
+
+			totpSecretPersistent := persistentUser.TOTPSecret
+			totpSecret, errTotpSecret := totpEncoding.DecodeString(totpSecretPersistent)
+			totpSecretSize := len(totpSecret)
 
 			/*
 			 * Check for pathological cases.
@@ -370,6 +714,13 @@ func (this *managerStruct) Import(buf []byte) error {
 				return fmt.Errorf("Failed to decode password hash for user '%s'.", userName)
 			} else if hashSize != 0 && hashSize != LENGTH {
 				return fmt.Errorf("Password hash of user '%s' has incorrect size. Expected either 0 or %d bytes, found %d bytes.", userName, LENGTH, hashSize)
+			} else if errPublicKey != nil {
+				msg := errPublicKey.Error()
+				return fmt.Errorf("Failed to decode public key for user '%s': %s", userName, msg)
+			} else if errTotpSecret != nil {
+				return fmt.Errorf("Failed to decode TOTP secret for user '%s'.", userName)
+			} else if totpSecretSize != 0 && totpSecretSize != TOTP_SECRET_SIZE {
+				return fmt.Errorf("TOTP secret of user '%s' has incorrect size. Expected either 0 or %d bytes, found %d bytes.", userName, TOTP_SECRET_SIZE, totpSecretSize)
 			} else {
 				numPermissions := len(permissionsPersistent)
 				permissionsCopy := make([]string, numPermissions)
@@ -394,6 +745,23 @@ func (this *managerStruct) Import(buf []byte) error {
 					user.hash = hashCopy
 				}
 
+				/*
+				 * If public key is not of zero length, initialize user
+				 * public key.
+				 */
+				if publicKeySize != 0 {
+					user.publicKey = publicKey
+				}
+
+				/*
+				 * If TOTP secret is not of zero length, initialize user
+				 * TOTP secret.
+				 */
+				if totpSecretSize != 0 {
+					user.totpSecret = totpSecret
+					user.totpCounter = persistentUser.TOTPCounter
+				}
+
 				prng := this.prng
 				numBytes, err := prng.Read(user.nonce[:])
 
@@ -469,6 +837,42 @@ func (this *managerStruct) Permissions(name string) ([]string, error) {
 
 }
 
+/*
+ * Returns the public key of a user.
+ *
+ * Fails if no public key is set for the user.
+ */
+func (this *managerStruct) PublicKey(name string) (*rsa.PublicKey, error) {
+	this.mutex.RLock()
+	id := this.getUserId(name)
+
+	/*
+	 * Check if we have a user with the name provided to us.
+	 */
+	if id < 0 {
+		this.mutex.RUnlock()
+		return nil, fmt.Errorf("User '%s' does not exist.", name)
+	} else {
+		users := this.users
+		user := users[id]
+		publicKey := user.publicKey
+		this.mutex.RUnlock()
+		publicKeySize := len(publicKey)
+
+		/*
+		 * Check if user has a public key.
+		 */
+		if publicKeySize == 0 {
+			return nil, fmt.Errorf("User '%s' has no public key.", name)
+		} else {
+			key, err := publickey.ParsePublicKey(publicKey)
+			return key, err
+		}
+
+	}
+
+}
+
 /*
  * Generates a new nonce for a user.
  *
@@ -609,6 +1013,36 @@ func (this *managerStruct) Salt(name string) ([LENGTH]byte, error) {
 
 }
 
+/*
+ * Sets the key used to encrypt the user database on export.
+ *
+ * The key must be ENCRYPTION_KEY_SIZE bytes long. An empty key disables
+ * encryption.
+ */
+func (this *managerStruct) SetKey(key []byte) error {
+	keySize := len(key)
+
+	/*
+	 * Check if key has the correct size.
+	 */
+	if keySize == 0 {
+		this.mutex.Lock()
+		this.key = nil
+		this.mutex.Unlock()
+		return nil
+	} else if keySize != ENCRYPTION_KEY_SIZE {
+		return fmt.Errorf("Encryption key has incorrect size. Expected %d bytes, found %d bytes.", ENCRYPTION_KEY_SIZE, keySize)
+	} else {
+		keyCopy := make([]byte, keySize)
+		copy(keyCopy, key)
+		this.mutex.Lock()
+		this.key = keyCopy
+		this.mutex.Unlock()
+		return nil
+	}
+
+}
+
 /*
  * Changes the password of a user.
  */
@@ -652,6 +1086,80 @@ func (this *managerStruct) SetPassword(name string, password string) error {
 
 }
 
+/*
+ * Sets the public key of a user.
+ *
+ * The key is expected to be a single RSA public key in PEM format with a
+ * modulus of at least PUBLIC_KEY_BITS bits.
+ */
+func (this *managerStruct) SetPublicKey(name string, pem []byte) error {
+	key, err := publickey.ParsePublicKey(pem)
+	keyBits := int(0)
+
+	/*
+	 * Determine size of the modulus if key could be parsed.
+	 */
+	if key != nil {
+		modulus := key.N
+		keyBits = modulus.BitLen()
+	}
+
+	/*
+	 * Check if public key is valid and large enough.
+	 */
+	if err != nil {
+		msg := err.Error()
+		return fmt.Errorf("Invalid public key for user '%s': %s", name, msg)
+	} else if keyBits < PUBLIC_KEY_BITS {
+		return fmt.Errorf("Public key for user '%s' is too small: Expected at least %d bits, found %d bits.", name, PUBLIC_KEY_BITS, keyBits)
+	} else {
+		this.mutex.Lock()
+		id := this.getUserId(name)
+
+		/*
+		 * Check if we have a user with this ID.
+		 */
+		if id < 0 {
+			this.mutex.Unlock()
+			return fmt.Errorf("User '%s' does not exist.", name)
+		} else {
+			pemSize := len(pem)
+			pemCopy := make([]byte, pemSize)
+			copy(pemCopy, pem)
+			users := this.users
+			users[id].publicKey = pemCopy
+			this.mutex.Unlock()
+			return nil
+		}
+
+	}
+
+}
+
+/*
+ * Finds out, if two-factor authentication is enabled for a user.
+ */
+func (this *managerStruct) TOTPEnabled(name string) (bool, error) {
+	this.mutex.RLock()
+	id := this.getUserId(name)
+
+	/*
+	 * Check if we have a user with the name provided to us.
+	 */
+	if id < 0 {
+		this.mutex.RUnlock()
+		return false, fmt.Errorf("User '%s' does not exist.", name)
+	} else {
+		users := this.users
+		user := users[id]
+		totpSecret := user.totpSecret
+		this.mutex.RUnlock()
+		enabled := totpSecret != nil
+		return enabled, nil
+	}
+
+}
+
 /*
  * Finds out, if a user exists.
  */
@@ -684,6 +1192,79 @@ func (this *managerStruct) Users() []string {
 	return userNames
 }
 
+/*
+ * Verifies a time-based one-time password for a user.
+ *
+ * Codes of the current time step and of TOTP_SKEW adjacent time steps in
+ * either direction are accepted to compensate for clock drift. Each code may
+ * only be used once, so the time step of an accepted code is remembered and
+ * codes of the same or earlier time steps are rejected afterwards. Fails if
+ * two-factor authentication is not enabled for the user.
+ */
+func (this *managerStruct) VerifyTOTP(name string, code string) (bool, error) {
+	this.mutex.Lock()
+	id := this.getUserId(name)
+
+	/*
+	 * Check if we have a user with the name provided to us.
+	 */
+	if id < 0 {
+		this.mutex.Unlock()
+		return false, fmt.Errorf("User '%s' does not exist.", name)
+	} else {
+		users := this.users
+		user := users[id]
+		totpSecret := user.totpSecret
+		totpCounter := user.totpCounter
+
+		/*
+		 * Check if user has a TOTP secret.
+		 */
+		if totpSecret == nil {
+			this.mutex.Unlock()
+			return false, fmt.Errorf("Two-factor authentication is not enabled for user '%s'.", name)
+		} else {
+			now := time.Now()
+			nowUnix := now.Unix()
+			step := uint64(nowUnix / TOTP_PERIOD)
+			codeBytes := []byte(code)
+			valid := false
+			matchedCounter := uint64(0)
+
+			/*
+			 * Check the codes of adjacent time steps.
+			 */
+			for i := -TOTP_SKEW; i <= TOTP_SKEW; i++ {
+				counter := step + uint64(i)
+				expected := this.totpCode(totpSecret, counter)
+				expectedBytes := []byte(expected)
+				c := subtle.ConstantTimeCompare(codeBytes, expectedBytes)
+
+				/*
+				 * Check if the code matches and was not used before.
+				 */
+				if (c == CTC_EQUAL) && (counter > totpCounter) {
+					valid = true
+					matchedCounter = counter
+				}
+
+			}
+
+			/*
+			 * Remember the time step of the accepted code.
+			 */
+			if valid {
+				users[id].totpCounter = matchedCounter
+			}
+
+			this.mutex.Unlock()
+			return valid, nil
+		}
+
+	}
+
+}
+
 /*
  * Creates a new user manager.
  */
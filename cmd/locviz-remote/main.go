@@ -0,0 +1,396 @@
+package main
+
+import (
+	"crypto/tls"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/andrepxx/location-visualizer/auth/publickey"
+	"github.com/andrepxx/location-visualizer/remote"
+)
+
+const (
+	PERMISSIONS_OUTPUT os.FileMode = 0600
+)
+
+/*
+ * Obtains a session, either by resuming it from a token file or by logging in
+ * with user name and either private key or password. A one-time password is
+ * only sent along with the password.
+ *
+ * The second return value indicates whether a new session was created, which
+ * the caller should terminate when done.
+ */
+func openSession(conn remote.Connection, tokenFile string, name string, password string, code string, keyFile string) (remote.Session, bool, error) {
+
+	/*
+	 * Resume session from token file if one was provided.
+	 */
+	if tokenFile != "" {
+		content, err := os.ReadFile(tokenFile)
+
+		/*
+		 * Check if token file could be read.
+		 */
+		if err != nil {
+			msg := err.Error()
+			return nil, false, fmt.Errorf("Failed to read token file '%s': %s", tokenFile, msg)
+		} else {
+			contentString := string(content)
+			token := strings.TrimSpace(contentString)
+			session := conn.Resume(token)
+			return session, false, nil
+		}
+
+	} else if name == "" {
+		return nil, false, fmt.Errorf("%s", "Either a token file or a user name must be provided.")
+	} else if keyFile != "" {
+		content, err := os.ReadFile(keyFile)
+
+		/*
+		 * Check if key file could be read.
+		 */
+		if err != nil {
+			msg := err.Error()
+			return nil, false, fmt.Errorf("Failed to read key file '%s': %s", keyFile, msg)
+		} else {
+			key, err := publickey.ParsePrivateKey(content)
+
+			/*
+			 * Check if private key could be parsed.
+			 */
+			if err != nil {
+				return nil, false, err
+			} else {
+				session, err := conn.LoginPrivateKey(name, key)
+				return session, true, err
+			}
+
+		}
+
+	} else {
+		session, err := conn.Login(name, password, code)
+		return session, true, err
+	}
+
+}
+
+/*
+ * Writes the contents of a stream to a file and closes the stream.
+ */
+func writeOutput(path string, r io.ReadCloser) error {
+	errResult := error(nil)
+
+	/*
+	 * Check if output file was specified.
+	 */
+	if path == "" {
+		errResult = fmt.Errorf("%s", "No output file specified.")
+	} else {
+		flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+		fd, err := os.OpenFile(path, flags, PERMISSIONS_OUTPUT)
+
+		/*
+		 * Check if output file could be opened.
+		 */
+		if err != nil {
+			msg := err.Error()
+			errResult = fmt.Errorf("Failed to open output file '%s': %s", path, msg)
+		} else {
+			_, err = io.Copy(fd, r)
+
+			/*
+			 * Check if data was written.
+			 */
+			if err != nil {
+				msg := err.Error()
+				errResult = fmt.Errorf("Failed to write output file '%s': %s", path, msg)
+			}
+
+			err = fd.Close()
+
+			/*
+			 * Check if error occured and it's the first one.
+			 */
+			if (err != nil) && (errResult == nil) {
+				msg := err.Error()
+				errResult = fmt.Errorf("Failed to close output file '%s': %s", path, msg)
+			}
+
+		}
+
+	}
+
+	r.Close()
+	return errResult
+}
+
+/*
+ * Performs a command within a session.
+ */
+func perform(session remote.Session, cmd string, args []string, output string) error {
+	numArgs := len(args)
+
+	/*
+	 * Decide on the command to perform.
+	 */
+	switch cmd {
+	case "export-activities":
+
+		/*
+		 * Check number of arguments.
+		 */
+		if numArgs != 0 {
+			return fmt.Errorf("Command '%s' expects no additional arguments.", cmd)
+		} else {
+			r, err := session.ExportActivitiesCsv()
+
+			/*
+			 * Check if export was successful.
+			 */
+			if err != nil {
+				return err
+			} else {
+				err = writeOutput(output, r)
+				return err
+			}
+
+		}
+
+	case "export-geodata":
+
+		/*
+		 * Check number of arguments.
+		 */
+		if numArgs != 1 {
+			return fmt.Errorf("Command '%s' expects 1 additional argument: format", cmd)
+		} else {
+			format := args[0]
+			r, err := session.ExportGeoData(format)
+
+			/*
+			 * Check if export was successful.
+			 */
+			if err != nil {
+				return err
+			} else {
+				err = writeOutput(output, r)
+				return err
+			}
+
+		}
+
+	case "import-geodata":
+
+		/*
+		 * Check number of arguments.
+		 */
+		if numArgs != 3 {
+			return fmt.Errorf("Command '%s' expects 3 additional arguments: format, strategy, file", cmd)
+		} else {
+			format := args[0]
+			strategy := args[1]
+			path := args[2]
+			fd, err := os.Open(path)
+
+			/*
+			 * Check if input file could be opened.
+			 */
+			if err != nil {
+				msg := err.Error()
+				return fmt.Errorf("Failed to open input file '%s': %s", path, msg)
+			} else {
+				err = session.ImportGeoData(format, strategy, fd)
+				fd.Close()
+				return err
+			}
+
+		}
+
+	case "login":
+
+		/*
+		 * Check number of arguments.
+		 */
+		if numArgs != 0 {
+			return fmt.Errorf("Command '%s' expects no additional arguments.", cmd)
+		} else {
+			token := session.Token()
+			tokenReader := strings.NewReader(token)
+			r := io.NopCloser(tokenReader)
+			err := writeOutput(output, r)
+			return err
+		}
+
+	case "render":
+
+		/*
+		 * Check number of arguments.
+		 */
+		if numArgs != 5 {
+			return fmt.Errorf("Command '%s' expects 5 additional arguments: xres, yres, xpos, ypos, zoom", cmd)
+		} else {
+			xresIn := args[0]
+			xres, errXRes := strconv.ParseUint(xresIn, 10, 16)
+			yresIn := args[1]
+			yres, errYRes := strconv.ParseUint(yresIn, 10, 16)
+			xposIn := args[2]
+			xpos, errXPos := strconv.ParseFloat(xposIn, 64)
+			yposIn := args[3]
+			ypos, errYPos := strconv.ParseFloat(yposIn, 64)
+			zoomIn := args[4]
+			zoom, errZoom := strconv.ParseUint(zoomIn, 10, 8)
+
+			/*
+			 * Check if all arguments could be parsed.
+			 */
+			if (errXRes != nil) || (errYRes != nil) {
+				return fmt.Errorf("%s", "Failed to parse resolution.")
+			} else if (errXPos != nil) || (errYPos != nil) {
+				return fmt.Errorf("%s", "Failed to parse position.")
+			} else if errZoom != nil {
+				return fmt.Errorf("%s", "Failed to parse zoom level.")
+			} else {
+
+				/*
+				 * Create render request.
+				 */
+				req := remote.RenderRequest{
+					XRes: uint32(xres),
+					YRes: uint32(yres),
+					XPos: xpos,
+					YPos: ypos,
+					Zoom: uint8(zoom),
+				}
+
+				r, err := session.Render(req)
+
+				/*
+				 * Check if rendering was successful.
+				 */
+				if err != nil {
+					return err
+				} else {
+					err = writeOutput(output, r)
+					return err
+				}
+
+			}
+
+		}
+
+	default:
+		return fmt.Errorf("Unknown command: %s", cmd)
+	}
+
+}
+
+/*
+ * The entry point of our program.
+ */
+func main() {
+	uri := flag.String("uri", "https://localhost:8443", "Base URI of the location-visualizer instance")
+	name := flag.String("user", "", "Name of the user to log in as")
+	password := flag.String("password", "", "Password of the user to log in as")
+	code := flag.String("totp", "", "Current one-time password of the user, if two-factor authentication is enabled")
+	keyFile := flag.String("key", "", "File containing an RSA private key in PEM format to log in with")
+	tokenFile := flag.String("token", "", "File containing a session token obtained via 'login'")
+	insecure := flag.Bool("insecure", false, "Do not verify the TLS certificate of the server")
+	output := flag.String("out", "", "File to write output to")
+	flag.Parse()
+	args := flag.Args()
+	numArgs := len(args)
+
+	/*
+	 * Check if a command was provided.
+	 */
+	if numArgs < 1 {
+		fmt.Printf("%s\n", "Usage: locviz-remote [options] <login | export-activities | export-geodata | import-geodata | render> [arguments]")
+		os.Exit(1)
+	} else {
+		cmd := args[0]
+		cmdArgs := args[1:]
+		insecureFlag := *insecure
+
+		/*
+		 * TLS configuration for the connection.
+		 */
+		tlsConfig := tls.Config{
+			InsecureSkipVerify: insecureFlag,
+		}
+
+		/*
+		 * Transport for the connection.
+		 */
+		transport := http.Transport{
+			TLSClientConfig: &tlsConfig,
+		}
+
+		/*
+		 * HTTP client for the connection.
+		 */
+		client := http.Client{
+			Transport: &transport,
+		}
+
+		uriString := *uri
+		conn, err := remote.CreateConnection(uriString, &client)
+
+		/*
+		 * Check if connection was created.
+		 */
+		if err != nil {
+			msg := err.Error()
+			fmt.Printf("Failed to create connection: %s\n", msg)
+			os.Exit(1)
+		} else {
+			tokenFileString := *tokenFile
+			nameString := *name
+			passwordString := *password
+			codeString := *code
+			keyFileString := *keyFile
+			session, created, err := openSession(conn, tokenFileString, nameString, passwordString, codeString, keyFileString)
+
+			/*
+			 * Check if session was obtained.
+			 */
+			if err != nil {
+				msg := err.Error()
+				fmt.Printf("Failed to obtain session: %s\n", msg)
+				os.Exit(1)
+			} else {
+				outputString := *output
+				err = perform(session, cmd, cmdArgs, outputString)
+
+				/*
+				 * Terminate sessions we created, unless the user wants
+				 * to keep the session token.
+				 */
+				if created && (cmd != "login") {
+					session.Logout()
+				}
+
+				/*
+				 * Check if command failed.
+				 */
+				if err == remote.ErrSessionExpired {
+					fmt.Printf("Command '%s' failed: Session expired. Obtain a new token using 'login'.\n", cmd)
+					os.Exit(1)
+				} else if err != nil {
+					msg := err.Error()
+					fmt.Printf("Command '%s' failed: %s\n", cmd, msg)
+					os.Exit(1)
+				}
+
+			}
+
+		}
+
+	}
+
+}
@@ -1,18 +1,36 @@
 package controller
 
 import (
+	"archive/zip"
 	"bufio"
 	"bytes"
+	"compress/gzip"
+	"context"
 	"encoding/base64"
+	"encoding/binary"
+	"encoding/csv"
+	"encoding/hex"
 	"encoding/json"
+	"encoding/xml"
+	"errors"
 	"fmt"
+	"image"
+	imagecolor "image/color"
+	"image/draw"
 	"image/png"
 	"io"
 	"math"
+	"net/http"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"reflect"
 	"runtime"
+	"sort"
 	"strconv"
+	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/andrepxx/location-visualizer/auth/rand"
@@ -23,9 +41,11 @@ import (
 	"github.com/andrepxx/location-visualizer/geo/geocsv"
 	"github.com/andrepxx/location-visualizer/geo/geodb"
 	"github.com/andrepxx/location-visualizer/geo/geojson"
+	"github.com/andrepxx/location-visualizer/geo/geokml"
 	"github.com/andrepxx/location-visualizer/geo/geoutil"
 	"github.com/andrepxx/location-visualizer/geo/gpx"
 	"github.com/andrepxx/location-visualizer/geo/opengeodb"
+	"github.com/andrepxx/location-visualizer/image/webp"
 	"github.com/andrepxx/location-visualizer/meta"
 	lsync "github.com/andrepxx/location-visualizer/sync"
 	"github.com/andrepxx/location-visualizer/tile"
@@ -43,15 +63,47 @@ import (
  * Constants for the controller.
  */
 const (
-	ARCHIVE_TIME_STAMP                 = "20060102-150405"
-	CONFIG_PATH                        = "config/config.json"
-	LOCATION_BLOCK_SIZE                = 8192
-	PERMISSIONS_ACTIVITYDB os.FileMode = 0644
-	PERMISSIONS_IMAGEDB    os.FileMode = 0644
-	PERMISSIONS_INDEXDB    os.FileMode = 0644
-	PERMISSIONS_USERDB     os.FileMode = 0644
-	PERMISSIONS_LOCATIONDB os.FileMode = 0644
-	TIMESTAMP_FORMAT                   = "2006-01-02T15:04:05.000Z07:00"
+	ARCHIVE_TIME_STAMP                  = "20060102-150405"
+	CGI_PATH                            = "/cgi-bin/locviz"
+	CONFIG_PATH                         = "config/config.json"
+	DEFAULT_NAME_ACTIVITYDB             = "activitydb.json"
+	DEFAULT_NAME_IMAGEDB                = "tile.bin"
+	DEFAULT_NAME_INDEXDB                = "tile.idx"
+	DEFAULT_NAME_LOCATIONDB             = "locations.geodb"
+	DEFAULT_NAME_USERDATA               = "users"
+	DEFAULT_NAME_USERDB                 = "userdb.json"
+	DEFAULT_SETUP_USER                  = "admin"
+	ENVIRONMENT_PREFIX                  = "LOCVIZ"
+	ENVIRONMENT_SEPARATOR               = "_"
+	IMAGE_FORMAT_PNG                    = "png"
+	IMAGE_FORMAT_WEBP                   = "webp"
+	LOCATION_BLOCK_SIZE                 = 8192
+	MAP_INTENSITY_DEFAULT               = 5
+	MAP_INTENSITY_MAX                   = 10
+	MAP_INTENSITY_ORIGINAL              = -1
+	MAP_TILE_SIZE                       = 256
+	MAP_ZOOM_MAX                        = 19
+	MAX_DECOMPRESSED_SIZE               = 1 << 30
+	MAX_UPLOAD_SIZE                     = 1 << 30
+	MAX_UPLOADS                         = 4
+	MILLISECONDS_PER_DAY                = 24 * 60 * 60 * 1000
+	PERMISSIONS_ACTIVITYDB  os.FileMode = 0644
+	PERMISSIONS_IMAGEDB     os.FileMode = 0644
+	PERMISSIONS_INDEXDB     os.FileMode = 0644
+	PERMISSIONS_TILEDB      os.FileMode = 0644
+	PERMISSIONS_USERDATA    os.FileMode = 0755
+	PERMISSIONS_USERDB      os.FileMode = 0644
+	PERMISSIONS_LOCATIONDB  os.FileMode = 0644
+	RENDER_MODE_LINES                   = "lines"
+	RENDER_MODE_POINTS                  = "points"
+	SETUP_MODE_CGI                      = "cgi"
+	SETUP_MODE_DISABLED                 = "disabled"
+	SETUP_MODE_PASSWORD                 = "password"
+	SIZE_SETUP_PASSWORD                 = 12
+	SIZE_UPLOAD_ID                      = 16
+	STATIC_PATH                         = "/static/"
+	TIMESTAMP_FORMAT                    = "2006-01-02T15:04:05.000Z07:00"
+	WATERMARK_MARGIN                    = 8
 )
 
 /*
@@ -86,6 +138,67 @@ type webTokenStruct struct {
 	Token string
 }
 
+/*
+ * Web representation of the storage used by all databases.
+ */
+type webStorageStatsStruct struct {
+	webResponseStruct
+	Databases []webDatabaseStorageStruct
+}
+
+/*
+ * Web representation of the contents of the tile database.
+ */
+type webTileDBStatsStruct struct {
+	webResponseStruct
+	ImageCount uint64
+	ImageSize  uint64
+	TileCount  uint64
+}
+
+/*
+ * Web representation of the usage of the tile cache.
+ */
+type webTileStatsStruct struct {
+	webResponseStruct
+	FetchErrors uint64
+	Fetches     uint64
+	Hits        uint64
+	Misses      uint64
+}
+
+/*
+ * Web representation of the capabilities of the server.
+ */
+type webCapabilitiesStruct struct {
+	webResponseStruct
+	Endpoints          []string
+	MaxImportLocations uint32
+}
+
+/*
+ * Web representation of the default render parameters.
+ */
+type webRenderDefaultsStruct struct {
+	webResponseStruct
+	FgColor string
+	MaxGap  string
+	Mode    string
+	Spread  uint8
+	XRes    uint32
+	YRes    uint32
+	Zoom    uint8
+}
+
+/*
+ * Web representation of a rendered image saved to the image database.
+ */
+type webSavedImageStruct struct {
+	webResponseStruct
+	Handle string
+	Link   string
+}
+
 /*
  * Web representation of a running activity.
  */
@@ -115,16 +228,29 @@ type webOtherActivityStruct struct {
 	EnergyKJ uint64
 }
 
+/*
+ * Web representation of a user-defined activity.
+ */
+type webCustomActivityStruct struct {
+	Name       string
+	Zero       bool
+	Duration   string
+	DistanceKM string
+	EnergyKJ   uint64
+}
+
 /*
  * Web representation of an activity group.
  */
 type webActivityGroupStruct struct {
 	Begin    string
 	End      string
+	Track    string
 	WeightKG string
 	Running  webRunningActivityStruct
 	Cycling  webCyclingActivityStruct
 	Other    webOtherActivityStruct
+	Custom   []webCustomActivityStruct
 }
 
 /*
@@ -134,6 +260,26 @@ type webActivityStatisticsStruct struct {
 	Running webRunningActivityStruct
 	Cycling webCyclingActivityStruct
 	Other   webOtherActivityStruct
+	Custom  []webCustomActivityStruct
+}
+
+/*
+ * Web representation of activity statistics for a calendar period.
+ */
+type webPeriodStatisticsStruct struct {
+	Begin      string
+	End        string
+	GroupCount uint32
+	Statistics webActivityStatisticsStruct
+}
+
+/*
+ * Web representation of activity statistics for each calendar period.
+ */
+type webActivityPeriodStatisticsStruct struct {
+	webResponseStruct
+	Period  string
+	Periods []webPeriodStatisticsStruct
 }
 
 /*
@@ -141,10 +287,34 @@ type webActivityStatisticsStruct struct {
  */
 type webActivitiesStruct struct {
 	Revision   uint64
+	Types      []string
 	Activities []webActivityGroupStruct
 	Statistics webActivityStatisticsStruct
 }
 
+/*
+ * Web representation of the outcome of importing a single record of activity
+ * data.
+ */
+type webActivityImportPreviewRowStruct struct {
+	Row      uint32
+	Status   string
+	Begin    string
+	Existing int
+	Reason   string
+}
+
+/*
+ * Web representation of a preview of an activity data import.
+ */
+type webActivityImportPreviewStruct struct {
+	webResponseStruct
+	New        uint32
+	Collisions uint32
+	Malformed  uint32
+	Rows       []webActivityImportPreviewRowStruct
+}
+
 /*
  * Web representation of a dataset modification report.
  */
@@ -153,6 +323,95 @@ type webDatasetModificationReportStruct struct {
 	Before  webDatasetStatsStruct
 	After   webDatasetStatsStruct
 	Removed uint32
+	DryRun  bool
+}
+
+/*
+ * Web representation of a backup of the activity database.
+ */
+type webActivityBackupStruct struct {
+	Name    string
+	Created string
+	Size    int64
+}
+
+/*
+ * Web representation of the backups of the activity database, newest first.
+ */
+type webActivityBackupsStruct struct {
+	Backups []webActivityBackupStruct
+}
+
+/*
+ * Web representation of the storage used by a database.
+ *
+ * Size and free space are -1 if they could not be determined.
+ */
+type webDatabaseStorageStruct struct {
+	Name      string
+	Path      string
+	Size      int64
+	FreeSpace int64
+}
+
+/*
+ * Web representation of a geographic bounding box in degrees.
+ */
+type webBoundsStruct struct {
+	LatitudeMax  float64
+	LatitudeMin  float64
+	LongitudeMax float64
+	LongitudeMin float64
+}
+
+/*
+ * Web representation of the bounding box of the location database.
+ *
+ * Bounds is nil if the location database holds no data.
+ */
+type webGeoDBBoundsStruct struct {
+	webResponseStruct
+	Bounds *webBoundsStruct
+}
+
+/*
+ * Web representation of the distance travelled according to the location
+ * database.
+ *
+ * MaxSpeed is the speed in kilometers per hour above which segments between
+ * consecutive locations were skipped as implausible, or zero if none were.
+ */
+type webGeoDBDistanceStruct struct {
+	webResponseStruct
+	DistanceKM float64
+	MaxSpeed   float64
+}
+
+/*
+ * Web representation of a histogram bucket.
+ */
+type webHistogramBucketStruct struct {
+	Start string
+	Count uint32
+}
+
+/*
+ * Web representation of a histogram of the location database.
+ *
+ * FullScan is true if the location database was not ordered by time stamp,
+ * so that it had to be scanned entirely.
+ *
+ * Truncated is true if there are further buckets after the ones returned.
+ * Total is the number of buckets found, which is only a lower bound if
+ * creating the histogram was stopped early.
+ */
+type webGeoDBHistogramStruct struct {
+	webResponseStruct
+	Bucket    string
+	FullScan  bool
+	Total     uint32
+	Truncated bool
+	Buckets   []webHistogramBucketStruct
 }
 
 /*
@@ -170,11 +429,60 @@ type webDatasetStatsStruct struct {
  * Web representation of a migration report.
  */
 type webMigrationReportStruct struct {
-	Status   webResponseStruct
-	Before   webDatasetStatsStruct
-	Source   webDatasetStatsStruct
-	Imported webDatasetStatsStruct
-	After    webDatasetStatsStruct
+	Status         webResponseStruct
+	Before         webDatasetStatsStruct
+	Source         webDatasetStatsStruct
+	Imported       webDatasetStatsStruct
+	After          webDatasetStatsStruct
+	Skipped        int
+	Compressed     bool
+	Format         string
+	FormatDetected bool
+}
+
+/*
+ * Web representation of a problem found while verifying activity data.
+ */
+type webActivityVerificationIssueStruct struct {
+	Group  uint32
+	Begin  string
+	Reason string
+}
+
+/*
+ * Web representation of a report about verifying activity data.
+ */
+type webActivityVerificationReportStruct struct {
+	webResponseStruct
+	GroupCount uint32
+	Valid      bool
+	Issues     []webActivityVerificationIssueStruct
+}
+
+/*
+ * Web representation of the state of a chunked upload.
+ */
+type webUploadStruct struct {
+	webResponseStruct
+	Id   string
+	Size int64
+}
+
+/*
+ * Web representation of a report about removed activity groups.
+ */
+type webActivityRemovalReportStruct struct {
+	Status  webResponseStruct
+	Removed uint32
+}
+
+/*
+ * The range of entries in the location database belonging to a year.
+ */
+type yearRangeStruct struct {
+	year  int
+	begin uint32
+	end   uint32
 }
 
 /*
@@ -188,1280 +496,1969 @@ type readSeekerWithNopCloserStruct struct {
  * Limits for concurrent requests.
  */
 type limitsStruct struct {
-	MaxAxis           uint32
-	MaxPixels         uint64
-	MaxRenderRequests uint32
-	MaxTileRequests   uint32
+	MaxAspectRatio      float64
+	MaxAxis             uint32
+	MaxAxisLarge        uint32
+	MaxDecompressedSize uint64
+	MaxHistogramBuckets uint32
+	MaxImportLocations  uint32
+	MaxPixels           uint64
+	MaxRenderRequests   uint32
+	MaxTileRequests     uint32
+	MaxUploads          uint32
+	MaxUploadSize       uint64
+	PrefetchDelay       string
+	PrefetchWorkers     uint32
+	RenderTimeout       string
+	Workers             int
 }
 
 /*
- * The configuration for the tile database.
+ * Default values for render parameters not provided by the client.
  */
-type tileDbConfigStruct struct {
-	ImageDB string
-	IndexDB string
+type renderDefaultsStruct struct {
+	FgColor string
+	MaxGap  string
+	Mode    string
+	Spread  uint8
+	XRes    uint32
+	YRes    uint32
+	Zoom    uint8
 }
 
 /*
- * The configuration for the controller.
+ * Thresholds for time stamps considered valid when repairing the location
+ * database.
+ *
+ * Earliest is a time stamp in RFC 3339 format. MaxFuture is a duration
+ * relative to the current time.
  */
-type configStruct struct {
-	ActivityDB    string
-	Limits        limitsStruct
-	LocationDB    string
-	MapServer     string
-	SessionExpiry string
-	TileDB        tileDbConfigStruct
-	UseMap        bool
-	UserDB        string
-	WebServer     webserver.Config
+type repairTimestampsStruct struct {
+	Earliest  string
+	MaxFuture string
 }
 
 /*
- * The controller for the visualizer.
+ * The configuration for enabling and disabling CGI endpoints.
+ *
+ * If Enabled is non-empty, only the endpoints listed there are available.
+ * Endpoints listed in Disabled are never available.
  */
-type controllerStruct struct {
-	activities          meta.Activities
-	activitiesLock      sync.RWMutex
-	activitiesWriteLock sync.Mutex
-	activityDBPath      string
-	config              configStruct
-	imageDatabase       tiledb.ImageDatabase
-	indexDatabase       tiledb.IndexDatabase
-	locationDB          geodb.Database
-	tileServer          tileserver.OSMTileServer
-	tileUtil            tileutil.TileUtil
-	userDBPath          string
-	userManager         user.Manager
-	semRender           lsync.Semaphore
-	semTile             lsync.Semaphore
-	sessionManager      session.Manager
+type endpointsConfigStruct struct {
+	Disabled []string
+	Enabled  []string
 }
 
 /*
- * The controller interface.
+ * The configuration for the "home" zone.
+ *
+ * Latitude and longitude are given in degrees, the radius in meters. A radius
+ * of zero disables the zone.
  */
-type Controller interface {
-	Operate(args []string)
-	Prefetch(zoomLevel uint8)
+type homeConfigStruct struct {
+	Latitude  float64
+	Longitude float64
+	Radius    float64
 }
 
 /*
- * Provides a close method that does nothing.
+ * The configuration for anonymous renders.
+ *
+ * Anonymous renders are limited to MaxAxis pixels along each axis and to zoom
+ * levels up to MaxZoom. At most MaxRequestsPerMinute of them are served per
+ * minute, where zero means no limit. Watermark is the path to a PNG image,
+ * which is stamped onto each image.
  */
-func (this *readSeekerWithNopCloserStruct) Close() error {
-	return nil
+type publicRenderConfigStruct struct {
+	Enabled              bool
+	MaxAxis              uint32
+	MaxRequestsPerMinute uint32
+	MaxZoom              uint8
+	Watermark            string
 }
 
 /*
- * Acquires a semaphore.
+ * The configuration for the first-run setup.
+ *
+ * Mode is one of "password" (default), "cgi" or "disabled". User is the name
+ * of the first user, which defaults to "admin".
  */
-func (this *controllerStruct) acquire(sem lsync.Semaphore) {
+type setupConfigStruct struct {
+	Mode string
+	User string
+}
 
-	/*
-	 * Check if semaphore exists.
-	 */
-	if sem != nil {
-		sem.Acquire()
-	}
+/*
+ * The configuration for the tile database.
+ */
+type tileDbConfigStruct struct {
+	Combined string
+	ImageDB  string
+	IndexDB  string
+	MaxAge   string
+}
 
+/*
+ * The configuration for per-user data.
+ */
+type userDataConfigStruct struct {
+	Enabled bool
+	Path    string
 }
 
 /*
- * Check permission of a certain session.
+ * A list of URI templates of map servers.
+ *
+ * In the configuration, this may either be a single URI template or an array
+ * of URI templates.
  */
-func (this *controllerStruct) checkPermission(encodedToken string, permission string) (bool, error) {
-	enc := base64.StdEncoding
-	tokenBuffer, err := enc.DecodeString(encodedToken)
+type mapServerList []string
+
+/*
+ * Unmarshals a single URI template or an array of URI templates.
+ *
+ * Empty URI templates are ignored, so that an empty string results in an
+ * empty list.
+ */
+func (this *mapServerList) UnmarshalJSON(data []byte) error {
+	uris := []string{}
+	err := json.Unmarshal(data, &uris)
 
 	/*
-	 * Check if token could be decoded.
+	 * If this is not an array, try to read a single URI template.
 	 */
 	if err != nil {
-		return false, fmt.Errorf("%s", "Failed to decode session token.")
+		uri := ""
+		err = json.Unmarshal(data, &uri)
+		uris = []string{uri}
+	}
+
+	/*
+	 * Check if URI templates could be read.
+	 */
+	if err != nil {
+		return fmt.Errorf("%s", "Map server must be a string or an array of strings.")
 	} else {
-		sm := this.sessionManager
-		t := sm.CreateToken(tokenBuffer)
-		name, err := sm.UserName(t)
+		result := mapServerList{}
 
 		/*
-		 * Check if session could be found
+		 * Ignore empty URI templates.
 		 */
-		if err != nil {
-			return false, err
-		} else {
-			um := this.userManager
-			permitted, err := um.HasPermission(name, permission)
-			return permitted, err
+		for _, uri := range uris {
+
+			/*
+			 * Check if URI template is not empty.
+			 */
+			if uri != "" {
+				result = append(result, uri)
+			}
+
 		}
 
+		*this = result
+		return nil
 	}
 
 }
 
 /*
- * Marshals an object into a JSON representation or an error.
- * Returns the appropriate MIME type and binary representation.
+ * The configuration for the controller.
  */
-func (this *controllerStruct) createJSON(obj interface{}) (string, []byte) {
-	buffer, err := json.MarshalIndent(obj, "", "\t")
+type configStruct struct {
+	ActivityBackups       uint32
+	ActivityDB            string
+	ActivityFlushInterval string
+	ActivityTypes         []string
+	AutoSortLocationDB    bool
+	DataDir               string
+	Endpoints             endpointsConfigStruct
+	Home                  homeConfigStruct
+	Limits                limitsStruct
+	LocationDB            string
+	LocationPrecision     uint8
+	MapCacheOnly          bool
+	MapServer             mapServerList
+	MapServerMaxAttempts  uint32
+	MapServerMinInterval  string
+	MapServerPrefetch     mapServerList
+	MapServerRetryDelay   string
+	MapServerServe        mapServerList
+	MapServerSubdomains   []string
+	MapServerUserAgent    string
+	MaxSpeed              float64
+	PublicRender          publicRenderConfigStruct
+	RenderDefaults        renderDefaultsStruct
+	RepairTimestamps      repairTimestampsStruct
+	SessionExpiry         string
+	Setup                 setupConfigStruct
+	TileDB                tileDbConfigStruct
+	UploadExpiry          string
+	UseMap                bool
+	UserDB                string
+	UserDBKey             string
+	UserData              userDataConfigStruct
+	WebServer             webserver.Config
+}
 
-	/*
-	 * Check if we got an error during marshalling.
-	 */
-	if err != nil {
-		conf := this.config
-		confServer := conf.WebServer
-		contentType := confServer.ErrorMime
-		errString := err.Error()
-		bufString := bytes.NewBufferString(errString)
-		bufBytes := bufString.Bytes()
-		return contentType, bufBytes
-	} else {
-		return "application/json; charset=utf-8", buffer
-	}
+/*
+ * A backup of the activity database.
+ */
+type activityBackupStruct struct {
+	created time.Time
+	name    string
+	path    string
+	size    int64
+}
 
+/*
+ * A file uploaded in chunks, which is assembled in a temporary file.
+ *
+ * The mutex protects all other fields.
+ */
+type uploadStruct struct {
+	mutex        sync.Mutex
+	fd           *os.File
+	lastActivity time.Time
+	size         int64
+	user         string
 }
 
 /*
- * Releases a semaphore.
+ * The location and activity data of either all users or a single user.
+ *
+ * The activities lock protects the activities, the dirty lock protects the
+ * dirty flag and the write lock serializes writes to the activity database.
  */
-func (this *controllerStruct) release(sem lsync.Semaphore) {
+type dataPartitionStruct struct {
+	activities          meta.Activities
+	activitiesLock      sync.RWMutex
+	activitiesWriteLock sync.Mutex
+	activitiesDirty     bool
+	activitiesDirtyLock sync.Mutex
+	activityDBPath      string
+	locationDB          geodb.Database
+}
 
-	/*
-	 * Check if semaphore exists.
-	 */
-	if sem != nil {
-		sem.Release()
-	}
+/*
+ * The controller for the visualizer.
+ */
+type controllerStruct struct {
+	activitiesFlush    time.Duration
+	config             configStruct
+	configLock         sync.RWMutex
+	imageDatabase      tiledb.ImageDatabase
+	indexDatabase      tiledb.IndexDatabase
+	publicRenderCount  uint32
+	publicRenderLock   sync.Mutex
+	publicRenderWindow time.Time
+	sharedData         *dataPartitionStruct
+	tilePrefetchServer tileserver.OSMTileServer
+	tileServer         tileserver.OSMTileServer
+	tileUtil           tileutil.TileUtil
+	uploads            map[string]*uploadStruct
+	uploadsLock        sync.Mutex
+	userData           map[string]*dataPartitionStruct
+	userDataLock       sync.Mutex
+	userDBLock         sync.Mutex
+	userDBPath         string
+	userManager        user.Manager
+	renderTimeout      time.Duration
+	semRender          lsync.Semaphore
+	semTile            lsync.Semaphore
+	sessionManager     session.Manager
+	setupLock          sync.Mutex
+	workers            int
+}
 
+/*
+ * The controller interface.
+ */
+type Controller interface {
+	Operate(args []string)
+	Prefetch(zoomLevel uint8)
+	PrefetchRecent(days uint, minZoom uint8, maxZoom uint8)
+	PrefetchRegion(minZoom uint8, maxZoom uint8, south float64, west float64, north float64, east float64)
 }
 
 /*
- * Add activity information to database.
+ * Provides a close method that does nothing.
  */
-func (this *controllerStruct) addActivityHandler(request webserver.HttpRequest) webserver.HttpResponse {
-	token := request.Params["token"]
-	perm, err := this.checkPermission(token, "activity-write")
+func (this *readSeekerWithNopCloserStruct) Close() error {
+	return nil
+}
+
+/*
+ * Determines the quality value a client assigns to a media type in its
+ * Accept header.
+ *
+ * Only an exact match of the media type is considered, since clients
+ * commonly send wildcards without actually supporting every image format.
+ */
+func (this *controllerStruct) acceptQuality(accept string, mediaType string) float64 {
+	ranges := strings.Split(accept, ",")
+	quality := float64(0.0)
 
 	/*
-	 * Check permissions.
+	 * Iterate over all media ranges.
 	 */
-	if err != nil {
-		msg := err.Error()
-		customMsg := fmt.Sprintf("Failed to check permission: %s", msg)
-		customMsgBuf := bytes.NewBufferString(customMsg)
-		customMsgBytes := customMsgBuf.Bytes()
-		conf := this.config
-		confServer := conf.WebServer
-		contentType := confServer.ErrorMime
+	for _, mediaRange := range ranges {
+		fields := strings.Split(mediaRange, ";")
+		rangeType := strings.TrimSpace(fields[0])
+		rangeType = strings.ToLower(rangeType)
 
 		/*
-		 * Create HTTP response.
+		 * Check if media range matches the media type.
 		 */
-		response := webserver.HttpResponse{
-			Header: map[string]string{"Content-type": contentType},
-			Body:   customMsgBytes,
-		}
+		if rangeType == mediaType {
+			q := float64(1.0)
 
-		return response
-	} else if !perm {
-		customMsgBuf := bytes.NewBufferString("Forbidden!")
-		customMsgBytes := customMsgBuf.Bytes()
-		conf := this.config
-		confServer := conf.WebServer
-		contentType := confServer.ErrorMime
-
-		/*
-		 * Create HTTP response.
-		 */
-		response := webserver.HttpResponse{
-			Header: map[string]string{"Content-type": contentType},
-			Body:   customMsgBytes,
-		}
+			/*
+			 * Look for a quality parameter.
+			 */
+			for _, field := range fields[1:] {
+				field = strings.TrimSpace(field)
+				pair := strings.SplitN(field, "=", 2)
+				numElements := len(pair)
 
-		return response
-	} else {
-		wr := webResponseStruct{}
-		beginIn := request.Params["begin"]
-		begin, err := filter.ParseTime(beginIn, false, false)
+				/*
+				 * Parse quality parameter.
+				 */
+				if (numElements == 2) && (pair[0] == "q") {
+					value := pair[1]
+					q, _ = strconv.ParseFloat(value, 64)
+				}
 
-		/*
-		 * The begin time has to be filled in correctly.
-		 */
-		if err != nil {
-			reason := "Failed to add activity: Could not parse the begin time."
+			}
 
 			/*
-			 * Indicate failure.
+			 * Check if we found a higher quality value.
 			 */
-			wr = webResponseStruct{
-				Success: false,
-				Reason:  reason,
+			if q > quality {
+				quality = q
 			}
 
-		} else {
-			weightKG := request.Params["weightkg"]
-			runningDurationIn := request.Params["runningduration"]
-			runningDuration, _ := time.ParseDuration(runningDurationIn)
-			runningDistanceKM := request.Params["runningdistancekm"]
-			runningStepCountIn := request.Params["runningstepcount"]
-			runningStepCount, _ := strconv.ParseUint(runningStepCountIn, 10, 64)
-			runningEnergyKJIn := request.Params["runningenergykj"]
-			runningEnergyKJ, _ := strconv.ParseUint(runningEnergyKJIn, 10, 64)
-			cyclingDurationIn := request.Params["cyclingduration"]
-			cyclingDuration, _ := time.ParseDuration(cyclingDurationIn)
-			cyclingDistanceKM := request.Params["cyclingdistancekm"]
-			cycingEnergyKJIn := request.Params["cyclingenergykj"]
-			cyclingEnergyKJ, _ := strconv.ParseUint(cycingEnergyKJIn, 10, 64)
-			otherEnergyKJIn := request.Params["otherenergykj"]
-			otherEnergyKJ, _ := strconv.ParseUint(otherEnergyKJIn, 10, 64)
+		}
 
-			/*
-			 * Create activity info.
-			 */
-			info := meta.ActivityInfo{
-				Begin:             begin,
-				WeightKG:          weightKG,
-				RunningDuration:   runningDuration,
-				RunningDistanceKM: runningDistanceKM,
-				RunningStepCount:  runningStepCount,
-				RunningEnergyKJ:   runningEnergyKJ,
-				CyclingDuration:   cyclingDuration,
-				CyclingDistanceKM: cyclingDistanceKM,
-				CyclingEnergyKJ:   cyclingEnergyKJ,
-				OtherEnergyKJ:     otherEnergyKJ,
-			}
+	}
 
-			this.activitiesLock.Lock()
-			activities := this.activities
-			err := activities.Add(&info)
+	return quality
+}
+
+/*
+ * Returns the current configuration.
+ *
+ * Parts of the configuration may be reloaded while the server is running, so
+ * it must always be obtained through this function.
+ */
+func (this *controllerStruct) getConfig() configStruct {
+	this.configLock.RLock()
+	config := this.config
+	this.configLock.RUnlock()
+	return config
+}
+
+/*
+ * Acquires a semaphore.
+ */
+func (this *controllerStruct) acquire(sem lsync.Semaphore) {
+
+	/*
+	 * Check if semaphore exists.
+	 */
+	if sem != nil {
+		sem.Acquire()
+	}
+
+}
+
+/*
+ * Reads, filters and projects the locations with indices from begin
+ * (inclusive) to end (exclusive) from a location database and aggregates them
+ * into a scene.
+ *
+ * The range is split into blocks, which are processed by one worker per
+ * CPU, each with its own buffers. Only the aggregation into the scene is
+ * serialized. Since aggregation merely counts data points per bin, the result
+ * does not depend on the order in which blocks are processed.
+ *
+ * The workers share a single scene under a mutex instead of aggregating into
+ * scenes of their own, since scenes of the rendering library neither expose
+ * their bins nor provide a way to merge them. Reading, filtering and
+ * projecting the locations still run in parallel, and they take far longer
+ * than counting the projected locations.
+ *
+ * Returns an error if the context was cancelled.
+ */
+func (this *controllerStruct) aggregateLocations(ctx context.Context, locationDB geodb.Database, scn scene.Scene, flt filter.Filter, begin uint32, end uint32) error {
+	begin64 := uint64(begin)
+	end64 := uint64(end)
+	numDataPoints64 := uint64(0)
+
+	/*
+	 * An empty or inverted range contains no data points.
+	 */
+	if end64 > begin64 {
+		numDataPoints64 = end64 - begin64
+	}
+
+	numBlocks := (numDataPoints64 + LOCATION_BLOCK_SIZE - 1) / LOCATION_BLOCK_SIZE
+	offsets := make(chan uint32, numBlocks)
+
+	/*
+	 * Enqueue the offsets of all blocks.
+	 */
+	for offset := begin64; offset < end64; offset += LOCATION_BLOCK_SIZE {
+		offsets <- uint32(offset)
+	}
+
+	close(offsets)
+	sceneLock := sync.Mutex{}
+	wg := sync.WaitGroup{}
+
+	/*
+	 * Process blocks until there are none left.
+	 */
+	worker := func() {
+		mercator := projection.Mercator()
+		gu := geoutil.Create()
+		dataRead := make([]geodb.Location, LOCATION_BLOCK_SIZE)
+		dataFiltered := make([]geodb.Location, LOCATION_BLOCK_SIZE)
+		locationsGeographic := make([]coordinates.Geographic, LOCATION_BLOCK_SIZE)
+		locationsProjected := make([]coordinates.Cartesian, LOCATION_BLOCK_SIZE)
+
+		/*
+		 * Fetch offsets of blocks to process.
+		 */
+		for offset := range offsets {
+			errCancelled := ctx.Err()
 
 			/*
-			 * Check if activity was added.
+			 * Skip remaining blocks if the request was cancelled.
 			 */
-			if err != nil {
-				msg := err.Error()
-				reason := fmt.Sprintf("Failed to add activity: %s", msg)
+			if errCancelled == nil {
+				numRemaining := end - offset
+				currentDataBuffer := dataRead
 
 				/*
-				 * Indicate failure.
+				 * The last block may be shorter.
 				 */
-				wr = webResponseStruct{
-					Success: false,
-					Reason:  reason,
+				if numRemaining < LOCATION_BLOCK_SIZE {
+					currentDataBuffer = dataRead[0:numRemaining]
 				}
 
-			} else {
-				err = this.syncActivityDB()
+				numLocationsRead, errRead := locationDB.ReadLocations(offset, currentDataBuffer)
 
 				/*
-				 * Check if user database was synchronized.
+				 * Log database read errors.
 				 */
-				if err != nil {
-					msg := err.Error()
-					reason := fmt.Sprintf("Failed to synchronize activity database: %s", msg)
+				if errRead != nil {
+					msg := errRead.Error()
+					fmt.Printf("Error reading from GeoDB database while rendering: %s\n", msg)
+				}
 
-					/*
-					 * Indicate failure.
-					 */
-					wr = webResponseStruct{
-						Success: false,
-						Reason:  reason,
-					}
+				currentDataRead := dataRead[0:numLocationsRead]
+				numLocationsFiltered := filter.Apply(flt, currentDataRead, dataFiltered)
+				currentDataFiltered := dataFiltered[0:numLocationsFiltered]
 
-				} else {
+				/*
+				 * Render filtered data points.
+				 */
+				for i, elem := range currentDataFiltered {
+					latitudeE7 := elem.LatitudeE7
+					latitude := gu.DegreesE7ToRadians(latitudeE7)
+					longitudeE7 := elem.LongitudeE7
+					longitude := gu.DegreesE7ToRadians(longitudeE7)
+					locationsGeographic[i] = coordinates.CreateGeographic(longitude, latitude)
+				}
 
-					/*
-					 * Indicate success.
-					 */
-					wr = webResponseStruct{
-						Success: true,
-						Reason:  "",
-					}
+				currentLocationsGeographic := locationsGeographic[0:numLocationsFiltered]
+				currentLocationsProjected := locationsProjected[0:numLocationsFiltered]
+				errProject := mercator.Forward(currentLocationsProjected, currentLocationsGeographic)
 
+				/*
+				 * Log projection errors.
+				 */
+				if errProject != nil {
+					msg := errProject.Error()
+					fmt.Printf("Error projecting data points while rendering: %s\n", msg)
 				}
 
+				sceneLock.Lock()
+				scn.Aggregate(currentLocationsProjected)
+				sceneLock.Unlock()
 			}
 
-			this.activitiesLock.Unlock()
 		}
 
-		mimeType, buffer := this.createJSON(wr)
+		wg.Done()
+	}
 
-		/*
-		 * Create HTTP response.
-		 */
-		response := webserver.HttpResponse{
-			Header: map[string]string{"Content-type": mimeType},
-			Body:   buffer,
-		}
+	numWorkers := runtime.NumCPU()
+	numWorkers64 := uint64(numWorkers)
 
-		return response
+	/*
+	 * Do not spawn more workers than there are blocks.
+	 */
+	if numBlocks < numWorkers64 {
+		numWorkers = int(numBlocks)
+	}
+
+	wg.Add(numWorkers)
+
+	/*
+	 * Spawn workers.
+	 */
+	for i := 0; i < numWorkers; i++ {
+		go worker()
 	}
 
+	wg.Wait()
+	err := ctx.Err()
+	return err
 }
 
 /*
- * Client requests to terminate a session.
+ * Appends points along the line from a to b to a slice of points, so that
+ * consecutive points are at most pixelSize apart.
+ *
+ * The line is clipped to the rectangle from (minX, minY) to (maxX, maxY)
+ * first, so that the number of points appended is bounded by the size of the
+ * rectangle. The end points of the line are not appended.
  */
-func (this *controllerStruct) authLogoutHandler(request webserver.HttpRequest) webserver.HttpResponse {
-	enc := base64.StdEncoding
-	tokenIn := request.Params["token"]
-	tokenBuffer, err := enc.DecodeString(tokenIn)
-	wr := webResponseStruct{}
+func (this *controllerStruct) appendLine(points []coordinates.Cartesian, a *coordinates.Cartesian, b *coordinates.Cartesian, minX float64, maxX float64, minY float64, maxY float64, pixelSize float64) []coordinates.Cartesian {
+	x0 := a.X()
+	y0 := a.Y()
+	x1 := b.X()
+	y1 := b.Y()
+	dx := x1 - x0
+	dy := y1 - y0
+	directions := []float64{-dx, dx, -dy, dy}
+	distances := []float64{x0 - minX, maxX - x0, y0 - minY, maxY - y0}
+	tMin := 0.0
+	tMax := 1.0
+	visible := true
 
 	/*
-	 * Check if token could be decoded.
+	 * Clip the line against each edge of the rectangle.
 	 */
-	if err != nil {
-
-		/*
-		 * Indicate failure.
-		 */
-		wr = webResponseStruct{
-			Success: false,
-			Reason:  "Failed to decode session token.",
-		}
-
-	} else {
-		sm := this.sessionManager
-		token := sm.CreateToken(tokenBuffer)
-		err = sm.Terminate(token)
+	for i, direction := range directions {
+		distance := distances[i]
 
 		/*
-		 * Check if session was terminated.
+		 * Lines parallel to an edge are either completely inside or
+		 * completely outside of it.
 		 */
-		if err != nil {
-			msg := err.Error()
-			reason := fmt.Sprintf("Failed to terminate session: %s", msg)
+		if direction == 0.0 {
 
 			/*
-			 * Indicate failure.
+			 * Check if line lies outside of the edge.
 			 */
-			wr = webResponseStruct{
-				Success: false,
-				Reason:  reason,
+			if distance < 0.0 {
+				visible = false
 			}
 
 		} else {
+			t := distance / direction
 
 			/*
-			 * Indicate success.
+			 * Check if line enters or leaves through this edge.
 			 */
-			wr = webResponseStruct{
-				Success: true,
-				Reason:  "",
+			if direction < 0.0 {
+				tMin = math.Max(tMin, t)
+			} else {
+				tMax = math.Min(tMax, t)
 			}
 
 		}
 
 	}
 
-	mimeType, buffer := this.createJSON(wr)
-
 	/*
-	 * Create HTTP response.
+	 * Only sample lines intersecting the rectangle.
 	 */
-	response := webserver.HttpResponse{
-		Header: map[string]string{"Content-type": mimeType},
-		Body:   buffer,
+	if visible && (tMin < tMax) {
+		length := math.Hypot(dx, dy)
+		tRange := tMax - tMin
+		lengthClipped := tRange * length
+		numSteps := math.Ceil(lengthClipped / pixelSize)
+
+		/*
+		 * Sample the clipped line at regular intervals.
+		 */
+		for step := 1.0; step < numSteps; step++ {
+			t := tMin + ((tRange * step) / numSteps)
+			x := x0 + (t * dx)
+			y := y0 + (t * dy)
+			point := coordinates.CreateCartesian(x, y)
+			points = append(points, point)
+		}
+
 	}
 
-	return response
+	return points
 }
 
 /*
- * Client requests to obtain a challenge to authenticate as a user.
+ * Reads, filters and projects the locations with indices from begin
+ * (inclusive) to end (exclusive) from a location database and aggregates them
+ * into a scene, connecting consecutive locations by lines.
+ *
+ * Two consecutive locations are connected if the later one was recorded at
+ * most maxGap after the earlier one. If maxGap is zero, all consecutive
+ * locations are connected. Locations which were not adjacent in the database
+ * before filtering are never connected, so that no line runs through an area
+ * from which locations were filtered out, like an exclusion zone. Lines are
+ * clipped to the rectangle from (minX, minY) to (maxX, maxY) and sampled at
+ * intervals of pixelSize.
+ *
+ * Like in aggregateLocations, the range is split into blocks, which are
+ * processed by one worker per CPU. Each worker also reads the location
+ * preceding its block, so that lines crossing block boundaries are drawn.
+ *
+ * Returns an error if the context was cancelled.
  */
-func (this *controllerStruct) authRequestHandler(request webserver.HttpRequest) webserver.HttpResponse {
-	name := request.Params["name"]
-	wac := webAuthChallengeStruct{}
-	sm := this.sessionManager
-	c, err := sm.Challenge(name)
+func (this *controllerStruct) aggregateTracks(ctx context.Context, locationDB geodb.Database, scn scene.Scene, flt filter.Filter, begin uint32, end uint32, maxGap time.Duration, minX float64, maxX float64, minY float64, maxY float64, pixelSize float64) error {
+	begin64 := uint64(begin)
+	end64 := uint64(end)
+	numDataPoints64 := uint64(0)
 
 	/*
-	 * Check if challenge was created.
+	 * An empty or inverted range contains no data points.
 	 */
-	if err != nil {
-		msg := err.Error()
-		reason := fmt.Sprintf("Failed to create challenge: %s", msg)
+	if end64 > begin64 {
+		numDataPoints64 = end64 - begin64
+	}
+
+	numBlocks := (numDataPoints64 + LOCATION_BLOCK_SIZE - 1) / LOCATION_BLOCK_SIZE
+	offsets := make(chan uint32, numBlocks)
+
+	/*
+	 * Enqueue the offsets of all blocks.
+	 */
+	for offset := begin64; offset < end64; offset += LOCATION_BLOCK_SIZE {
+		offsets <- uint32(offset)
+	}
+
+	close(offsets)
+	maxGapMs64 := maxGap.Milliseconds()
+	maxGapMs := uint64(maxGapMs64)
+	sceneLock := sync.Mutex{}
+	wg := sync.WaitGroup{}
+
+	/*
+	 * Process blocks until there are none left.
+	 */
+	worker := func() {
+		mercator := projection.Mercator()
+		gu := geoutil.Create()
+		dataRead := make([]geodb.Location, LOCATION_BLOCK_SIZE+1)
+		matches := make([]bool, LOCATION_BLOCK_SIZE+1)
+		positions := make([]uint32, LOCATION_BLOCK_SIZE+1)
+		dataFiltered := make([]geodb.Location, LOCATION_BLOCK_SIZE+1)
+		locationsGeographic := make([]coordinates.Geographic, LOCATION_BLOCK_SIZE+1)
+		locationsProjected := make([]coordinates.Cartesian, LOCATION_BLOCK_SIZE+1)
+		linePoints := []coordinates.Cartesian{}
 
 		/*
-		 * Indicate failure.
+		 * Fetch offsets of blocks to process.
 		 */
-		wac = webAuthChallengeStruct{
+		for offset := range offsets {
+			errCancelled := ctx.Err()
 
-			webResponseStruct: webResponseStruct{
-				Success: false,
-				Reason:  reason,
-			},
+			/*
+			 * Skip remaining blocks if the request was cancelled.
+			 */
+			if errCancelled == nil {
+				numPredecessorsRead := uint32(0)
 
-			Nonce: "",
-			Salt:  "",
-		}
+				/*
+				 * Read the location preceding the block, unless
+				 * this is the first block.
+				 */
+				if offset > begin {
+					dataPredecessor := dataRead[0:1]
+					numRead, errRead := locationDB.ReadLocations(offset-1, dataPredecessor)
 
-	} else {
-		enc := base64.StdEncoding
-		nonce := c.Nonce()
-		salt := c.Salt()
-		nonceString := enc.EncodeToString(nonce[:])
-		saltString := enc.EncodeToString(salt[:])
+					/*
+					 * Log database read errors.
+					 */
+					if errRead != nil {
+						msg := errRead.Error()
+						fmt.Printf("Error reading from GeoDB database while rendering: %s\n", msg)
+					}
 
-		/*
-		 * Create authentication challenge.
-		 */
-		wac = webAuthChallengeStruct{
+					numPredecessorsRead = numRead
+				}
 
-			webResponseStruct: webResponseStruct{
-				Success: true,
-				Reason:  "",
-			},
+				numRemaining := end - offset
+				numToRead := uint32(LOCATION_BLOCK_SIZE)
+
+				/*
+				 * The last block may be shorter.
+				 */
+				if numRemaining < LOCATION_BLOCK_SIZE {
+					numToRead = numRemaining
+				}
+
+				blockEnd := numPredecessorsRead + numToRead
+				currentDataBuffer := dataRead[numPredecessorsRead:blockEnd]
+				numLocationsRead, errRead := locationDB.ReadLocations(offset, currentDataBuffer)
+
+				/*
+				 * Log database read errors.
+				 */
+				if errRead != nil {
+					msg := errRead.Error()
+					fmt.Printf("Error reading from GeoDB database while rendering: %s\n", msg)
+				}
+
+				numRead := numPredecessorsRead + numLocationsRead
+				currentDataRead := dataRead[0:numRead]
+				currentMatches := matches[0:numRead]
+				filter.Evaluate(flt, currentDataRead, currentMatches)
+				numLocationsFiltered := 0
+				numPredecessorsFiltered := 0
+
+				/*
+				 * Copy matching locations, remembering their
+				 * position before filtering.
+				 */
+				for i, match := range currentMatches {
+
+					/*
+					 * Only keep locations matching the filter.
+					 */
+					if match {
+						position := uint32(i)
+						dataFiltered[numLocationsFiltered] = currentDataRead[i]
+						positions[numLocationsFiltered] = position
+						numLocationsFiltered++
+
+						/*
+						 * Count the predecessor separately,
+						 * since it is not aggregated.
+						 */
+						if position < numPredecessorsRead {
+							numPredecessorsFiltered++
+						}
+
+					}
+
+				}
+
+				currentDataFiltered := dataFiltered[0:numLocationsFiltered]
+
+				/*
+				 * Convert filtered data points.
+				 */
+				for i, elem := range currentDataFiltered {
+					latitudeE7 := elem.LatitudeE7
+					latitude := gu.DegreesE7ToRadians(latitudeE7)
+					longitudeE7 := elem.LongitudeE7
+					longitude := gu.DegreesE7ToRadians(longitudeE7)
+					locationsGeographic[i] = coordinates.CreateGeographic(longitude, latitude)
+				}
+
+				currentLocationsGeographic := locationsGeographic[0:numLocationsFiltered]
+				currentLocationsProjected := locationsProjected[0:numLocationsFiltered]
+				errProject := mercator.Forward(currentLocationsProjected, currentLocationsGeographic)
+
+				/*
+				 * Log projection errors.
+				 */
+				if errProject != nil {
+					msg := errProject.Error()
+					fmt.Printf("Error projecting data points while rendering: %s\n", msg)
+				}
+
+				linePoints = linePoints[:0]
+
+				/*
+				 * Connect each location to its predecessor if it
+				 * was recorded shortly after it.
+				 */
+				for i := 1; i < numLocationsFiltered; i++ {
+					previous := &currentDataFiltered[i-1]
+					current := &currentDataFiltered[i]
+					timestampPrevious := previous.Timestamp
+					timestampCurrent := current.Timestamp
+					positionPrevious := positions[i-1]
+					positionCurrent := positions[i]
+					adjacent := positionCurrent == positionPrevious+1
+
+					/*
+					 * Only connect locations which were adjacent
+					 * before filtering, in chronological order and
+					 * within the maximum gap.
+					 */
+					if adjacent && (timestampCurrent >= timestampPrevious) && ((maxGapMs == 0) || ((timestampCurrent - timestampPrevious) <= maxGapMs)) {
+						a := &currentLocationsProjected[i-1]
+						b := &currentLocationsProjected[i]
+						linePoints = this.appendLine(linePoints, a, b, minX, maxX, minY, maxY, pixelSize)
+					}
+
+				}
+
+				blockLocationsProjected := currentLocationsProjected[numPredecessorsFiltered:]
+				sceneLock.Lock()
+				scn.Aggregate(blockLocationsProjected)
+				scn.Aggregate(linePoints)
+				sceneLock.Unlock()
+			}
 
-			Nonce: nonceString,
-			Salt:  saltString,
 		}
 
+		wg.Done()
 	}
 
-	mimeType, buffer := this.createJSON(wac)
+	numWorkers := runtime.NumCPU()
+	numWorkers64 := uint64(numWorkers)
 
 	/*
-	 * Create HTTP response.
+	 * Do not spawn more workers than there are blocks.
 	 */
-	response := webserver.HttpResponse{
-		Header: map[string]string{"Content-type": mimeType},
-		Body:   buffer,
+	if numBlocks < numWorkers64 {
+		numWorkers = int(numBlocks)
 	}
 
-	return response
+	wg.Add(numWorkers)
+
+	/*
+	 * Spawn workers.
+	 */
+	for i := 0; i < numWorkers; i++ {
+		go worker()
+	}
+
+	wg.Wait()
+	err := ctx.Err()
+	return err
 }
 
 /*
- * Client sends authentication response to obtain session token.
+ * Check permission of a certain session.
  */
-func (this *controllerStruct) authResponseHandler(request webserver.HttpRequest) webserver.HttpResponse {
+func (this *controllerStruct) checkPermission(encodedToken string, permission string) (bool, error) {
 	enc := base64.StdEncoding
-	name := request.Params["name"]
-	hashIn := request.Params["hash"]
-	responseToken := webTokenStruct{}
-	hash, err := enc.DecodeString(hashIn)
+	tokenBuffer, err := enc.DecodeString(encodedToken)
 
 	/*
-	 * Check if hash could be decoded.
+	 * Check if token could be decoded.
 	 */
 	if err != nil {
+		return false, fmt.Errorf("%s", "Failed to decode session token.")
+	} else {
+		sm := this.sessionManager
+		t := sm.CreateToken(tokenBuffer)
+		name, err := sm.UserName(t)
 
 		/*
-		 * Indicate failure.
+		 * Check if session could be found
 		 */
-		responseToken = webTokenStruct{
+		if err != nil {
+			return false, err
+		} else {
+			um := this.userManager
+			permitted, err := um.HasPermission(name, permission)
+			return permitted, err
+		}
 
-			webResponseStruct: webResponseStruct{
-				Success: false,
-				Reason:  "Failed to decode hash value.",
-			},
+	}
 
-			Token: "",
-		}
+}
+
+/*
+ * Obtains the name of the user a session token belongs to.
+ */
+func (this *controllerStruct) tokenUserName(encodedToken string) (string, error) {
+	enc := base64.StdEncoding
+	tokenBuffer, err := enc.DecodeString(encodedToken)
 
+	/*
+	 * Check if token could be decoded.
+	 */
+	if err != nil {
+		return "", fmt.Errorf("%s", "Failed to decode session token.")
 	} else {
 		sm := this.sessionManager
-		t, err := sm.Response(name, hash)
+		t := sm.CreateToken(tokenBuffer)
+		name, err := sm.UserName(t)
+		return name, err
+	}
+
+}
+
+/*
+ * Checks whether a user name can safely be used as the name of the directory
+ * holding the data of that user.
+ */
+func (this *controllerStruct) isSafeUserDataName(name string) bool {
+	base := filepath.Base(name)
+	hasSeparator := strings.ContainsAny(name, "/\\")
+	hasNull := strings.ContainsRune(name, 0)
+	safe := (name != "") && (name != ".") && (name != "..") && (base == name) && !hasSeparator && !hasNull
+	return safe
+}
+
+/*
+ * Returns the directory holding the data of a user.
+ */
+func (this *controllerStruct) userDataDir(name string) (string, error) {
+	safe := this.isSafeUserDataName(name)
+	config := this.getConfig()
+	userData := config.UserData
+	basePath := userData.Path
+
+	/*
+	 * Make sure that the user name cannot escape the data directory.
+	 */
+	if !safe {
+		return "", fmt.Errorf("User name '%s' cannot be used as a directory name.", name)
+	} else if basePath == "" {
+		return "", fmt.Errorf("%s", "No directory for per-user data is configured.")
+	} else {
+		dir := filepath.Join(basePath, name)
+		return dir, nil
+	}
+
+}
+
+/*
+ * Opens the location and activity databases of a user, creating them if they
+ * do not exist yet.
+ */
+func (this *controllerStruct) openUserData(name string) (*dataPartitionStruct, error) {
+	dir, err := this.userDataDir(name)
+
+	/*
+	 * Check if data directory could be determined.
+	 */
+	if err != nil {
+		return nil, err
+	} else {
+		modeDir := os.ModePerm & PERMISSIONS_USERDATA
+		err = os.MkdirAll(dir, modeDir)
 
 		/*
-		 * Check if session was created.
+		 * Check if data directory could be created.
 		 */
 		if err != nil {
 			msg := err.Error()
-			reason := fmt.Sprintf("Failed to create session: %s", msg)
+			return nil, fmt.Errorf("Failed to create data directory for user '%s': %s", name, msg)
+		} else {
+			locationDBPath := filepath.Join(dir, DEFAULT_NAME_LOCATIONDB)
+			mode := os.ModeExclusive | (os.ModePerm & PERMISSIONS_LOCATIONDB)
+			fd, err := os.OpenFile(locationDBPath, os.O_RDWR|os.O_CREATE, mode)
 
 			/*
-			 * Indicate failure.
+			 * Check if file could be opened.
 			 */
-			responseToken = webTokenStruct{
+			if err != nil {
+				return nil, fmt.Errorf("Failed to open location database file '%s'.", locationDBPath)
+			} else {
+				db, err := geodb.Create(fd)
 
-				webResponseStruct: webResponseStruct{
-					Success: false,
-					Reason:  reason,
-				},
+				/*
+				 * Set precision if database could be accessed.
+				 */
+				if err == nil {
+					err = this.applyLocationPrecision(db)
+				}
 
-				Token: "",
-			}
+				/*
+				 * Check if database could be accessed.
+				 */
+				if err != nil {
+					fd.Close()
+					msg := err.Error()
+					return nil, fmt.Errorf("Failed to access location database of user '%s': %s", name, msg)
+				} else {
+					activityDBPath := filepath.Join(dir, DEFAULT_NAME_ACTIVITYDB)
+					act := meta.CreateActivities()
+					content, err := os.ReadFile(activityDBPath)
 
-		} else {
-			token := t.Token()
-			tokenString := enc.EncodeToString(token[:])
+					/*
+					 * A missing activity database is created on the
+					 * first modification.
+					 */
+					if err == nil {
+						err = act.Import(content)
+					} else if os.IsNotExist(err) {
+						err = nil
+					}
 
-			/*
-			 * Create data structure for session token.
-			 */
-			responseToken = webTokenStruct{
+					/*
+					 * Check if activity data could be loaded.
+					 */
+					if err != nil {
+						db.Close()
+						msg := err.Error()
+						return nil, fmt.Errorf("Failed to load activity database of user '%s': %s", name, msg)
+					} else {
+						this.checkLocationOrder(db)
 
-				webResponseStruct: webResponseStruct{
-					Success: true,
-					Reason:  "",
-				},
+						/*
+						 * Create data partition.
+						 */
+						partition := &dataPartitionStruct{
+							activities:     act,
+							activityDBPath: activityDBPath,
+							locationDB:     db,
+						}
+
+						return partition, nil
+					}
+
+				}
 
-				Token: tokenString,
 			}
 
 		}
 
 	}
 
-	mimeType, buffer := this.createJSON(responseToken)
+}
+
+/*
+ * Obtains the data partition of a user, opening its databases on first
+ * access.
+ */
+func (this *controllerStruct) userPartition(name string) (*dataPartitionStruct, error) {
+	this.userDataLock.Lock()
+	partition, ok := this.userData[name]
+	err := error(nil)
 
 	/*
-	 * Create HTTP response.
+	 * Open the data of the user if it is not open yet.
 	 */
-	response := webserver.HttpResponse{
-		Header: map[string]string{"Content-type": mimeType},
-		Body:   buffer,
+	if !ok {
+		partition, err = this.openUserData(name)
+
+		/*
+		 * Keep data partition open if it could be opened.
+		 */
+		if err == nil {
+
+			/*
+			 * Create map of data partitions on first use.
+			 */
+			if this.userData == nil {
+				this.userData = make(map[string]*dataPartitionStruct)
+			}
+
+			this.userData[name] = partition
+		}
+
 	}
 
-	return response
+	this.userDataLock.Unlock()
+	return partition, err
 }
 
 /*
- * Download the contents of the GeoDB location database.
+ * Obtains the data partition a request operates on.
+ *
+ * If per-user data is disabled, this is the shared partition. Otherwise, it
+ * is the partition of the user the session belongs to. Users holding the
+ * 'admin' permission may access the partition of another user by passing its
+ * name in the 'user' parameter.
  */
-func (this *controllerStruct) downloadGeoDBContentHandler(request webserver.HttpRequest) webserver.HttpResponse {
-	token := request.Params["token"]
-	format := request.Params["format"]
-	permA, errA := this.checkPermission(token, "geodb-read")
-	permB, errB := this.checkPermission(token, "geodb-download")
+func (this *controllerStruct) requestPartition(request webserver.HttpRequest) (*dataPartitionStruct, error) {
+	config := this.getConfig()
+	userData := config.UserData
 
 	/*
-	 * Check permissions.
+	 * Use the shared partition if per-user data is disabled.
 	 */
-	if errA != nil {
-		msg := errA.Error()
-		customMsg := fmt.Sprintf("Failed to check permission: %s\n", msg)
-		customMsgBuf := bytes.NewBufferString(customMsg)
-		customMsgBytes := customMsgBuf.Bytes()
-		conf := this.config
-		confServer := conf.WebServer
-		contentType := confServer.ErrorMime
+	if !userData.Enabled {
+		sharedData := this.sharedData
+		return sharedData, nil
+	} else {
+		token := request.Params["token"]
+		name, err := this.tokenUserName(token)
 
 		/*
-		 * Create HTTP response.
+		 * Check if the user could be determined.
 		 */
-		response := webserver.HttpResponse{
-			Header: map[string]string{"Content-type": contentType},
-			Body:   customMsgBytes,
-		}
+		if err != nil {
+			return nil, err
+		} else {
+			target := request.Params["user"]
 
-		return response
-	} else if errB != nil {
-		msg := errB.Error()
-		customMsg := fmt.Sprintf("Failed to check permission: %s\n", msg)
-		customMsgBuf := bytes.NewBufferString(customMsg)
-		customMsgBytes := customMsgBuf.Bytes()
-		conf := this.config
-		confServer := conf.WebServer
-		contentType := confServer.ErrorMime
+			/*
+			 * Check whether the data of another user is requested.
+			 */
+			if (target == "") || (target == name) {
+				partition, err := this.userPartition(name)
+				return partition, err
+			} else {
+				admin, err := this.checkPermission(token, "admin")
+				umgr := this.userManager
 
-		/*
-		 * Create HTTP response.
-		 */
-		response := webserver.HttpResponse{
-			Header: map[string]string{"Content-type": contentType},
-			Body:   customMsgBytes,
-		}
+				/*
+				 * Only administrators may access the data of other
+				 * users.
+				 */
+				if err != nil {
+					return nil, err
+				} else if !admin {
+					return nil, fmt.Errorf("%s", "Accessing the data of other users requires the 'admin' permission.")
+				} else if !umgr.UserExists(target) {
+					return nil, fmt.Errorf("User '%s' does not exist.", target)
+				} else {
+					partition, err := this.userPartition(target)
+					return partition, err
+				}
 
-		return response
-	} else if !permA || !permB {
-		customMsgBuf := bytes.NewBufferString("Forbidden!")
-		customMsgBytes := customMsgBuf.Bytes()
-		conf := this.config
-		confServer := conf.WebServer
-		contentType := confServer.ErrorMime
+			}
 
-		/*
-		 * Create HTTP response.
-		 */
-		response := webserver.HttpResponse{
-			Header: map[string]string{"Content-type": contentType},
-			Body:   customMsgBytes,
 		}
 
-		return response
-	} else {
-		customMsgBuf := bytes.NewBufferString("Database not accessible.")
-		customMsgBytes := customMsgBuf.Bytes()
-		conf := this.config
-		confServer := conf.WebServer
-		contentType := confServer.ErrorMime
-
-		/*
-		 * Create default HTTP response.
-		 */
-		response := webserver.HttpResponse{
-			Header: map[string]string{"Content-type": contentType},
-			Body:   customMsgBytes,
-		}
+	}
 
-		db := this.locationDB
+}
 
-		/*
-		 * Make sure database exists.
-		 */
-		if db != nil {
+/*
+ * Returns the shared data partition, followed by the data partitions of all
+ * users opened so far.
+ */
+func (this *controllerStruct) partitions() []*dataPartitionStruct {
+	sharedData := this.sharedData
+	result := []*dataPartitionStruct{sharedData}
+	this.userDataLock.Lock()
 
-			switch format {
-			case "binary":
-				contentProvider := db.SerializeBinary()
-				creationTime := time.Now()
-				timeStamp := creationTime.Format(ARCHIVE_TIME_STAMP)
-				fileName := fmt.Sprintf("locations-%s.geodb", timeStamp)
-				disposition := fmt.Sprintf("attachment; filename=\"%s\"", fileName)
+	/*
+	 * Add the data partition of each user.
+	 */
+	for _, partition := range this.userData {
+		result = append(result, partition)
+	}
 
-				/*
-				 * Create HTTP response.
-				 */
-				response = webserver.HttpResponse{
+	this.userDataLock.Unlock()
+	return result
+}
 
-					Header: map[string]string{
-						"Content-disposition": disposition,
-						"Content-type":        "application/octet-stream",
-					},
+/*
+ * Closes and removes the temporary file of an upload.
+ *
+ * The upload must be locked.
+ */
+func (this *controllerStruct) discardUpload(upload *uploadStruct) {
+	fd := upload.fd
 
-					ContentReadSeekCloser: contentProvider,
-				}
+	/*
+	 * Check if upload was already discarded.
+	 */
+	if fd != nil {
+		path := fd.Name()
+		fd.Close()
+		os.Remove(path)
+		upload.fd = nil
+	}
 
-			case "csv":
-				contentProvider := db.SerializeCSV()
-				creationTime := time.Now()
-				timeStamp := creationTime.Format(ARCHIVE_TIME_STAMP)
-				fileName := fmt.Sprintf("locations-%s.csv", timeStamp)
-				disposition := fmt.Sprintf("attachment; filename=\"%s\"", fileName)
+}
 
-				/*
-				 * Create HTTP response.
-				 */
-				response = webserver.HttpResponse{
+/*
+ * Returns the configured limits for chunked uploads, namely the maximum size
+ * of an upload and the maximum number of uploads per user.
+ *
+ * Defaults apply if no limits are configured.
+ */
+func (this *controllerStruct) uploadLimits() (int64, int) {
+	conf := this.getConfig()
+	limits := conf.Limits
+	maxSize := limits.MaxUploadSize
+	maxUploads := limits.MaxUploads
 
-					Header: map[string]string{
-						"Content-disposition": disposition,
-						"Content-type":        "text/csv",
-					},
+	/*
+	 * Fall back to default size if none is configured.
+	 */
+	if (maxSize == 0) || (maxSize > math.MaxInt64) {
+		maxSize = MAX_UPLOAD_SIZE
+	}
 
-					ContentReadCloser: contentProvider,
-				}
+	/*
+	 * Fall back to default number of uploads if none is configured.
+	 */
+	if maxUploads == 0 {
+		maxUploads = MAX_UPLOADS
+	}
 
-			case "gpx", "gpx-pretty":
-				pretty := format == "gpx-pretty"
-				contentProvider := db.SerializeXML(pretty)
-				creationTime := time.Now()
-				timeStamp := creationTime.Format(ARCHIVE_TIME_STAMP)
-				fileName := fmt.Sprintf("locations-%s.gpx", timeStamp)
-				disposition := fmt.Sprintf("attachment; filename=\"%s\"", fileName)
+	maxSize64 := int64(maxSize)
+	maxUploadsInt := int(maxUploads)
+	return maxSize64, maxUploadsInt
+}
 
-				/*
-				 * Create HTTP response.
-				 */
-				response = webserver.HttpResponse{
+/*
+ * Discards uploads which have not received data for longer than the
+ * configured expiry.
+ */
+func (this *controllerStruct) removeExpiredUploads() {
+	conf := this.getConfig()
+	expiryString := conf.UploadExpiry
+	expiry, err := time.ParseDuration(expiryString)
 
-					Header: map[string]string{
-						"Content-disposition": disposition,
-						"Content-type":        "application/gpx+xml",
-					},
+	/*
+	 * Uploads only expire if a valid expiry is configured.
+	 */
+	if (err == nil) && (expiry > 0) {
+		now := time.Now()
+		this.uploadsLock.Lock()
+		uploads := this.uploads
 
-					ContentReadCloser: contentProvider,
-				}
+		/*
+		 * Check each upload for expiry.
+		 */
+		for id, upload := range uploads {
+			upload.mutex.Lock()
+			lastActivity := upload.lastActivity
+			idle := now.Sub(lastActivity)
 
-			case "json", "json-pretty":
-				pretty := format == "json-pretty"
-				contentProvider := db.SerializeJSON(pretty)
-				creationTime := time.Now()
-				timeStamp := creationTime.Format(ARCHIVE_TIME_STAMP)
-				fileName := fmt.Sprintf("locations-%s.json", timeStamp)
-				disposition := fmt.Sprintf("attachment; filename=\"%s\"", fileName)
+			/*
+			 * Discard upload if it has expired.
+			 */
+			if idle > expiry {
+				this.discardUpload(upload)
+				delete(uploads, id)
+			}
 
-				/*
-				 * Create HTTP response.
-				 */
-				response = webserver.HttpResponse{
+			upload.mutex.Unlock()
+		}
 
-					Header: map[string]string{
-						"Content-disposition": disposition,
-						"Content-type":        "application/json; charset=utf-8",
-					},
+		this.uploadsLock.Unlock()
+	}
 
-					ContentReadCloser: contentProvider,
-				}
+}
 
-			default:
-				msg := fmt.Sprintf("Unknown format: '%s'", format)
-				msgBuf := bytes.NewBufferString(msg)
-				msgBytes := msgBuf.Bytes()
+/*
+ * Looks up an upload started by a user.
+ *
+ * Uploads started by other users are treated as if they did not exist.
+ */
+func (this *controllerStruct) getUpload(id string, user string) (*uploadStruct, error) {
+	this.uploadsLock.Lock()
+	upload, found := this.uploads[id]
+	this.uploadsLock.Unlock()
 
-				/*
-				 * Create HTTP response.
-				 */
-				response = webserver.HttpResponse{
-					Header: map[string]string{"Content-type": contentType},
-					Body:   msgBytes,
-				}
+	/*
+	 * Check if upload exists and belongs to user.
+	 */
+	if !found || (upload.user != user) {
+		return nil, fmt.Errorf("No upload with id '%s'.", id)
+	} else {
+		return upload, nil
+	}
 
-			}
+}
 
-		}
+/*
+ * Marshals an object into a JSON representation or an error.
+ * Returns the appropriate MIME type and binary representation.
+ */
+func (this *controllerStruct) createJSON(obj interface{}) (string, []byte) {
+	buffer, err := json.MarshalIndent(obj, "", "\t")
 
-		return response
+	/*
+	 * Check if we got an error during marshalling.
+	 */
+	if err != nil {
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+		errString := err.Error()
+		bufString := bytes.NewBufferString(errString)
+		bufBytes := bufString.Bytes()
+		return contentType, bufBytes
+	} else {
+		return "application/json; charset=utf-8", buffer
 	}
 
 }
 
 /*
- * Export activity data as CSV.
+ * Creates a user with the given password and all permissions.
+ *
+ * If the password, a permission or the user database cannot be written, the
+ * user is removed again, so that the first-run setup remains available.
  */
-func (this *controllerStruct) exportActivitiesCsvHandler(request webserver.HttpRequest) webserver.HttpResponse {
-	token := request.Params["token"]
-	perm, err := this.checkPermission(token, "activity-read")
+func (this *controllerStruct) createAdminUser(name string, password string) error {
+	umgr := this.userManager
+	err := umgr.CreateUser(name)
 
 	/*
-	 * Check permissions.
+	 * Check if user could be created.
 	 */
 	if err != nil {
 		msg := err.Error()
-		customMsg := fmt.Sprintf("Failed to check permission: %s\n", msg)
-		customMsgBuf := bytes.NewBufferString(customMsg)
-		customMsgBytes := customMsgBuf.Bytes()
-		conf := this.config
-		confServer := conf.WebServer
-		contentType := confServer.ErrorMime
+		return fmt.Errorf("Failed to create user '%s': %s", name, msg)
+	} else {
+		errResult := error(nil)
+		err = umgr.SetPassword(name, password)
 
 		/*
-		 * Create HTTP response.
+		 * Check if password could be set.
 		 */
-		response := webserver.HttpResponse{
-			Header: map[string]string{"Content-type": contentType},
-			Body:   customMsgBytes,
+		if err != nil {
+			msg := err.Error()
+			errResult = fmt.Errorf("Failed to set password for user '%s': %s", name, msg)
 		}
 
-		return response
-	} else if !perm {
-		customMsgBuf := bytes.NewBufferString("Forbidden!")
-		customMsgBytes := customMsgBuf.Bytes()
-		conf := this.config
-		confServer := conf.WebServer
-		contentType := confServer.ErrorMime
-
 		/*
-		 * Create HTTP response.
+		 * All permissions checked by the CGI handlers.
 		 */
-		response := webserver.HttpResponse{
-			Header: map[string]string{"Content-type": contentType},
-			Body:   customMsgBytes,
+		permissions := []string{
+			"activity-read",
+			"activity-write",
+			"admin",
+			"geodb-download",
+			"geodb-read",
+			"geodb-write",
+			"get-tile",
+			"render",
+			"render-large",
 		}
 
-		return response
-	} else {
-		conf := this.config
-		confServer := conf.WebServer
-		contentType := confServer.ErrorMime
-		this.activitiesLock.RLock()
-		activities := this.activities
-		rs, err := activities.ExportCSV()
-		this.activitiesLock.RUnlock()
-
 		/*
-		 * Check if error occured during export.
+		 * Grant each permission.
 		 */
-		if err != nil {
-			msg := err.Error()
+		for _, permission := range permissions {
 
 			/*
-			 * Create HTTP response.
+			 * Only continue if no error occured so far.
 			 */
-			response := webserver.HttpResponse{
-				Header: map[string]string{"Content-type": contentType},
-				Body:   []byte(msg),
-			}
+			if errResult == nil {
+				err = umgr.AddPermission(name, permission)
 
-			return response
-		} else {
+				/*
+				 * Check if permission could be granted.
+				 */
+				if err != nil {
+					msg := err.Error()
+					errResult = fmt.Errorf("Failed to add permission '%s' to user '%s': %s", permission, name, msg)
+				}
 
-			/*
-			 * Provide dummy close method.
-			 */
-			rsc := &readSeekerWithNopCloserStruct{
-				rs,
 			}
 
-			creationTime := time.Now()
-			timeStamp := creationTime.Format(ARCHIVE_TIME_STAMP)
-			fileName := fmt.Sprintf("activities-%s.csv", timeStamp)
-			disposition := fmt.Sprintf("attachment; filename=\"%s\"", fileName)
-
-			/*
-			 * Create HTTP response.
-			 */
-			response := webserver.HttpResponse{
+		}
 
-				Header: map[string]string{
-					"Content-disposition": disposition,
-					"Content-type":        "text/csv",
-				},
+		/*
+		 * Only persist user if it is complete.
+		 */
+		if errResult == nil {
+			errResult = this.syncUserDB()
+		}
 
-				ContentReadSeekCloser: rsc,
+		/*
+		 * Remove incomplete user again, so that setup remains available.
+		 */
+		if errResult != nil {
+			umgr.RemoveUser(name)
+		}
+
+		return errResult
+	}
+
+}
+
+/*
+ * Creates a transform anonymizing locations during export.
+ *
+ * If precision is non-empty, coordinates are truncated to this number of
+ * decimal places. If redact is "true", locations within the configured home
+ * zone are omitted. Returns nil if no anonymization was requested.
+ */
+func (this *controllerStruct) createExportTransform(precision string, redact string) (geodb.LocationTransform, error) {
+	conf := this.getConfig()
+	home := conf.Home
+	redactHome := redact == "true"
+	decimals := uint64(7)
+	err := error(nil)
+
+	/*
+	 * Parse precision if it was provided.
+	 */
+	if precision != "" {
+		decimals, err = strconv.ParseUint(precision, 10, 8)
+	}
+
+	/*
+	 * Check if precision and home zone are valid.
+	 */
+	if (err != nil) || (decimals > 7) {
+		return nil, fmt.Errorf("Precision must be a number of decimal places between 0 and 7, but was '%s'.", precision)
+	} else if redactHome && (home.Radius <= 0.0) {
+		return nil, fmt.Errorf("%s", "Cannot redact home zone: No home zone configured.")
+	} else if (decimals == 7) && !redactHome {
+		return nil, nil
+	} else {
+		gu := geoutil.Create()
+		decimals8 := uint8(decimals)
+		homeLatitudeE7 := int32(math.Round(home.Latitude * 1e7))
+		homeLongitudeE7 := int32(math.Round(home.Longitude * 1e7))
+		homeRadius := home.Radius
+
+		/*
+		 * Anonymize a single location.
+		 */
+		transform := func(loc *geodb.Location) bool {
+			keep := true
+
+			/*
+			 * Check if location lies within home zone.
+			 */
+			if redactHome {
+				distance := gu.DistanceE7(loc.LatitudeE7, loc.LongitudeE7, homeLatitudeE7, homeLongitudeE7)
+				keep = distance > homeRadius
 			}
 
-			return response
+			loc.LatitudeE7 = gu.TruncateE7(loc.LatitudeE7, decimals8)
+			loc.LongitudeE7 = gu.TruncateE7(loc.LongitudeE7, decimals8)
+			return keep
 		}
 
+		return transform, nil
 	}
 
 }
 
 /*
- * Retrieve all activity information from database.
+ * Encodes an image in the requested format.
+ *
+ * The quality only applies to WebP, where webp.QUALITY_LOSSLESS encodes the
+ * image losslessly. Returns the encoded image and its MIME type.
  */
-func (this *controllerStruct) getActivitiesHandler(request webserver.HttpRequest) webserver.HttpResponse {
-	token := request.Params["token"]
-	perm, err := this.checkPermission(token, "activity-read")
+func (this *controllerStruct) encodeImage(img image.Image, format string, quality uint8) ([]byte, string, error) {
+	buf := &bytes.Buffer{}
 
 	/*
-	 * Check permissions.
+	 * Decide on the image format.
 	 */
-	if err != nil {
-		msg := err.Error()
-		customMsg := fmt.Sprintf("Failed to check permission: %s\n", msg)
-		customMsgBuf := bytes.NewBufferString(customMsg)
-		customMsgBytes := customMsgBuf.Bytes()
-		conf := this.config
-		confServer := conf.WebServer
-		contentType := confServer.ErrorMime
+	switch format {
+	case IMAGE_FORMAT_PNG:
 
 		/*
-		 * Create HTTP response.
+		 * Create a PNG encoder.
 		 */
-		response := webserver.HttpResponse{
-			Header: map[string]string{"Content-type": contentType},
-			Body:   customMsgBytes,
+		encoder := png.Encoder{
+			CompressionLevel: png.BestCompression,
 		}
 
-		return response
-	} else if !perm {
-		customMsgBuf := bytes.NewBufferString("Forbidden!")
-		customMsgBytes := customMsgBuf.Bytes()
-		conf := this.config
-		confServer := conf.WebServer
-		contentType := confServer.ErrorMime
+		err := encoder.Encode(buf, img)
+		bufBytes := buf.Bytes()
+		return bufBytes, "image/png", err
+	case IMAGE_FORMAT_WEBP:
+		err := webp.EncodeWithQuality(buf, img, quality)
+		bufBytes := buf.Bytes()
+		return bufBytes, "image/webp", err
+	default:
+		return nil, "", fmt.Errorf("Unsupported image format: '%s'", format)
+	}
+
+}
+
+/*
+ * Reads location data, decompressing it if it is gzip-compressed.
+ *
+ * Data is recognized as compressed by the gzip magic number. Compressed data
+ * is decompressed while it is read, so that it is never held in memory as a
+ * whole. Other data is returned unchanged. Also returns whether the data was
+ * compressed.
+ *
+ * Fails if the decompressed data would exceed maxSize bytes, so that a small
+ * upload cannot exhaust memory.
+ */
+func (this *controllerStruct) readGeoData(r io.Reader, maxSize uint64) ([]byte, bool, error) {
+	gzipMagic := []byte("\x1f\x8b")
+	gzipMagicSize := len(gzipMagic)
+	br := bufio.NewReader(r)
+	magic, _ := br.Peek(gzipMagicSize)
+
+	/*
+	 * Only decompress data starting with the gzip magic number.
+	 */
+	if !bytes.Equal(magic, gzipMagic) {
+		data, err := io.ReadAll(br)
 
 		/*
-		 * Create HTTP response.
+		 * Check if data could be read.
 		 */
-		response := webserver.HttpResponse{
-			Header: map[string]string{"Content-type": contentType},
-			Body:   customMsgBytes,
+		if err != nil {
+			msg := err.Error()
+			return nil, false, fmt.Errorf("Failed to read data: %s", msg)
+		} else {
+			return data, false, nil
 		}
 
-		return response
 	} else {
-		this.activitiesLock.RLock()
-		activities := this.activities
-		revision := activities.Revision()
-		numActivities := activities.Length()
-		webActivityGroups := make([]webActivityGroupStruct, 0)
-		timeFormat := time.RFC3339
+		gzr, err := gzip.NewReader(br)
 
 		/*
-		 * Iterate over all activities.
+		 * Check if gzip header could be read.
 		 */
-		for id := uint32(0); id < numActivities; id++ {
-			activityGroup, err := activities.Get(id)
+		if err != nil {
+			msg := err.Error()
+			return nil, true, fmt.Errorf("Invalid gzip header: %s", msg)
+		} else {
+			limit := int64(math.MaxInt64)
 
 			/*
-			 * Check if activity group was found.
+			 * Read one byte beyond the limit to detect excess data.
 			 */
-			if err == nil {
-				runningActivity := activityGroup.Running()
-				runningZero := runningActivity.Zero()
-				runningDuration := runningActivity.Duration()
-				runningDurationString := runningDuration.String()
-				runningDistanceKMString := runningActivity.DistanceKM()
-				runningStepCount := runningActivity.StepCount()
-				runningEnergyKJ := runningActivity.EnergyKJ()
-
-				/*
-				 * Create data structure representing running activity.
-				 */
-				webRunningActivity := webRunningActivityStruct{
-					Zero:       runningZero,
-					Duration:   runningDurationString,
-					DistanceKM: runningDistanceKMString,
-					StepCount:  runningStepCount,
-					EnergyKJ:   runningEnergyKJ,
-				}
+			if maxSize < math.MaxInt64 {
+				limit = int64(maxSize) + 1
+			}
 
-				cyclingActivity := activityGroup.Cycling()
-				cyclingZero := cyclingActivity.Zero()
-				cyclingDuration := cyclingActivity.Duration()
-				cyclingDurationString := cyclingDuration.String()
-				cyclingDistanceKMString := cyclingActivity.DistanceKM()
-				cyclingEnergyKJ := cyclingActivity.EnergyKJ()
+			lr := io.LimitReader(gzr, limit)
+			decompressed, err := io.ReadAll(lr)
+			gzr.Close()
+			size := len(decompressed)
+			size64 := uint64(size)
 
-				/*
-				 * Create data structure representing cycling activity.
-				 */
-				webCyclingActivity := webCyclingActivityStruct{
-					Zero:       cyclingZero,
-					Duration:   cyclingDurationString,
-					DistanceKM: cyclingDistanceKMString,
-					EnergyKJ:   cyclingEnergyKJ,
-				}
+			/*
+			 * Check if data could be decompressed.
+			 */
+			if err != nil {
+				msg := err.Error()
+				return nil, true, fmt.Errorf("Compressed data is truncated or corrupt: %s", msg)
+			} else if size64 > maxSize {
+				return nil, true, fmt.Errorf("Decompressed data too large: Exceeds limit of %d bytes.", maxSize)
+			} else {
+				return decompressed, true, nil
+			}
 
-				otherActivity := activityGroup.Other()
-				otherZero := otherActivity.Zero()
-				otherEnergyKJ := otherActivity.EnergyKJ()
+		}
 
-				/*
-				 * Create data structure representing other activities.
-				 */
-				webOtherActivity := webOtherActivityStruct{
-					Zero:     otherZero,
-					EnergyKJ: otherEnergyKJ,
-				}
+	}
 
-				begin := activityGroup.Begin()
-				beginString := begin.Format(timeFormat)
-				end, _ := activities.End(id)
-				endString := end.Format(timeFormat)
-				weightKGString := activityGroup.WeightKG()
+}
 
-				/*
-				 * Create data structure representing activity group.
-				 */
-				webActivityGroup := webActivityGroupStruct{
-					Begin:    beginString,
-					End:      endString,
-					WeightKG: weightKGString,
-					Running:  webRunningActivity,
-					Cycling:  webCyclingActivity,
-					Other:    webOtherActivity,
-				}
+/*
+ * Detects the format of location data from its content.
+ *
+ * Recognizes OpenGeoDB by its magic number, GPX and KML by their XML root
+ * element, Records JSON by a leading brace or bracket and CSV by a first
+ * record with three or four fields. Formats which cannot be imported, like ZIP
+ * and FIT, are recognized as well, so that a meaningful error can be reported.
+ */
+func (this *controllerStruct) detectGeoDataFormat(data []byte) (string, error) {
+	size := len(data)
+	magicNumber := uint64(0)
 
-				webActivityGroups = append(webActivityGroups, webActivityGroup)
-			}
+	/*
+	 * Read magic number if data is large enough.
+	 */
+	if size >= opengeodb.SIZE_MAGIC {
+		endian := binary.BigEndian
+		magic := data[0:opengeodb.SIZE_MAGIC]
+		magicNumber = endian.Uint64(magic)
+	}
 
-		}
+	zipMagic := []byte("PK\x03\x04")
+	fitMagic := []byte(".FIT")
+	isFit := (size >= 12) && bytes.Equal(data[8:12], fitMagic)
+	bom := []byte("\xef\xbb\xbf")
+	text := bytes.TrimPrefix(data, bom)
+	text = bytes.TrimLeft(text, " \t\r\n")
+	jsonObject := bytes.HasPrefix(text, []byte("{"))
+	jsonArray := bytes.HasPrefix(text, []byte("["))
+	xmlDocument := bytes.HasPrefix(text, []byte("<"))
+	candidates := "Specify one of 'binary', 'csv', 'gpx', 'json' or 'kml' as format."
 
-		activityStatistics := activities.Statistics()
-		runningActivity := activityStatistics.Running()
-		runningZero := runningActivity.Zero()
-		runningDuration := runningActivity.Duration()
-		runningDurationString := runningDuration.String()
-		runningDistanceKMString := runningActivity.DistanceKM()
-		runningStepCount := runningActivity.StepCount()
-		runningEnergyKJ := runningActivity.EnergyKJ()
+	/*
+	 * Check for the different formats.
+	 */
+	if magicNumber == opengeodb.MAGIC_NUMBER {
+		return "binary", nil
+	} else if bytes.HasPrefix(data, zipMagic) {
+		return "", fmt.Errorf("%s", "ZIP archives cannot be imported. Extract the archive and import the files it contains.")
+	} else if isFit {
+		return "", fmt.Errorf("%s", "FIT files cannot be imported.")
+	} else if jsonObject || jsonArray {
+		return "json", nil
+	} else if xmlDocument {
+		r := bytes.NewReader(text)
+		decoder := xml.NewDecoder(r)
+		root := ""
+		err := error(nil)
 
 		/*
-		 * Create data structure representing running activity.
+		 * Find the root element.
 		 */
-		webRunningActivity := webRunningActivityStruct{
-			Zero:       runningZero,
-			Duration:   runningDurationString,
-			DistanceKM: runningDistanceKMString,
-			StepCount:  runningStepCount,
-			EnergyKJ:   runningEnergyKJ,
-		}
+		for (root == "") && (err == nil) {
+			token, errToken := decoder.Token()
+			err = errToken
+			element, ok := token.(xml.StartElement)
 
-		cyclingActivity := activityStatistics.Cycling()
-		cyclingZero := cyclingActivity.Zero()
-		cyclingDuration := cyclingActivity.Duration()
-		cyclingDurationString := cyclingDuration.String()
-		cyclingDistanceKMString := cyclingActivity.DistanceKM()
-		cyclingEnergyKJ := cyclingActivity.EnergyKJ()
+			/*
+			 * Check if we found the first element.
+			 */
+			if ok {
+				name := element.Name
+				root = name.Local
+			}
 
-		/*
-		 * Create data structure representing cycling activity.
-		 */
-		webCyclingActivity := webCyclingActivityStruct{
-			Zero:       cyclingZero,
-			Duration:   cyclingDurationString,
-			DistanceKM: cyclingDistanceKMString,
-			EnergyKJ:   cyclingEnergyKJ,
 		}
 
-		otherActivity := activityStatistics.Other()
-		otherZero := otherActivity.Zero()
-		otherEnergyKJ := otherActivity.EnergyKJ()
+		rootLower := strings.ToLower(root)
 
 		/*
-		 * Create data structure representing other activities.
+		 * Decide on format based on root element.
 		 */
-		webOtherActivity := webOtherActivityStruct{
-			Zero:     otherZero,
-			EnergyKJ: otherEnergyKJ,
-		}
-
-		/*
-		 * Create data structure representing overall activity statistics.
-		 */
-		webActivityStatistics := webActivityStatisticsStruct{
-			Running: webRunningActivity,
-			Cycling: webCyclingActivity,
-			Other:   webOtherActivity,
+		switch rootLower {
+		case "":
+			return "", fmt.Errorf("Failed to find root element of XML document. %s", candidates)
+		case "gpx":
+			return "gpx", nil
+		case "kml":
+			return "kml", nil
+		default:
+			return "", fmt.Errorf("Unknown XML root element '%s'. %s", root, candidates)
 		}
 
-		this.activitiesLock.RUnlock()
+	} else {
+		line := text
+		idx := bytes.IndexByte(text, '\n')
 
 		/*
-		 * Create data structure representing all activity information.
+		 * Only consider the first line.
 		 */
-		webActivities := webActivitiesStruct{
-			Revision:   revision,
-			Activities: webActivityGroups,
-			Statistics: webActivityStatistics,
+		if idx >= 0 {
+			line = text[0:idx]
 		}
 
-		mimeType, buffer := this.createJSON(webActivities)
+		r := bytes.NewReader(line)
+		reader := csv.NewReader(r)
+		record, err := reader.Read()
+		numFields := len(record)
 
 		/*
-		 * Create HTTP response.
+		 * CSV records consist of time stamp, latitude, longitude and
+		 * optionally accuracy.
 		 */
-		response := webserver.HttpResponse{
-			Header: map[string]string{"Content-type": mimeType},
-			Body:   buffer,
+		if (err == nil) && ((numFields == 3) || (numFields == 4)) {
+			return "csv", nil
+		} else {
+			return "", fmt.Errorf("Format could not be detected. %s", candidates)
 		}
 
-		return response
 	}
 
 }
 
 /*
- * Obtain statistics from the GeoDB location database.
+ * Parses the background color of a rendered image.
+ *
+ * The color is either "transparent", one of the named foreground colors,
+ * "black" or a hexadecimal value like "#rrggbb". An empty value is treated as
+ * transparent. Returns the color and whether it is opaque.
  */
-func (this *controllerStruct) getGeoDBStatsHandler(request webserver.HttpRequest) webserver.HttpResponse {
-	token := request.Params["token"]
-	perm, err := this.checkPermission(token, "geodb-read")
+func (this *controllerStruct) parseBackgroundColor(value string) (imagecolor.NRGBA, bool, error) {
+	valueLower := strings.ToLower(value)
 
 	/*
-	 * Check permissions.
+	 * Decide on the color.
 	 */
-	if err != nil {
-		msg := err.Error()
-		customMsg := fmt.Sprintf("Failed to check permission: %s\n", msg)
-		customMsgBuf := bytes.NewBufferString(customMsg)
-		customMsgBytes := customMsgBuf.Bytes()
-		conf := this.config
-		confServer := conf.WebServer
-		contentType := confServer.ErrorMime
+	switch valueLower {
+	case "", "transparent":
+		return imagecolor.NRGBA{}, false, nil
+	case "black":
+		return imagecolor.NRGBA{R: 0, G: 0, B: 0, A: 255}, true, nil
+	case "red":
+		return imagecolor.NRGBA{R: 255, G: 0, B: 0, A: 255}, true, nil
+	case "green":
+		return imagecolor.NRGBA{R: 0, G: 255, B: 0, A: 255}, true, nil
+	case "blue":
+		return imagecolor.NRGBA{R: 0, G: 0, B: 255, A: 255}, true, nil
+	case "yellow":
+		return imagecolor.NRGBA{R: 255, G: 255, B: 0, A: 255}, true, nil
+	case "cyan":
+		return imagecolor.NRGBA{R: 0, G: 255, B: 255, A: 255}, true, nil
+	case "magenta":
+		return imagecolor.NRGBA{R: 255, G: 0, B: 255, A: 255}, true, nil
+	case "gray":
+		return imagecolor.NRGBA{R: 127, G: 127, B: 127, A: 255}, true, nil
+	case "brightblue":
+		return imagecolor.NRGBA{R: 127, G: 127, B: 255, A: 255}, true, nil
+	case "white":
+		return imagecolor.NRGBA{R: 255, G: 255, B: 255, A: 255}, true, nil
+	default:
+		hasPrefix := strings.HasPrefix(valueLower, "#")
+		hexString := strings.TrimPrefix(valueLower, "#")
+		rgb, err := hex.DecodeString(hexString)
+		numBytes := len(rgb)
 
 		/*
-		 * Create HTTP response.
+		 * Check if value is a hexadecimal color.
 		 */
-		response := webserver.HttpResponse{
-			Header: map[string]string{"Content-type": contentType},
-			Body:   customMsgBytes,
-		}
+		if !hasPrefix || (err != nil) || (numBytes != 3) {
+			return imagecolor.NRGBA{}, false, fmt.Errorf("Unknown background color: '%s'", value)
+		} else {
 
-		return response
-	} else if !perm {
-		customMsgBuf := bytes.NewBufferString("Forbidden!")
-		customMsgBytes := customMsgBuf.Bytes()
-		conf := this.config
-		confServer := conf.WebServer
-		contentType := confServer.ErrorMime
+			/*
+			 * Create color from its components.
+			 */
+			c := imagecolor.NRGBA{
+				R: rgb[0],
+				G: rgb[1],
+				B: rgb[2],
+				A: 255,
+			}
 
-		/*
-		 * Create HTTP response.
-		 */
-		response := webserver.HttpResponse{
-			Header: map[string]string{"Content-type": contentType},
-			Body:   customMsgBytes,
+			return c, true, nil
 		}
 
-		return response
-	} else {
-		datasetStats := webDatasetStatsStruct{}
-		gu := geoutil.Create()
-		db := this.locationDB
-		stats, err := gu.GeoDBStats(db)
+	}
+
+}
+
+/*
+ * Determines the image format for the response to a request.
+ *
+ * An explicit "format" parameter overrides negotiation. Otherwise, WebP is
+ * only sent to clients explicitly accepting it, everyone else gets PNG.
+ */
+func (this *controllerStruct) negotiateImageFormat(request webserver.HttpRequest) string {
+	format := request.Params["format"]
+
+	/*
+	 * Negotiate format if none was requested explicitly.
+	 */
+	if format == "" {
+		accept := request.Header["Accept"]
+		qualityWebP := this.acceptQuality(accept, "image/webp")
+		qualityPNG := this.acceptQuality(accept, "image/png")
+		format = IMAGE_FORMAT_PNG
 
 		/*
-		 * Make sure that no error occured.
+		 * Prefer WebP if client accepts it at least as much as PNG.
 		 */
-		if err == nil {
-			locationCount := stats.LocationCount()
-			ordered := stats.Ordered()
-			orderedStrict := stats.OrderedStrict()
-			timestampEarliest := stats.TimestampEarliest()
-			timestampLatest := stats.TimestampLatest()
-			timestampEarliestString := ""
-			timestampLatestString := ""
+		if (qualityWebP > 0.0) && (qualityWebP >= qualityPNG) {
+			format = IMAGE_FORMAT_WEBP
+		}
 
-			/*
-			 * Check if timestamps are defined.
-			 */
-			if timestampEarliest <= timestampLatest {
-				timestampEarliestTime := gu.MillisecondsToTime(timestampEarliest)
-				timestampEarliestString = timestampEarliestTime.Format(TIMESTAMP_FORMAT)
-				timestampLatestTime := gu.MillisecondsToTime(timestampLatest)
-				timestampLatestString = timestampLatestTime.Format(TIMESTAMP_FORMAT)
-			}
+	}
 
-			/*
-			 * Create dataset statistics.
-			 */
-			datasetStats = webDatasetStatsStruct{
-				LocationCount:     locationCount,
-				Ordered:           ordered,
-				OrderedStrict:     orderedStrict,
-				TimestampEarliest: timestampEarliestString,
-				TimestampLatest:   timestampLatestString,
-			}
+	return format
+}
 
-		}
+/*
+ * Releases a semaphore.
+ */
+func (this *controllerStruct) release(sem lsync.Semaphore) {
 
-		mimeType, buffer := this.createJSON(datasetStats)
+	/*
+	 * Check if semaphore exists.
+	 */
+	if sem != nil {
+		sem.Release()
+	}
 
-		/*
-		 * Create HTTP response.
-		 */
-		response := webserver.HttpResponse{
-			Header: map[string]string{"Content-type": mimeType},
-			Body:   buffer,
-		}
+}
 
-		return response
+/*
+ * Creates the web representation of activity statistics.
+ */
+func (this *controllerStruct) createWebActivityStatistics(stats meta.ActivityStatistics) webActivityStatisticsStruct {
+	runningActivity := stats.Running()
+	runningZero := runningActivity.Zero()
+	runningDuration := runningActivity.Duration()
+	runningDurationString := runningDuration.String()
+	runningDistanceKMString := runningActivity.DistanceKM()
+	runningStepCount := runningActivity.StepCount()
+	runningEnergyKJ := runningActivity.EnergyKJ()
+
+	/*
+	 * Create data structure representing running activity.
+	 */
+	webRunningActivity := webRunningActivityStruct{
+		Zero:       runningZero,
+		Duration:   runningDurationString,
+		DistanceKM: runningDistanceKMString,
+		StepCount:  runningStepCount,
+		EnergyKJ:   runningEnergyKJ,
+	}
+
+	cyclingActivity := stats.Cycling()
+	cyclingZero := cyclingActivity.Zero()
+	cyclingDuration := cyclingActivity.Duration()
+	cyclingDurationString := cyclingDuration.String()
+	cyclingDistanceKMString := cyclingActivity.DistanceKM()
+	cyclingEnergyKJ := cyclingActivity.EnergyKJ()
+
+	/*
+	 * Create data structure representing cycling activity.
+	 */
+	webCyclingActivity := webCyclingActivityStruct{
+		Zero:       cyclingZero,
+		Duration:   cyclingDurationString,
+		DistanceKM: cyclingDistanceKMString,
+		EnergyKJ:   cyclingEnergyKJ,
+	}
+
+	otherActivity := stats.Other()
+	otherZero := otherActivity.Zero()
+	otherEnergyKJ := otherActivity.EnergyKJ()
+
+	/*
+	 * Create data structure representing other activities.
+	 */
+	webOtherActivity := webOtherActivityStruct{
+		Zero:     otherZero,
+		EnergyKJ: otherEnergyKJ,
+	}
+
+	customActivities := stats.Custom()
+	webCustomActivities := this.createWebCustomActivities(customActivities)
+
+	/*
+	 * Create data structure representing activity statistics.
+	 */
+	result := webActivityStatisticsStruct{
+		Running: webRunningActivity,
+		Cycling: webCyclingActivity,
+		Other:   webOtherActivity,
+		Custom:  webCustomActivities,
 	}
 
+	return result
 }
 
 /*
- * Render a map tile.
+ * Creates the web representation of user-defined activities.
  */
-func (this *controllerStruct) getTileHandler(request webserver.HttpRequest) webserver.HttpResponse {
-	token := request.Params["token"]
-	perm, err := this.checkPermission(token, "get-tile")
+func (this *controllerStruct) createWebCustomActivities(activities []meta.CustomActivity) []webCustomActivityStruct {
+	numActivities := len(activities)
+	result := make([]webCustomActivityStruct, numActivities)
 
 	/*
-	 * Check permissions.
+	 * Create web representation of each user-defined activity.
 	 */
-	if err != nil {
-		msg := err.Error()
-		customMsg := fmt.Sprintf("Failed to check permission: %s\n", msg)
-		customMsgBuf := bytes.NewBufferString(customMsg)
-		customMsgBytes := customMsgBuf.Bytes()
-		conf := this.config
-		confServer := conf.WebServer
-		contentType := confServer.ErrorMime
+	for i, activity := range activities {
+		name := activity.Name()
+		zero := activity.Zero()
+		duration := activity.Duration()
+		durationString := duration.String()
+		distanceKMString := activity.DistanceKM()
+		energyKJ := activity.EnergyKJ()
 
 		/*
-		 * Create HTTP response.
+		 * Create data structure representing user-defined activity.
 		 */
-		response := webserver.HttpResponse{
-			Header: map[string]string{"Content-type": contentType},
-			Body:   customMsgBytes,
+		result[i] = webCustomActivityStruct{
+			Name:       name,
+			Zero:       zero,
+			Duration:   durationString,
+			DistanceKM: distanceKMString,
+			EnergyKJ:   energyKJ,
 		}
 
-		return response
-	} else if !perm {
-		customMsgBuf := bytes.NewBufferString("Forbidden!")
-		customMsgBytes := customMsgBuf.Bytes()
-		conf := this.config
-		confServer := conf.WebServer
-		contentType := confServer.ErrorMime
+	}
 
-		/*
-		 * Create HTTP response.
-		 */
-		response := webserver.HttpResponse{
-			Header: map[string]string{"Content-type": contentType},
-			Body:   customMsgBytes,
-		}
+	return result
+}
 
-		return response
-	} else {
-		conf := this.config
-		useMap := conf.UseMap
+/*
+ * Adds user-defined activities of an existing activity group to the
+ * information about user-defined activities, unless information about
+ * activities of the same name is already present.
+ *
+ * This keeps user-defined activities when a client, which does not know
+ * about them, replaces an activity group.
+ */
+func (this *controllerStruct) mergeCustomActivities(custom []meta.CustomActivityInfo, existing []meta.CustomActivity) []meta.CustomActivityInfo {
+	present := map[string]bool{}
+
+	/*
+	 * Collect the names of the activities already present.
+	 */
+	for _, info := range custom {
+		name := info.Name
+		present[name] = true
+	}
+
+	/*
+	 * Add existing activities which are not present.
+	 */
+	for _, activity := range existing {
+		name := activity.Name()
 
 		/*
-		 * Check if we use a map at all.
-		 *
-		 * (If not, this.tileUtil and this.tileServer will be nil.)
+		 * Check if activity is already present.
 		 */
-		if !useMap {
-			customMsgBuf := bytes.NewBufferString("Server does not serve map tiles.")
-			customMsgBytes := customMsgBuf.Bytes()
-			conf := this.config
-			confServer := conf.WebServer
-			contentType := confServer.ErrorMime
+		if !present[name] {
+			duration := activity.Duration()
+			distanceKM := activity.DistanceKM()
+			energyKJ := activity.EnergyKJ()
 
 			/*
-			 * Create HTTP response.
+			 * Create user-defined activity info.
 			 */
-			response := webserver.HttpResponse{
-				Header: map[string]string{"Content-type": contentType},
-				Body:   customMsgBytes,
+			info := meta.CustomActivityInfo{
+				Name:       name,
+				Duration:   duration,
+				DistanceKM: distanceKM,
+				EnergyKJ:   energyKJ,
 			}
 
-			return response
-		} else {
-			xIn := request.Params["x"]
-			x64, _ := strconv.ParseUint(xIn, 10, 32)
-			x := uint32(x64)
-			yIn := request.Params["y"]
-			y64, _ := strconv.ParseUint(yIn, 10, 32)
-			y := uint32(y64)
-			zIn := request.Params["z"]
-			z64, _ := strconv.ParseUint(zIn, 10, 8)
-			z := uint8(z64)
-			tileId := tile.CreateId(z, x, y)
-			tileUtil := this.tileUtil
-			tileServer := this.tileServer
-			t, err := tileUtil.Fetch(tileServer, tileId)
+			custom = append(custom, info)
+		}
 
-			/*
-			 * Check if tile could be fetched.
-			 */
-			if err != nil {
-				msg := err.Error()
-				customMsg := fmt.Sprintf("Failed to fetch map tile: %s\n", msg)
-				customMsgBuf := bytes.NewBufferString(customMsg)
-				customMsgBytes := customMsgBuf.Bytes()
-				confServer := conf.WebServer
-				contentType := confServer.ErrorMime
+	}
 
-				/*
-				 * Create HTTP response.
-				 */
-				response := webserver.HttpResponse{
-					Header: map[string]string{"Content-type": contentType},
-					Body:   customMsgBytes,
-				}
+	return custom
+}
 
-				return response
-			} else {
+/*
+ * Obtains information about user-defined activities of the given types from
+ * request parameters.
+ *
+ * For an activity type like "rowing", the parameters are "rowingduration",
+ * "rowingdistancekm" and "rowingenergykj". Types for which none of these
+ * parameters are present are skipped.
+ */
+func (this *controllerStruct) parseCustomActivities(params map[string]string, types []string) []meta.CustomActivityInfo {
+	result := []meta.CustomActivityInfo{}
 
-				/*
-				 * Create HTTP response.
-				 */
-				response := webserver.HttpResponse{
-					Header:                map[string]string{"Content-type": "image/png"},
-					ContentReadSeekCloser: t,
-				}
+	/*
+	 * Obtain information about each activity type.
+	 */
+	for _, name := range types {
+		durationIn, hasDuration := params[name+"duration"]
+		distanceKM, hasDistanceKM := params[name+"distancekm"]
+		energyKJIn, hasEnergyKJ := params[name+"energykj"]
 
-				return response
+		/*
+		 * Check if any information about this activity type is present.
+		 */
+		if hasDuration || hasDistanceKM || hasEnergyKJ {
+			duration, _ := time.ParseDuration(durationIn)
+			energyKJ, _ := strconv.ParseUint(energyKJIn, 10, 64)
+
+			/*
+			 * Create user-defined activity info.
+			 */
+			info := meta.CustomActivityInfo{
+				Name:       name,
+				Duration:   duration,
+				DistanceKM: distanceKM,
+				EnergyKJ:   energyKJ,
 			}
 
+			result = append(result, info)
 		}
 
 	}
 
+	return result
 }
 
 /*
- * Import activity data from CSV and add it to the database.
+ * Add activity information to database.
  */
-func (this *controllerStruct) importActivityCsvHandler(request webserver.HttpRequest) webserver.HttpResponse {
+func (this *controllerStruct) addActivityHandler(request webserver.HttpRequest) webserver.HttpResponse {
 	token := request.Params["token"]
 	perm, err := this.checkPermission(token, "activity-write")
+	partition, errPartition := this.requestPartition(request)
 
 	/*
 	 * Check permissions.
@@ -1471,7 +2468,7 @@ func (this *controllerStruct) importActivityCsvHandler(request webserver.HttpReq
 		customMsg := fmt.Sprintf("Failed to check permission: %s", msg)
 		customMsgBuf := bytes.NewBufferString(customMsg)
 		customMsgBytes := customMsgBuf.Bytes()
-		conf := this.config
+		conf := this.getConfig()
 		confServer := conf.WebServer
 		contentType := confServer.ErrorMime
 
@@ -1487,7 +2484,25 @@ func (this *controllerStruct) importActivityCsvHandler(request webserver.HttpReq
 	} else if !perm {
 		customMsgBuf := bytes.NewBufferString("Forbidden!")
 		customMsgBytes := customMsgBuf.Bytes()
-		conf := this.config
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType},
+			Body:   customMsgBytes,
+		}
+
+		return response
+	} else if errPartition != nil {
+		msg := errPartition.Error()
+		customMsg := fmt.Sprintf("Failed to access data: %s", msg)
+		customMsgBuf := bytes.NewBufferString(customMsg)
+		customMsgBytes := customMsgBuf.Bytes()
+		conf := this.getConfig()
 		confServer := conf.WebServer
 		contentType := confServer.ErrorMime
 
@@ -1502,17 +2517,14 @@ func (this *controllerStruct) importActivityCsvHandler(request webserver.HttpReq
 		return response
 	} else {
 		wr := webResponseStruct{}
-		data := request.Params["data"]
-		this.activitiesLock.Lock()
-		activities := this.activities
-		err = activities.ImportCSV(data)
+		beginIn := request.Params["begin"]
+		begin, err := filter.ParseTime(beginIn, false, false)
 
 		/*
-		 * Check if activity data was imported.
+		 * The begin time has to be filled in correctly.
 		 */
 		if err != nil {
-			msg := err.Error()
-			reason := fmt.Sprintf("Failed to import activity data: %s", msg)
+			reason := "Failed to add activity: Could not parse the begin time."
 
 			/*
 			 * Indicate failure.
@@ -1523,14 +2535,52 @@ func (this *controllerStruct) importActivityCsvHandler(request webserver.HttpReq
 			}
 
 		} else {
-			err = this.syncActivityDB()
+			weightKG := request.Params["weightkg"]
+			runningDurationIn := request.Params["runningduration"]
+			runningDuration, _ := time.ParseDuration(runningDurationIn)
+			runningDistanceKM := request.Params["runningdistancekm"]
+			runningStepCountIn := request.Params["runningstepcount"]
+			runningStepCount, _ := strconv.ParseUint(runningStepCountIn, 10, 64)
+			runningEnergyKJIn := request.Params["runningenergykj"]
+			runningEnergyKJ, _ := strconv.ParseUint(runningEnergyKJIn, 10, 64)
+			cyclingDurationIn := request.Params["cyclingduration"]
+			cyclingDuration, _ := time.ParseDuration(cyclingDurationIn)
+			cyclingDistanceKM := request.Params["cyclingdistancekm"]
+			cycingEnergyKJIn := request.Params["cyclingenergykj"]
+			cyclingEnergyKJ, _ := strconv.ParseUint(cycingEnergyKJIn, 10, 64)
+			otherEnergyKJIn := request.Params["otherenergykj"]
+			otherEnergyKJ, _ := strconv.ParseUint(otherEnergyKJIn, 10, 64)
+			conf := this.getConfig()
+			activityTypes := conf.ActivityTypes
+			custom := this.parseCustomActivities(request.Params, activityTypes)
 
 			/*
-			 * Check if user database was synchronized.
+			 * Create activity info.
+			 */
+			info := meta.ActivityInfo{
+				Begin:             begin,
+				WeightKG:          weightKG,
+				RunningDuration:   runningDuration,
+				RunningDistanceKM: runningDistanceKM,
+				RunningStepCount:  runningStepCount,
+				RunningEnergyKJ:   runningEnergyKJ,
+				CyclingDuration:   cyclingDuration,
+				CyclingDistanceKM: cyclingDistanceKM,
+				CyclingEnergyKJ:   cyclingEnergyKJ,
+				OtherEnergyKJ:     otherEnergyKJ,
+				Custom:            custom,
+			}
+
+			partition.activitiesLock.Lock()
+			activities := partition.activities
+			err := activities.Add(&info)
+
+			/*
+			 * Check if activity was added.
 			 */
 			if err != nil {
 				msg := err.Error()
-				reason := fmt.Sprintf("Failed to synchronize activity database: %s", msg)
+				reason := fmt.Sprintf("Failed to add activity: %s", msg)
 
 				/*
 				 * Indicate failure.
@@ -1541,20 +2591,40 @@ func (this *controllerStruct) importActivityCsvHandler(request webserver.HttpReq
 				}
 
 			} else {
+				err = this.syncActivityDB(partition)
 
 				/*
-				 * Indicate success.
+				 * Check if user database was synchronized.
 				 */
-				wr = webResponseStruct{
-					Success: true,
-					Reason:  "",
+				if err != nil {
+					msg := err.Error()
+					reason := fmt.Sprintf("Failed to synchronize activity database: %s", msg)
+
+					/*
+					 * Indicate failure.
+					 */
+					wr = webResponseStruct{
+						Success: false,
+						Reason:  reason,
+					}
+
+				} else {
+
+					/*
+					 * Indicate success.
+					 */
+					wr = webResponseStruct{
+						Success: true,
+						Reason:  "",
+					}
+
 				}
 
 			}
 
+			partition.activitiesLock.Unlock()
 		}
 
-		this.activitiesLock.Unlock()
 		mimeType, buffer := this.createJSON(wr)
 
 		/*
@@ -1571,374 +2641,62 @@ func (this *controllerStruct) importActivityCsvHandler(request webserver.HttpReq
 }
 
 /*
- * Import location data in CSV, GPX or GeoJSON format.
+ * Client requests to terminate a session.
  */
-func (this *controllerStruct) importGeoDataHandler(request webserver.HttpRequest) webserver.HttpResponse {
-	token := request.Params["token"]
-	migrationReport := webMigrationReportStruct{}
-	perm, err := this.checkPermission(token, "geodb-write")
-
-	/*
-	 * Check permissions.
+func (this *controllerStruct) authLogoutHandler(request webserver.HttpRequest) webserver.HttpResponse {
+	enc := base64.StdEncoding
+	tokenIn := request.Params["token"]
+	tokenBuffer, err := enc.DecodeString(tokenIn)
+	wr := webResponseStruct{}
+
+	/*
+	 * Check if token could be decoded.
 	 */
 	if err != nil {
-		msg := err.Error()
-		reason := fmt.Sprintf("Failed to check permission: %s", msg)
-
-		/*
-		 * Indicate failure.
-		 */
-		status := webResponseStruct{
-			Success: false,
-			Reason:  reason,
-		}
-
-		migrationReport.Status = status
-	} else if !perm {
 
 		/*
 		 * Indicate failure.
 		 */
-		status := webResponseStruct{
+		wr = webResponseStruct{
 			Success: false,
-			Reason:  "Forbidden!",
+			Reason:  "Failed to decode session token.",
 		}
 
-		migrationReport.Status = status
 	} else {
-		files := request.Files["file"]
+		sm := this.sessionManager
+		token := sm.CreateToken(tokenBuffer)
+		err = sm.Terminate(token)
 
 		/*
-		 * Make sure that files are not nil.
+		 * Check if session was terminated.
 		 */
-		if files == nil {
+		if err != nil {
+			msg := err.Error()
+			reason := fmt.Sprintf("Failed to terminate session: %s", msg)
 
 			/*
 			 * Indicate failure.
 			 */
-			status := webResponseStruct{
+			wr = webResponseStruct{
 				Success: false,
-				Reason:  "Field 'file' not defined as a multipart field.",
+				Reason:  reason,
 			}
 
-			migrationReport.Status = status
 		} else {
-			numFiles := len(files)
 
 			/*
-			 * Make sure that exactly one file is sent in request.
+			 * Indicate success.
 			 */
-			if numFiles == 0 {
-
-				/*
-				 * Indicate failure.
-				 */
-				status := webResponseStruct{
-					Success: false,
-					Reason:  "No file sent in request.",
-				}
-
-				migrationReport.Status = status
-			} else if numFiles != 1 {
-
-				/*
-				 * Indicate failure.
-				 */
-				status := webResponseStruct{
-					Success: false,
-					Reason:  "Multiple files sent in request.",
-				}
-
-				migrationReport.Status = status
-			} else {
-				target := this.locationDB
-				file := files[0]
-				data, err := io.ReadAll(file)
-
-				/*
-				 * Check if source file could be successfully read.
-				 */
-				if err != nil {
-
-					/*
-					 * Indicate failure.
-					 */
-					status := webResponseStruct{
-						Success: false,
-						Reason:  "Failed to read source file.",
-					}
-
-					migrationReport.Status = status
-				} else {
-					source, err := geo.Database(nil), fmt.Errorf("%s", "No source file or invalid format.")
-					format := request.Params["format"]
-
-					switch format {
-					case "binary":
-						source, err = opengeodb.FromBytes(data)
-					case "csv":
-						source, err = geocsv.FromBytes(data)
-					case "gpx":
-						source, err = gpx.FromBytes(data)
-					case "json":
-						source, err = geojson.FromBytes(data)
-					}
-
-					/*
-					 * Check if source file could be successfully parsed.
-					 */
-					if err != nil {
-						msg := err.Error()
-						reason := fmt.Sprintf("Failed to parse source file: %s", msg)
-
-						/*
-						 * Indicate failure.
-						 */
-						status := webResponseStruct{
-							Success: false,
-							Reason:  reason,
-						}
-
-						migrationReport.Status = status
-					} else {
-						importStrategy := int(geoutil.IMPORT_NONE)
-						importStrategyValid := false
-						strategy := request.Params["strategy"]
-
-						/*
-						 * Decide on import strategy.
-						 */
-						switch strategy {
-						case "all":
-							importStrategy = int(geoutil.IMPORT_ALL)
-							importStrategyValid = true
-						case "newer":
-							importStrategy = int(geoutil.IMPORT_NEWER)
-							importStrategyValid = true
-						case "none":
-							importStrategy = int(geoutil.IMPORT_NONE)
-							importStrategyValid = true
-						default:
-							importStrategyValid = false
-						}
-
-						/*
-						 * Check if import strategy is valid.
-						 */
-						if !importStrategyValid {
-							reason := fmt.Sprintf("Invalid import strategy: '%s'", strategy)
-
-							/*
-							 * Indicate failure.
-							 */
-							status := webResponseStruct{
-								Success: false,
-								Reason:  reason,
-							}
-
-							migrationReport.Status = status
-						} else {
-							gu := geoutil.Create()
-							report, errMigrate := gu.Migrate(target, source, importStrategy)
-							reportBefore := report.Before()
-							reportBeforeLocationCount := reportBefore.LocationCount()
-							reportBeforeOrdered := reportBefore.Ordered()
-							reportBeforeOrderedStrict := reportBefore.OrderedStrict()
-							reportBeforeTimestampEarliest := reportBefore.TimestampEarliest()
-							reportBeforeTimestampEarliestTime := gu.MillisecondsToTime(reportBeforeTimestampEarliest)
-							reportBeforeTimestampEarliestString := reportBeforeTimestampEarliestTime.Format(TIMESTAMP_FORMAT)
-
-							/*
-							 * Strip default value from report.
-							 */
-							if reportBeforeTimestampEarliest == math.MaxUint64 {
-								reportBeforeTimestampEarliestString = ""
-							}
-
-							reportBeforeTimestampLatest := reportBefore.TimestampLatest()
-							reportBeforeTimestampLatestTime := gu.MillisecondsToTime(reportBeforeTimestampLatest)
-							reportBeforeTimestampLatestString := reportBeforeTimestampLatestTime.Format(TIMESTAMP_FORMAT)
-
-							/*
-							 * Strip default value from report.
-							 */
-							if reportBeforeTimestampLatest == 0 {
-								reportBeforeTimestampLatestString = ""
-							}
-
-							/*
-							 * Create statistics for GeoDB state before data migration.
-							 */
-							webStatsBefore := webDatasetStatsStruct{
-								LocationCount:     reportBeforeLocationCount,
-								Ordered:           reportBeforeOrdered,
-								OrderedStrict:     reportBeforeOrderedStrict,
-								TimestampEarliest: reportBeforeTimestampEarliestString,
-								TimestampLatest:   reportBeforeTimestampLatestString,
-							}
-
-							reportSource := report.Source()
-							reportSourceLocationCount := reportSource.LocationCount()
-							reportSourceOrdered := reportSource.Ordered()
-							reportSourceOrderedStrict := reportSource.OrderedStrict()
-							reportSourceTimestampEarliest := reportSource.TimestampEarliest()
-							reportSourceTimestampEarliestTime := gu.MillisecondsToTime(reportSourceTimestampEarliest)
-							reportSourceTimestampEarliestString := reportSourceTimestampEarliestTime.Format(TIMESTAMP_FORMAT)
-
-							/*
-							 * Strip default value from report.
-							 */
-							if reportSourceTimestampEarliest == math.MaxUint64 {
-								reportSourceTimestampEarliestString = ""
-							}
-
-							reportSourceTimestampLatest := reportSource.TimestampLatest()
-							reportSourceTimestampLatestTime := gu.MillisecondsToTime(reportSourceTimestampLatest)
-							reportSourceTimestampLatestString := reportSourceTimestampLatestTime.Format(TIMESTAMP_FORMAT)
-
-							/*
-							 * Strip default value from report.
-							 */
-							if reportSourceTimestampLatest == 0 {
-								reportSourceTimestampLatestString = ""
-							}
-
-							/*
-							 * Create statistics for GeoJSON data provided as source.
-							 */
-							webStatsSource := webDatasetStatsStruct{
-								LocationCount:     reportSourceLocationCount,
-								Ordered:           reportSourceOrdered,
-								OrderedStrict:     reportSourceOrderedStrict,
-								TimestampEarliest: reportSourceTimestampEarliestString,
-								TimestampLatest:   reportSourceTimestampLatestString,
-							}
-
-							reportImported := report.Imported()
-							reportImportedLocationCount := reportImported.LocationCount()
-							reportImportedOrdered := reportImported.Ordered()
-							reportImportedOrderedStrict := reportImported.OrderedStrict()
-							reportImportedTimestampEarliest := reportImported.TimestampEarliest()
-							reportImportedTimestampEarliestTime := gu.MillisecondsToTime(reportImportedTimestampEarliest)
-							reportImportedTimestampEarliestString := reportImportedTimestampEarliestTime.Format(TIMESTAMP_FORMAT)
-
-							/*
-							 * Strip default value from report.
-							 */
-							if reportImportedTimestampEarliest == math.MaxUint64 {
-								reportImportedTimestampEarliestString = ""
-							}
-
-							reportImportedTimestampLatest := reportImported.TimestampLatest()
-							reportImportedTimestampLatestTime := gu.MillisecondsToTime(reportImportedTimestampLatest)
-							reportImportedTimestampLatestString := reportImportedTimestampLatestTime.Format(TIMESTAMP_FORMAT)
-
-							/*
-							 * Strip default value from report.
-							 */
-							if reportImportedTimestampLatest == 0 {
-								reportImportedTimestampLatestString = ""
-							}
-
-							/*
-							 * Create statistics for GeoJSON data actually imported.
-							 */
-							webStatsImported := webDatasetStatsStruct{
-								LocationCount:     reportImportedLocationCount,
-								Ordered:           reportImportedOrdered,
-								OrderedStrict:     reportImportedOrderedStrict,
-								TimestampEarliest: reportImportedTimestampEarliestString,
-								TimestampLatest:   reportImportedTimestampLatestString,
-							}
-
-							reportAfter := report.After()
-							reportAfterLocationCount := reportAfter.LocationCount()
-							reportAfterOrdered := reportAfter.Ordered()
-							reportAfterOrderedStrict := reportAfter.OrderedStrict()
-							reportAfterTimestampEarliest := reportAfter.TimestampEarliest()
-							reportAfterTimestampEarliestTime := gu.MillisecondsToTime(reportAfterTimestampEarliest)
-							reportAfterTimestampEarliestString := reportAfterTimestampEarliestTime.Format(TIMESTAMP_FORMAT)
-
-							/*
-							 * Strip default value from report.
-							 */
-							if reportAfterTimestampEarliest == math.MaxUint64 {
-								reportAfterTimestampEarliestString = ""
-							}
-
-							reportAfterTimestampLatest := reportAfter.TimestampLatest()
-							reportAfterTimestampLatestTime := gu.MillisecondsToTime(reportAfterTimestampLatest)
-							reportAfterTimestampLatestString := reportAfterTimestampLatestTime.Format(TIMESTAMP_FORMAT)
-
-							/*
-							 * Strip default value from report.
-							 */
-							if reportAfterTimestampLatest == 0 {
-								reportAfterTimestampLatestString = ""
-							}
-
-							/*
-							 * Create statistics for GeoDB state after data migration.
-							 */
-							webStatsAfter := webDatasetStatsStruct{
-								LocationCount:     reportAfterLocationCount,
-								Ordered:           reportAfterOrdered,
-								OrderedStrict:     reportAfterOrderedStrict,
-								TimestampEarliest: reportAfterTimestampEarliestString,
-								TimestampLatest:   reportAfterTimestampLatestString,
-							}
-
-							/*
-							 * Create migration report.
-							 */
-							migrationReport = webMigrationReportStruct{
-								Before:   webStatsBefore,
-								Source:   webStatsSource,
-								Imported: webStatsImported,
-								After:    webStatsAfter,
-							}
-
-							/*
-							 * Check if error happened during migration.
-							 */
-							if errMigrate != nil {
-								msg := errMigrate.Error()
-
-								/*
-								 * Indicate failure.
-								 */
-								status := webResponseStruct{
-									Success: false,
-									Reason:  msg,
-								}
-
-								migrationReport.Status = status
-							} else {
-
-								/*
-								 * Indicate success.
-								 */
-								status := webResponseStruct{
-									Success: true,
-									Reason:  "",
-								}
-
-								migrationReport.Status = status
-							}
-
-						}
-
-					}
-
-				}
-
+			wr = webResponseStruct{
+				Success: true,
+				Reason:  "",
 			}
 
 		}
 
 	}
 
-	mimeType, buffer := this.createJSON(migrationReport)
+	mimeType, buffer := this.createJSON(wr)
 
 	/*
 	 * Create HTTP response.
@@ -1952,205 +2710,165 @@ func (this *controllerStruct) importGeoDataHandler(request webserver.HttpRequest
 }
 
 /*
- * Modify entries in GeoDB location database.
+ * Client requests to obtain a challenge to authenticate as a user.
  */
-func (this *controllerStruct) modifyGeoDataHandler(request webserver.HttpRequest) webserver.HttpResponse {
-	token := request.Params["token"]
-	perm, err := this.checkPermission(token, "geodb-write")
-	report := webDatasetModificationReportStruct{}
+func (this *controllerStruct) authRequestHandler(request webserver.HttpRequest) webserver.HttpResponse {
+	name := request.Params["name"]
+	wac := webAuthChallengeStruct{}
+	sm := this.sessionManager
+	c, err := sm.Challenge(name)
 
 	/*
-	 * Check permissions.
+	 * Check if challenge was created.
 	 */
 	if err != nil {
 		msg := err.Error()
-		reason := fmt.Sprintf("Failed to check permission: %s\n", msg)
+		reason := fmt.Sprintf("Failed to create challenge: %s", msg)
 
 		/*
-		 * Report failure.
+		 * Indicate failure.
 		 */
-		report.Status = webResponseStruct{
-			Success: false,
-			Reason:  reason,
-		}
+		wac = webAuthChallengeStruct{
 
-	} else if !perm {
-		reason := "Forbidden!"
+			webResponseStruct: webResponseStruct{
+				Success: false,
+				Reason:  reason,
+			},
 
-		/*
-		 * Report failure.
-		 */
-		report.Status = webResponseStruct{
-			Success: false,
-			Reason:  reason,
+			Nonce: "",
+			Salt:  "",
 		}
 
 	} else {
-		db := this.locationDB
+		enc := base64.StdEncoding
+		nonce := c.Nonce()
+		salt := c.Salt()
+		nonceString := enc.EncodeToString(nonce[:])
+		saltString := enc.EncodeToString(salt[:])
 
 		/*
-		 * Make sure database exists.
+		 * Create authentication challenge.
 		 */
-		if db != nil {
-			gu := geoutil.Create()
-			datasetStatsBefore := webDatasetStatsStruct{}
-			datasetStatsAfter := webDatasetStatsStruct{}
-			statsBefore, err := gu.GeoDBStats(db)
+		wac = webAuthChallengeStruct{
 
-			/*
-			 * Make sure that no error occured.
-			 */
-			if err != nil {
-				msg := err.Error()
-				reason := fmt.Sprintf("Error obtaining database stats: %s", msg)
+			webResponseStruct: webResponseStruct{
+				Success: true,
+				Reason:  "",
+			},
 
-				/*
-				 * Report failure.
-				 */
-				report.Status = webResponseStruct{
-					Success: false,
-					Reason:  reason,
-				}
+			Nonce: nonceString,
+			Salt:  saltString,
+		}
 
-			} else {
-				locationCountBefore := statsBefore.LocationCount()
-				orderedBefore := statsBefore.Ordered()
-				orderedStrictBefore := statsBefore.OrderedStrict()
-				timestampEarliestBefore := statsBefore.TimestampEarliest()
-				timestampLatestBefore := statsBefore.TimestampLatest()
-				timestampEarliestStringBefore := ""
-				timestampLatestStringBefore := ""
+	}
 
-				/*
-				 * Check if timestamps are defined.
-				 */
-				if timestampEarliestBefore <= timestampLatestBefore {
-					timestampEarliestTimeBefore := gu.MillisecondsToTime(timestampEarliestBefore)
-					timestampEarliestStringBefore = timestampEarliestTimeBefore.Format(TIMESTAMP_FORMAT)
-					timestampLatestTimeBefore := gu.MillisecondsToTime(timestampLatestBefore)
-					timestampLatestStringBefore = timestampLatestTimeBefore.Format(TIMESTAMP_FORMAT)
-				}
+	mimeType, buffer := this.createJSON(wac)
 
-				/*
-				 * Create dataset statistics.
-				 */
-				datasetStatsBefore = webDatasetStatsStruct{
-					LocationCount:     locationCountBefore,
-					Ordered:           orderedBefore,
-					OrderedStrict:     orderedStrictBefore,
-					TimestampEarliest: timestampEarliestStringBefore,
-					TimestampLatest:   timestampLatestStringBefore,
-				}
+	/*
+	 * Create HTTP response.
+	 */
+	response := webserver.HttpResponse{
+		Header: map[string]string{"Content-type": mimeType},
+		Body:   buffer,
+	}
 
-				action := request.Params["action"]
-				n := uint32(0)
-				err := fmt.Errorf("Unknown action: '%s'", action)
-				actionDescription := "unknown action"
+	return response
+}
 
-				/*
-				 * Decide which action to carry out.
-				 */
-				switch action {
-				case "deduplicate":
-					actionDescription = "deduplication"
-					n, err = db.Deduplicate()
-				case "sort":
-					actionDescription = "sorting"
-					err = db.Sort()
-				}
+/*
+ * Client sends authentication response to obtain session token.
+ */
+func (this *controllerStruct) authResponseHandler(request webserver.HttpRequest) webserver.HttpResponse {
+	enc := base64.StdEncoding
+	name := request.Params["name"]
+	hashIn := request.Params["hash"]
+	code := request.Params["code"]
+	responseToken := webTokenStruct{}
+	hash, err := enc.DecodeString(hashIn)
 
-				/*
-				 * Make sure that no error occured.
-				 */
-				if err != nil {
-					msg := err.Error()
-					reason := fmt.Sprintf("Error during %s: %s", actionDescription, msg)
+	/*
+	 * Check if hash could be decoded.
+	 */
+	if err != nil {
 
-					/*
-					 * Report failure.
-					 */
-					report.Status = webResponseStruct{
-						Success: false,
-						Reason:  reason,
-					}
+		/*
+		 * Indicate failure.
+		 */
+		responseToken = webTokenStruct{
 
-				} else {
-					statsAfter, err := gu.GeoDBStats(db)
+			webResponseStruct: webResponseStruct{
+				Success: false,
+				Reason:  "Failed to decode hash value.",
+			},
 
-					/*
-					 * Make sure that no error occured.
-					 */
-					if err != nil {
-						msg := err.Error()
-						reason := fmt.Sprintf("Error obtaining database stats: %s", msg)
+			Token: "",
+		}
 
-						/*
-						 * Report failure.
-						 */
-						report.Status = webResponseStruct{
-							Success: false,
-							Reason:  reason,
-						}
+	} else {
+		sm := this.sessionManager
+		t, err := sm.Response(name, hash, code)
+		mgr := this.userManager
+		totpEnabled, _ := mgr.TOTPEnabled(name)
 
-					} else {
-						locationCountAfter := statsAfter.LocationCount()
-						orderedAfter := statsAfter.Ordered()
-						orderedStrictAfter := statsAfter.OrderedStrict()
-						timestampEarliestAfter := statsAfter.TimestampEarliest()
-						timestampLatestAfter := statsAfter.TimestampLatest()
-						timestampEarliestStringAfter := ""
-						timestampLatestStringAfter := ""
+		/*
+		 * The time step of the accepted one-time password must be
+		 * persisted, so that the code cannot be used again after a
+		 * restart.
+		 */
+		if (err == nil) && totpEnabled {
+			err = this.syncUserDB()
 
-						/*
-						* Check if timestamps are defined.
-						 */
-						if timestampEarliestAfter <= timestampLatestAfter {
-							timestampEarliestTimeAfter := gu.MillisecondsToTime(timestampEarliestAfter)
-							timestampEarliestStringAfter = timestampEarliestTimeAfter.Format(TIMESTAMP_FORMAT)
-							timestampLatestTimeAfter := gu.MillisecondsToTime(timestampLatestAfter)
-							timestampLatestStringAfter = timestampLatestTimeAfter.Format(TIMESTAMP_FORMAT)
-						}
+			/*
+			 * Do not hand out a session we cannot protect against replay.
+			 */
+			if err != nil {
+				sm.Terminate(t)
+			}
 
-						/*
-						* Create dataset statistics.
-						 */
-						datasetStatsAfter = webDatasetStatsStruct{
-							LocationCount:     locationCountAfter,
-							Ordered:           orderedAfter,
-							OrderedStrict:     orderedStrictAfter,
-							TimestampEarliest: timestampEarliestStringAfter,
-							TimestampLatest:   timestampLatestStringAfter,
-						}
+		}
 
-						/*
-						 * Report success.
-						 */
-						status := webResponseStruct{
-							Success: true,
-							Reason:  "",
-						}
+		/*
+		 * Check if session was created.
+		 */
+		if err != nil {
+			msg := err.Error()
+			reason := fmt.Sprintf("Failed to create session: %s", msg)
 
-						/*
-						 * Create dataset modification report.
-						 */
-						report = webDatasetModificationReportStruct{
-							Status:  status,
-							Before:  datasetStatsBefore,
-							After:   datasetStatsAfter,
-							Removed: n,
-						}
+			/*
+			 * Indicate failure.
+			 */
+			responseToken = webTokenStruct{
 
-					}
+				webResponseStruct: webResponseStruct{
+					Success: false,
+					Reason:  reason,
+				},
 
-				}
+				Token: "",
+			}
+
+		} else {
+			token := t.Token()
+			tokenString := enc.EncodeToString(token[:])
+
+			/*
+			 * Create data structure for session token.
+			 */
+			responseToken = webTokenStruct{
+
+				webResponseStruct: webResponseStruct{
+					Success: true,
+					Reason:  "",
+				},
 
+				Token: tokenString,
 			}
 
 		}
 
 	}
 
-	mimeType, buffer := this.createJSON(report)
+	mimeType, buffer := this.createJSON(responseToken)
 
 	/*
 	 * Create HTTP response.
@@ -2164,181 +2882,160 @@ func (this *controllerStruct) modifyGeoDataHandler(request webserver.HttpRequest
 }
 
 /*
- * Remove activity information from database.
+ * Client sends signature over authentication challenge to obtain session token.
  */
-func (this *controllerStruct) removeActivityHandler(request webserver.HttpRequest) webserver.HttpResponse {
-	token := request.Params["token"]
-	perm, err := this.checkPermission(token, "activity-write")
+func (this *controllerStruct) authResponsePublicKeyHandler(request webserver.HttpRequest) webserver.HttpResponse {
+	enc := base64.StdEncoding
+	name := request.Params["name"]
+	signatureIn := request.Params["signature"]
+	responseToken := webTokenStruct{}
+	signature, err := enc.DecodeString(signatureIn)
 
 	/*
-	 * Check permissions.
+	 * Check if signature could be decoded.
 	 */
 	if err != nil {
-		msg := err.Error()
-		customMsg := fmt.Sprintf("Failed to check permission: %s", msg)
-		customMsgBuf := bytes.NewBufferString(customMsg)
-		customMsgBytes := customMsgBuf.Bytes()
-		conf := this.config
-		confServer := conf.WebServer
-		contentType := confServer.ErrorMime
 
 		/*
-		 * Create HTTP response.
+		 * Indicate failure.
 		 */
-		response := webserver.HttpResponse{
-			Header: map[string]string{"Content-type": contentType},
-			Body:   customMsgBytes,
-		}
+		responseToken = webTokenStruct{
 
-		return response
-	} else if !perm {
-		customMsgBuf := bytes.NewBufferString("Forbidden!")
-		customMsgBytes := customMsgBuf.Bytes()
-		conf := this.config
-		confServer := conf.WebServer
-		contentType := confServer.ErrorMime
+			webResponseStruct: webResponseStruct{
+				Success: false,
+				Reason:  "Failed to decode signature.",
+			},
 
-		/*
-		 * Create HTTP response.
-		 */
-		response := webserver.HttpResponse{
-			Header: map[string]string{"Content-type": contentType},
-			Body:   customMsgBytes,
+			Token: "",
 		}
 
-		return response
 	} else {
-		wr := webResponseStruct{}
-		revisionIn := request.Params["revision"]
-		revision, err := strconv.ParseUint(revisionIn, 10, 64)
+		sm := this.sessionManager
+		t, err := sm.ResponsePublicKey(name, signature)
 
 		/*
-		 * Check if revision could be parsed.
+		 * Check if session was created.
 		 */
 		if err != nil {
+			msg := err.Error()
+			reason := fmt.Sprintf("Failed to create session: %s", msg)
 
 			/*
 			 * Indicate failure.
 			 */
-			wr = webResponseStruct{
-				Success: false,
-				Reason:  "Failed to remove activity: Invalid revision number.",
+			responseToken = webTokenStruct{
+
+				webResponseStruct: webResponseStruct{
+					Success: false,
+					Reason:  reason,
+				},
+
+				Token: "",
 			}
 
 		} else {
-			idIn := request.Params["id"]
-			id64, err := strconv.ParseUint(idIn, 10, 32)
+			token := t.Token()
+			tokenString := enc.EncodeToString(token[:])
 
 			/*
-			 * Check if ID could be parsed.
+			 * Create data structure for session token.
 			 */
-			if err != nil {
-
-				/*
-				 * Indicate failure.
-				 */
-				wr = webResponseStruct{
-					Success: false,
-					Reason:  "Failed to remove activity: Invalid id.",
-				}
-
-			} else {
-				id := uint32(id64)
-				this.activitiesLock.Lock()
-				activities := this.activities
-				currentRevision := activities.Revision()
-
-				/*
-				 * Make sure that revision information matches.
-				 */
-				if revision != currentRevision {
-
-					/*
-					 * Indicate failure.
-					 */
-					wr = webResponseStruct{
-						Success: false,
-						Reason:  "Failed to remove activity: Activity data was changed in the meantime.",
-					}
-
-				} else {
-					err := activities.Remove(id)
-
-					/*
-					 * Check if activity was removed.
-					 */
-					if err != nil {
-						msg := err.Error()
-						reason := fmt.Sprintf("Failed to remove activity: %s", msg)
-
-						/*
-						 * Indicate failure.
-						 */
-						wr = webResponseStruct{
-							Success: false,
-							Reason:  reason,
-						}
-
-					} else {
-						err = this.syncActivityDB()
-
-						/*
-						 * Check if user database was synchronized.
-						 */
-						if err != nil {
-							msg := err.Error()
-							reason := fmt.Sprintf("Failed to synchronize activity database: %s", msg)
+			responseToken = webTokenStruct{
 
-							/*
-							 * Indicate failure.
-							 */
-							wr = webResponseStruct{
-								Success: false,
-								Reason:  reason,
-							}
+				webResponseStruct: webResponseStruct{
+					Success: true,
+					Reason:  "",
+				},
 
-						} else {
+				Token: tokenString,
+			}
 
-							/*
-							 * Indicate success.
-							 */
-							wr = webResponseStruct{
-								Success: true,
-								Reason:  "",
-							}
+		}
 
-						}
+	}
 
-					}
+	mimeType, buffer := this.createJSON(responseToken)
 
-				}
+	/*
+	 * Create HTTP response.
+	 */
+	response := webserver.HttpResponse{
+		Header: map[string]string{"Content-type": mimeType},
+		Body:   buffer,
+	}
 
-				this.activitiesLock.Unlock()
-			}
+	return response
+}
 
-		}
+/*
+ * Determines the range of time stamps considered valid when repairing the
+ * location database.
+ *
+ * Returns the earliest and latest valid time stamp in milliseconds since the
+ * Epoch. If no earliest time stamp is configured, only time stamps of zero
+ * are considered invalid. If no maximum is configured, time stamps may lie up
+ * to one day in the future.
+ */
+func (this *controllerStruct) timestampRepairRange() (uint64, uint64, error) {
+	conf := this.getConfig()
+	repair := conf.RepairTimestamps
+	earliestIn := repair.Earliest
+	maxFutureIn := repair.MaxFuture
+	earliest := uint64(1)
+	maxFuture := 24 * time.Hour
+	errEarliest := error(nil)
+	errMaxFuture := error(nil)
 
-		mimeType, buffer := this.createJSON(wr)
+	/*
+	 * Parse earliest time stamp if it was configured.
+	 */
+	if earliestIn != "" {
+		earliestTime, err := time.Parse(time.RFC3339, earliestIn)
+		earliestNano := earliestTime.UnixNano()
+		earliestMilli := earliestNano / int64(time.Millisecond)
+		errEarliest = err
 
 		/*
-		 * Create HTTP response.
+		 * Time stamps before the Epoch are never valid.
 		 */
-		response := webserver.HttpResponse{
-			Header: map[string]string{"Content-type": mimeType},
-			Body:   buffer,
+		if earliestMilli > 0 {
+			earliest = uint64(earliestMilli)
 		}
 
-		return response
+	}
+
+	/*
+	 * Parse maximum time into the future if it was configured.
+	 */
+	if maxFutureIn != "" {
+		maxFuture, errMaxFuture = time.ParseDuration(maxFutureIn)
+	}
+
+	/*
+	 * Check if configuration is valid.
+	 */
+	if errEarliest != nil {
+		return 0, 0, fmt.Errorf("Invalid earliest time stamp: '%s'", earliestIn)
+	} else if (errMaxFuture != nil) || (maxFuture < 0) {
+		return 0, 0, fmt.Errorf("Invalid maximum time into the future: '%s'", maxFutureIn)
+	} else {
+		now := time.Now()
+		latestTime := now.Add(maxFuture)
+		latestNano := latestTime.UnixNano()
+		latestMilli := latestNano / int64(time.Millisecond)
+		latest := uint64(latestMilli)
+		return earliest, latest, nil
 	}
 
 }
 
 /*
- * Replace activity information inside the database.
+ * Download a backup of the activity database.
  */
-func (this *controllerStruct) replaceActivityHandler(request webserver.HttpRequest) webserver.HttpResponse {
+func (this *controllerStruct) downloadActivityBackupHandler(request webserver.HttpRequest) webserver.HttpResponse {
 	token := request.Params["token"]
-	perm, err := this.checkPermission(token, "activity-write")
+	perm, err := this.checkPermission(token, "activity-read")
+	partition, errPartition := this.requestPartition(request)
 
 	/*
 	 * Check permissions.
@@ -2348,7 +3045,7 @@ func (this *controllerStruct) replaceActivityHandler(request webserver.HttpReque
 		customMsg := fmt.Sprintf("Failed to check permission: %s", msg)
 		customMsgBuf := bytes.NewBufferString(customMsg)
 		customMsgBytes := customMsgBuf.Bytes()
-		conf := this.config
+		conf := this.getConfig()
 		confServer := conf.WebServer
 		contentType := confServer.ErrorMime
 
@@ -2364,7 +3061,25 @@ func (this *controllerStruct) replaceActivityHandler(request webserver.HttpReque
 	} else if !perm {
 		customMsgBuf := bytes.NewBufferString("Forbidden!")
 		customMsgBytes := customMsgBuf.Bytes()
-		conf := this.config
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType},
+			Body:   customMsgBytes,
+		}
+
+		return response
+	} else if errPartition != nil {
+		msg := errPartition.Error()
+		customMsg := fmt.Sprintf("Failed to access data: %s", msg)
+		customMsgBuf := bytes.NewBufferString(customMsg)
+		customMsgBytes := customMsgBuf.Bytes()
+		conf := this.getConfig()
 		confServer := conf.WebServer
 		contentType := confServer.ErrorMime
 
@@ -2378,465 +3093,10281 @@ func (this *controllerStruct) replaceActivityHandler(request webserver.HttpReque
 
 		return response
 	} else {
-		wr := webResponseStruct{}
-		revisionIn := request.Params["revision"]
-		revision, err := strconv.ParseUint(revisionIn, 10, 64)
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+		name := request.Params["name"]
+		backups, err := this.listActivityBackups(partition)
+		path := ""
 
 		/*
-		 * Check if revision could be parsed.
+		 * Look for the requested backup.
+		 */
+		for _, backup := range backups {
+
+			/*
+			 * Check if this is the requested backup.
+			 */
+			if backup.name == name {
+				path = backup.path
+			}
+
+		}
+
+		/*
+		 * Check if backup exists.
 		 */
 		if err != nil {
+			msg := err.Error()
+			customMsgBuf := bytes.NewBufferString(msg)
+			customMsgBytes := customMsgBuf.Bytes()
 
 			/*
-			 * Indicate failure.
+			 * Create HTTP response.
 			 */
-			wr = webResponseStruct{
-				Success: false,
-				Reason:  "Failed to remove activity: Invalid revision number.",
+			response := webserver.HttpResponse{
+				Header: map[string]string{"Content-type": contentType},
+				Body:   customMsgBytes,
+			}
+
+			return response
+		} else if path == "" {
+			customMsg := fmt.Sprintf("There is no backup of the activity database named '%s'.", name)
+			customMsgBuf := bytes.NewBufferString(customMsg)
+			customMsgBytes := customMsgBuf.Bytes()
+
+			/*
+			 * Create HTTP response.
+			 */
+			response := webserver.HttpResponse{
+				Header: map[string]string{"Content-type": contentType},
+				Body:   customMsgBytes,
 			}
 
+			return response
 		} else {
-			idIn := request.Params["id"]
-			id64, err := strconv.ParseUint(idIn, 10, 32)
+			fd, err := os.Open(path)
 
 			/*
-			 * Check if ID could be parsed.
+			 * Check if backup could be opened.
 			 */
 			if err != nil {
+				msg := err.Error()
+				customMsg := fmt.Sprintf("Failed to open backup of activity database: %s", msg)
+				customMsgBuf := bytes.NewBufferString(customMsg)
+				customMsgBytes := customMsgBuf.Bytes()
 
 				/*
-				 * Indicate failure.
+				 * Create HTTP response.
 				 */
-				wr = webResponseStruct{
-					Success: false,
-					Reason:  "Failed to replace activity: Invalid id.",
+				response := webserver.HttpResponse{
+					Header: map[string]string{"Content-type": contentType},
+					Body:   customMsgBytes,
 				}
 
+				return response
 			} else {
-				id := uint32(id64)
-				beginIn := request.Params["begin"]
-				begin, err := filter.ParseTime(beginIn, false, false)
+				disposition := fmt.Sprintf("attachment; filename=\"%s\"", name)
 
 				/*
-				 * The begin time has to be filled in correctly.
+				 * Create HTTP response.
 				 */
-				if err != nil {
-					reason := "Failed to add activity: Could not parse the begin time."
+				response := webserver.HttpResponse{
 
-					/*
-					 * Indicate failure.
-					 */
-					wr = webResponseStruct{
-						Success: false,
-						Reason:  reason,
-					}
+					Header: map[string]string{
+						"Content-disposition": disposition,
+						"Content-type":        "application/json; charset=utf-8",
+					},
 
-				} else {
-					weightKG := request.Params["weightkg"]
-					runningDurationIn := request.Params["runningduration"]
-					runningDuration, _ := time.ParseDuration(runningDurationIn)
-					runningDistanceKM := request.Params["runningdistancekm"]
-					runningStepCountIn := request.Params["runningstepcount"]
-					runningStepCount, _ := strconv.ParseUint(runningStepCountIn, 10, 64)
-					runningEnergyKJIn := request.Params["runningenergykj"]
-					runningEnergyKJ, _ := strconv.ParseUint(runningEnergyKJIn, 10, 64)
-					cyclingDurationIn := request.Params["cyclingduration"]
-					cyclingDuration, _ := time.ParseDuration(cyclingDurationIn)
-					cyclingDistanceKM := request.Params["cyclingdistancekm"]
-					cycingEnergyKJIn := request.Params["cyclingenergykj"]
-					cyclingEnergyKJ, _ := strconv.ParseUint(cycingEnergyKJIn, 10, 64)
-					otherEnergyKJIn := request.Params["otherenergykj"]
-					otherEnergyKJ, _ := strconv.ParseUint(otherEnergyKJIn, 10, 64)
+					ContentReadSeekCloser: fd,
+				}
 
-					/*
-					 * Create activity info.
-					 */
-					info := meta.ActivityInfo{
-						Begin:             begin,
-						WeightKG:          weightKG,
-						RunningDuration:   runningDuration,
-						RunningDistanceKM: runningDistanceKM,
-						RunningStepCount:  runningStepCount,
-						RunningEnergyKJ:   runningEnergyKJ,
-						CyclingDuration:   cyclingDuration,
-						CyclingDistanceKM: cyclingDistanceKM,
-						CyclingEnergyKJ:   cyclingEnergyKJ,
-						OtherEnergyKJ:     otherEnergyKJ,
-					}
+				return response
+			}
+
+		}
+
+	}
+
+}
+
+/*
+ * Download the contents of the GeoDB location database.
+ */
+func (this *controllerStruct) downloadGeoDBContentHandler(request webserver.HttpRequest) webserver.HttpResponse {
+	token := request.Params["token"]
+	format := request.Params["format"]
+	tz := request.Params["tz"]
+	precision := request.Params["precision"]
+	redact := request.Params["redact"]
+	strideIn := request.Params["stride"]
+	coords := request.Params["coords"]
+	gapIn := request.Params["gap"]
+	revisionIn := request.Params["revision"]
+	sinceIn := request.Params["since"]
+	permA, errA := this.checkPermission(token, "geodb-read")
+	permB, errB := this.checkPermission(token, "geodb-download")
+	partition, errPartition := this.requestPartition(request)
+	location, errTz := time.LoadLocation(tz)
+	transform, errTransform := this.createExportTransform(precision, redact)
+	stride64 := uint64(1)
+	errStride := error(nil)
+
+	/*
+	 * Parse stride if it was provided.
+	 */
+	if strideIn != "" {
+		stride64, errStride = strconv.ParseUint(strideIn, 10, 32)
+	}
+
+	gap := time.Duration(0)
+	errGap := error(nil)
+
+	/*
+	 * Parse time gap if it was provided.
+	 */
+	if gapIn != "" {
+		gap, errGap = time.ParseDuration(gapIn)
+	}
+
+	incremental := sinceIn != ""
+	revision := uint64(0)
+	since64 := uint64(0)
+	errRevision := error(nil)
+	errSince := error(nil)
+
+	/*
+	 * Parse revision and location count known to the client if an
+	 * incremental backup was requested.
+	 */
+	if incremental {
+		revision, errRevision = strconv.ParseUint(revisionIn, 10, 64)
+		since64, errSince = strconv.ParseUint(sinceIn, 10, 32)
+	}
+
+	/*
+	 * Check permissions.
+	 */
+	if errA != nil {
+		msg := errA.Error()
+		customMsg := fmt.Sprintf("Failed to check permission: %s\n", msg)
+		customMsgBuf := bytes.NewBufferString(customMsg)
+		customMsgBytes := customMsgBuf.Bytes()
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType},
+			Body:   customMsgBytes,
+		}
+
+		return response
+	} else if errB != nil {
+		msg := errB.Error()
+		customMsg := fmt.Sprintf("Failed to check permission: %s\n", msg)
+		customMsgBuf := bytes.NewBufferString(customMsg)
+		customMsgBytes := customMsgBuf.Bytes()
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType},
+			Body:   customMsgBytes,
+		}
+
+		return response
+	} else if !permA || !permB {
+		customMsgBuf := bytes.NewBufferString("Forbidden!")
+		customMsgBytes := customMsgBuf.Bytes()
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType},
+			Body:   customMsgBytes,
+		}
+
+		return response
+	} else if errPartition != nil {
+		msg := errPartition.Error()
+		customMsg := fmt.Sprintf("Failed to access data: %s", msg)
+		customMsgBuf := bytes.NewBufferString(customMsg)
+		customMsgBytes := customMsgBuf.Bytes()
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType},
+			Body:   customMsgBytes,
+		}
+
+		return response
+	} else if errTz != nil {
+		customMsg := fmt.Sprintf("Unknown time zone: '%s'", tz)
+		customMsgBuf := bytes.NewBufferString(customMsg)
+		customMsgBytes := customMsgBuf.Bytes()
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType},
+			Body:   customMsgBytes,
+		}
+
+		return response
+	} else if errTransform != nil {
+		msg := errTransform.Error()
+		customMsgBuf := bytes.NewBufferString(msg)
+		customMsgBytes := customMsgBuf.Bytes()
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType},
+			Body:   customMsgBytes,
+		}
+
+		return response
+	} else if (errStride != nil) || (stride64 == 0) {
+		customMsg := fmt.Sprintf("Stride must be a positive integer, but was '%s'.", strideIn)
+		customMsgBuf := bytes.NewBufferString(customMsg)
+		customMsgBytes := customMsgBuf.Bytes()
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType},
+			Body:   customMsgBytes,
+		}
+
+		return response
+	} else if (errGap != nil) || (gap < 0) {
+		customMsg := fmt.Sprintf("Gap must be a non-negative duration like '30m', but was '%s'.", gapIn)
+		customMsgBuf := bytes.NewBufferString(customMsg)
+		customMsgBytes := customMsgBuf.Bytes()
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType},
+			Body:   customMsgBytes,
+		}
+
+		return response
+	} else if incremental && (format != "binary") {
+		customMsg := fmt.Sprintf("Incremental backups are only supported in binary format, but format was '%s'.", format)
+		customMsgBuf := bytes.NewBufferString(customMsg)
+		customMsgBytes := customMsgBuf.Bytes()
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType},
+			Body:   customMsgBytes,
+		}
+
+		return response
+	} else if errRevision != nil {
+		customMsg := fmt.Sprintf("Revision must be a non-negative integer, but was '%s'.", revisionIn)
+		customMsgBuf := bytes.NewBufferString(customMsg)
+		customMsgBytes := customMsgBuf.Bytes()
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType},
+			Body:   customMsgBytes,
+		}
+
+		return response
+	} else if errSince != nil {
+		customMsg := fmt.Sprintf("Location count must be a non-negative integer, but was '%s'.", sinceIn)
+		customMsgBuf := bytes.NewBufferString(customMsg)
+		customMsgBytes := customMsgBuf.Bytes()
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType},
+			Body:   customMsgBytes,
+		}
+
+		return response
+	} else if (coords != "") && (coords != "degrees") && (coords != "e7") {
+		customMsg := fmt.Sprintf("Coordinate format must be either 'degrees' or 'e7', but was '%s'.", coords)
+		customMsgBuf := bytes.NewBufferString(customMsg)
+		customMsgBytes := customMsgBuf.Bytes()
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType},
+			Body:   customMsgBytes,
+		}
+
+		return response
+	} else {
+		stride := uint32(stride64)
+		customMsgBuf := bytes.NewBufferString("Database not accessible.")
+		customMsgBytes := customMsgBuf.Bytes()
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+
+		/*
+		 * Create default HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType},
+			Body:   customMsgBytes,
+		}
+
+		db := partition.locationDB
+
+		/*
+		 * Make sure database exists.
+		 */
+		if db != nil {
+
+			switch format {
+			case "binary":
+				creationTime := time.Now()
+				timeStamp := creationTime.Format(ARCHIVE_TIME_STAMP)
+
+				/*
+				 * Check if an incremental backup was requested.
+				 */
+				if incremental {
+					since := uint32(since64)
+					contentProvider, currentRevision, tail := db.SerializeBinaryIncremental(revision, since)
+					revisionString := strconv.FormatUint(currentRevision, 10)
+					tailString := strconv.FormatBool(tail)
+					fileName := fmt.Sprintf("locations-%s.geodb", timeStamp)
+
+					/*
+					 * Appended entries are not a database on their own.
+					 */
+					if tail {
+						fileName = fmt.Sprintf("locations-%s.geodb-tail", timeStamp)
+					}
+
+					disposition := fmt.Sprintf("attachment; filename=\"%s\"", fileName)
+
+					/*
+					 * Create HTTP response.
+					 */
+					response = webserver.HttpResponse{
+
+						Header: map[string]string{
+							"Content-disposition": disposition,
+							"Content-type":        "application/octet-stream",
+							"X-Geodb-Incremental": tailString,
+							"X-Geodb-Revision":    revisionString,
+						},
+
+						ContentReadSeekCloser: contentProvider,
+					}
+
+				} else {
+					contentProvider := db.SerializeBinary()
+					fileName := fmt.Sprintf("locations-%s.geodb", timeStamp)
+					disposition := fmt.Sprintf("attachment; filename=\"%s\"", fileName)
+
+					/*
+					 * Create HTTP response.
+					 */
+					response = webserver.HttpResponse{
+
+						Header: map[string]string{
+							"Content-disposition": disposition,
+							"Content-type":        "application/octet-stream",
+						},
+
+						ContentReadSeekCloser: contentProvider,
+					}
+
+				}
+
+			case "csv":
+				contentProvider := db.SerializeCSV(location, stride, transform)
+				creationTime := time.Now()
+				timeStamp := creationTime.Format(ARCHIVE_TIME_STAMP)
+				fileName := fmt.Sprintf("locations-%s.csv", timeStamp)
+				disposition := fmt.Sprintf("attachment; filename=\"%s\"", fileName)
+
+				/*
+				 * Create HTTP response.
+				 */
+				response = webserver.HttpResponse{
+
+					Header: map[string]string{
+						"Content-disposition": disposition,
+						"Content-type":        "text/csv",
+					},
+
+					ContentReadCloser: contentProvider,
+				}
+
+			case "geojson", "geojson-pretty":
+				pretty := format == "geojson-pretty"
+				contentProvider := db.SerializeGeoJSON(pretty, gap, stride, transform)
+				creationTime := time.Now()
+				timeStamp := creationTime.Format(ARCHIVE_TIME_STAMP)
+				fileName := fmt.Sprintf("locations-%s.geojson", timeStamp)
+				disposition := fmt.Sprintf("attachment; filename=\"%s\"", fileName)
+
+				/*
+				 * Create HTTP response.
+				 */
+				response = webserver.HttpResponse{
+
+					Header: map[string]string{
+						"Content-disposition": disposition,
+						"Content-type":        "application/geo+json",
+					},
+
+					ContentReadCloser: contentProvider,
+				}
+
+			case "gpx", "gpx-pretty":
+				pretty := format == "gpx-pretty"
+				contentProvider := db.SerializeXML(pretty, location, stride, transform)
+				creationTime := time.Now()
+				timeStamp := creationTime.Format(ARCHIVE_TIME_STAMP)
+				fileName := fmt.Sprintf("locations-%s.gpx", timeStamp)
+				disposition := fmt.Sprintf("attachment; filename=\"%s\"", fileName)
+
+				/*
+				 * Create HTTP response.
+				 */
+				response = webserver.HttpResponse{
+
+					Header: map[string]string{
+						"Content-disposition": disposition,
+						"Content-type":        "application/gpx+xml",
+					},
+
+					ContentReadCloser: contentProvider,
+				}
+
+			case "json", "json-pretty":
+				pretty := format == "json-pretty"
+				degrees := coords == "degrees"
+				contentProvider := db.SerializeJSON(pretty, degrees, stride, transform)
+				creationTime := time.Now()
+				timeStamp := creationTime.Format(ARCHIVE_TIME_STAMP)
+				fileName := fmt.Sprintf("locations-%s.json", timeStamp)
+				disposition := fmt.Sprintf("attachment; filename=\"%s\"", fileName)
+
+				/*
+				 * Create HTTP response.
+				 */
+				response = webserver.HttpResponse{
+
+					Header: map[string]string{
+						"Content-disposition": disposition,
+						"Content-type":        "application/json; charset=utf-8",
+					},
+
+					ContentReadCloser: contentProvider,
+				}
+
+			case "kml", "kml-pretty":
+				pretty := format == "kml-pretty"
+				contentProvider := db.SerializeKML(pretty, stride, transform)
+				creationTime := time.Now()
+				timeStamp := creationTime.Format(ARCHIVE_TIME_STAMP)
+				fileName := fmt.Sprintf("locations-%s.kml", timeStamp)
+				disposition := fmt.Sprintf("attachment; filename=\"%s\"", fileName)
+
+				/*
+				 * Create HTTP response.
+				 */
+				response = webserver.HttpResponse{
+
+					Header: map[string]string{
+						"Content-disposition": disposition,
+						"Content-type":        "application/vnd.google-earth.kml+xml",
+					},
+
+					ContentReadCloser: contentProvider,
+				}
+
+			default:
+				msg := fmt.Sprintf("Unknown format: '%s'", format)
+				msgBuf := bytes.NewBufferString(msg)
+				msgBytes := msgBuf.Bytes()
+
+				/*
+				 * Create HTTP response.
+				 */
+				response = webserver.HttpResponse{
+					Header: map[string]string{"Content-type": contentType},
+					Body:   msgBytes,
+				}
+
+			}
+
+		}
+
+		return response
+	}
+
+}
+
+/*
+ * Determines the ranges of entries in the location database belonging to
+ * each year, with year boundaries in the provided time zone.
+ *
+ * The database must be ordered by time stamp. Years without entries are
+ * omitted.
+ */
+func (this *controllerStruct) yearRanges(db geodb.Database, location *time.Location) ([]yearRangeStruct, error) {
+	locationCount := db.LocationCount()
+	ranges := []yearRangeStruct{}
+
+	/*
+	 * An empty database has no years.
+	 */
+	if locationCount == 0 {
+		return ranges, nil
+	} else {
+		first := make([]geodb.Location, 1)
+		last := make([]geodb.Location, 1)
+		_, errFirst := db.ReadLocations(0, first)
+		_, errLast := db.ReadLocations(locationCount-1, last)
+
+		/*
+		 * Check if first and last location could be read.
+		 */
+		if errFirst != nil {
+			msg := errFirst.Error()
+			return nil, fmt.Errorf("Failed to read first location: %s", msg)
+		} else if errLast != nil {
+			msg := errLast.Error()
+			return nil, fmt.Errorf("Failed to read last location: %s", msg)
+		} else {
+			gu := geoutil.Create()
+			timeFirst := gu.MillisecondsToTime(first[0].Timestamp)
+			timeFirstLocal := timeFirst.In(location)
+			yearFirst := timeFirstLocal.Year()
+			timeLast := gu.MillisecondsToTime(last[0].Timestamp)
+			timeLastLocal := timeLast.In(location)
+			yearLast := timeLastLocal.Year()
+			errResult := error(nil)
+
+			/*
+			 * Find the entries of each year using binary search.
+			 */
+			for year := yearFirst; (year <= yearLast) && (errResult == nil); year++ {
+				start := time.Date(year, time.January, 1, 0, 0, 0, 0, location)
+				startMs := gu.TimeToMilliseconds(start)
+				next := time.Date(year+1, time.January, 1, 0, 0, 0, 0, location)
+				nextMs := gu.TimeToMilliseconds(next)
+				begin, errBegin := db.SeekTimestamp(startMs)
+				end, errEnd := db.SeekTimestamp(nextMs)
+
+				/*
+				 * Check if boundaries could be determined.
+				 */
+				if errBegin != nil {
+					errResult = errBegin
+				} else if errEnd != nil {
+					errResult = errEnd
+				} else if end > begin {
+
+					/*
+					 * Create year range.
+					 */
+					r := yearRangeStruct{
+						year:  year,
+						begin: begin,
+						end:   end,
+					}
+
+					ranges = append(ranges, r)
+				}
+
+			}
+
+			/*
+			 * Check if error occured.
+			 */
+			if errResult != nil {
+				msg := errResult.Error()
+				return nil, fmt.Errorf("Failed to determine year boundaries: %s", msg)
+			} else {
+				return ranges, nil
+			}
+
+		}
+
+	}
+
+}
+
+/*
+ * Writes a ZIP archive with one file per year into the provided pipe.
+ *
+ * Each year is serialized on its own, so that the location database is only
+ * locked for reading while a year is written. Since the ranges of entries
+ * were determined beforehand, the archive is aborted with an error if entries
+ * were reordered or removed since the database had the provided revision.
+ */
+func (this *controllerStruct) writeYearlyArchive(pw *io.PipeWriter, db geodb.Database, revision uint64, ranges []yearRangeStruct, format string, location *time.Location, stride uint32, transform geodb.LocationTransform) {
+	zw := zip.NewWriter(pw)
+	numRanges := len(ranges)
+	errResult := error(nil)
+
+	/*
+	 * Write a file for each year until an error occurs.
+	 */
+	for i := 0; (i < numRanges) && (errResult == nil); i++ {
+		r := ranges[i]
+		year := r.year
+		begin := r.begin
+		end := r.end
+		extension := "csv"
+		contentProvider := io.ReadCloser(nil)
+
+		/*
+		 * Create serializer for the range of the year.
+		 */
+		switch format {
+		case "csv":
+			contentProvider = db.SerializeCSVRange(begin, end, location, stride, transform)
+		case "gpx", "gpx-pretty":
+			pretty := format == "gpx-pretty"
+			extension = "gpx"
+			contentProvider = db.SerializeXMLRange(begin, end, pretty, location, stride, transform)
+		}
+
+		fileName := fmt.Sprintf("locations-%04d.%s", year, extension)
+
+		/*
+		 * Create file header.
+		 */
+		header := zip.FileHeader{
+			Name:     fileName,
+			Method:   zip.Deflate,
+			Modified: time.Now(),
+		}
+
+		w, err := zw.CreateHeader(&header)
+
+		/*
+		 * Check if file could be created within archive.
+		 */
+		if err != nil {
+			errResult = err
+		} else {
+			_, errResult = io.Copy(w, contentProvider)
+		}
+
+		contentProvider.Close()
+		currentRevision := db.Revision()
+
+		/*
+		 * Abort if the entries of the year may have moved.
+		 */
+		if (errResult == nil) && (currentRevision != revision) {
+			errResult = fmt.Errorf("Location database was modified while exporting locations of year %04d.", year)
+		}
+
+	}
+
+	/*
+	 * Only finish the archive if all files were written.
+	 */
+	if errResult == nil {
+		errResult = zw.Close()
+	}
+
+	pw.CloseWithError(errResult)
+}
+
+/*
+ * Download the contents of the GeoDB location database as a ZIP archive,
+ * split into one file per year.
+ */
+func (this *controllerStruct) downloadGeoDBYearlyHandler(request webserver.HttpRequest) webserver.HttpResponse {
+	token := request.Params["token"]
+	format := request.Params["format"]
+	tz := request.Params["tz"]
+	precision := request.Params["precision"]
+	redact := request.Params["redact"]
+	strideIn := request.Params["stride"]
+	permA, errA := this.checkPermission(token, "geodb-read")
+	permB, errB := this.checkPermission(token, "geodb-download")
+	partition, errPartition := this.requestPartition(request)
+	location, errTz := time.LoadLocation(tz)
+	transform, errTransform := this.createExportTransform(precision, redact)
+	stride64 := uint64(1)
+	errStride := error(nil)
+
+	/*
+	 * Parse stride if it was provided.
+	 */
+	if strideIn != "" {
+		stride64, errStride = strconv.ParseUint(strideIn, 10, 32)
+	}
+
+	/*
+	 * Check permissions.
+	 */
+	if errA != nil {
+		msg := errA.Error()
+		customMsg := fmt.Sprintf("Failed to check permission: %s\n", msg)
+		customMsgBuf := bytes.NewBufferString(customMsg)
+		customMsgBytes := customMsgBuf.Bytes()
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType},
+			Body:   customMsgBytes,
+		}
+
+		return response
+	} else if errB != nil {
+		msg := errB.Error()
+		customMsg := fmt.Sprintf("Failed to check permission: %s\n", msg)
+		customMsgBuf := bytes.NewBufferString(customMsg)
+		customMsgBytes := customMsgBuf.Bytes()
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType},
+			Body:   customMsgBytes,
+		}
+
+		return response
+	} else if !permA || !permB {
+		customMsg := "Forbidden!"
+		customMsgBuf := bytes.NewBufferString(customMsg)
+		customMsgBytes := customMsgBuf.Bytes()
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType},
+			Body:   customMsgBytes,
+		}
+
+		return response
+	} else if errPartition != nil {
+		msg := errPartition.Error()
+		customMsg := fmt.Sprintf("Failed to access data: %s", msg)
+		customMsgBuf := bytes.NewBufferString(customMsg)
+		customMsgBytes := customMsgBuf.Bytes()
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType},
+			Body:   customMsgBytes,
+		}
+
+		return response
+	} else if errTz != nil {
+		customMsg := fmt.Sprintf("Unknown time zone: '%s'", tz)
+		customMsgBuf := bytes.NewBufferString(customMsg)
+		customMsgBytes := customMsgBuf.Bytes()
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType},
+			Body:   customMsgBytes,
+		}
+
+		return response
+	} else if errTransform != nil {
+		customMsg := errTransform.Error()
+		customMsgBuf := bytes.NewBufferString(customMsg)
+		customMsgBytes := customMsgBuf.Bytes()
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType},
+			Body:   customMsgBytes,
+		}
+
+		return response
+	} else if (errStride != nil) || (stride64 == 0) {
+		customMsg := fmt.Sprintf("Stride must be a positive integer, but was '%s'.", strideIn)
+		customMsgBuf := bytes.NewBufferString(customMsg)
+		customMsgBytes := customMsgBuf.Bytes()
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType},
+			Body:   customMsgBytes,
+		}
+
+		return response
+	} else if (format != "csv") && (format != "gpx") && (format != "gpx-pretty") {
+		customMsg := fmt.Sprintf("Unknown format: '%s'", format)
+		customMsgBuf := bytes.NewBufferString(customMsg)
+		customMsgBytes := customMsgBuf.Bytes()
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType},
+			Body:   customMsgBytes,
+		}
+
+		return response
+	} else {
+		customMsgBuf := bytes.NewBufferString("Database not accessible.")
+		customMsgBytes := customMsgBuf.Bytes()
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+
+		/*
+		 * Create default HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType},
+			Body:   customMsgBytes,
+		}
+
+		db := partition.locationDB
+
+		/*
+		 * Make sure database exists.
+		 */
+		if db != nil {
+			revision := db.Revision()
+			ranges, err := this.yearRanges(db, location)
+			currentRevision := db.Revision()
+
+			/*
+			 * Check if year boundaries could be determined.
+			 */
+			if err != nil {
+				msg := err.Error()
+				msgBuf := bytes.NewBufferString(msg)
+				msgBytes := msgBuf.Bytes()
+
+				/*
+				 * Create HTTP response.
+				 */
+				response = webserver.HttpResponse{
+					Header: map[string]string{"Content-type": contentType},
+					Body:   msgBytes,
+				}
+
+			} else if currentRevision != revision {
+				msgBuf := bytes.NewBufferString("Failed to determine year boundaries: Location database was modified.")
+				msgBytes := msgBuf.Bytes()
+
+				/*
+				 * Create HTTP response.
+				 */
+				response = webserver.HttpResponse{
+					Header: map[string]string{"Content-type": contentType},
+					Body:   msgBytes,
+				}
+
+			} else {
+				stride := uint32(stride64)
+				pr, pw := io.Pipe()
+				go this.writeYearlyArchive(pw, db, revision, ranges, format, location, stride, transform)
+				creationTime := time.Now()
+				timeStamp := creationTime.Format(ARCHIVE_TIME_STAMP)
+				fileName := fmt.Sprintf("locations-%s.zip", timeStamp)
+				disposition := fmt.Sprintf("attachment; filename=\"%s\"", fileName)
+
+				/*
+				 * Create HTTP response.
+				 */
+				response = webserver.HttpResponse{
+
+					Header: map[string]string{
+						"Content-disposition": disposition,
+						"Content-type":        "application/zip",
+					},
+
+					ContentReadCloser: pr,
+				}
+
+			}
+
+		}
+
+		return response
+	}
+
+}
+
+/*
+ * Export activity data as CSV.
+ */
+func (this *controllerStruct) exportActivitiesCsvHandler(request webserver.HttpRequest) webserver.HttpResponse {
+	token := request.Params["token"]
+	perm, err := this.checkPermission(token, "activity-read")
+	partition, errPartition := this.requestPartition(request)
+
+	/*
+	 * Check permissions.
+	 */
+	if err != nil {
+		msg := err.Error()
+		customMsg := fmt.Sprintf("Failed to check permission: %s\n", msg)
+		customMsgBuf := bytes.NewBufferString(customMsg)
+		customMsgBytes := customMsgBuf.Bytes()
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType},
+			Body:   customMsgBytes,
+		}
+
+		return response
+	} else if !perm {
+		customMsgBuf := bytes.NewBufferString("Forbidden!")
+		customMsgBytes := customMsgBuf.Bytes()
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType},
+			Body:   customMsgBytes,
+		}
+
+		return response
+	} else if errPartition != nil {
+		msg := errPartition.Error()
+		customMsg := fmt.Sprintf("Failed to access data: %s", msg)
+		customMsgBuf := bytes.NewBufferString(customMsg)
+		customMsgBytes := customMsgBuf.Bytes()
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType},
+			Body:   customMsgBytes,
+		}
+
+		return response
+	} else {
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+		partition.activitiesLock.RLock()
+		activities := partition.activities
+		rs, err := activities.ExportCSV()
+		partition.activitiesLock.RUnlock()
+
+		/*
+		 * Check if error occured during export.
+		 */
+		if err != nil {
+			msg := err.Error()
+
+			/*
+			 * Create HTTP response.
+			 */
+			response := webserver.HttpResponse{
+				Header: map[string]string{"Content-type": contentType},
+				Body:   []byte(msg),
+			}
+
+			return response
+		} else {
+
+			/*
+			 * Provide dummy close method.
+			 */
+			rsc := &readSeekerWithNopCloserStruct{
+				rs,
+			}
+
+			creationTime := time.Now()
+			timeStamp := creationTime.Format(ARCHIVE_TIME_STAMP)
+			fileName := fmt.Sprintf("activities-%s.csv", timeStamp)
+			disposition := fmt.Sprintf("attachment; filename=\"%s\"", fileName)
+
+			/*
+			 * Create HTTP response.
+			 */
+			response := webserver.HttpResponse{
+
+				Header: map[string]string{
+					"Content-disposition": disposition,
+					"Content-type":        "text/csv",
+				},
+
+				ContentReadSeekCloser: rsc,
+			}
+
+			return response
+		}
+
+	}
+
+}
+
+/*
+ * Export activity data as JSON.
+ *
+ * The data is streamed one activity group at a time, so memory usage does not
+ * grow with the number of activities.
+ */
+func (this *controllerStruct) exportActivitiesJsonHandler(request webserver.HttpRequest) webserver.HttpResponse {
+	token := request.Params["token"]
+	perm, err := this.checkPermission(token, "activity-read")
+	partition, errPartition := this.requestPartition(request)
+
+	/*
+	 * Check permissions.
+	 */
+	if err != nil {
+		msg := err.Error()
+		customMsg := fmt.Sprintf("Failed to check permission: %s\n", msg)
+		customMsgBuf := bytes.NewBufferString(customMsg)
+		customMsgBytes := customMsgBuf.Bytes()
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType},
+			Body:   customMsgBytes,
+		}
+
+		return response
+	} else if !perm {
+		customMsgBuf := bytes.NewBufferString("Forbidden!")
+		customMsgBytes := customMsgBuf.Bytes()
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType},
+			Body:   customMsgBytes,
+		}
+
+		return response
+	} else if errPartition != nil {
+		msg := errPartition.Error()
+		customMsg := fmt.Sprintf("Failed to access data: %s", msg)
+		customMsgBuf := bytes.NewBufferString(customMsg)
+		customMsgBytes := customMsgBuf.Bytes()
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType},
+			Body:   customMsgBytes,
+		}
+
+		return response
+	} else {
+		partition.activitiesLock.RLock()
+		activities := partition.activities
+		contentProvider := activities.SerializeJSON()
+		partition.activitiesLock.RUnlock()
+		creationTime := time.Now()
+		timeStamp := creationTime.Format(ARCHIVE_TIME_STAMP)
+		fileName := fmt.Sprintf("activities-%s.json", timeStamp)
+		disposition := fmt.Sprintf("attachment; filename=\"%s\"", fileName)
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+
+			Header: map[string]string{
+				"Content-disposition": disposition,
+				"Content-type":        "application/json; charset=utf-8",
+			},
+
+			ContentReadCloser: contentProvider,
+		}
+
+		return response
+	}
+
+}
+
+/*
+ * Export activity data as TCX.
+ *
+ * If tracks is "true", the locations recorded during each activity are
+ * included as track points, which additionally requires permission to read
+ * and download the geographical database.
+ */
+func (this *controllerStruct) exportActivitiesTcxHandler(request webserver.HttpRequest) webserver.HttpResponse {
+	token := request.Params["token"]
+	tracks := request.Params["tracks"] == "true"
+	precision := request.Params["precision"]
+	redact := request.Params["redact"]
+	permA, errA := this.checkPermission(token, "activity-read")
+	permB, errB := true, error(nil)
+	permC, errC := true, error(nil)
+
+	/*
+	 * Exporting tracks requires access to location data.
+	 */
+	if tracks {
+		permB, errB = this.checkPermission(token, "geodb-read")
+		permC, errC = this.checkPermission(token, "geodb-download")
+	}
+
+	partition, errPartition := this.requestPartition(request)
+	transform, errTransform := this.createExportTransform(precision, redact)
+
+	/*
+	 * Check permissions and parameters.
+	 */
+	if (errA != nil) || (errB != nil) || (errC != nil) {
+		err := errA
+
+		/*
+		 * Report the first error that occured.
+		 */
+		if err == nil {
+			err = errB
+
+			/*
+			 * Report the second error if the first check succeeded.
+			 */
+			if err == nil {
+				err = errC
+			}
+
+		}
+
+		msg := err.Error()
+		customMsg := fmt.Sprintf("Failed to check permission: %s", msg)
+		customMsgBuf := bytes.NewBufferString(customMsg)
+		customMsgBytes := customMsgBuf.Bytes()
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType},
+			Body:   customMsgBytes,
+		}
+
+		return response
+	} else if !permA || !permB || !permC {
+		customMsgBuf := bytes.NewBufferString("Forbidden!")
+		customMsgBytes := customMsgBuf.Bytes()
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType},
+			Body:   customMsgBytes,
+		}
+
+		return response
+	} else if errPartition != nil {
+		msg := errPartition.Error()
+		customMsg := fmt.Sprintf("Failed to access data: %s", msg)
+		customMsgBuf := bytes.NewBufferString(customMsg)
+		customMsgBytes := customMsgBuf.Bytes()
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType},
+			Body:   customMsgBytes,
+		}
+
+		return response
+	} else if errTransform != nil {
+		msg := errTransform.Error()
+		customMsgBuf := bytes.NewBufferString(msg)
+		customMsgBytes := customMsgBuf.Bytes()
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType},
+			Body:   customMsgBytes,
+		}
+
+		return response
+	} else {
+		trackFunc := meta.TrackFunc(nil)
+
+		/*
+		 * Provide track points from the location database if requested.
+		 */
+		if tracks {
+			gu := geoutil.Create()
+			db := partition.locationDB
+
+			/*
+			 * Read the locations recorded during an activity.
+			 */
+			trackFunc = func(begin time.Time, end time.Time) ([]meta.TrackPoint, error) {
+				beginMs := gu.TimeToMilliseconds(begin)
+				endMs := gu.TimeToMilliseconds(end)
+				locations, err := gu.GeoDBRange(db, beginMs, endMs)
+				numLocations := len(locations)
+				points := make([]meta.TrackPoint, 0, numLocations)
+
+				/*
+				 * Convert locations into track points.
+				 */
+				for _, loc := range locations {
+					keep := true
+
+					/*
+					 * Apply export transform.
+					 */
+					if transform != nil {
+						keep = transform(&loc)
+					}
+
+					/*
+					 * Only add locations which were not omitted.
+					 */
+					if keep {
+						t := gu.MillisecondsToTime(loc.Timestamp)
+
+						/*
+						 * Create track point.
+						 */
+						point := meta.TrackPoint{
+							Time:        t,
+							LatitudeE7:  loc.LatitudeE7,
+							LongitudeE7: loc.LongitudeE7,
+						}
+
+						points = append(points, point)
+					}
+
+				}
+
+				return points, err
+			}
+
+		}
+
+		/*
+		 * Reading tracks may take a while, so do not block changes to
+		 * activity data while exporting. The activities synchronize
+		 * access on their own.
+		 */
+		partition.activitiesLock.RLock()
+		activities := partition.activities
+		partition.activitiesLock.RUnlock()
+		rs, err := activities.ExportTCX(trackFunc)
+
+		/*
+		 * Check if error occured during export.
+		 */
+		if err != nil {
+			msg := err.Error()
+			conf := this.getConfig()
+			confServer := conf.WebServer
+			contentType := confServer.ErrorMime
+
+			/*
+			 * Create HTTP response.
+			 */
+			response := webserver.HttpResponse{
+				Header: map[string]string{"Content-type": contentType},
+				Body:   []byte(msg),
+			}
+
+			return response
+		} else {
+
+			/*
+			 * Provide dummy close method.
+			 */
+			rsc := &readSeekerWithNopCloserStruct{
+				rs,
+			}
+
+			creationTime := time.Now()
+			timeStamp := creationTime.Format(ARCHIVE_TIME_STAMP)
+			fileName := fmt.Sprintf("activities-%s.tcx", timeStamp)
+			disposition := fmt.Sprintf("attachment; filename=\"%s\"", fileName)
+
+			/*
+			 * Create HTTP response.
+			 */
+			response := webserver.HttpResponse{
+
+				Header: map[string]string{
+					"Content-disposition": disposition,
+					"Content-type":        "application/vnd.garmin.tcx+xml",
+				},
+
+				ContentReadSeekCloser: rsc,
+			}
+
+			return response
+		}
+
+	}
+
+}
+
+/*
+ * Retrieve all activity information from database.
+ */
+func (this *controllerStruct) getActivitiesHandler(request webserver.HttpRequest) webserver.HttpResponse {
+	token := request.Params["token"]
+	perm, err := this.checkPermission(token, "activity-read")
+	partition, errPartition := this.requestPartition(request)
+	fromIn := request.Params["from"]
+	toIn := request.Params["to"]
+	filtered := (fromIn != "") || (toIn != "")
+	from, errFrom := filter.ParseTime(fromIn, false, false)
+	to, errTo := filter.ParseTime(toIn, false, false)
+
+	/*
+	 * Check permissions.
+	 */
+	if err != nil {
+		msg := err.Error()
+		customMsg := fmt.Sprintf("Failed to check permission: %s\n", msg)
+		customMsgBuf := bytes.NewBufferString(customMsg)
+		customMsgBytes := customMsgBuf.Bytes()
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType},
+			Body:   customMsgBytes,
+		}
+
+		return response
+	} else if !perm {
+		customMsgBuf := bytes.NewBufferString("Forbidden!")
+		customMsgBytes := customMsgBuf.Bytes()
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType},
+			Body:   customMsgBytes,
+		}
+
+		return response
+	} else if errPartition != nil {
+		msg := errPartition.Error()
+		customMsg := fmt.Sprintf("Failed to access data: %s", msg)
+		customMsgBuf := bytes.NewBufferString(customMsg)
+		customMsgBytes := customMsgBuf.Bytes()
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType},
+			Body:   customMsgBytes,
+		}
+
+		return response
+	} else if filtered && (errFrom != nil) {
+		customMsgBuf := bytes.NewBufferString("Failed to get activities: Could not parse the beginning of the time range.")
+		customMsgBytes := customMsgBuf.Bytes()
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType},
+			Body:   customMsgBytes,
+		}
+
+		return response
+	} else if filtered && (errTo != nil) {
+		customMsgBuf := bytes.NewBufferString("Failed to get activities: Could not parse the end of the time range.")
+		customMsgBytes := customMsgBuf.Bytes()
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType},
+			Body:   customMsgBytes,
+		}
+
+		return response
+	} else {
+		partition.activitiesLock.RLock()
+		activities := partition.activities
+		revision := activities.Revision()
+		first := uint32(0)
+		end := activities.Length()
+
+		/*
+		 * Only report activity groups beginning within the time range, if
+		 * one was requested.
+		 */
+		if filtered {
+			first, end = activities.Range(from, to)
+		}
+
+		webActivityGroups := make([]webActivityGroupStruct, 0)
+		trackFormat := CGI_PATH + "?cgi=get-activity-track&id=%d&revision=%d"
+		timeFormat := time.RFC3339
+
+		/*
+		 * Iterate over all activities within the time range.
+		 */
+		for id := first; id < end; id++ {
+			activityGroup, err := activities.Get(id)
+
+			/*
+			 * Check if activity group was found.
+			 */
+			if err == nil {
+				runningActivity := activityGroup.Running()
+				runningZero := runningActivity.Zero()
+				runningDuration := runningActivity.Duration()
+				runningDurationString := runningDuration.String()
+				runningDistanceKMString := runningActivity.DistanceKM()
+				runningStepCount := runningActivity.StepCount()
+				runningEnergyKJ := runningActivity.EnergyKJ()
+
+				/*
+				 * Create data structure representing running activity.
+				 */
+				webRunningActivity := webRunningActivityStruct{
+					Zero:       runningZero,
+					Duration:   runningDurationString,
+					DistanceKM: runningDistanceKMString,
+					StepCount:  runningStepCount,
+					EnergyKJ:   runningEnergyKJ,
+				}
+
+				cyclingActivity := activityGroup.Cycling()
+				cyclingZero := cyclingActivity.Zero()
+				cyclingDuration := cyclingActivity.Duration()
+				cyclingDurationString := cyclingDuration.String()
+				cyclingDistanceKMString := cyclingActivity.DistanceKM()
+				cyclingEnergyKJ := cyclingActivity.EnergyKJ()
+
+				/*
+				 * Create data structure representing cycling activity.
+				 */
+				webCyclingActivity := webCyclingActivityStruct{
+					Zero:       cyclingZero,
+					Duration:   cyclingDurationString,
+					DistanceKM: cyclingDistanceKMString,
+					EnergyKJ:   cyclingEnergyKJ,
+				}
+
+				otherActivity := activityGroup.Other()
+				otherZero := otherActivity.Zero()
+				otherEnergyKJ := otherActivity.EnergyKJ()
+
+				/*
+				 * Create data structure representing other activities.
+				 */
+				webOtherActivity := webOtherActivityStruct{
+					Zero:     otherZero,
+					EnergyKJ: otherEnergyKJ,
+				}
+
+				begin := activityGroup.Begin()
+				beginString := begin.Format(timeFormat)
+				end, _ := activities.End(id)
+				endString := end.Format(timeFormat)
+				track := fmt.Sprintf(trackFormat, id, revision)
+				weightKGString := activityGroup.WeightKG()
+				customActivities := activityGroup.Custom()
+				webCustomActivities := this.createWebCustomActivities(customActivities)
+
+				/*
+				 * Create data structure representing activity group.
+				 */
+				webActivityGroup := webActivityGroupStruct{
+					Begin:    beginString,
+					End:      endString,
+					Track:    track,
+					WeightKG: weightKGString,
+					Running:  webRunningActivity,
+					Cycling:  webCyclingActivity,
+					Other:    webOtherActivity,
+					Custom:   webCustomActivities,
+				}
+
+				webActivityGroups = append(webActivityGroups, webActivityGroup)
+			}
+
+		}
+
+		activityStatistics := activities.StatisticsRange(first, end)
+		webActivityStatistics := this.createWebActivityStatistics(activityStatistics)
+		partition.activitiesLock.RUnlock()
+		conf := this.getConfig()
+		activityTypes := conf.ActivityTypes
+
+		/*
+		 * Always provide a list of activity types.
+		 */
+		if activityTypes == nil {
+			activityTypes = []string{}
+		}
+
+		/*
+		 * Create data structure representing all activity information.
+		 */
+		webActivities := webActivitiesStruct{
+			Revision:   revision,
+			Types:      activityTypes,
+			Activities: webActivityGroups,
+			Statistics: webActivityStatistics,
+		}
+
+		mimeType, buffer := this.createJSON(webActivities)
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": mimeType},
+			Body:   buffer,
+		}
+
+		return response
+	}
+
+}
+
+/*
+ * Report activity statistics for each calendar week, month or year.
+ */
+func (this *controllerStruct) getActivityStatisticsHandler(request webserver.HttpRequest) webserver.HttpResponse {
+	token := request.Params["token"]
+	perm, err := this.checkPermission(token, "activity-read")
+	partition, errPartition := this.requestPartition(request)
+
+	/*
+	 * Check permissions.
+	 */
+	if err != nil {
+		msg := err.Error()
+		customMsg := fmt.Sprintf("Failed to check permission: %s\n", msg)
+		customMsgBuf := bytes.NewBufferString(customMsg)
+		customMsgBytes := customMsgBuf.Bytes()
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType},
+			Body:   customMsgBytes,
+		}
+
+		return response
+	} else if !perm {
+		customMsgBuf := bytes.NewBufferString("Forbidden!")
+		customMsgBytes := customMsgBuf.Bytes()
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType},
+			Body:   customMsgBytes,
+		}
+
+		return response
+	} else if errPartition != nil {
+		msg := errPartition.Error()
+		customMsg := fmt.Sprintf("Failed to access data: %s", msg)
+		customMsgBuf := bytes.NewBufferString(customMsg)
+		customMsgBytes := customMsgBuf.Bytes()
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType},
+			Body:   customMsgBytes,
+		}
+
+		return response
+	} else {
+		period := request.Params["period"]
+		partition.activitiesLock.RLock()
+		activities := partition.activities
+		periodStats, err := activities.StatisticsByPeriod(period)
+		partition.activitiesLock.RUnlock()
+		result := webActivityPeriodStatisticsStruct{}
+
+		/*
+		 * Check if statistics could be created.
+		 */
+		if err != nil {
+			msg := err.Error()
+			reason := fmt.Sprintf("Failed to create activity statistics: %s", msg)
+
+			/*
+			 * Indicate failure.
+			 */
+			result.webResponseStruct = webResponseStruct{
+				Success: false,
+				Reason:  reason,
+			}
+
+			result.Period = period
+			result.Periods = []webPeriodStatisticsStruct{}
+		} else {
+			numPeriods := len(periodStats)
+			webPeriods := make([]webPeriodStatisticsStruct, numPeriods)
+			timeFormat := time.RFC3339
+
+			/*
+			 * Create web representation of each period.
+			 */
+			for i, stats := range periodStats {
+				begin := stats.Begin()
+				beginString := begin.Format(timeFormat)
+				end := stats.End()
+				endString := end.Format(timeFormat)
+				groupCount := stats.GroupCount()
+				webStats := this.createWebActivityStatistics(stats)
+
+				/*
+				 * Create data structure representing statistics for
+				 * period.
+				 */
+				webPeriods[i] = webPeriodStatisticsStruct{
+					Begin:      beginString,
+					End:        endString,
+					GroupCount: groupCount,
+					Statistics: webStats,
+				}
+
+			}
+
+			/*
+			 * Indicate success.
+			 */
+			result.webResponseStruct = webResponseStruct{
+				Success: true,
+				Reason:  "",
+			}
+
+			result.Period = period
+			result.Periods = webPeriods
+		}
+
+		mimeType, buffer := this.createJSON(result)
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": mimeType},
+			Body:   buffer,
+		}
+
+		return response
+	}
+
+}
+
+/*
+ * Export the track recorded during an activity.
+ *
+ * The track consists of all locations with a time stamp between the beginning
+ * and the end of the activity group.
+ */
+func (this *controllerStruct) getActivityTrackHandler(request webserver.HttpRequest) webserver.HttpResponse {
+	token := request.Params["token"]
+	idIn := request.Params["id"]
+	revisionIn := request.Params["revision"]
+	format := request.Params["format"]
+	tz := request.Params["tz"]
+	precision := request.Params["precision"]
+	redact := request.Params["redact"]
+	permA, errA := this.checkPermission(token, "activity-read")
+	permB, errB := this.checkPermission(token, "geodb-read")
+	permC, errC := this.checkPermission(token, "geodb-download")
+	partition, errPartition := this.requestPartition(request)
+	id64, errId := strconv.ParseUint(idIn, 10, 32)
+	revision := uint64(0)
+	errRevision := error(nil)
+
+	/*
+	 * Parse revision if it was provided.
+	 */
+	if revisionIn != "" {
+		revision, errRevision = strconv.ParseUint(revisionIn, 10, 64)
+	}
+
+	location, errTz := time.LoadLocation(tz)
+	transform, errTransform := this.createExportTransform(precision, redact)
+
+	/*
+	 * Default to compact GPX.
+	 */
+	if format == "" {
+		format = "gpx"
+	}
+
+	/*
+	 * Check permissions and parameters.
+	 */
+	if (errA != nil) || (errB != nil) || (errC != nil) {
+		err := errA
+
+		/*
+		 * Report the first error that occured.
+		 */
+		if err == nil {
+			err = errB
+
+			/*
+			 * Report the second error if the first check succeeded.
+			 */
+			if err == nil {
+				err = errC
+			}
+
+		}
+
+		msg := err.Error()
+		customMsg := fmt.Sprintf("Failed to check permission: %s", msg)
+		customMsgBuf := bytes.NewBufferString(customMsg)
+		customMsgBytes := customMsgBuf.Bytes()
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType},
+			Body:   customMsgBytes,
+		}
+
+		return response
+	} else if !permA || !permB || !permC {
+		customMsg := "Forbidden!"
+		customMsgBuf := bytes.NewBufferString(customMsg)
+		customMsgBytes := customMsgBuf.Bytes()
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType},
+			Body:   customMsgBytes,
+		}
+
+		return response
+	} else if errPartition != nil {
+		msg := errPartition.Error()
+		customMsg := fmt.Sprintf("Failed to access data: %s", msg)
+		customMsgBuf := bytes.NewBufferString(customMsg)
+		customMsgBytes := customMsgBuf.Bytes()
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType},
+			Body:   customMsgBytes,
+		}
+
+		return response
+	} else if errId != nil {
+		customMsg := fmt.Sprintf("Activity ID must be a non-negative integer, but was '%s'.", idIn)
+		customMsgBuf := bytes.NewBufferString(customMsg)
+		customMsgBytes := customMsgBuf.Bytes()
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType},
+			Body:   customMsgBytes,
+		}
+
+		return response
+	} else if errRevision != nil {
+		customMsg := fmt.Sprintf("Revision must be a non-negative integer, but was '%s'.", revisionIn)
+		customMsgBuf := bytes.NewBufferString(customMsg)
+		customMsgBytes := customMsgBuf.Bytes()
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType},
+			Body:   customMsgBytes,
+		}
+
+		return response
+	} else if errTz != nil {
+		customMsg := fmt.Sprintf("Unknown time zone: '%s'", tz)
+		customMsgBuf := bytes.NewBufferString(customMsg)
+		customMsgBytes := customMsgBuf.Bytes()
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType},
+			Body:   customMsgBytes,
+		}
+
+		return response
+	} else if errTransform != nil {
+		customMsg := errTransform.Error()
+		customMsgBuf := bytes.NewBufferString(customMsg)
+		customMsgBytes := customMsgBuf.Bytes()
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType},
+			Body:   customMsgBytes,
+		}
+
+		return response
+	} else if (format != "geojson") && (format != "geojson-pretty") && (format != "gpx") && (format != "gpx-pretty") {
+		customMsg := fmt.Sprintf("Unknown format: '%s'", format)
+		customMsgBuf := bytes.NewBufferString(customMsg)
+		customMsgBytes := customMsgBuf.Bytes()
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType},
+			Body:   customMsgBytes,
+		}
+
+		return response
+	} else {
+		id := uint32(id64)
+		partition.activitiesLock.RLock()
+		activities := partition.activities
+		currentRevision := activities.Revision()
+		activityGroup, errGroup := activities.Get(id)
+		end, _ := activities.End(id)
+		partition.activitiesLock.RUnlock()
+		db := partition.locationDB
+
+		/*
+		 * Make sure that the activity exists and revision information matches.
+		 */
+		if (revisionIn != "") && (revision != currentRevision) {
+			customMsg := "Activity data was changed in the meantime."
+			customMsgBuf := bytes.NewBufferString(customMsg)
+			customMsgBytes := customMsgBuf.Bytes()
+			conf := this.getConfig()
+			confServer := conf.WebServer
+			contentType := confServer.ErrorMime
+
+			/*
+			 * Create HTTP response.
+			 */
+			response := webserver.HttpResponse{
+				Header: map[string]string{"Content-type": contentType},
+				Body:   customMsgBytes,
+			}
+
+			return response
+		} else if errGroup != nil {
+			msg := errGroup.Error()
+			customMsg := fmt.Sprintf("Failed to find activity: %s", msg)
+			customMsgBuf := bytes.NewBufferString(customMsg)
+			customMsgBytes := customMsgBuf.Bytes()
+			conf := this.getConfig()
+			confServer := conf.WebServer
+			contentType := confServer.ErrorMime
+
+			/*
+			 * Create HTTP response.
+			 */
+			response := webserver.HttpResponse{
+				Header: map[string]string{"Content-type": contentType},
+				Body:   customMsgBytes,
+			}
+
+			return response
+		} else if db == nil {
+			customMsg := "Database not accessible."
+			customMsgBuf := bytes.NewBufferString(customMsg)
+			customMsgBytes := customMsgBuf.Bytes()
+			conf := this.getConfig()
+			confServer := conf.WebServer
+			contentType := confServer.ErrorMime
+
+			/*
+			 * Create HTTP response.
+			 */
+			response := webserver.HttpResponse{
+				Header: map[string]string{"Content-type": contentType},
+				Body:   customMsgBytes,
+			}
+
+			return response
+		} else {
+			gu := geoutil.Create()
+			begin := activityGroup.Begin()
+			beginMs := gu.TimeToMilliseconds(begin)
+			endMs := gu.TimeToMilliseconds(end)
+			count, err := gu.GeoDBCount(db, beginMs, endMs)
+
+			/*
+			 * Check if any locations were recorded during the activity.
+			 */
+			if err != nil {
+				msg := err.Error()
+				customMsg := fmt.Sprintf("Failed to find track: %s", msg)
+				customMsgBuf := bytes.NewBufferString(customMsg)
+				customMsgBytes := customMsgBuf.Bytes()
+				conf := this.getConfig()
+				confServer := conf.WebServer
+				contentType := confServer.ErrorMime
+
+				/*
+				 * Create HTTP response.
+				 */
+				response := webserver.HttpResponse{
+					Header: map[string]string{"Content-type": contentType},
+					Body:   customMsgBytes,
+				}
+
+				return response
+			} else if count == 0 {
+				customMsg := fmt.Sprintf("No locations were recorded during activity %d.", id)
+				customMsgBuf := bytes.NewBufferString(customMsg)
+				customMsgBytes := customMsgBuf.Bytes()
+				conf := this.getConfig()
+				confServer := conf.WebServer
+				contentType := confServer.ErrorMime
+
+				/*
+				 * Create HTTP response.
+				 */
+				response := webserver.HttpResponse{
+					Header: map[string]string{"Content-type": contentType},
+					Body:   customMsgBytes,
+				}
+
+				return response
+			} else {
+
+				/*
+				 * Only export locations recorded during the activity.
+				 */
+				transformTrack := func(loc *geodb.Location) bool {
+					timestamp := loc.Timestamp
+					keep := (timestamp >= beginMs) && (timestamp < endMs)
+
+					/*
+					 * Apply export transform to locations within the track.
+					 */
+					if keep && (transform != nil) {
+						keep = transform(loc)
+					}
+
+					return keep
+				}
+
+				contentProvider := io.ReadCloser(nil)
+				contentType := ""
+				extension := ""
+
+				/*
+				 * Decide on the output format.
+				 */
+				switch format {
+				case "geojson", "geojson-pretty":
+					pretty := format == "geojson-pretty"
+					contentProvider = db.SerializeGeoJSON(pretty, 0, 1, transformTrack)
+					contentType = "application/geo+json"
+					extension = "geojson"
+				default:
+					pretty := format == "gpx-pretty"
+					contentProvider = db.SerializeXML(pretty, location, 1, transformTrack)
+					contentType = "application/gpx+xml"
+					extension = "gpx"
+				}
+
+				timeStamp := begin.Format(ARCHIVE_TIME_STAMP)
+				fileName := fmt.Sprintf("activity-%s.%s", timeStamp, extension)
+				disposition := fmt.Sprintf("attachment; filename=\"%s\"", fileName)
+
+				/*
+				 * Create HTTP response.
+				 */
+				response := webserver.HttpResponse{
+
+					Header: map[string]string{
+						"Content-disposition": disposition,
+						"Content-type":        contentType,
+					},
+
+					ContentReadCloser: contentProvider,
+				}
+
+				return response
+			}
+
+		}
+
+	}
+
+}
+
+/*
+ * Obtain the bounding box of all locations in the GeoDB location database.
+ */
+func (this *controllerStruct) getGeoDBBoundsHandler(request webserver.HttpRequest) webserver.HttpResponse {
+	token := request.Params["token"]
+	perm, err := this.checkPermission(token, "geodb-read")
+	partition, errPartition := this.requestPartition(request)
+
+	/*
+	 * Check permissions.
+	 */
+	if err != nil {
+		msg := err.Error()
+		customMsg := fmt.Sprintf("Failed to check permission: %s", msg)
+		customMsgBuf := bytes.NewBufferString(customMsg)
+		customMsgBytes := customMsgBuf.Bytes()
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType},
+			Body:   customMsgBytes,
+		}
+
+		return response
+	} else if !perm {
+		customMsgBuf := bytes.NewBufferString("Forbidden!")
+		customMsgBytes := customMsgBuf.Bytes()
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType},
+			Body:   customMsgBytes,
+		}
+
+		return response
+	} else if errPartition != nil {
+		msg := errPartition.Error()
+		customMsg := fmt.Sprintf("Failed to access data: %s", msg)
+		customMsgBuf := bytes.NewBufferString(customMsg)
+		customMsgBytes := customMsgBuf.Bytes()
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType},
+			Body:   customMsgBytes,
+		}
+
+		return response
+	} else {
+		gu := geoutil.Create()
+		db := partition.locationDB
+		bounds, err := gu.GeoDBBounds(db)
+		result := webGeoDBBoundsStruct{}
+
+		/*
+		 * Check if bounding box could be determined.
+		 */
+		if err != nil {
+			msg := err.Error()
+			reason := fmt.Sprintf("Failed to determine bounding box: %s", msg)
+
+			/*
+			 * Indicate failure.
+			 */
+			result.webResponseStruct = webResponseStruct{
+				Success: false,
+				Reason:  reason,
+			}
+
+		} else {
+
+			/*
+			 * Indicate success.
+			 */
+			result.webResponseStruct = webResponseStruct{
+				Success: true,
+				Reason:  "",
+			}
+
+			empty := bounds.Empty()
+
+			/*
+			 * Bounding box is only defined for non-empty databases.
+			 */
+			if !empty {
+				latitudeMax := bounds.LatitudeMax()
+				latitudeMin := bounds.LatitudeMin()
+				longitudeMax := bounds.LongitudeMax()
+				longitudeMin := bounds.LongitudeMin()
+
+				/*
+				 * Create bounding box in degrees.
+				 */
+				webBounds := webBoundsStruct{
+					LatitudeMax:  float64(latitudeMax) / 1e7,
+					LatitudeMin:  float64(latitudeMin) / 1e7,
+					LongitudeMax: float64(longitudeMax) / 1e7,
+					LongitudeMin: float64(longitudeMin) / 1e7,
+				}
+
+				result.Bounds = &webBounds
+			}
+
+		}
+
+		mimeType, buffer := this.createJSON(result)
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": mimeType},
+			Body:   buffer,
+		}
+
+		return response
+	}
+
+}
+
+/*
+ * Obtain the distance travelled according to the GeoDB location database.
+ *
+ * Segments between consecutive locations implying a speed above the configured
+ * maximum are skipped.
+ */
+func (this *controllerStruct) getGeoDBDistanceHandler(request webserver.HttpRequest) webserver.HttpResponse {
+	token := request.Params["token"]
+	perm, err := this.checkPermission(token, "geodb-read")
+	partition, errPartition := this.requestPartition(request)
+
+	/*
+	 * Check permissions.
+	 */
+	if err != nil {
+		msg := err.Error()
+		customMsg := fmt.Sprintf("Failed to check permission: %s", msg)
+		customMsgBuf := bytes.NewBufferString(customMsg)
+		customMsgBytes := customMsgBuf.Bytes()
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType},
+			Body:   customMsgBytes,
+		}
+
+		return response
+	} else if !perm {
+		customMsgBuf := bytes.NewBufferString("Forbidden!")
+		customMsgBytes := customMsgBuf.Bytes()
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType},
+			Body:   customMsgBytes,
+		}
+
+		return response
+	} else if errPartition != nil {
+		msg := errPartition.Error()
+		customMsg := fmt.Sprintf("Failed to access data: %s", msg)
+		customMsgBuf := bytes.NewBufferString(customMsg)
+		customMsgBytes := customMsgBuf.Bytes()
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType},
+			Body:   customMsgBytes,
+		}
+
+		return response
+	} else {
+		conf := this.getConfig()
+		maxSpeed := conf.MaxSpeed
+		gu := geoutil.Create()
+		db := partition.locationDB
+		distanceKM, err := gu.GeoDBDistanceKM(db, maxSpeed)
+		result := webGeoDBDistanceStruct{}
+
+		/*
+		 * Check if distance could be determined.
+		 */
+		if err != nil {
+			msg := err.Error()
+			reason := fmt.Sprintf("Failed to determine distance: %s", msg)
+
+			/*
+			 * Indicate failure.
+			 */
+			result.webResponseStruct = webResponseStruct{
+				Success: false,
+				Reason:  reason,
+			}
+
+		} else {
+
+			/*
+			 * Indicate success.
+			 */
+			result.webResponseStruct = webResponseStruct{
+				Success: true,
+				Reason:  "",
+			}
+
+			result.DistanceKM = distanceKM
+			result.MaxSpeed = maxSpeed
+		}
+
+		mimeType, buffer := this.createJSON(result)
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": mimeType},
+			Body:   buffer,
+		}
+
+		return response
+	}
+
+}
+
+/*
+ * Obtain a histogram of the number of locations in the GeoDB location
+ * database per day, week, month or year.
+ */
+func (this *controllerStruct) getGeoDBHistogramHandler(request webserver.HttpRequest) webserver.HttpResponse {
+	token := request.Params["token"]
+	perm, err := this.checkPermission(token, "geodb-read")
+	partition, errPartition := this.requestPartition(request)
+	bucketString := request.Params["bucket"]
+	bucket := geoutil.BUCKET_DAY
+	bucketValid := true
+
+	/*
+	 * Decide on bucket size.
+	 */
+	switch bucketString {
+	case "", "day":
+		bucketString = "day"
+		bucket = geoutil.BUCKET_DAY
+	case "week":
+		bucket = geoutil.BUCKET_WEEK
+	case "month":
+		bucket = geoutil.BUCKET_MONTH
+	case "year":
+		bucket = geoutil.BUCKET_YEAR
+	default:
+		bucketValid = false
+	}
+
+	tz := request.Params["tz"]
+	location, errLocation := time.LoadLocation(tz)
+	offsetIn := request.Params["offset"]
+	offset64 := uint64(0)
+	errOffset := error(nil)
+
+	/*
+	 * Parse offset if it was provided.
+	 */
+	if offsetIn != "" {
+		offset64, errOffset = strconv.ParseUint(offsetIn, 10, 32)
+	}
+
+	limitIn := request.Params["limit"]
+	limit64 := uint64(0)
+	errLimit := error(nil)
+
+	/*
+	 * Parse limit if it was provided.
+	 */
+	if limitIn != "" {
+		limit64, errLimit = strconv.ParseUint(limitIn, 10, 32)
+	}
+
+	/*
+	 * Check permissions and parameters.
+	 */
+	if err != nil {
+		msg := err.Error()
+		customMsg := fmt.Sprintf("Failed to check permission: %s", msg)
+		customMsgBuf := bytes.NewBufferString(customMsg)
+		customMsgBytes := customMsgBuf.Bytes()
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType},
+			Body:   customMsgBytes,
+		}
+
+		return response
+	} else if !perm {
+		customMsgBuf := bytes.NewBufferString("Forbidden!")
+		customMsgBytes := customMsgBuf.Bytes()
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType},
+			Body:   customMsgBytes,
+		}
+
+		return response
+	} else if errPartition != nil {
+		msg := errPartition.Error()
+		customMsg := fmt.Sprintf("Failed to access data: %s", msg)
+		customMsgBuf := bytes.NewBufferString(customMsg)
+		customMsgBytes := customMsgBuf.Bytes()
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType},
+			Body:   customMsgBytes,
+		}
+
+		return response
+	} else if !bucketValid {
+		customMsg := fmt.Sprintf("Bucket must be one of 'day', 'week', 'month' or 'year', but was '%s'.", bucketString)
+		customMsgBuf := bytes.NewBufferString(customMsg)
+		customMsgBytes := customMsgBuf.Bytes()
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType},
+			Body:   customMsgBytes,
+		}
+
+		return response
+	} else if errLocation != nil {
+		customMsg := fmt.Sprintf("Unknown time zone: '%s'", tz)
+		customMsgBuf := bytes.NewBufferString(customMsg)
+		customMsgBytes := customMsgBuf.Bytes()
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType},
+			Body:   customMsgBytes,
+		}
+
+		return response
+	} else if errOffset != nil {
+		customMsg := fmt.Sprintf("Offset must be a non-negative integer, but was '%s'.", offsetIn)
+		customMsgBuf := bytes.NewBufferString(customMsg)
+		customMsgBytes := customMsgBuf.Bytes()
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType},
+			Body:   customMsgBytes,
+		}
+
+		return response
+	} else if errLimit != nil {
+		customMsg := fmt.Sprintf("Limit must be a non-negative integer, but was '%s'.", limitIn)
+		customMsgBuf := bytes.NewBufferString(customMsg)
+		customMsgBytes := customMsgBuf.Bytes()
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType},
+			Body:   customMsgBytes,
+		}
+
+		return response
+	} else {
+		conf := this.getConfig()
+		limits := conf.Limits
+		maxBucketsConf := uint64(limits.MaxHistogramBuckets)
+
+		/*
+		 * Limit the number of buckets to the configured maximum. A limit
+		 * of zero requests as many buckets as allowed.
+		 */
+		if (maxBucketsConf > 0) && ((limit64 == 0) || (limit64 > maxBucketsConf)) {
+			limit64 = maxBucketsConf
+		}
+
+		maxBuckets64 := uint64(0)
+
+		/*
+		 * Create one more bucket than requested to find out whether
+		 * there are more.
+		 */
+		if limit64 > 0 {
+			maxBuckets64 = offset64 + limit64 + 1
+
+			/*
+			 * Prevent overflow.
+			 */
+			if maxBuckets64 > math.MaxUint32 {
+				maxBuckets64 = math.MaxUint32
+			}
+
+		}
+
+		maxBuckets := uint32(maxBuckets64)
+		gu := geoutil.Create()
+		db := partition.locationDB
+		histogram, err := gu.GeoDBHistogram(db, bucket, location, maxBuckets)
+		result := webGeoDBHistogramStruct{}
+
+		/*
+		 * Check if histogram could be created.
+		 */
+		if err != nil {
+			msg := err.Error()
+			reason := fmt.Sprintf("Failed to create histogram: %s", msg)
+
+			/*
+			 * Indicate failure.
+			 */
+			result.webResponseStruct = webResponseStruct{
+				Success: false,
+				Reason:  reason,
+			}
+
+		} else {
+			buckets := histogram.Buckets()
+			numBuckets := len(buckets)
+			numBuckets64 := uint64(numBuckets)
+			begin64 := offset64
+			end64 := numBuckets64
+
+			/*
+			 * The page cannot start after the last bucket.
+			 */
+			if begin64 > numBuckets64 {
+				begin64 = numBuckets64
+			}
+
+			/*
+			 * Only return as many buckets as requested.
+			 */
+			if (limit64 > 0) && ((begin64 + limit64) < end64) {
+				end64 = begin64 + limit64
+			}
+
+			complete := histogram.Complete()
+			truncated := (end64 < numBuckets64) || !complete
+			page := buckets[begin64:end64]
+			numPage := len(page)
+			webBuckets := make([]webHistogramBucketStruct, numPage)
+
+			/*
+			 * Convert buckets into web representation.
+			 */
+			for i, b := range page {
+				start := b.Start
+				startString := start.Format(TIMESTAMP_FORMAT)
+
+				/*
+				 * Create web representation of bucket.
+				 */
+				webBuckets[i] = webHistogramBucketStruct{
+					Start: startString,
+					Count: b.Count,
+				}
+
+			}
+
+			fullScan := histogram.FullScan()
+
+			/*
+			 * Indicate success.
+			 */
+			result.webResponseStruct = webResponseStruct{
+				Success: true,
+				Reason:  "",
+			}
+
+			result.Bucket = bucketString
+			result.FullScan = fullScan
+			result.Total = uint32(numBuckets)
+			result.Truncated = truncated
+			result.Buckets = webBuckets
+		}
+
+		mimeType, buffer := this.createJSON(result)
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": mimeType},
+			Body:   buffer,
+		}
+
+		return response
+	}
+
+}
+
+/*
+ * Obtain statistics from the GeoDB location database.
+ */
+func (this *controllerStruct) getGeoDBStatsHandler(request webserver.HttpRequest) webserver.HttpResponse {
+	token := request.Params["token"]
+	perm, err := this.checkPermission(token, "geodb-read")
+	partition, errPartition := this.requestPartition(request)
+
+	/*
+	 * Check permissions.
+	 */
+	if err != nil {
+		msg := err.Error()
+		customMsg := fmt.Sprintf("Failed to check permission: %s\n", msg)
+		customMsgBuf := bytes.NewBufferString(customMsg)
+		customMsgBytes := customMsgBuf.Bytes()
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType},
+			Body:   customMsgBytes,
+		}
+
+		return response
+	} else if !perm {
+		customMsgBuf := bytes.NewBufferString("Forbidden!")
+		customMsgBytes := customMsgBuf.Bytes()
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType},
+			Body:   customMsgBytes,
+		}
+
+		return response
+	} else if errPartition != nil {
+		msg := errPartition.Error()
+		customMsg := fmt.Sprintf("Failed to access data: %s", msg)
+		customMsgBuf := bytes.NewBufferString(customMsg)
+		customMsgBytes := customMsgBuf.Bytes()
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType},
+			Body:   customMsgBytes,
+		}
+
+		return response
+	} else {
+		datasetStats := webDatasetStatsStruct{}
+		gu := geoutil.Create()
+		db := partition.locationDB
+		stats, err := gu.GeoDBStats(db)
+
+		/*
+		 * Make sure that no error occured.
+		 */
+		if err == nil {
+			locationCount := stats.LocationCount()
+			ordered := stats.Ordered()
+			orderedStrict := stats.OrderedStrict()
+			timestampEarliest := stats.TimestampEarliest()
+			timestampLatest := stats.TimestampLatest()
+			timestampEarliestString := ""
+			timestampLatestString := ""
+
+			/*
+			 * Check if timestamps are defined.
+			 */
+			if timestampEarliest <= timestampLatest {
+				timestampEarliestTime := gu.MillisecondsToTime(timestampEarliest)
+				timestampEarliestString = timestampEarliestTime.Format(TIMESTAMP_FORMAT)
+				timestampLatestTime := gu.MillisecondsToTime(timestampLatest)
+				timestampLatestString = timestampLatestTime.Format(TIMESTAMP_FORMAT)
+			}
+
+			/*
+			 * Create dataset statistics.
+			 */
+			datasetStats = webDatasetStatsStruct{
+				LocationCount:     locationCount,
+				Ordered:           ordered,
+				OrderedStrict:     orderedStrict,
+				TimestampEarliest: timestampEarliestString,
+				TimestampLatest:   timestampLatestString,
+			}
+
+		}
+
+		mimeType, buffer := this.createJSON(datasetStats)
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": mimeType},
+			Body:   buffer,
+		}
+
+		return response
+	}
+
+}
+
+/*
+ * Obtain the default values for render parameters.
+ */
+func (this *controllerStruct) getRenderDefaultsHandler(request webserver.HttpRequest) webserver.HttpResponse {
+	token := request.Params["token"]
+	perm, err := this.checkPermission(token, "render")
+
+	/*
+	 * Check permissions.
+	 */
+	if err != nil {
+		msg := err.Error()
+		customMsg := fmt.Sprintf("Failed to check permission: %s", msg)
+		customMsgBuf := bytes.NewBufferString(customMsg)
+		customMsgBytes := customMsgBuf.Bytes()
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType},
+			Body:   customMsgBytes,
+		}
+
+		return response
+	} else if !perm {
+		customMsgBuf := bytes.NewBufferString("Forbidden!")
+		customMsgBytes := customMsgBuf.Bytes()
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType},
+			Body:   customMsgBytes,
+		}
+
+		return response
+	} else {
+		conf := this.getConfig()
+		defaults := conf.RenderDefaults
+
+		/*
+		 * Indicate success.
+		 */
+		status := webResponseStruct{
+			Success: true,
+			Reason:  "",
+		}
+
+		/*
+		 * Create default render parameters.
+		 */
+		webDefaults := webRenderDefaultsStruct{
+			webResponseStruct: status,
+			FgColor:           defaults.FgColor,
+			MaxGap:            defaults.MaxGap,
+			Mode:              defaults.Mode,
+			Spread:            defaults.Spread,
+			XRes:              defaults.XRes,
+			YRes:              defaults.YRes,
+			Zoom:              defaults.Zoom,
+		}
+
+		mimeType, buffer := this.createJSON(webDefaults)
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": mimeType},
+			Body:   buffer,
+		}
+
+		return response
+	}
+
+}
+
+/*
+ * Retrieve a rendered image previously saved to the image database.
+ */
+func (this *controllerStruct) getSavedImageHandler(request webserver.HttpRequest) webserver.HttpResponse {
+	token := request.Params["token"]
+	perm, err := this.checkPermission(token, "render")
+
+	/*
+	 * Check permissions.
+	 */
+	if err != nil {
+		msg := err.Error()
+		customMsg := fmt.Sprintf("Failed to check permission: %s", msg)
+		customMsgBuf := bytes.NewBufferString(customMsg)
+		customMsgBytes := customMsgBuf.Bytes()
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType},
+			Body:   customMsgBytes,
+		}
+
+		return response
+	} else if !perm {
+		customMsgBuf := bytes.NewBufferString("Forbidden!")
+		customMsgBytes := customMsgBuf.Bytes()
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType},
+			Body:   customMsgBytes,
+		}
+
+		return response
+	} else {
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+		imgdb := this.imageDatabase
+		handleIn := request.Params["handle"]
+		handleBytes, errHandle := hex.DecodeString(handleIn)
+		numHandleBytes := len(handleBytes)
+
+		/*
+		 * Check if we have an image database and a valid handle.
+		 */
+		if imgdb == nil {
+			customMsgBuf := bytes.NewBufferString("Server has no image database.")
+			customMsgBytes := customMsgBuf.Bytes()
+
+			/*
+			 * Create HTTP response.
+			 */
+			response := webserver.HttpResponse{
+				Header: map[string]string{"Content-type": contentType},
+				Body:   customMsgBytes,
+			}
+
+			return response
+		} else if (errHandle != nil) || (numHandleBytes != tiledb.SIZE_HASH) {
+			customMsg := fmt.Sprintf("Handle must consist of %d hexadecimal digits.", 2*tiledb.SIZE_HASH)
+			customMsgBuf := bytes.NewBufferString(customMsg)
+			customMsgBytes := customMsgBuf.Bytes()
+
+			/*
+			 * Create HTTP response.
+			 */
+			response := webserver.HttpResponse{
+				Header: map[string]string{"Content-type": contentType},
+				Body:   customMsgBytes,
+			}
+
+			return response
+		} else {
+			handle := tiledb.ImageHandle{}
+			copy(handle[:], handleBytes)
+			img, err := imgdb.Open(handle)
+
+			/*
+			 * Check if image could be opened.
+			 */
+			if err != nil {
+				msg := err.Error()
+				customMsg := fmt.Sprintf("Failed to open saved image: %s", msg)
+				customMsgBuf := bytes.NewBufferString(customMsg)
+				customMsgBytes := customMsgBuf.Bytes()
+
+				/*
+				 * Create HTTP response.
+				 */
+				response := webserver.HttpResponse{
+					Header: map[string]string{"Content-type": contentType},
+					Body:   customMsgBytes,
+				}
+
+				return response
+			} else {
+
+				/*
+				 * Create HTTP response.
+				 *
+				 * Saved images are addressed by their content, so
+				 * they never change and may be cached indefinitely.
+				 */
+				response := webserver.HttpResponse{
+
+					Header: map[string]string{
+						"Cache-control": "private, max-age=31536000, immutable",
+						"Content-type":  "image/png",
+					},
+
+					ContentReadSeekCloser: img,
+				}
+
+				return response
+			}
+
+		}
+
+	}
+
+}
+
+/*
+ * Returns the sizes of all databases and the free disk space on their volumes.
+ */
+func (this *controllerStruct) getStorageStatsHandler(request webserver.HttpRequest) webserver.HttpResponse {
+	token := request.Params["token"]
+	perm, err := this.checkPermission(token, "admin")
+
+	/*
+	 * Check permissions.
+	 */
+	if err != nil {
+		msg := err.Error()
+		customMsg := fmt.Sprintf("Failed to check permission: %s", msg)
+		customMsgBuf := bytes.NewBufferString(customMsg)
+		customMsgBytes := customMsgBuf.Bytes()
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType},
+			Body:   customMsgBytes,
+		}
+
+		return response
+	} else if !perm {
+		customMsgBuf := bytes.NewBufferString("Forbidden!")
+		customMsgBytes := customMsgBuf.Bytes()
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType},
+			Body:   customMsgBytes,
+		}
+
+		return response
+	} else {
+		conf := this.getConfig()
+		tileDB := conf.TileDB
+		combinedPath := tileDB.Combined
+		names := []string{"activity", "location", "user"}
+		paths := []string{conf.ActivityDB, conf.LocationDB, this.userDBPath}
+
+		/*
+		 * Report either the combined or the separate tile databases.
+		 */
+		if combinedPath != "" {
+			names = append(names, "tile")
+			paths = append(paths, combinedPath)
+		} else {
+			names = append(names, "tile-image", "tile-index")
+			paths = append(paths, tileDB.ImageDB, tileDB.IndexDB)
+		}
+
+		databases := []webDatabaseStorageStruct{}
+
+		/*
+		 * Determine size and free space for each database.
+		 */
+		for i, name := range names {
+			path := paths[i]
+
+			/*
+			 * Skip databases which are not configured.
+			 */
+			if path != "" {
+				size := int64(-1)
+				info, err := os.Stat(path)
+
+				/*
+				 * Check if file size could be determined.
+				 */
+				if err == nil {
+					size = info.Size()
+				}
+
+				free := int64(-1)
+				dir := filepath.Dir(path)
+				freeBytes, err := freeSpace(dir)
+
+				/*
+				 * Check if free space could be determined.
+				 */
+				if (err == nil) && (freeBytes <= math.MaxInt64) {
+					free = int64(freeBytes)
+				}
+
+				/*
+				 * Create storage statistics for database.
+				 */
+				database := webDatabaseStorageStruct{
+					Name:      name,
+					Path:      path,
+					Size:      size,
+					FreeSpace: free,
+				}
+
+				databases = append(databases, database)
+			}
+
+		}
+
+		/*
+		 * Indicate success.
+		 */
+		status := webResponseStruct{
+			Success: true,
+			Reason:  "",
+		}
+
+		/*
+		 * Create storage statistics.
+		 */
+		webStats := webStorageStatsStruct{
+			webResponseStruct: status,
+			Databases:         databases,
+		}
+
+		mimeType, buffer := this.createJSON(webStats)
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": mimeType},
+			Body:   buffer,
+		}
+
+		return response
+	}
+
+}
+
+/*
+ * Render a map tile.
+ */
+func (this *controllerStruct) getTileHandler(request webserver.HttpRequest) webserver.HttpResponse {
+	token := request.Params["token"]
+	perm, err := this.checkPermission(token, "get-tile")
+
+	/*
+	 * Check permissions.
+	 */
+	if err != nil {
+		msg := err.Error()
+		customMsg := fmt.Sprintf("Failed to check permission: %s\n", msg)
+		customMsgBuf := bytes.NewBufferString(customMsg)
+		customMsgBytes := customMsgBuf.Bytes()
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType},
+			Body:   customMsgBytes,
+		}
+
+		return response
+	} else if !perm {
+		customMsgBuf := bytes.NewBufferString("Forbidden!")
+		customMsgBytes := customMsgBuf.Bytes()
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType},
+			Body:   customMsgBytes,
+		}
+
+		return response
+	} else {
+		conf := this.getConfig()
+		useMap := conf.UseMap
+
+		/*
+		 * Check if we use a map at all.
+		 *
+		 * (If not, this.tileUtil and this.tileServer will be nil.)
+		 */
+		if !useMap {
+			customMsgBuf := bytes.NewBufferString("Server does not serve map tiles.")
+			customMsgBytes := customMsgBuf.Bytes()
+			conf := this.getConfig()
+			confServer := conf.WebServer
+			contentType := confServer.ErrorMime
+
+			/*
+			 * Create HTTP response.
+			 */
+			response := webserver.HttpResponse{
+				Header: map[string]string{"Content-type": contentType},
+				Body:   customMsgBytes,
+			}
+
+			return response
+		} else {
+			xIn := request.Params["x"]
+			x64, _ := strconv.ParseUint(xIn, 10, 32)
+			x := uint32(x64)
+			yIn := request.Params["y"]
+			y64, _ := strconv.ParseUint(yIn, 10, 32)
+			y := uint32(y64)
+			zIn := request.Params["z"]
+			z64, _ := strconv.ParseUint(zIn, 10, 8)
+			z := uint8(z64)
+			tileId := tile.CreateId(z, x, y)
+			tileUtil := this.tileUtil
+			tileServer := this.tileServer
+			t, err := tileUtil.Fetch(tileServer, tileId)
+
+			/*
+			 * Check if tile could be fetched.
+			 */
+			if err != nil {
+				msg := err.Error()
+				customMsg := fmt.Sprintf("Failed to fetch map tile: %s\n", msg)
+				customMsgBuf := bytes.NewBufferString(customMsg)
+				customMsgBytes := customMsgBuf.Bytes()
+				confServer := conf.WebServer
+				contentType := confServer.ErrorMime
+
+				/*
+				 * Create HTTP response.
+				 */
+				response := webserver.HttpResponse{
+					Header: map[string]string{"Content-type": contentType},
+					Body:   customMsgBytes,
+				}
+
+				return response
+			} else {
+				format := this.negotiateImageFormat(request)
+
+				/*
+				 * Tiles are stored as PNG, so other formats require
+				 * conversion.
+				 */
+				if format == IMAGE_FORMAT_PNG {
+
+					/*
+					 * Create HTTP response.
+					 */
+					response := webserver.HttpResponse{
+						Header:                map[string]string{"Content-type": "image/png", "Vary": "Accept"},
+						ContentReadSeekCloser: t,
+					}
+
+					return response
+				} else {
+					img, err := png.Decode(t)
+					t.Close()
+					buf := []byte(nil)
+					mimeType := ""
+
+					/*
+					 * Encode tile if it could be decoded.
+					 */
+					if err == nil {
+						buf, mimeType, err = this.encodeImage(img, format, webp.QUALITY_LOSSLESS)
+					}
+
+					/*
+					 * Check if tile could be converted.
+					 */
+					if err != nil {
+						msg := err.Error()
+						customMsg := fmt.Sprintf("Failed to convert map tile: %s\n", msg)
+						customMsgBuf := bytes.NewBufferString(customMsg)
+						customMsgBytes := customMsgBuf.Bytes()
+						confServer := conf.WebServer
+						contentType := confServer.ErrorMime
+
+						/*
+						 * Create HTTP response.
+						 */
+						response := webserver.HttpResponse{
+							Header: map[string]string{"Content-type": contentType},
+							Body:   customMsgBytes,
+						}
+
+						return response
+					} else {
+
+						/*
+						 * Create HTTP response.
+						 */
+						response := webserver.HttpResponse{
+							Header: map[string]string{"Content-type": mimeType, "Vary": "Accept"},
+							Body:   buf,
+						}
+
+						return response
+					}
+
+				}
+
+			}
+
+		}
+
+	}
+
+}
+
+/*
+ * Report the number of tiles and distinct images in the tile database, as well
+ * as the size of the image database.
+ */
+func (this *controllerStruct) getTileDBStatsHandler(request webserver.HttpRequest) webserver.HttpResponse {
+	token := request.Params["token"]
+	perm, err := this.checkPermission(token, "get-tile")
+
+	/*
+	 * Check permissions.
+	 */
+	if err != nil {
+		msg := err.Error()
+		customMsg := fmt.Sprintf("Failed to check permission: %s", msg)
+		customMsgBuf := bytes.NewBufferString(customMsg)
+		customMsgBytes := customMsgBuf.Bytes()
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType},
+			Body:   customMsgBytes,
+		}
+
+		return response
+	} else if !perm {
+		customMsgBuf := bytes.NewBufferString("Forbidden!")
+		customMsgBytes := customMsgBuf.Bytes()
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType},
+			Body:   customMsgBytes,
+		}
+
+		return response
+	} else {
+		tileUtil := this.tileUtil
+
+		/*
+		 * Check if we use a map at all.
+		 */
+		if tileUtil == nil {
+			customMsgBuf := bytes.NewBufferString("Server does not serve map tiles.")
+			customMsgBytes := customMsgBuf.Bytes()
+			conf := this.getConfig()
+			confServer := conf.WebServer
+			contentType := confServer.ErrorMime
+
+			/*
+			 * Create HTTP response.
+			 */
+			response := webserver.HttpResponse{
+				Header: map[string]string{"Content-type": contentType},
+				Body:   customMsgBytes,
+			}
+
+			return response
+		} else {
+			stats, err := tileUtil.DatabaseStats()
+
+			/*
+			 * Check if statistics could be obtained.
+			 */
+			if err != nil {
+				msg := err.Error()
+				customMsg := fmt.Sprintf("Failed to obtain tile database statistics: %s", msg)
+				customMsgBuf := bytes.NewBufferString(customMsg)
+				customMsgBytes := customMsgBuf.Bytes()
+				conf := this.getConfig()
+				confServer := conf.WebServer
+				contentType := confServer.ErrorMime
+
+				/*
+				 * Create HTTP response.
+				 */
+				response := webserver.HttpResponse{
+					Header: map[string]string{"Content-type": contentType},
+					Body:   customMsgBytes,
+				}
+
+				return response
+			} else {
+				imageCount := stats.ImageCount()
+				imageSize := stats.ImageSize()
+				tileCount := stats.TileCount()
+
+				/*
+				 * Indicate success.
+				 */
+				status := webResponseStruct{
+					Success: true,
+					Reason:  "",
+				}
+
+				/*
+				 * Create tile database statistics.
+				 */
+				webStats := webTileDBStatsStruct{
+					webResponseStruct: status,
+					ImageCount:        imageCount,
+					ImageSize:         imageSize,
+					TileCount:         tileCount,
+				}
+
+				mimeType, buffer := this.createJSON(webStats)
+
+				/*
+				 * Create HTTP response.
+				 */
+				response := webserver.HttpResponse{
+					Header: map[string]string{"Content-type": mimeType},
+					Body:   buffer,
+				}
+
+				return response
+			}
+
+		}
+
+	}
+
+}
+
+/*
+ * Report statistics on the usage of the tile cache since the server started.
+ */
+func (this *controllerStruct) getTileStatsHandler(request webserver.HttpRequest) webserver.HttpResponse {
+	token := request.Params["token"]
+	perm, err := this.checkPermission(token, "get-tile")
+
+	/*
+	 * Check permissions.
+	 */
+	if err != nil {
+		msg := err.Error()
+		customMsg := fmt.Sprintf("Failed to check permission: %s", msg)
+		customMsgBuf := bytes.NewBufferString(customMsg)
+		customMsgBytes := customMsgBuf.Bytes()
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType},
+			Body:   customMsgBytes,
+		}
+
+		return response
+	} else if !perm {
+		customMsgBuf := bytes.NewBufferString("Forbidden!")
+		customMsgBytes := customMsgBuf.Bytes()
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType},
+			Body:   customMsgBytes,
+		}
+
+		return response
+	} else {
+		tileUtil := this.tileUtil
+
+		/*
+		 * Check if we use a map at all.
+		 */
+		if tileUtil == nil {
+			customMsgBuf := bytes.NewBufferString("Server does not serve map tiles.")
+			customMsgBytes := customMsgBuf.Bytes()
+			conf := this.getConfig()
+			confServer := conf.WebServer
+			contentType := confServer.ErrorMime
+
+			/*
+			 * Create HTTP response.
+			 */
+			response := webserver.HttpResponse{
+				Header: map[string]string{"Content-type": contentType},
+				Body:   customMsgBytes,
+			}
+
+			return response
+		} else {
+			stats := tileUtil.Stats()
+			fetchErrors := stats.FetchErrors()
+			fetches := stats.Fetches()
+			hits := stats.Hits()
+			misses := stats.Misses()
+
+			/*
+			 * Indicate success.
+			 */
+			status := webResponseStruct{
+				Success: true,
+				Reason:  "",
+			}
+
+			/*
+			 * Create tile statistics.
+			 */
+			webStats := webTileStatsStruct{
+				webResponseStruct: status,
+				FetchErrors:       fetchErrors,
+				Fetches:           fetches,
+				Hits:              hits,
+				Misses:            misses,
+			}
+
+			mimeType, buffer := this.createJSON(webStats)
+
+			/*
+			 * Create HTTP response.
+			 */
+			response := webserver.HttpResponse{
+				Header: map[string]string{"Content-type": mimeType},
+				Body:   buffer,
+			}
+
+			return response
+		}
+
+	}
+
+}
+
+/*
+ * Import activity data from CSV and add it to the database.
+ */
+func (this *controllerStruct) importActivityCsvHandler(request webserver.HttpRequest) webserver.HttpResponse {
+	token := request.Params["token"]
+	preview := request.Params["preview"] == "true"
+	perm, err := this.checkPermission(token, "activity-write")
+	partition, errPartition := this.requestPartition(request)
+
+	/*
+	 * Check permissions.
+	 */
+	if err != nil {
+		msg := err.Error()
+		customMsg := fmt.Sprintf("Failed to check permission: %s", msg)
+		customMsgBuf := bytes.NewBufferString(customMsg)
+		customMsgBytes := customMsgBuf.Bytes()
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType},
+			Body:   customMsgBytes,
+		}
+
+		return response
+	} else if !perm {
+		customMsgBuf := bytes.NewBufferString("Forbidden!")
+		customMsgBytes := customMsgBuf.Bytes()
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType},
+			Body:   customMsgBytes,
+		}
+
+		return response
+	} else if errPartition != nil {
+		msg := errPartition.Error()
+		customMsg := fmt.Sprintf("Failed to access data: %s", msg)
+		customMsgBuf := bytes.NewBufferString(customMsg)
+		customMsgBytes := customMsgBuf.Bytes()
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType},
+			Body:   customMsgBytes,
+		}
+
+		return response
+	} else if preview {
+		data := request.Params["data"]
+		partition.activitiesLock.RLock()
+		activities := partition.activities
+		entries, err := activities.PreviewCSV(data)
+		partition.activitiesLock.RUnlock()
+		result := webActivityImportPreviewStruct{}
+
+		/*
+		 * Check if activity data could be previewed.
+		 */
+		if err != nil {
+			msg := err.Error()
+			reason := fmt.Sprintf("Failed to preview activity data: %s", msg)
+
+			/*
+			 * Indicate failure.
+			 */
+			result.webResponseStruct = webResponseStruct{
+				Success: false,
+				Reason:  reason,
+			}
+
+		} else {
+			numEntries := len(entries)
+			rows := make([]webActivityImportPreviewRowStruct, numEntries)
+
+			/*
+			 * Convert preview entries into web representation.
+			 */
+			for i, entry := range entries {
+				status := "new"
+				beginString := ""
+
+				/*
+				 * Decide on the outcome of the import.
+				 */
+				switch entry.Status {
+				case meta.PREVIEW_COLLISION:
+					status = "collision"
+					result.Collisions++
+				case meta.PREVIEW_MALFORMED:
+					status = "malformed"
+					result.Malformed++
+				default:
+					result.New++
+				}
+
+				/*
+				 * Malformed records have no beginning.
+				 */
+				if entry.Status != meta.PREVIEW_MALFORMED {
+					begin := entry.Begin
+					beginString = begin.Format(time.RFC3339)
+				}
+
+				/*
+				 * Create web representation of preview entry.
+				 */
+				rows[i] = webActivityImportPreviewRowStruct{
+					Row:      entry.Row,
+					Status:   status,
+					Begin:    beginString,
+					Existing: entry.Existing,
+					Reason:   entry.Reason,
+				}
+
+			}
+
+			/*
+			 * Indicate success.
+			 */
+			result.webResponseStruct = webResponseStruct{
+				Success: true,
+				Reason:  "",
+			}
+
+			result.Rows = rows
+		}
+
+		mimeType, buffer := this.createJSON(result)
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": mimeType},
+			Body:   buffer,
+		}
+
+		return response
+	} else {
+		wr := webResponseStruct{}
+		data := request.Params["data"]
+		partition.activitiesLock.Lock()
+		activities := partition.activities
+		err = this.backupActivityDB(partition)
+
+		/*
+		 * Only import activities if a backup was written.
+		 */
+		if err == nil {
+			err = activities.ImportCSV(data)
+		}
+
+		/*
+		 * Check if activity data was imported.
+		 */
+		if err != nil {
+			msg := err.Error()
+			reason := fmt.Sprintf("Failed to import activity data: %s", msg)
+
+			/*
+			 * Indicate failure.
+			 */
+			wr = webResponseStruct{
+				Success: false,
+				Reason:  reason,
+			}
+
+		} else {
+			err = this.syncActivityDB(partition)
+
+			/*
+			 * Check if user database was synchronized.
+			 */
+			if err != nil {
+				msg := err.Error()
+				reason := fmt.Sprintf("Failed to synchronize activity database: %s", msg)
+
+				/*
+				 * Indicate failure.
+				 */
+				wr = webResponseStruct{
+					Success: false,
+					Reason:  reason,
+				}
+
+			} else {
+
+				/*
+				 * Indicate success.
+				 */
+				wr = webResponseStruct{
+					Success: true,
+					Reason:  "",
+				}
+
+			}
+
+		}
+
+		partition.activitiesLock.Unlock()
+		mimeType, buffer := this.createJSON(wr)
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": mimeType},
+			Body:   buffer,
+		}
+
+		return response
+	}
+
+}
+
+/*
+ * Derive activity data from a GPX track and add it to the database.
+ */
+func (this *controllerStruct) importActivityGpxHandler(request webserver.HttpRequest) webserver.HttpResponse {
+	token := request.Params["token"]
+	perm, err := this.checkPermission(token, "activity-write")
+	partition, errPartition := this.requestPartition(request)
+
+	/*
+	 * Check permissions.
+	 */
+	if err != nil {
+		msg := err.Error()
+		customMsg := fmt.Sprintf("Failed to check permission: %s", msg)
+		customMsgBuf := bytes.NewBufferString(customMsg)
+		customMsgBytes := customMsgBuf.Bytes()
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType},
+			Body:   customMsgBytes,
+		}
+
+		return response
+	} else if !perm {
+		customMsgBuf := bytes.NewBufferString("Forbidden!")
+		customMsgBytes := customMsgBuf.Bytes()
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType},
+			Body:   customMsgBytes,
+		}
+
+		return response
+	} else if errPartition != nil {
+		msg := errPartition.Error()
+		customMsg := fmt.Sprintf("Failed to access data: %s", msg)
+		customMsgBuf := bytes.NewBufferString(customMsg)
+		customMsgBytes := customMsgBuf.Bytes()
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType},
+			Body:   customMsgBytes,
+		}
+
+		return response
+	} else {
+		wr := webResponseStruct{}
+		data := request.Params["data"]
+		dataBytes := []byte(data)
+		sport := request.Params["sport"]
+		partition.activitiesLock.Lock()
+		activities := partition.activities
+		err = this.backupActivityDB(partition)
+
+		/*
+		 * Only import activities if a backup was written.
+		 */
+		if err == nil {
+			err = activities.ImportActivityGPX(dataBytes, sport)
+		}
+
+		/*
+		 * Check if activity data was imported.
+		 */
+		if err != nil {
+			msg := err.Error()
+			reason := fmt.Sprintf("Failed to import activity data: %s", msg)
+
+			/*
+			 * Indicate failure.
+			 */
+			wr = webResponseStruct{
+				Success: false,
+				Reason:  reason,
+			}
+
+		} else {
+			err = this.syncActivityDB(partition)
+
+			/*
+			 * Check if user database was synchronized.
+			 */
+			if err != nil {
+				msg := err.Error()
+				reason := fmt.Sprintf("Failed to synchronize activity database: %s", msg)
+
+				/*
+				 * Indicate failure.
+				 */
+				wr = webResponseStruct{
+					Success: false,
+					Reason:  reason,
+				}
+
+			} else {
+
+				/*
+				 * Indicate success.
+				 */
+				wr = webResponseStruct{
+					Success: true,
+					Reason:  "",
+				}
+
+			}
+
+		}
+
+		partition.activitiesLock.Unlock()
+		mimeType, buffer := this.createJSON(wr)
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": mimeType},
+			Body:   buffer,
+		}
+
+		return response
+	}
+
+}
+
+/*
+ * Import location data in the given format into the location database of a
+ * data partition.
+ *
+ * The format may be "auto" to detect it from the data. If defaultTimeIn is
+ * non-empty, it is an RFC 3339 time stamp assigned to KML locations without a
+ * time stamp, which are skipped otherwise.
+ */
+func (this *controllerStruct) importGeoData(ctx context.Context, partition *dataPartitionStruct, r io.Reader, format string, strategy string, defaultTimeIn string) webMigrationReportStruct {
+	target := partition.locationDB
+	migrationReport := webMigrationReportStruct{}
+	conf := this.getConfig()
+	limits := conf.Limits
+	maxDecompressedSize := limits.MaxDecompressedSize
+
+	/*
+	 * Fall back to default limit if none is configured.
+	 */
+	if maxDecompressedSize == 0 {
+		maxDecompressedSize = MAX_DECOMPRESSED_SIZE
+	}
+
+	data, compressed, errDecompress := this.readGeoData(r, maxDecompressedSize)
+	formatDetected := format == "auto"
+	errDetect := error(nil)
+
+	/*
+	 * Detect format from content if requested.
+	 */
+	if formatDetected && (errDecompress == nil) {
+		format, errDetect = this.detectGeoDataFormat(data)
+	}
+
+	migrationReport.Compressed = compressed
+	migrationReport.Format = format
+	migrationReport.FormatDetected = formatDetected
+	hasDefaultTime := defaultTimeIn != ""
+	defaultTime := time.Time{}
+	errDefaultTime := error(nil)
+
+	/*
+	 * Parse default time stamp if one was provided.
+	 */
+	if hasDefaultTime {
+		defaultTime, errDefaultTime = filter.ParseTime(defaultTimeIn, false, false)
+	}
+
+	source, err := geo.Database(nil), fmt.Errorf("%s", "No source file or invalid format.")
+
+	switch format {
+	case "binary":
+		source, err = opengeodb.FromBytes(data)
+	case "csv":
+		source, err = geocsv.FromBytes(data)
+	case "gpx":
+		source, err = gpx.FromBytes(data)
+	case "json":
+		source, err = geojson.FromBytes(data)
+	case "kml":
+
+		/*
+		 * Assign default time stamp to locations without one, if
+		 * provided.
+		 */
+		if !hasDefaultTime {
+			source, err = geokml.FromBytes(data)
+		} else if errDefaultTime == nil {
+			gu := geoutil.Create()
+			defaultTimestamp := gu.TimeToMilliseconds(defaultTime)
+			source, err = geokml.FromBytesWithDefaultTimestamp(data, defaultTimestamp)
+		}
+
+	}
+
+	/*
+	 * Check if source file could be decompressed, format could be
+	 * detected and source file could be successfully parsed.
+	 */
+	if errDecompress != nil {
+		msg := errDecompress.Error()
+		reason := fmt.Sprintf("Failed to decompress source file: %s", msg)
+
+		/*
+		 * Indicate failure.
+		 */
+		status := webResponseStruct{
+			Success: false,
+			Reason:  reason,
+		}
+
+		migrationReport.Status = status
+	} else if errDetect != nil {
+		msg := errDetect.Error()
+		reason := fmt.Sprintf("Failed to detect format of source file: %s", msg)
+
+		/*
+		 * Indicate failure.
+		 */
+		status := webResponseStruct{
+			Success: false,
+			Reason:  reason,
+		}
+
+		migrationReport.Status = status
+	} else if errDefaultTime != nil {
+		msg := errDefaultTime.Error()
+		reason := fmt.Sprintf("Failed to parse default time stamp: %s", msg)
+
+		/*
+		 * Indicate failure.
+		 */
+		status := webResponseStruct{
+			Success: false,
+			Reason:  reason,
+		}
+
+		migrationReport.Status = status
+	} else if err != nil {
+		msg := err.Error()
+		reason := fmt.Sprintf("Failed to parse source file: %s", msg)
+
+		/*
+		 * Indicate failure.
+		 */
+		status := webResponseStruct{
+			Success: false,
+			Reason:  reason,
+		}
+
+		migrationReport.Status = status
+	} else {
+		importStrategy := int(geoutil.IMPORT_NONE)
+		importStrategyValid := false
+
+		/*
+		 * Decide on import strategy.
+		 */
+		switch strategy {
+		case "all":
+			importStrategy = int(geoutil.IMPORT_ALL)
+			importStrategyValid = true
+		case "newer":
+			importStrategy = int(geoutil.IMPORT_NEWER)
+			importStrategyValid = true
+		case "none":
+			importStrategy = int(geoutil.IMPORT_NONE)
+			importStrategyValid = true
+		default:
+			importStrategyValid = false
+		}
+
+		/*
+		 * Check if import strategy is valid.
+		 */
+		if !importStrategyValid {
+			reason := fmt.Sprintf("Invalid import strategy: '%s'", strategy)
+
+			/*
+			 * Indicate failure.
+			 */
+			status := webResponseStruct{
+				Success: false,
+				Reason:  reason,
+			}
+
+			migrationReport.Status = status
+		} else {
+			gu := geoutil.Create()
+			maxImportLocations := limits.MaxImportLocations
+			report, errMigrate := gu.Migrate(ctx, target, source, importStrategy, maxImportLocations)
+			reportBefore := report.Before()
+			reportBeforeLocationCount := reportBefore.LocationCount()
+			reportBeforeOrdered := reportBefore.Ordered()
+			reportBeforeOrderedStrict := reportBefore.OrderedStrict()
+			reportBeforeTimestampEarliest := reportBefore.TimestampEarliest()
+			reportBeforeTimestampEarliestTime := gu.MillisecondsToTime(reportBeforeTimestampEarliest)
+			reportBeforeTimestampEarliestString := reportBeforeTimestampEarliestTime.Format(TIMESTAMP_FORMAT)
+
+			/*
+			 * Strip default value from report.
+			 */
+			if reportBeforeTimestampEarliest == math.MaxUint64 {
+				reportBeforeTimestampEarliestString = ""
+			}
+
+			reportBeforeTimestampLatest := reportBefore.TimestampLatest()
+			reportBeforeTimestampLatestTime := gu.MillisecondsToTime(reportBeforeTimestampLatest)
+			reportBeforeTimestampLatestString := reportBeforeTimestampLatestTime.Format(TIMESTAMP_FORMAT)
+
+			/*
+			 * Strip default value from report.
+			 */
+			if reportBeforeTimestampLatest == 0 {
+				reportBeforeTimestampLatestString = ""
+			}
+
+			/*
+			 * Create statistics for GeoDB state before data migration.
+			 */
+			webStatsBefore := webDatasetStatsStruct{
+				LocationCount:     reportBeforeLocationCount,
+				Ordered:           reportBeforeOrdered,
+				OrderedStrict:     reportBeforeOrderedStrict,
+				TimestampEarliest: reportBeforeTimestampEarliestString,
+				TimestampLatest:   reportBeforeTimestampLatestString,
+			}
+
+			reportSource := report.Source()
+			reportSourceLocationCount := reportSource.LocationCount()
+			reportSourceOrdered := reportSource.Ordered()
+			reportSourceOrderedStrict := reportSource.OrderedStrict()
+			reportSourceTimestampEarliest := reportSource.TimestampEarliest()
+			reportSourceTimestampEarliestTime := gu.MillisecondsToTime(reportSourceTimestampEarliest)
+			reportSourceTimestampEarliestString := reportSourceTimestampEarliestTime.Format(TIMESTAMP_FORMAT)
+
+			/*
+			 * Strip default value from report.
+			 */
+			if reportSourceTimestampEarliest == math.MaxUint64 {
+				reportSourceTimestampEarliestString = ""
+			}
+
+			reportSourceTimestampLatest := reportSource.TimestampLatest()
+			reportSourceTimestampLatestTime := gu.MillisecondsToTime(reportSourceTimestampLatest)
+			reportSourceTimestampLatestString := reportSourceTimestampLatestTime.Format(TIMESTAMP_FORMAT)
+
+			/*
+			 * Strip default value from report.
+			 */
+			if reportSourceTimestampLatest == 0 {
+				reportSourceTimestampLatestString = ""
+			}
+
+			/*
+			 * Create statistics for GeoJSON data provided as source.
+			 */
+			webStatsSource := webDatasetStatsStruct{
+				LocationCount:     reportSourceLocationCount,
+				Ordered:           reportSourceOrdered,
+				OrderedStrict:     reportSourceOrderedStrict,
+				TimestampEarliest: reportSourceTimestampEarliestString,
+				TimestampLatest:   reportSourceTimestampLatestString,
+			}
+
+			reportImported := report.Imported()
+			reportImportedLocationCount := reportImported.LocationCount()
+			reportImportedOrdered := reportImported.Ordered()
+			reportImportedOrderedStrict := reportImported.OrderedStrict()
+			reportImportedTimestampEarliest := reportImported.TimestampEarliest()
+			reportImportedTimestampEarliestTime := gu.MillisecondsToTime(reportImportedTimestampEarliest)
+			reportImportedTimestampEarliestString := reportImportedTimestampEarliestTime.Format(TIMESTAMP_FORMAT)
+
+			/*
+			 * Strip default value from report.
+			 */
+			if reportImportedTimestampEarliest == math.MaxUint64 {
+				reportImportedTimestampEarliestString = ""
+			}
+
+			reportImportedTimestampLatest := reportImported.TimestampLatest()
+			reportImportedTimestampLatestTime := gu.MillisecondsToTime(reportImportedTimestampLatest)
+			reportImportedTimestampLatestString := reportImportedTimestampLatestTime.Format(TIMESTAMP_FORMAT)
+
+			/*
+			 * Strip default value from report.
+			 */
+			if reportImportedTimestampLatest == 0 {
+				reportImportedTimestampLatestString = ""
+			}
+
+			/*
+			 * Create statistics for GeoJSON data actually imported.
+			 */
+			webStatsImported := webDatasetStatsStruct{
+				LocationCount:     reportImportedLocationCount,
+				Ordered:           reportImportedOrdered,
+				OrderedStrict:     reportImportedOrderedStrict,
+				TimestampEarliest: reportImportedTimestampEarliestString,
+				TimestampLatest:   reportImportedTimestampLatestString,
+			}
+
+			reportAfter := report.After()
+			reportAfterLocationCount := reportAfter.LocationCount()
+			reportAfterOrdered := reportAfter.Ordered()
+			reportAfterOrderedStrict := reportAfter.OrderedStrict()
+			reportAfterTimestampEarliest := reportAfter.TimestampEarliest()
+			reportAfterTimestampEarliestTime := gu.MillisecondsToTime(reportAfterTimestampEarliest)
+			reportAfterTimestampEarliestString := reportAfterTimestampEarliestTime.Format(TIMESTAMP_FORMAT)
+
+			/*
+			 * Strip default value from report.
+			 */
+			if reportAfterTimestampEarliest == math.MaxUint64 {
+				reportAfterTimestampEarliestString = ""
+			}
+
+			reportAfterTimestampLatest := reportAfter.TimestampLatest()
+			reportAfterTimestampLatestTime := gu.MillisecondsToTime(reportAfterTimestampLatest)
+			reportAfterTimestampLatestString := reportAfterTimestampLatestTime.Format(TIMESTAMP_FORMAT)
+
+			/*
+			 * Strip default value from report.
+			 */
+			if reportAfterTimestampLatest == 0 {
+				reportAfterTimestampLatestString = ""
+			}
+
+			/*
+			 * Create statistics for GeoDB state after data migration.
+			 */
+			webStatsAfter := webDatasetStatsStruct{
+				LocationCount:     reportAfterLocationCount,
+				Ordered:           reportAfterOrdered,
+				OrderedStrict:     reportAfterOrderedStrict,
+				TimestampEarliest: reportAfterTimestampEarliestString,
+				TimestampLatest:   reportAfterTimestampLatestString,
+			}
+
+			skipped := source.SkippedCount()
+
+			/*
+			 * Create migration report.
+			 */
+			migrationReport = webMigrationReportStruct{
+				Before:         webStatsBefore,
+				Source:         webStatsSource,
+				Imported:       webStatsImported,
+				After:          webStatsAfter,
+				Skipped:        skipped,
+				Compressed:     compressed,
+				Format:         format,
+				FormatDetected: formatDetected,
+			}
+
+			/*
+			 * Check if error happened during migration.
+			 */
+			if errMigrate != nil {
+				msg := errMigrate.Error()
+
+				/*
+				 * Indicate failure.
+				 */
+				status := webResponseStruct{
+					Success: false,
+					Reason:  msg,
+				}
+
+				migrationReport.Status = status
+			} else {
+
+				/*
+				 * Indicate success.
+				 */
+				status := webResponseStruct{
+					Success: true,
+					Reason:  "",
+				}
+
+				migrationReport.Status = status
+			}
+
+		}
+
+	}
+
+	return migrationReport
+}
+
+/*
+ * Import location data in CSV, GPX or GeoJSON format.
+ */
+func (this *controllerStruct) importGeoDataHandler(request webserver.HttpRequest) webserver.HttpResponse {
+	token := request.Params["token"]
+	migrationReport := webMigrationReportStruct{}
+	perm, err := this.checkPermission(token, "geodb-write")
+	partition, errPartition := this.requestPartition(request)
+
+	/*
+	 * Check permissions.
+	 */
+	if err != nil {
+		msg := err.Error()
+		reason := fmt.Sprintf("Failed to check permission: %s", msg)
+
+		/*
+		 * Indicate failure.
+		 */
+		status := webResponseStruct{
+			Success: false,
+			Reason:  reason,
+		}
+
+		migrationReport.Status = status
+	} else if !perm {
+
+		/*
+		 * Indicate failure.
+		 */
+		status := webResponseStruct{
+			Success: false,
+			Reason:  "Forbidden!",
+		}
+
+		migrationReport.Status = status
+	} else if errPartition != nil {
+		msg := errPartition.Error()
+		reason := fmt.Sprintf("Failed to access data: %s", msg)
+
+		/*
+		 * Indicate failure.
+		 */
+		status := webResponseStruct{
+			Success: false,
+			Reason:  reason,
+		}
+
+		migrationReport.Status = status
+	} else {
+		files := request.Files["file"]
+
+		/*
+		 * Make sure that files are not nil.
+		 */
+		if files == nil {
+
+			/*
+			 * Indicate failure.
+			 */
+			status := webResponseStruct{
+				Success: false,
+				Reason:  "Field 'file' not defined as a multipart field.",
+			}
+
+			migrationReport.Status = status
+		} else {
+			numFiles := len(files)
+
+			/*
+			 * Make sure that exactly one file is sent in request.
+			 */
+			if numFiles == 0 {
+
+				/*
+				 * Indicate failure.
+				 */
+				status := webResponseStruct{
+					Success: false,
+					Reason:  "No file sent in request.",
+				}
+
+				migrationReport.Status = status
+			} else if numFiles != 1 {
+
+				/*
+				 * Indicate failure.
+				 */
+				status := webResponseStruct{
+					Success: false,
+					Reason:  "Multiple files sent in request.",
+				}
+
+				migrationReport.Status = status
+			} else {
+				file := files[0]
+				data, err := io.ReadAll(file)
+
+				/*
+				 * Check if source file could be successfully read.
+				 */
+				if err != nil {
+
+					/*
+					 * Indicate failure.
+					 */
+					status := webResponseStruct{
+						Success: false,
+						Reason:  "Failed to read source file.",
+					}
+
+					migrationReport.Status = status
+				} else {
+					ctx := request.Context
+					format := request.Params["format"]
+					strategy := request.Params["strategy"]
+					defaultTimeIn := request.Params["defaulttime"]
+					r := bytes.NewReader(data)
+					migrationReport = this.importGeoData(ctx, partition, r, format, strategy, defaultTimeIn)
+				}
+
+			}
+
+		}
+
+	}
+
+	mimeType, buffer := this.createJSON(migrationReport)
+
+	/*
+	 * Create HTTP response.
+	 */
+	response := webserver.HttpResponse{
+		Header: map[string]string{"Content-type": mimeType},
+		Body:   buffer,
+	}
+
+	return response
+}
+
+/*
+ * Predicts the statistics of the GeoDB location database after sorting it,
+ * without modifying it.
+ *
+ * If removeDuplicates is true, the statistics after deduplication are
+ * predicted instead. Returns the number of locations that would be removed.
+ */
+func (this *controllerStruct) predictOrdering(db geodb.Database, before webDatasetStatsStruct, removeDuplicates bool) (uint32, webDatasetStatsStruct, error) {
+	gu := geoutil.Create()
+	dups, err := gu.GeoDBDuplicates(db)
+
+	/*
+	 * Check if duplicates could be counted.
+	 */
+	if err != nil {
+		msg := err.Error()
+		return 0, webDatasetStatsStruct{}, fmt.Errorf("Error counting duplicates: %s", msg)
+	} else {
+		duplicates := dups.Duplicates()
+		timestampsUnique := dups.TimestampsUnique()
+		locationCount := before.LocationCount
+		removed := uint32(0)
+		orderedStrict := timestampsUnique && (duplicates == 0)
+
+		/*
+		 * Deduplication removes duplicates, so only distinct locations
+		 * need unique time stamps.
+		 */
+		if removeDuplicates {
+			removed = duplicates
+			locationCount -= duplicates
+			orderedStrict = timestampsUnique
+		}
+
+		/*
+		 * Create dataset statistics.
+		 */
+		after := webDatasetStatsStruct{
+			LocationCount:     locationCount,
+			Ordered:           true,
+			OrderedStrict:     orderedStrict,
+			TimestampEarliest: before.TimestampEarliest,
+			TimestampLatest:   before.TimestampLatest,
+		}
+
+		return removed, after, nil
+	}
+
+}
+
+/*
+ * Lists the backups of the activity database.
+ */
+func (this *controllerStruct) listActivityBackupsHandler(request webserver.HttpRequest) webserver.HttpResponse {
+	token := request.Params["token"]
+	perm, err := this.checkPermission(token, "activity-read")
+	partition, errPartition := this.requestPartition(request)
+
+	/*
+	 * Check permissions.
+	 */
+	if err != nil {
+		msg := err.Error()
+		customMsg := fmt.Sprintf("Failed to check permission: %s", msg)
+		customMsgBuf := bytes.NewBufferString(customMsg)
+		customMsgBytes := customMsgBuf.Bytes()
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType},
+			Body:   customMsgBytes,
+		}
+
+		return response
+	} else if !perm {
+		customMsgBuf := bytes.NewBufferString("Forbidden!")
+		customMsgBytes := customMsgBuf.Bytes()
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType},
+			Body:   customMsgBytes,
+		}
+
+		return response
+	} else if errPartition != nil {
+		msg := errPartition.Error()
+		customMsg := fmt.Sprintf("Failed to access data: %s", msg)
+		customMsgBuf := bytes.NewBufferString(customMsg)
+		customMsgBytes := customMsgBuf.Bytes()
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType},
+			Body:   customMsgBytes,
+		}
+
+		return response
+	} else {
+		backups, err := this.listActivityBackups(partition)
+
+		/*
+		 * Check if backups could be listed.
+		 */
+		if err != nil {
+			msg := err.Error()
+			customMsgBuf := bytes.NewBufferString(msg)
+			customMsgBytes := customMsgBuf.Bytes()
+			conf := this.getConfig()
+			confServer := conf.WebServer
+			contentType := confServer.ErrorMime
+
+			/*
+			 * Create HTTP response.
+			 */
+			response := webserver.HttpResponse{
+				Header: map[string]string{"Content-type": contentType},
+				Body:   customMsgBytes,
+			}
+
+			return response
+		} else {
+			numBackups := len(backups)
+			webBackups := make([]webActivityBackupStruct, numBackups)
+
+			/*
+			 * Convert backups into web representation.
+			 */
+			for i, backup := range backups {
+				created := backup.created
+				createdString := created.Format(time.RFC3339)
+
+				/*
+				 * Create web representation of backup.
+				 */
+				webBackups[i] = webActivityBackupStruct{
+					Name:    backup.name,
+					Created: createdString,
+					Size:    backup.size,
+				}
+
+			}
+
+			/*
+			 * Create list of backups.
+			 */
+			result := webActivityBackupsStruct{
+				Backups: webBackups,
+			}
+
+			mimeType, buffer := this.createJSON(result)
+
+			/*
+			 * Create HTTP response.
+			 */
+			response := webserver.HttpResponse{
+				Header: map[string]string{"Content-type": mimeType},
+				Body:   buffer,
+			}
+
+			return response
+		}
+
+	}
+
+}
+
+/*
+ * Modify entries in GeoDB location database.
+ */
+func (this *controllerStruct) modifyGeoDataHandler(request webserver.HttpRequest) webserver.HttpResponse {
+	token := request.Params["token"]
+	perm, err := this.checkPermission(token, "geodb-write")
+	partition, errPartition := this.requestPartition(request)
+	report := webDatasetModificationReportStruct{}
+
+	/*
+	 * Check permissions.
+	 */
+	if err != nil {
+		msg := err.Error()
+		reason := fmt.Sprintf("Failed to check permission: %s\n", msg)
+
+		/*
+		 * Report failure.
+		 */
+		report.Status = webResponseStruct{
+			Success: false,
+			Reason:  reason,
+		}
+
+	} else if !perm {
+		reason := "Forbidden!"
+
+		/*
+		 * Report failure.
+		 */
+		report.Status = webResponseStruct{
+			Success: false,
+			Reason:  reason,
+		}
+
+	} else if errPartition != nil {
+		msg := errPartition.Error()
+		reason := fmt.Sprintf("Failed to access data: %s", msg)
+
+		/*
+		 * Report failure.
+		 */
+		report.Status = webResponseStruct{
+			Success: false,
+			Reason:  reason,
+		}
+
+	} else {
+		db := partition.locationDB
+
+		/*
+		 * Make sure database exists.
+		 */
+		if db != nil {
+			gu := geoutil.Create()
+			datasetStatsBefore := webDatasetStatsStruct{}
+			datasetStatsAfter := webDatasetStatsStruct{}
+			statsBefore, err := gu.GeoDBStats(db)
+
+			/*
+			 * Make sure that no error occured.
+			 */
+			if err != nil {
+				msg := err.Error()
+				reason := fmt.Sprintf("Error obtaining database stats: %s", msg)
+
+				/*
+				 * Report failure.
+				 */
+				report.Status = webResponseStruct{
+					Success: false,
+					Reason:  reason,
+				}
+
+			} else {
+				locationCountBefore := statsBefore.LocationCount()
+				orderedBefore := statsBefore.Ordered()
+				orderedStrictBefore := statsBefore.OrderedStrict()
+				timestampEarliestBefore := statsBefore.TimestampEarliest()
+				timestampLatestBefore := statsBefore.TimestampLatest()
+				timestampEarliestStringBefore := ""
+				timestampLatestStringBefore := ""
+
+				/*
+				 * Check if timestamps are defined.
+				 */
+				if timestampEarliestBefore <= timestampLatestBefore {
+					timestampEarliestTimeBefore := gu.MillisecondsToTime(timestampEarliestBefore)
+					timestampEarliestStringBefore = timestampEarliestTimeBefore.Format(TIMESTAMP_FORMAT)
+					timestampLatestTimeBefore := gu.MillisecondsToTime(timestampLatestBefore)
+					timestampLatestStringBefore = timestampLatestTimeBefore.Format(TIMESTAMP_FORMAT)
+				}
+
+				/*
+				 * Create dataset statistics.
+				 */
+				datasetStatsBefore = webDatasetStatsStruct{
+					LocationCount:     locationCountBefore,
+					Ordered:           orderedBefore,
+					OrderedStrict:     orderedStrictBefore,
+					TimestampEarliest: timestampEarliestStringBefore,
+					TimestampLatest:   timestampLatestStringBefore,
+				}
+
+				action := request.Params["action"]
+				dryRun := request.Params["dryrun"] == "true"
+				ctx := request.Context
+				n := uint32(0)
+				err := fmt.Errorf("Unknown action: '%s'", action)
+				actionDescription := "unknown action"
+
+				/*
+				 * Decide which action to carry out.
+				 */
+				switch action {
+				case "deduplicate":
+					actionDescription = "deduplication"
+
+					/*
+					 * Only predict the outcome on a dry run.
+					 */
+					if dryRun {
+						n, datasetStatsAfter, err = this.predictOrdering(db, datasetStatsBefore, true)
+					} else {
+						n, err = db.Deduplicate(ctx)
+					}
+
+				case "deduplicate-threshold":
+					actionDescription = "deduplication"
+					distanceIn := request.Params["distance"]
+					distance, errDistance := strconv.ParseFloat(distanceIn, 64)
+					gapIn := request.Params["gap"]
+					gap, errGap := time.ParseDuration(gapIn)
+					gapMs := gap.Milliseconds()
+
+					/*
+					 * Collapse nearby entries if thresholds could be
+					 * parsed.
+					 */
+					if dryRun {
+						err = fmt.Errorf("%s", "Dry run is not supported for this action.")
+					} else if errDistance != nil {
+						err = fmt.Errorf("%s", "Could not parse the distance.")
+					} else if errGap != nil {
+						err = fmt.Errorf("%s", "Could not parse the time gap.")
+					} else if gapMs < 0 {
+						err = fmt.Errorf("%s", "Time gap must not be negative.")
+					} else {
+						gapMs64 := uint64(gapMs)
+						n, err = db.DeduplicateThreshold(distance, gapMs64)
+					}
+
+				case "delete-range":
+					actionDescription = "deletion"
+					beginIn := request.Params["begin"]
+					begin, errBegin := filter.ParseTime(beginIn, false, false)
+					beginMs := begin.UnixMilli()
+					endIn := request.Params["end"]
+					end, errEnd := filter.ParseTime(endIn, false, false)
+					endMs := end.UnixMilli()
+
+					/*
+					 * Remove entries within the time range if it could be
+					 * parsed.
+					 */
+					if dryRun {
+						err = fmt.Errorf("%s", "Dry run is not supported for this action.")
+					} else if errBegin != nil {
+						err = fmt.Errorf("%s", "Could not parse the begin time.")
+					} else if errEnd != nil {
+						err = fmt.Errorf("%s", "Could not parse the end time.")
+					} else if (beginMs < 0) || (endMs < 0) {
+						err = fmt.Errorf("%s", "Time range must not lie before the Epoch.")
+					} else {
+						beginMs64 := uint64(beginMs)
+						endMs64 := uint64(endMs)
+						n, err = db.DeleteRange(beginMs64, endMs64)
+					}
+
+				case "repair-timestamps":
+					actionDescription = "timestamp repair"
+					sortAfter := request.Params["sort"] == "true"
+					earliest, latest, errRange := this.timestampRepairRange()
+
+					/*
+					 * Remove entries outside the valid range if it could
+					 * be determined.
+					 */
+					if dryRun {
+						err = fmt.Errorf("%s", "Dry run is not supported for this action.")
+					} else if errRange != nil {
+						err = errRange
+					} else {
+						n, err = db.RemoveOutsideTimeRange(ctx, earliest, latest)
+
+						/*
+						 * Sort database afterwards if requested.
+						 */
+						if (err == nil) && sortAfter {
+							err = db.Sort(ctx)
+						}
+
+					}
+
+				case "sort":
+					actionDescription = "sorting"
+
+					/*
+					 * Only predict the outcome on a dry run.
+					 */
+					if dryRun {
+						_, datasetStatsAfter, err = this.predictOrdering(db, datasetStatsBefore, false)
+					} else {
+						err = db.Sort(ctx)
+					}
+
+				case "thin":
+					actionDescription = "thinning"
+					keepEveryNIn := request.Params["n"]
+					keepEveryN, errKeepEveryN := strconv.ParseUint(keepEveryNIn, 10, 32)
+
+					/*
+					 * Keep only every n-th entry if n could be parsed.
+					 */
+					if dryRun {
+						err = fmt.Errorf("%s", "Dry run is not supported for this action.")
+					} else if errKeepEveryN != nil {
+						err = fmt.Errorf("%s", "Could not parse the number of entries.")
+					} else {
+						keepEveryN32 := uint32(keepEveryN)
+						n, err = db.Thin(keepEveryN32)
+					}
+
+				}
+
+				/*
+				 * Make sure that no error occured.
+				 */
+				if err != nil {
+					msg := err.Error()
+					reason := fmt.Sprintf("Error during %s: %s", actionDescription, msg)
+
+					/*
+					 * Report failure.
+					 */
+					report.Status = webResponseStruct{
+						Success: false,
+						Reason:  reason,
+					}
+
+				} else if dryRun {
+
+					/*
+					 * Report success.
+					 */
+					status := webResponseStruct{
+						Success: true,
+						Reason:  "",
+					}
+
+					/*
+					 * Create dataset modification report.
+					 */
+					report = webDatasetModificationReportStruct{
+						Status:  status,
+						Before:  datasetStatsBefore,
+						After:   datasetStatsAfter,
+						Removed: n,
+						DryRun:  true,
+					}
+
+				} else {
+					statsAfter, err := gu.GeoDBStats(db)
+
+					/*
+					 * Make sure that no error occured.
+					 */
+					if err != nil {
+						msg := err.Error()
+						reason := fmt.Sprintf("Error obtaining database stats: %s", msg)
+
+						/*
+						 * Report failure.
+						 */
+						report.Status = webResponseStruct{
+							Success: false,
+							Reason:  reason,
+						}
+
+					} else {
+						locationCountAfter := statsAfter.LocationCount()
+						orderedAfter := statsAfter.Ordered()
+						orderedStrictAfter := statsAfter.OrderedStrict()
+						timestampEarliestAfter := statsAfter.TimestampEarliest()
+						timestampLatestAfter := statsAfter.TimestampLatest()
+						timestampEarliestStringAfter := ""
+						timestampLatestStringAfter := ""
+
+						/*
+						* Check if timestamps are defined.
+						 */
+						if timestampEarliestAfter <= timestampLatestAfter {
+							timestampEarliestTimeAfter := gu.MillisecondsToTime(timestampEarliestAfter)
+							timestampEarliestStringAfter = timestampEarliestTimeAfter.Format(TIMESTAMP_FORMAT)
+							timestampLatestTimeAfter := gu.MillisecondsToTime(timestampLatestAfter)
+							timestampLatestStringAfter = timestampLatestTimeAfter.Format(TIMESTAMP_FORMAT)
+						}
+
+						/*
+						* Create dataset statistics.
+						 */
+						datasetStatsAfter = webDatasetStatsStruct{
+							LocationCount:     locationCountAfter,
+							Ordered:           orderedAfter,
+							OrderedStrict:     orderedStrictAfter,
+							TimestampEarliest: timestampEarliestStringAfter,
+							TimestampLatest:   timestampLatestStringAfter,
+						}
+
+						/*
+						 * Report success.
+						 */
+						status := webResponseStruct{
+							Success: true,
+							Reason:  "",
+						}
+
+						/*
+						 * Create dataset modification report.
+						 */
+						report = webDatasetModificationReportStruct{
+							Status:  status,
+							Before:  datasetStatsBefore,
+							After:   datasetStatsAfter,
+							Removed: n,
+						}
+
+					}
+
+				}
+
+			}
+
+		}
+
+	}
+
+	mimeType, buffer := this.createJSON(report)
+
+	/*
+	 * Create HTTP response.
+	 */
+	response := webserver.HttpResponse{
+		Header: map[string]string{"Content-type": mimeType},
+		Body:   buffer,
+	}
+
+	return response
+}
+
+/*
+ * Remove activity information from database.
+ */
+func (this *controllerStruct) removeActivityHandler(request webserver.HttpRequest) webserver.HttpResponse {
+	token := request.Params["token"]
+	perm, err := this.checkPermission(token, "activity-write")
+	partition, errPartition := this.requestPartition(request)
+
+	/*
+	 * Check permissions.
+	 */
+	if err != nil {
+		msg := err.Error()
+		customMsg := fmt.Sprintf("Failed to check permission: %s", msg)
+		customMsgBuf := bytes.NewBufferString(customMsg)
+		customMsgBytes := customMsgBuf.Bytes()
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType},
+			Body:   customMsgBytes,
+		}
+
+		return response
+	} else if !perm {
+		customMsgBuf := bytes.NewBufferString("Forbidden!")
+		customMsgBytes := customMsgBuf.Bytes()
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType},
+			Body:   customMsgBytes,
+		}
+
+		return response
+	} else if errPartition != nil {
+		msg := errPartition.Error()
+		customMsg := fmt.Sprintf("Failed to access data: %s", msg)
+		customMsgBuf := bytes.NewBufferString(customMsg)
+		customMsgBytes := customMsgBuf.Bytes()
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType},
+			Body:   customMsgBytes,
+		}
+
+		return response
+	} else {
+		wr := webResponseStruct{}
+		revisionIn := request.Params["revision"]
+		revision, err := strconv.ParseUint(revisionIn, 10, 64)
+
+		/*
+		 * Check if revision could be parsed.
+		 */
+		if err != nil {
+
+			/*
+			 * Indicate failure.
+			 */
+			wr = webResponseStruct{
+				Success: false,
+				Reason:  "Failed to remove activity: Invalid revision number.",
+			}
+
+		} else {
+			idIn := request.Params["id"]
+			id64, err := strconv.ParseUint(idIn, 10, 32)
+
+			/*
+			 * Check if ID could be parsed.
+			 */
+			if err != nil {
+
+				/*
+				 * Indicate failure.
+				 */
+				wr = webResponseStruct{
+					Success: false,
+					Reason:  "Failed to remove activity: Invalid id.",
+				}
+
+			} else {
+				id := uint32(id64)
+				partition.activitiesLock.Lock()
+				activities := partition.activities
+				currentRevision := activities.Revision()
+
+				/*
+				 * Make sure that revision information matches.
+				 */
+				if revision != currentRevision {
+
+					/*
+					 * Indicate failure.
+					 */
+					wr = webResponseStruct{
+						Success: false,
+						Reason:  "Failed to remove activity: Activity data was changed in the meantime.",
+					}
+
+				} else {
+					err := this.backupActivityDB(partition)
+
+					/*
+					 * Only remove activity if a backup was written.
+					 */
+					if err == nil {
+						err = activities.Remove(id)
+					}
+
+					/*
+					 * Check if activity was removed.
+					 */
+					if err != nil {
+						msg := err.Error()
+						reason := fmt.Sprintf("Failed to remove activity: %s", msg)
+
+						/*
+						 * Indicate failure.
+						 */
+						wr = webResponseStruct{
+							Success: false,
+							Reason:  reason,
+						}
+
+					} else {
+						err = this.syncActivityDB(partition)
+
+						/*
+						 * Check if user database was synchronized.
+						 */
+						if err != nil {
+							msg := err.Error()
+							reason := fmt.Sprintf("Failed to synchronize activity database: %s", msg)
+
+							/*
+							 * Indicate failure.
+							 */
+							wr = webResponseStruct{
+								Success: false,
+								Reason:  reason,
+							}
+
+						} else {
+
+							/*
+							 * Indicate success.
+							 */
+							wr = webResponseStruct{
+								Success: true,
+								Reason:  "",
+							}
+
+						}
+
+					}
+
+				}
+
+				partition.activitiesLock.Unlock()
+			}
+
+		}
+
+		mimeType, buffer := this.createJSON(wr)
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": mimeType},
+			Body:   buffer,
+		}
+
+		return response
+	}
+
+}
+
+/*
+ * Remove all activity information within a time window from database.
+ */
+func (this *controllerStruct) removeActivitiesRangeHandler(request webserver.HttpRequest) webserver.HttpResponse {
+	token := request.Params["token"]
+	perm, err := this.checkPermission(token, "activity-write")
+	partition, errPartition := this.requestPartition(request)
+
+	/*
+	 * Check permissions.
+	 */
+	if err != nil {
+		msg := err.Error()
+		customMsg := fmt.Sprintf("Failed to check permission: %s", msg)
+		customMsgBuf := bytes.NewBufferString(customMsg)
+		customMsgBytes := customMsgBuf.Bytes()
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType},
+			Body:   customMsgBytes,
+		}
+
+		return response
+	} else if !perm {
+		customMsgBuf := bytes.NewBufferString("Forbidden!")
+		customMsgBytes := customMsgBuf.Bytes()
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType},
+			Body:   customMsgBytes,
+		}
+
+		return response
+	} else if errPartition != nil {
+		msg := errPartition.Error()
+		customMsg := fmt.Sprintf("Failed to access data: %s", msg)
+		customMsgBuf := bytes.NewBufferString(customMsg)
+		customMsgBytes := customMsgBuf.Bytes()
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType},
+			Body:   customMsgBytes,
+		}
+
+		return response
+	} else {
+		status := webResponseStruct{}
+		numRemoved := uint32(0)
+		revisionIn := request.Params["revision"]
+		revision, err := strconv.ParseUint(revisionIn, 10, 64)
+		beginIn := request.Params["begin"]
+		begin, errBegin := filter.ParseTime(beginIn, false, false)
+		endIn := request.Params["end"]
+		end, errEnd := filter.ParseTime(endIn, false, false)
+
+		/*
+		 * Check if revision and time window could be parsed.
+		 */
+		if err != nil {
+
+			/*
+			 * Indicate failure.
+			 */
+			status = webResponseStruct{
+				Success: false,
+				Reason:  "Failed to remove activities: Invalid revision number.",
+			}
+
+		} else if errBegin != nil {
+
+			/*
+			 * Indicate failure.
+			 */
+			status = webResponseStruct{
+				Success: false,
+				Reason:  "Failed to remove activities: Could not parse the begin time.",
+			}
+
+		} else if errEnd != nil {
+
+			/*
+			 * Indicate failure.
+			 */
+			status = webResponseStruct{
+				Success: false,
+				Reason:  "Failed to remove activities: Could not parse the end time.",
+			}
+
+		} else {
+			partition.activitiesLock.Lock()
+			activities := partition.activities
+			currentRevision := activities.Revision()
+
+			/*
+			 * Make sure that revision information matches.
+			 */
+			if revision != currentRevision {
+
+				/*
+				 * Indicate failure.
+				 */
+				status = webResponseStruct{
+					Success: false,
+					Reason:  "Failed to remove activities: Activity data was changed in the meantime.",
+				}
+
+			} else {
+				err = this.backupActivityDB(partition)
+
+				/*
+				 * Only remove activities if a backup was written.
+				 */
+				if err == nil {
+					numRemoved, err = activities.RemoveRange(begin, end)
+				}
+
+				/*
+				 * Check if activities were removed.
+				 */
+				if err != nil {
+					msg := err.Error()
+					reason := fmt.Sprintf("Failed to remove activities: %s", msg)
+
+					/*
+					 * Indicate failure.
+					 */
+					status = webResponseStruct{
+						Success: false,
+						Reason:  reason,
+					}
+
+				} else {
+					err = this.syncActivityDB(partition)
+
+					/*
+					 * Check if activity database was synchronized.
+					 */
+					if err != nil {
+						msg := err.Error()
+						reason := fmt.Sprintf("Failed to synchronize activity database: %s", msg)
+
+						/*
+						 * Indicate failure.
+						 */
+						status = webResponseStruct{
+							Success: false,
+							Reason:  reason,
+						}
+
+					} else {
+
+						/*
+						 * Indicate success.
+						 */
+						status = webResponseStruct{
+							Success: true,
+							Reason:  "",
+						}
+
+					}
+
+				}
+
+			}
+
+			partition.activitiesLock.Unlock()
+		}
+
+		/*
+		 * Create activity removal report.
+		 */
+		report := webActivityRemovalReportStruct{
+			Status:  status,
+			Removed: numRemoved,
+		}
+
+		mimeType, buffer := this.createJSON(report)
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": mimeType},
+			Body:   buffer,
+		}
+
+		return response
+	}
+
+}
+
+/*
+ * Replace activity information inside the database.
+ */
+func (this *controllerStruct) replaceActivityHandler(request webserver.HttpRequest) webserver.HttpResponse {
+	token := request.Params["token"]
+	perm, err := this.checkPermission(token, "activity-write")
+	partition, errPartition := this.requestPartition(request)
+
+	/*
+	 * Check permissions.
+	 */
+	if err != nil {
+		msg := err.Error()
+		customMsg := fmt.Sprintf("Failed to check permission: %s", msg)
+		customMsgBuf := bytes.NewBufferString(customMsg)
+		customMsgBytes := customMsgBuf.Bytes()
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType},
+			Body:   customMsgBytes,
+		}
+
+		return response
+	} else if !perm {
+		customMsgBuf := bytes.NewBufferString("Forbidden!")
+		customMsgBytes := customMsgBuf.Bytes()
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType},
+			Body:   customMsgBytes,
+		}
+
+		return response
+	} else if errPartition != nil {
+		msg := errPartition.Error()
+		customMsg := fmt.Sprintf("Failed to access data: %s", msg)
+		customMsgBuf := bytes.NewBufferString(customMsg)
+		customMsgBytes := customMsgBuf.Bytes()
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType},
+			Body:   customMsgBytes,
+		}
+
+		return response
+	} else {
+		wr := webResponseStruct{}
+		revisionIn := request.Params["revision"]
+		revision, err := strconv.ParseUint(revisionIn, 10, 64)
+
+		/*
+		 * Check if revision could be parsed.
+		 */
+		if err != nil {
+
+			/*
+			 * Indicate failure.
+			 */
+			wr = webResponseStruct{
+				Success: false,
+				Reason:  "Failed to remove activity: Invalid revision number.",
+			}
+
+		} else {
+			idIn := request.Params["id"]
+			id64, err := strconv.ParseUint(idIn, 10, 32)
+
+			/*
+			 * Check if ID could be parsed.
+			 */
+			if err != nil {
+
+				/*
+				 * Indicate failure.
+				 */
+				wr = webResponseStruct{
+					Success: false,
+					Reason:  "Failed to replace activity: Invalid id.",
+				}
+
+			} else {
+				id := uint32(id64)
+				beginIn := request.Params["begin"]
+				begin, err := filter.ParseTime(beginIn, false, false)
+
+				/*
+				 * The begin time has to be filled in correctly.
+				 */
+				if err != nil {
+					reason := "Failed to add activity: Could not parse the begin time."
+
+					/*
+					 * Indicate failure.
+					 */
+					wr = webResponseStruct{
+						Success: false,
+						Reason:  reason,
+					}
+
+				} else {
+					weightKG := request.Params["weightkg"]
+					runningDurationIn := request.Params["runningduration"]
+					runningDuration, _ := time.ParseDuration(runningDurationIn)
+					runningDistanceKM := request.Params["runningdistancekm"]
+					runningStepCountIn := request.Params["runningstepcount"]
+					runningStepCount, _ := strconv.ParseUint(runningStepCountIn, 10, 64)
+					runningEnergyKJIn := request.Params["runningenergykj"]
+					runningEnergyKJ, _ := strconv.ParseUint(runningEnergyKJIn, 10, 64)
+					cyclingDurationIn := request.Params["cyclingduration"]
+					cyclingDuration, _ := time.ParseDuration(cyclingDurationIn)
+					cyclingDistanceKM := request.Params["cyclingdistancekm"]
+					cycingEnergyKJIn := request.Params["cyclingenergykj"]
+					cyclingEnergyKJ, _ := strconv.ParseUint(cycingEnergyKJIn, 10, 64)
+					otherEnergyKJIn := request.Params["otherenergykj"]
+					otherEnergyKJ, _ := strconv.ParseUint(otherEnergyKJIn, 10, 64)
+					conf := this.getConfig()
+					activityTypes := conf.ActivityTypes
+					custom := this.parseCustomActivities(request.Params, activityTypes)
+
+					/*
+					 * Create activity info.
+					 */
+					info := meta.ActivityInfo{
+						Begin:             begin,
+						WeightKG:          weightKG,
+						RunningDuration:   runningDuration,
+						RunningDistanceKM: runningDistanceKM,
+						RunningStepCount:  runningStepCount,
+						RunningEnergyKJ:   runningEnergyKJ,
+						CyclingDuration:   cyclingDuration,
+						CyclingDistanceKM: cyclingDistanceKM,
+						CyclingEnergyKJ:   cyclingEnergyKJ,
+						OtherEnergyKJ:     otherEnergyKJ,
+						Custom:            custom,
+					}
+
+					partition.activitiesLock.Lock()
+					activities := partition.activities
+					currentRevision := activities.Revision()
+
+					/*
+					 * Make sure that revision information matches.
+					 */
+					if revision != currentRevision {
+
+						/*
+						 * Indicate failure.
+						 */
+						wr = webResponseStruct{
+							Success: false,
+							Reason:  "Failed to replace activity: Activity data was changed in the meantime.",
+						}
+
+					} else {
+						err := this.backupActivityDB(partition)
+
+						/*
+						 * Only replace activity if a backup was
+						 * written.
+						 */
+						if err == nil {
+							existing, errExisting := activities.Get(id)
+
+							/*
+							 * Keep user-defined activities which were
+							 * not provided.
+							 */
+							if errExisting == nil {
+								existingCustom := existing.Custom()
+								info.Custom = this.mergeCustomActivities(custom, existingCustom)
+							}
+
+							err = activities.Replace(id, &info)
+						}
+
+						/*
+						 * Check if activity was replaced.
+						 */
+						if err != nil {
+							msg := err.Error()
+							reason := fmt.Sprintf("Failed to replace activity: %s", msg)
+
+							/*
+							 * Indicate failure.
+							 */
+							wr = webResponseStruct{
+								Success: false,
+								Reason:  reason,
+							}
+
+						} else {
+							err = this.syncActivityDB(partition)
+
+							/*
+							 * Check if user database was synchronized.
+							 */
+							if err != nil {
+								msg := err.Error()
+								reason := fmt.Sprintf("Failed to synchronize activity database: %s", msg)
+
+								/*
+								 * Indicate failure.
+								 */
+								wr = webResponseStruct{
+									Success: false,
+									Reason:  reason,
+								}
+
+							} else {
+
+								/*
+								 * Indicate success.
+								 */
+								wr = webResponseStruct{
+									Success: true,
+									Reason:  "",
+								}
+
+							}
+
+						}
+
+					}
+
+					partition.activitiesLock.Unlock()
+				}
+
+			}
+
+		}
+
+		mimeType, buffer := this.createJSON(wr)
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": mimeType},
+			Body:   buffer,
+		}
+
+		return response
+	}
+
+}
+
+/*
+ * Saves an encoded image to the image database and creates a response
+ * containing its handle and a link to retrieve it.
+ */
+func (this *controllerStruct) saveImage(buf []byte) webserver.HttpResponse {
+	imgdb := this.imageDatabase
+	webSavedImage := webSavedImageStruct{}
+
+	/*
+	 * Check if we have an image database.
+	 */
+	if imgdb == nil {
+
+		/*
+		 * Indicate failure.
+		 */
+		webSavedImage.webResponseStruct = webResponseStruct{
+			Success: false,
+			Reason:  "Server has no image database to save rendered images to.",
+		}
+
+	} else {
+		handle, err := imgdb.Insert(buf)
+
+		/*
+		 * Check if image could be inserted into image database.
+		 */
+		if err != nil {
+			msg := err.Error()
+			reason := fmt.Sprintf("Failed to insert image into image database: %s", msg)
+
+			/*
+			 * Indicate failure.
+			 */
+			webSavedImage.webResponseStruct = webResponseStruct{
+				Success: false,
+				Reason:  reason,
+			}
+
+		} else {
+			handleString := hex.EncodeToString(handle[:])
+			linkFormat := CGI_PATH + "?cgi=get-saved-image&handle=%s"
+			link := fmt.Sprintf(linkFormat, handleString)
+
+			/*
+			 * Create data structure representing saved image.
+			 */
+			webSavedImage = webSavedImageStruct{
+
+				webResponseStruct: webResponseStruct{
+					Success: true,
+					Reason:  "",
+				},
+
+				Handle: handleString,
+				Link:   link,
+			}
+
+		}
+
+	}
+
+	mimeType, buffer := this.createJSON(webSavedImage)
+
+	/*
+	 * Create HTTP response.
+	 */
+	response := webserver.HttpResponse{
+		Header: map[string]string{"Content-type": mimeType},
+		Body:   buffer,
+	}
+
+	return response
+}
+
+/*
+ * Renders the map tiles covering a scene into an opaque image.
+ *
+ * The scene is given in the same way as for renderScene. Tiles are chosen at
+ * the same zoom level as in the web interface, fetched through the tile
+ * source and scaled to the resolution of the image.
+ *
+ * An intensity of MAP_INTENSITY_ORIGINAL keeps the original colors of the
+ * map. Otherwise, the map is inverted to grayscale and its brightness is
+ * scaled by a tenth of the intensity, just like in the web interface.
+ */
+func (this *controllerStruct) renderMap(xres uint32, yres uint32, xpos float64, ypos float64, zoom uint8, intensity int64) (*image.NRGBA, error) {
+	zoomFloat := float64(zoom)
+	zoomExp := 0.2 * zoomFloat
+	zoomFac := math.Pow(2.0, zoomExp)
+	halfWidth := 0.5 / zoomFac
+	xresFloat := float64(xres)
+	yresFloat := float64(yres)
+	aspectRatio := yresFloat / xresFloat
+	halfHeight := aspectRatio * halfWidth
+	minX := xpos - halfWidth
+	maxY := ypos + halfHeight
+	pixelSize := (2.0 * halfWidth) / xresFloat
+	tilesPerImage := xresFloat / MAP_TILE_SIZE
+	osmZoomFloat := math.Floor(math.Log2(zoomFac * tilesPerImage))
+
+	/*
+	 * Limit OSM zoom.
+	 */
+	if osmZoomFloat < 0.0 {
+		osmZoomFloat = 0.0
+	} else if osmZoomFloat > MAP_ZOOM_MAX {
+		osmZoomFloat = MAP_ZOOM_MAX
+	}
+
+	osmZoom := uint8(osmZoomFloat)
+	tilesPerAxis64 := int64(1) << osmZoom
+	tilesPerAxis := float64(tilesPerAxis64)
+	xresInt := int(xres)
+	yresInt := int(yres)
+	bounds := image.Rect(0, 0, xresInt, yresInt)
+	target := image.NewNRGBA(bounds)
+	tiles := map[tile.Id]image.Image{}
+	tileUtil := this.tileUtil
+	tileServer := this.tileServer
+	this.configLock.RLock()
+	sem := this.semTile
+	this.configLock.RUnlock()
+	scale := 0.1 * float64(intensity)
+
+	/*
+	 * Iterate over the rows of the image.
+	 */
+	for py := 0; py < yresInt; py++ {
+		pyFloat := float64(py)
+		sceneY := maxY - ((pyFloat + 0.5) * pixelSize)
+		tileYFloat := (0.5 - sceneY) * tilesPerAxis
+		tileYIdx := math.Floor(tileYFloat)
+		tileYFrac := tileYFloat - tileYIdx
+
+		/*
+		 * Iterate over the columns of the image.
+		 */
+		for px := 0; px < xresInt; px++ {
+			pxFloat := float64(px)
+			sceneX := minX + ((pxFloat + 0.5) * pixelSize)
+			tileXFloat := (sceneX + 0.5) * tilesPerAxis
+			tileXIdx := math.Floor(tileXFloat)
+			tileXFrac := tileXFloat - tileXIdx
+			offset := target.PixOffset(px, py)
+			pixel := target.Pix[offset : offset+4]
+			pixel[3] = 255
+
+			/*
+			 * Leave pixels outside of the map black.
+			 */
+			if (tileXIdx >= 0.0) && (tileXIdx < tilesPerAxis) && (tileYIdx >= 0.0) && (tileYIdx < tilesPerAxis) {
+				tileX := uint32(tileXIdx)
+				tileY := uint32(tileYIdx)
+				tileId := tile.CreateId(osmZoom, tileX, tileY)
+				img, ok := tiles[tileId]
+
+				/*
+				 * Fetch and decode tile on first use.
+				 */
+				if !ok {
+					this.acquire(sem)
+					t, err := tileUtil.Fetch(tileServer, tileId)
+					this.release(sem)
+
+					/*
+					 * Check if tile could be fetched.
+					 */
+					if err != nil {
+						msg := err.Error()
+						return nil, fmt.Errorf("Failed to fetch map tile (%d, %d, %d): %s", osmZoom, tileX, tileY, msg)
+					} else {
+						img, err = png.Decode(t)
+						t.Close()
+
+						/*
+						 * Check if tile could be decoded.
+						 */
+						if err != nil {
+							msg := err.Error()
+							return nil, fmt.Errorf("Failed to decode map tile (%d, %d, %d): %s", osmZoom, tileX, tileY, msg)
+						}
+
+						tiles[tileId] = img
+					}
+
+				}
+
+				tileBounds := img.Bounds()
+				tileWidth := tileBounds.Dx()
+				tileHeight := tileBounds.Dy()
+				tileWidthFloat := float64(tileWidth)
+				tileHeightFloat := float64(tileHeight)
+				sx := tileBounds.Min.X + int(tileXFrac*tileWidthFloat)
+				sy := tileBounds.Min.Y + int(tileYFrac*tileHeightFloat)
+				r, g, b, _ := img.At(sx, sy).RGBA()
+				red := uint8(r >> 8)
+				green := uint8(g >> 8)
+				blue := uint8(b >> 8)
+
+				/*
+				 * Transform to scaled, inverted grayscale unless the
+				 * original colors are requested.
+				 */
+				if intensity != MAP_INTENSITY_ORIGINAL {
+					redInv := 1.0 - (float64(red) / 255.0)
+					greenInv := 1.0 - (float64(green) / 255.0)
+					blueInv := 1.0 - (float64(blue) / 255.0)
+					luma := (0.22 * redInv) + (0.72 * greenInv) + (0.06 * blueInv)
+					lumaScaled := scale * luma
+					lumaByte := uint8(math.Round(lumaScaled * 255.0))
+					red = lumaByte
+					green = lumaByte
+					blue = lumaByte
+				}
+
+				pixel[0] = red
+				pixel[1] = green
+				pixel[2] = blue
+			}
+
+		}
+
+	}
+
+	return target, nil
+}
+
+/*
+ * Creates a filter matching the locations which may contribute to a scene.
+ *
+ * The scene is given by its limits in projected coordinates. It is extended by
+ * margin in each direction, so that locations which are spread into the scene
+ * from outside are kept. Longitudes are limited to the range of the map, since
+ * locations are not repeated beyond it.
+ */
+func (this *controllerStruct) sceneFilter(minX float64, maxX float64, minY float64, maxY float64, margin float64) filter.Filter {
+	mercator := projection.Mercator()
+	lowerLeft := coordinates.CreateCartesian(minX-margin, minY-margin)
+	upperRight := coordinates.CreateCartesian(maxX+margin, maxY+margin)
+	lowerLeftGeographic := coordinates.Geographic{}
+	upperRightGeographic := coordinates.Geographic{}
+	mercator.InverseSingle(&lowerLeftGeographic, &lowerLeft)
+	mercator.InverseSingle(&upperRightGeographic, &upperRight)
+	minLatitude := lowerLeftGeographic.Latitude() * (180.0 / math.Pi)
+	maxLatitude := upperRightGeographic.Latitude() * (180.0 / math.Pi)
+	minLongitude := lowerLeftGeographic.Longitude() * (180.0 / math.Pi)
+	maxLongitude := upperRightGeographic.Longitude() * (180.0 / math.Pi)
+	minLatitude = math.Max(minLatitude, -90.0)
+	maxLatitude = math.Min(maxLatitude, 90.0)
+	minLongitude = math.Max(minLongitude, -180.0)
+	maxLongitude = math.Min(maxLongitude, 180.0)
+	minLatitudeE7 := int32(math.Floor(minLatitude * 1e7))
+	maxLatitudeE7 := int32(math.Ceil(maxLatitude * 1e7))
+	minLongitudeE7 := int32(math.Floor(minLongitude * 1e7))
+	maxLongitudeE7 := int32(math.Ceil(maxLongitude * 1e7))
+	flt := filter.BoundingBox(minLatitudeE7, maxLatitudeE7, minLongitudeE7, maxLongitudeE7)
+	return flt
+}
+
+/*
+ * Determines the range of indices of the locations in a database, which may
+ * lie within a time interval.
+ *
+ * Time values may be zero to leave either or both of the bounds open. If the
+ * database is ordered by time stamp, the bounds are found using binary search.
+ * Otherwise, or if the search fails, the range covers the entire database.
+ */
+func (this *controllerStruct) locationRange(db geodb.Database, minTime time.Time, maxTime time.Time) (uint32, uint32) {
+	begin := uint32(0)
+	end := db.LocationCount()
+	minTimeIsZero := minTime.IsZero()
+	maxTimeIsZero := maxTime.IsZero()
+
+	/*
+	 * Only search if at least one of the bounds is set.
+	 */
+	if !minTimeIsZero || !maxTimeIsZero {
+		ordered, err := db.Ordered()
+
+		/*
+		 * Binary search requires an ordered database.
+		 */
+		if (err == nil) && ordered {
+			minMs := minTime.UnixMilli()
+			maxMs := maxTime.UnixMilli()
+
+			/*
+			 * Find the first location not before the beginning of
+			 * the interval.
+			 */
+			if !minTimeIsZero && (minMs > 0) {
+				minMs64 := uint64(minMs)
+				idx, err := db.SeekTimestamp(minMs64)
+
+				/*
+				 * Only narrow the range if the search succeeded.
+				 */
+				if err == nil {
+					begin = idx
+				}
+
+			}
+
+			/*
+			 * Find the first location after the end of the interval.
+			 */
+			if !maxTimeIsZero {
+
+				/*
+				 * No location lies before the Epoch.
+				 */
+				if maxMs < 0 {
+					end = begin
+				} else {
+					maxMs64 := uint64(maxMs)
+					idx, err := db.SeekTimestamp(maxMs64 + 1)
+
+					/*
+					 * Only narrow the range if the search
+					 * succeeded.
+					 */
+					if err == nil {
+						end = idx
+					}
+
+				}
+
+			}
+
+		}
+
+	}
+
+	return begin, end
+}
+
+/*
+ * Renders the density of the locations in a database matching a filter into
+ * an image.
+ *
+ * The image is centered around a position in projected coordinates. Each
+ * zoom level magnifies the image by a factor of 2^0.2. Locations too far
+ * outside of the image to contribute to it are skipped before aggregation.
+ * Only locations between minTime and maxTime are read if the database is
+ * ordered by time stamp, where zero values leave the bounds open. Rendering is
+ * aborted once the configured render timeout expires.
+ *
+ * If mode is RENDER_MODE_LINES, consecutive locations recorded at most maxGap
+ * apart are connected by lines. Since lines may cross the image even if both
+ * of their locations lie far outside of it, locations are not skipped then.
+ */
+func (this *controllerStruct) renderScene(ctx context.Context, db geodb.Database, minTime time.Time, maxTime time.Time, xres uint32, yres uint32, xpos float64, ypos float64, zoom uint8, spread uint8, mode string, maxGap time.Duration, fgColor string, flt filter.Filter) (*image.NRGBA, error) {
+	zoomFloat := float64(zoom)
+	zoomExp := -0.2 * zoomFloat
+	zoomFac := math.Pow(2.0, zoomExp)
+	halfWidth := 0.5 * zoomFac
+	xresFloat := float64(xres)
+	yresFloat := float64(yres)
+	aspectRatio := yresFloat / xresFloat
+	halfHeight := aspectRatio * halfWidth
+	minX := xpos - halfWidth
+	maxX := xpos + halfWidth
+	minY := ypos - halfHeight
+	maxY := ypos + halfHeight
+	scn := scene.Create(xres, yres, minX, maxX, minY, maxY)
+	spreadFloat := float64(spread)
+	pixelSize := (2.0 * halfWidth) / xresFloat
+	margin := (spreadFloat + 1.0) * pixelSize
+	cancel := context.CancelFunc(nil)
+	this.configLock.RLock()
+	renderTimeout := this.renderTimeout
+	this.configLock.RUnlock()
+
+	/*
+	 * Abort rendering once the timeout expires.
+	 */
+	if renderTimeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, renderTimeout)
+	}
+
+	begin, end := this.locationRange(db, minTime, maxTime)
+	errCancelled := error(nil)
+
+	/*
+	 * Either connect locations by lines or aggregate them individually.
+	 */
+	if mode == RENDER_MODE_LINES {
+		clipMinX := minX - margin
+		clipMaxX := maxX + margin
+		clipMinY := minY - margin
+		clipMaxY := maxY + margin
+		errCancelled = this.aggregateTracks(ctx, db, scn, flt, begin, end, maxGap, clipMinX, clipMaxX, clipMinY, clipMaxY, pixelSize)
+	} else {
+		sceneFlt := this.sceneFilter(minX, maxX, minY, maxY, margin)
+		pointFlt := filter.And(sceneFlt, flt)
+		errCancelled = this.aggregateLocations(ctx, db, scn, pointFlt, begin, end)
+	}
+
+	/*
+	 * Release resources associated with the timeout.
+	 */
+	if cancel != nil {
+		cancel()
+	}
+
+	scn.Spread(spread)
+	mapping := color.DefaultMapping()
+
+	/*
+	 * Check if custom color mapping is required.
+	 */
+	switch fgColor {
+	case "red":
+		mapping = color.SimpleMapping(255, 0, 0)
+	case "green":
+		mapping = color.SimpleMapping(0, 255, 0)
+	case "blue":
+		mapping = color.SimpleMapping(0, 0, 255)
+	case "yellow":
+		mapping = color.SimpleMapping(255, 255, 0)
+	case "cyan":
+		mapping = color.SimpleMapping(0, 255, 255)
+	case "magenta":
+		mapping = color.SimpleMapping(255, 0, 255)
+	case "gray":
+		mapping = color.SimpleMapping(127, 127, 127)
+	case "brightblue":
+		mapping = color.SimpleMapping(127, 127, 255)
+	case "white":
+		mapping = color.SimpleMapping(255, 255, 255)
+	}
+
+	target := (*image.NRGBA)(nil)
+	err := errCancelled
+
+	/*
+	 * Only render image if request was not cancelled.
+	 */
+	if err == nil {
+		target, err = scn.Render(mapping)
+	}
+
+	return target, err
+}
+
+/*
+ * Clears the pixels of a rendered image which overlap with a zone around a
+ * point, given by its latitude and longitude in degrees and its radius in
+ * meters.
+ *
+ * The image is positioned like in renderScene. This removes the density of
+ * locations outside of the zone which was spread into it, as well as lines
+ * crossing it. A zone with a radius of zero is left untouched.
+ */
+func (this *controllerStruct) redactZone(target *image.NRGBA, xpos float64, ypos float64, zoom uint8, latitude float64, longitude float64, radius float64) {
+
+	/*
+	 * Only redact zones of positive size.
+	 */
+	if radius > 0.0 {
+		bounds := target.Bounds()
+		width := bounds.Dx()
+		height := bounds.Dy()
+		zoomFloat := float64(zoom)
+		zoomExp := -0.2 * zoomFloat
+		zoomFac := math.Pow(2.0, zoomExp)
+		halfWidth := 0.5 * zoomFac
+		widthFloat := float64(width)
+		heightFloat := float64(height)
+		aspectRatio := heightFloat / widthFloat
+		halfHeight := aspectRatio * halfWidth
+		minX := xpos - halfWidth
+		maxY := ypos + halfHeight
+		pixelSize := (2.0 * halfWidth) / widthFloat
+		mercator := projection.Mercator()
+		gu := geoutil.Create()
+		latitudeE7 := int32(math.Round(latitude * 1e7))
+		longitudeE7 := int32(math.Round(longitude * 1e7))
+		latitudeRadians := gu.DegreesE7ToRadians(latitudeE7)
+		longitudeRadians := gu.DegreesE7ToRadians(longitudeE7)
+		centerGeographic := coordinates.CreateGeographic(longitudeRadians, latitudeRadians)
+		center := coordinates.Cartesian{}
+		mercator.ForwardSingle(&center, &centerGeographic)
+		circumference := 2.0 * math.Pi * geoutil.EARTH_RADIUS_METERS
+		cosLatitude := math.Cos(latitudeRadians)
+		metersPerUnit := circumference * cosLatitude
+		radiusProjected := (2.0 * radius) / metersPerUnit
+		pixelRadius := (radiusProjected / pixelSize) + 2.0
+		centerPixelX := (center.X() - minX) / pixelSize
+		centerPixelY := (maxY - center.Y()) / pixelSize
+		minPixelX := int(math.Max(math.Floor(centerPixelX-pixelRadius), 0.0))
+		maxPixelX := int(math.Min(math.Ceil(centerPixelX+pixelRadius), widthFloat))
+		minPixelY := int(math.Max(math.Floor(centerPixelY-pixelRadius), 0.0))
+		maxPixelY := int(math.Min(math.Ceil(centerPixelY+pixelRadius), heightFloat))
+		radiansToDegreesE7 := 1e7 * (180.0 / math.Pi)
+		transparent := imagecolor.NRGBA{}
+
+		/*
+		 * Iterate over the rows of pixels which may overlap the zone.
+		 */
+		for py := minPixelY; py < maxPixelY; py++ {
+			pyFloat := float64(py)
+			y := maxY - ((pyFloat + 0.5) * pixelSize)
+			yCorner := maxY - (pyFloat * pixelSize)
+
+			/*
+			 * Iterate over the columns of pixels which may overlap
+			 * the zone.
+			 */
+			for px := minPixelX; px < maxPixelX; px++ {
+				pxFloat := float64(px)
+				x := minX + ((pxFloat + 0.5) * pixelSize)
+				xCorner := minX + (pxFloat * pixelSize)
+				pixelCenter := coordinates.CreateCartesian(x, y)
+				pixelCorner := coordinates.CreateCartesian(xCorner, yCorner)
+				pixelCenterGeographic := coordinates.Geographic{}
+				pixelCornerGeographic := coordinates.Geographic{}
+				mercator.InverseSingle(&pixelCenterGeographic, &pixelCenter)
+				mercator.InverseSingle(&pixelCornerGeographic, &pixelCorner)
+				pixelLatitudeE7 := int32(math.Round(pixelCenterGeographic.Latitude() * radiansToDegreesE7))
+				pixelLongitudeE7 := int32(math.Round(pixelCenterGeographic.Longitude() * radiansToDegreesE7))
+				cornerLatitudeE7 := int32(math.Round(pixelCornerGeographic.Latitude() * radiansToDegreesE7))
+				cornerLongitudeE7 := int32(math.Round(pixelCornerGeographic.Longitude() * radiansToDegreesE7))
+				distance := gu.DistanceE7(pixelLatitudeE7, pixelLongitudeE7, latitudeE7, longitudeE7)
+				halfDiagonal := gu.DistanceE7(pixelLatitudeE7, pixelLongitudeE7, cornerLatitudeE7, cornerLongitudeE7)
+
+				/*
+				 * Clear pixels which overlap the zone.
+				 */
+				if distance <= (radius + halfDiagonal) {
+					target.SetNRGBA(px, py, transparent)
+				}
+
+			}
+
+		}
+
+	}
+
+}
+
+/*
+ * Render location data into an image.
+ */
+func (this *controllerStruct) renderHandler(request webserver.HttpRequest) webserver.HttpResponse {
+	token := request.Params["token"]
+	perm, err := this.checkPermission(token, "render")
+	partition, errPartition := this.requestPartition(request)
+
+	/*
+	 * Check permissions.
+	 */
+	if err != nil {
+		msg := err.Error()
+		customMsg := fmt.Sprintf("Failed to check permission: %s", msg)
+		customMsgBuf := bytes.NewBufferString(customMsg)
+		customMsgBytes := customMsgBuf.Bytes()
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType},
+			Body:   customMsgBytes,
+		}
+
+		return response
+	} else if !perm {
+		customMsgBuf := bytes.NewBufferString("Forbidden!")
+		customMsgBytes := customMsgBuf.Bytes()
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType},
+			Body:   customMsgBytes,
+		}
+
+		return response
+	} else if errPartition != nil {
+		msg := errPartition.Error()
+		customMsg := fmt.Sprintf("Failed to access data: %s", msg)
+		customMsgBuf := bytes.NewBufferString(customMsg)
+		customMsgBytes := customMsgBuf.Bytes()
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType},
+			Body:   customMsgBytes,
+		}
+
+		return response
+	} else {
+		conf := this.getConfig()
+		defaults := conf.RenderDefaults
+		xresIn := request.Params["xres"]
+		xres64 := uint64(defaults.XRes)
+
+		/*
+		 * Parse resolution along X axis if it was provided.
+		 */
+		if xresIn != "" {
+			xres64, _ = strconv.ParseUint(xresIn, 10, 16)
+		}
+
+		xres := uint32(xres64)
+		xres64 = uint64(xres)
+		yresIn := request.Params["yres"]
+		yres64 := uint64(defaults.YRes)
+
+		/*
+		 * Parse resolution along Y axis if it was provided.
+		 */
+		if yresIn != "" {
+			yres64, _ = strconv.ParseUint(yresIn, 10, 16)
+		}
+
+		yres := uint32(yres64)
+		yres64 = uint64(yres)
+		resolution := xres64 * yres64
+		tz := request.Params["tz"]
+		location, errTz := time.LoadLocation(tz)
+		confLimits := conf.Limits
+		maxAxis := confLimits.MaxAxis
+		maxAxisLarge := confLimits.MaxAxisLarge
+		maxAspectRatio := confLimits.MaxAspectRatio
+		large, errLarge := this.checkPermission(token, "render-large")
+
+		/*
+		 * Users allowed to render large images may exceed the regular
+		 * limit along each axis and are not limited in aspect ratio.
+		 */
+		if (errLarge == nil) && large {
+			maxAspectRatio = 0.0
+
+			/*
+			 * Only raise limit along each axis.
+			 */
+			if maxAxisLarge > maxAxis {
+				maxAxis = maxAxisLarge
+			}
+
+		}
+
+		/*
+		 * Ensure that resolution along X axis does not exceed limits.
+		 */
+		if xres > maxAxis {
+			xres = maxAxis
+		}
+
+		/*
+		 * Ensure that resolution along Y axis does not exceed limits.
+		 */
+		if yres > maxAxis {
+			yres = maxAxis
+		}
+
+		maxPixels := confLimits.MaxPixels
+		home := conf.Home
+		redact := request.Params["redact"]
+		redactHome := redact == "true"
+		withMap := request.Params["render-with-map"] == "true"
+		bgColorIn := request.Params["bgcolor"]
+		bgColor, bgOpaque, errBgColor := this.parseBackgroundColor(bgColorIn)
+		useMap := conf.UseMap
+		tilePerm, errTilePerm := this.checkPermission(token, "get-tile")
+		mayUseMap := (errTilePerm == nil) && tilePerm
+		intensityIn := request.Params["mapintensity"]
+		intensity := int64(MAP_INTENSITY_DEFAULT)
+		errIntensity := error(nil)
+
+		/*
+		 * Parse map intensity if it was provided.
+		 */
+		if intensityIn != "" {
+			intensity, errIntensity = strconv.ParseInt(intensityIn, 10, 8)
+		}
+
+		qualityIn := request.Params["quality"]
+		qualityIn64 := uint64(webp.QUALITY_LOSSLESS)
+		errQuality := error(nil)
+
+		/*
+		 * Parse image quality if it was provided.
+		 */
+		if qualityIn != "" {
+			qualityIn64, errQuality = strconv.ParseUint(qualityIn, 10, 8)
+		}
+
+		mode := request.Params["mode"]
+
+		/*
+		 * Use default render mode if none was provided.
+		 */
+		if mode == "" {
+			mode = defaults.Mode
+		}
+
+		/*
+		 * Render points if no default render mode is configured.
+		 */
+		if mode == "" {
+			mode = RENDER_MODE_POINTS
+		}
+
+		aspectRatio := 0.0
+
+		/*
+		 * Determine aspect ratio of the image, no matter if it is wide or
+		 * tall.
+		 */
+		if (xres > 0) && (yres > 0) {
+			xresFloat := float64(xres)
+			yresFloat := float64(yres)
+			longer := math.Max(xresFloat, yresFloat)
+			shorter := math.Min(xresFloat, yresFloat)
+			aspectRatio = longer / shorter
+		}
+
+		/*
+		 * Check if overall number of pixels and aspect ratio are within
+		 * limits.
+		 */
+		if resolution > maxPixels {
+			msg := fmt.Sprintf("Total number of pixels must not exceed %d.", maxPixels)
+			msgBuf := bytes.NewBufferString(msg)
+			msgBytes := msgBuf.Bytes()
+			confServer := conf.WebServer
+			contentType := confServer.ErrorMime
+
+			/*
+			 * Create HTTP response.
+			 */
+			response := webserver.HttpResponse{
+				Header: map[string]string{"Content-type": contentType},
+				Body:   msgBytes,
+			}
+
+			return response
+		} else if (maxAspectRatio > 0.0) && (aspectRatio > maxAspectRatio) {
+			msg := fmt.Sprintf("Aspect ratio must not exceed %g, but was %g.", maxAspectRatio, aspectRatio)
+			msgBuf := bytes.NewBufferString(msg)
+			msgBytes := msgBuf.Bytes()
+			confServer := conf.WebServer
+			contentType := confServer.ErrorMime
+
+			/*
+			 * Create HTTP response.
+			 */
+			response := webserver.HttpResponse{
+				Header: map[string]string{"Content-type": contentType},
+				Body:   msgBytes,
+			}
+
+			return response
+		} else if errTz != nil {
+			msg := fmt.Sprintf("Unknown time zone: '%s'", tz)
+			msgBuf := bytes.NewBufferString(msg)
+			msgBytes := msgBuf.Bytes()
+			confServer := conf.WebServer
+			contentType := confServer.ErrorMime
+
+			/*
+			 * Create HTTP response.
+			 */
+			response := webserver.HttpResponse{
+				Header: map[string]string{"Content-type": contentType},
+				Body:   msgBytes,
+			}
+
+			return response
+		} else if redactHome && (home.Radius <= 0.0) {
+			msgBuf := bytes.NewBufferString("Cannot redact home zone: No home zone configured.")
+			msgBytes := msgBuf.Bytes()
+			confServer := conf.WebServer
+			contentType := confServer.ErrorMime
+
+			/*
+			 * Create HTTP response.
+			 */
+			response := webserver.HttpResponse{
+				Header: map[string]string{"Content-type": contentType},
+				Body:   msgBytes,
+			}
+
+			return response
+		} else if withMap && !mayUseMap {
+			msgBuf := bytes.NewBufferString("Cannot render with map: Permission 'get-tile' is required.")
+			msgBytes := msgBuf.Bytes()
+			confServer := conf.WebServer
+			contentType := confServer.ErrorMime
+
+			/*
+			 * Create HTTP response.
+			 */
+			response := webserver.HttpResponse{
+				Header: map[string]string{"Content-type": contentType},
+				Body:   msgBytes,
+			}
+
+			return response
+		} else if withMap && !useMap {
+			msgBuf := bytes.NewBufferString("Cannot render with map: Server does not serve map tiles.")
+			msgBytes := msgBuf.Bytes()
+			confServer := conf.WebServer
+			contentType := confServer.ErrorMime
+
+			/*
+			 * Create HTTP response.
+			 */
+			response := webserver.HttpResponse{
+				Header: map[string]string{"Content-type": contentType},
+				Body:   msgBytes,
+			}
+
+			return response
+		} else if (errIntensity != nil) || (intensity < MAP_INTENSITY_ORIGINAL) || (intensity > MAP_INTENSITY_MAX) {
+			msg := fmt.Sprintf("Map intensity must be between %d and %d, but was '%s'.", MAP_INTENSITY_ORIGINAL, MAP_INTENSITY_MAX, intensityIn)
+			msgBuf := bytes.NewBufferString(msg)
+			msgBytes := msgBuf.Bytes()
+			confServer := conf.WebServer
+			contentType := confServer.ErrorMime
+
+			/*
+			 * Create HTTP response.
+			 */
+			response := webserver.HttpResponse{
+				Header: map[string]string{"Content-type": contentType},
+				Body:   msgBytes,
+			}
+
+			return response
+		} else if errBgColor != nil {
+			msg := errBgColor.Error()
+			msgBuf := bytes.NewBufferString(msg)
+			msgBytes := msgBuf.Bytes()
+			confServer := conf.WebServer
+			contentType := confServer.ErrorMime
+
+			/*
+			 * Create HTTP response.
+			 */
+			response := webserver.HttpResponse{
+				Header: map[string]string{"Content-type": contentType},
+				Body:   msgBytes,
+			}
+
+			return response
+		} else if (errQuality != nil) || (qualityIn64 > webp.QUALITY_LOSSLESS) {
+			msg := fmt.Sprintf("Quality must be between 0 and %d, but was '%s'.", webp.QUALITY_LOSSLESS, qualityIn)
+			msgBuf := bytes.NewBufferString(msg)
+			msgBytes := msgBuf.Bytes()
+			confServer := conf.WebServer
+			contentType := confServer.ErrorMime
+
+			/*
+			 * Create HTTP response.
+			 */
+			response := webserver.HttpResponse{
+				Header: map[string]string{"Content-type": contentType},
+				Body:   msgBytes,
+			}
+
+			return response
+		} else if (mode != RENDER_MODE_POINTS) && (mode != RENDER_MODE_LINES) {
+			msg := fmt.Sprintf("Render mode must be '%s' or '%s', but was '%s'.", RENDER_MODE_POINTS, RENDER_MODE_LINES, mode)
+			msgBuf := bytes.NewBufferString(msg)
+			msgBytes := msgBuf.Bytes()
+			confServer := conf.WebServer
+			contentType := confServer.ErrorMime
+
+			/*
+			 * Create HTTP response.
+			 */
+			response := webserver.HttpResponse{
+				Header: map[string]string{"Content-type": contentType},
+				Body:   msgBytes,
+			}
+
+			return response
+		} else {
+			xposIn := request.Params["xpos"]
+			xpos, _ := strconv.ParseFloat(xposIn, 64)
+			yposIn := request.Params["ypos"]
+			ypos, _ := strconv.ParseFloat(yposIn, 64)
+			zoomIn := request.Params["zoom"]
+			zoom := uint64(defaults.Zoom)
+
+			/*
+			 * Parse zoom level if it was provided.
+			 */
+			if zoomIn != "" {
+				zoom, _ = strconv.ParseUint(zoomIn, 10, 8)
+			}
+
+			now := time.Now()
+			minTimeIn := request.Params["mintime"]
+			minTime, _ := filter.ParseTimeRelative(minTimeIn, now, location)
+			maxTimeIn := request.Params["maxtime"]
+			maxTime, _ := filter.ParseTimeRelative(maxTimeIn, now, location)
+			fgColor := request.Params["fgcolor"]
+
+			/*
+			 * Use default color if none was provided.
+			 */
+			if fgColor == "" {
+				fgColor = defaults.FgColor
+			}
+
+			spreadIn := request.Params["spread"]
+			spread64 := uint64(defaults.Spread)
+
+			/*
+			 * Parse spread if it was provided.
+			 */
+			if spreadIn != "" {
+				spread64, _ = strconv.ParseUint(spreadIn, 10, 8)
+			}
+
+			spread := uint8(spread64)
+			maxGapIn := defaults.MaxGap
+			maxGap := time.Duration(0)
+
+			/*
+			 * Parse maximum gap between connected locations if it
+			 * was configured.
+			 */
+			if maxGapIn != "" {
+				maxGap, _ = time.ParseDuration(maxGapIn)
+			}
+
+			flt := filter.Filter(nil)
+			minTimeIsZero := minTime.IsZero()
+			maxTimeIsZero := maxTime.IsZero()
+
+			/*
+			 * Create filter if at least one of the limits is set.
+			 */
+			if !minTimeIsZero || !maxTimeIsZero {
+				flt = filter.Time(minTime, maxTime)
+			}
+
+			/*
+			 * Leave out locations within the home zone if requested, so
+			 * that they do not contribute to the density of the image.
+			 */
+			if redactHome {
+				homeLatitudeE7 := int32(math.Round(home.Latitude * 1e7))
+				homeLongitudeE7 := int32(math.Round(home.Longitude * 1e7))
+				homeRadius := home.Radius
+				homeFilter := filter.ExclusionZone(homeLatitudeE7, homeLongitudeE7, homeRadius)
+				flt = filter.And(flt, homeFilter)
+			}
+
+			ctx := request.Context
+			zoom8 := uint8(zoom)
+			db := partition.locationDB
+			target, err := this.renderScene(ctx, db, minTime, maxTime, xres, yres, xpos, ypos, zoom8, spread, mode, maxGap, fgColor, flt)
+
+			/*
+			 * Clear the home zone in the rendered image as well, since
+			 * lines and the spread of locations nearby may reach into
+			 * it.
+			 */
+			if redactHome && (err == nil) {
+				this.redactZone(target, xpos, ypos, zoom8, home.Latitude, home.Longitude, home.Radius)
+			}
+
+			/*
+			 * Draw the rendered locations on top of the map or of a
+			 * solid background if requested.
+			 */
+			if withMap && (err == nil) {
+				background, errMap := this.renderMap(xres, yres, xpos, ypos, zoom8, intensity)
+
+				/*
+				 * Only composite if map could be rendered.
+				 */
+				if errMap != nil {
+					err = errMap
+				} else {
+					bounds := target.Bounds()
+					draw.Draw(background, bounds, target, bounds.Min, draw.Over)
+					target = background
+				}
+
+			} else if bgOpaque && (err == nil) {
+				bounds := target.Bounds()
+				background := image.NewNRGBA(bounds)
+				uniform := image.NewUniform(bgColor)
+				draw.Draw(background, bounds, uniform, image.Point{}, draw.Src)
+				draw.Draw(background, bounds, target, bounds.Min, draw.Over)
+				target = background
+			}
+
+			this.configLock.RLock()
+			renderTimeout := this.renderTimeout
+			this.configLock.RUnlock()
+
+			/*
+			 * Check if image could be rendered.
+			 */
+			if err == context.DeadlineExceeded {
+				customMsg := fmt.Sprintf("Rendering took longer than %s and was aborted.", renderTimeout)
+				customMsgBuf := bytes.NewBufferString(customMsg)
+				customMsgBytes := customMsgBuf.Bytes()
+				conf := this.getConfig()
+				confServer := conf.WebServer
+				contentType := confServer.ErrorMime
+
+				/*
+				 * Create HTTP response.
+				 */
+				response := webserver.HttpResponse{
+					Header: map[string]string{"Content-type": contentType},
+					Body:   customMsgBytes,
+					Status: http.StatusServiceUnavailable,
+				}
+
+				return response
+			} else if err != nil {
+				msg := err.Error()
+				customMsg := fmt.Sprintf("Failed to render image: %s", msg)
+				customMsgBuf := bytes.NewBufferString(customMsg)
+				customMsgBytes := customMsgBuf.Bytes()
+				conf := this.getConfig()
+				confServer := conf.WebServer
+				contentType := confServer.ErrorMime
+
+				/*
+				 * Create HTTP response.
+				 */
+				response := webserver.HttpResponse{
+					Header: map[string]string{"Content-type": contentType},
+					Body:   customMsgBytes,
+				}
+
+				return response
+			} else {
+				save := request.Params["save"] == "true"
+				format := this.negotiateImageFormat(request)
+
+				/*
+				 * Saved images are always stored as PNG.
+				 */
+				if save {
+					format = IMAGE_FORMAT_PNG
+				}
+
+				quality := uint8(qualityIn64)
+				buf, mimeType, err := this.encodeImage(target, format, quality)
+
+				/*
+				 * Check if image could be encoded.
+				 */
+				if err != nil {
+					msg := err.Error()
+					customMsg := fmt.Sprintf("Failed to encode image: %s\n", msg)
+					customMsgBuf := bytes.NewBufferString(customMsg)
+					customMsgBytes := customMsgBuf.Bytes()
+					conf := this.getConfig()
+					confServer := conf.WebServer
+					contentType := confServer.ErrorMime
+
+					/*
+					 * Create HTTP response.
+					 */
+					response := webserver.HttpResponse{
+						Header: map[string]string{"Content-type": contentType},
+						Body:   customMsgBytes,
+					}
+
+					return response
+				} else if save {
+					response := this.saveImage(buf)
+					return response
+				} else {
+
+					/*
+					 * Create HTTP response.
+					 */
+					response := webserver.HttpResponse{
+						Header: map[string]string{"Content-type": mimeType, "Vary": "Accept"},
+						Body:   buf,
+					}
+
+					return response
+				}
+
+			}
+
+		}
+
+	}
+
+}
+
+/*
+ * Counts an anonymous render request against the rate limit.
+ *
+ * Requests are counted in windows of one minute. Returns false if the limit
+ * for the current window is already exhausted. A limit of zero means that the
+ * number of requests is not limited.
+ */
+func (this *controllerStruct) allowPublicRender(maxRequestsPerMinute uint32) bool {
+
+	/*
+	 * Check if rate limit is in place.
+	 */
+	if maxRequestsPerMinute == 0 {
+		return true
+	} else {
+		allowed := false
+		now := time.Now()
+		this.publicRenderLock.Lock()
+		windowStart := this.publicRenderWindow
+		elapsed := now.Sub(windowStart)
+
+		/*
+		 * Start a new window once the current one is over.
+		 */
+		if elapsed >= time.Minute {
+			this.publicRenderWindow = now
+			this.publicRenderCount = 0
+		}
+
+		/*
+		 * Count request if limit is not exhausted.
+		 */
+		if this.publicRenderCount < maxRequestsPerMinute {
+			this.publicRenderCount++
+			allowed = true
+		}
+
+		this.publicRenderLock.Unlock()
+		return allowed
+	}
+
+}
+
+/*
+ * Loads a watermark from a PNG file and stamps it onto the bottom right corner
+ * of an image.
+ */
+func (this *controllerStruct) stampWatermark(target *image.NRGBA, path string) error {
+
+	/*
+	 * Check if a watermark is configured.
+	 */
+	if path == "" {
+		return fmt.Errorf("%s", "No watermark configured.")
+	} else {
+		fd, err := os.Open(path)
+
+		/*
+		 * Check if watermark could be opened.
+		 */
+		if err != nil {
+			return fmt.Errorf("Failed to open watermark '%s'.", path)
+		} else {
+			watermark, err := png.Decode(fd)
+			fd.Close()
+
+			/*
+			 * Check if watermark could be decoded.
+			 */
+			if err != nil {
+				msg := err.Error()
+				return fmt.Errorf("Failed to decode watermark '%s': %s", path, msg)
+			} else {
+				bounds := target.Bounds()
+				watermarkBounds := watermark.Bounds()
+				width := watermarkBounds.Dx()
+				height := watermarkBounds.Dy()
+				x := bounds.Max.X - width - WATERMARK_MARGIN
+				y := bounds.Max.Y - height - WATERMARK_MARGIN
+
+				/*
+				 * Keep watermark inside the image horizontally.
+				 */
+				if x < bounds.Min.X {
+					x = bounds.Min.X
+				}
+
+				/*
+				 * Keep watermark inside the image vertically.
+				 */
+				if y < bounds.Min.Y {
+					y = bounds.Min.Y
+				}
+
+				position := image.Pt(x, y)
+				size := image.Pt(width, height)
+				area := image.Rectangle{
+					Min: position,
+					Max: position.Add(size),
+				}
+
+				draw.Draw(target, area, watermark, watermarkBounds.Min, draw.Over)
+				return nil
+			}
+
+		}
+
+	}
+
+}
+
+/*
+ * Render location data into an image for anonymous users.
+ *
+ * Resolution and zoom level are limited by the server, locations within the
+ * home zone are always left out, time filters are ignored and a watermark is
+ * stamped onto the image, regardless of the parameters sent by the client.
+ */
+func (this *controllerStruct) renderPublicHandler(request webserver.HttpRequest) webserver.HttpResponse {
+	conf := this.getConfig()
+	confServer := conf.WebServer
+	contentType := confServer.ErrorMime
+	public := conf.PublicRender
+	home := conf.Home
+
+	/*
+	 * Check if anonymous renders are allowed.
+	 */
+	if !public.Enabled {
+		msgBuf := bytes.NewBufferString("Public render is disabled.")
+		msgBytes := msgBuf.Bytes()
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType},
+			Body:   msgBytes,
+			Status: http.StatusForbidden,
+		}
+
+		return response
+	} else if home.Radius <= 0.0 {
+		msgBuf := bytes.NewBufferString("Public render requires a home zone to be configured.")
+		msgBytes := msgBuf.Bytes()
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType},
+			Body:   msgBytes,
+			Status: http.StatusServiceUnavailable,
+		}
+
+		return response
+	} else if !this.allowPublicRender(public.MaxRequestsPerMinute) {
+		msgBuf := bytes.NewBufferString("Too many public render requests. Try again later.")
+		msgBytes := msgBuf.Bytes()
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType, "Retry-After": "60"},
+			Body:   msgBytes,
+			Status: http.StatusTooManyRequests,
+		}
+
+		return response
+	} else {
+		defaults := conf.RenderDefaults
+		confLimits := conf.Limits
+		maxAxis := confLimits.MaxAxis
+
+		/*
+		 * Apply the stricter limit along each axis.
+		 */
+		if public.MaxAxis < maxAxis {
+			maxAxis = public.MaxAxis
+		}
+
+		xresIn := request.Params["xres"]
+		xres64 := uint64(defaults.XRes)
+
+		/*
+		 * Parse resolution along X axis if it was provided.
+		 */
+		if xresIn != "" {
+			xres64, _ = strconv.ParseUint(xresIn, 10, 16)
+		}
+
+		xres := uint32(xres64)
+		yresIn := request.Params["yres"]
+		yres64 := uint64(defaults.YRes)
+
+		/*
+		 * Parse resolution along Y axis if it was provided.
+		 */
+		if yresIn != "" {
+			yres64, _ = strconv.ParseUint(yresIn, 10, 16)
+		}
+
+		yres := uint32(yres64)
+
+		/*
+		 * Ensure that resolution along X axis does not exceed limits.
+		 */
+		if xres > maxAxis {
+			xres = maxAxis
+		}
+
+		/*
+		 * Ensure that resolution along Y axis does not exceed limits.
+		 */
+		if yres > maxAxis {
+			yres = maxAxis
+		}
+
+		xposIn := request.Params["xpos"]
+		xpos, _ := strconv.ParseFloat(xposIn, 64)
+		yposIn := request.Params["ypos"]
+		ypos, _ := strconv.ParseFloat(yposIn, 64)
+		zoomIn := request.Params["zoom"]
+		zoom64 := uint64(defaults.Zoom)
+
+		/*
+		 * Parse zoom level if it was provided.
+		 */
+		if zoomIn != "" {
+			zoom64, _ = strconv.ParseUint(zoomIn, 10, 8)
+		}
+
+		zoom := uint8(zoom64)
+
+		/*
+		 * Ensure that zoom level does not exceed limit.
+		 */
+		if zoom > public.MaxZoom {
+			zoom = public.MaxZoom
+		}
+
+		fgColor := request.Params["fgcolor"]
+
+		/*
+		 * Use default color if none was provided.
+		 */
+		if fgColor == "" {
+			fgColor = defaults.FgColor
+		}
+
+		spreadIn := request.Params["spread"]
+		spread64 := uint64(defaults.Spread)
+
+		/*
+		 * Parse spread if it was provided.
+		 */
+		if spreadIn != "" {
+			spread64, _ = strconv.ParseUint(spreadIn, 10, 8)
+		}
+
+		spread := uint8(spread64)
+		homeLatitudeE7 := int32(math.Round(home.Latitude * 1e7))
+		homeLongitudeE7 := int32(math.Round(home.Longitude * 1e7))
+		homeRadius := home.Radius
+		flt := filter.ExclusionZone(homeLatitudeE7, homeLongitudeE7, homeRadius)
+		ctx := request.Context
+		xres64 = uint64(xres)
+		yres64 = uint64(yres)
+		resolution := xres64 * yres64
+		maxPixels := confLimits.MaxPixels
+		target := (*image.NRGBA)(nil)
+		err := error(nil)
+
+		/*
+		 * Render only images of positive size within limits.
+		 */
+		if (xres == 0) || (yres == 0) {
+			err = fmt.Errorf("%s", "Resolution must be positive.")
+		} else if resolution > maxPixels {
+			err = fmt.Errorf("Total number of pixels must not exceed %d.", maxPixels)
+		} else {
+			sharedData := this.sharedData
+			db := sharedData.locationDB
+			zero := time.Time{}
+			target, err = this.renderScene(ctx, db, zero, zero, xres, yres, xpos, ypos, zoom, spread, RENDER_MODE_POINTS, 0, fgColor, flt)
+		}
+
+		/*
+		 * Stamp watermark onto rendered image.
+		 */
+		if err == nil {
+			watermark := public.Watermark
+			err = this.stampWatermark(target, watermark)
+		}
+
+		/*
+		 * Check if image could be rendered.
+		 */
+		if err == context.DeadlineExceeded {
+			this.configLock.RLock()
+			renderTimeout := this.renderTimeout
+			this.configLock.RUnlock()
+			customMsg := fmt.Sprintf("Rendering took longer than %s and was aborted.", renderTimeout)
+			customMsgBuf := bytes.NewBufferString(customMsg)
+			customMsgBytes := customMsgBuf.Bytes()
+
+			/*
+			 * Create HTTP response.
+			 */
+			response := webserver.HttpResponse{
+				Header: map[string]string{"Content-type": contentType},
+				Body:   customMsgBytes,
+				Status: http.StatusServiceUnavailable,
+			}
+
+			return response
+		} else if err != nil {
+			msg := err.Error()
+			customMsg := fmt.Sprintf("Failed to render image: %s", msg)
+			customMsgBuf := bytes.NewBufferString(customMsg)
+			customMsgBytes := customMsgBuf.Bytes()
+
+			/*
+			 * Create HTTP response.
+			 */
+			response := webserver.HttpResponse{
+				Header: map[string]string{"Content-type": contentType},
+				Body:   customMsgBytes,
+			}
+
+			return response
+		} else {
+			format := this.negotiateImageFormat(request)
+			buf, mimeType, err := this.encodeImage(target, format, webp.QUALITY_LOSSLESS)
+
+			/*
+			 * Check if image could be encoded.
+			 */
+			if err != nil {
+				msg := err.Error()
+				customMsg := fmt.Sprintf("Failed to encode image: %s", msg)
+				customMsgBuf := bytes.NewBufferString(customMsg)
+				customMsgBytes := customMsgBuf.Bytes()
+
+				/*
+				 * Create HTTP response.
+				 */
+				response := webserver.HttpResponse{
+					Header: map[string]string{"Content-type": contentType},
+					Body:   customMsgBytes,
+				}
+
+				return response
+			} else {
+
+				/*
+				 * Create HTTP response.
+				 */
+				response := webserver.HttpResponse{
+					Header: map[string]string{"Content-type": mimeType, "Vary": "Accept"},
+					Body:   buf,
+				}
+
+				return response
+			}
+
+		}
+
+	}
+
+}
+
+/*
+ * Handles CGI requests that could not be dispatched to other CGIs.
+ */
+func (this *controllerStruct) errorHandler(request webserver.HttpRequest) webserver.HttpResponse {
+	_ = request
+	conf := this.getConfig()
+	confServer := conf.WebServer
+	contentType := confServer.ErrorMime
+	msgBuf := bytes.NewBufferString("This CGI call is not implemented.")
+	msgBytes := msgBuf.Bytes()
+
+	/*
+	 * Create HTTP response.
+	 */
+	response := webserver.HttpResponse{
+		Header: map[string]string{"Content-type": contentType},
+		Body:   msgBytes,
+	}
+
+	return response
+}
+
+/*
+ * Handles requests to CGI endpoints which are disabled in the configuration.
+ */
+func (this *controllerStruct) endpointDisabledHandler(request webserver.HttpRequest) webserver.HttpResponse {
+	cgi := request.Params["cgi"]
+	reason := fmt.Sprintf("Endpoint disabled: '%s'", cgi)
+
+	/*
+	 * Indicate failure.
+	 */
+	webResponse := webResponseStruct{
+		Success: false,
+		Reason:  reason,
+	}
+
+	mimeType, buffer := this.createJSON(webResponse)
+
+	/*
+	 * Create HTTP response.
+	 */
+	response := webserver.HttpResponse{
+		Header: map[string]string{"Content-type": mimeType},
+		Body:   buffer,
+	}
+
+	return response
+}
+
+/*
+ * Checks whether the setup CGI may currently be used.
+ *
+ * Assumes that the caller holds the setup lock, if the result is used to
+ * decide whether to create a user.
+ */
+func (this *controllerStruct) isSetupAvailable() bool {
+	conf := this.getConfig()
+	setup := conf.Setup
+	mode := setup.Mode
+	umgr := this.userManager
+	users := umgr.Users()
+	numUsers := len(users)
+	result := (mode == SETUP_MODE_CGI) && (numUsers == 0)
+	return result
+}
+
+/*
+ * Checks whether a CGI endpoint is enabled in the configuration.
+ */
+func (this *controllerStruct) isEndpointEnabled(cgi string) bool {
+	conf := this.getConfig()
+	endpoints := conf.Endpoints
+	enabled := endpoints.Enabled
+	disabled := endpoints.Disabled
+	numEnabled := len(enabled)
+	result := numEnabled == 0
+
+	/*
+	 * Check if endpoint is explicitly enabled.
+	 */
+	for _, name := range enabled {
+
+		/*
+		 * Check if name matches endpoint.
+		 */
+		if name == cgi {
+			result = true
+		}
+
+	}
+
+	/*
+	 * Check if endpoint is explicitly disabled.
+	 */
+	for _, name := range disabled {
+
+		/*
+		 * Check if name matches endpoint.
+		 */
+		if name == cgi {
+			result = false
+		}
+
+	}
+
+	return result
+}
+
+/*
+ * Returns the CGI endpoints enabled on this server.
+ */
+func (this *controllerStruct) getCapabilitiesHandler(request webserver.HttpRequest) webserver.HttpResponse {
+	_ = request
+
+	/*
+	 * All endpoints known to dispatch.
+	 */
+	cgis := []string{
+		"add-activity",
+		"auth-logout",
+		"auth-request",
+		"auth-response",
+		"auth-response-public-key",
+		"download-activity-backup",
+		"download-geodb-content",
+		"download-geodb-yearly",
+		"export-activities-csv",
+		"export-activities-json",
+		"export-activities-tcx",
+		"get-activities",
+		"get-activity-statistics",
+		"get-activity-track",
+		"get-capabilities",
+		"get-geodb-bounds",
+		"get-geodb-distance",
+		"get-geodb-histogram",
+		"get-geodb-stats",
+		"get-render-defaults",
+		"get-saved-image",
+		"get-storage-stats",
+		"get-tile",
+		"get-tile-db-stats",
+		"get-tile-stats",
+		"import-activity-csv",
+		"import-activity-gpx",
+		"import-geodata",
+		"list-activity-backups",
+		"modify-geodata",
+		"remove-activities-range",
+		"remove-activity",
+		"render",
+		"render-public",
+		"replace-activity",
+		"setup",
+		"upload-begin",
+		"upload-chunk",
+		"upload-commit",
+		"verify-activities",
+	}
+
+	endpoints := []string{}
+
+	/*
+	 * Only report enabled endpoints.
+	 */
+	for _, cgi := range cgis {
+		enabled := this.isEndpointEnabled(cgi)
+
+		/*
+		 * The setup CGI is only available on a fresh installation.
+		 */
+		if cgi == "setup" {
+			setupAvailable := this.isSetupAvailable()
+			enabled = enabled && setupAvailable
+		}
+
+		/*
+		 * Check if endpoint is enabled.
+		 */
+		if enabled {
+			endpoints = append(endpoints, cgi)
+		}
+
+	}
+
+	/*
+	 * Indicate success.
+	 */
+	status := webResponseStruct{
+		Success: true,
+		Reason:  "",
+	}
+
+	conf := this.getConfig()
+	limits := conf.Limits
+
+	/*
+	 * Create capabilities.
+	 */
+	webCapabilities := webCapabilitiesStruct{
+		webResponseStruct:  status,
+		Endpoints:          endpoints,
+		MaxImportLocations: limits.MaxImportLocations,
+	}
+
+	mimeType, buffer := this.createJSON(webCapabilities)
+
+	/*
+	 * Create HTTP response.
+	 */
+	response := webserver.HttpResponse{
+		Header: map[string]string{"Content-type": mimeType},
+		Body:   buffer,
+	}
+
+	return response
+}
+
+/*
+ * Creates the first user on a fresh installation.
+ *
+ * This is only available if the setup CGI is enabled in the configuration and
+ * the user database is empty. Creating the first user disables it.
+ */
+func (this *controllerStruct) setupHandler(request webserver.HttpRequest) webserver.HttpResponse {
+	name := request.Params["name"]
+	password := request.Params["password"]
+	reason := ""
+	this.setupLock.Lock()
+	available := this.isSetupAvailable()
+
+	/*
+	 * Check if setup is available and parameters are valid.
+	 */
+	if !available {
+		reason = "Setup is not available."
+	} else if name == "" {
+		reason = "User name must not be empty."
+	} else if password == "" {
+		reason = "Password must not be empty."
+	} else {
+		err := this.createAdminUser(name, password)
+
+		/*
+		 * Check if administrator could be created.
+		 */
+		if err != nil {
+			reason = err.Error()
+		}
+
+	}
+
+	this.setupLock.Unlock()
+	success := reason == ""
+
+	/*
+	 * Indicate success or failure.
+	 */
+	webResponse := webResponseStruct{
+		Success: success,
+		Reason:  reason,
+	}
+
+	mimeType, buffer := this.createJSON(webResponse)
+
+	/*
+	 * Create HTTP response.
+	 */
+	response := webserver.HttpResponse{
+		Header: map[string]string{"Content-type": mimeType},
+		Body:   buffer,
+	}
+
+	return response
+}
+
+/*
+ * Begin a chunked upload of location data.
+ */
+func (this *controllerStruct) uploadBeginHandler(request webserver.HttpRequest) webserver.HttpResponse {
+	token := request.Params["token"]
+	result := webUploadStruct{}
+	perm, err := this.checkPermission(token, "geodb-write")
+
+	/*
+	 * Check permissions.
+	 */
+	if err != nil {
+		msg := err.Error()
+		reason := fmt.Sprintf("Failed to check permission: %s", msg)
+
+		/*
+		 * Indicate failure.
+		 */
+		result.webResponseStruct = webResponseStruct{
+			Success: false,
+			Reason:  reason,
+		}
+
+	} else if !perm {
+
+		/*
+		 * Indicate failure.
+		 */
+		result.webResponseStruct = webResponseStruct{
+			Success: false,
+			Reason:  "Forbidden!",
+		}
+
+	} else {
+		this.removeExpiredUploads()
+		user, errUser := this.tokenUserName(token)
+		r := rand.SystemPRNG()
+		idBytes := make([]byte, SIZE_UPLOAD_ID)
+		_, errRand := r.Read(idBytes)
+
+		/*
+		 * Check if user and upload id could be determined.
+		 */
+		if errUser != nil {
+			msg := errUser.Error()
+			reason := fmt.Sprintf("Failed to determine user: %s", msg)
+
+			/*
+			 * Indicate failure.
+			 */
+			result.webResponseStruct = webResponseStruct{
+				Success: false,
+				Reason:  reason,
+			}
+
+		} else if errRand != nil {
+
+			/*
+			 * Indicate failure.
+			 */
+			result.webResponseStruct = webResponseStruct{
+				Success: false,
+				Reason:  "Failed to obtain entropy from system.",
+			}
+
+		} else {
+			fd, err := os.CreateTemp("", "locviz-upload-*")
+
+			/*
+			 * Check if temporary file could be created.
+			 */
+			if err != nil {
+				msg := err.Error()
+				reason := fmt.Sprintf("Failed to create temporary file: %s", msg)
+
+				/*
+				 * Indicate failure.
+				 */
+				result.webResponseStruct = webResponseStruct{
+					Success: false,
+					Reason:  reason,
+				}
+
+			} else {
+				id := hex.EncodeToString(idBytes)
+				now := time.Now()
+				_, maxUploads := this.uploadLimits()
+
+				/*
+				 * Create upload.
+				 */
+				upload := uploadStruct{
+					fd:           fd,
+					lastActivity: now,
+					size:         0,
+					user:         user,
+				}
+
+				this.uploadsLock.Lock()
+
+				/*
+				 * Create map of uploads if it does not exist yet.
+				 */
+				if this.uploads == nil {
+					this.uploads = make(map[string]*uploadStruct)
+				}
+
+				numUploads := 0
+
+				/*
+				 * Count the uploads of this user.
+				 */
+				for _, other := range this.uploads {
+
+					/*
+					 * Check if upload belongs to this user.
+					 */
+					if other.user == user {
+						numUploads++
+					}
+
+				}
+
+				/*
+				 * Limit the number of uploads per user.
+				 */
+				if numUploads >= maxUploads {
+					this.uploadsLock.Unlock()
+					this.discardUpload(&upload)
+					reason := fmt.Sprintf("There cannot be more than %d uploads per user. Commit or wait for uploads to expire first.", maxUploads)
+
+					/*
+					 * Indicate failure.
+					 */
+					result.webResponseStruct = webResponseStruct{
+						Success: false,
+						Reason:  reason,
+					}
+
+				} else {
+					this.uploads[id] = &upload
+					this.uploadsLock.Unlock()
+
+					/*
+					 * Indicate success.
+					 */
+					result.webResponseStruct = webResponseStruct{
+						Success: true,
+						Reason:  "",
+					}
+
+					result.Id = id
+				}
+
+			}
+
+		}
+
+	}
+
+	mimeType, buffer := this.createJSON(result)
+
+	/*
+	 * Create HTTP response.
+	 */
+	response := webserver.HttpResponse{
+		Header: map[string]string{"Content-type": mimeType},
+		Body:   buffer,
+	}
+
+	return response
+}
+
+/*
+ * Write a chunk of data to a chunked upload.
+ *
+ * The offset must not exceed the number of bytes received so far, so that
+ * chunks can be sent again if a response was lost.
+ */
+func (this *controllerStruct) uploadChunkHandler(request webserver.HttpRequest) webserver.HttpResponse {
+	token := request.Params["token"]
+	id := request.Params["id"]
+	result := webUploadStruct{}
+	result.Id = id
+	perm, err := this.checkPermission(token, "geodb-write")
+
+	/*
+	 * Check permissions.
+	 */
+	if err != nil {
+		msg := err.Error()
+		reason := fmt.Sprintf("Failed to check permission: %s", msg)
+
+		/*
+		 * Indicate failure.
+		 */
+		result.webResponseStruct = webResponseStruct{
+			Success: false,
+			Reason:  reason,
+		}
+
+	} else if !perm {
+
+		/*
+		 * Indicate failure.
+		 */
+		result.webResponseStruct = webResponseStruct{
+			Success: false,
+			Reason:  "Forbidden!",
+		}
+
+	} else {
+		this.removeExpiredUploads()
+		user, errUser := this.tokenUserName(token)
+		upload, errUpload := this.getUpload(id, user)
+		offsetString := request.Params["offset"]
+		offset, errOffset := strconv.ParseInt(offsetString, 10, 64)
+		files := request.Files["file"]
+		numFiles := len(files)
+
+		/*
+		 * Check if upload exists and chunk is valid.
+		 */
+		if errUser != nil {
+			msg := errUser.Error()
+			reason := fmt.Sprintf("Failed to determine user: %s", msg)
+
+			/*
+			 * Indicate failure.
+			 */
+			result.webResponseStruct = webResponseStruct{
+				Success: false,
+				Reason:  reason,
+			}
+
+		} else if errUpload != nil {
+			msg := errUpload.Error()
+
+			/*
+			 * Indicate failure.
+			 */
+			result.webResponseStruct = webResponseStruct{
+				Success: false,
+				Reason:  msg,
+			}
+
+		} else if (errOffset != nil) || (offset < 0) {
+			reason := fmt.Sprintf("Offset must be a non-negative integer, but was '%s'.", offsetString)
+
+			/*
+			 * Indicate failure.
+			 */
+			result.webResponseStruct = webResponseStruct{
+				Success: false,
+				Reason:  reason,
+			}
+
+		} else if numFiles != 1 {
+
+			/*
+			 * Indicate failure.
+			 */
+			result.webResponseStruct = webResponseStruct{
+				Success: false,
+				Reason:  "Expected exactly one file in field 'file'.",
+			}
+
+		} else {
+			file := files[0]
+			data, err := io.ReadAll(file)
+			dataSize := len(data)
+			dataSize64 := int64(dataSize)
+			maxSize, _ := this.uploadLimits()
+			upload.mutex.Lock()
+			size := upload.size
+			fd := upload.fd
+
+			/*
+			 * Check if chunk could be read and fits the upload.
+			 */
+			if err != nil {
+
+				/*
+				 * Indicate failure.
+				 */
+				result.webResponseStruct = webResponseStruct{
+					Success: false,
+					Reason:  "Failed to read chunk.",
+				}
+
+			} else if fd == nil {
+				reason := fmt.Sprintf("No upload with id '%s'.", id)
+
+				/*
+				 * Indicate failure.
+				 */
+				result.webResponseStruct = webResponseStruct{
+					Success: false,
+					Reason:  reason,
+				}
+
+			} else if offset > size {
+				reason := fmt.Sprintf("Offset %d exceeds the %d bytes received so far.", offset, size)
+
+				/*
+				 * Indicate failure.
+				 */
+				result.webResponseStruct = webResponseStruct{
+					Success: false,
+					Reason:  reason,
+				}
+
+			} else if dataSize64 > (maxSize - offset) {
+				reason := fmt.Sprintf("Upload would exceed the maximum size of %d bytes.", maxSize)
+
+				/*
+				 * Indicate failure.
+				 */
+				result.webResponseStruct = webResponseStruct{
+					Success: false,
+					Reason:  reason,
+				}
+
+			} else {
+				n, err := fd.WriteAt(data, offset)
+				n64 := int64(n)
+				end := offset + n64
+
+				/*
+				 * Data written may extend the upload.
+				 */
+				if end > size {
+					size = end
+					upload.size = size
+				}
+
+				upload.lastActivity = time.Now()
+
+				/*
+				 * Check if chunk could be written.
+				 */
+				if err != nil {
+					msg := err.Error()
+					reason := fmt.Sprintf("Failed to write chunk: %s", msg)
+
+					/*
+					 * Indicate failure.
+					 */
+					result.webResponseStruct = webResponseStruct{
+						Success: false,
+						Reason:  reason,
+					}
+
+				} else {
+
+					/*
+					 * Indicate success.
+					 */
+					result.webResponseStruct = webResponseStruct{
+						Success: true,
+						Reason:  "",
+					}
+
+				}
+
+			}
+
+			result.Size = size
+			upload.mutex.Unlock()
+		}
+
+	}
+
+	mimeType, buffer := this.createJSON(result)
+
+	/*
+	 * Create HTTP response.
+	 */
+	response := webserver.HttpResponse{
+		Header: map[string]string{"Content-type": mimeType},
+		Body:   buffer,
+	}
+
+	return response
+}
+
+/*
+ * Finish a chunked upload and import the assembled file into the location
+ * database.
+ *
+ * If a size is provided, the upload is only imported if it matches the number
+ * of bytes received. Otherwise, the upload is kept, so that missing chunks
+ * can still be sent.
+ */
+func (this *controllerStruct) uploadCommitHandler(request webserver.HttpRequest) webserver.HttpResponse {
+	token := request.Params["token"]
+	id := request.Params["id"]
+	migrationReport := webMigrationReportStruct{}
+	perm, err := this.checkPermission(token, "geodb-write")
+	partition, errPartition := this.requestPartition(request)
+
+	/*
+	 * Check permissions.
+	 */
+	if err != nil {
+		msg := err.Error()
+		reason := fmt.Sprintf("Failed to check permission: %s", msg)
+
+		/*
+		 * Indicate failure.
+		 */
+		migrationReport.Status = webResponseStruct{
+			Success: false,
+			Reason:  reason,
+		}
+
+	} else if !perm {
+
+		/*
+		 * Indicate failure.
+		 */
+		migrationReport.Status = webResponseStruct{
+			Success: false,
+			Reason:  "Forbidden!",
+		}
+
+	} else if errPartition != nil {
+		msg := errPartition.Error()
+		reason := fmt.Sprintf("Failed to access data: %s", msg)
+
+		/*
+		 * Indicate failure.
+		 */
+		migrationReport.Status = webResponseStruct{
+			Success: false,
+			Reason:  reason,
+		}
+
+	} else {
+		this.removeExpiredUploads()
+		user, errUser := this.tokenUserName(token)
+		upload, errUpload := this.getUpload(id, user)
+		sizeString := request.Params["size"]
+		expectedSize := int64(-1)
+		errSize := error(nil)
+
+		/*
+		 * Parse expected size if it was provided.
+		 */
+		if sizeString != "" {
+			expectedSize, errSize = strconv.ParseInt(sizeString, 10, 64)
+		}
+
+		/*
+		 * Check if upload exists and size is valid.
+		 */
+		if errUser != nil {
+			msg := errUser.Error()
+			reason := fmt.Sprintf("Failed to determine user: %s", msg)
+
+			/*
+			 * Indicate failure.
+			 */
+			migrationReport.Status = webResponseStruct{
+				Success: false,
+				Reason:  reason,
+			}
+
+		} else if errUpload != nil {
+			msg := errUpload.Error()
+
+			/*
+			 * Indicate failure.
+			 */
+			migrationReport.Status = webResponseStruct{
+				Success: false,
+				Reason:  msg,
+			}
+
+		} else if (errSize != nil) || (expectedSize < -1) {
+			reason := fmt.Sprintf("Size must be a non-negative integer, but was '%s'.", sizeString)
+
+			/*
+			 * Indicate failure.
+			 */
+			migrationReport.Status = webResponseStruct{
+				Success: false,
+				Reason:  reason,
+			}
+
+		} else {
+			upload.mutex.Lock()
+			size := upload.size
+			fd := upload.fd
+
+			/*
+			 * Check if upload is complete.
+			 */
+			if fd == nil {
+				upload.mutex.Unlock()
+				reason := fmt.Sprintf("No upload with id '%s'.", id)
+
+				/*
+				 * Indicate failure.
+				 */
+				migrationReport.Status = webResponseStruct{
+					Success: false,
+					Reason:  reason,
+				}
+
+			} else if (expectedSize >= 0) && (expectedSize != size) {
+				upload.mutex.Unlock()
+				reason := fmt.Sprintf("Expected %d bytes, but only %d bytes were received.", expectedSize, size)
+
+				/*
+				 * Indicate failure.
+				 */
+				migrationReport.Status = webResponseStruct{
+					Success: false,
+					Reason:  reason,
+				}
+
+			} else {
+
+				/*
+				 * Detach the temporary file from the upload, so that no
+				 * more chunks are written to it while it is imported.
+				 */
+				upload.fd = nil
+				upload.mutex.Unlock()
+				this.uploadsLock.Lock()
+				delete(this.uploads, id)
+				this.uploadsLock.Unlock()
+				r := io.NewSectionReader(fd, 0, size)
+				ctx := request.Context
+				format := request.Params["format"]
+				strategy := request.Params["strategy"]
+				defaultTimeIn := request.Params["defaulttime"]
+				migrationReport = this.importGeoData(ctx, partition, r, format, strategy, defaultTimeIn)
+				path := fd.Name()
+				fd.Close()
+				os.Remove(path)
+			}
+
+		}
+
+	}
+
+	mimeType, buffer := this.createJSON(migrationReport)
+
+	/*
+	 * Create HTTP response.
+	 */
+	response := webserver.HttpResponse{
+		Header: map[string]string{"Content-type": mimeType},
+		Body:   buffer,
+	}
+
+	return response
+}
+
+/*
+ * Verify the integrity of activity data.
+ */
+func (this *controllerStruct) verifyActivitiesHandler(request webserver.HttpRequest) webserver.HttpResponse {
+	token := request.Params["token"]
+	perm, err := this.checkPermission(token, "activity-read")
+	partition, errPartition := this.requestPartition(request)
+
+	/*
+	 * Check permissions.
+	 */
+	if err != nil {
+		msg := err.Error()
+		customMsg := fmt.Sprintf("Failed to check permission: %s", msg)
+		customMsgBuf := bytes.NewBufferString(customMsg)
+		customMsgBytes := customMsgBuf.Bytes()
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType},
+			Body:   customMsgBytes,
+		}
+
+		return response
+	} else if !perm {
+		customMsgBuf := bytes.NewBufferString("Forbidden!")
+		customMsgBytes := customMsgBuf.Bytes()
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType},
+			Body:   customMsgBytes,
+		}
+
+		return response
+	} else if errPartition != nil {
+		msg := errPartition.Error()
+		customMsg := fmt.Sprintf("Failed to access data: %s", msg)
+		customMsgBuf := bytes.NewBufferString(customMsg)
+		customMsgBytes := customMsgBuf.Bytes()
+		conf := this.getConfig()
+		confServer := conf.WebServer
+		contentType := confServer.ErrorMime
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": contentType},
+			Body:   customMsgBytes,
+		}
+
+		return response
+	} else {
+		partition.activitiesLock.RLock()
+		activities := partition.activities
+		report := activities.Verify()
+		partition.activitiesLock.RUnlock()
+		issues := report.Issues
+		numIssues := len(issues)
+		webIssues := make([]webActivityVerificationIssueStruct, numIssues)
+
+		/*
+		 * Convert issues into web representation.
+		 */
+		for i, issue := range issues {
+			begin := issue.Begin
+			beginString := begin.Format(time.RFC3339)
+
+			/*
+			 * Create web representation of issue.
+			 */
+			webIssues[i] = webActivityVerificationIssueStruct{
+				Group:  issue.Group,
+				Begin:  beginString,
+				Reason: issue.Reason,
+			}
+
+		}
+
+		/*
+		 * Indicate success.
+		 */
+		status := webResponseStruct{
+			Success: true,
+			Reason:  "",
+		}
+
+		/*
+		 * Create verification report.
+		 */
+		result := webActivityVerificationReportStruct{
+			webResponseStruct: status,
+			GroupCount:        report.GroupCount,
+			Valid:             numIssues == 0,
+			Issues:            webIssues,
+		}
+
+		mimeType, buffer := this.createJSON(result)
+
+		/*
+		 * Create HTTP response.
+		 */
+		response := webserver.HttpResponse{
+			Header: map[string]string{"Content-type": mimeType},
+			Body:   buffer,
+		}
+
+		return response
+	}
+
+}
+
+/*
+ * Dispatch CGI requests to the corresponding CGI handlers.
+ */
+func (this *controllerStruct) dispatch(request webserver.HttpRequest) webserver.HttpResponse {
+	cgi := request.Params["cgi"]
+	response := webserver.HttpResponse{}
+	enabled := this.isEndpointEnabled(cgi)
+
+	/*
+	 * Reject requests to disabled endpoints before routing them.
+	 */
+	if !enabled {
+		response = this.endpointDisabledHandler(request)
+	} else {
+
+		/*
+		 * Find the right CGI to handle the request.
+		 */
+		switch cgi {
+		case "add-activity":
+			response = this.addActivityHandler(request)
+		case "auth-logout":
+			response = this.authLogoutHandler(request)
+		case "auth-request":
+			response = this.authRequestHandler(request)
+		case "auth-response":
+			response = this.authResponseHandler(request)
+		case "auth-response-public-key":
+			response = this.authResponsePublicKeyHandler(request)
+		case "download-activity-backup":
+			response = this.downloadActivityBackupHandler(request)
+		case "download-geodb-content":
+			response = this.downloadGeoDBContentHandler(request)
+		case "download-geodb-yearly":
+			response = this.downloadGeoDBYearlyHandler(request)
+		case "export-activities-csv":
+			response = this.exportActivitiesCsvHandler(request)
+		case "export-activities-json":
+			response = this.exportActivitiesJsonHandler(request)
+		case "export-activities-tcx":
+			response = this.exportActivitiesTcxHandler(request)
+		case "get-activities":
+			response = this.getActivitiesHandler(request)
+		case "get-activity-statistics":
+			response = this.getActivityStatisticsHandler(request)
+		case "get-activity-track":
+			response = this.getActivityTrackHandler(request)
+		case "get-capabilities":
+			response = this.getCapabilitiesHandler(request)
+		case "get-geodb-bounds":
+			response = this.getGeoDBBoundsHandler(request)
+		case "get-geodb-distance":
+			response = this.getGeoDBDistanceHandler(request)
+		case "get-geodb-histogram":
+			response = this.getGeoDBHistogramHandler(request)
+		case "get-geodb-stats":
+			response = this.getGeoDBStatsHandler(request)
+		case "get-render-defaults":
+			response = this.getRenderDefaultsHandler(request)
+		case "get-saved-image":
+			response = this.getSavedImageHandler(request)
+		case "get-storage-stats":
+			response = this.getStorageStatsHandler(request)
+		case "get-tile":
+			this.configLock.RLock()
+			sem := this.semTile
+			this.configLock.RUnlock()
+			this.acquire(sem)
+			response = this.getTileHandler(request)
+			this.release(sem)
+		case "get-tile-db-stats":
+			response = this.getTileDBStatsHandler(request)
+		case "get-tile-stats":
+			response = this.getTileStatsHandler(request)
+		case "import-activity-csv":
+			response = this.importActivityCsvHandler(request)
+		case "import-activity-gpx":
+			response = this.importActivityGpxHandler(request)
+		case "import-geodata":
+			response = this.importGeoDataHandler(request)
+		case "list-activity-backups":
+			response = this.listActivityBackupsHandler(request)
+		case "modify-geodata":
+			response = this.modifyGeoDataHandler(request)
+		case "remove-activities-range":
+			response = this.removeActivitiesRangeHandler(request)
+		case "remove-activity":
+			response = this.removeActivityHandler(request)
+		case "replace-activity":
+			response = this.replaceActivityHandler(request)
+		case "render":
+			this.configLock.RLock()
+			sem := this.semRender
+			this.configLock.RUnlock()
+			this.acquire(sem)
+			response = this.renderHandler(request)
+			this.release(sem)
+		case "render-public":
+			this.configLock.RLock()
+			sem := this.semRender
+			this.configLock.RUnlock()
+			this.acquire(sem)
+			response = this.renderPublicHandler(request)
+			this.release(sem)
+		case "setup":
+			response = this.setupHandler(request)
+		case "upload-begin":
+			response = this.uploadBeginHandler(request)
+		case "upload-chunk":
+			response = this.uploadChunkHandler(request)
+		case "upload-commit":
+			response = this.uploadCommitHandler(request)
+		case "verify-activities":
+			response = this.verifyActivitiesHandler(request)
+		default:
+			response = this.errorHandler(request)
+		}
+
+	}
+
+	return response
+}
+
+/*
+ * Determines the directory holding the backups of the activity database of a
+ * data partition, as well as the prefix and suffix of their file names.
+ */
+func (this *controllerStruct) activityBackupPattern(partition *dataPartitionStruct) (string, string, string) {
+	path := partition.activityDBPath
+	dir := filepath.Dir(path)
+	base := filepath.Base(path)
+	suffix := filepath.Ext(base)
+	stem := strings.TrimSuffix(base, suffix)
+	prefix := stem + "-"
+	return dir, prefix, suffix
+}
+
+/*
+ * Lists the backups of the activity database of a data partition, newest
+ * first.
+ */
+func (this *controllerStruct) listActivityBackups(partition *dataPartitionStruct) ([]activityBackupStruct, error) {
+	dir, prefix, suffix := this.activityBackupPattern(partition)
+	entries, err := os.ReadDir(dir)
+
+	/*
+	 * Check if directory could be read.
+	 */
+	if err != nil {
+		msg := err.Error()
+		return nil, fmt.Errorf("Failed to list backups of activity database: %s", msg)
+	} else {
+		backups := []activityBackupStruct{}
+
+		/*
+		 * Find the backups among the files beside the activity database.
+		 */
+		for _, entry := range entries {
+			name := entry.Name()
+			isDir := entry.IsDir()
+			hasPrefix := strings.HasPrefix(name, prefix)
+			hasSuffix := strings.HasSuffix(name, suffix)
+			numName := len(name)
+			numPrefix := len(prefix)
+			numSuffix := len(suffix)
+
+			/*
+			 * Only consider files named after the activity database.
+			 */
+			if !isDir && hasPrefix && hasSuffix && (numName > numPrefix+numSuffix) {
+				timeStamp := name[numPrefix : numName-numSuffix]
+				created, err := time.ParseInLocation(ARCHIVE_TIME_STAMP, timeStamp, time.Local)
+
+				/*
+				 * Only consider files carrying a time stamp.
+				 */
+				if err == nil {
+					path := filepath.Join(dir, name)
+					size := int64(-1)
+					info, err := entry.Info()
+
+					/*
+					 * Check if file size could be determined.
+					 */
+					if err == nil {
+						size = info.Size()
+					}
+
+					/*
+					 * Create backup information.
+					 */
+					backup := activityBackupStruct{
+						created: created,
+						name:    name,
+						path:    path,
+						size:    size,
+					}
+
+					backups = append(backups, backup)
+				}
+
+			}
+
+		}
+
+		/*
+		 * Order backups from newest to oldest.
+		 */
+		sort.SliceStable(backups, func(i int, j int) bool {
+			createdI := backups[i].created
+			createdJ := backups[j].created
+			return createdI.After(createdJ)
+		})
+
+		return backups, nil
+	}
+
+}
+
+/*
+ * Removes the oldest backups of the activity database of a data partition,
+ * keeping the configured number of backups.
+ */
+func (this *controllerStruct) pruneActivityBackups(partition *dataPartitionStruct) error {
+	config := this.getConfig()
+	keep := config.ActivityBackups
+	backups, err := this.listActivityBackups(partition)
+
+	/*
+	 * Check if backups could be listed.
+	 */
+	if err != nil {
+		return err
+	} else {
+		errResult := error(nil)
+
+		/*
+		 * Remove all backups beyond the configured number.
+		 */
+		for i, backup := range backups {
+
+			/*
+			 * Check if backup is to be removed.
+			 */
+			if uint64(i) >= uint64(keep) {
+				path := backup.path
+				err := os.Remove(path)
+
+				/*
+				 * Check if this is the first error.
+				 */
+				if (err != nil) && (errResult == nil) {
+					msg := err.Error()
+					errResult = fmt.Errorf("Failed to remove backup of activity database: %s", msg)
+				}
+
+			}
+
+		}
+
+		return errResult
+	}
+
+}
+
+/*
+ * Writes a backup of the activity database of a data partition, as held in
+ * memory, into a file with a time stamp beside the activity database.
+ *
+ * This is called before the activities are modified, so the activities must
+ * be locked. If a backup was already written within the same second, it is
+ * kept, since it holds an older state.
+ */
+func (this *controllerStruct) backupActivityDB(partition *dataPartitionStruct) error {
+	config := this.getConfig()
+	keep := config.ActivityBackups
+
+	/*
+	 * Check if backups are enabled.
+	 */
+	if keep == 0 {
+		return nil
+	} else {
+		act := partition.activities
+		buf, err := act.Export()
+
+		/*
+		 * Check if export failed.
+		 */
+		if err != nil {
+			msg := err.Error()
+			return fmt.Errorf("Error serializing activity database: %s", msg)
+		} else {
+			dir, prefix, suffix := this.activityBackupPattern(partition)
+			now := time.Now()
+			timeStamp := now.Format(ARCHIVE_TIME_STAMP)
+			name := prefix + timeStamp + suffix
+			path := filepath.Join(dir, name)
+			mode := os.ModeExclusive | (os.ModePerm & PERMISSIONS_ACTIVITYDB)
+			fd, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode)
+
+			/*
+			 * Keep a backup written within the same second and write
+			 * the new backup otherwise.
+			 */
+			if os.IsExist(err) {
+				err = nil
+			} else if err == nil {
+				_, err = fd.Write(buf)
+
+				/*
+				 * Make sure data reaches the disk.
+				 */
+				if err == nil {
+					err = fd.Sync()
+				}
+
+				errClose := fd.Close()
+
+				/*
+				 * Report the first error that occured.
+				 */
+				if err == nil {
+					err = errClose
+				}
+
+				/*
+				 * Do not keep incomplete backups.
+				 */
+				if err != nil {
+					os.Remove(path)
+				}
+
+			}
+
+			/*
+			 * Check if backup was written.
+			 */
+			if err != nil {
+				msg := err.Error()
+				return fmt.Errorf("Error writing backup of activity database: %s", msg)
+			} else {
+				err = this.pruneActivityBackups(partition)
+
+				/*
+				 * Failing to remove old backups must not prevent
+				 * modifications.
+				 */
+				if err != nil {
+					msg := err.Error()
+					fmt.Printf("%s\n", msg)
+				}
+
+				return nil
+			}
+
+		}
+
+	}
+
+}
+
+/*
+ * Write activity database of a data partition to disk.
+ */
+func (this *controllerStruct) writeActivityDB(partition *dataPartitionStruct) error {
+	act := partition.activities
+	buf, err := act.Export()
+
+	/*
+	 * Check if export failed.
+	 */
+	if err != nil {
+		msg := err.Error()
+		return fmt.Errorf("Error serializing activity database: %s", msg)
+	} else {
+		path := partition.activityDBPath
+		partition.activitiesWriteLock.Lock()
+		mode := os.ModeExclusive | (os.ModePerm & PERMISSIONS_ACTIVITYDB)
+		err := os.WriteFile(path, buf, mode)
+		partition.activitiesWriteLock.Unlock()
+
+		/*
+		 * Check if something went wrong.
+		 */
+		if err != nil {
+			msg := err.Error()
+			return fmt.Errorf("Error synchronizing activity database: %s", msg)
+		} else {
+			return nil
+		}
+
+	}
+
+}
+
+/*
+ * Closes the location databases of all data partitions and the tile
+ * databases, syncing pending writes to disk.
+ *
+ * All databases are closed, even if closing one of them fails. Returns the
+ * first error that occured.
+ */
+func (this *controllerStruct) closeDatabases() error {
+	errResult := error(nil)
+	partitions := this.partitions()
+
+	/*
+	 * Close the location database of each data partition.
+	 */
+	for _, partition := range partitions {
+		locationDB := partition.locationDB
+
+		/*
+		 * Close location database if it was opened.
+		 */
+		if locationDB != nil {
+			err := locationDB.Close()
+
+			/*
+			 * Check if this is the first error.
+			 */
+			if (err != nil) && (errResult == nil) {
+				errResult = err
+			}
+
+		}
+
+	}
+
+	tileUtil := this.tileUtil
+	indexDB := this.indexDatabase
+	errTile := error(nil)
+
+	/*
+	 * Close tile databases through the tile util if there is one, so
+	 * that no tile is stored while they are closed.
+	 */
+	if tileUtil != nil {
+		errTile = tileUtil.Close()
+	} else if indexDB != nil {
+		errTile = indexDB.Close()
+	}
+
+	/*
+	 * Check if this is the first error.
+	 */
+	if (errTile != nil) && (errResult == nil) {
+		errResult = errTile
+	}
+
+	return errResult
+}
+
+/*
+ * Closes the location and tile databases and prints an error if this fails.
+ */
+func (this *controllerStruct) closeDatabasesAndReport() {
+	err := this.closeDatabases()
+
+	/*
+	 * Check if databases could be closed.
+	 */
+	if err != nil {
+		msg := err.Error()
+		fmt.Printf("Failed to close databases: %s\n", msg)
+	}
+
+}
+
+/*
+ * Writes the activity database of a data partition to disk if it was modified
+ * since it was last written.
+ */
+func (this *controllerStruct) flushActivityDB(partition *dataPartitionStruct) error {
+	partition.activitiesDirtyLock.Lock()
+	dirty := partition.activitiesDirty
+	partition.activitiesDirty = false
+	partition.activitiesDirtyLock.Unlock()
+
+	/*
+	 * Only write activity database if it was modified.
+	 */
+	if !dirty {
+		return nil
+	} else {
+		err := this.writeActivityDB(partition)
+
+		/*
+		 * If write failed, retry on next flush.
+		 */
+		if err != nil {
+			partition.activitiesDirtyLock.Lock()
+			partition.activitiesDirty = true
+			partition.activitiesDirtyLock.Unlock()
+		}
+
+		return err
+	}
+
+}
+
+/*
+ * Writes the activity databases of all data partitions to disk, which were
+ * modified since they were last written.
+ *
+ * All activity databases are written, even if writing one of them fails.
+ * Returns the first error that occured.
+ */
+func (this *controllerStruct) flushActivityDBs() error {
+	errResult := error(nil)
+	partitions := this.partitions()
+
+	/*
+	 * Flush the activity database of each data partition.
+	 */
+	for _, partition := range partitions {
+		err := this.flushActivityDB(partition)
+
+		/*
+		 * Check if this is the first error.
+		 */
+		if (err != nil) && (errResult == nil) {
+			errResult = err
+		}
+
+	}
+
+	return errResult
+}
+
+/*
+ * Periodically flushes the activity databases to disk.
+ */
+func (this *controllerStruct) runActivityFlusher(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+
+	/*
+	 * Flush activity databases on every tick.
+	 */
+	for range ticker.C {
+		err := this.flushActivityDBs()
+
+		/*
+		 * Check if something went wrong.
+		 */
+		if err != nil {
+			msg := err.Error()
+			fmt.Printf("%s\n", msg)
+		}
+
+	}
+
+}
+
+/*
+ * Synchronize activity database of a data partition to disk.
+ *
+ * If a flush interval is configured, this only marks the activity database as
+ * modified, coalescing rapid mutations into a single write on the next flush.
+ * Otherwise, the activity database is written immediately.
+ */
+func (this *controllerStruct) syncActivityDB(partition *dataPartitionStruct) error {
+	interval := this.activitiesFlush
+
+	/*
+	 * Check if writes shall be deferred.
+	 */
+	if interval <= 0 {
+		err := this.writeActivityDB(partition)
+		return err
+	} else {
+		partition.activitiesDirtyLock.Lock()
+		partition.activitiesDirty = true
+		partition.activitiesDirtyLock.Unlock()
+		return nil
+	}
+
+}
+
+/*
+ * Synchronize user database to disk.
+ *
+ * Since logins with a one-time password also modify the user database, this
+ * may be called concurrently, so writes are serialized.
+ */
+func (this *controllerStruct) syncUserDB() error {
+	this.userDBLock.Lock()
+	mgr := this.userManager
+	buf, err := mgr.Export()
+
+	/*
+	 * Check if export failed.
+	 */
+	if err != nil {
+		this.userDBLock.Unlock()
+		msg := err.Error()
+		return fmt.Errorf("Error serializing user database: %s", msg)
+	} else {
+		path := this.userDBPath
+		mode := os.ModeExclusive | (os.ModePerm & PERMISSIONS_USERDB)
+		err := os.WriteFile(path, buf, mode)
+		this.userDBLock.Unlock()
+
+		/*
+		 * Check if something went wrong
+		 */
+		if err != nil {
+			msg := err.Error()
+			return fmt.Errorf("Error synchronizing user database: %s", msg)
+		} else {
+			return nil
+		}
+
+	}
+
+}
+
+/*
+ * Copies a database file to a new file, which must not exist yet.
+ */
+func (this *controllerStruct) copyDatabaseFile(sourcePath string, targetPath string, perm os.FileMode) error {
+	source, err := os.Open(sourcePath)
+
+	/*
+	 * Check if source file could be opened.
+	 */
+	if err != nil {
+		msg := err.Error()
+		return fmt.Errorf("Failed to open '%s': %s", sourcePath, msg)
+	} else {
+		mode := os.ModeExclusive | (os.ModePerm & perm)
+		target, err := os.OpenFile(targetPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode)
+
+		/*
+		 * Check if target file could be created.
+		 */
+		if err != nil {
+			source.Close()
+			msg := err.Error()
+			return fmt.Errorf("Failed to create '%s': %s", targetPath, msg)
+		} else {
+			_, err = io.Copy(target, source)
+
+			/*
+			 * Make sure data reaches the disk.
+			 */
+			if err == nil {
+				err = target.Sync()
+			}
+
+			errClose := target.Close()
+			source.Close()
+
+			/*
+			 * Report the first error that occured.
+			 */
+			if err == nil {
+				err = errClose
+			}
+
+			/*
+			 * Check if file could be copied.
+			 */
+			if err != nil {
+				msg := err.Error()
+				return fmt.Errorf("Failed to copy '%s' to '%s': %s", sourcePath, targetPath, msg)
+			} else {
+				return nil
+			}
+
+		}
+
+	}
+
+}
+
+/*
+ * Copies the shared location and activity databases into the data partition
+ * of a user.
+ *
+ * The data of the user must not exist yet, so that no data is overwritten.
+ */
+func (this *controllerStruct) migrateUserData(name string) error {
+	umgr := this.userManager
+	exists := umgr.UserExists(name)
+
+	/*
+	 * Make sure that the user exists.
+	 */
+	if !exists {
+		return fmt.Errorf("User '%s' does not exist.", name)
+	} else {
+		dir, err := this.userDataDir(name)
+
+		/*
+		 * Check if data directory could be determined.
+		 */
+		if err != nil {
+			return err
+		} else {
+			config := this.getConfig()
+			sourceActivityDB := config.ActivityDB
+			sourceLocationDB := config.LocationDB
+			targetActivityDB := filepath.Join(dir, DEFAULT_NAME_ACTIVITYDB)
+			targetLocationDB := filepath.Join(dir, DEFAULT_NAME_LOCATIONDB)
+			_, errActivityDB := os.Lstat(targetActivityDB)
+			_, errLocationDB := os.Lstat(targetLocationDB)
+
+			/*
+			 * Refuse to overwrite existing data.
+			 */
+			if !os.IsNotExist(errActivityDB) || !os.IsNotExist(errLocationDB) {
+				return fmt.Errorf("Data of user '%s' already exists in '%s'.", name, dir)
+			} else {
+				modeDir := os.ModePerm & PERMISSIONS_USERDATA
+				err = os.MkdirAll(dir, modeDir)
+
+				/*
+				 * Check if data directory could be created.
+				 */
+				if err != nil {
+					msg := err.Error()
+					return fmt.Errorf("Failed to create data directory for user '%s': %s", name, msg)
+				} else {
+					err = this.copyDatabaseFile(sourceLocationDB, targetLocationDB, PERMISSIONS_LOCATIONDB)
+
+					/*
+					 * Only copy activity database if location
+					 * database could be copied.
+					 */
+					if err == nil {
+						err = this.copyDatabaseFile(sourceActivityDB, targetActivityDB, PERMISSIONS_ACTIVITYDB)
+
+						/*
+						 * Do not leave partially migrated data behind.
+						 */
+						if err != nil {
+							os.Remove(targetLocationDB)
+						}
+
+					}
+
+					return err
+				}
+
+			}
+
+		}
+
+	}
+
+}
+
+/*
+ * Obtain statistics of a location database in the format used for reports.
+ */
+func (this *controllerStruct) locationDBStats(db geodb.Database) (webDatasetStatsStruct, error) {
+	gu := geoutil.Create()
+	stats, err := gu.GeoDBStats(db)
+
+	/*
+	 * Check if statistics could be obtained.
+	 */
+	if err != nil {
+		msg := err.Error()
+		return webDatasetStatsStruct{}, fmt.Errorf("Error obtaining database stats: %s", msg)
+	} else {
+		locationCount := stats.LocationCount()
+		ordered := stats.Ordered()
+		orderedStrict := stats.OrderedStrict()
+		timestampEarliest := stats.TimestampEarliest()
+		timestampLatest := stats.TimestampLatest()
+		timestampEarliestString := ""
+		timestampLatestString := ""
+
+		/*
+		 * Check if timestamps are defined.
+		 */
+		if timestampEarliest <= timestampLatest {
+			timestampEarliestTime := gu.MillisecondsToTime(timestampEarliest)
+			timestampEarliestString = timestampEarliestTime.Format(TIMESTAMP_FORMAT)
+			timestampLatestTime := gu.MillisecondsToTime(timestampLatest)
+			timestampLatestString = timestampLatestTime.Format(TIMESTAMP_FORMAT)
+		}
+
+		/*
+		 * Create dataset statistics.
+		 */
+		result := webDatasetStatsStruct{
+			LocationCount:     locationCount,
+			Ordered:           ordered,
+			OrderedStrict:     orderedStrict,
+			TimestampEarliest: timestampEarliestString,
+			TimestampLatest:   timestampLatestString,
+		}
+
+		return result, nil
+	}
+
+}
+
+/*
+ * Sort or deduplicate the shared location database and print the statistics
+ * before and after.
+ */
+func (this *controllerStruct) maintainLocationDB(action string) error {
+	err := this.initializeLocationData()
+
+	/*
+	 * Check if location data could be loaded.
+	 */
+	if err != nil {
+		return err
+	} else {
+		sharedData := this.sharedData
+		db := sharedData.locationDB
+		before, err := this.locationDBStats(db)
+
+		/*
+		 * Check if statistics could be obtained.
+		 */
+		if err != nil {
+			return err
+		} else {
+			ctx := context.Background()
+			n := uint32(0)
+			err = fmt.Errorf("Unknown action: '%s'", action)
+
+			/*
+			 * Decide which action to carry out.
+			 */
+			switch action {
+			case "deduplicate":
+				n, err = db.Deduplicate(ctx)
+			case "sort":
+				err = db.Sort(ctx)
+			}
+
+			/*
+			 * Check if action was carried out.
+			 */
+			if err != nil {
+				msg := err.Error()
+				return fmt.Errorf("Error during %s: %s", action, msg)
+			} else {
+				after, err := this.locationDBStats(db)
+
+				/*
+				 * Check if statistics could be obtained.
+				 */
+				if err != nil {
+					return err
+				} else {
+					this.printDatasetStats("Before", before)
+					this.printDatasetStats("After", after)
+					fmt.Printf("Removed: %d locations\n", n)
+					return nil
+				}
+
+			}
+
+		}
+
+	}
+
+}
+
+/*
+ * Print statistics of a dataset from a report.
+ */
+func (this *controllerStruct) printDatasetStats(label string, stats webDatasetStatsStruct) {
+	locationCount := stats.LocationCount
+	timestampEarliest := stats.TimestampEarliest
+	timestampLatest := stats.TimestampLatest
+	ordered := stats.Ordered
+	orderedStrict := stats.OrderedStrict
+	fmt.Printf("%s: %d locations, earliest '%s', latest '%s', ordered: %t, strictly ordered: %t\n", label, locationCount, timestampEarliest, timestampLatest, ordered, orderedStrict)
+}
+
+/*
+ * Interpret user commands entered into shell.
+ */
+func (this *controllerStruct) interpret(args []string) {
+	numArgs := len(args)
+
+	/*
+	 * Ensure that there is at least one argument.
+	 */
+	if numArgs > 0 {
+		cmd := args[0]
+		umgr := this.userManager
+
+		/*
+		 * Perform action based on command.
+		 */
+		switch cmd {
+		case "add-permission":
+
+			/*
+			 * Check number of arguments.
+			 */
+			if numArgs != 3 {
+				fmt.Printf("Command '%s' expects 2 additional arguments: name, permission\n", cmd)
+			} else {
+				name := args[1]
+				permission := args[2]
+				err := umgr.AddPermission(name, permission)
+
+				/*
+				 * Check if something went wrong.
+				 */
+				if err != nil {
+					msg := err.Error()
+					fmt.Printf("Command '%s' failed: %s\n", cmd, msg)
+				} else {
+					err = this.syncUserDB()
+
+					/*
+					 * Check if something went wrong.
+					 */
+					if err != nil {
+						msg := err.Error()
+						fmt.Printf("%s\n", msg)
+					}
+
+				}
+
+			}
+
+		case "cleanup-tiles":
+
+			/*
+			 * Check number of arguments.
+			 */
+			if numArgs != 1 {
+				fmt.Printf("Command '%s' expects no additional arguments.\n", cmd)
+			} else {
+				err := this.initializeTileDatabase()
+
+				/*
+				 * Check if tile database could be initialized.
+				 */
+				if err != nil {
+					msg := err.Error()
+					fmt.Printf("Failed to initialize tile database: %s", msg)
+				} else {
+					util := this.tileUtil
+					err := util.Cleanup()
+
+					/*
+					 * Check if errors occured during cleanup.
+					 */
+					if err != nil {
+						msg := err.Error()
+						fmt.Printf("Failed to cleanup tiles: %s\n", msg)
+					}
+
+				}
+
+			}
+
+		case "clear-password":
+
+			/*
+			 * Check number of arguments.
+			 */
+			if numArgs != 2 {
+				fmt.Printf("Command '%s' expects 1 additional argument: name\n", cmd)
+			} else {
+				name := args[1]
+				err := umgr.SetPassword(name, "")
+
+				/*
+				 * Check if something went wrong.
+				 */
+				if err != nil {
+					msg := err.Error()
+					fmt.Printf("Command '%s' failed: %s\n", cmd, msg)
+				} else {
+					err = this.syncUserDB()
+
+					/*
+					 * Check if something went wrong.
+					 */
+					if err != nil {
+						msg := err.Error()
+						fmt.Printf("%s\n", msg)
+					}
+
+				}
+
+			}
+
+		case "clear-public-key":
+
+			/*
+			 * Check number of arguments.
+			 */
+			if numArgs != 2 {
+				fmt.Printf("Command '%s' expects 1 additional argument: name\n", cmd)
+			} else {
+				name := args[1]
+				err := umgr.ClearPublicKey(name)
+
+				/*
+				 * Check if something went wrong.
+				 */
+				if err != nil {
+					msg := err.Error()
+					fmt.Printf("Command '%s' failed: %s\n", cmd, msg)
+				} else {
+					err = this.syncUserDB()
+
+					/*
+					 * Check if something went wrong.
+					 */
+					if err != nil {
+						msg := err.Error()
+						fmt.Printf("%s\n", msg)
+					}
+
+				}
+
+			}
+
+		case "create-user":
+
+			/*
+			 * Check number of arguments.
+			 */
+			if numArgs != 2 {
+				fmt.Printf("Command '%s' expects 1 additional argument: name\n", cmd)
+			} else {
+				name := args[1]
+				err := umgr.CreateUser(name)
+
+				/*
+				 * Check if something went wrong.
+				 */
+				if err != nil {
+					msg := err.Error()
+					fmt.Printf("Command '%s' failed: %s\n", cmd, msg)
+				} else {
+					err = this.syncUserDB()
+
+					/*
+					 * Check if something went wrong.
+					 */
+					if err != nil {
+						msg := err.Error()
+						fmt.Printf("%s\n", msg)
+					}
+
+				}
+
+			}
+
+		case "deduplicate-geodb":
+
+			/*
+			 * Check number of arguments.
+			 */
+			if numArgs != 1 {
+				fmt.Printf("Command '%s' expects no additional arguments.\n", cmd)
+			} else {
+				err := this.maintainLocationDB("deduplicate")
+
+				/*
+				 * Check if something went wrong.
+				 */
+				if err != nil {
+					msg := err.Error()
+					fmt.Printf("Command '%s' failed: %s\n", cmd, msg)
+				}
+
+			}
+
+		case "disable-totp":
+
+			/*
+			 * Check number of arguments.
+			 */
+			if numArgs != 2 {
+				fmt.Printf("Command '%s' expects 1 additional argument: name\n", cmd)
+			} else {
+				name := args[1]
+				err := umgr.DisableTOTP(name)
+
+				/*
+				 * Check if something went wrong.
+				 */
+				if err != nil {
+					msg := err.Error()
+					fmt.Printf("Command '%s' failed: %s\n", cmd, msg)
+				} else {
+					err = this.syncUserDB()
+
+					/*
+					 * Check if something went wrong.
+					 */
+					if err != nil {
+						msg := err.Error()
+						fmt.Printf("%s\n", msg)
+					}
+
+				}
+
+			}
+
+		case "enable-totp":
+
+			/*
+			 * Check number of arguments.
+			 */
+			if numArgs != 2 {
+				fmt.Printf("Command '%s' expects 1 additional argument: name\n", cmd)
+			} else {
+				name := args[1]
+				secret, err := umgr.EnableTOTP(name)
+
+				/*
+				 * Check if something went wrong.
+				 */
+				if err != nil {
+					msg := err.Error()
+					fmt.Printf("Command '%s' failed: %s\n", cmd, msg)
+				} else {
+					err = this.syncUserDB()
+
+					/*
+					 * Check if something went wrong.
+					 */
+					if err != nil {
+						msg := err.Error()
+						fmt.Printf("%s\n", msg)
+					} else {
+						uri := fmt.Sprintf("otpauth://totp/location-visualizer:%s?secret=%s&issuer=location-visualizer", name, secret)
+						fmt.Printf("TOTP secret: %s\n", secret)
+						fmt.Printf("TOTP URI: %s\n", uri)
+					}
+
+				}
+
+			}
+
+		case "evict-tiles":
+
+			/*
+			 * Check number of arguments.
+			 */
+			if numArgs != 2 {
+				fmt.Printf("Command '%s' expects 1 additional argument: days\n", cmd)
+			} else {
+				daysString := args[1]
+				days, err := strconv.ParseUint(daysString, 10, 16)
+
+				/*
+				 * Check if number of days could be parsed.
+				 */
+				if err != nil {
+					fmt.Printf("Command '%s' expects a number of days, but got '%s'.\n", cmd, daysString)
+				} else {
+					err := this.initializeTileDatabase()
+
+					/*
+					 * Check if tile database could be initialized.
+					 */
+					if err != nil {
+						msg := err.Error()
+						fmt.Printf("Failed to initialize tile database: %s", msg)
+					} else if this.tileUtil == nil {
+						fmt.Printf("%s\n", "Map integration is disabled. Set 'UseMap' to true to evict tiles.")
+					} else {
+						now := time.Now()
+						age := time.Duration(days) * 24 * time.Hour
+						cutoff := now.Add(-age)
+						cutoffMs := cutoff.UnixMilli()
+						util := this.tileUtil
+						numEvicted, err := util.EvictOlderThan(cutoffMs)
+
+						/*
+						 * Check if errors occured during eviction.
+						 */
+						if err != nil {
+							msg := err.Error()
+							fmt.Printf("Command '%s' failed: %s\n", cmd, msg)
+						} else {
+							fmt.Printf("Evicted %d tiles.\n", numEvicted)
+						}
+
+					}
+
+				}
+
+			}
+
+		case "export-tiles":
+
+			/*
+			 * Check number of arguments.
+			 */
+			if numArgs != 2 {
+				fmt.Printf("Command '%s' expects 1 additional argument: path\n", cmd)
+			} else {
+				err := this.initializeTileDatabase()
+
+				/*
+				 * Check if tile database could be initialized.
+				 */
+				if err != nil {
+					msg := err.Error()
+					fmt.Printf("Failed to initialize tile database: %s", msg)
+				} else {
+					path := args[1]
+					mode := os.ModeExclusive | (os.ModePerm & PERMISSIONS_USERDB)
+					fd, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode)
+
+					/*
+					 * Check if file could be opened.
+					 */
+					if err != nil {
+						msg := err.Error()
+						fmt.Printf("Failed to create file '%s': %s\n", path, msg)
+					} else {
+						util := this.tileUtil
+						creationTime := time.Now()
+						err := util.Export(fd, creationTime)
+
+						/*
+						 * Check if errors occured during export.
+						 */
+						if err != nil {
+							msg := err.Error()
+							fmt.Printf("Failed to export tiles: %s\n", msg)
+						}
+
+					}
+
+					err = fd.Close()
+
+					/*
+					 * Check if file could be closed.
+					 */
+					if err != nil {
+						msg := err.Error()
+						fmt.Printf("Failed to close file '%s': %s\n", path, msg)
+					}
+
+				}
+
+			}
+
+		case "generate-userdb-key":
+
+			/*
+			 * Check number of arguments.
+			 */
+			if numArgs != 1 {
+				fmt.Printf("Command '%s' expects no additional arguments.\n", cmd)
+			} else {
+				r := rand.SystemPRNG()
+				key := make([]byte, user.ENCRYPTION_KEY_SIZE)
+				_, err := io.ReadFull(r, key)
+
+				/*
+				 * Check if key could be generated.
+				 */
+				if err != nil {
+					msg := err.Error()
+					fmt.Printf("Command '%s' failed: %s\n", cmd, msg)
+				} else {
+					encoding := base64.StdEncoding
+					keyString := encoding.EncodeToString(key)
+					fmt.Printf("%s\n", keyString)
+				}
+
+			}
+
+		case "has-permission":
+
+			/*
+			 * Check number of arguments.
+			 */
+			if numArgs != 3 {
+				fmt.Printf("Command '%s' expects 2 additional arguments: name, permission\n", cmd)
+			} else {
+				name := args[1]
+				permission := args[2]
+				result, err := umgr.HasPermission(name, permission)
+
+				/*
+				 * Check if something went wrong.
+				 */
+				if err != nil {
+					msg := err.Error()
+					fmt.Printf("Command '%s' failed: %s\n", cmd, msg)
+				} else {
+					resultString := strconv.FormatBool(result)
+					fmt.Printf("%s\n", resultString)
+				}
+
+			}
+
+		case "import-geodata":
+
+			/*
+			 * Check number of arguments.
+			 */
+			if numArgs != 4 {
+				fmt.Printf("Command '%s' expects 3 additional arguments: format, strategy, path\n", cmd)
+			} else {
+				format := args[1]
+				strategy := args[2]
+				path := args[3]
+				data, err := os.ReadFile(path)
+
+				/*
+				 * Check if source file could be read.
+				 */
+				if err != nil {
+					fmt.Printf("Command '%s' failed: Failed to read source file '%s'.\n", cmd, path)
+				} else {
+					err = this.initializeLocationData()
+
+					/*
+					 * Check if location data could be loaded.
+					 */
+					if err != nil {
+						msg := err.Error()
+						fmt.Printf("Command '%s' failed: %s\n", cmd, msg)
+					} else {
+						ctx := context.Background()
+						partition := this.sharedData
+						r := bytes.NewReader(data)
+						report := this.importGeoData(ctx, partition, r, format, strategy, "")
+						status := report.Status
+						success := status.Success
+						formatUsed := report.Format
+
+						/*
+						 * Check if import was successful.
+						 */
+						if !success {
+							reason := status.Reason
+							fmt.Printf("Command '%s' failed: %s\n", cmd, reason)
+						} else {
+							before := report.Before
+							source := report.Source
+							imported := report.Imported
+							after := report.After
+							skipped := report.Skipped
+							fmt.Printf("Imported source file '%s' in format '%s'.\n", path, formatUsed)
+							this.printDatasetStats("Before", before)
+							this.printDatasetStats("Source", source)
+							this.printDatasetStats("Imported", imported)
+							this.printDatasetStats("After", after)
+							fmt.Printf("Skipped: %d locations\n", skipped)
+						}
+
+					}
+
+				}
+
+			}
+
+		case "import-tiles":
+
+			/*
+			 * Check number of arguments.
+			 */
+			if numArgs != 2 {
+				fmt.Printf("Command '%s' expects 1 additional argument: path\n", cmd)
+			} else {
+				err := this.initializeTileDatabase()
+
+				/*
+				 * Check if tile database could be initialized.
+				 */
+				if err != nil {
+					msg := err.Error()
+					fmt.Printf("Failed to initialize tile database: %s", msg)
+				} else {
+					path := args[1]
+					mode := os.ModeExclusive | (os.ModePerm & PERMISSIONS_USERDB)
+					fd, err := os.OpenFile(path, os.O_RDONLY, mode)
+
+					/*
+					 * Check if file could be opened.
+					 */
+					if err != nil {
+						msg := err.Error()
+						fmt.Printf("Failed to open file '%s': %s\n", path, msg)
+					} else {
+						util := this.tileUtil
+						err := util.Import(fd)
+
+						/*
+						 * Check if errors occured during import.
+						 */
+						if err != nil {
+							msg := err.Error()
+							fmt.Printf("Failed to import tiles: %s\n", msg)
+						}
+
+					}
+
+					err = fd.Close()
+
+					/*
+					 * Check if file could be closed.
+					 */
+					if err != nil {
+						msg := err.Error()
+						fmt.Printf("Failed to close file '%s': %s\n", path, msg)
+					}
+
+				}
+
+			}
+
+		case "list-permissions":
+
+			/*
+			 * Check number of arguments.
+			 */
+			if numArgs != 2 {
+				fmt.Printf("Command '%s' expects 1 additional argument: name\n", cmd)
+			} else {
+				name := args[1]
+				permissions, err := umgr.Permissions(name)
+
+				/*
+				 * Check if something went wrong.
+				 */
+				if err != nil {
+					msg := err.Error()
+					fmt.Printf("Command '%s' failed: %s\n", cmd, msg)
+				} else {
+
+					/*
+					 * Print each permission on a new line.
+					 */
+					for _, permission := range permissions {
+						fmt.Printf("%s\n", permission)
+					}
+
+				}
+
+			}
+
+		case "list-users":
+
+			/*
+			 * Check number of arguments.
+			 */
+			if numArgs != 1 {
+				fmt.Printf("Command '%s' expects no additional arguments.\n", cmd)
+			} else {
+				users := umgr.Users()
+
+				/*
+				 * Print each user on a new line.
+				 */
+				for _, user := range users {
+					fmt.Printf("%s\n", user)
+				}
+
+			}
+
+		case "migrate-user-data":
+
+			/*
+			 * Check number of arguments.
+			 */
+			if numArgs != 2 {
+				fmt.Printf("Command '%s' expects 1 additional argument: name\n", cmd)
+			} else {
+				name := args[1]
+				err := this.migrateUserData(name)
+
+				/*
+				 * Check if something went wrong.
+				 */
+				if err != nil {
+					msg := err.Error()
+					fmt.Printf("Command '%s' failed: %s\n", cmd, msg)
+				} else {
+					fmt.Printf("Copied shared data to data of user '%s'.\n", name)
+				}
+
+			}
+
+		case "remove-permission":
+
+			/*
+			 * Check number of arguments.
+			 */
+			if numArgs != 3 {
+				fmt.Printf("Command '%s' expects 2 additional arguments: name, permission\n", cmd)
+			} else {
+				name := args[1]
+				permission := args[2]
+				err := umgr.RemovePermission(name, permission)
+
+				/*
+				 * Check if something went wrong.
+				 */
+				if err != nil {
+					msg := err.Error()
+					fmt.Printf("Command '%s' failed: %s\n", cmd, msg)
+				} else {
+					err = this.syncUserDB()
+
+					/*
+					 * Check if something went wrong.
+					 */
+					if err != nil {
+						msg := err.Error()
+						fmt.Printf("%s\n", msg)
+					}
+
+				}
+
+			}
+
+		case "remove-user":
+
+			/*
+			 * Check number of arguments.
+			 */
+			if numArgs != 2 {
+				fmt.Printf("Command '%s' expects 1 additional argument: name\n", cmd)
+			} else {
+				name := args[1]
+				err := umgr.RemoveUser(name)
+
+				/*
+				 * Check if something went wrong.
+				 */
+				if err != nil {
+					msg := err.Error()
+					fmt.Printf("Command '%s' failed: %s\n", cmd, msg)
+				} else {
+					err = this.syncUserDB()
+
+					/*
+					 * Check if something went wrong.
+					 */
+					if err != nil {
+						msg := err.Error()
+						fmt.Printf("%s\n", msg)
+					}
+
+				}
+
+			}
+
+		case "rotate-userdb-key":
+
+			/*
+			 * Check number of arguments.
+			 */
+			if numArgs != 2 {
+				fmt.Printf("Command '%s' expects 1 additional argument: key\n", cmd)
+			} else {
+				keyString := args[1]
+				key, err := this.decodeUserDBKey(keyString)
+
+				/*
+				 * Set new encryption key if it could be decoded.
+				 */
+				if err == nil {
+					err = umgr.SetKey(key)
+				}
+
+				/*
+				 * Check if something went wrong.
+				 */
+				if err != nil {
+					msg := err.Error()
+					fmt.Printf("Command '%s' failed: %s\n", cmd, msg)
+				} else {
+					err = this.syncUserDB()
+
+					/*
+					 * Check if something went wrong.
+					 */
+					if err != nil {
+						msg := err.Error()
+						fmt.Printf("%s\n", msg)
+					} else {
+						fmt.Printf("%s\n", "User database rewritten. Set 'UserDBKey' to the new key before starting the server.")
+					}
+
+				}
+
+			}
+
+		case "set-password":
+
+			/*
+			 * Check number of arguments.
+			 */
+			if numArgs != 3 {
+				fmt.Printf("Command '%s' expects 2 additional arguments: name, password\n", cmd)
+			} else {
+				name := args[1]
+				password := args[2]
+				err := umgr.SetPassword(name, password)
 
-					this.activitiesLock.Lock()
-					activities := this.activities
-					currentRevision := activities.Revision()
+				/*
+				 * Check if something went wrong.
+				 */
+				if err != nil {
+					msg := err.Error()
+					fmt.Printf("Command '%s' failed: %s\n", cmd, msg)
+				} else {
+					err = this.syncUserDB()
 
 					/*
-					 * Make sure that revision information matches.
+					 * Check if something went wrong.
 					 */
-					if revision != currentRevision {
+					if err != nil {
+						msg := err.Error()
+						fmt.Printf("%s\n", msg)
+					}
 
-						/*
-						 * Indicate failure.
-						 */
-						wr = webResponseStruct{
-							Success: false,
-							Reason:  "Failed to replace activity: Activity data was changed in the meantime.",
-						}
+				}
+
+			}
+
+		case "set-public-key":
+
+			/*
+			 * Check number of arguments.
+			 */
+			if numArgs != 3 {
+				fmt.Printf("Command '%s' expects 2 additional arguments: name, file\n", cmd)
+			} else {
+				name := args[1]
+				path := args[2]
+				content, err := os.ReadFile(path)
+
+				/*
+				 * Check if key file could be read.
+				 */
+				if err != nil {
+					msg := err.Error()
+					fmt.Printf("Command '%s' failed: Failed to read key file '%s': %s\n", cmd, path, msg)
+				} else {
+					err = umgr.SetPublicKey(name, content)
 
+					/*
+					 * Check if something went wrong.
+					 */
+					if err != nil {
+						msg := err.Error()
+						fmt.Printf("Command '%s' failed: %s\n", cmd, msg)
 					} else {
-						err := activities.Replace(id, &info)
+						err = this.syncUserDB()
 
 						/*
-						 * Check if activity was replaced.
+						 * Check if something went wrong.
 						 */
 						if err != nil {
 							msg := err.Error()
-							reason := fmt.Sprintf("Failed to replace activity: %s", msg)
+							fmt.Printf("%s\n", msg)
+						}
 
-							/*
-							 * Indicate failure.
-							 */
-							wr = webResponseStruct{
-								Success: false,
-								Reason:  reason,
-							}
+					}
 
-						} else {
-							err = this.syncActivityDB()
+				}
 
-							/*
-							 * Check if user database was synchronized.
-							 */
-							if err != nil {
-								msg := err.Error()
-								reason := fmt.Sprintf("Failed to synchronize activity database: %s", msg)
+			}
 
-								/*
-								 * Indicate failure.
-								 */
-								wr = webResponseStruct{
-									Success: false,
-									Reason:  reason,
-								}
+		case "sort-geodb":
 
-							} else {
+			/*
+			 * Check number of arguments.
+			 */
+			if numArgs != 1 {
+				fmt.Printf("Command '%s' expects no additional arguments.\n", cmd)
+			} else {
+				err := this.maintainLocationDB("sort")
 
-								/*
-								 * Indicate success.
-								 */
-								wr = webResponseStruct{
-									Success: true,
-									Reason:  "",
-								}
+				/*
+				 * Check if something went wrong.
+				 */
+				if err != nil {
+					msg := err.Error()
+					fmt.Printf("Command '%s' failed: %s\n", cmd, msg)
+				}
 
-							}
+			}
+
+		case "verify-activities":
+
+			/*
+			 * Check number of arguments.
+			 */
+			if numArgs != 1 {
+				fmt.Printf("Command '%s' expects no additional arguments.\n", cmd)
+			} else {
+				config := this.getConfig()
+				activityDBPath := config.ActivityDB
+				content, err := os.ReadFile(activityDBPath)
+
+				/*
+				 * Check if activity database could be read.
+				 */
+				if err != nil {
+					fmt.Printf("Command '%s' failed: Failed to open activity database '%s'.\n", cmd, activityDBPath)
+				} else {
+					report, err := meta.VerifyJSON(content)
+
+					/*
+					 * Check if activity data could be verified.
+					 */
+					if err != nil {
+						msg := err.Error()
+						fmt.Printf("Command '%s' failed: %s\n", cmd, msg)
+					} else {
+						issues := report.Issues
+						numIssues := len(issues)
+						groupCount := report.GroupCount
 
+						/*
+						 * Print each issue found.
+						 */
+						for _, issue := range issues {
+							group := issue.Group
+							begin := issue.Begin
+							beginString := begin.Format(time.RFC3339)
+							reason := issue.Reason
+							fmt.Printf("Group %d (%s): %s\n", group, beginString, reason)
 						}
 
+						fmt.Printf("Verified %d activity groups, found %d issues.\n", groupCount, numIssues)
 					}
 
-					this.activitiesLock.Unlock()
 				}
 
 			}
 
+		default:
+			fmt.Printf("Unknown command: %s\n", cmd)
 		}
 
-		mimeType, buffer := this.createJSON(wr)
-
-		/*
-		 * Create HTTP response.
-		 */
-		response := webserver.HttpResponse{
-			Header: map[string]string{"Content-type": mimeType},
-			Body:   buffer,
-		}
-
-		return response
 	}
 
 }
 
 /*
- * Render location data into an image.
+ * Runs the server and message pump.
  */
-func (this *controllerStruct) renderHandler(request webserver.HttpRequest) webserver.HttpResponse {
-	token := request.Params["token"]
-	perm, err := this.checkPermission(token, "render")
+func (this *controllerStruct) runServer() {
+	cfg := this.getConfig()
+	serverCfg := cfg.WebServer
+	server, err := webserver.CreateWebServer(serverCfg)
 
 	/*
-	 * Check permissions.
+	 * Check if we got a web server.
 	 */
 	if err != nil {
 		msg := err.Error()
-		customMsg := fmt.Sprintf("Failed to check permission: %s", msg)
-		customMsgBuf := bytes.NewBufferString(customMsg)
-		customMsgBytes := customMsgBuf.Bytes()
-		conf := this.config
-		confServer := conf.WebServer
-		contentType := confServer.ErrorMime
+		fmt.Printf("Web server did not enter message loop: %s\n", msg)
+	} else {
+		requests := server.RegisterCgi(CGI_PATH)
+		staticAssets := serverCfg.StaticAssets
 
 		/*
-		 * Create HTTP response.
+		 * Serve static assets with compression and caching if enabled.
 		 */
-		response := webserver.HttpResponse{
-			Header: map[string]string{"Content-type": contentType},
-			Body:   customMsgBytes,
+		if staticAssets {
+			err = server.RegisterStatic(STATIC_PATH)
+
+			/*
+			 * Fall back to serving files directly if static assets
+			 * could not be loaded.
+			 */
+			if err != nil {
+				msg := err.Error()
+				fmt.Printf("%s\n", msg)
+			}
+
 		}
 
-		return response
-	} else if !perm {
-		customMsgBuf := bytes.NewBufferString("Forbidden!")
-		customMsgBytes := customMsgBuf.Bytes()
-		conf := this.config
-		confServer := conf.WebServer
-		contentType := confServer.ErrorMime
+		server.Run()
+		protocol := "https"
+		port := serverCfg.TLSPort
+		tlsDisabled := serverCfg.TLSDisabled
+
+		if tlsDisabled {
+			protocol = "http"
+			port = serverCfg.Port
+		}
+
+		fmt.Printf("Web interface ready: %s://localhost:%s/\n", protocol, port)
 
 		/*
-		 * Create HTTP response.
+		 * A worker processing HTTP requests.
 		 */
-		response := webserver.HttpResponse{
-			Header: map[string]string{"Content-type": contentType},
-			Body:   customMsgBytes,
+		worker := func(requests <-chan webserver.HttpRequest) {
+
+			/*
+			 * This is the actual message pump.
+			 */
+			for request := range requests {
+				response := this.dispatch(request)
+				respond := request.Respond
+				respond <- response
+			}
+
 		}
 
-		return response
-	} else {
-		xresIn := request.Params["xres"]
-		xres64, _ := strconv.ParseUint(xresIn, 10, 16)
-		xres := uint32(xres64)
-		xres64 = uint64(xres)
-		yresIn := request.Params["yres"]
-		yres64, _ := strconv.ParseUint(yresIn, 10, 16)
-		yres := uint32(yres64)
-		yres64 = uint64(yres)
-		resolution := xres64 * yres64
-		conf := this.config
-		confLimits := conf.Limits
-		maxAxis := confLimits.MaxAxis
+		numWorkers := this.workers
 
 		/*
-		 * Ensure that resolution along X axis does not exceed limits.
+		 * Spawn as many workers as configured.
 		 */
-		if xres > maxAxis {
-			xres = maxAxis
+		for i := 0; i < numWorkers; i++ {
+			go worker(requests)
 		}
 
+		flush := this.activitiesFlush
+
 		/*
-		 * Ensure that resolution along Y axis does not exceed limits.
+		 * Periodically flush activity database if writes are deferred.
 		 */
-		if yres > maxAxis {
-			yres = maxAxis
+		if flush > 0 {
+			go this.runActivityFlusher(flush)
 		}
 
-		maxPixels := confLimits.MaxPixels
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
 
 		/*
-		 * Check if overall number of pixels is within limits.
+		 * Flush pending changes to disk before terminating.
 		 */
-		if resolution > maxPixels {
-			msg := fmt.Sprintf("Total number of pixels must not exceed %d.", maxPixels)
-			msgBuf := bytes.NewBufferString(msg)
-			msgBytes := msgBuf.Bytes()
-			confServer := conf.WebServer
-			contentType := confServer.ErrorMime
+		go func() {
+			<-signals
+			errActivities := this.flushActivityDBs()
+			errDatabases := this.closeDatabases()
 
 			/*
-			 * Create HTTP response.
+			 * Check if activity database could be written.
 			 */
-			response := webserver.HttpResponse{
-				Header: map[string]string{"Content-type": contentType},
-				Body:   msgBytes,
+			if errActivities != nil {
+				msg := errActivities.Error()
+				fmt.Printf("%s\n", msg)
 			}
 
-			return response
-		} else {
-			xposIn := request.Params["xpos"]
-			xpos, _ := strconv.ParseFloat(xposIn, 64)
-			yposIn := request.Params["ypos"]
-			ypos, _ := strconv.ParseFloat(yposIn, 64)
-			zoomIn := request.Params["zoom"]
-			zoom, _ := strconv.ParseUint(zoomIn, 10, 8)
-			zoomFloat := float64(zoom)
-			zoomExp := -0.2 * zoomFloat
-			zoomFac := math.Pow(2.0, zoomExp)
-			minTimeIn := request.Params["mintime"]
-			minTime, _ := filter.ParseTime(minTimeIn, true, true)
-			maxTimeIn := request.Params["maxtime"]
-			maxTime, _ := filter.ParseTime(maxTimeIn, true, true)
-			fgColor := request.Params["fgcolor"]
-			spreadIn := request.Params["spread"]
-			spread64, _ := strconv.ParseUint(spreadIn, 10, 8)
-			spread := uint8(spread64)
-			flt := filter.Filter(nil)
-			minTimeIsZero := minTime.IsZero()
-			maxTimeIsZero := maxTime.IsZero()
+			/*
+			 * Check if databases could be closed.
+			 */
+			if errDatabases != nil {
+				msg := errDatabases.Error()
+				fmt.Printf("%s\n", msg)
+			}
 
 			/*
-			 * Create filter if at least one of the limits is set.
+			 * Check if something went wrong.
 			 */
-			if !minTimeIsZero || !maxTimeIsZero {
-				flt = filter.Time(minTime, maxTime)
+			if (errActivities != nil) || (errDatabases != nil) {
+				os.Exit(1)
+			} else {
+				os.Exit(0)
 			}
 
-			mercator := projection.Mercator()
-			locationDB := this.locationDB
-			numDataPoints := locationDB.LocationCount()
-			offset := uint32(0)
-			dataRead := make([]geodb.Location, LOCATION_BLOCK_SIZE)
-			dataFiltered := make([]geodb.Location, LOCATION_BLOCK_SIZE)
-			locationsGeographic := make([]coordinates.Geographic, LOCATION_BLOCK_SIZE)
-			locationsProjected := make([]coordinates.Cartesian, LOCATION_BLOCK_SIZE)
-			halfWidth := 0.5 * zoomFac
-			xresFloat := float64(xres)
-			yresFloat := float64(yres)
-			aspectRatio := yresFloat / xresFloat
-			halfHeight := aspectRatio * halfWidth
-			minX := xpos - halfWidth
-			maxX := xpos + halfWidth
-			minY := ypos - halfHeight
-			maxY := ypos + halfHeight
-			scn := scene.Create(xres, yres, minX, maxX, minY, maxY)
-			gu := geoutil.Create()
+		}()
+
+		hangups := make(chan os.Signal, 1)
+		signal.Notify(hangups, syscall.SIGHUP)
+
+		/*
+		 * Reload configuration whenever we receive a hangup signal.
+		 */
+		go func() {
+
+			/*
+			 * Wait for hangup signals forever.
+			 */
+			for {
+				<-hangups
+				this.reloadConfigAndReport()
+			}
+
+		}()
+
+		stdin := os.Stdin
+		scanner := bufio.NewScanner(stdin)
+
+		/*
+		 * Read commands from standard input forever.
+		 */
+		for {
+			scanner.Scan()
+			line := scanner.Text()
+			fields := strings.Fields(line)
+			numFields := len(fields)
 
 			/*
-			 * Check if there is still data to read.
+			 * Interpret command if one was entered.
 			 */
-			for offset < numDataPoints {
-				numLocationsRead, errRead := locationDB.ReadLocations(offset, dataRead)
+			if numFields > 0 {
+				cmd := fields[0]
 
 				/*
-				 * Log database read errors.
+				 * Check which command was entered.
 				 */
-				if errRead != nil {
-					msg := errRead.Error()
-					fmt.Printf("Error reading from GeoDB database while rendering: %s\n", msg)
+				switch cmd {
+				case "reload-config":
+					this.reloadConfigAndReport()
+				default:
+					fmt.Printf("Unknown command: %s\n", cmd)
 				}
 
-				currentDataRead := dataRead[0:numLocationsRead]
-				numLocationsFiltered := filter.Apply(flt, currentDataRead, dataFiltered)
-				currentDataFiltered := dataFiltered[0:numLocationsFiltered]
+			}
 
-				/*
-				 * Render filtered data points.
-				 */
-				for i, elem := range currentDataFiltered {
-					latitudeE7 := elem.LatitudeE7
-					latitude := gu.DegreesE7ToRadians(latitudeE7)
-					longitudeE7 := elem.LongitudeE7
-					longitude := gu.DegreesE7ToRadians(longitudeE7)
-					locationsGeographic[i] = coordinates.CreateGeographic(longitude, latitude)
-				}
+		}
 
-				currentLocationsGeographic := locationsGeographic[0:numLocationsFiltered]
-				currentLocationsProjected := locationsProjected[0:numLocationsFiltered]
-				errProject := mercator.Forward(currentLocationsProjected, currentLocationsGeographic)
+	}
 
-				/*
-				 * Log projection errors.
-				 */
-				if errProject != nil {
-					msg := errProject.Error()
-					fmt.Printf("Error projecting data points while rendering: %s\n", msg)
-				}
+}
 
-				scn.Aggregate(currentLocationsProjected)
-				offset += numLocationsRead
-			}
+/*
+ * Initialize activity data.
+ */
+func (this *controllerStruct) initializeActivities() error {
+	config := this.getConfig()
+	activityDBPath := config.ActivityDB
+	contentActivityDB, err := os.ReadFile(activityDBPath)
+
+	/*
+	 * Check if file could be read.
+	 */
+	if err != nil {
+		return fmt.Errorf("Failed to open activity database '%s'.", activityDBPath)
+	} else {
+		act := meta.CreateActivities()
+		err = act.Import(contentActivityDB)
+		sharedData := this.sharedData
+		sharedData.activities = act
+		sharedData.activityDBPath = activityDBPath
+		flushString := config.ActivityFlushInterval
+		flush, _ := time.ParseDuration(flushString)
+		this.activitiesFlush = flush
 
-			scn.Spread(spread)
-			mapping := color.DefaultMapping()
+		/*
+		 * Check if activity data could be decoded.
+		 */
+		if err != nil {
+			msg := err.Error()
+			return fmt.Errorf("Failed to import activity data: %s", msg)
+		}
 
-			/*
-			 * Check if custom color mapping is required.
-			 */
-			switch fgColor {
-			case "red":
-				mapping = color.SimpleMapping(255, 0, 0)
-			case "green":
-				mapping = color.SimpleMapping(0, 255, 0)
-			case "blue":
-				mapping = color.SimpleMapping(0, 0, 255)
-			case "yellow":
-				mapping = color.SimpleMapping(255, 255, 0)
-			case "cyan":
-				mapping = color.SimpleMapping(0, 255, 255)
-			case "magenta":
-				mapping = color.SimpleMapping(255, 0, 255)
-			case "gray":
-				mapping = color.SimpleMapping(127, 127, 127)
-			case "brightblue":
-				mapping = color.SimpleMapping(127, 127, 255)
-			case "white":
-				mapping = color.SimpleMapping(255, 255, 255)
-			}
+	}
+
+	return nil
+}
+
+/*
+ * Decodes a base64-encoded key for the user database.
+ *
+ * An empty string yields a nil key, which disables encryption.
+ */
+func (this *controllerStruct) decodeUserDBKey(keyString string) ([]byte, error) {
+
+	/*
+	 * An empty key disables encryption.
+	 */
+	if keyString == "" {
+		return nil, nil
+	} else {
+		encoding := base64.StdEncoding
+		key, err := encoding.DecodeString(keyString)
+		keySize := len(key)
+
+		/*
+		 * Check if key could be decoded and has the correct size.
+		 */
+		if err != nil {
+			return nil, fmt.Errorf("%s", "Failed to decode user database key: Key must be base64-encoded.")
+		} else if keySize != user.ENCRYPTION_KEY_SIZE {
+			return nil, fmt.Errorf("User database key has incorrect size. Expected %d bytes, found %d bytes.", user.ENCRYPTION_KEY_SIZE, keySize)
+		} else {
+			return key, nil
+		}
+
+	}
+
+}
+
+/*
+ * Initialize user database.
+ */
+func (this *controllerStruct) initializeUserDB() error {
+	config := this.getConfig()
+	userDBPath := config.UserDB
+	contentUserDB, err := os.ReadFile(userDBPath)
+
+	/*
+	 * Check if file could be read.
+	 */
+	if err != nil {
+		return fmt.Errorf("Failed to open user database '%s'.", userDBPath)
+	} else {
+		r := rand.SystemPRNG()
+		seed := make([]byte, rand.SEED_SIZE)
+		_, err = r.Read(seed)
 
-			target, err := scn.Render(mapping)
+		/*
+		 * Check if seed could be read from system.
+		 */
+		if err != nil {
+			return fmt.Errorf("Failed to obtain entropy from system.")
+		} else {
+			prng, err := rand.CreatePRNG(seed)
 
 			/*
-			 * Check if image could be rendered.
+			 * Check if PRNG could be created.
 			 */
 			if err != nil {
 				msg := err.Error()
-				customMsg := fmt.Sprintf("Failed to render image: %s", msg)
-				customMsgBuf := bytes.NewBufferString(customMsg)
-				customMsgBytes := customMsgBuf.Bytes()
-				conf := this.config
-				confServer := conf.WebServer
-				contentType := confServer.ErrorMime
-
-				/*
-				 * Create HTTP response.
-				 */
-				response := webserver.HttpResponse{
-					Header: map[string]string{"Content-type": contentType},
-					Body:   customMsgBytes,
-				}
-
-				return response
+				return fmt.Errorf("Failed to create pseudo-random number generator: %s", msg)
 			} else {
+				userManager, err := user.CreateManager(prng)
 
 				/*
-				 * Create a PNG encoder.
-				 */
-				encoder := png.Encoder{
-					CompressionLevel: png.BestCompression,
-				}
-
-				buf := &bytes.Buffer{}
-				err := encoder.Encode(buf, target)
-
-				/*
-				 * Check if image could be encoded.
+				 * Check if user manager could be created.
 				 */
 				if err != nil {
 					msg := err.Error()
-					customMsg := fmt.Sprintf("Failed to encode image: %s\n", msg)
-					customMsgBuf := bytes.NewBufferString(customMsg)
-					customMsgBytes := customMsgBuf.Bytes()
-					conf := this.config
-					confServer := conf.WebServer
-					contentType := confServer.ErrorMime
+					return fmt.Errorf("Failed to create user manager: %s", msg)
+				} else {
+					this.userManager = userManager
+					this.userDBPath = userDBPath
+					keyString := config.UserDBKey
+					key, err := this.decodeUserDBKey(keyString)
 
 					/*
-					 * Create HTTP response.
+					 * Set encryption key if it could be decoded.
 					 */
-					response := webserver.HttpResponse{
-						Header: map[string]string{"Content-type": contentType},
-						Body:   customMsgBytes,
+					if err == nil {
+						err = userManager.SetKey(key)
 					}
 
-					return response
-				} else {
-					bufBytes := buf.Bytes()
+					/*
+					 * Only import user database if key could be set.
+					 */
+					if err == nil {
+						err = userManager.Import(contentUserDB)
+					}
 
 					/*
-					 * Create HTTP response.
+					 * Check if user database could be imported.
 					 */
-					response := webserver.HttpResponse{
-						Header: map[string]string{"Content-type": "image/png"},
-						Body:   bufBytes,
+					if err != nil {
+						msg := err.Error()
+						return fmt.Errorf("Failed to import user database: %s", msg)
+					} else {
+						expiry := this.parseSessionExpiry(config)
+						sessionManager, err := session.CreateManager(userManager, prng, expiry)
+
+						/*
+						 * Check if user manager could be created.
+						 */
+						if err != nil {
+							msg := err.Error()
+							return fmt.Errorf("Failed to create session manager: %s", msg)
+						} else {
+							this.sessionManager = sessionManager
+							return nil
+						}
+
 					}
 
-					return response
 				}
 
 			}
@@ -2848,890 +13379,1448 @@ func (this *controllerStruct) renderHandler(request webserver.HttpRequest) webse
 }
 
 /*
- * Handles CGI requests that could not be dispatched to other CGIs.
+ * Checks whether a location database is ordered by timestamp.
+ *
+ * Time filtering during rendering relies on the order of the locations, so
+ * print a warning if the database is unordered, or sort it right away if
+ * automatic sorting is enabled.
  */
-func (this *controllerStruct) errorHandler(request webserver.HttpRequest) webserver.HttpResponse {
-	_ = request
-	conf := this.config
-	confServer := conf.WebServer
-	contentType := confServer.ErrorMime
-	msgBuf := bytes.NewBufferString("This CGI call is not implemented.")
-	msgBytes := msgBuf.Bytes()
+func (this *controllerStruct) checkLocationOrder(db geodb.Database) {
+	gu := geoutil.Create()
+	stats, err := gu.GeoDBStats(db)
 
 	/*
-	 * Create HTTP response.
+	 * Check if database statistics could be obtained.
 	 */
-	response := webserver.HttpResponse{
-		Header: map[string]string{"Content-type": contentType},
-		Body:   msgBytes,
-	}
+	if err != nil {
+		msg := err.Error()
+		fmt.Printf("Failed to check order of location database: %s\n", msg)
+	} else {
+		ordered := stats.Ordered()
 
-	return response
-}
+		/*
+		 * Check if location database is unordered.
+		 */
+		if !ordered {
+			config := this.getConfig()
+			autoSort := config.AutoSortLocationDB
 
-/*
- * Dispatch CGI requests to the corresponding CGI handlers.
- */
-func (this *controllerStruct) dispatch(request webserver.HttpRequest) webserver.HttpResponse {
-	cgi := request.Params["cgi"]
-	response := webserver.HttpResponse{}
+			/*
+			 * Either sort database or warn the user.
+			 */
+			if autoSort {
+				fmt.Printf("%s\n", "Location database is not ordered by timestamp. Sorting ...")
+				ctx := context.Background()
+				err = db.Sort(ctx)
+
+				/*
+				 * Check if database could be sorted.
+				 */
+				if err != nil {
+					msg := err.Error()
+					fmt.Printf("Failed to sort location database: %s\n", msg)
+				} else {
+					fmt.Printf("%s\n", "Location database sorted.")
+				}
+
+			} else {
+				fmt.Printf("%s\n", "WARNING: Location database is not ordered by timestamp. Time filtering will produce wrong results.")
+				fmt.Printf("%s\n", "Sort the database using 'modify-geodata' with 'action=sort' or set 'AutoSortLocationDB' in the configuration.")
+			}
+
+		}
 
-	/*
-	 * Find the right CGI to handle the request.
-	 */
-	switch cgi {
-	case "add-activity":
-		response = this.addActivityHandler(request)
-	case "auth-logout":
-		response = this.authLogoutHandler(request)
-	case "auth-request":
-		response = this.authRequestHandler(request)
-	case "auth-response":
-		response = this.authResponseHandler(request)
-	case "download-geodb-content":
-		response = this.downloadGeoDBContentHandler(request)
-	case "export-activities-csv":
-		response = this.exportActivitiesCsvHandler(request)
-	case "get-activities":
-		response = this.getActivitiesHandler(request)
-	case "get-geodb-stats":
-		response = this.getGeoDBStatsHandler(request)
-	case "get-tile":
-		sem := this.semTile
-		this.acquire(sem)
-		response = this.getTileHandler(request)
-		this.release(sem)
-	case "import-activity-csv":
-		response = this.importActivityCsvHandler(request)
-	case "import-geodata":
-		response = this.importGeoDataHandler(request)
-	case "modify-geodata":
-		response = this.modifyGeoDataHandler(request)
-	case "remove-activity":
-		response = this.removeActivityHandler(request)
-	case "replace-activity":
-		response = this.replaceActivityHandler(request)
-	case "render":
-		sem := this.semRender
-		this.acquire(sem)
-		response = this.renderHandler(request)
-		this.release(sem)
-	default:
-		response = this.errorHandler(request)
 	}
 
-	return response
 }
 
 /*
- * Synchronize activity database to disk.
+ * Rounds locations appended to a location database to the configured
+ * precision.
  */
-func (this *controllerStruct) syncActivityDB() error {
-	act := this.activities
-	buf, err := act.Export()
+func (this *controllerStruct) applyLocationPrecision(db geodb.Database) error {
+	config := this.getConfig()
+	digits := config.LocationPrecision
 
 	/*
-	 * Check if export failed.
+	 * Zero keeps full precision.
 	 */
-	if err != nil {
-		msg := err.Error()
-		return fmt.Errorf("Error serializing activity database: %s", msg)
+	if digits == 0 {
+		return nil
 	} else {
-		path := this.activityDBPath
-		this.activitiesWriteLock.Lock()
-		mode := os.ModeExclusive | (os.ModePerm & PERMISSIONS_ACTIVITYDB)
-		err := os.WriteFile(path, buf, mode)
-		this.activitiesWriteLock.Unlock()
-
-		/*
-		 * Check if something went wrong.
-		 */
-		if err != nil {
-			msg := err.Error()
-			return fmt.Errorf("Error synchronizing activity database: %s", msg)
-		} else {
-			return nil
-		}
-
+		err := db.SetPrecision(digits)
+		return err
 	}
 
 }
 
 /*
- * Synchronize user database to disk.
+ * Initialize geographical database with location data.
  */
-func (this *controllerStruct) syncUserDB() error {
-	mgr := this.userManager
-	buf, err := mgr.Export()
+func (this *controllerStruct) initializeLocationData() error {
+	config := this.getConfig()
+	locationDBPath := config.LocationDB
+	mode := os.ModeExclusive | (os.ModePerm & PERMISSIONS_USERDB)
+	fd, err := os.OpenFile(locationDBPath, os.O_RDWR|os.O_CREATE, mode)
 
 	/*
-	 * Check if export failed.
+	 * Check if file could be opened.
 	 */
 	if err != nil {
-		msg := err.Error()
-		return fmt.Errorf("Error serializing user database: %s", msg)
+		return fmt.Errorf("Failed to open location database file '%s'.", locationDBPath)
 	} else {
-		path := this.userDBPath
-		mode := os.ModeExclusive | (os.ModePerm & PERMISSIONS_USERDB)
-		err := os.WriteFile(path, buf, mode)
+		db, err := geodb.Create(fd)
+
+		/*
+		 * Set precision if database could be accessed.
+		 */
+		if err == nil {
+			err = this.applyLocationPrecision(db)
+		}
 
 		/*
-		 * Check if something went wrong
+		 * Check if database could be accessed.
 		 */
 		if err != nil {
 			msg := err.Error()
-			return fmt.Errorf("Error synchronizing user database: %s", msg)
+			return fmt.Errorf("Failed to access location database: %s", msg)
 		} else {
-			return nil
+			sharedData := this.sharedData
+			sharedData.locationDB = db
+			this.checkLocationOrder(db)
 		}
 
+		return nil
 	}
 
 }
 
 /*
- * Interpret user commands entered into shell.
+ * Initialize tile database from a single file holding both the index and the
+ * image database.
  */
-func (this *controllerStruct) interpret(args []string) {
-	numArgs := len(args)
+func (this *controllerStruct) initializeCombinedTileDatabase(path string) error {
+	mode := os.ModeExclusive | (os.ModePerm & PERMISSIONS_TILEDB)
+	fd, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, mode)
 
 	/*
-	 * Ensure that there is at least one argument.
+	 * Check if file could be opened.
 	 */
-	if numArgs > 0 {
-		cmd := args[0]
-		umgr := this.userManager
+	if err != nil {
+		msg := err.Error()
+		return fmt.Errorf("Failed to open file '%s': %s", path, msg)
+	} else {
+		container, err := tiledb.CreateContainer(fd)
 
 		/*
-		 * Perform action based on command.
+		 * Check if container was created successfully.
 		 */
-		switch cmd {
-		case "add-permission":
+		if err != nil {
+			msg := err.Error()
+			return fmt.Errorf("Failed to open tile database container: %s", msg)
+		} else {
+			indexStorage := container.IndexStorage()
+			indexDB, err := tiledb.CreateIndexDatabase(indexStorage)
 
 			/*
-			 * Check number of arguments.
+			 * Check if index database was created sucessfully.
 			 */
-			if numArgs != 3 {
-				fmt.Printf("Command '%s' expects 2 additional arguments: name, permission\n", cmd)
+			if err != nil {
+				msg := err.Error()
+				return fmt.Errorf("Failed to create index database: %s", msg)
 			} else {
-				name := args[1]
-				permission := args[2]
-				err := umgr.AddPermission(name, permission)
+				this.indexDatabase = indexDB
+				imageStorage := container.ImageStorage()
+				imageDB, err := tiledb.CreateImageDatabase(imageStorage)
 
 				/*
-				 * Check if something went wrong.
+				 * Check if image database was created successfully.
 				 */
 				if err != nil {
 					msg := err.Error()
-					fmt.Printf("Command '%s' failed: %s\n", cmd, msg)
+					return fmt.Errorf("Failed to create image database: %s", msg)
 				} else {
-					err = this.syncUserDB()
-
-					/*
-					 * Check if something went wrong.
-					 */
-					if err != nil {
-						msg := err.Error()
-						fmt.Printf("%s\n", msg)
-					}
-
+					this.imageDatabase = imageDB
+					util := tileutil.CreateTileUtil(indexDB, imageDB)
+					this.tileUtil = util
+					return nil
 				}
 
 			}
 
-		case "cleanup-tiles":
+		}
+
+	}
+
+}
+
+/*
+ * Initialize tile database.
+ *
+ * If a combined tile database is configured, it takes precedence over
+ * separate index and image databases.
+ */
+func (this *controllerStruct) initializeTileDatabase() error {
+	config := this.getConfig()
+	tileDB := config.TileDB
+	combinedPath := tileDB.Combined
+	indexDBPath := tileDB.IndexDB
+	imageDBPath := tileDB.ImageDB
+	useMap := config.UseMap
+	errResult := error(nil)
+
+	/*
+	 * Create index and image databases if map should be used and database
+	 * paths are set.
+	 */
+	if useMap && combinedPath != "" {
+		errResult = this.initializeCombinedTileDatabase(combinedPath)
+	} else if useMap && indexDBPath != "" && imageDBPath != "" {
+		modeIndexDB := os.ModeExclusive | (os.ModePerm & PERMISSIONS_INDEXDB)
+		fdIndexDB, err := os.OpenFile(indexDBPath, os.O_RDWR|os.O_CREATE, modeIndexDB)
+
+		/*
+		 * Check if file could be opened.
+		 */
+		if err != nil {
+			msg := err.Error()
+			errResult = fmt.Errorf("Failed to open file '%s': %s", indexDBPath, msg)
+		} else {
+			indexDB, err := tiledb.CreateIndexDatabase(fdIndexDB)
 
 			/*
-			 * Check number of arguments.
+			 * Check if index database was created sucessfully.
 			 */
-			if numArgs != 1 {
-				fmt.Printf("Command '%s' expects no additional arguments.\n", cmd)
+			if err != nil {
+				msg := err.Error()
+				errResult = fmt.Errorf("Failed to create index database: %s", msg)
 			} else {
-				err := this.initializeTileDatabase()
+				this.indexDatabase = indexDB
+				modeImageDB := os.ModeExclusive | (os.ModePerm & PERMISSIONS_IMAGEDB)
+				fdImageDb, err := os.OpenFile(imageDBPath, os.O_RDWR|os.O_CREATE, modeImageDB)
 
 				/*
-				 * Check if tile database could be initialized.
+				 * Check if file could be opened
 				 */
 				if err != nil {
 					msg := err.Error()
-					fmt.Printf("Failed to initialize tile database: %s", msg)
+					errResult = fmt.Errorf("Failed to open file '%s': %s", imageDBPath, msg)
 				} else {
-					util := this.tileUtil
-					err := util.Cleanup()
+					imageDB, err := tiledb.CreateImageDatabase(fdImageDb)
 
 					/*
-					 * Check if errors occured during cleanup.
+					 * Check if image database was created successfully.
 					 */
 					if err != nil {
 						msg := err.Error()
-						fmt.Printf("Failed to cleanup tiles: %s\n", msg)
+						errResult = fmt.Errorf("Failed to create image database: %s", msg)
+					} else {
+						this.imageDatabase = imageDB
+						util := tileutil.CreateTileUtil(indexDB, imageDB)
+						this.tileUtil = util
 					}
 
 				}
 
 			}
 
-		case "clear-password":
+		}
 
-			/*
-			 * Check number of arguments.
-			 */
-			if numArgs != 2 {
-				fmt.Printf("Command '%s' expects 1 additional argument: name\n", cmd)
-			} else {
-				name := args[1]
-				err := umgr.SetPassword(name, "")
+	}
 
-				/*
-				 * Check if something went wrong.
-				 */
-				if err != nil {
-					msg := err.Error()
-					fmt.Printf("Command '%s' failed: %s\n", cmd, msg)
-				} else {
-					err = this.syncUserDB()
+	tileUtil := this.tileUtil
 
-					/*
-					 * Check if something went wrong.
-					 */
-					if err != nil {
-						msg := err.Error()
-						fmt.Printf("%s\n", msg)
-					}
+	/*
+	 * Set maximum age of cached tiles and pre-fetch limits if map
+	 * integration is enabled.
+	 */
+	if (errResult == nil) && (tileUtil != nil) {
+		maxAge, err := this.parseTileMaxAge(config)
+		limits := config.Limits
+		prefetchDelay, errPrefetchDelay := this.parsePrefetchDelay(limits)
 
-				}
+		/*
+		 * Check if maximum age and pre-fetch delay could be parsed.
+		 */
+		if err != nil {
+			errResult = err
+		} else if errPrefetchDelay != nil {
+			errResult = errPrefetchDelay
+		} else {
+			tileUtil.SetMaxAge(maxAge)
+			prefetchWorkers := limits.PrefetchWorkers
+			tileUtil.SetPrefetchLimits(prefetchWorkers, prefetchDelay)
+		}
 
-			}
+	}
 
-		case "create-user":
+	return errResult
+}
+
+/*
+ * Performs the first-run setup if the user database is empty.
+ *
+ * Depending on the configuration, this either creates an administrator with a
+ * generated password, prints instructions for the setup CGI or does nothing.
+ */
+func (this *controllerStruct) initializeSetup() {
+	umgr := this.userManager
+	users := umgr.Users()
+	numUsers := len(users)
+
+	/*
+	 * Only perform setup if there are no users yet.
+	 */
+	if numUsers == 0 {
+		conf := this.getConfig()
+		setup := conf.Setup
+		mode := setup.Mode
+		name := setup.User
+
+		/*
+		 * Use default user name if none is configured.
+		 */
+		if name == "" {
+			name = DEFAULT_SETUP_USER
+		}
+
+		/*
+		 * Decide on the setup mode.
+		 */
+		switch mode {
+		case "", SETUP_MODE_PASSWORD:
+			r := rand.SystemPRNG()
+			buf := make([]byte, SIZE_SETUP_PASSWORD)
+			_, err := io.ReadFull(r, buf)
 
 			/*
-			 * Check number of arguments.
+			 * Check if password could be generated.
 			 */
-			if numArgs != 2 {
-				fmt.Printf("Command '%s' expects 1 additional argument: name\n", cmd)
+			if err != nil {
+				fmt.Printf("%s\n", "First-run setup failed: Failed to obtain entropy from system.")
 			} else {
-				name := args[1]
-				err := umgr.CreateUser(name)
+				password := base64.RawURLEncoding.EncodeToString(buf)
+				err = this.createAdminUser(name, password)
 
 				/*
-				 * Check if something went wrong.
+				 * Check if administrator could be created.
 				 */
 				if err != nil {
 					msg := err.Error()
-					fmt.Printf("Command '%s' failed: %s\n", cmd, msg)
+					fmt.Printf("First-run setup failed: %s\n", msg)
 				} else {
-					err = this.syncUserDB()
-
-					/*
-					 * Check if something went wrong.
-					 */
-					if err != nil {
-						msg := err.Error()
-						fmt.Printf("%s\n", msg)
-					}
-
+					fmt.Printf("%s\n", "The user database was empty, so an administrator with all permissions was created.")
+					fmt.Printf("User name: %s\n", name)
+					fmt.Printf("Initial password: %s\n", password)
+					fmt.Printf("Change the password using: ./locviz set-password %s <new password>\n", name)
 				}
 
 			}
 
-		case "export-tiles":
+		case SETUP_MODE_CGI:
+			fmt.Printf("%s\n", "The user database is empty. Create the first user using the 'setup' CGI.")
+		case SETUP_MODE_DISABLED:
+			fmt.Printf("%s\n", "The user database is empty. Create a user using: ./locviz create-user <name>")
+		default:
+			fmt.Printf("Unknown setup mode '%s'. The user database is empty. Create a user using: ./locviz create-user <name>\n", mode)
+		}
 
-			/*
-			 * Check number of arguments.
-			 */
-			if numArgs != 2 {
-				fmt.Printf("Command '%s' expects 1 additional argument: path\n", cmd)
-			} else {
-				err := this.initializeTileDatabase()
+	}
 
-				/*
-				 * Check if tile database could be initialized.
-				 */
-				if err != nil {
-					msg := err.Error()
-					fmt.Printf("Failed to initialize tile database: %s", msg)
-				} else {
-					path := args[1]
-					mode := os.ModeExclusive | (os.ModePerm & PERMISSIONS_USERDB)
-					fd, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode)
+}
 
-					/*
-					 * Check if file could be opened.
-					 */
-					if err != nil {
-						msg := err.Error()
-						fmt.Printf("Failed to create file '%s': %s\n", path, msg)
-					} else {
-						util := this.tileUtil
-						creationTime := time.Now()
-						err := util.Export(fd, creationTime)
+/*
+ * Initialize tile servers.
+ *
+ * Tiles may be pre-fetched from different servers than the ones used to fetch
+ * tiles on demand. Both default to the map servers. When map tiles shall be
+ * served from cache only, no server is used to fetch tiles on demand.
+ */
+func (this *controllerStruct) initializeTileServer() {
+	config := this.getConfig()
+	uris := config.MapServer
+	prefetchUris := config.MapServerPrefetch
+	serveUris := config.MapServerServe
+	subdomains := config.MapServerSubdomains
+	userAgent := config.MapServerUserAgent
+	minInterval := this.parseMapServerMinInterval(config)
+	maxAttempts := config.MapServerMaxAttempts
+	retryDelay := this.parseMapServerRetryDelay(config)
+	cacheOnly := config.MapCacheOnly
+	useMap := config.UseMap
 
-						/*
-						 * Check if errors occured during export.
-						 */
-						if err != nil {
-							msg := err.Error()
-							fmt.Printf("Failed to export tiles: %s\n", msg)
-						}
+	/*
+	 * Use map servers for pre-fetching if no other servers are configured.
+	 */
+	if len(prefetchUris) == 0 {
+		prefetchUris = uris
+	}
 
-					}
+	/*
+	 * Use map servers for serving if no other servers are configured.
+	 */
+	if len(serveUris) == 0 {
+		serveUris = uris
+	}
 
-					err = fd.Close()
+	/*
+	 * Create OSM tile sources if map should be used
+	 * and cache path is set.
+	 */
+	if useMap {
+		limits := config.Limits
+		prefetchWorkers := limits.PrefetchWorkers
+		prefetchSrv := tileserver.CreateOSMTileServer(prefetchUris, subdomains, userAgent, prefetchWorkers, minInterval, maxAttempts, retryDelay)
+		this.tilePrefetchServer = prefetchSrv
 
-					/*
-					 * Check if file could be closed.
-					 */
-					if err != nil {
-						msg := err.Error()
-						fmt.Printf("Failed to close file '%s': %s\n", path, msg)
-					}
+		/*
+		 * Only fetch tiles on demand if we do not serve from cache only.
+		 */
+		if cacheOnly {
+			this.tileServer = nil
+		} else {
+			srv := tileserver.CreateOSMTileServer(serveUris, subdomains, userAgent, 1, minInterval, maxAttempts, retryDelay)
+			this.tileServer = srv
+		}
 
-				}
+	} else {
+		this.tilePrefetchServer = nil
+		this.tileServer = nil
+	}
+
+}
+
+/*
+ * Overrides a single configuration value from the environment.
+ *
+ * Structures are traversed recursively, appending the name of each field to
+ * the name of the environment variable. Lists are given as comma-separated
+ * values. Maps cannot be overridden.
+ */
+func (this *controllerStruct) applyEnvironmentValue(name string, value reflect.Value) error {
+	kind := value.Kind()
+
+	/*
+	 * Traverse structures recursively.
+	 */
+	if kind == reflect.Struct {
+		valueType := value.Type()
+		numFields := valueType.NumField()
+
+		/*
+		 * Iterate over all fields of the structure.
+		 */
+		for i := 0; i < numFields; i++ {
+			field := valueType.Field(i)
+			fieldName := strings.ToUpper(field.Name)
+			fieldEnvName := name + ENVIRONMENT_SEPARATOR + fieldName
+			fieldValue := value.Field(i)
+			err := this.applyEnvironmentValue(fieldEnvName, fieldValue)
 
+			/*
+			 * Abort on first error.
+			 */
+			if err != nil {
+				return err
 			}
 
-		case "has-permission":
+		}
+
+		return nil
+	} else {
+		env, ok := os.LookupEnv(name)
+
+		/*
+		 * Only override values which are set in the environment.
+		 */
+		if !ok {
+			return nil
+		} else {
 
 			/*
-			 * Check number of arguments.
+			 * Parse the value according to its type.
 			 */
-			if numArgs != 3 {
-				fmt.Printf("Command '%s' expects 2 additional arguments: name, permission\n", cmd)
-			} else {
-				name := args[1]
-				permission := args[2]
-				result, err := umgr.HasPermission(name, permission)
+			switch kind {
+			case reflect.String:
+				value.SetString(env)
+				return nil
+			case reflect.Bool:
+				b, err := strconv.ParseBool(env)
 
 				/*
-				 * Check if something went wrong.
+				 * Check if value could be parsed.
 				 */
 				if err != nil {
-					msg := err.Error()
-					fmt.Printf("Command '%s' failed: %s\n", cmd, msg)
+					return fmt.Errorf("Environment variable '%s' must be a boolean.", name)
 				} else {
-					resultString := strconv.FormatBool(result)
-					fmt.Printf("%s\n", resultString)
+					value.SetBool(b)
+					return nil
 				}
 
-			}
+			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+				bits := value.Type().Bits()
+				n, err := strconv.ParseInt(env, 10, bits)
 
-		case "import-tiles":
+				/*
+				 * Check if value could be parsed.
+				 */
+				if err != nil {
+					return fmt.Errorf("Environment variable '%s' must be an integer.", name)
+				} else {
+					value.SetInt(n)
+					return nil
+				}
 
-			/*
-			 * Check number of arguments.
-			 */
-			if numArgs != 2 {
-				fmt.Printf("Command '%s' expects 1 additional argument: path\n", cmd)
-			} else {
-				err := this.initializeTileDatabase()
+			case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+				bits := value.Type().Bits()
+				n, err := strconv.ParseUint(env, 10, bits)
 
 				/*
-				 * Check if tile database could be initialized.
+				 * Check if value could be parsed.
 				 */
 				if err != nil {
-					msg := err.Error()
-					fmt.Printf("Failed to initialize tile database: %s", msg)
+					return fmt.Errorf("Environment variable '%s' must be an unsigned integer.", name)
 				} else {
-					path := args[1]
-					mode := os.ModeExclusive | (os.ModePerm & PERMISSIONS_USERDB)
-					fd, err := os.OpenFile(path, os.O_RDONLY, mode)
+					value.SetUint(n)
+					return nil
+				}
+
+			case reflect.Float32, reflect.Float64:
+				bits := value.Type().Bits()
+				f, err := strconv.ParseFloat(env, bits)
+
+				/*
+				 * Check if value could be parsed.
+				 */
+				if err != nil {
+					return fmt.Errorf("Environment variable '%s' must be a number.", name)
+				} else {
+					value.SetFloat(f)
+					return nil
+				}
+
+			case reflect.Slice:
+				elemKind := value.Type().Elem().Kind()
+
+				/*
+				 * Only lists of strings can be overridden.
+				 */
+				if elemKind != reflect.String {
+					return fmt.Errorf("Environment variable '%s' is not supported.", name)
+				} else {
+					items := []string{}
 
 					/*
-					 * Check if file could be opened.
+					 * An empty value denotes an empty list.
 					 */
-					if err != nil {
-						msg := err.Error()
-						fmt.Printf("Failed to open file '%s': %s\n", path, msg)
-					} else {
-						util := this.tileUtil
-						err := util.Import(fd)
+					if env != "" {
+						parts := strings.Split(env, ",")
 
 						/*
-						 * Check if errors occured during import.
+						 * Trim whitespace around each item.
 						 */
-						if err != nil {
-							msg := err.Error()
-							fmt.Printf("Failed to import tiles: %s\n", msg)
+						for _, part := range parts {
+							item := strings.TrimSpace(part)
+							items = append(items, item)
 						}
 
 					}
 
-					err = fd.Close()
-
-					/*
-					 * Check if file could be closed.
-					 */
-					if err != nil {
-						msg := err.Error()
-						fmt.Printf("Failed to close file '%s': %s\n", path, msg)
-					}
-
+					itemsValue := reflect.ValueOf(items)
+					value.Set(itemsValue)
+					return nil
 				}
 
+			default:
+				return fmt.Errorf("Environment variable '%s' is not supported.", name)
 			}
 
-		case "list-permissions":
+		}
 
-			/*
-			 * Check number of arguments.
-			 */
-			if numArgs != 2 {
-				fmt.Printf("Command '%s' expects 1 additional argument: name\n", cmd)
-			} else {
-				name := args[1]
-				permissions, err := umgr.Permissions(name)
+	}
 
-				/*
-				 * Check if something went wrong.
-				 */
-				if err != nil {
-					msg := err.Error()
-					fmt.Printf("Command '%s' failed: %s\n", cmd, msg)
-				} else {
+}
 
-					/*
-					 * Print each permission on a new line.
-					 */
-					for _, permission := range permissions {
-						fmt.Printf("%s\n", permission)
-					}
+/*
+ * Overrides configuration values with values from the environment.
+ *
+ * The name of the environment variable is derived from the path of the field
+ * within the configuration, e. g. LOCVIZ_LOCATIONDB or LOCVIZ_LIMITS_MAXAXIS.
+ */
+func (this *controllerStruct) applyEnvironment(config configStruct) (configStruct, error) {
+	value := reflect.ValueOf(&config).Elem()
+	err := this.applyEnvironmentValue(ENVIRONMENT_PREFIX, value)
+	return config, err
+}
 
-				}
+/*
+ * Resolves the paths of all databases relative to the data directory.
+ *
+ * Paths explicitly set in the configuration take precedence. Paths left empty
+ * default to well-known names beneath the data directory, if one is set.
+ */
+func (this *controllerStruct) resolvePaths(config configStruct) configStruct {
+	dataDir := config.DataDir
 
-			}
+	/*
+	 * Only resolve paths if a data directory is set.
+	 */
+	if dataDir != "" {
 
-		case "list-users":
+		/*
+		 * Resolve a single path.
+		 */
+		resolve := func(path string, defaultName string) string {
 
 			/*
-			 * Check number of arguments.
+			 * Use default name beneath data directory if path is not set.
 			 */
-			if numArgs != 1 {
-				fmt.Printf("Command '%s' expects no additional arguments.\n", cmd)
-			} else {
-				users := umgr.Users()
+			if path == "" {
+				path = filepath.Join(dataDir, defaultName)
+			}
 
-				/*
-				 * Print each user on a new line.
-				 */
-				for _, user := range users {
-					fmt.Printf("%s\n", user)
-				}
+			return path
+		}
 
-			}
+		config.ActivityDB = resolve(config.ActivityDB, DEFAULT_NAME_ACTIVITYDB)
+		config.LocationDB = resolve(config.LocationDB, DEFAULT_NAME_LOCATIONDB)
+		config.UserDB = resolve(config.UserDB, DEFAULT_NAME_USERDB)
+		userData := config.UserData
+		userData.Path = resolve(userData.Path, DEFAULT_NAME_USERDATA)
+		config.UserData = userData
+		tileDB := config.TileDB
+		tileDB.ImageDB = resolve(tileDB.ImageDB, DEFAULT_NAME_IMAGEDB)
+		tileDB.IndexDB = resolve(tileDB.IndexDB, DEFAULT_NAME_INDEXDB)
+		config.TileDB = tileDB
+	}
 
-		case "remove-permission":
+	return config
+}
 
-			/*
-			 * Check number of arguments.
-			 */
-			if numArgs != 3 {
-				fmt.Printf("Command '%s' expects 2 additional arguments: name, permission\n", cmd)
-			} else {
-				name := args[1]
-				permission := args[2]
-				err := umgr.RemovePermission(name, permission)
+/*
+ * Prints the effective locations of all databases.
+ */
+func (this *controllerStruct) printPaths() {
+	config := this.getConfig()
+	activityDBPath := config.ActivityDB
+	locationDBPath := config.LocationDB
+	userDBPath := config.UserDB
+	userData := config.UserData
+	tileDB := config.TileDB
+	combinedPath := tileDB.Combined
+	imageDBPath := tileDB.ImageDB
+	indexDBPath := tileDB.IndexDB
+	fmt.Printf("Activity database: %s\n", activityDBPath)
+	fmt.Printf("Location database: %s\n", locationDBPath)
+	fmt.Printf("User database: %s\n", userDBPath)
 
-				/*
-				 * Check if something went wrong.
-				 */
-				if err != nil {
-					msg := err.Error()
-					fmt.Printf("Command '%s' failed: %s\n", cmd, msg)
-				} else {
-					err = this.syncUserDB()
+	/*
+	 * Print the directory holding per-user data if it is enabled.
+	 */
+	if userData.Enabled {
+		userDataPath := userData.Path
+		fmt.Printf("Per-user data: %s\n", userDataPath)
+	}
 
-					/*
-					 * Check if something went wrong.
-					 */
-					if err != nil {
-						msg := err.Error()
-						fmt.Printf("%s\n", msg)
-					}
+	/*
+	 * Print either the combined or the separate tile databases.
+	 */
+	if combinedPath != "" {
+		fmt.Printf("Tile database: %s\n", combinedPath)
+	} else {
+		fmt.Printf("Tile image database: %s\n", imageDBPath)
+		fmt.Printf("Tile index database: %s\n", indexDBPath)
+	}
 
-				}
+}
 
-			}
+/*
+ * Reads the configuration file, applies the environment and resolves paths.
+ */
+func (this *controllerStruct) loadConfig() (configStruct, error) {
+	content, err := os.ReadFile(CONFIG_PATH)
 
-		case "remove-user":
+	/*
+	 * Check if file could be read.
+	 */
+	if err != nil {
+		return configStruct{}, fmt.Errorf("Failed to open config file: '%s'", CONFIG_PATH)
+	} else {
+		config := configStruct{}
+		err = json.Unmarshal(content, &config)
+
+		/*
+		 * Check if file failed to unmarshal.
+		 */
+		if err != nil {
+			msg := this.describeDecodeError(content, err)
+			return configStruct{}, fmt.Errorf("Failed to decode config file '%s': %s", CONFIG_PATH, msg)
+		} else {
+			config, err = this.applyEnvironment(config)
 
 			/*
-			 * Check number of arguments.
+			 * Check if environment could be applied.
 			 */
-			if numArgs != 2 {
-				fmt.Printf("Command '%s' expects 1 additional argument: name\n", cmd)
+			if err != nil {
+				msg := err.Error()
+				return configStruct{}, fmt.Errorf("Failed to apply environment: %s", msg)
 			} else {
-				name := args[1]
-				err := umgr.RemoveUser(name)
+				config = this.resolvePaths(config)
+				err = this.validateConfig(config)
 
 				/*
-				 * Check if something went wrong.
+				 * Check if configuration is valid.
 				 */
 				if err != nil {
-					msg := err.Error()
-					fmt.Printf("Command '%s' failed: %s\n", cmd, msg)
+					return configStruct{}, err
 				} else {
-					err = this.syncUserDB()
-
-					/*
-					 * Check if something went wrong.
-					 */
-					if err != nil {
-						msg := err.Error()
-						fmt.Printf("%s\n", msg)
-					}
-
+					return config, nil
 				}
 
 			}
 
-		case "set-password":
+		}
 
-			/*
-			 * Check number of arguments.
-			 */
-			if numArgs != 3 {
-				fmt.Printf("Command '%s' expects 2 additional arguments: name, password\n", cmd)
-			} else {
-				name := args[1]
-				password := args[2]
-				err := umgr.SetPassword(name, password)
+	}
 
-				/*
-				 * Check if something went wrong.
-				 */
-				if err != nil {
-					msg := err.Error()
-					fmt.Printf("Command '%s' failed: %s\n", cmd, msg)
-				} else {
-					err = this.syncUserDB()
+}
 
-					/*
-					 * Check if something went wrong.
-					 */
-					if err != nil {
-						msg := err.Error()
-						fmt.Printf("%s\n", msg)
-					}
+/*
+ * Describes why a configuration file could not be decoded, including the
+ * position of syntax errors and the name of fields of the wrong type.
+ */
+func (this *controllerStruct) describeDecodeError(content []byte, err error) string {
+	syntaxErr := (*json.SyntaxError)(nil)
+	typeErr := (*json.UnmarshalTypeError)(nil)
 
-				}
+	/*
+	 * Check which kind of error occured.
+	 */
+	if errors.As(err, &syntaxErr) {
+		offset := syntaxErr.Offset
+		prefix := content[:offset]
+		line := bytes.Count(prefix, []byte{'\n'}) + 1
+		lineStart := bytes.LastIndexByte(prefix, '\n') + 1
+		column := len(prefix) - lineStart
+		msg := syntaxErr.Error()
+		return fmt.Sprintf("Syntax error in line %d, column %d: %s", line, column, msg)
+	} else if errors.As(err, &typeErr) {
+		field := typeErr.Field
+		value := typeErr.Value
+		expected := typeErr.Type
+		return fmt.Sprintf("Field '%s' must be of type %s, but was %s.", field, expected, value)
+	} else {
+		msg := err.Error()
+		return msg
+	}
+
+}
+
+/*
+ * Checks that a configuration field holds a non-negative duration, if it is
+ * set at all.
+ *
+ * Returns the problems found so far, extended by a description of the
+ * problem with this field, if any.
+ */
+func (this *controllerStruct) validateDuration(problems []string, field string, value string) []string {
 
-			}
+	/*
+	 * Empty durations are always valid.
+	 */
+	if value != "" {
+		duration, err := time.ParseDuration(value)
 
-		default:
-			fmt.Printf("Unknown command: %s\n", cmd)
+		/*
+		 * Check if duration is valid.
+		 */
+		if err != nil {
+			problem := fmt.Sprintf("%s must be a duration like '2h' or '30m', but was '%s'.", field, value)
+			problems = append(problems, problem)
+		} else if duration < 0 {
+			problem := fmt.Sprintf("%s must not be negative, but was '%s'.", field, value)
+			problems = append(problems, problem)
 		}
 
 	}
 
+	return problems
 }
 
 /*
- * Runs the server and message pump.
+ * Checks that a path in the configuration is set.
+ *
+ * Returns the problems found so far, extended by a description of the
+ * problem with this field, if any.
  */
-func (this *controllerStruct) runServer() {
-	cfg := this.config
-	serverCfg := cfg.WebServer
-	server := webserver.CreateWebServer(serverCfg)
+func (this *controllerStruct) validatePath(problems []string, field string, value string) []string {
 
 	/*
-	 * Check if we got a web server.
+	 * Report missing path.
 	 */
-	if server == nil {
-		fmt.Printf("%s\n", "Web server did not enter message loop.")
-	} else {
-		requests := server.RegisterCgi("/cgi-bin/locviz")
-		server.Run()
-		protocol := "https"
-		port := serverCfg.TLSPort
-		tlsDisabled := serverCfg.TLSDisabled
+	if value == "" {
+		problem := fmt.Sprintf("%s must not be empty. Set it or set DataDir.", field)
+		problems = append(problems, problem)
+	}
 
-		if tlsDisabled {
-			protocol = "http"
-			port = serverCfg.Port
-		}
+	return problems
+}
 
-		fmt.Printf("Web interface ready: %s://localhost:%s/\n", protocol, port)
+/*
+ * Checks a configuration for values which are missing or out of range.
+ *
+ * All problems found are reported together, each naming the field concerned.
+ */
+func (this *controllerStruct) validateConfig(config configStruct) error {
+	problems := []string{}
 
-		/*
-		 * A worker processing HTTP requests.
-		 */
-		worker := func(requests <-chan webserver.HttpRequest) {
+	problems = this.validatePath(problems, "ActivityDB", config.ActivityDB)
+	problems = this.validatePath(problems, "LocationDB", config.LocationDB)
+	problems = this.validatePath(problems, "UserDB", config.UserDB)
+	userData := config.UserData
 
-			/*
-			 * This is the actual message pump.
-			 */
-			for request := range requests {
-				response := this.dispatch(request)
-				respond := request.Respond
-				respond <- response
-			}
+	/*
+	 * A directory for per-user data is only required if it is enabled.
+	 */
+	if userData.Enabled {
+		problems = this.validatePath(problems, "UserData.Path", userData.Path)
+	}
 
-		}
+	activityTypes := map[string]bool{}
 
-		numCPU := runtime.NumCPU()
+	/*
+	 * User-defined activity types must have valid and unique names.
+	 */
+	for _, name := range config.ActivityTypes {
+		err := meta.ValidateActivityName(name)
 
 		/*
-		 * Spawn as many workers as we have CPUs.
+		 * Check if name is valid and unique.
 		 */
-		for i := 0; i < numCPU; i++ {
-			go worker(requests)
+		if err != nil {
+			msg := err.Error()
+			problem := fmt.Sprintf("ActivityTypes contains an invalid name: %s", msg)
+			problems = append(problems, problem)
+		} else if activityTypes[name] {
+			problem := fmt.Sprintf("ActivityTypes contains '%s' more than once.", name)
+			problems = append(problems, problem)
 		}
 
-		stdin := os.Stdin
-		scanner := bufio.NewScanner(stdin)
+		activityTypes[name] = true
+	}
+
+	tileDB := config.TileDB
+
+	/*
+	 * Paths to separate tile databases are only required if the map is
+	 * used and no combined tile database is configured.
+	 */
+	if config.UseMap && (tileDB.Combined == "") {
+		problems = this.validatePath(problems, "TileDB.ImageDB", tileDB.ImageDB)
+		problems = this.validatePath(problems, "TileDB.IndexDB", tileDB.IndexDB)
+	}
+
+	mapServer := config.MapServer
+	mapServerPrefetch := config.MapServerPrefetch
+	mapServerServe := config.MapServerServe
+	dedicatedMapServers := (len(mapServerPrefetch) > 0) && (len(mapServerServe) > 0)
+
+	/*
+	 * A map server is required if the map is used, unless dedicated
+	 * servers are configured for both pre-fetching and serving tiles.
+	 */
+	if config.UseMap && (len(mapServer) == 0) && !dedicatedMapServers {
+		problems = append(problems, "MapServer must not be empty if UseMap is true.")
+	}
+
+	allMapServers := []string{}
+	allMapServers = append(allMapServers, mapServer...)
+	allMapServers = append(allMapServers, mapServerPrefetch...)
+	allMapServers = append(allMapServers, mapServerServe...)
+	numSubdomains := len(config.MapServerSubdomains)
+	maxAttempts := config.MapServerMaxAttempts
+
+	/*
+	 * Limit number of attempts, since the delay doubles with every attempt.
+	 */
+	if maxAttempts > tileserver.MAX_ATTEMPTS {
+		problem := fmt.Sprintf("MapServerMaxAttempts must not exceed %d, but was %d.", tileserver.MAX_ATTEMPTS, maxAttempts)
+		problems = append(problems, problem)
+	}
+
+	/*
+	 * Subdomains are required if a map server uses them.
+	 */
+	for _, uri := range allMapServers {
 
 		/*
-		 * Read from standard input forever.
+		 * Check if URI template contains subdomain placeholder.
 		 */
-		for {
-			scanner.Scan()
+		if strings.Contains(uri, tileserver.TEMPLATE_SUBDOMAIN) && (numSubdomains == 0) {
+			problem := fmt.Sprintf("MapServerSubdomains must not be empty, since map server '%s' uses the '%s' placeholder.", uri, tileserver.TEMPLATE_SUBDOMAIN)
+			problems = append(problems, problem)
 		}
 
 	}
 
-}
-
-/*
- * Initialize activity data.
- */
-func (this *controllerStruct) initializeActivities() error {
-	config := this.config
-	activityDBPath := config.ActivityDB
-	contentActivityDB, err := os.ReadFile(activityDBPath)
+	problems = this.validateDuration(problems, "ActivityFlushInterval", config.ActivityFlushInterval)
+	problems = this.validateDuration(problems, "Limits.PrefetchDelay", config.Limits.PrefetchDelay)
+	problems = this.validateDuration(problems, "Limits.RenderTimeout", config.Limits.RenderTimeout)
+	problems = this.validateDuration(problems, "MapServerMinInterval", config.MapServerMinInterval)
+	problems = this.validateDuration(problems, "MapServerRetryDelay", config.MapServerRetryDelay)
+	problems = this.validateDuration(problems, "RepairTimestamps.MaxFuture", config.RepairTimestamps.MaxFuture)
+	problems = this.validateDuration(problems, "SessionExpiry", config.SessionExpiry)
+	problems = this.validateDuration(problems, "TileDB.MaxAge", config.TileDB.MaxAge)
+	problems = this.validateDuration(problems, "UploadExpiry", config.UploadExpiry)
+	earliest := config.RepairTimestamps.Earliest
 
 	/*
-	 * Check if file could be read.
+	 * Earliest time stamp for repairs must be in RFC 3339 format.
 	 */
-	if err != nil {
-		return fmt.Errorf("Failed to open activity database '%s'.", activityDBPath)
-	} else {
-		act := meta.CreateActivities()
-		err = act.Import(contentActivityDB)
-		this.activities = act
-		this.activityDBPath = activityDBPath
+	if earliest != "" {
+		_, err := time.Parse(time.RFC3339, earliest)
 
 		/*
-		 * Check if activity data could be decoded.
+		 * Check if time stamp could be parsed.
 		 */
 		if err != nil {
-			msg := err.Error()
-			return fmt.Errorf("Failed to import activity data: %s", msg)
+			problem := fmt.Sprintf("RepairTimestamps.Earliest must be a time stamp like '1980-01-06T00:00:00Z', but was '%s'.", earliest)
+			problems = append(problems, problem)
 		}
 
 	}
 
-	return nil
+	locationPrecision := config.LocationPrecision
+
+	/*
+	 * Locations are stored with at most seven decimal places.
+	 */
+	if locationPrecision > geodb.PRECISION_MAX {
+		problem := fmt.Sprintf("LocationPrecision must be between 1 and %d (or 0 for full precision), but was %d.", geodb.PRECISION_MAX, locationPrecision)
+		problems = append(problems, problem)
+	}
+
+	home := config.Home
+
+	/*
+	 * Check that the home zone lies on earth.
+	 */
+	if (home.Latitude < -90.0) || (home.Latitude > 90.0) {
+		problem := fmt.Sprintf("Home.Latitude must be between -90 and 90 degrees, but was %g.", home.Latitude)
+		problems = append(problems, problem)
+	}
+
+	/*
+	 * Check longitude of home zone.
+	 */
+	if (home.Longitude < -180.0) || (home.Longitude > 180.0) {
+		problem := fmt.Sprintf("Home.Longitude must be between -180 and 180 degrees, but was %g.", home.Longitude)
+		problems = append(problems, problem)
+	}
+
+	/*
+	 * Check radius of home zone.
+	 */
+	if home.Radius < 0.0 {
+		problem := fmt.Sprintf("Home.Radius must not be negative, but was %g.", home.Radius)
+		problems = append(problems, problem)
+	}
+
+	/*
+	 * Check maximum plausible speed.
+	 */
+	if config.MaxSpeed < 0.0 {
+		problem := fmt.Sprintf("MaxSpeed must not be negative (or 0 for no limit), but was %g.", config.MaxSpeed)
+		problems = append(problems, problem)
+	}
+
+	limits := config.Limits
+
+	/*
+	 * Images must be allowed to have at least one pixel.
+	 */
+	if limits.MaxAxis == 0 {
+		problems = append(problems, "Limits.MaxAxis must be positive, but was 0.")
+	}
+
+	/*
+	 * Check limit on number of pixels.
+	 */
+	if limits.MaxPixels == 0 {
+		problems = append(problems, "Limits.MaxPixels must be positive, but was 0.")
+	}
+
+	/*
+	 * An aspect ratio is never less than one.
+	 */
+	if (limits.MaxAspectRatio != 0.0) && (limits.MaxAspectRatio < 1.0) {
+		problem := fmt.Sprintf("Limits.MaxAspectRatio must be at least 1 (or 0 for no limit), but was %g.", limits.MaxAspectRatio)
+		problems = append(problems, problem)
+	}
+
+	/*
+	 * Check number of workers.
+	 */
+	if limits.Workers < 0 {
+		problem := fmt.Sprintf("Limits.Workers must be at least 1 (or 0 to use the number of CPUs), but was %d.", limits.Workers)
+		problems = append(problems, problem)
+	}
+
+	defaults := config.RenderDefaults
+
+	/*
+	 * Default resolution must be positive.
+	 */
+	if (defaults.XRes == 0) || (defaults.YRes == 0) {
+		problem := fmt.Sprintf("RenderDefaults.XRes and RenderDefaults.YRes must be positive, but were %d and %d.", defaults.XRes, defaults.YRes)
+		problems = append(problems, problem)
+	}
+
+	/*
+	 * Check default foreground color.
+	 */
+	switch defaults.FgColor {
+	case "", "red", "green", "blue", "yellow", "cyan", "magenta", "gray", "brightblue", "white":
+	default:
+		problem := fmt.Sprintf("RenderDefaults.FgColor must be one of red, green, blue, yellow, cyan, magenta, gray, brightblue or white (or empty), but was '%s'.", defaults.FgColor)
+		problems = append(problems, problem)
+	}
+
+	/*
+	 * Check default render mode.
+	 */
+	switch defaults.Mode {
+	case "", RENDER_MODE_LINES, RENDER_MODE_POINTS:
+	default:
+		problem := fmt.Sprintf("RenderDefaults.Mode must be one of %s or %s (or empty), but was '%s'.", RENDER_MODE_POINTS, RENDER_MODE_LINES, defaults.Mode)
+		problems = append(problems, problem)
+	}
+
+	problems = this.validateDuration(problems, "RenderDefaults.MaxGap", defaults.MaxGap)
+
+	public := config.PublicRender
+
+	/*
+	 * Public renders must be allowed to have at least one pixel.
+	 */
+	if public.Enabled && (public.MaxAxis == 0) {
+		problems = append(problems, "PublicRender.MaxAxis must be positive if PublicRender is enabled, but was 0.")
+	}
+
+	mode := config.Setup.Mode
+
+	/*
+	 * Check setup mode.
+	 */
+	switch mode {
+	case "", SETUP_MODE_CGI, SETUP_MODE_DISABLED, SETUP_MODE_PASSWORD:
+	default:
+		problem := fmt.Sprintf("Setup.Mode must be one of %s, %s or %s, but was '%s'.", SETUP_MODE_PASSWORD, SETUP_MODE_CGI, SETUP_MODE_DISABLED, mode)
+		problems = append(problems, problem)
+	}
+
+	serverConfig := config.WebServer
+
+	/*
+	 * The HTTP port is always required, since it redirects to HTTPS if
+	 * TLS is enabled.
+	 */
+	if serverConfig.Port == "" {
+		problems = append(problems, "WebServer.Port must not be empty.")
+	}
+
+	/*
+	 * A TLS port is required unless TLS is disabled.
+	 */
+	if !serverConfig.TLSDisabled && (serverConfig.TLSPort == "") {
+		problems = append(problems, "WebServer.TLSPort must not be empty unless TLSDisabled is true.")
+	}
+
+	/*
+	 * The web interface must be served from somewhere.
+	 */
+	if serverConfig.WebRoot == "" {
+		problems = append(problems, "WebServer.WebRoot must not be empty.")
+	}
+
+	numProblems := len(problems)
+
+	/*
+	 * Report all problems at once.
+	 */
+	if numProblems > 0 {
+		msg := strings.Join(problems, " ")
+		return fmt.Errorf("Invalid configuration: %s", msg)
+	} else {
+		return nil
+	}
+
 }
 
 /*
- * Initialize user database.
+ * Parses the minimum interval between two requests to a map server from the
+ * configuration.
+ *
+ * Returns zero, which does not throttle requests, if no valid interval is
+ * configured.
  */
-func (this *controllerStruct) initializeUserDB() error {
-	config := this.config
-	userDBPath := config.UserDB
-	contentUserDB, err := os.ReadFile(userDBPath)
+func (this *controllerStruct) parseMapServerMinInterval(config configStruct) time.Duration {
+	minIntervalString := config.MapServerMinInterval
+	minInterval, _ := time.ParseDuration(minIntervalString)
 
 	/*
-	 * Check if file could be read.
+	 * Do not throttle requests if interval is negative.
 	 */
-	if err != nil {
-		return fmt.Errorf("Failed to open user database '%s'.", userDBPath)
-	} else {
-		r := rand.SystemPRNG()
-		seed := make([]byte, rand.SEED_SIZE)
-		_, err = r.Read(seed)
+	if minInterval < 0 {
+		minInterval = 0
+	}
 
-		/*
-		 * Check if seed could be read from system.
-		 */
-		if err != nil {
-			return fmt.Errorf("Failed to obtain entropy from system.")
-		} else {
-			prng, err := rand.CreatePRNG(seed)
+	return minInterval
+}
 
-			/*
-			 * Check if PRNG could be created.
-			 */
-			if err != nil {
-				msg := err.Error()
-				return fmt.Errorf("Failed to create pseudo-random number generator: %s", msg)
-			} else {
-				userManager, err := user.CreateManager(prng)
+/*
+ * Parses the delay before the first retry of a failed request to a map server
+ * from the configuration.
+ *
+ * Returns zero, which retries immediately, if no valid delay is configured.
+ */
+func (this *controllerStruct) parseMapServerRetryDelay(config configStruct) time.Duration {
+	retryDelayString := config.MapServerRetryDelay
+	retryDelay, _ := time.ParseDuration(retryDelayString)
 
-				/*
-				 * Check if user manager could be created.
-				 */
-				if err != nil {
-					msg := err.Error()
-					return fmt.Errorf("Failed to create user manager: %s", msg)
-				} else {
-					this.userManager = userManager
-					this.userDBPath = userDBPath
-					err := userManager.Import(contentUserDB)
+	/*
+	 * Retry immediately if delay is negative.
+	 */
+	if retryDelay < 0 {
+		retryDelay = 0
+	}
 
-					/*
-					 * Check if user database could be imported.
-					 */
-					if err != nil {
-						msg := err.Error()
-						return fmt.Errorf("Failed to import user database: %s", msg)
-					} else {
-						expiryString := config.SessionExpiry
-						expiry, _ := time.ParseDuration(expiryString)
+	return retryDelay
+}
 
-						/*
-						 * Set default session expiry of one hour.
-						 */
-						if expiry <= 0 {
-							expiry = time.Hour
-						}
+/*
+ * Parses the minimum delay between two requests of a pre-fetch worker from
+ * the limits.
+ */
+func (this *controllerStruct) parsePrefetchDelay(limits limitsStruct) (time.Duration, error) {
+	prefetchDelayString := limits.PrefetchDelay
+	prefetchDelay := time.Duration(0)
+	err := error(nil)
 
-						sessionManager, err := session.CreateManager(userManager, prng, expiry)
+	/*
+	 * Parse pre-fetch delay if one is configured.
+	 */
+	if prefetchDelayString != "" {
+		prefetchDelay, err = time.ParseDuration(prefetchDelayString)
+	}
 
-						/*
-						 * Check if user manager could be created.
-						 */
-						if err != nil {
-							msg := err.Error()
-							return fmt.Errorf("Failed to create session manager: %s", msg)
-						} else {
-							this.sessionManager = sessionManager
-							return nil
-						}
+	/*
+	 * Make sure that the pre-fetch delay is valid.
+	 */
+	if err != nil {
+		return 0, fmt.Errorf("Failed to parse pre-fetch delay '%s'.", prefetchDelayString)
+	} else if prefetchDelay < 0 {
+		return 0, fmt.Errorf("Pre-fetch delay must not be negative, but was '%s'.", prefetchDelayString)
+	} else {
+		return prefetchDelay, nil
+	}
+
+}
+
+/*
+ * Parses the render timeout from the limits.
+ */
+func (this *controllerStruct) parseRenderTimeout(limits limitsStruct) (time.Duration, error) {
+	renderTimeoutString := limits.RenderTimeout
+	renderTimeout := time.Duration(0)
+	err := error(nil)
 
-					}
+	/*
+	 * Parse render timeout if one is configured.
+	 */
+	if renderTimeoutString != "" {
+		renderTimeout, err = time.ParseDuration(renderTimeoutString)
+	}
 
-				}
+	/*
+	 * Make sure that the render timeout is valid.
+	 */
+	if err != nil {
+		return 0, fmt.Errorf("Failed to parse render timeout '%s'.", renderTimeoutString)
+	} else if renderTimeout < 0 {
+		return 0, fmt.Errorf("Render timeout must not be negative, but was '%s'.", renderTimeoutString)
+	} else {
+		return renderTimeout, nil
+	}
 
-			}
+}
 
-		}
+/*
+ * Parses the session expiry from the configuration.
+ *
+ * Defaults to one hour if no valid expiry is configured.
+ */
+func (this *controllerStruct) parseSessionExpiry(config configStruct) time.Duration {
+	expiryString := config.SessionExpiry
+	expiry, _ := time.ParseDuration(expiryString)
 
+	/*
+	 * Set default session expiry of one hour.
+	 */
+	if expiry <= 0 {
+		expiry = time.Hour
 	}
 
+	return expiry
 }
 
 /*
- * Initialize geographical database with location data.
+ * Parses the maximum age of cached tiles from the configuration.
+ *
+ * Returns zero, which keeps tiles forever, if no maximum age is configured.
  */
-func (this *controllerStruct) initializeLocationData() error {
-	config := this.config
-	locationDBPath := config.LocationDB
-	mode := os.ModeExclusive | (os.ModePerm & PERMISSIONS_USERDB)
-	fd, err := os.OpenFile(locationDBPath, os.O_RDWR|os.O_CREATE, mode)
+func (this *controllerStruct) parseTileMaxAge(config configStruct) (time.Duration, error) {
+	tileDB := config.TileDB
+	maxAgeString := tileDB.MaxAge
+	maxAge := time.Duration(0)
+	err := error(nil)
 
 	/*
-	 * Check if file could be opened.
+	 * Parse maximum age if one is configured.
+	 */
+	if maxAgeString != "" {
+		maxAge, err = time.ParseDuration(maxAgeString)
+	}
+
+	/*
+	 * Make sure that the maximum age is valid.
 	 */
 	if err != nil {
-		return fmt.Errorf("Failed to open location database file '%s'.", locationDBPath)
+		return 0, fmt.Errorf("Failed to parse maximum age of tiles '%s'.", maxAgeString)
+	} else if maxAge < 0 {
+		return 0, fmt.Errorf("Maximum age of tiles must not be negative, but was '%s'.", maxAgeString)
 	} else {
-		db, err := geodb.Create(fd)
+		return maxAge, nil
+	}
+
+}
+
+/*
+ * Returns the names of the configuration fields which differ between two
+ * configurations and can only be changed by restarting the server.
+ */
+func (this *controllerStruct) restartRequiredFields(current configStruct, config configStruct) []string {
+	currentValue := reflect.ValueOf(current)
+	configValue := reflect.ValueOf(config)
+	configType := configValue.Type()
+	numFields := configType.NumField()
+	result := []string{}
+
+	/*
+	 * Compare each field which cannot be reloaded.
+	 */
+	for i := 0; i < numFields; i++ {
+		field := configType.Field(i)
+		name := field.Name
+		currentField := currentValue.Field(i)
+		currentFieldValue := currentField.Interface()
+		configField := configValue.Field(i)
+		configFieldValue := configField.Interface()
+		equal := reflect.DeepEqual(currentFieldValue, configFieldValue)
 
 		/*
-		 * Check if database could be accessed.
+		 * Only some fields within limits and tile database
+		 * configuration require a restart.
 		 */
-		if err != nil {
-			msg := err.Error()
-			return fmt.Errorf("Failed to access location database: %s", msg)
-		} else {
-			this.locationDB = db
+		switch name {
+		case "ActivityTypes", "Endpoints", "Home", "MaxSpeed", "PublicRender", "RenderDefaults", "RepairTimestamps", "SessionExpiry", "UploadExpiry":
+		case "Limits":
+
+			/*
+			 * The number of workers is fixed at startup.
+			 */
+			if current.Limits.Workers != config.Limits.Workers {
+				result = append(result, "Limits.Workers")
+			}
+
+			/*
+			 * The number of pre-fetch workers also limits the
+			 * connections of the pre-fetch tile server, which is
+			 * created at startup.
+			 */
+			if current.Limits.PrefetchWorkers != config.Limits.PrefetchWorkers {
+				result = append(result, "Limits.PrefetchWorkers")
+			}
+
+		case "TileDB":
+			currentTileDB := current.TileDB
+			currentTileDB.MaxAge = ""
+			configTileDB := config.TileDB
+			configTileDB.MaxAge = ""
+
+			/*
+			 * Only the maximum age of tiles can be reloaded.
+			 */
+			if currentTileDB != configTileDB {
+				result = append(result, "TileDB")
+			}
+
+		default:
+
+			/*
+			 * Check if field was changed.
+			 */
+			if !equal {
+				result = append(result, name)
+			}
+
 		}
 
-		return nil
 	}
 
+	return result
 }
 
 /*
- * Initialize tile database.
+ * Re-reads the configuration file and applies the parts of it which can be
+ * changed while the server is running.
+ *
+ * These are the activity types, the enabled endpoints, the home zone, the
+ * limits except for the number of workers and pre-fetch workers, the maximum
+ * plausible speed, the public render settings, the render defaults, the
+ * timestamp repair range, the session and upload expiry and the maximum age of
+ * cached tiles. Changes to other fields are reported, but only take effect
+ * after a restart.
  */
-func (this *controllerStruct) initializeTileDatabase() error {
-	config := this.config
-	tileDB := config.TileDB
-	indexDBPath := tileDB.IndexDB
-	imageDBPath := tileDB.ImageDB
-	useMap := config.UseMap
-	errResult := error(nil)
+func (this *controllerStruct) reloadConfig() error {
+	config, err := this.loadConfig()
 
 	/*
-	 * Create index and image databases if map should be used and database
-	 * paths are set.
+	 * Check if configuration could be loaded.
 	 */
-	if useMap && indexDBPath != "" && imageDBPath != "" {
-		modeIndexDB := os.ModeExclusive | (os.ModePerm & PERMISSIONS_INDEXDB)
-		fdIndexDB, err := os.OpenFile(indexDBPath, os.O_RDWR|os.O_CREATE, modeIndexDB)
+	if err != nil {
+		return err
+	} else {
+		limits := config.Limits
+		renderTimeout, errRenderTimeout := this.parseRenderTimeout(limits)
+		maxAge, errMaxAge := this.parseTileMaxAge(config)
+		prefetchDelay, errPrefetchDelay := this.parsePrefetchDelay(limits)
 
 		/*
-		 * Check if file could be opened.
+		 * Make sure that the new configuration is valid.
 		 */
-		if err != nil {
-			msg := err.Error()
-			errResult = fmt.Errorf("Failed to open file '%s': %s", indexDBPath, msg)
+		if errRenderTimeout != nil {
+			return errRenderTimeout
+		} else if errMaxAge != nil {
+			return errMaxAge
+		} else if errPrefetchDelay != nil {
+			return errPrefetchDelay
 		} else {
-			indexDB, err := tiledb.CreateIndexDatabase(fdIndexDB)
+			current := this.getConfig()
+			restartRequired := this.restartRequiredFields(current, config)
+			currentLimits := current.Limits
+			updated := current
+			updated.ActivityTypes = config.ActivityTypes
+			updated.Endpoints = config.Endpoints
+			updated.Home = config.Home
+			updated.Limits = limits
+			updated.Limits.Workers = currentLimits.Workers
+			updated.Limits.PrefetchWorkers = currentLimits.PrefetchWorkers
+			updated.MaxSpeed = config.MaxSpeed
+			updated.PublicRender = config.PublicRender
+			updated.RenderDefaults = config.RenderDefaults
+			updated.RepairTimestamps = config.RepairTimestamps
+			updated.SessionExpiry = config.SessionExpiry
+			updated.UploadExpiry = config.UploadExpiry
+			updated.TileDB.MaxAge = config.TileDB.MaxAge
+			maxRenderRequests := limits.MaxRenderRequests
+			maxTileRequests := limits.MaxTileRequests
+			this.configLock.Lock()
+			this.config = updated
+			this.renderTimeout = renderTimeout
 
 			/*
-			 * Check if index database was created sucessfully.
+			 * Replace render semaphore if limit was changed. Requests
+			 * in progress release the semaphore they acquired.
 			 */
-			if err != nil {
-				msg := err.Error()
-				errResult = fmt.Errorf("Failed to create index database: %s", msg)
-			} else {
-				this.indexDatabase = indexDB
-				modeImageDB := os.ModeExclusive | (os.ModePerm & PERMISSIONS_IMAGEDB)
-				fdImageDb, err := os.OpenFile(imageDBPath, os.O_RDWR|os.O_CREATE, modeImageDB)
+			if maxRenderRequests != currentLimits.MaxRenderRequests {
+				this.semRender = nil
 
 				/*
-				 * Check if file could be opened
+				 * Create render semaphore if limit is in place.
 				 */
-				if err != nil {
-					msg := err.Error()
-					errResult = fmt.Errorf("Failed to open file '%s': %s", imageDBPath, msg)
-				} else {
-					imageDB, err := tiledb.CreateImageDatabase(fdImageDb)
+				if maxRenderRequests > 0 {
+					this.semRender = lsync.CreateSemaphore(maxRenderRequests)
+				}
 
-					/*
-					 * Check if image database was created successfully.
-					 */
-					if err != nil {
-						msg := err.Error()
-						errResult = fmt.Errorf("Failed to create image database: %s", msg)
-					} else {
-						this.imageDatabase = imageDB
-						util := tileutil.CreateTileUtil(indexDB, imageDB)
-						this.tileUtil = util
-					}
+			}
+
+			/*
+			 * Replace tile semaphore if limit was changed.
+			 */
+			if maxTileRequests != currentLimits.MaxTileRequests {
+				this.semTile = nil
 
+				/*
+				 * Create tile semaphore if limit is in place.
+				 */
+				if maxTileRequests > 0 {
+					this.semTile = lsync.CreateSemaphore(maxTileRequests)
 				}
 
 			}
 
+			this.configLock.Unlock()
+			sessionManager := this.sessionManager
+			expiry := this.parseSessionExpiry(config)
+
+			/*
+			 * Apply new session expiry if sessions are managed.
+			 */
+			if sessionManager != nil {
+				sessionManager.SetExpiry(expiry)
+			}
+
+			tileUtil := this.tileUtil
+
+			/*
+			 * Apply new maximum age of tiles and pre-fetch delay if
+			 * map integration is enabled.
+			 */
+			if tileUtil != nil {
+				prefetchWorkers := currentLimits.PrefetchWorkers
+				tileUtil.SetMaxAge(maxAge)
+				tileUtil.SetPrefetchLimits(prefetchWorkers, prefetchDelay)
+			}
+
+			/*
+			 * Report changes which require a restart.
+			 */
+			for _, name := range restartRequired {
+				fmt.Printf("Configuration field '%s' was changed, but this only takes effect after a restart.\n", name)
+			}
+
+			return nil
 		}
 
 	}
 
-	return errResult
 }
 
 /*
- * Initialize tile server.
+ * Reloads the configuration and reports the result on the console.
  */
-func (this *controllerStruct) initializeTileServer() {
-	config := this.config
-	uri := config.MapServer
-	useMap := config.UseMap
+func (this *controllerStruct) reloadConfigAndReport() {
+	err := this.reloadConfig()
 
 	/*
-	 * Create OSM tile source if map should be used
-	 * and cache path is set.
+	 * Check if configuration could be reloaded.
 	 */
-	if useMap {
-		srv := tileserver.CreateOSMTileServer(uri)
-		this.tileServer = srv
+	if err != nil {
+		msg := err.Error()
+		fmt.Printf("Failed to reload configuration: %s\n", msg)
 	} else {
-		this.tileServer = nil
+		fmt.Printf("%s\n", "Configuration reloaded.")
 	}
 
 }
@@ -3740,25 +14829,38 @@ func (this *controllerStruct) initializeTileServer() {
  * Initialize the controller.
  */
 func (this *controllerStruct) initialize() error {
-	content, err := os.ReadFile(CONFIG_PATH)
+	config, err := this.loadConfig()
 
 	/*
-	 * Check if file could be read.
+	 * Check if configuration could be loaded.
 	 */
 	if err != nil {
-		return fmt.Errorf("Failed to open config file: '%s'", CONFIG_PATH)
+		return err
 	} else {
-		config := configStruct{}
-		err = json.Unmarshal(content, &config)
 		this.config = config
+		limits := config.Limits
+		workers := limits.Workers
 
 		/*
-		 * Check if file failed to unmarshal.
+		 * Default to as many workers as we have CPUs.
 		 */
-		if err != nil {
-			return fmt.Errorf("Failed to decode config file: '%s'", CONFIG_PATH)
+		if workers == 0 {
+			workers = runtime.NumCPU()
+		}
+
+		renderTimeout, errRenderTimeout := this.parseRenderTimeout(limits)
+
+		/*
+		 * Make sure that at least one worker processes requests and that
+		 * the render timeout is valid.
+		 */
+		if workers < 1 {
+			return fmt.Errorf("Number of workers must be at least 1 (or 0 to use the number of CPUs), but was %d.", workers)
+		} else if errRenderTimeout != nil {
+			return errRenderTimeout
 		} else {
-			limits := config.Limits
+			this.workers = workers
+			this.renderTimeout = renderTimeout
 			maxRenderRequests := limits.MaxRenderRequests
 
 			/*
@@ -3815,6 +14917,8 @@ func (this *controllerStruct) Operate(args []string) {
 		 * If no arguments are passed, run the server, otherwise interpret them.
 		 */
 		if numArgs == 0 {
+			this.printPaths()
+			this.initializeSetup()
 			err = this.initializeLocationData()
 
 			/*
@@ -3850,6 +14954,7 @@ func (this *controllerStruct) Operate(args []string) {
 
 		} else {
 			this.interpret(args)
+			this.closeDatabasesAndReport()
 		}
 
 	}
@@ -3878,10 +14983,181 @@ func (this *controllerStruct) Prefetch(zoomLevel uint8) {
 		if err != nil {
 			msg := err.Error()
 			fmt.Printf("Failed to initialize tile database: %s", msg)
+		} else if this.tileUtil == nil {
+			fmt.Printf("%s\n", "Map integration is disabled. Set 'UseMap' to true to pre-fetch tiles.")
 		} else {
 			tileUtil := this.tileUtil
-			tileServer := this.tileServer
+			tileServer := this.tilePrefetchServer
 			tileUtil.Prefetch(tileServer, zoomLevel)
+			this.closeDatabasesAndReport()
+		}
+
+	}
+
+}
+
+/*
+ * Pre-fetch tile data covering a bounding box from OSM for a range of zoom
+ * levels.
+ *
+ * The number of tiles to fetch is printed for each zoom level before the
+ * pre-fetch starts. The tile database must be initialized.
+ */
+func (this *controllerStruct) prefetchRegion(minZoom uint8, maxZoom uint8, south float64, west float64, north float64, east float64) {
+	total := uint64(0)
+
+	/*
+	 * Limit zoom level to allowed maximum.
+	 */
+	if maxZoom > tileutil.MAX_ZOOM_LEVEL {
+		maxZoom = tileutil.MAX_ZOOM_LEVEL
+	}
+
+	/*
+	 * Print number of tiles for each zoom level.
+	 */
+	for z := minZoom; z <= maxZoom; z++ {
+		count := tileutil.RegionTileCount(z, south, west, north, east)
+		fmt.Printf("Zoom level %d: %d tiles\n", z, count)
+		total += count
+	}
+
+	fmt.Printf("Pre-fetching %d tiles in total.\n", total)
+	tileUtil := this.tileUtil
+	tileServer := this.tilePrefetchServer
+	tileUtil.PrefetchRegion(tileServer, minZoom, maxZoom, south, west, north, east)
+}
+
+/*
+ * Pre-fetch tile data from OSM around the locations of the most recent days.
+ *
+ * The days are counted back from the latest location in the database, so
+ * that the pre-fetch also works if the location data has not been updated
+ * for some time.
+ */
+func (this *controllerStruct) PrefetchRecent(days uint, minZoom uint8, maxZoom uint8) {
+	err := this.initialize()
+
+	/*
+	 * Check if initialization was successful.
+	 */
+	if err != nil {
+		msg := err.Error()
+		fmt.Printf("Initialization failed: %s\n", msg)
+	} else {
+		err = this.initializeLocationData()
+
+		/*
+		 * Check if location data could be loaded.
+		 */
+		if err != nil {
+			msg := err.Error()
+			fmt.Printf("Error loading location data: %s\n", msg)
+		} else {
+			sharedData := this.sharedData
+			db := sharedData.locationDB
+			this.initializeTileServer()
+			err = this.initializeTileDatabase()
+
+			/*
+			 * Check if tile database could be initialized.
+			 */
+			if err != nil {
+				msg := err.Error()
+				fmt.Printf("Failed to initialize tile database: %s", msg)
+			} else if this.tileUtil == nil {
+				fmt.Printf("%s\n", "Map integration is disabled. Set 'UseMap' to true to pre-fetch tiles.")
+			} else {
+				gu := geoutil.Create()
+				stats, err := gu.GeoDBStats(db)
+
+				/*
+				 * Check if statistics could be obtained.
+				 */
+				if err != nil {
+					msg := err.Error()
+					fmt.Printf("Failed to obtain statistics of location database: %s\n", msg)
+				} else if stats.LocationCount() == 0 {
+					fmt.Printf("%s\n", "Location database is empty. Nothing to pre-fetch.")
+				} else {
+					timestampLatest := stats.TimestampLatest()
+					days64 := uint64(days)
+					span := days64 * MILLISECONDS_PER_DAY
+					timestampBegin := uint64(0)
+
+					/*
+					 * Prevent underflow if the span reaches back before
+					 * the epoch.
+					 */
+					if span < timestampLatest {
+						timestampBegin = timestampLatest - span
+					}
+
+					bounds, err := gu.GeoDBBoundsSince(db, timestampBegin)
+
+					/*
+					 * Check if bounding box could be determined.
+					 */
+					if err != nil {
+						msg := err.Error()
+						fmt.Printf("Failed to determine bounding box of recent locations: %s\n", msg)
+					} else if bounds.Empty() {
+						fmt.Printf("%s\n", "No recent locations found. Nothing to pre-fetch.")
+					} else {
+						latitudeMin := bounds.LatitudeMin()
+						longitudeMin := bounds.LongitudeMin()
+						latitudeMax := bounds.LatitudeMax()
+						longitudeMax := bounds.LongitudeMax()
+						south := float64(latitudeMin) / 1e7
+						west := float64(longitudeMin) / 1e7
+						north := float64(latitudeMax) / 1e7
+						east := float64(longitudeMax) / 1e7
+						fmt.Printf("Locations of the last %d days lie within %.7f,%.7f,%.7f,%.7f.\n", days, south, west, north, east)
+						this.prefetchRegion(minZoom, maxZoom, south, west, north, east)
+					}
+
+				}
+
+			}
+
+			this.closeDatabasesAndReport()
+		}
+
+	}
+
+}
+
+/*
+ * Pre-fetch tile data covering a bounding box from OSM for a range of zoom
+ * levels.
+ *
+ * The number of tiles to fetch is printed for each zoom level before the
+ * pre-fetch starts.
+ */
+func (this *controllerStruct) PrefetchRegion(minZoom uint8, maxZoom uint8, south float64, west float64, north float64, east float64) {
+	err := this.initialize()
+
+	/*
+	 * Check if initialization was successful.
+	 */
+	if err != nil {
+		msg := err.Error()
+		fmt.Printf("Initialization failed: %s\n", msg)
+	} else {
+		this.initializeTileServer()
+		err = this.initializeTileDatabase()
+
+		/*
+		 * Check if tile database could be initialized.
+		 */
+		if err != nil {
+			msg := err.Error()
+			fmt.Printf("Failed to initialize tile database: %s", msg)
+		} else if this.tileUtil == nil {
+			fmt.Printf("%s\n", "Map integration is disabled. Set 'UseMap' to true to pre-fetch tiles.")
+		} else {
+			this.prefetchRegion(minZoom, maxZoom, south, west, north, east)
+			this.closeDatabasesAndReport()
 		}
 
 	}
@@ -3892,6 +15168,14 @@ func (this *controllerStruct) Prefetch(zoomLevel uint8) {
  * Creates a new controller.
  */
 func CreateController() Controller {
-	controller := controllerStruct{}
+	sharedData := dataPartitionStruct{}
+
+	/*
+	 * Create controller.
+	 */
+	controller := controllerStruct{
+		sharedData: &sharedData,
+	}
+
 	return &controller
 }
@@ -0,0 +1,101 @@
+package controller
+
+import (
+	"bytes"
+	"compress/gzip"
+	"testing"
+)
+
+/*
+ * Compresses data using gzip.
+ */
+func compressGzip(t *testing.T, data []byte) []byte {
+	buf := bytes.NewBuffer(nil)
+	w := gzip.NewWriter(buf)
+	_, err := w.Write(data)
+
+	/*
+	 * Check if data could be compressed.
+	 */
+	if err != nil {
+		t.Fatalf("Failed to compress data: %s", err.Error())
+	}
+
+	err = w.Close()
+
+	/*
+	 * Check if compressed stream could be finished.
+	 */
+	if err != nil {
+		t.Fatalf("Failed to finish compressed data: %s", err.Error())
+	}
+
+	result := buf.Bytes()
+	return result
+}
+
+/*
+ * Highly compressible data decompressing to more than the limit must be
+ * rejected, while data at the limit is accepted.
+ */
+func TestReadGeoDataLimit(t *testing.T) {
+	this := controllerStruct{}
+	size := 4 << 20
+	maxSize := uint64(size)
+	data := make([]byte, size)
+	compressed := compressGzip(t, data)
+	compressedSize := len(compressed)
+
+	/*
+	 * Make sure the payload is actually a compression bomb.
+	 */
+	if compressedSize >= (size / 100) {
+		t.Fatalf("Expected compressed size below %d bytes, got %d.", size/100, compressedSize)
+	}
+
+	r := bytes.NewReader(compressed)
+	decompressed, wasCompressed, err := this.readGeoData(r, maxSize)
+	decompressedSize := len(decompressed)
+
+	/*
+	 * Data at the limit must be accepted.
+	 */
+	if err != nil {
+		t.Errorf("Expected data at the limit to be accepted, got error: %s", err.Error())
+	} else if !wasCompressed {
+		t.Errorf("%s", "Expected data to be recognized as compressed.")
+	} else if decompressedSize != size {
+		t.Errorf("Expected %d bytes of decompressed data, got %d.", size, decompressedSize)
+	}
+
+	maxSizeDec := maxSize - 1
+	r = bytes.NewReader(compressed)
+	decompressed, wasCompressed, err = this.readGeoData(r, maxSizeDec)
+
+	/*
+	 * Data exceeding the limit must be rejected.
+	 */
+	if err == nil {
+		t.Errorf("Expected data exceeding the limit of %d bytes to be rejected.", maxSizeDec)
+	} else if decompressed != nil {
+		t.Errorf("%s", "Expected no data to be returned on error.")
+	} else if !wasCompressed {
+		t.Errorf("%s", "Expected data to be recognized as compressed.")
+	}
+
+	uncompressed := []byte("2023-01-01T00:00:00Z,12.3N,45.6E,0\n")
+	r = bytes.NewReader(uncompressed)
+	result, wasCompressed, err := this.readGeoData(r, 1)
+
+	/*
+	 * Uncompressed data must be returned unchanged, regardless of the limit.
+	 */
+	if err != nil {
+		t.Errorf("Expected uncompressed data to be accepted, got error: %s", err.Error())
+	} else if wasCompressed {
+		t.Errorf("%s", "Expected data not to be recognized as compressed.")
+	} else if !bytes.Equal(result, uncompressed) {
+		t.Errorf("%s", "Expected uncompressed data to be returned unchanged.")
+	}
+
+}
@@ -0,0 +1,18 @@
+//go:build !darwin && !freebsd && !linux
+// +build !darwin,!freebsd,!linux
+
+package controller
+
+import (
+	"fmt"
+)
+
+/*
+ * Determines the amount of free disk space (in bytes) available to
+ * unprivileged users on the volume holding a certain path.
+ *
+ * This is not supported on the current platform.
+ */
+func freeSpace(path string) (uint64, error) {
+	return 0, fmt.Errorf("%s", "Determining free disk space is not supported on this platform.")
+}
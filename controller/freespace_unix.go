@@ -0,0 +1,30 @@
+//go:build darwin || freebsd || linux
+// +build darwin freebsd linux
+
+package controller
+
+import (
+	"syscall"
+)
+
+/*
+ * Determines the amount of free disk space (in bytes) available to
+ * unprivileged users on the volume holding a certain path.
+ */
+func freeSpace(path string) (uint64, error) {
+	stat := syscall.Statfs_t{}
+	err := syscall.Statfs(path, &stat)
+
+	/*
+	 * Check if file system statistics could be obtained.
+	 */
+	if err != nil {
+		return 0, err
+	} else {
+		blocksAvailable := uint64(stat.Bavail)
+		blockSize := uint64(stat.Bsize)
+		result := blocksAvailable * blockSize
+		return result, nil
+	}
+
+}
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/andrepxx/location-visualizer/geo/geodb"
+	"github.com/andrepxx/location-visualizer/geo/geoutil"
 )
 
 const (
@@ -30,6 +31,36 @@ type timeFilterStruct struct {
 	max time.Time
 }
 
+/*
+ * Filters out location data within a certain distance of a point.
+ */
+type exclusionZoneFilterStruct struct {
+	latitudeE7  int32
+	longitudeE7 int32
+	radius      float64
+	util        geoutil.Util
+}
+
+/*
+ * Filters location data by a rectangle in geographic coordinates.
+ *
+ * If the minimum longitude exceeds the maximum longitude, the rectangle
+ * crosses the antimeridian.
+ */
+type boundingBoxFilterStruct struct {
+	minLatitudeE7  int32
+	maxLatitudeE7  int32
+	minLongitudeE7 int32
+	maxLongitudeE7 int32
+}
+
+/*
+ * Combines multiple filters, matching data points which all of them match.
+ */
+type andFilterStruct struct {
+	filters []Filter
+}
+
 /*
  * Get string value with default value if empty.
  */
@@ -150,6 +181,84 @@ func (this *timeFilterStruct) Evaluate(loc *geodb.Location) bool {
 
 }
 
+/*
+ * Evaluate whether a geographical location lies outside the exclusion zone.
+ */
+func (this *exclusionZoneFilterStruct) Evaluate(loc *geodb.Location) bool {
+
+	/*
+	 * Nil locations never match a filter.
+	 */
+	if loc == nil {
+		return false
+	} else {
+		util := this.util
+		distance := util.DistanceE7(loc.LatitudeE7, loc.LongitudeE7, this.latitudeE7, this.longitudeE7)
+		radius := this.radius
+		match := distance > radius
+		return match
+	}
+
+}
+
+/*
+ * Evaluate whether a geographical location lies inside the bounding box.
+ */
+func (this *boundingBoxFilterStruct) Evaluate(loc *geodb.Location) bool {
+
+	/*
+	 * Nil locations never match a filter.
+	 */
+	if loc == nil {
+		return false
+	} else {
+		latitude := loc.LatitudeE7
+		longitude := loc.LongitudeE7
+		minLongitude := this.minLongitudeE7
+		maxLongitude := this.maxLongitudeE7
+		matchLatitude := (latitude >= this.minLatitudeE7) && (latitude <= this.maxLatitudeE7)
+		matchLongitude := false
+
+		/*
+		 * A box crossing the antimeridian consists of two ranges of
+		 * longitude, one east of the minimum and one west of the maximum.
+		 */
+		if minLongitude <= maxLongitude {
+			matchLongitude = (longitude >= minLongitude) && (longitude <= maxLongitude)
+		} else {
+			matchLongitude = (longitude >= minLongitude) || (longitude <= maxLongitude)
+		}
+
+		match := matchLatitude && matchLongitude
+		return match
+	}
+
+}
+
+/*
+ * Evaluate whether a geographical location matches all filters.
+ */
+func (this *andFilterStruct) Evaluate(loc *geodb.Location) bool {
+	filters := this.filters
+	match := true
+
+	/*
+	 * Evaluate filters until one of them does not match.
+	 */
+	for _, flt := range filters {
+
+		/*
+		 * Stop as soon as one filter does not match.
+		 */
+		if match {
+			match = flt.Evaluate(loc)
+		}
+
+	}
+
+	return match
+}
+
 /*
  * Apply a filter to a set of geographical locations to narrow it down.
  */
@@ -254,6 +363,136 @@ func ParseTime(timestamp string, sloppy bool, utc bool) (time.Time, error) {
 
 }
 
+/*
+ * Creates a UTC time stamp from either a keyword or a "sloppy" time stamp.
+ *
+ * The keyword "now" resolves to the current time. The keywords "today" and
+ * "yesterday" resolve to the beginning of the respective day in the provided
+ * time zone. Any other value is parsed as a "sloppy" time stamp.
+ */
+func ParseTimeRelative(timestamp string, now time.Time, location *time.Location) (time.Time, error) {
+	trimmed := strings.TrimSpace(timestamp)
+	keyword := strings.ToLower(trimmed)
+	localNow := now.In(location)
+	year, month, day := localNow.Date()
+
+	/*
+	 * Resolve keywords.
+	 */
+	switch keyword {
+	case "now":
+		result := now.UTC()
+		return result, nil
+	case "today":
+		begin := time.Date(year, month, day, 0, 0, 0, 0, location)
+		result := begin.UTC()
+		return result, nil
+	case "yesterday":
+		begin := time.Date(year, month, day-1, 0, 0, 0, 0, location)
+		result := begin.UTC()
+		return result, nil
+	default:
+		result, err := ParseTime(timestamp, true, true)
+		return result, err
+	}
+
+}
+
+/*
+ * Creates a filter which matches data points matched by all of the given
+ * filters.
+ *
+ * Nil filters match everything and are therefore ignored. If no filters
+ * remain, the result is nil as well.
+ */
+func And(filters ...Filter) Filter {
+	nonNil := []Filter{}
+
+	/*
+	 * Collect filters which are not nil.
+	 */
+	for _, flt := range filters {
+
+		/*
+		 * Ignore nil filters.
+		 */
+		if flt != nil {
+			nonNil = append(nonNil, flt)
+		}
+
+	}
+
+	numFilters := len(nonNil)
+
+	/*
+	 * Only combine filters if there is more than one.
+	 */
+	switch numFilters {
+	case 0:
+		return nil
+	case 1:
+		flt := nonNil[0]
+		return flt
+	default:
+
+		/*
+		 * Create a new combined filter.
+		 */
+		a := andFilterStruct{
+			filters: nonNil,
+		}
+
+		return &a
+	}
+
+}
+
+/*
+ * Creates a filter which matches data points inside a rectangle in geographic
+ * coordinates, given in degrees times 10^7.
+ *
+ * If minLongitudeE7 exceeds maxLongitudeE7, the rectangle is assumed to cross
+ * the antimeridian, so that it spans from minLongitudeE7 eastwards to
+ * maxLongitudeE7.
+ */
+func BoundingBox(minLatitudeE7 int32, maxLatitudeE7 int32, minLongitudeE7 int32, maxLongitudeE7 int32) Filter {
+
+	/*
+	 * Create a new bounding box filter.
+	 */
+	b := boundingBoxFilterStruct{
+		minLatitudeE7:  minLatitudeE7,
+		maxLatitudeE7:  maxLatitudeE7,
+		minLongitudeE7: minLongitudeE7,
+		maxLongitudeE7: maxLongitudeE7,
+	}
+
+	return &b
+}
+
+/*
+ * Creates a filter which matches data points further than a given distance
+ * away from a point.
+ *
+ * The point is given in degrees in fixed-point representation with a fixed
+ * exponent of seven, the radius in meters.
+ */
+func ExclusionZone(latitudeE7 int32, longitudeE7 int32, radius float64) Filter {
+	util := geoutil.Create()
+
+	/*
+	 * Create a new exclusion zone filter.
+	 */
+	e := exclusionZoneFilterStruct{
+		latitudeE7:  latitudeE7,
+		longitudeE7: longitudeE7,
+		radius:      radius,
+		util:        util,
+	}
+
+	return &e
+}
+
 /*
  * Creates a filter which matches data points in a given time interval.
  *
@@ -4,6 +4,7 @@ package geo
  * A geographic location.
  */
 type Location interface {
+	Accuracy() uint16
 	Latitude() int32
 	Longitude() int32
 	Timestamp() uint64
@@ -15,4 +16,5 @@ type Location interface {
 type Database interface {
 	LocationAt(idx int) (Location, error)
 	LocationCount() int
+	SkippedCount() int
 }
@@ -18,6 +18,7 @@ type locationStruct struct {
 	timestamp   uint64
 	latitudeE7  int32
 	longitudeE7 int32
+	accuracy    uint16
 }
 
 /*
@@ -25,6 +26,15 @@ type locationStruct struct {
  */
 type databaseStruct struct {
 	locations []locationStruct
+	skipped   int
+}
+
+/*
+ * Returns the horizontal accuracy (in meters) of this location.
+ */
+func (this *locationStruct) Accuracy() uint16 {
+	accuracy := this.accuracy
+	return accuracy
 }
 
 /*
@@ -52,6 +62,26 @@ func (this *locationStruct) Timestamp() uint64 {
 	return timestamp
 }
 
+/*
+ * Parses a horizontal accuracy in meters.
+ */
+func (this *databaseStruct) parseAccuracy(accuracyString string) (uint16, error) {
+	accuracyString = strings.TrimSpace(accuracyString)
+	accuracy, err := strconv.ParseUint(accuracyString, 10, 16)
+
+	/*
+	 * Check if error occured.
+	 */
+	if err != nil {
+		msg := err.Error()
+		return 0, fmt.Errorf("Failed to parse accuracy: %s", msg)
+	} else {
+		accuracy16 := uint16(accuracy)
+		return accuracy16, nil
+	}
+
+}
+
 /*
  * Parses a latitude.
  */
@@ -182,22 +212,75 @@ func (this *databaseStruct) parseLongitude(longitudeString string) (int32, error
 
 /*
  * Parses a timestamp and returns the number of milliseconds since the Epoch.
+ *
+ * Accepts RFC 3339 timestamps, optionally with a space instead of the 'T'
+ * separating date and time, as well as bare numbers of seconds or
+ * milliseconds since the Epoch. Numbers with up to 11 digits are interpreted
+ * as seconds, longer numbers as milliseconds. Timestamps without a time zone
+ * are interpreted as UTC.
  */
 func (this *databaseStruct) parseTimestamp(timestampString string) (uint64, error) {
-	layout := time.RFC3339Nano
-	location := time.UTC
-	parsedTime, err := time.ParseInLocation(layout, timestampString, location)
-	timestamp := uint64(0)
+	timestampString = strings.TrimSpace(timestampString)
+	n := len(timestampString)
+	epoch, errEpoch := strconv.ParseUint(timestampString, 10, 64)
 
 	/*
-	 * ParseInLocation does not specify the result on error.
+	 * Check if timestamp is a bare number.
 	 */
-	if err == nil {
-		unixMs := parsedTime.UnixMilli()
-		timestamp = uint64(unixMs)
+	if errEpoch == nil {
+
+		/*
+		 * Short numbers are seconds, long numbers are milliseconds.
+		 */
+		if n <= 11 {
+			return epoch * 1000, nil
+		} else {
+			return epoch, nil
+		}
+
+	} else {
+
+		/*
+		 * Accepted layouts for timestamps.
+		 */
+		layouts := []string{
+			time.RFC3339Nano,
+			"2006-01-02 15:04:05Z07:00",
+			"2006-01-02T15:04:05",
+			"2006-01-02 15:04:05",
+		}
+
+		location := time.UTC
+
+		/*
+		 * Try each layout until one matches.
+		 */
+		for _, layout := range layouts {
+			parsedTime, err := time.ParseInLocation(layout, timestampString, location)
+
+			/*
+			 * ParseInLocation does not specify the result on error.
+			 */
+			if err == nil {
+				unixMs := parsedTime.UnixMilli()
+
+				/*
+				 * Timestamps cannot be negative.
+				 */
+				if unixMs < 0 {
+					return 0, fmt.Errorf("Timestamp '%s' lies before the Epoch.", timestampString)
+				} else {
+					timestamp := uint64(unixMs)
+					return timestamp, nil
+				}
+
+			}
+
+		}
+
+		return 0, fmt.Errorf("Unknown timestamp format: '%s'", timestampString)
 	}
 
-	return timestamp, err
 }
 
 /*
@@ -229,15 +312,26 @@ func (this *databaseStruct) LocationCount() int {
 	return numLocs
 }
 
+/*
+ * The number of records skipped while parsing, since they could not be parsed.
+ */
+func (this *databaseStruct) SkippedCount() int {
+	skipped := this.skipped
+	return skipped
+}
+
 /*
  * Create CSV database from byte slice.
+ *
+ * Records which cannot be parsed are skipped and counted. Parsing only fails
+ * if no record could be parsed at all.
  */
 func FromBytes(data []byte) (geo.Database, error) {
 	db := &databaseStruct{}
 	errResult := error(nil)
 	fd := bytes.NewReader(data)
 	r := csv.NewReader(fd)
-	r.FieldsPerRecord = 3
+	r.FieldsPerRecord = -1
 	records, err := r.ReadAll()
 
 	/*
@@ -247,70 +341,101 @@ func FromBytes(data []byte) (geo.Database, error) {
 		msg := err.Error()
 		errResult = fmt.Errorf("Error occured during reading: %s", msg)
 	} else {
-		numLocations := len(records)
-		locs := make([]locationStruct, numLocations)
+		numRecords := len(records)
+		locs := make([]locationStruct, 0, numRecords)
+		errFirst := error(nil)
 
 		/*
 		 * Iterate over the records.
 		 */
 		for i, record := range records {
-			timestampString := record[0]
-			timestamp, errTimestamp := db.parseTimestamp(timestampString)
-			latitudeString := record[1]
-			latitude, errLatitude := db.parseLatitude(latitudeString)
-			longitudeString := record[2]
-			longitude, errLongitude := db.parseLongitude(longitudeString)
+			numFields := len(record)
+			errRecord := error(nil)
 
 			/*
-			 * Check for parse errors.
+			 * Check if record has the expected number of fields.
 			 */
-			if errTimestamp != nil {
+			if (numFields != 3) && (numFields != 4) {
+				errRecord = fmt.Errorf("Expected %d or %d fields in record %d, but found %d.", 3, 4, i, numFields)
+			} else {
+				timestampString := record[0]
+				timestamp, errTimestamp := db.parseTimestamp(timestampString)
+				latitudeString := record[1]
+				latitude, errLatitude := db.parseLatitude(latitudeString)
+				longitudeString := record[2]
+				longitude, errLongitude := db.parseLongitude(longitudeString)
+				accuracy := uint16(0)
+				errAccuracy := error(nil)
 
 				/*
-				 * Store the first parse error.
+				 * The accuracy is optional.
 				 */
-				if errResult == nil {
-					msg := errTimestamp.Error()
-					errResult = fmt.Errorf("Error parsing timestamp of record %d: %s", i, msg)
+				if numFields == 4 {
+					accuracyString := record[3]
+					accuracy, errAccuracy = db.parseAccuracy(accuracyString)
 				}
 
-			} else if errLatitude != nil {
-
 				/*
-				 * Store the first parse error.
+				 * Check for parse errors.
 				 */
-				if errResult == nil {
+				if errTimestamp != nil {
+					msg := errTimestamp.Error()
+					errRecord = fmt.Errorf("Error parsing timestamp of record %d: %s", i, msg)
+				} else if errLatitude != nil {
 					msg := errLatitude.Error()
-					errResult = fmt.Errorf("Error parsing latitude of record %d: %s", i, msg)
+					errRecord = fmt.Errorf("Error parsing latitude of record %d: %s", i, msg)
+				} else if errLongitude != nil {
+					msg := errLongitude.Error()
+					errRecord = fmt.Errorf("Error parsing longitude of record %d: %s", i, msg)
+				} else if errAccuracy != nil {
+					msg := errAccuracy.Error()
+					errRecord = fmt.Errorf("Error parsing accuracy of record %d: %s", i, msg)
+				} else {
+
+					/*
+					 * Create location.
+					 */
+					loc := locationStruct{
+						timestamp:   timestamp,
+						latitudeE7:  latitude,
+						longitudeE7: longitude,
+						accuracy:    accuracy,
+					}
+
+					locs = append(locs, loc)
 				}
 
-			} else if errLongitude != nil {
+			}
+
+			/*
+			 * Skip records which could not be parsed, but keep the
+			 * first error.
+			 */
+			if errRecord != nil {
+				db.skipped++
 
 				/*
 				 * Store the first parse error.
 				 */
-				if errResult == nil {
-					msg := errLongitude.Error()
-					errResult = fmt.Errorf("Error parsing longitude of record %d: %s", i, msg)
+				if errFirst == nil {
+					errFirst = errRecord
 				}
 
-			} else {
+			}
 
-				/*
-				 * Create location.
-				 */
-				loc := locationStruct{
-					timestamp:   timestamp,
-					latitudeE7:  latitude,
-					longitudeE7: longitude,
-				}
+		}
 
-				locs[i] = loc
-			}
+		numLocations := len(locs)
 
+		/*
+		 * Fail if records were present, but none could be parsed.
+		 */
+		if (numLocations == 0) && (errFirst != nil) {
+			errResult = errFirst
+		} else {
+			db.locations = locs
 		}
 
-		db.locations = locs
 	}
 
 	/*
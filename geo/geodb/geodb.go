@@ -2,6 +2,7 @@ package geodb
 
 import (
 	"bytes"
+	"context"
 	"crypto/sha512"
 	"crypto/subtle"
 	"encoding/binary"
@@ -20,13 +21,27 @@ import (
  * Constants for the geographical database.
  */
 const (
-	MAGIC_NUMBER         = 0x47656f44420a0004
-	SIZE_DATABASE_ENTRY  = 14
-	SIZE_DATABASE_HEADER = 10
-	SIZE_TIMESTAMP       = 6
-	SUBTLE_COMPARE_EQUAL = 1
-	VERSION_MAJOR        = 1
-	VERSION_MINOR        = 0
+	DEGREES_E7_TO_RADIANS      = (math.Pi / 180.0) * 1e-7
+	EARTH_RADIUS_METERS        = 6371008.8
+	MAGIC_NUMBER               = 0x47656f44420a0004
+	PRECISION_MAX              = 7
+	SIZE_DATABASE_ENTRY        = 16
+	SIZE_DATABASE_ENTRY_LEGACY = 14
+	SIZE_DATABASE_HEADER       = 10
+	SIZE_ORDER_SCAN_BLOCK      = 4096
+	SIZE_TIMESTAMP             = 6
+	SUBTLE_COMPARE_EQUAL       = 1
+	VERSION_MAJOR              = 1
+	VERSION_MINOR              = 1
+)
+
+/*
+ * Known ordering of the database.
+ */
+const (
+	ORDER_UNKNOWN = iota
+	ORDER_ORDERED
+	ORDER_UNORDERED
 )
 
 /*
@@ -40,6 +55,18 @@ const (
 	JSON_STREAM_ERROR
 )
 
+/*
+ * States for GeoJSON serializer.
+ */
+const (
+	GEOJSON_STREAM_HEADER = iota
+	GEOJSON_STREAM_COORDINATES
+	GEOJSON_STREAM_TIMES
+	GEOJSON_STREAM_TRAILER
+	GEOJSON_STREAM_EOF
+	GEOJSON_STREAM_ERROR
+)
+
 /*
  * Indentation direction.
  */
@@ -60,6 +87,18 @@ const (
 	XML_STREAM_ERROR
 )
 
+/*
+ * States for KML serializer.
+ */
+const (
+	KML_STREAM_HEADER = iota
+	KML_STREAM_TIMES
+	KML_STREAM_COORDINATES
+	KML_STREAM_TRAILER
+	KML_STREAM_EOF
+	KML_STREAM_ERROR
+)
+
 /*
  * Indentation direction.
  */
@@ -69,6 +108,27 @@ const (
 	XML_INDENT_OUT  = -1
 )
 
+/*
+ * Categories of errors reported by the database.
+ *
+ * Errors returned by the database keep their specific messages, but can be
+ * tested for these categories using errors.Is.
+ */
+var (
+	ErrClosed     = fmt.Errorf("%s", "Database is closed.")
+	ErrCorrupt    = fmt.Errorf("%s", "Database is corrupt.")
+	ErrOutOfRange = fmt.Errorf("%s", "Value out of range.")
+	ErrUnordered  = fmt.Errorf("%s", "Database is not ordered.")
+)
+
+/*
+ * An error belonging to one of the error categories.
+ */
+type databaseErrorStruct struct {
+	kind error
+	msg  string
+}
+
 /*
  * A geographic location stored in the geo database.
  */
@@ -76,23 +136,47 @@ type Location struct {
 	Timestamp   uint64
 	LatitudeE7  int32
 	LongitudeE7 int32
+	Accuracy    uint16
 }
 
+/*
+ * A function applied to each location before it is serialized into a text
+ * format.
+ *
+ * The function may modify the location. If it returns false, the location is
+ * omitted from the output.
+ */
+type LocationTransform func(loc *Location) bool
+
 /*
  * A database storing geographic data.
  */
 type Database interface {
 	Append(loc *Location) error
+	AppendBatch(locs []Location) (uint32, error)
 	Clear(hash []byte) (uint32, error)
-	Close()
-	Deduplicate() (uint32, error)
+	Close() error
+	Deduplicate(ctx context.Context) (uint32, error)
+	DeduplicateThreshold(maxDistanceMeters float64, maxTimeGapMs uint64) (uint32, error)
+	DeleteRange(minTimestampMs uint64, maxTimestampMs uint64) (uint32, error)
 	LocationCount() uint32
+	Ordered() (bool, error)
 	ReadLocations(offset uint32, target []Location) (uint32, error)
+	RemoveOutsideTimeRange(ctx context.Context, timestampEarliest uint64, timestampLatest uint64) (uint32, error)
+	Revision() uint64
+	SeekTimestamp(timestampMs uint64) (uint32, error)
 	SerializeBinary() io.ReadSeekCloser
-	SerializeCSV() io.ReadCloser
-	SerializeJSON(pretty bool) io.ReadCloser
-	SerializeXML(pretty bool) io.ReadCloser
-	Sort() error
+	SerializeBinaryIncremental(revision uint64, locationCount uint32) (io.ReadSeekCloser, uint64, bool)
+	SerializeCSV(location *time.Location, stride uint32, transform LocationTransform) io.ReadCloser
+	SerializeCSVRange(begin uint32, end uint32, location *time.Location, stride uint32, transform LocationTransform) io.ReadCloser
+	SerializeGeoJSON(pretty bool, gap time.Duration, stride uint32, transform LocationTransform) io.ReadCloser
+	SerializeJSON(pretty bool, degrees bool, stride uint32, transform LocationTransform) io.ReadCloser
+	SerializeKML(pretty bool, stride uint32, transform LocationTransform) io.ReadCloser
+	SerializeXML(pretty bool, location *time.Location, stride uint32, transform LocationTransform) io.ReadCloser
+	SerializeXMLRange(begin uint32, end uint32, pretty bool, location *time.Location, stride uint32, transform LocationTransform) io.ReadCloser
+	SetPrecision(digits uint8) error
+	Sort(ctx context.Context) error
+	Thin(keepEveryN uint32) (uint32, error)
 }
 
 /*
@@ -102,6 +186,7 @@ type Database interface {
 type Storage interface {
 	ReadAt(buf []byte, offset int64) (int, error)
 	Seek(offset int64, whence int) (int64, error)
+	Sync() error
 	Truncate(size int64) error
 	WriteAt(buf []byte, offset int64) (int, error)
 }
@@ -118,56 +203,107 @@ type databaseHeaderStruct struct {
 /*
  * Each database entry consists of a 48 bit time stamp storing milliseconds
  * since the Epoch, as well as longitude and latitude values in degrees, stored
- * as fixed-point values with a fixed exponent of 10^(-7).
+ * as fixed-point values with a fixed exponent of 10^(-7), followed by the
+ * horizontal accuracy in meters.
+ *
+ * Databases in version 1.0 do not store the accuracy, so their entries are
+ * only SIZE_DATABASE_ENTRY_LEGACY bytes long and read with an accuracy of zero.
  */
 type databaseEntryStruct struct {
 	TimestampMSB uint16
 	TimestampLSB uint32
 	LatitudeE7   int32
 	LongitudeE7  int32
+	Accuracy     uint16
 }
 
 /*
  * Database accessor.
+ *
+ * Open serializers hold the snapshot lock for reading, so that the entries
+ * they serialize are not rewritten. Operations which rewrite or remove
+ * entries take the snapshot lock for writing before taking the mutex, while
+ * appending only extends the database and does not need the snapshot lock.
  */
 type databaseStruct struct {
 	mutex         sync.RWMutex
+	snapshotLock  sync.RWMutex
 	fd            Storage
+	entrySize     uint64
 	locationCount uint32
+	order         int
+	quantum       int64
+	revision      uint64
+	timestampLast uint64
 }
 
 /*
  * Data structure for serializing the database into binary format.
  */
 type databaseBinarySerializerStruct struct {
-	mutex  sync.Mutex
-	db     *databaseStruct
-	offset uint64
+	mutex         sync.Mutex
+	begin         uint64
+	db            *databaseStruct
+	locationCount uint32
+	offset        uint64
 }
 
 /*
  * Data structure for serializing the database into CSV format.
  */
 type databaseCsvSerializerStruct struct {
-	mutex      sync.Mutex
-	csvWriter  *csv.Writer
-	db         *databaseStruct
-	entryId    uint32
-	lineBuffer *strings.Builder
-	lineOffset int
+	mutex         sync.Mutex
+	csvWriter     *csv.Writer
+	db            *databaseStruct
+	end           uint32
+	entryId       uint32
+	lineBuffer    *strings.Builder
+	lineOffset    int
+	location      *time.Location
+	locationCount uint32
+	stride        uint32
+	transform     LocationTransform
 }
 
 /*
  * Data structure for serializing the database into GeoJSON format.
  */
 type databaseJsonSerializerStruct struct {
-	mutex   sync.Mutex
-	buffer  *strings.Builder
-	db      *databaseStruct
-	entryId uint32
-	indent  uint16
-	pretty  bool
-	state   int
+	mutex          sync.Mutex
+	buffer         *strings.Builder
+	db             *databaseStruct
+	degrees        bool
+	entryId        uint32
+	entriesWritten uint32
+	indent         uint16
+	locationCount  uint32
+	pretty         bool
+	state          int
+	stride         uint32
+	transform      LocationTransform
+}
+
+/*
+ * Data structure for serializing the database into a GeoJSON feature
+ * collection.
+ */
+type databaseGeoJsonSerializerStruct struct {
+	mutex             sync.Mutex
+	buffer            *strings.Builder
+	db                *databaseStruct
+	entryId           uint32
+	gap               uint64
+	indent            uint16
+	lineOpen          bool
+	linesWritten      uint32
+	locationCount     uint32
+	pending           string
+	pendingOpen       bool
+	pretty            bool
+	state             int
+	stride            uint32
+	timestampPrevious uint64
+	transform         LocationTransform
 }
 
 /*
@@ -182,20 +318,364 @@ type keyValuePairStruct struct {
  * Data structure for serializing the database into GPX format.
  */
 type databaseXmlSerializerStruct struct {
-	mutex   sync.Mutex
-	buffer  *strings.Builder
-	db      *databaseStruct
-	entryId uint32
-	indent  uint16
-	pretty  bool
-	state   int
+	mutex          sync.Mutex
+	buffer         *strings.Builder
+	db             *databaseStruct
+	end            uint32
+	entryId        uint32
+	entriesWritten uint32
+	indent         uint16
+	location       *time.Location
+	locationCount  uint32
+	pretty         bool
+	state          int
+	stride         uint32
+	transform      LocationTransform
+}
+
+/*
+ * Data structure for serializing the database into KML format.
+ */
+type databaseKmlSerializerStruct struct {
+	mutex          sync.Mutex
+	buffer         *strings.Builder
+	db             *databaseStruct
+	entryId        uint32
+	entriesWritten uint32
+	indent         uint16
+	locationCount  uint32
+	pretty         bool
+	state          int
+	stride         uint32
+	transform      LocationTransform
+}
+
+/*
+ * Passed to panic when sorting is cancelled.
+ */
+type contextCancelledStruct struct {
+	cause error
 }
 
 /*
  * Data structure for sorting the database.
  */
 type databaseSorterStruct struct {
-	db *databaseStruct
+	ctx context.Context
+	db  *databaseStruct
+}
+
+/*
+ * Returns the message of this error.
+ */
+func (this *databaseErrorStruct) Error() string {
+	msg := this.msg
+	return msg
+}
+
+/*
+ * Returns the category of this error.
+ */
+func (this *databaseErrorStruct) Unwrap() error {
+	kind := this.kind
+	return kind
+}
+
+/*
+ * Determines whether the database is ordered by time stamp by scanning all
+ * entries.
+ *
+ * Assumes that the database is locked for writing.
+ */
+func (this *databaseStruct) determineOrder() error {
+	fd := this.fd
+	numEntries := this.locationCount
+	endianness := binary.BigEndian
+	entrySize := this.entrySize
+	entrySize32 := uint32(entrySize)
+	entrySize64 := int64(entrySize)
+	buf := make([]byte, entrySize*SIZE_ORDER_SCAN_BLOCK)
+	ordered := true
+	timestampPrevious := uint64(0)
+	errResult := error(nil)
+	idx := uint32(0)
+
+	/*
+	 * Read blocks of entries until the end of the database, an error or the
+	 * first entry out of order.
+	 */
+	for ordered && (errResult == nil) && (idx < numEntries) {
+		numRemaining := numEntries - idx
+		numBlock := uint32(SIZE_ORDER_SCAN_BLOCK)
+
+		/*
+		 * The last block may be shorter.
+		 */
+		if numRemaining < numBlock {
+			numBlock = numRemaining
+		}
+
+		idx64 := int64(idx)
+		offset := SIZE_DATABASE_HEADER + (entrySize64 * idx64)
+		size := entrySize32 * numBlock
+		block := buf[0:size]
+		n, err := fd.ReadAt(block, offset)
+		sizeInt := int(size)
+
+		/*
+		 * Check for errors.
+		 */
+		if n != sizeInt {
+			errResult = fmt.Errorf("Expected %d bytes reading from offset 0x%016x (%d), but got %d.", size, offset, offset, n)
+		} else if (err != nil) && (err != io.EOF) {
+			msg := err.Error()
+			errResult = fmt.Errorf("Error reading from offset %016x (%d): %s", offset, offset, msg)
+		} else {
+
+			/*
+			 * Iterate over the entries in the block.
+			 */
+			for i := uint32(0); ordered && (i < numBlock); i++ {
+				start := entrySize32 * i
+				timestampMSBBytes := block[start : start+2]
+				timestampMSB := endianness.Uint16(timestampMSBBytes)
+				timestampMSB64 := uint64(timestampMSB)
+				timestampLSBBytes := block[start+2 : start+SIZE_TIMESTAMP]
+				timestampLSB := endianness.Uint32(timestampLSBBytes)
+				timestampLSB64 := uint64(timestampLSB)
+				timestamp := (timestampMSB64 << 32) | timestampLSB64
+				ordered = timestamp >= timestampPrevious
+				timestampPrevious = timestamp
+			}
+
+			idx += numBlock
+		}
+
+	}
+
+	/*
+	 * Check if ordering could be determined.
+	 */
+	if errResult != nil {
+		this.order = ORDER_UNKNOWN
+	} else if ordered {
+		this.order = ORDER_ORDERED
+		this.timestampLast = timestampPrevious
+	} else {
+		this.order = ORDER_UNORDERED
+	}
+
+	return errResult
+}
+
+/*
+ * Reads the time stamp of the entry at the provided index.
+ *
+ * Assumes that the database is locked.
+ */
+func (this *databaseStruct) readTimestamp(idx uint32) (uint64, error) {
+	fd := this.fd
+	buf := make([]byte, SIZE_TIMESTAMP)
+	entrySize := this.entrySize
+	entrySize64 := int64(entrySize)
+	idx64 := int64(idx)
+	offset := SIZE_DATABASE_HEADER + (entrySize64 * idx64)
+	n, err := fd.ReadAt(buf, offset)
+
+	/*
+	 * Check for errors.
+	 */
+	if n != SIZE_TIMESTAMP {
+		return 0, fmt.Errorf("Expected %d bytes reading from offset 0x%016x (%d), but got %d.", SIZE_TIMESTAMP, offset, offset, n)
+	} else if (err != nil) && (err != io.EOF) {
+		msg := err.Error()
+		return 0, fmt.Errorf("Error reading from offset %016x (%d): %s", offset, offset, msg)
+	} else {
+		endianness := binary.BigEndian
+		timestampMSBBytes := buf[0:2]
+		timestampMSB := endianness.Uint16(timestampMSBBytes)
+		timestampMSB64 := uint64(timestampMSB)
+		timestampLSBBytes := buf[2:SIZE_TIMESTAMP]
+		timestampLSB := endianness.Uint32(timestampLSBBytes)
+		timestampLSB64 := uint64(timestampLSB)
+		timestamp := (timestampMSB64 << 32) | timestampLSB64
+		return timestamp, nil
+	}
+
+}
+
+/*
+ * Returns the index of the first entry with a time stamp of at least
+ * timestampMs, or the number of entries if there is no such entry.
+ *
+ * Assumes that the database is locked and ordered by time stamp.
+ */
+func (this *databaseStruct) searchTimestamp(timestampMs uint64) (uint32, error) {
+	lower := uint32(0)
+	upper := this.locationCount
+	errResult := error(nil)
+
+	/*
+	 * Narrow down the range until lower and upper meet.
+	 */
+	for (lower < upper) && (errResult == nil) {
+		middle := lower + ((upper - lower) / 2)
+		timestamp, err := this.readTimestamp(middle)
+
+		/*
+		 * Check if time stamp could be read and continue search in the
+		 * half containing the entry.
+		 */
+		if err != nil {
+			errResult = err
+		} else if timestamp < timestampMs {
+			lower = middle + 1
+		} else {
+			upper = middle
+		}
+
+	}
+
+	return lower, errResult
+}
+
+/*
+ * Removes the entries with indices from begin (inclusive) to end (exclusive)
+ * by moving the entries following them down and truncating the file.
+ *
+ * Assumes that the database is locked for writing.
+ */
+func (this *databaseStruct) removeEntries(begin uint32, end uint32) error {
+	fd := this.fd
+	numEntries := this.locationCount
+	entrySize := this.entrySize
+	entrySize32 := uint32(entrySize)
+	entrySize64 := int64(entrySize)
+	buf := make([]byte, entrySize*SIZE_ORDER_SCAN_BLOCK)
+	errResult := error(nil)
+	readIdx := end
+	writeIdx := begin
+
+	/*
+	 * Move blocks of entries until the end of the database or an error.
+	 */
+	for (errResult == nil) && (readIdx < numEntries) {
+		numRemaining := numEntries - readIdx
+		numBlock := uint32(SIZE_ORDER_SCAN_BLOCK)
+
+		/*
+		 * The last block may be shorter.
+		 */
+		if numRemaining < numBlock {
+			numBlock = numRemaining
+		}
+
+		readIdx64 := int64(readIdx)
+		offsetRead := SIZE_DATABASE_HEADER + (entrySize64 * readIdx64)
+		size := entrySize32 * numBlock
+		sizeInt := int(size)
+		block := buf[0:size]
+		n, err := fd.ReadAt(block, offsetRead)
+
+		/*
+		 * Check for errors.
+		 */
+		if n != sizeInt {
+			errResult = fmt.Errorf("Expected %d bytes reading from offset 0x%016x (%d), but got %d.", size, offsetRead, offsetRead, n)
+		} else if (err != nil) && (err != io.EOF) {
+			msg := err.Error()
+			errResult = fmt.Errorf("Error reading from offset %016x (%d): %s", offsetRead, offsetRead, msg)
+		} else {
+			writeIdx64 := int64(writeIdx)
+			offsetWrite := SIZE_DATABASE_HEADER + (entrySize64 * writeIdx64)
+			n, err := fd.WriteAt(block, offsetWrite)
+
+			/*
+			 * Check if write error occured or write was not of
+			 * expected size.
+			 */
+			if err != nil {
+				msg := err.Error()
+				errResult = fmt.Errorf("Error writing to offset %016x (%d): %s", offsetWrite, offsetWrite, msg)
+			} else if n != sizeInt {
+				errResult = fmt.Errorf("Expected %d bytes writing to offset 0x%016x (%d), but got %d.", size, offsetWrite, offsetWrite, n)
+			}
+
+			readIdx += numBlock
+			writeIdx += numBlock
+		}
+
+	}
+
+	/*
+	 * Truncate the file if all entries were moved.
+	 */
+	if errResult == nil {
+		numRemoved := end - begin
+		numEntries -= numRemoved
+		numEntries64 := int64(numEntries)
+		fileSize := SIZE_DATABASE_HEADER + (entrySize64 * numEntries64)
+		err := fd.Truncate(fileSize)
+
+		/*
+		 * Check if error occured during truncation.
+		 */
+		if err != nil {
+			msg := err.Error()
+			errResult = fmt.Errorf("Failed to truncate file to size 0x%016x (%d): %s", fileSize, fileSize, msg)
+		} else {
+			this.locationCount = numEntries
+		}
+
+	}
+
+	return errResult
+}
+
+/*
+ * Marks the database as ordered, for example after it has been sorted.
+ *
+ * Assumes that the database is locked for writing.
+ */
+func (this *databaseStruct) markOrdered() {
+	fd := this.fd
+	numEntries := this.locationCount
+
+	/*
+	 * An empty database is always ordered.
+	 */
+	if numEntries == 0 {
+		this.order = ORDER_ORDERED
+		this.timestampLast = 0
+	} else {
+		entrySize := this.entrySize
+		entrySize64 := int64(entrySize)
+		entrySizeInt := int(entrySize)
+		buf := make([]byte, entrySize)
+		lastIdx := numEntries - 1
+		lastIdx64 := int64(lastIdx)
+		offset := SIZE_DATABASE_HEADER + (entrySize64 * lastIdx64)
+		n, err := fd.ReadAt(buf, offset)
+
+		/*
+		 * If the last time stamp cannot be read, the order is unknown.
+		 */
+		if (n != entrySizeInt) || ((err != nil) && (err != io.EOF)) {
+			this.order = ORDER_UNKNOWN
+		} else {
+			endianness := binary.BigEndian
+			timestampMSBBytes := buf[0:2]
+			timestampMSB := endianness.Uint16(timestampMSBBytes)
+			timestampMSB64 := uint64(timestampMSB)
+			timestampLSBBytes := buf[2:SIZE_TIMESTAMP]
+			timestampLSB := endianness.Uint32(timestampLSBBytes)
+			timestampLSB64 := uint64(timestampLSB)
+			this.order = ORDER_ORDERED
+			this.timestampLast = (timestampMSB64 << 32) | timestampLSB64
+		}
+
+	}
+
 }
 
 /*
@@ -203,9 +683,12 @@ type databaseSorterStruct struct {
  *
  * Converts panic into proper error handling.
  *
+ * Sorting stops early when the context is cancelled. The database then still
+ * contains all of its entries, but only partially sorted.
+ *
  * Assumes that the database is locked for writing.
  */
-func (this *databaseStruct) sort() (err error) {
+func (this *databaseStruct) sort(ctx context.Context) (err error) {
 
 	/*
 	 * Sorting may panic, for example on I/O errors.
@@ -218,12 +701,17 @@ func (this *databaseStruct) sort() (err error) {
 		 */
 		if r != nil {
 			msg, ok := r.(string)
+			errCtx, isCancelled := r.(contextCancelledStruct)
 
 			/*
-			 * Check if string was passed to panic.
+			 * Check if string was passed to panic or sorting was
+			 * cancelled.
 			 */
 			if ok {
 				err = fmt.Errorf("Error during sorting: %s", msg)
+			} else if isCancelled {
+				cause := errCtx.cause
+				err = fmt.Errorf("Sorting was cancelled: %w", cause)
 			} else {
 				err = fmt.Errorf("Unknown error during sorting.")
 				stack := debug.Stack()
@@ -238,16 +726,38 @@ func (this *databaseStruct) sort() (err error) {
 	 * Create database accessor for the sort algorithm.
 	 */
 	sorter := databaseSorterStruct{
-		db: this,
+		ctx: ctx,
+		db:  this,
 	}
 
 	sort.Stable(&sorter)
 	return
 }
 
+/*
+ * Takes a snapshot of the database for a serializer.
+ *
+ * The snapshot lock is held for reading until the serializer is closed, so
+ * that the entries covered by the snapshot are not rewritten. Locations
+ * appended in the meantime lie beyond these entries, so appending is not
+ * blocked, but these locations are not part of the snapshot.
+ *
+ * Returns the number of entries in the snapshot.
+ */
+func (this *databaseStruct) snapshot() uint32 {
+	this.snapshotLock.RLock()
+	this.mutex.RLock()
+	locationCount := this.locationCount
+	this.mutex.RUnlock()
+	return locationCount
+}
+
 /*
  * Appends the location pointed to by loc to the database.
  *
+ * Coordinates are rounded to the precision set using SetPrecision before
+ * they are stored.
+ *
  * When loc == nil, this is a no-op.
  *
  * When loc != nil, this temporarily locks the database for write access.
@@ -270,15 +780,17 @@ func (this *databaseStruct) Append(loc *Location) error {
 		 * store another location.
 		 */
 		if fd == nil {
-			errResult = fmt.Errorf("%s", "Database is closed.")
+			errResult = createError(ErrClosed, "%s", "Database is closed.")
 		} else if locationCount >= math.MaxUint32 {
-			errResult = fmt.Errorf("Reached maximum number of stored locations: %d", math.MaxUint32)
+			errResult = createError(ErrOutOfRange, "Reached maximum number of stored locations: %d", math.MaxUint32)
 		} else {
 			timestamp := loc.Timestamp
 			timestampMSB := uint16((timestamp & 0xffff00000000) >> 32)
 			timestampLSB := uint32(timestamp & 0xffffffff)
-			latitudeE7 := loc.LatitudeE7
-			longitudeE7 := loc.LongitudeE7
+			quantum := this.quantum
+			latitudeE7 := quantizeE7(loc.LatitudeE7, quantum)
+			longitudeE7 := quantizeE7(loc.LongitudeE7, quantum)
+			accuracy := loc.Accuracy
 
 			/*
 			 * Create database entry.
@@ -288,6 +800,7 @@ func (this *databaseStruct) Append(loc *Location) error {
 				TimestampLSB: timestampLSB,
 				LatitudeE7:   latitudeE7,
 				LongitudeE7:  longitudeE7,
+				Accuracy:     accuracy,
 			}
 
 			buf := bytes.Buffer{}
@@ -305,9 +818,12 @@ func (this *databaseStruct) Append(loc *Location) error {
 			} else if sizeWrittenBuf != SIZE_DATABASE_ENTRY {
 				errResult = fmt.Errorf("Unexpected size of database entry: Expected %d, got %d.", SIZE_DATABASE_ENTRY, sizeWrittenBuf)
 			} else {
-				content := buf.Next(sizeWrittenBuf)
+				entrySize := this.entrySize
+				entrySize64 := int64(entrySize)
+				entrySizeInt := int(entrySize)
+				content := buf.Next(entrySizeInt)
 				locationCount64 := int64(locationCount)
-				offset := SIZE_DATABASE_HEADER + (SIZE_DATABASE_ENTRY * locationCount64)
+				offset := SIZE_DATABASE_HEADER + (entrySize64 * locationCount64)
 				sizeWrittenFd, err := fd.WriteAt(content, offset)
 
 				/*
@@ -316,10 +832,29 @@ func (this *databaseStruct) Append(loc *Location) error {
 				if err != nil {
 					reason := err.Error()
 					errResult = fmt.Errorf("Failed to write database entry: %s", reason)
-				} else if sizeWrittenFd != sizeWrittenBuf {
-					errResult = fmt.Errorf("Unexpected write size when writing database entry: Expected %d, got %d.", sizeWrittenBuf, sizeWrittenFd)
+				} else if sizeWrittenFd != entrySizeInt {
+					errResult = fmt.Errorf("Unexpected write size when writing database entry: Expected %d, got %d.", entrySizeInt, sizeWrittenFd)
 				} else {
 					this.locationCount = locationCount + 1
+					order := this.order
+
+					/*
+					 * Keep track of the order of the database.
+					 */
+					if order == ORDER_ORDERED {
+						timestampLast := this.timestampLast
+
+						/*
+						 * Check if the new location is out of order.
+						 */
+						if timestamp < timestampLast {
+							this.order = ORDER_UNORDERED
+						} else {
+							this.timestampLast = timestamp
+						}
+
+					}
+
 				}
 
 			}
@@ -333,59 +868,263 @@ func (this *databaseStruct) Append(loc *Location) error {
 }
 
 /*
- * Clears the database, removing all entries.
+ * Appends multiple locations to the database at once.
  *
- * Caller has to provide a hash of the database contents.
+ * All entries are serialized into a single buffer, which is written to the
+ * end of the database using a single write operation. Coordinates are rounded
+ * to the precision set using SetPrecision before they are stored.
  *
- * Returns the number of removed entries.
+ * Returns the number of locations appended. If an error occurs, the database
+ * contains the locations appended before the error and the file is truncated
+ * to a whole number of entries.
+ *
+ * This temporarily locks the database for write access.
  */
-func (this *databaseStruct) Clear(hash []byte) (uint32, error) {
+func (this *databaseStruct) AppendBatch(locs []Location) (uint32, error) {
 	result := uint32(0)
 	errResult := error(nil)
 	this.mutex.Lock()
 	fd := this.fd
+	locationCount := this.locationCount
+	numLocs := len(locs)
+	numLocs64 := uint64(numLocs)
+	capacity := uint64(math.MaxUint32 - locationCount)
 
 	/*
-	 * Verify that database is not closed.
+	 * Check if there is an open file descriptor and space left to store
+	 * the locations.
 	 */
 	if fd == nil {
-		errResult = fmt.Errorf("%s", "Database is already closed")
-	} else {
-		h := sha512.New()
-		locationCount := this.locationCount
-		locationCount64 := int64(locationCount)
-		size := SIZE_DATABASE_HEADER + (SIZE_DATABASE_ENTRY * locationCount64)
-		r := io.NewSectionReader(fd, 0, size)
-		_, err := io.Copy(h, r)
+		errResult = createError(ErrClosed, "%s", "Database is closed.")
+	} else if numLocs64 > capacity {
+		errResult = createError(ErrOutOfRange, "Reached maximum number of stored locations: %d", math.MaxUint32)
+	} else if numLocs > 0 {
+		quantum := this.quantum
+		entries := make([]databaseEntryStruct, numLocs)
 
 		/*
-		 * Check if an I/O error occured.
+		 * Create database entries.
+		 */
+		for i := range locs {
+			loc := &locs[i]
+			timestamp := loc.Timestamp
+			timestampMSB := uint16((timestamp & 0xffff00000000) >> 32)
+			timestampLSB := uint32(timestamp & 0xffffffff)
+			latitudeE7 := quantizeE7(loc.LatitudeE7, quantum)
+			longitudeE7 := quantizeE7(loc.LongitudeE7, quantum)
+			accuracy := loc.Accuracy
+
+			/*
+			 * Create database entry.
+			 */
+			entries[i] = databaseEntryStruct{
+				TimestampMSB: timestampMSB,
+				TimestampLSB: timestampLSB,
+				LatitudeE7:   latitudeE7,
+				LongitudeE7:  longitudeE7,
+				Accuracy:     accuracy,
+			}
+
+		}
+
+		expectedSize := SIZE_DATABASE_ENTRY * numLocs
+		buf := bytes.Buffer{}
+		buf.Grow(expectedSize)
+		endianness := binary.BigEndian
+		err := binary.Write(&buf, endianness, entries)
+		sizeWrittenBuf := buf.Len()
+
+		/*
+		 * Check if database entries could be serialized.
 		 */
 		if err != nil {
-			msg := err.Error()
-			errResult = fmt.Errorf("I/O error: %s", msg)
+			reason := err.Error()
+			errResult = fmt.Errorf("Failed to serialize database entries: %s", reason)
+		} else if sizeWrittenBuf != expectedSize {
+			errResult = fmt.Errorf("Unexpected size of database entries: Expected %d, got %d.", expectedSize, sizeWrittenBuf)
 		} else {
-			buf := make([]byte, sha512.Size)
-			buf = h.Sum(buf[:0])
-			comparisonResult := subtle.ConstantTimeCompare(buf, hash)
+			content := buf.Bytes()
+			entrySize := this.entrySize
+			entrySize64 := int64(entrySize)
+			entrySizeInt := int(entrySize)
 
 			/*
-			 * If the hash matches, clear database.
+			 * Entries in legacy databases do not store the
+			 * accuracy, so strip it from each entry.
 			 */
-			if comparisonResult != SUBTLE_COMPARE_EQUAL {
-				errResult = fmt.Errorf("%s", "Hashes do not match")
-			} else {
-				err := fd.Truncate(SIZE_DATABASE_HEADER)
+			if entrySizeInt < SIZE_DATABASE_ENTRY {
 
 				/*
-				 * Check if truncation was successful.
+				 * Move each entry to its position in the
+				 * compacted buffer.
 				 */
-				if err != nil {
+				for i := 0; i < numLocs; i++ {
+					src := SIZE_DATABASE_ENTRY * i
+					dst := entrySizeInt * i
+					entry := content[src : src+entrySizeInt]
+					copy(content[dst:], entry)
+				}
+
+				size := entrySizeInt * numLocs
+				content = content[0:size]
+			}
+
+			sizeContent := len(content)
+			locationCount64 := int64(locationCount)
+			offset := SIZE_DATABASE_HEADER + (entrySize64 * locationCount64)
+			sizeWrittenFd, err := fd.WriteAt(content, offset)
+			numWritten := sizeWrittenFd / entrySizeInt
+
+			/*
+			 * Check if buffer could be written to file.
+			 */
+			if err != nil {
+				reason := err.Error()
+				errResult = fmt.Errorf("Failed to write database entries: %s", reason)
+			} else if sizeWrittenFd != sizeContent {
+				errResult = fmt.Errorf("Unexpected write size when writing database entries: Expected %d, got %d.", sizeContent, sizeWrittenFd)
+			}
+
+			/*
+			 * Remove partially written entry on failure.
+			 */
+			if errResult != nil {
+				numWritten64 := int64(numWritten)
+				fileSize := offset + (entrySize64 * numWritten64)
+				err := fd.Truncate(fileSize)
+
+				/*
+				 * Check if file could be truncated.
+				 */
+				if err != nil {
+					msg := errResult.Error()
+					reason := err.Error()
+					errResult = fmt.Errorf("%s Failed to truncate database: %s", msg, reason)
+				}
+
+			}
+
+			order := this.order
+			timestampLast := this.timestampLast
+
+			/*
+			 * Keep track of the order of the database.
+			 */
+			for i := 0; (i < numWritten) && (order == ORDER_ORDERED); i++ {
+				timestamp := locs[i].Timestamp
+
+				/*
+				 * Check if the location is out of order.
+				 */
+				if timestamp < timestampLast {
+					order = ORDER_UNORDERED
+				} else {
+					timestampLast = timestamp
+				}
+
+			}
+
+			this.order = order
+			this.timestampLast = timestampLast
+			result = uint32(numWritten)
+			this.locationCount = locationCount + result
+		}
+
+	}
+
+	this.mutex.Unlock()
+	return result, errResult
+}
+
+/*
+ * Clears the database, removing all entries.
+ *
+ * Caller has to provide a hash of the database contents.
+ *
+ * Returns the number of removed entries.
+ */
+func (this *databaseStruct) Clear(hash []byte) (uint32, error) {
+	result := uint32(0)
+	errResult := error(nil)
+	this.snapshotLock.Lock()
+	this.mutex.Lock()
+	fd := this.fd
+
+	/*
+	 * Verify that database is not closed.
+	 */
+	if fd == nil {
+		errResult = createError(ErrClosed, "%s", "Database is already closed")
+	} else {
+		h := sha512.New()
+		locationCount := this.locationCount
+		locationCount64 := int64(locationCount)
+		entrySize := this.entrySize
+		entrySize64 := int64(entrySize)
+		size := SIZE_DATABASE_HEADER + (entrySize64 * locationCount64)
+		r := io.NewSectionReader(fd, 0, size)
+		_, err := io.Copy(h, r)
+
+		/*
+		 * Check if an I/O error occured.
+		 */
+		if err != nil {
+			msg := err.Error()
+			errResult = fmt.Errorf("I/O error: %s", msg)
+		} else {
+			buf := make([]byte, sha512.Size)
+			buf = h.Sum(buf[:0])
+			comparisonResult := subtle.ConstantTimeCompare(buf, hash)
+
+			/*
+			 * If the hash matches, clear database.
+			 */
+			if comparisonResult != SUBTLE_COMPARE_EQUAL {
+				errResult = fmt.Errorf("%s", "Hashes do not match")
+			} else {
+				size := int64(SIZE_DATABASE_HEADER)
+
+				/*
+				 * Legacy databases are upgraded to the current
+				 * version by writing a new header.
+				 */
+				if entrySize != SIZE_DATABASE_ENTRY {
+					size = 0
+				}
+
+				err := fd.Truncate(size)
+
+				/*
+				 * Check if truncation was successful.
+				 */
+				if err != nil {
 					msg := err.Error()
 					errResult = fmt.Errorf("Error truncating database file: %s", msg)
 				} else {
 					result = locationCount
 					this.locationCount = 0
+					this.order = ORDER_ORDERED
+					this.revision++
+					this.timestampLast = 0
+
+					/*
+					 * Write a new header if the database
+					 * is being upgraded.
+					 */
+					if size == 0 {
+						_, entrySize, err := prepareStorage(fd)
+
+						/*
+						 * Check if header was written.
+						 */
+						if err != nil {
+							errResult = fmt.Errorf("Error upgrading database: %w", err)
+						} else {
+							this.entrySize = entrySize
+						}
+
+					}
+
 				}
 
 			}
@@ -395,6 +1134,7 @@ func (this *databaseStruct) Clear(hash []byte) (uint32, error) {
 	}
 
 	this.mutex.Unlock()
+	this.snapshotLock.Unlock()
 	return result, errResult
 }
 
@@ -403,15 +1143,41 @@ func (this *databaseStruct) Clear(hash []byte) (uint32, error) {
  *
  * NOTE: This does NOT close the file descriptor itself!
  *
+ * Pending writes are synced to the underlying storage first. The database is
+ * closed even if this fails.
+ *
  * If the database is already closed, this is a no-op.
  *
  * This temporarily locks the database for write access.
  */
-func (this *databaseStruct) Close() {
+func (this *databaseStruct) Close() error {
+	errResult := error(nil)
+	this.snapshotLock.Lock()
 	this.mutex.Lock()
+	fd := this.fd
+
+	/*
+	 * Sync pending writes if database is still open.
+	 */
+	if fd != nil {
+		err := fd.Sync()
+
+		/*
+		 * Check if pending writes could be synced.
+		 */
+		if err != nil {
+			msg := err.Error()
+			errResult = fmt.Errorf("Failed to sync location database: %s", msg)
+		}
+
+	}
+
 	this.fd = nil
 	this.locationCount = 0
+	this.order = ORDER_UNKNOWN
 	this.mutex.Unlock()
+	this.snapshotLock.Unlock()
+	return errResult
 }
 
 /*
@@ -419,25 +1185,31 @@ func (this *databaseStruct) Close() {
  *
  * Returns the number of removed entries.
  *
- * This implicitly sorts the database.
+ * This implicitly sorts the database. Cancelling the context aborts the
+ * operation while sorting. Once sorting is complete, removal of duplicates
+ * runs to completion, so that the database is always left consistent.
  */
-func (this *databaseStruct) Deduplicate() (uint32, error) {
+func (this *databaseStruct) Deduplicate(ctx context.Context) (uint32, error) {
+	this.snapshotLock.Lock()
 	this.mutex.Lock()
+	this.revision++
 	numSkipped := uint32(0)
 	errResult := error(nil)
-	err := this.sort()
+	err := this.sort(ctx)
 
 	/*
 	 * Check if sorting was successful.
 	 */
 	if err != nil {
-		msg := err.Error()
-		errResult = fmt.Errorf("Error occured during sorting: %s", msg)
+		errResult = fmt.Errorf("Error occured during sorting: %w", err)
 	} else {
 		numEntries := this.locationCount
+		entrySize := this.entrySize
+		entrySize64 := int64(entrySize)
+		entrySizeInt := int(entrySize)
 		bufCurrentEntries := [][SIZE_DATABASE_ENTRY]byte{}
-		bufCurrentEntry := make([]byte, SIZE_DATABASE_ENTRY)
-		bufPreviousEntry := make([]byte, SIZE_DATABASE_ENTRY)
+		bufCurrentEntry := make([]byte, entrySize)
+		bufPreviousEntry := make([]byte, entrySize)
 		fd := this.fd
 
 		/*
@@ -445,7 +1217,7 @@ func (this *databaseStruct) Deduplicate() (uint32, error) {
 		 */
 		for readIdx := uint32(0); (errResult == nil) && (readIdx < numEntries); readIdx++ {
 			readIdx64 := int64(readIdx)
-			offsetRead := SIZE_DATABASE_HEADER + (SIZE_DATABASE_ENTRY * readIdx64)
+			offsetRead := SIZE_DATABASE_HEADER + (entrySize64 * readIdx64)
 			n, err := fd.ReadAt(bufCurrentEntry, offsetRead)
 
 			/*
@@ -454,8 +1226,8 @@ func (this *databaseStruct) Deduplicate() (uint32, error) {
 			if err != nil {
 				msg := err.Error()
 				errResult = fmt.Errorf("Error reading from offset %016x (%d): %s", offsetRead, offsetRead, msg)
-			} else if n != SIZE_DATABASE_ENTRY {
-				errResult = fmt.Errorf("Expected %d bytes reading from offset 0x%016x (%d), but got %d.", SIZE_DATABASE_ENTRY, offsetRead, offsetRead, n)
+			} else if n != entrySizeInt {
+				errResult = fmt.Errorf("Expected %d bytes reading from offset 0x%016x (%d), but got %d.", entrySizeInt, offsetRead, offsetRead, n)
 			} else {
 				currentTimestamp := bufCurrentEntry[0:SIZE_TIMESTAMP]
 				previousTimestamp := bufPreviousEntry[0:SIZE_TIMESTAMP]
@@ -470,13 +1242,18 @@ func (this *databaseStruct) Deduplicate() (uint32, error) {
 
 				skipCurrent := false
 
+				currentLocation := bufCurrentEntry[0:SIZE_DATABASE_ENTRY_LEGACY]
+
 				/*
 				 * Iterate over all previously seen entries with the same time
 				 * stamp and check if the current entry has already been seen.
+				 *
+				 * Only time stamp and coordinates are compared, so that
+				 * entries differing only in accuracy are duplicates.
 				 */
 				for _, entry := range bufCurrentEntries {
-					entrySlice := entry[:]
-					entryEqual := bytes.Equal(entrySlice, bufCurrentEntry)
+					entryLocation := entry[0:SIZE_DATABASE_ENTRY_LEGACY]
+					entryEqual := bytes.Equal(entryLocation, currentLocation)
 					skipCurrent = skipCurrent || entryEqual
 				}
 
@@ -498,7 +1275,7 @@ func (this *databaseStruct) Deduplicate() (uint32, error) {
 					if numSkipped > 0 {
 						writeIdx := readIdx - numSkipped
 						writeIdx64 := int64(writeIdx)
-						offsetWrite := SIZE_DATABASE_HEADER + (SIZE_DATABASE_ENTRY * writeIdx64)
+						offsetWrite := SIZE_DATABASE_HEADER + (entrySize64 * writeIdx64)
 						n, err := fd.WriteAt(bufCurrentEntry, offsetWrite)
 
 						/*
@@ -508,8 +1285,8 @@ func (this *databaseStruct) Deduplicate() (uint32, error) {
 						if err != nil {
 							msg := err.Error()
 							errResult = fmt.Errorf("Error writing to offset %016x (%d): %s", offsetWrite, offsetWrite, msg)
-						} else if n != SIZE_DATABASE_ENTRY {
-							errResult = fmt.Errorf("Expected %d bytes writing to offset 0x%016x (%d), but got %d.", SIZE_DATABASE_ENTRY, offsetWrite, offsetWrite, n)
+						} else if n != entrySizeInt {
+							errResult = fmt.Errorf("Expected %d bytes writing to offset 0x%016x (%d), but got %d.", entrySizeInt, offsetWrite, offsetWrite, n)
 						}
 
 					}
@@ -525,12 +1302,12 @@ func (this *databaseStruct) Deduplicate() (uint32, error) {
 		 * Make sure that we didn't skip more entries than are in the database.
 		 */
 		if numSkipped > numEntries {
-			errResult = fmt.Errorf("Skipped more entries (%d) than there are in the database (%d).", numSkipped, numEntries)
+			errResult = createError(ErrCorrupt, "Skipped more entries (%d) than there are in the database (%d).", numSkipped, numEntries)
 		} else {
 			numEntries -= numSkipped
 			this.locationCount = numEntries
 			numEntries64 := int64(numEntries)
-			fileSize := SIZE_DATABASE_HEADER + (SIZE_DATABASE_ENTRY * numEntries64)
+			fileSize := SIZE_DATABASE_HEADER + (entrySize64 * numEntries64)
 			err := fd.Truncate(fileSize)
 
 			/*
@@ -545,771 +1322,3002 @@ func (this *databaseStruct) Deduplicate() (uint32, error) {
 
 	}
 
+	/*
+	 * Deduplication leaves the database ordered, unless it failed.
+	 */
+	if errResult != nil {
+		this.order = ORDER_UNKNOWN
+	} else {
+		this.markOrdered()
+	}
+
 	this.mutex.Unlock()
+	this.snapshotLock.Unlock()
 	return numSkipped, errResult
 }
 
 /*
- * Returns the number of locations stored in the database.
- *
- * On a closed database, this returns zero.
+ * Collapses runs of consecutive entries which were recorded close to each
+ * other into their first entry, e.g. while the device was not moving.
  *
- * This temporarily locks the database for read access.
- */
-func (this *databaseStruct) LocationCount() uint32 {
-	this.mutex.RLock()
-	result := this.locationCount
-	this.mutex.RUnlock()
-	return result
-}
-
-/*
- * Reads locations from the database into target, starting at the provided
- * offset.
+ * An entry is removed if it lies at most maxDistanceMeters away from the last
+ * entry kept and was recorded at most maxTimeGapMs after it. Otherwise, it is
+ * kept and starts a new run. Since the time is measured from the last entry
+ * kept, a device which does not move leaves one entry every maxTimeGapMs.
  *
- * Will fill the target buffer unless there are not enough locations left.
+ * Returns the number of removed entries.
  *
- * Returns the number of locations read and whether read errors occured.
+ * The database has to be ordered by time stamp. Otherwise, an error of
+ * category ErrUnordered is returned.
  *
- * When len(target) > 0, this temporarily locks the database for read access.
+ * This temporarily locks the database for write access.
  */
-func (this *databaseStruct) ReadLocations(offset uint32, target []Location) (uint32, error) {
-	numReadErrors := uint64(0)
-	firstReadErrorOffset := uint64(0)
-	numDeserializationErrors := uint64(0)
-	firstDeserializationErrorOffset := uint64(0)
-	numLocationsTarget := len(target)
-	numLocationsRead := uint32(0)
+func (this *databaseStruct) DeduplicateThreshold(maxDistanceMeters float64, maxTimeGapMs uint64) (uint32, error) {
+	result := uint32(0)
+	errResult := error(nil)
+	this.snapshotLock.Lock()
+	this.mutex.Lock()
+	fd := this.fd
 
 	/*
-	 * Check if we have to read locations.
+	 * Determine order if it is not known.
 	 */
-	if numLocationsTarget > 0 {
-		this.mutex.RLock()
-		locationCount := this.locationCount
+	if (fd != nil) && (this.order == ORDER_UNKNOWN) {
+		this.determineOrder()
+	}
+
+	/*
+	 * Verify that database is not closed, ordered and the distance is
+	 * valid.
+	 */
+	if fd == nil {
+		errResult = createError(ErrClosed, "%s", "Database is closed.")
+	} else if this.order != ORDER_ORDERED {
+		errResult = createError(ErrUnordered, "%s", "Database must be sorted by time stamp before removing nearby entries.")
+	} else if (maxDistanceMeters < 0.0) || math.IsNaN(maxDistanceMeters) {
+		errResult = createError(ErrOutOfRange, "Maximum distance must not be negative, but was %g.", maxDistanceMeters)
+	} else {
+		this.revision++
+		numEntries := this.locationCount
+		entrySize := this.entrySize
+		entrySize64 := int64(entrySize)
+		entrySizeInt := int(entrySize)
+		bufRead := make([]byte, SIZE_DATABASE_ENTRY)
+		bufCurrentEntry := bufRead[0:entrySize]
+		endianness := binary.BigEndian
+		kept := Location{}
 
 		/*
-		 * Check if we are in bounds.
+		 * Read every entry.
 		 */
-		if offset < locationCount {
-			numLocationsToRead := uint32(numLocationsTarget)
-
-			/*
-			 * Prevent overflow.
-			 */
-			if numLocationsToRead > math.MaxUint32 {
-				numLocationsToRead = math.MaxUint32
-			}
-
-			numLocationsInFile := locationCount - offset
-
-			/*
-			 * We can only read as many locations as are in the file.
-			 */
-			if numLocationsToRead > numLocationsInFile {
-				numLocationsToRead = numLocationsInFile
-			}
-
-			buf := make([]byte, SIZE_DATABASE_ENTRY)
-			entry := databaseEntryStruct{}
-			fd := this.fd
-			endianness := binary.BigEndian
+		for readIdx := uint32(0); (errResult == nil) && (readIdx < numEntries); readIdx++ {
+			readIdx64 := int64(readIdx)
+			offsetRead := SIZE_DATABASE_HEADER + (entrySize64 * readIdx64)
+			n, err := fd.ReadAt(bufCurrentEntry, offsetRead)
 
 			/*
-			 * Read locations from file.
+			 * Check for errors.
 			 */
-			for idx := uint32(0); idx < numLocationsToRead; idx++ {
-				offsetTotal := offset + idx
-				offsetTotal64 := uint64(offsetTotal)
-				offsetBytes := SIZE_DATABASE_HEADER + (SIZE_DATABASE_ENTRY * offsetTotal64)
-				offsetBytesSigned := int64(offsetBytes)
-				numBytesRead, err := fd.ReadAt(buf, offsetBytesSigned)
+			if err != nil {
+				msg := err.Error()
+				errResult = fmt.Errorf("Error reading from offset %016x (%d): %s", offsetRead, offsetRead, msg)
+			} else if n != entrySizeInt {
+				errResult = fmt.Errorf("Expected %d bytes reading from offset 0x%016x (%d), but got %d.", entrySizeInt, offsetRead, offsetRead, n)
+			} else {
+				entry := databaseEntryStruct{}
+				rd := bytes.NewReader(bufRead)
+				err = binary.Read(rd, endianness, &entry)
 
 				/*
-				 * If we read less bytes than expected, zero
-				 * out part of the buffer.
+				 * Check if database entry could be deserialized.
 				 */
-				if numBytesRead < SIZE_DATABASE_ENTRY {
-					zero := buf[numBytesRead:SIZE_DATABASE_ENTRY]
+				if err != nil {
+					errResult = createError(ErrCorrupt, "Error deserializing entry at offset: 0x%016x", offsetRead)
+				} else {
+					timestampMSB := entry.TimestampMSB
+					timestampMSB64 := uint64(timestampMSB)
+					timestampLSB := entry.TimestampLSB
+					timestampLSB64 := uint64(timestampLSB)
+					timestamp := (timestampMSB64 << 32) | timestampLSB64
 
 					/*
-					 * Zero the unused part of the buffer.
+					 * Create location.
 					 */
-					for i := range zero {
-						zero[i] = 0
+					current := Location{
+						Timestamp:   timestamp,
+						LatitudeE7:  entry.LatitudeE7,
+						LongitudeE7: entry.LongitudeE7,
+						Accuracy:    entry.Accuracy,
 					}
 
-				}
-
-				/*
-				 * Check for read error.
-				 */
-				if err != nil {
+					remove := false
 
 					/*
-					 * If this is the first read error,
-					 * store offset.
+					 * The first entry is always kept.
 					 */
-					if numReadErrors == 0 {
-						firstReadErrorOffset = offsetBytes
+					if readIdx > 0 {
+						timeGap := current.Timestamp - kept.Timestamp
+						distance := distanceMeters(&kept, &current)
+						remove = (timeGap <= maxTimeGapMs) && (distance <= maxDistanceMeters)
 					}
 
 					/*
-					 * Count read errors.
+					 * Check if we shall remove the current entry.
 					 */
-					if numReadErrors < math.MaxUint64 {
-						numReadErrors++
+					if remove {
+						result++
+					} else {
+						kept = current
+
+						/*
+						 * Check if current entry shall be moved to be
+						 * preserved due to previous entries being
+						 * removed.
+						 */
+						if result > 0 {
+							writeIdx := readIdx - result
+							writeIdx64 := int64(writeIdx)
+							offsetWrite := SIZE_DATABASE_HEADER + (entrySize64 * writeIdx64)
+							n, err := fd.WriteAt(bufCurrentEntry, offsetWrite)
+
+							/*
+							 * Check if write error occured or write
+							 * was not of expected size.
+							 */
+							if err != nil {
+								msg := err.Error()
+								errResult = fmt.Errorf("Error writing to offset %016x (%d): %s", offsetWrite, offsetWrite, msg)
+							} else if n != entrySizeInt {
+								errResult = fmt.Errorf("Expected %d bytes writing to offset 0x%016x (%d), but got %d.", entrySizeInt, offsetWrite, offsetWrite, n)
+							}
+
+						}
+
 					}
 
 				}
 
-				rd := bytes.NewReader(buf)
-				err = binary.Read(rd, endianness, &entry)
+			}
 
-				/*
-				 * Check if database entry could be deserialized.
+		}
+
+		/*
+		 * Truncate the file if entries were removed.
+		 */
+		if (errResult == nil) && (result > 0) {
+			numEntries -= result
+			numEntries64 := int64(numEntries)
+			fileSize := SIZE_DATABASE_HEADER + (entrySize64 * numEntries64)
+			err := fd.Truncate(fileSize)
+
+			/*
+			 * Check if error occured during truncation.
+			 */
+			if err != nil {
+				msg := err.Error()
+				errResult = fmt.Errorf("Failed to truncate file to size 0x%016x (%d): %s", fileSize, fileSize, msg)
+			} else {
+				this.locationCount = numEntries
+			}
+
+		}
+
+		/*
+		 * Removal preserves the order of the database, unless it failed.
+		 */
+		if errResult != nil {
+			this.order = ORDER_UNKNOWN
+		} else {
+			this.markOrdered()
+		}
+
+	}
+
+	this.mutex.Unlock()
+	this.snapshotLock.Unlock()
+	return result, errResult
+}
+
+/*
+ * Removes all entries with a time stamp from minTimestampMs to maxTimestampMs
+ * (both inclusive) from the database.
+ *
+ * Returns the number of removed entries.
+ *
+ * If the database is ordered by time stamp, the range is found using binary
+ * search and the entries following it are moved down. Otherwise, all entries
+ * are scanned. The order of the remaining entries is preserved.
+ *
+ * This temporarily locks the database for write access.
+ */
+func (this *databaseStruct) DeleteRange(minTimestampMs uint64, maxTimestampMs uint64) (uint32, error) {
+	result := uint32(0)
+	errResult := error(nil)
+	this.snapshotLock.Lock()
+	this.mutex.Lock()
+	fd := this.fd
+
+	/*
+	 * Verify that database is not closed and the range is valid.
+	 */
+	if fd == nil {
+		errResult = createError(ErrClosed, "%s", "Database is closed.")
+	} else if minTimestampMs > maxTimestampMs {
+		errResult = createError(ErrOutOfRange, "Beginning of range (%d) lies after its end (%d).", minTimestampMs, maxTimestampMs)
+	} else {
+		this.revision++
+
+		/*
+		 * Determine order if it is not known.
+		 *
+		 * If this fails, the database is scanned.
+		 */
+		if this.order == ORDER_UNKNOWN {
+			this.determineOrder()
+		}
+
+		order := this.order
+		numEntries := this.locationCount
+
+		/*
+		 * Search the range in ordered databases, otherwise scan all
+		 * entries.
+		 */
+		if order == ORDER_ORDERED {
+			begin, err := this.searchTimestamp(minTimestampMs)
+			end := numEntries
+
+			/*
+			 * The end of the range is the first entry after it,
+			 * unless the range extends to the largest time stamp.
+			 */
+			if (err == nil) && (maxTimestampMs < math.MaxUint64) {
+				end, err = this.searchTimestamp(maxTimestampMs + 1)
+			}
+
+			/*
+			 * Check if range could be found.
+			 */
+			if err != nil {
+				msg := err.Error()
+				errResult = fmt.Errorf("Error searching range: %s", msg)
+			} else if begin < end {
+				errResult = this.removeEntries(begin, end)
+
+				/*
+				 * Check if entries were removed.
 				 */
-				if err != nil {
-					target[idx] = Location{}
+				if errResult == nil {
+					result = end - begin
+				}
 
-					/*
-					 * If this is the first deserialization
-					 * error, store offset.
-					 */
-					if numDeserializationErrors == 0 {
-						firstDeserializationErrorOffset = offsetBytes
-					}
+			}
 
-					/*
-					 * Count deserialization errors.
-					 */
-					if numDeserializationErrors < math.MaxUint64 {
-						numDeserializationErrors++
-					}
+		} else {
+			entrySize := this.entrySize
+			entrySize64 := int64(entrySize)
+			entrySizeInt := int(entrySize)
+			bufCurrentEntry := make([]byte, entrySize)
+			endianness := binary.BigEndian
+
+			/*
+			 * Read every entry.
+			 */
+			for readIdx := uint32(0); (errResult == nil) && (readIdx < numEntries); readIdx++ {
+				readIdx64 := int64(readIdx)
+				offsetRead := SIZE_DATABASE_HEADER + (entrySize64 * readIdx64)
+				n, err := fd.ReadAt(bufCurrentEntry, offsetRead)
 
+				/*
+				 * Check for errors.
+				 */
+				if err != nil {
+					msg := err.Error()
+					errResult = fmt.Errorf("Error reading from offset %016x (%d): %s", offsetRead, offsetRead, msg)
+				} else if n != entrySizeInt {
+					errResult = fmt.Errorf("Expected %d bytes reading from offset 0x%016x (%d), but got %d.", entrySizeInt, offsetRead, offsetRead, n)
 				} else {
-					timestampMSB := entry.TimestampMSB
+					timestampMSBBytes := bufCurrentEntry[0:2]
+					timestampMSB := endianness.Uint16(timestampMSBBytes)
 					timestampMSB64 := uint64(timestampMSB)
-					timestampLSB := entry.TimestampLSB
+					timestampLSBBytes := bufCurrentEntry[2:SIZE_TIMESTAMP]
+					timestampLSB := endianness.Uint32(timestampLSBBytes)
 					timestampLSB64 := uint64(timestampLSB)
 					timestamp := (timestampMSB64 << 32) | timestampLSB64
-					latitudeE7 := entry.LatitudeE7
-					longitudeE7 := entry.LongitudeE7
 
 					/*
-					 * Fill in location structure.
+					 * Check if we shall remove the current entry.
 					 */
-					target[idx] = Location{
-						Timestamp:   timestamp,
-						LatitudeE7:  latitudeE7,
-						LongitudeE7: longitudeE7,
+					if (timestamp >= minTimestampMs) && (timestamp <= maxTimestampMs) {
+						result++
+					} else if result > 0 {
+						writeIdx := readIdx - result
+						writeIdx64 := int64(writeIdx)
+						offsetWrite := SIZE_DATABASE_HEADER + (entrySize64 * writeIdx64)
+						n, err := fd.WriteAt(bufCurrentEntry, offsetWrite)
+
+						/*
+						 * Check if write error occured or write was
+						 * not of expected size.
+						 */
+						if err != nil {
+							msg := err.Error()
+							errResult = fmt.Errorf("Error writing to offset %016x (%d): %s", offsetWrite, offsetWrite, msg)
+						} else if n != entrySizeInt {
+							errResult = fmt.Errorf("Expected %d bytes writing to offset 0x%016x (%d), but got %d.", entrySizeInt, offsetWrite, offsetWrite, n)
+						}
+
 					}
 
 				}
 
 			}
 
-			numLocationsRead = numLocationsToRead
-		}
+			/*
+			 * Truncate the file if entries were removed.
+			 */
+			if (errResult == nil) && (result > 0) {
+				numEntries -= result
+				numEntries64 := int64(numEntries)
+				fileSize := SIZE_DATABASE_HEADER + (entrySize64 * numEntries64)
+				err := fd.Truncate(fileSize)
 
-		this.mutex.RUnlock()
-	}
+				/*
+				 * Check if error occured during truncation.
+				 */
+				if err != nil {
+					msg := err.Error()
+					errResult = fmt.Errorf("Failed to truncate file to size 0x%016x (%d): %s", fileSize, fileSize, msg)
+				} else {
+					this.locationCount = numEntries
+				}
 
-	errResult := error(nil)
+			}
+
+		}
+
+		/*
+		 * Removal preserves the order of an ordered database, unless it
+		 * failed. Removing entries from an unordered database may
+		 * order it.
+		 */
+		if (errResult != nil) || (order != ORDER_ORDERED) {
+			this.order = ORDER_UNKNOWN
+		} else {
+			this.markOrdered()
+		}
 
-	/*
-	 * Check for read errors and deserialization errors.
-	 */
-	switch {
-	case (numReadErrors != 0) && (numDeserializationErrors == 0):
-		errResult = fmt.Errorf("Encountered %d read errors, first at offset %d (0x%016x).", numReadErrors, firstReadErrorOffset, firstReadErrorOffset)
-	case (numReadErrors == 0) && (numDeserializationErrors != 0):
-		errResult = fmt.Errorf("Encountered %d deserialization errors, first at offset %d (0x%016x).", numDeserializationErrors, firstDeserializationErrorOffset, firstDeserializationErrorOffset)
-	case (numReadErrors != 0) && (numDeserializationErrors != 0):
-		errResult = fmt.Errorf("Encountered %d read errors, first at offset %d (0x%016x), and %d deserialization errors, first at offset %d (0x%016x).", numReadErrors, firstReadErrorOffset, firstReadErrorOffset, numDeserializationErrors, firstDeserializationErrorOffset, firstDeserializationErrorOffset)
 	}
 
-	return numLocationsRead, errResult
+	this.mutex.Unlock()
+	this.snapshotLock.Unlock()
+	return result, errResult
 }
 
 /*
- * Locks the database for read access and provides a ReadSeekCloser
- * granting random access to the database in binary format.
+ * Returns the number of locations stored in the database.
+ *
+ * On a closed database, this returns zero.
  *
- * Closing the returned ReadSeekCloser yields the lock on the database.
+ * This temporarily locks the database for read access.
  */
-func (this *databaseStruct) SerializeBinary() io.ReadSeekCloser {
+func (this *databaseStruct) LocationCount() uint32 {
 	this.mutex.RLock()
-
-	/*
-	 * Create database binary serializer.
-	 */
-	s := databaseBinarySerializerStruct{
-		db: this,
-	}
-
-	return &s
+	result := this.locationCount
+	this.mutex.RUnlock()
+	return result
 }
 
 /*
- * Locks the database for read access and provides a ReadCloser granting
- * sequential access to the database in CSV format.
+ * Returns whether the database is ordered by time stamp.
  *
- * CSV data will be generated on-the-fly while reading from the provided
- * ReadCloser.
+ * The order is tracked as the database is modified. If it is not known, for
+ * example right after the database was opened, it is determined by scanning
+ * the database once.
  *
- * Closing the returned ReadCloser yields the lock on the database.
+ * This temporarily locks the database for write access.
  */
-func (this *databaseStruct) SerializeCSV() io.ReadCloser {
-	this.mutex.RLock()
-	buf := &strings.Builder{}
-	w := csv.NewWriter(buf)
+func (this *databaseStruct) Ordered() (bool, error) {
+	result := false
+	errResult := error(nil)
+	this.mutex.Lock()
+	fd := this.fd
 
 	/*
-	 * Create database CSV serializer.
+	 * Verify that database is not closed.
 	 */
-	s := databaseCsvSerializerStruct{
-		csvWriter:  w,
-		db:         this,
-		lineBuffer: buf,
+	if fd == nil {
+		errResult = createError(ErrClosed, "%s", "Database is closed.")
+	} else {
+		order := this.order
+
+		/*
+		 * Determine order if it is not known.
+		 */
+		if order == ORDER_UNKNOWN {
+			errResult = this.determineOrder()
+		}
+
+		result = this.order == ORDER_ORDERED
 	}
 
-	return &s
+	this.mutex.Unlock()
+	return result, errResult
 }
 
 /*
- * Locks the database for read access and provides a ReadCloser granting
- * sequential access to the database in JSON format.
+ * Reads locations from the database into target, starting at the provided
+ * offset.
  *
- * JSON data will be generated on-the-fly while reading from the provided
- * ReadCloser.
+ * Will fill the target buffer unless there are not enough locations left.
  *
- * - When pretty == true, data will be pretty-printed for human consumption.
- * - When pretty == false, data will be compact for machine consumption.
+ * Returns the number of locations read and whether read errors occured.
  *
- * Closing the returned ReadCloser yields the lock on the database.
+ * When len(target) > 0, this temporarily locks the database for read access.
  */
-func (this *databaseStruct) SerializeJSON(pretty bool) io.ReadCloser {
-	this.mutex.RLock()
-	buf := &strings.Builder{}
+func (this *databaseStruct) ReadLocations(offset uint32, target []Location) (uint32, error) {
+	numReadErrors := uint64(0)
+	firstReadErrorOffset := uint64(0)
+	numDeserializationErrors := uint64(0)
+	firstDeserializationErrorOffset := uint64(0)
+	numLocationsTarget := len(target)
+	numLocationsRead := uint32(0)
 
 	/*
-	 * Create database JSON serializer.
+	 * Check if we have to read locations.
 	 */
-	s := databaseJsonSerializerStruct{
-		buffer: buf,
-		db:     this,
-		pretty: pretty,
-		state:  JSON_STREAM_HEADER,
-	}
+	if numLocationsTarget > 0 {
+		this.mutex.RLock()
+		locationCount := this.locationCount
 
-	return &s
-}
+		/*
+		 * Check if we are in bounds.
+		 */
+		if offset < locationCount {
+			numLocationsToRead := uint32(numLocationsTarget)
 
-/*
- * Locks the database for read access and provides a ReadCloser granting
- * sequential access to the database in XML format.
- *
- * XML data will be generated on-the-fly while reading from the provided
- * ReadCloser.
- *
- * - When pretty == true, data will be pretty-printed for human consumption.
- * - When pretty == false, data will be compact for machine consumption.
- *
- * Closing the returned ReadCloser yields the lock on the database.
- */
-func (this *databaseStruct) SerializeXML(pretty bool) io.ReadCloser {
-	this.mutex.RLock()
-	buf := &strings.Builder{}
+			/*
+			 * Prevent overflow.
+			 */
+			if numLocationsToRead > math.MaxUint32 {
+				numLocationsToRead = math.MaxUint32
+			}
+
+			numLocationsInFile := locationCount - offset
+
+			/*
+			 * We can only read as many locations as are in the file.
+			 */
+			if numLocationsToRead > numLocationsInFile {
+				numLocationsToRead = numLocationsInFile
+			}
+
+			entrySize := this.entrySize
+			buf := make([]byte, SIZE_DATABASE_ENTRY)
+			bufEntry := buf[0:entrySize]
+			entry := databaseEntryStruct{}
+			fd := this.fd
+			endianness := binary.BigEndian
+
+			/*
+			 * Read locations from file.
+			 */
+			for idx := uint32(0); idx < numLocationsToRead; idx++ {
+				offsetTotal := offset + idx
+				offsetTotal64 := uint64(offsetTotal)
+				offsetBytes := SIZE_DATABASE_HEADER + (entrySize * offsetTotal64)
+				offsetBytesSigned := int64(offsetBytes)
+				numBytesRead, err := fd.ReadAt(bufEntry, offsetBytesSigned)
+
+				/*
+				 * If we read less bytes than expected, zero
+				 * out part of the buffer.
+				 */
+				if numBytesRead < SIZE_DATABASE_ENTRY {
+					zero := buf[numBytesRead:SIZE_DATABASE_ENTRY]
+
+					/*
+					 * Zero the unused part of the buffer.
+					 */
+					for i := range zero {
+						zero[i] = 0
+					}
+
+				}
+
+				/*
+				 * Check for read error.
+				 */
+				if err != nil {
+
+					/*
+					 * If this is the first read error,
+					 * store offset.
+					 */
+					if numReadErrors == 0 {
+						firstReadErrorOffset = offsetBytes
+					}
+
+					/*
+					 * Count read errors.
+					 */
+					if numReadErrors < math.MaxUint64 {
+						numReadErrors++
+					}
+
+				}
+
+				rd := bytes.NewReader(buf)
+				err = binary.Read(rd, endianness, &entry)
+
+				/*
+				 * Check if database entry could be deserialized.
+				 */
+				if err != nil {
+					target[idx] = Location{}
+
+					/*
+					 * If this is the first deserialization
+					 * error, store offset.
+					 */
+					if numDeserializationErrors == 0 {
+						firstDeserializationErrorOffset = offsetBytes
+					}
+
+					/*
+					 * Count deserialization errors.
+					 */
+					if numDeserializationErrors < math.MaxUint64 {
+						numDeserializationErrors++
+					}
+
+				} else {
+					timestampMSB := entry.TimestampMSB
+					timestampMSB64 := uint64(timestampMSB)
+					timestampLSB := entry.TimestampLSB
+					timestampLSB64 := uint64(timestampLSB)
+					timestamp := (timestampMSB64 << 32) | timestampLSB64
+					latitudeE7 := entry.LatitudeE7
+					longitudeE7 := entry.LongitudeE7
+					accuracy := entry.Accuracy
+
+					/*
+					 * Fill in location structure.
+					 */
+					target[idx] = Location{
+						Timestamp:   timestamp,
+						LatitudeE7:  latitudeE7,
+						LongitudeE7: longitudeE7,
+						Accuracy:    accuracy,
+					}
+
+				}
+
+			}
+
+			numLocationsRead = numLocationsToRead
+		}
+
+		this.mutex.RUnlock()
+	}
+
+	errResult := error(nil)
+
+	/*
+	 * Check for read errors and deserialization errors.
+	 */
+	switch {
+	case (numReadErrors != 0) && (numDeserializationErrors == 0):
+		errResult = fmt.Errorf("Encountered %d read errors, first at offset %d (0x%016x).", numReadErrors, firstReadErrorOffset, firstReadErrorOffset)
+	case (numReadErrors == 0) && (numDeserializationErrors != 0):
+		errResult = createError(ErrCorrupt, "Encountered %d deserialization errors, first at offset %d (0x%016x).", numDeserializationErrors, firstDeserializationErrorOffset, firstDeserializationErrorOffset)
+	case (numReadErrors != 0) && (numDeserializationErrors != 0):
+		errResult = createError(ErrCorrupt, "Encountered %d read errors, first at offset %d (0x%016x), and %d deserialization errors, first at offset %d (0x%016x).", numReadErrors, firstReadErrorOffset, firstReadErrorOffset, numDeserializationErrors, firstDeserializationErrorOffset, firstDeserializationErrorOffset)
+	}
+
+	return numLocationsRead, errResult
+}
+
+/*
+ * Removes all entries with a time stamp before timestampEarliest or after
+ * timestampLatest from the database.
+ *
+ * Returns the number of removed entries.
+ *
+ * The order of the remaining entries is preserved. Cancelling the context
+ * before removal started aborts the operation. Once removal started, it runs
+ * to completion, so that the database is always left consistent.
+ */
+func (this *databaseStruct) RemoveOutsideTimeRange(ctx context.Context, timestampEarliest uint64, timestampLatest uint64) (uint32, error) {
+	this.snapshotLock.Lock()
+	this.mutex.Lock()
+	numSkipped := uint32(0)
+	errResult := ctx.Err()
+
+	/*
+	 * Check if context was cancelled.
+	 */
+	if errResult == nil {
+		this.revision++
+		numEntries := this.locationCount
+		entrySize := this.entrySize
+		entrySize64 := int64(entrySize)
+		entrySizeInt := int(entrySize)
+		bufCurrentEntry := make([]byte, entrySize)
+		fd := this.fd
+		endianness := binary.BigEndian
+
+		/*
+		 * Read every entry.
+		 */
+		for readIdx := uint32(0); (errResult == nil) && (readIdx < numEntries); readIdx++ {
+			readIdx64 := int64(readIdx)
+			offsetRead := SIZE_DATABASE_HEADER + (entrySize64 * readIdx64)
+			n, err := fd.ReadAt(bufCurrentEntry, offsetRead)
+
+			/*
+			 * Check for errors.
+			 */
+			if err != nil {
+				msg := err.Error()
+				errResult = fmt.Errorf("Error reading from offset %016x (%d): %s", offsetRead, offsetRead, msg)
+			} else if n != entrySizeInt {
+				errResult = fmt.Errorf("Expected %d bytes reading from offset 0x%016x (%d), but got %d.", entrySizeInt, offsetRead, offsetRead, n)
+			} else {
+				timestampMSBBytes := bufCurrentEntry[0:2]
+				timestampMSB := endianness.Uint16(timestampMSBBytes)
+				timestampMSB64 := uint64(timestampMSB)
+				timestampLSBBytes := bufCurrentEntry[2:SIZE_TIMESTAMP]
+				timestampLSB := endianness.Uint32(timestampLSBBytes)
+				timestampLSB64 := uint64(timestampLSB)
+				timestamp := (timestampMSB64 << 32) | timestampLSB64
+
+				/*
+				 * Check if we shall skip the current entry.
+				 */
+				if (timestamp < timestampEarliest) || (timestamp > timestampLatest) {
+					numSkipped++
+				} else if numSkipped > 0 {
+					writeIdx := readIdx - numSkipped
+					writeIdx64 := int64(writeIdx)
+					offsetWrite := SIZE_DATABASE_HEADER + (entrySize64 * writeIdx64)
+					n, err := fd.WriteAt(bufCurrentEntry, offsetWrite)
+
+					/*
+					 * Check if write error occured or write was not of
+					 * expected size.
+					 */
+					if err != nil {
+						msg := err.Error()
+						errResult = fmt.Errorf("Error writing to offset %016x (%d): %s", offsetWrite, offsetWrite, msg)
+					} else if n != entrySizeInt {
+						errResult = fmt.Errorf("Expected %d bytes writing to offset 0x%016x (%d), but got %d.", entrySizeInt, offsetWrite, offsetWrite, n)
+					}
+
+				}
+
+			}
+
+		}
+
+		/*
+		 * Make sure that we didn't skip more entries than are in the database.
+		 */
+		if numSkipped > numEntries {
+			errResult = createError(ErrCorrupt, "Skipped more entries (%d) than there are in the database (%d).", numSkipped, numEntries)
+		} else if numSkipped > 0 {
+			numEntries -= numSkipped
+			this.locationCount = numEntries
+			numEntries64 := int64(numEntries)
+			fileSize := SIZE_DATABASE_HEADER + (entrySize64 * numEntries64)
+			err := fd.Truncate(fileSize)
+
+			/*
+			 * Check if error occured during truncation.
+			 */
+			if err != nil {
+				msg := err.Error()
+				errResult = fmt.Errorf("Failed to truncate file to size 0x%016x (%d): %s", fileSize, fileSize, msg)
+			}
+
+		}
+
+	}
+
+	order := this.order
+
+	/*
+	 * Removal preserves the order of the database, unless it failed.
+	 */
+	if (errResult != nil) && (errResult != ctx.Err()) {
+		this.order = ORDER_UNKNOWN
+	} else if (errResult == nil) && (order == ORDER_ORDERED) {
+		this.markOrdered()
+	}
+
+	this.mutex.Unlock()
+	this.snapshotLock.Unlock()
+	return numSkipped, errResult
+}
+
+/*
+ * Returns the revision of the database.
+ *
+ * The revision changes whenever existing entries may have been reordered or
+ * removed, but not when entries are appended. It is initialized from the
+ * current time when the database is opened, so it also changes when the
+ * database is re-opened. Zero is never a valid revision.
+ */
+func (this *databaseStruct) Revision() uint64 {
+	this.mutex.RLock()
+	result := this.revision
+	this.mutex.RUnlock()
+	return result
+}
+
+/*
+ * Returns the index of the first entry with a time stamp of at least
+ * timestampMs, or the number of entries if there is no such entry.
+ *
+ * The entry is found using binary search, so the database must be ordered by
+ * time stamp. Otherwise, an error of category ErrUnordered is returned and
+ * the database has to be sorted first.
+ *
+ * This temporarily locks the database for write access.
+ */
+func (this *databaseStruct) SeekTimestamp(timestampMs uint64) (uint32, error) {
+	lower := uint32(0)
+	errResult := error(nil)
+	this.mutex.Lock()
+	fd := this.fd
+
+	/*
+	 * Verify that database is not closed.
+	 */
+	if fd == nil {
+		errResult = createError(ErrClosed, "%s", "Database is closed.")
+	} else {
+
+		/*
+		 * Determine order if it is not known.
+		 */
+		if this.order == ORDER_UNKNOWN {
+			errResult = this.determineOrder()
+		}
+
+		order := this.order
+
+		/*
+		 * Binary search requires an ordered database.
+		 */
+		if errResult != nil {
+			msg := errResult.Error()
+			errResult = fmt.Errorf("Error determining order of database: %s", msg)
+		} else if order != ORDER_ORDERED {
+			errResult = createError(ErrUnordered, "%s", "Database is not ordered by time stamp. Sort it first.")
+		} else {
+			lower, errResult = this.searchTimestamp(timestampMs)
+		}
+
+	}
+
+	this.mutex.Unlock()
+	return lower, errResult
+}
+
+/*
+ * Takes a snapshot of the database and provides a ReadSeekCloser
+ * granting random access to the database in binary format.
+ *
+ * Closing the returned ReadSeekCloser releases the snapshot.
+ */
+func (this *databaseStruct) SerializeBinary() io.ReadSeekCloser {
+	locationCount := this.snapshot()
+
+	/*
+	 * Create database binary serializer.
+	 */
+	s := databaseBinarySerializerStruct{
+		db:            this,
+		locationCount: locationCount,
+	}
+
+	return &s
+}
+
+/*
+ * Takes a snapshot of the database and provides a ReadSeekCloser
+ * granting random access to the entries appended to the database since a
+ * client last saw it.
+ *
+ * If the revision matches the current revision of the database and it holds
+ * at least locationCount entries, only the entries following the first
+ * locationCount entries are provided, without the database header.
+ * Otherwise, the entire database is provided in binary format, including the
+ * header.
+ *
+ * Also returns the current revision of the database and whether only the
+ * appended entries are provided.
+ *
+ * Closing the returned ReadSeekCloser releases the snapshot.
+ */
+func (this *databaseStruct) SerializeBinaryIncremental(revision uint64, locationCount uint32) (io.ReadSeekCloser, uint64, bool) {
+	currentLocationCount := this.snapshot()
+	currentRevision := this.Revision()
+	incremental := (revision == currentRevision) && (locationCount <= currentLocationCount)
+	begin := uint64(0)
+
+	/*
+	 * Skip header and entries known to the client.
+	 */
+	if incremental {
+		locationCount64 := uint64(locationCount)
+		entrySize := this.entrySize
+		begin = SIZE_DATABASE_HEADER + (entrySize * locationCount64)
+	}
+
+	/*
+	 * Create database binary serializer.
+	 */
+	s := databaseBinarySerializerStruct{
+		begin:         begin,
+		db:            this,
+		locationCount: currentLocationCount,
+	}
+
+	return &s, currentRevision, incremental
+}
+
+/*
+ * Takes a snapshot of the database and provides a ReadCloser granting
+ * sequential access to the database in CSV format.
+ *
+ * CSV data will be generated on-the-fly while reading from the provided
+ * ReadCloser.
+ *
+ * Timestamps are formatted in the provided time zone. If location is nil,
+ * timestamps are formatted in UTC.
+ *
+ * If stride is greater than one, only every stride-th entry is serialized.
+ *
+ * If transform is not nil, it is applied to each location before it is
+ * serialized.
+ *
+ * Closing the returned ReadCloser releases the snapshot.
+ */
+func (this *databaseStruct) SerializeCSV(location *time.Location, stride uint32, transform LocationTransform) io.ReadCloser {
+	s := this.SerializeCSVRange(0, math.MaxUint32, location, stride, transform)
+	return s
+}
+
+/*
+ * Like SerializeCSV, but only serializes the entries with an index of at
+ * least begin, but less than end.
+ *
+ * Closing the returned ReadCloser releases the snapshot.
+ */
+func (this *databaseStruct) SerializeCSVRange(begin uint32, end uint32, location *time.Location, stride uint32, transform LocationTransform) io.ReadCloser {
+	locationCount := this.snapshot()
+	buf := &strings.Builder{}
+	w := csv.NewWriter(buf)
+
+	/*
+	 * Default to UTC.
+	 */
+	if location == nil {
+		location = time.UTC
+	}
+
+	/*
+	 * Create database CSV serializer.
+	 */
+	s := databaseCsvSerializerStruct{
+		csvWriter:     w,
+		db:            this,
+		end:           end,
+		entryId:       begin,
+		lineBuffer:    buf,
+		location:      location,
+		locationCount: locationCount,
+		stride:        stride,
+		transform:     transform,
+	}
+
+	return &s
+}
+
+/*
+ * Takes a snapshot of the database and provides a ReadCloser granting
+ * sequential access to the database as a GeoJSON feature collection.
+ *
+ * The feature collection consists of a single feature with a
+ * "MultiLineString" geometry. Its "coordTimes" property holds the time stamp
+ * of each coordinate, using the same nesting as the coordinates.
+ *
+ * GeoJSON data will be generated on-the-fly while reading from the provided
+ * ReadCloser.
+ *
+ * - When pretty == true, data will be pretty-printed for human consumption.
+ * - When pretty == false, data will be compact for machine consumption.
+ *
+ * If gap is positive, a new line string is started whenever two consecutive
+ * locations are more than gap apart in time.
+ *
+ * If stride is greater than one, only every stride-th entry is serialized.
+ *
+ * If transform is not nil, it is applied to each location before it is
+ * serialized. Locations omitted by the transform end the current line string.
+ *
+ * Closing the returned ReadCloser releases the snapshot.
+ */
+func (this *databaseStruct) SerializeGeoJSON(pretty bool, gap time.Duration, stride uint32, transform LocationTransform) io.ReadCloser {
+	locationCount := this.snapshot()
+	buf := &strings.Builder{}
+	gapMilliseconds := gap.Milliseconds()
+	gapUnsigned := uint64(0)
+
+	/*
+	 * Negative gaps disable splitting.
+	 */
+	if gapMilliseconds > 0 {
+		gapUnsigned = uint64(gapMilliseconds)
+	}
+
+	/*
+	 * Create database GeoJSON serializer.
+	 */
+	s := databaseGeoJsonSerializerStruct{
+		buffer:        buf,
+		db:            this,
+		gap:           gapUnsigned,
+		locationCount: locationCount,
+		pretty:        pretty,
+		state:         GEOJSON_STREAM_HEADER,
+		stride:        stride,
+		transform:     transform,
+	}
+
+	return &s
+}
+
+/*
+ * Takes a snapshot of the database and provides a ReadCloser granting
+ * sequential access to the database in JSON format.
+ *
+ * JSON data will be generated on-the-fly while reading from the provided
+ * ReadCloser.
+ *
+ * - When pretty == true, data will be pretty-printed for human consumption.
+ * - When pretty == false, data will be compact for machine consumption.
+ *
+ * - When degrees == true, coordinates will be emitted as decimal degrees in
+ *   "latitude" and "longitude" fields.
+ * - When degrees == false, coordinates will be emitted as integers in
+ *   "latitudeE7" and "longitudeE7" fields.
+ *
+ * If stride is greater than one, only every stride-th entry is serialized.
+ *
+ * If transform is not nil, it is applied to each location before it is
+ * serialized.
+ *
+ * Closing the returned ReadCloser releases the snapshot.
+ */
+func (this *databaseStruct) SerializeJSON(pretty bool, degrees bool, stride uint32, transform LocationTransform) io.ReadCloser {
+	locationCount := this.snapshot()
+	buf := &strings.Builder{}
+
+	/*
+	 * Create database JSON serializer.
+	 */
+	s := databaseJsonSerializerStruct{
+		buffer:        buf,
+		db:            this,
+		degrees:       degrees,
+		locationCount: locationCount,
+		pretty:        pretty,
+		state:         JSON_STREAM_HEADER,
+		stride:        stride,
+		transform:     transform,
+	}
+
+	return &s
+}
+
+/*
+ * Takes a snapshot of the database and provides a ReadCloser granting
+ * sequential access to the database in KML format.
+ *
+ * The document consists of a single placemark holding a track (gx:Track).
+ * As KML requires, the track lists the time stamps of all locations first,
+ * followed by their coordinates in the same order. Time stamps are formatted
+ * according to RFC 3339 in UTC.
+ *
+ * KML data will be generated on-the-fly while reading from the provided
+ * ReadCloser.
+ *
+ * - When pretty == true, data will be pretty-printed for human consumption.
+ * - When pretty == false, data will be compact for machine consumption.
+ *
+ * If stride is greater than one, only every stride-th entry is serialized.
+ *
+ * If transform is not nil, it is applied to each location before it is
+ * serialized.
+ *
+ * Closing the returned ReadCloser releases the snapshot.
+ */
+func (this *databaseStruct) SerializeKML(pretty bool, stride uint32, transform LocationTransform) io.ReadCloser {
+	locationCount := this.snapshot()
+	buf := &strings.Builder{}
+
+	/*
+	 * Create database KML serializer.
+	 */
+	s := databaseKmlSerializerStruct{
+		buffer:        buf,
+		db:            this,
+		locationCount: locationCount,
+		pretty:        pretty,
+		state:         KML_STREAM_HEADER,
+		stride:        stride,
+		transform:     transform,
+	}
+
+	return &s
+}
+
+/*
+ * Takes a snapshot of the database and provides a ReadCloser granting
+ * sequential access to the database in XML format.
+ *
+ * XML data will be generated on-the-fly while reading from the provided
+ * ReadCloser.
+ *
+ * - When pretty == true, data will be pretty-printed for human consumption.
+ * - When pretty == false, data will be compact for machine consumption.
+ *
+ * Timestamps are formatted in the provided time zone. If location is nil,
+ * timestamps are formatted in UTC.
+ *
+ * If stride is greater than one, only every stride-th entry is serialized.
+ *
+ * If transform is not nil, it is applied to each location before it is
+ * serialized.
+ *
+ * Closing the returned ReadCloser releases the snapshot.
+ */
+func (this *databaseStruct) SerializeXML(pretty bool, location *time.Location, stride uint32, transform LocationTransform) io.ReadCloser {
+	s := this.SerializeXMLRange(0, math.MaxUint32, pretty, location, stride, transform)
+	return s
+}
+
+/*
+ * Like SerializeXML, but only serializes the entries with an index of at
+ * least begin, but less than end.
+ *
+ * Closing the returned ReadCloser releases the snapshot.
+ */
+func (this *databaseStruct) SerializeXMLRange(begin uint32, end uint32, pretty bool, location *time.Location, stride uint32, transform LocationTransform) io.ReadCloser {
+	locationCount := this.snapshot()
+	buf := &strings.Builder{}
+
+	/*
+	 * Default to UTC.
+	 */
+	if location == nil {
+		location = time.UTC
+	}
+
+	/*
+	 * Create database XML serializer.
+	 */
+	s := databaseXmlSerializerStruct{
+		buffer:        buf,
+		db:            this,
+		end:           end,
+		entryId:       begin,
+		location:      location,
+		locationCount: locationCount,
+		pretty:        pretty,
+		state:         XML_STREAM_HEADER,
+		stride:        stride,
+		transform:     transform,
+	}
+
+	return &s
+}
+
+/*
+ * Sets the number of decimal places to which the coordinates of appended
+ * locations are rounded.
+ *
+ * The database always stores coordinates with seven decimal places (about
+ * one centimeter), which is also the default precision. Lower precisions only
+ * affect the values stored, e.g. five decimal places round coordinates to
+ * about one meter. This makes nearby locations identical, so that more of
+ * them are removed by Deduplicate. Locations already stored are left as they
+ * are.
+ *
+ * Returns an error of category ErrOutOfRange if digits exceeds the precision
+ * of the database.
+ *
+ * This temporarily locks the database for write access.
+ */
+func (this *databaseStruct) SetPrecision(digits uint8) error {
+
+	/*
+	 * Make sure that precision can be represented.
+	 */
+	if digits > PRECISION_MAX {
+		return createError(ErrOutOfRange, "Precision must not exceed %d decimal places, but was %d.", PRECISION_MAX, digits)
+	} else {
+		quantum := int64(1)
+
+		/*
+		 * Each decimal place less makes the quantum ten times larger.
+		 */
+		for i := digits; i < PRECISION_MAX; i++ {
+			quantum *= 10
+		}
+
+		this.mutex.Lock()
+		this.quantum = quantum
+		this.mutex.Unlock()
+		return nil
+	}
+
+}
+
+/*
+ * Sorts entries in the database by (ascending) time stamp using a stable
+ * sorting algorithm.
+ *
+ * If the database is closed, this is a no-op.
+ *
+ * Cancelling the context aborts sorting, leaving the database only partially
+ * sorted.
+ *
+ * This temporarily locks the database for write access.
+ */
+func (this *databaseStruct) Sort(ctx context.Context) error {
+	result := error(nil)
+	this.snapshotLock.Lock()
+	this.mutex.Lock()
+	fd := this.fd
+
+	/*
+	 * Only sort database if it is still open.
+	 */
+	if fd != nil {
+		this.revision++
+		result = this.sort(ctx)
+
+		/*
+		 * Check if sorting was successful.
+		 */
+		if result != nil {
+			this.order = ORDER_UNKNOWN
+		} else {
+			this.markOrdered()
+		}
+
+	}
+
+	this.mutex.Unlock()
+	this.snapshotLock.Unlock()
+	return result
+}
+
+/*
+ * Thins out the database by keeping only every keepEveryN-th entry, starting
+ * with the first one, and removing all others.
+ *
+ * Returns the number of removed entries.
+ *
+ * The order of the remaining entries is preserved. Returns an error of
+ * category ErrOutOfRange if keepEveryN is zero.
+ *
+ * This temporarily locks the database for write access.
+ */
+func (this *databaseStruct) Thin(keepEveryN uint32) (uint32, error) {
+	result := uint32(0)
+	errResult := error(nil)
+	this.snapshotLock.Lock()
+	this.mutex.Lock()
+	fd := this.fd
+
+	/*
+	 * Verify that database is not closed and the interval is valid.
+	 */
+	if fd == nil {
+		errResult = createError(ErrClosed, "%s", "Database is closed.")
+	} else if keepEveryN == 0 {
+		errResult = createError(ErrOutOfRange, "%s", "Interval of entries to keep must be positive.")
+	} else if keepEveryN > 1 {
+		this.revision++
+		order := this.order
+		numEntries := this.locationCount
+		entrySize := this.entrySize
+		entrySize64 := int64(entrySize)
+		entrySizeInt := int(entrySize)
+		bufCurrentEntry := make([]byte, entrySize)
+		numEntries64 := uint64(numEntries)
+		keepEveryN64 := uint64(keepEveryN)
+		writeIdx := uint32(0)
+
+		/*
+		 * Move every entry that is kept to its new position.
+		 *
+		 * The read index is 64 bits wide, so that it cannot overflow.
+		 */
+		for readIdx := uint64(0); (errResult == nil) && (readIdx < numEntries64); readIdx += keepEveryN64 {
+			readIdx64 := int64(readIdx)
+			offsetRead := SIZE_DATABASE_HEADER + (entrySize64 * readIdx64)
+			n, err := fd.ReadAt(bufCurrentEntry, offsetRead)
+
+			/*
+			 * Check for errors.
+			 */
+			if err != nil {
+				msg := err.Error()
+				errResult = fmt.Errorf("Error reading from offset %016x (%d): %s", offsetRead, offsetRead, msg)
+			} else if n != entrySizeInt {
+				errResult = fmt.Errorf("Expected %d bytes reading from offset 0x%016x (%d), but got %d.", entrySizeInt, offsetRead, offsetRead, n)
+			} else {
+				writeIdx64 := int64(writeIdx)
+				offsetWrite := SIZE_DATABASE_HEADER + (entrySize64 * writeIdx64)
+				n, err := fd.WriteAt(bufCurrentEntry, offsetWrite)
+
+				/*
+				 * Check if write error occured or write was not of
+				 * expected size.
+				 */
+				if err != nil {
+					msg := err.Error()
+					errResult = fmt.Errorf("Error writing to offset %016x (%d): %s", offsetWrite, offsetWrite, msg)
+				} else if n != entrySizeInt {
+					errResult = fmt.Errorf("Expected %d bytes writing to offset 0x%016x (%d), but got %d.", entrySizeInt, offsetWrite, offsetWrite, n)
+				} else {
+					writeIdx++
+				}
+
+			}
+
+		}
+
+		/*
+		 * Truncate the file if entries were removed.
+		 */
+		if (errResult == nil) && (writeIdx < numEntries) {
+			writeIdx64 := int64(writeIdx)
+			fileSize := SIZE_DATABASE_HEADER + (entrySize64 * writeIdx64)
+			err := fd.Truncate(fileSize)
+
+			/*
+			 * Check if error occured during truncation.
+			 */
+			if err != nil {
+				msg := err.Error()
+				errResult = fmt.Errorf("Failed to truncate file to size 0x%016x (%d): %s", fileSize, fileSize, msg)
+			} else {
+				result = numEntries - writeIdx
+				this.locationCount = writeIdx
+			}
+
+		}
+
+		/*
+		 * Thinning preserves the order of an ordered database, unless it
+		 * failed. Thinning an unordered database may order it.
+		 */
+		if (errResult != nil) || (order != ORDER_ORDERED) {
+			this.order = ORDER_UNKNOWN
+		} else {
+			this.markOrdered()
+		}
+
+	}
+
+	this.mutex.Unlock()
+	this.snapshotLock.Unlock()
+	return result, errResult
+}
+
+/*
+ * Implements the Read function from io.ReadSeekCloser.
+ */
+func (this *databaseBinarySerializerStruct) Read(buf []byte) (int, error) {
+	result := int(0)
+	errResult := error(nil)
+	this.mutex.Lock()
+	db := this.db
+
+	/*
+	 * Check if serializer is still open.
+	 */
+	if db == nil {
+		errResult = createError(ErrClosed, "%s", "Database serializer is already closed.")
+	} else {
+		fd := db.fd
+
+		/*
+		 * Check if file descriptor is still open.
+		 */
+		if fd == nil {
+			errResult = createError(ErrClosed, "%s", "Database is already closed.")
+		} else {
+			locationCount := this.locationCount
+			locationCount64 := uint64(locationCount)
+			begin := this.begin
+			entrySize := db.entrySize
+			size := SIZE_DATABASE_HEADER + (entrySize * locationCount64) - begin
+			offset := this.offset
+			bytesInFile := size - offset
+			bufSize := len(buf)
+			bytesToRead := uint64(bufSize)
+
+			/*
+			 * Limit bytes to read to file size.
+			 */
+			if bytesToRead > bytesInFile {
+				bytesToRead = bytesInFile
+			}
+
+			bufTarget := buf[0:bytesToRead]
+			offsetSigned := int64(begin + offset)
+
+			/*
+			 * Prevent overflow.
+			 */
+			if offsetSigned < 0 {
+				errResult = createError(ErrOutOfRange, "%s", "Overflow.")
+			} else {
+				bytesRead, err := fd.ReadAt(bufTarget, offsetSigned)
+				bytesRead64 := uint64(bytesRead)
+
+				/*
+				 * Prevent out of bounds errors and implausible results.
+				 */
+				if bytesRead < 0 {
+					bytesRead = 0
+					bytesRead64 = uint64(bytesRead)
+				} else if bytesRead64 > bytesToRead {
+					bytesRead = int(bytesToRead)
+					bytesRead64 = bytesToRead
+				}
+
+				/*
+				 * Handle I/O errors.
+				 */
+				if err == io.EOF {
+
+					/*
+					 * Check if we read as many bytes as expected.
+					 */
+					if bytesRead64 < bytesToRead {
+						errResult = io.ErrUnexpectedEOF
+					}
+
+				} else if err != nil {
+					msg := err.Error()
+					errResult = fmt.Errorf("I/O error: %s", msg)
+					bytesRead = 0
+					bytesRead64 = 0
+				}
+
+				bufToZero := buf[bytesRead:bufSize]
+
+				/*
+				 * Zero out remaining part of the buffer.
+				 */
+				for i := range bufToZero {
+					bufToZero[i] = 0
+				}
+
+				offset += bytesRead64
+				result = bytesRead
+			}
+
+			this.offset = offset
+		}
+
+	}
+
+	this.mutex.Unlock()
+	return result, errResult
+}
+
+/*
+ * Implements the Seek function from io.ReadSeekCloser.
+ */
+func (this *databaseBinarySerializerStruct) Seek(offset int64, whence int) (int64, error) {
+	result := int64(0)
+	errResult := error(nil)
+	this.mutex.Lock()
+	db := this.db
+
+	/*
+	 * Check if serializer is still open.
+	 */
+	if db == nil {
+		errResult = createError(ErrClosed, "%s", "Database serializer is already closed.")
+	} else {
+		fd := db.fd
+
+		/*
+		 * Check if file descriptor is still open.
+		 */
+		if fd == nil {
+			errResult = createError(ErrClosed, "%s", "Database is already closed.")
+		} else {
+			locationCount := this.locationCount
+			locationCount64 := uint64(locationCount)
+			begin := this.begin
+			entrySize := db.entrySize
+			size := SIZE_DATABASE_HEADER + (entrySize * locationCount64) - begin
+			offset64 := uint64(offset)
+			offsetCurrent := this.offset
+
+			/*
+			 * Decide relative to what to seek.
+			 */
+			switch whence {
+			case io.SeekStart:
+
+				/*
+				 * Check if absolute offset is negative.
+				 */
+				if offset < 0 {
+					errResult = createError(ErrOutOfRange, "%s", "Cannot seek to negative absolute offset.")
+				} else {
+					offsetCurrent = offset64
+					result = int64(offsetCurrent)
+				}
+
+			case io.SeekCurrent:
+				offsetNew := offsetCurrent + offset64
+
+				/*
+				 * Prevent numeric overflow.
+				 */
+				if ((offset > 0) && (offsetNew <= offsetCurrent)) || ((offset < 0) && (offsetNew >= offsetCurrent)) {
+					errResult = createError(ErrOutOfRange, "%s", "Overflow or negative target offset.")
+				} else {
+					offsetCurrent = offsetNew
+					result = int64(offsetCurrent)
+				}
+
+			case io.SeekEnd:
+				offsetNew := size + offset64
+
+				/*
+				 * Prevent numeric overflow.
+				 */
+				if ((offset > 0) && (offsetNew <= size)) || ((offset < 0) && (offsetNew >= size)) {
+					errResult = createError(ErrOutOfRange, "%s", "Overflow or negative target offset.")
+				} else {
+					offsetCurrent = offsetNew
+					result = int64(offsetCurrent)
+				}
+
+			default:
+				errResult = fmt.Errorf("Seek: Invalid value for 'whence': %d", whence)
+			}
+
+			this.offset = offsetCurrent
+		}
+
+	}
+
+	this.mutex.Unlock()
+	return result, errResult
+}
+
+/*
+ * Implements the Close function from io.ReadSeekCloser.
+ *
+ * This will yield the read lock on the underlying database.
+ */
+func (this *databaseBinarySerializerStruct) Close() error {
+	result := error(nil)
+	this.mutex.Lock()
+	db := this.db
+
+	/*
+	 * Check if serializer is already closed.
+	 */
+	if db == nil {
+		result = createError(ErrClosed, "%s", "Database serializer is already closed.")
+	} else {
+		db.snapshotLock.RUnlock()
+		this.db = nil
+	}
+
+	this.mutex.Unlock()
+	return result
+}
+
+/*
+ * Applies the stride and the transform of the serializer to a location.
+ *
+ * Returns whether the location shall be serialized.
+ */
+func (this *databaseCsvSerializerStruct) applyTransform(entryId uint32, loc *Location) bool {
+	stride := this.stride
+	transform := this.transform
+	result := true
+
+	/*
+	 * Skip entries between strides and only apply transform if there is
+	 * one.
+	 */
+	if (stride > 1) && ((entryId % stride) != 0) {
+		result = false
+	} else if transform != nil {
+		result = transform(loc)
+	}
+
+	return result
+}
+
+/*
+ * Format timestamp as string value in the time zone of the serializer.
+ */
+func (this *databaseCsvSerializerStruct) formatTimestamp(timestamp uint64) string {
+	timestampSigned := int64(timestamp)
+	t := time.UnixMilli(timestampSigned)
+	location := this.location
+	localTime := t.In(location)
+	result := localTime.Format(time.RFC3339Nano)
+	return result
+}
+
+/*
+ * Format latitude as string value.
+ */
+func (this *databaseCsvSerializerStruct) formatLatitude(latitudeE7 int32) string {
+	result := "<INVALID>"
+	buf := fmt.Sprintf("%+09d", latitudeE7)
+	bufSize := len(buf)
+
+	/*
+	 * Check that buffer has sufficient size.
+	 */
+	if bufSize >= 9 {
+		sign := buf[0]
+		direction := '?'
+
+		/*
+		 * Check sign of number.
+		 */
+		switch sign {
+		case byte('+'):
+			direction = 'N'
+		case byte('-'):
+			direction = 'S'
+		}
+
+		posDecimalPoint := bufSize - 7
+		leftOfPoint := buf[1:posDecimalPoint]
+		rightOfPoint := buf[posDecimalPoint:bufSize]
+		outputSize := bufSize + 1
+		builder := strings.Builder{}
+		builder.Grow(outputSize)
+		builder.WriteString(leftOfPoint)
+		builder.WriteRune('.')
+		builder.WriteString(rightOfPoint)
+		builder.WriteRune(direction)
+		result = builder.String()
+	}
+
+	return result
+}
+
+/*
+ * Format longitude as string value.
+ */
+func (this *databaseCsvSerializerStruct) formatLongitude(longitudeE7 int32) string {
+	result := "<INVALID>"
+	buf := fmt.Sprintf("%+09d", longitudeE7)
+	bufSize := len(buf)
+
+	/*
+	 * Check that buffer has sufficient size.
+	 */
+	if bufSize >= 9 {
+		sign := buf[0]
+		direction := '?'
+
+		/*
+		 * Check sign of number.
+		 */
+		switch sign {
+		case byte('+'):
+			direction = 'E'
+		case byte('-'):
+			direction = 'W'
+		}
+
+		posDecimalPoint := bufSize - 7
+		leftOfPoint := buf[1:posDecimalPoint]
+		rightOfPoint := buf[posDecimalPoint:bufSize]
+		outputSize := bufSize + 1
+		builder := strings.Builder{}
+		builder.Grow(outputSize)
+		builder.WriteString(leftOfPoint)
+		builder.WriteRune('.')
+		builder.WriteString(rightOfPoint)
+		builder.WriteRune(direction)
+		result = builder.String()
+	}
+
+	return result
+}
+
+/*
+ * Implements the Read function from io.ReadCloser.
+ */
+func (this *databaseCsvSerializerStruct) Read(buf []byte) (int, error) {
+	numBytesToRead := len(buf)
+	readBytes := int(0)
+	errResult := error(nil)
+
+	/*
+	 * Check if we have to read bytes.
+	 */
+	if numBytesToRead > 0 {
+		this.mutex.Lock()
+		db := this.db
+
+		/*
+		 * Check if serializer is already closed.
+		 */
+		if db == nil {
+			errResult = createError(ErrClosed, "%s", "Database serializer is already closed.")
+		} else {
+			numEntries := this.locationCount
+			end := this.end
+
+			/*
+			 * Only serialize entries up to the end of the range.
+			 */
+			if end < numEntries {
+				numEntries = end
+			}
+
+			entryId := this.entryId
+			csvWriter := this.csvWriter
+			lineBuffer := this.lineBuffer
+			line := lineBuffer.String()
+			lineLength := len(line)
+			lineOffset := this.lineOffset
+			entrySize := db.entrySize
+			bufRead := make([]byte, SIZE_DATABASE_ENTRY)
+			bufEntry := bufRead[0:entrySize]
+
+			/*
+			 * Continue until we reach the end of the file or
+			 * filled the read buffer.
+			 */
+			for ((entryId < numEntries) || ((entryId == numEntries) && (lineLength > 0))) && (readBytes < numBytesToRead) && (errResult == nil) {
+				lineFromOffset := line[lineOffset:]
+				bufOffset := buf[readBytes:]
+				n := copy(bufOffset, lineFromOffset)
+				lineOffset += n
+				readBytes += n
+
+				/*
+				 * If no bytes were copied, we have to update our buffers.
+				 */
+				if n == 0 {
+
+					/*
+					 * If there are no more entries, we have to clear our buffers.
+					 *
+					 * Otherwise, we will generate a new line.
+					 */
+					if entryId >= numEntries {
+						lineBuffer.Reset()
+						line = lineBuffer.String()
+						lineLength = len(line)
+						lineOffset = 0
+					} else {
+						entry := databaseEntryStruct{}
+						fd := db.fd
+						endianness := binary.BigEndian
+						offset := uint64(entryId)
+						offsetBytes := SIZE_DATABASE_HEADER + (entrySize * offset)
+						offsetBytesSigned := int64(offsetBytes)
+						numBytesRead, err := fd.ReadAt(bufEntry, offsetBytesSigned)
+
+						/*
+						 * If we read less bytes than expected,
+						 * zero out part of the buffer.
+						 */
+						if numBytesRead < SIZE_DATABASE_ENTRY {
+							zero := bufRead[numBytesRead:SIZE_DATABASE_ENTRY]
+
+							/*
+							 * Zero the unused part of the buffer.
+							 */
+							for i := range zero {
+								zero[i] = 0
+							}
+
+						}
+
+						/*
+						 * Check for read error.
+						 */
+						if err != nil {
+							errResult = fmt.Errorf("Error reading from offset: 0x%016x", offsetBytes)
+						} else {
+							rd := bytes.NewReader(bufRead)
+							err = binary.Read(rd, endianness, &entry)
+
+							/*
+							 * Check if database entry could be deserialized.
+							 */
+							if err != nil {
+								errResult = createError(ErrCorrupt, "Error deserializing entry at offset: 0x%016x", offsetBytes)
+							} else {
+								timestampMSB := entry.TimestampMSB
+								timestampMSB64 := uint64(timestampMSB)
+								timestampLSB := entry.TimestampLSB
+								timestampLSB64 := uint64(timestampLSB)
+								timestamp := (timestampMSB64 << 32) | timestampLSB64
+
+								/*
+								 * Create location.
+								 */
+								loc := Location{
+									Timestamp:   timestamp,
+									LatitudeE7:  entry.LatitudeE7,
+									LongitudeE7: entry.LongitudeE7,
+									Accuracy:    entry.Accuracy,
+								}
+
+								keep := this.applyTransform(entryId, &loc)
+
+								/*
+								 * Only serialize locations which were not
+								 * omitted by the transform.
+								 */
+								if keep {
+									timestampString := this.formatTimestamp(loc.Timestamp)
+									latitudeString := this.formatLatitude(loc.LatitudeE7)
+									longitudeString := this.formatLongitude(loc.LongitudeE7)
+									accuracyString := fmt.Sprintf("%d", loc.Accuracy)
+
+									/*
+									 * Create record.
+									 */
+									record := []string{
+										timestampString,
+										latitudeString,
+										longitudeString,
+										accuracyString,
+									}
+
+									lineBuffer.Reset()
+									csvWriter.Write(record)
+									csvWriter.Flush()
+									line = lineBuffer.String()
+									lineLength = len(line)
+									lineOffset = 0
+								}
+
+							}
+
+						}
+
+						entryId++
+					}
+
+				}
+
+			}
+
+			/*
+			 * Check for end of file condition.
+			 */
+			if (entryId > numEntries) || ((entryId == numEntries) && (lineLength == 0)) {
+				errResult = io.EOF
+			}
+
+			this.entryId = entryId
+			this.lineOffset = lineOffset
+		}
+
+		this.mutex.Unlock()
+	}
+
+	return readBytes, errResult
+}
+
+/*
+ * Implements the Close function from io.ReadCloser.
+ *
+ * This will yield the read lock on the underlying database.
+ */
+func (this *databaseCsvSerializerStruct) Close() error {
+	result := error(nil)
+	this.mutex.Lock()
+	db := this.db
+
+	/*
+	 * Check if serializer is already closed.
+	 */
+	if db == nil {
+		result = createError(ErrClosed, "%s", "Database serializer is already closed.")
+	} else {
+		db.snapshotLock.RUnlock()
+		this.db = nil
+	}
+
+	this.mutex.Unlock()
+	return result
+}
+
+/*
+ * Begin a JSON list.
+ */
+func (this *databaseJsonSerializerStruct) beginList() {
+	buffer := this.buffer
+	buffer.WriteRune('[')
+	this.startLine(JSON_INDENT_IN)
+}
+
+/*
+ * Begin a JSON object.
+ */
+func (this *databaseJsonSerializerStruct) beginObject() {
+	buffer := this.buffer
+	buffer.WriteRune('{')
+	this.startLine(JSON_INDENT_IN)
+}
+
+/*
+ * Change the indentation depth.
+ */
+func (this *databaseJsonSerializerStruct) changeIndent(direction int) {
+	indent := this.indent
+
+	/*
+	 * Decide on the indentation direction.
+	 */
+	switch direction {
+	case JSON_INDENT_IN:
+
+		/*
+		 * Increase indent, preventing overflow.
+		 */
+		if indent < math.MaxUint16 {
+			indent++
+		}
+
+	case JSON_INDENT_OUT:
+
+		/*
+		 * Decrease indent, preventing underflow.
+		 */
+		if indent > 0 {
+			indent--
+		}
+
+	default:
+		// Do nothing.
+	}
+
+	this.indent = indent
+}
+
+/*
+ * End a JSON list.
+ */
+func (this *databaseJsonSerializerStruct) endList() {
+	this.startLine(JSON_INDENT_OUT)
+	buffer := this.buffer
+	buffer.WriteRune(']')
+}
+
+/*
+ * End a JSON object.
+ */
+func (this *databaseJsonSerializerStruct) endObject() {
+	this.startLine(JSON_INDENT_OUT)
+	buffer := this.buffer
+	buffer.WriteRune('}')
+}
+
+/*
+ * Applies the stride and the transform of the serializer to a location.
+ *
+ * Returns whether the location shall be serialized.
+ */
+func (this *databaseJsonSerializerStruct) applyTransform(entryId uint32, loc *Location) bool {
+	stride := this.stride
+	transform := this.transform
+	result := true
+
+	/*
+	 * Skip entries between strides and only apply transform if there is
+	 * one.
+	 */
+	if (stride > 1) && ((entryId % stride) != 0) {
+		result = false
+	} else if transform != nil {
+		result = transform(loc)
+	}
+
+	return result
+}
+
+/*
+ * Format fixed-point value with E7 exponent as string.
+ */
+func (this *databaseJsonSerializerStruct) formatFixedE7(valueE7 int32) string {
+	result := "<INVALID>"
+	buf := fmt.Sprintf("%+09d", valueE7)
+	bufSize := len(buf)
+
+	/*
+	 * Check that buffer has sufficient size.
+	 */
+	if bufSize >= 9 {
+		sign := buf[0]
+		negative := sign == byte('-')
+		posDecimalPoint := bufSize - 7
+		leftOfPoint := buf[1:posDecimalPoint]
+		rightOfPoint := buf[posDecimalPoint:bufSize]
+		outputSize := bufSize + 1
+
+		/*
+		 * Negative number needs one byte more for the sign.
+		 */
+		if negative {
+			outputSize++
+		}
+
+		builder := strings.Builder{}
+		builder.Grow(outputSize)
+
+		/*
+		 * If number is negative, start with unary minus.
+		 */
+		if negative {
+			builder.WriteRune('-')
+		}
+
+		builder.WriteString(leftOfPoint)
+		builder.WriteRune('.')
+		builder.WriteString(rightOfPoint)
+		result = builder.String()
+	}
+
+	return result
+}
+
+/*
+ * Format timestamp as string value.
+ */
+func (this *databaseJsonSerializerStruct) formatTimestamp(timestamp uint64) string {
+	timestampSigned := int64(timestamp)
+	t := time.UnixMilli(timestampSigned)
+	utcTime := t.UTC()
+	result := utcTime.Format(time.RFC3339Nano)
+	return result
+}
+
+/*
+ * Generate more JSON data.
+ */
+func (this *databaseJsonSerializerStruct) generateJSON() error {
+	state := this.state
+	errResult := error(nil)
+
+	switch state {
+	case JSON_STREAM_HEADER:
+		this.beginObject()
+		this.generateJSONForObjectKey("locations")
+		this.beginList()
+		state = JSON_STREAM_ENTRIES
+	case JSON_STREAM_ENTRIES:
+		err := this.generateJSONForNextEntry()
+
+		/*
+		 * Check for errors during serialization.
+		 */
+		if err != nil {
+			msg := err.Error()
+			errResult = fmt.Errorf("Error generating entry: %s", msg)
+			state = JSON_STREAM_ERROR
+		} else {
+			moreAvailable := this.hasMoreEntries()
+
+			/*
+			 * If there are no more entries to be serialized,
+			 * transition to serializing the trailer.
+			 */
+			if !moreAvailable {
+				state = JSON_STREAM_TRAILER
+			}
+
+		}
+
+	case JSON_STREAM_TRAILER:
+		this.endList()
+		this.endObject()
+		state = JSON_STREAM_EOF
+	case JSON_STREAM_EOF:
+		errResult = io.EOF
+	default:
+		errResult = fmt.Errorf("%s", "Error during JSON serialization.")
+	}
+
+	this.state = state
+	return errResult
+}
+
+/*
+ * Generate JSON data for a key-value-pair.
+ */
+func (this *databaseJsonSerializerStruct) generateJSONForKeyValuePair(key string, value string, valueAsStringLiteral bool) {
+	buffer := this.buffer
+	this.generateJSONForObjectKey(key)
+	valueLiteral := value
+
+	/*
+	 * Optionally, encode value as string literal.
+	 */
+	if valueAsStringLiteral {
+		valueLiteral = this.toStringLiteral(value)
+	}
+
+	buffer.WriteString(valueLiteral)
+}
+
+/*
+ * Generate JSON data for object key.
+ */
+func (this *databaseJsonSerializerStruct) generateJSONForObjectKey(key string) {
+	pretty := this.pretty
+	buffer := this.buffer
+	keyLiteral := this.toStringLiteral(key)
+	buffer.WriteString(keyLiteral)
+	buffer.WriteRune(':')
+
+	/*
+	 * When pretty-printing, emit space after object key.
+	 */
+	if pretty {
+		buffer.WriteRune(' ')
+	}
+
+}
+
+/*
+ * Generate JSON data for next entry in geographical database.
+ */
+func (this *databaseJsonSerializerStruct) generateJSONForNextEntry() error {
+	errResult := error(nil)
+	moreAvailable := this.hasMoreEntries()
+
+	/*
+	 * Check if more entries are available.
+	 */
+	if moreAvailable {
+		db := this.db
+		entryId := this.entryId
+		entry := databaseEntryStruct{}
+		fd := db.fd
+		endianness := binary.BigEndian
+		offset := uint64(entryId)
+		entrySize := db.entrySize
+		offsetBytes := SIZE_DATABASE_HEADER + (entrySize * offset)
+		offsetBytesSigned := int64(offsetBytes)
+		bufRead := make([]byte, SIZE_DATABASE_ENTRY)
+		bufEntry := bufRead[0:entrySize]
+		numBytesRead, err := fd.ReadAt(bufEntry, offsetBytesSigned)
+
+		/*
+		 * If we read less bytes than expected, zero out part of the
+		 * buffer.
+		 */
+		if numBytesRead < SIZE_DATABASE_ENTRY {
+			zero := bufRead[numBytesRead:SIZE_DATABASE_ENTRY]
+
+			/*
+			 * Zero the unused part of the buffer.
+			 */
+			for i := range zero {
+				zero[i] = 0
+			}
+
+		}
+
+		/*
+		 * Check for read error.
+		 */
+		if err != nil {
+			errResult = fmt.Errorf("Error reading from offset: 0x%016x", offsetBytes)
+		} else {
+			rd := bytes.NewReader(bufRead)
+			err = binary.Read(rd, endianness, &entry)
+
+			/*
+			 * Check if database entry could be deserialized.
+			 */
+			if err != nil {
+				errResult = createError(ErrCorrupt, "Error deserializing entry at offset: 0x%016x", offsetBytes)
+			} else {
+				timestampMSB := entry.TimestampMSB
+				timestampMSB64 := uint64(timestampMSB)
+				timestampLSB := entry.TimestampLSB
+				timestampLSB64 := uint64(timestampLSB)
+				timestamp := (timestampMSB64 << 32) | timestampLSB64
+
+				/*
+				 * Create location.
+				 */
+				loc := Location{
+					Timestamp:   timestamp,
+					LatitudeE7:  entry.LatitudeE7,
+					LongitudeE7: entry.LongitudeE7,
+					Accuracy:    entry.Accuracy,
+				}
+
+				keep := this.applyTransform(entryId, &loc)
+
+				/*
+				 * Only serialize locations which were not omitted by
+				 * the transform.
+				 */
+				if keep {
+					entriesWritten := this.entriesWritten
+
+					/*
+					 * Separate entry from the previous one.
+					 */
+					if entriesWritten > 0 {
+						this.nextItem()
+					}
+
+					timestampString := this.formatTimestamp(loc.Timestamp)
+					timestampMsString := fmt.Sprintf("%d", loc.Timestamp)
+					degrees := this.degrees
+					latitudeKey := "latitudeE7"
+					latitudeString := fmt.Sprintf("%d", loc.LatitudeE7)
+					longitudeKey := "longitudeE7"
+					longitudeString := fmt.Sprintf("%d", loc.LongitudeE7)
+					accuracyString := fmt.Sprintf("%d", loc.Accuracy)
+
+					/*
+					 * Emit coordinates in decimal degrees if requested.
+					 */
+					if degrees {
+						latitudeKey = "latitude"
+						latitudeString = this.formatFixedE7(loc.LatitudeE7)
+						longitudeKey = "longitude"
+						longitudeString = this.formatFixedE7(loc.LongitudeE7)
+					}
+
+					this.beginObject()
+					this.generateJSONForKeyValuePair("timestamp", timestampString, true)
+					this.nextItem()
+					this.generateJSONForKeyValuePair("timestampMs", timestampMsString, true)
+					this.nextItem()
+					this.generateJSONForKeyValuePair(latitudeKey, latitudeString, false)
+					this.nextItem()
+					this.generateJSONForKeyValuePair(longitudeKey, longitudeString, false)
+					this.nextItem()
+					this.generateJSONForKeyValuePair("accuracy", accuracyString, false)
+					this.endObject()
+					this.entriesWritten = entriesWritten + 1
+				}
+
+			}
+		}
+
+		entryId++
+		this.entryId = entryId
+	}
+
+	return errResult
+}
+
+/*
+ * Returns whether there are more entries in the database to be serialized.
+ */
+func (this *databaseJsonSerializerStruct) hasMoreEntries() bool {
+	entryId := this.entryId
+	locationCount := this.locationCount
+	result := entryId < locationCount
+	return result
+}
+
+/*
+ * Returns whether this byte is an ASCII control character.
+ */
+func (this *databaseJsonSerializerStruct) isControlCharacter(value rune) bool {
+	result := (value < 0x20) || (value == 0x7f)
+	return result
+}
+
+/*
+ * Starts a new item, either in a list or an object.
+ */
+func (this *databaseJsonSerializerStruct) nextItem() {
+	buffer := this.buffer
+	buffer.WriteRune(',')
+	pretty := this.pretty
+
+	/*
+	 * For pretty-printing, start new line for each item.
+	 */
+	if pretty {
+		this.startLine(JSON_INDENT_NONE)
+	}
+
+}
+
+/*
+ * Begins a new line, including indentation.
+ */
+func (this *databaseJsonSerializerStruct) startLine(indentationDirection int) {
+	pretty := this.pretty
+
+	/*
+	 * Only do this when pretty-printing JSON.
+	 */
+	if pretty {
+		this.changeIndent(indentationDirection)
+		indent := this.indent
+		indentByte := uint8(indent)
+
+		/*
+		 * Limit indentation depth.
+		 */
+		if indent > math.MaxUint8 {
+			indentByte = math.MaxUint8
+		}
+
+		buffer := this.buffer
+		buffer.WriteRune('\n')
+
+		/*
+		 * Write indentation.
+		 */
+		for i := uint8(0); i < indentByte; i++ {
+			buffer.WriteRune('\t')
+		}
+
+	}
+
+}
+
+/*
+ * Convert a string value into a JSON string literal.
+ */
+func (this *databaseJsonSerializerStruct) toStringLiteral(value string) string {
+	buf := strings.Builder{}
+	buf.WriteRune('"')
+
+	/*
+	 * Iterate over the input string.
+	 */
+	for _, c := range value {
+
+		/*
+		 * Perform action depending on character.
+		 */
+		switch c {
+		case '\\':
+			buf.WriteString("\\\\")
+		case '"':
+			buf.WriteString("\\\"")
+		case '\n':
+			buf.WriteString("\\n")
+		case '\r':
+			buf.WriteString("\\r")
+		case '\t':
+			buf.WriteString("\\t")
+		default:
+			isControl := this.isControlCharacter(c)
+
+			/*
+			 * Escape control character.
+			 */
+			if isControl {
+				uc := uint16(c)
+				fmt.Fprintf(&buf, "\\u%04x", uc)
+			} else {
+				buf.WriteRune(c)
+			}
+
+		}
+
+	}
+
+	buf.WriteRune('"')
+	result := buf.String()
+	return result
+}
+
+/*
+ * Implements the Read function from io.ReadCloser.
+ */
+func (this *databaseJsonSerializerStruct) Read(buf []byte) (int, error) {
+	numBytesRead := 0
+	errResult := error(nil)
+	this.mutex.Lock()
+	db := this.db
+
+	/*
+	 * Check if serializer is already closed.
+	 */
+	if db == nil {
+		errResult = createError(ErrClosed, "%s", "Database serializer is already closed.")
+	} else {
+		buffer := this.buffer
+		numBytesAvailable := buffer.Len()
+		numBytesToRead := len(buf)
+		err := error(nil)
+
+		/*
+		 * Generate JSON until enough data is available or error occurs.
+		 */
+		for (numBytesAvailable < numBytesToRead) && (err == nil) {
+			err = this.generateJSON()
+			numBytesAvailable = buffer.Len()
+		}
+
+		/*
+		 * Check if error occured.
+		 */
+		if err != nil {
+			errResult = err
+		}
+
+		bufferContent := buffer.String()
+		bufferBytes := []byte(bufferContent)
+		buffer.Reset()
+		numBytesAvailable = len(bufferBytes)
+		numBytesRead = numBytesToRead
+
+		/*
+		 * If there are fewer bytes available, then this is the limit.
+		 */
+		if numBytesAvailable < numBytesRead {
+			numBytesRead = numBytesAvailable
+		}
+
+		bufferToCopy := bufferBytes[0:numBytesRead]
+		copy(buf, bufferToCopy)
+
+		/*
+		 * If there are leftover bytes, we need to keep them.
+		 */
+		if numBytesAvailable > numBytesRead {
+			bufferToKeep := bufferBytes[numBytesRead:numBytesAvailable]
+			buffer.Write(bufferToKeep)
+		}
+
+		this.mutex.Unlock()
+	}
+
+	return numBytesRead, errResult
+}
+
+/*
+ * Implements the Close function from io.ReadCloser.
+ *
+ * This will yield the read lock on the underlying database.
+ */
+func (this *databaseJsonSerializerStruct) Close() error {
+	result := error(nil)
+	this.mutex.Lock()
+	db := this.db
+
+	/*
+	 * Check if serializer is already closed.
+	 */
+	if db == nil {
+		result = createError(ErrClosed, "%s", "Database serializer is already closed.")
+	} else {
+		db.snapshotLock.RUnlock()
+		this.db = nil
+	}
+
+	this.mutex.Unlock()
+	return result
+}
+
+/*
+ * Begin a JSON list.
+ */
+func (this *databaseGeoJsonSerializerStruct) beginList() {
+	buffer := this.buffer
+	buffer.WriteRune('[')
+	this.startLine(JSON_INDENT_IN)
+}
+
+/*
+ * Begin a JSON object.
+ */
+func (this *databaseGeoJsonSerializerStruct) beginObject() {
+	buffer := this.buffer
+	buffer.WriteRune('{')
+	this.startLine(JSON_INDENT_IN)
+}
+
+/*
+ * Change the indentation depth.
+ */
+func (this *databaseGeoJsonSerializerStruct) changeIndent(direction int) {
+	indent := this.indent
+
+	/*
+	 * Decide on the indentation direction.
+	 */
+	switch direction {
+	case JSON_INDENT_IN:
+
+		/*
+		 * Increase indent, preventing overflow.
+		 */
+		if indent < math.MaxUint16 {
+			indent++
+		}
+
+	case JSON_INDENT_OUT:
+
+		/*
+		 * Decrease indent, preventing underflow.
+		 */
+		if indent > 0 {
+			indent--
+		}
+
+	default:
+		// Do nothing.
+	}
+
+	this.indent = indent
+}
+
+/*
+ * Begin a new line string.
+ */
+func (this *databaseGeoJsonSerializerStruct) beginLine() {
+	linesWritten := this.linesWritten
+
+	/*
+	 * Separate line string from the previous one.
+	 */
+	if linesWritten > 0 {
+		this.nextItem()
+	}
+
+	this.beginList()
+	this.linesWritten = linesWritten + 1
+}
+
+/*
+ * End the current line string, if one is in progress.
+ *
+ * A line string must consist of at least two positions, so a line string
+ * consisting of a single location repeats that location.
+ */
+func (this *databaseGeoJsonSerializerStruct) endLine() {
+	lineOpen := this.lineOpen
+	pendingOpen := this.pendingOpen
+
+	/*
+	 * Check whether a line string or a single location is in progress.
+	 */
+	if lineOpen {
+		this.endList()
+		this.lineOpen = false
+	} else if pendingOpen {
+		buffer := this.buffer
+		pending := this.pending
+		this.beginLine()
+		buffer.WriteString(pending)
+		this.nextItem()
+		buffer.WriteString(pending)
+		this.endList()
+		this.pendingOpen = false
+	}
+
+}
+
+/*
+ * End a JSON list.
+ */
+func (this *databaseGeoJsonSerializerStruct) endList() {
+	this.startLine(JSON_INDENT_OUT)
+	buffer := this.buffer
+	buffer.WriteRune(']')
+}
+
+/*
+ * End a JSON object.
+ */
+func (this *databaseGeoJsonSerializerStruct) endObject() {
+	this.startLine(JSON_INDENT_OUT)
+	buffer := this.buffer
+	buffer.WriteRune('}')
+}
+
+/*
+ * Format fixed-point value with E7 exponent as string.
+ */
+func (this *databaseGeoJsonSerializerStruct) formatFixedE7(valueE7 int32) string {
+	result := "<INVALID>"
+	buf := fmt.Sprintf("%+09d", valueE7)
+	bufSize := len(buf)
+
+	/*
+	 * Check that buffer has sufficient size.
+	 */
+	if bufSize >= 9 {
+		sign := buf[0]
+		negative := sign == byte('-')
+		posDecimalPoint := bufSize - 7
+		leftOfPoint := buf[1:posDecimalPoint]
+		rightOfPoint := buf[posDecimalPoint:bufSize]
+		outputSize := bufSize + 1
+
+		/*
+		 * Negative number needs one byte more for the sign.
+		 */
+		if negative {
+			outputSize++
+		}
 
-	/*
-	 * Create database XML serializer.
-	 */
-	s := databaseXmlSerializerStruct{
-		buffer: buf,
-		db:     this,
-		pretty: pretty,
-		state:  XML_STREAM_HEADER,
+		builder := strings.Builder{}
+		builder.Grow(outputSize)
+
+		/*
+		 * If number is negative, start with unary minus.
+		 */
+		if negative {
+			builder.WriteRune('-')
+		}
+
+		builder.WriteString(leftOfPoint)
+		builder.WriteRune('.')
+		builder.WriteString(rightOfPoint)
+		result = builder.String()
 	}
 
-	return &s
+	return result
 }
 
 /*
- * Sorts entries in the database by (ascending) time stamp using a stable
- * sorting algorithm.
- *
- * If the database is closed, this is a no-op.
- *
- * This temporarily locks the database for write access.
+ * Format timestamp as string value.
  */
-func (this *databaseStruct) Sort() error {
-	result := error(nil)
-	this.mutex.Lock()
-	fd := this.fd
-
-	/*
-	 * Only sort database if it is still open.
-	 */
-	if fd != nil {
-		result = this.sort()
-	}
-
-	this.mutex.Unlock()
+func (this *databaseGeoJsonSerializerStruct) formatTimestamp(timestamp uint64) string {
+	timestampSigned := int64(timestamp)
+	t := time.UnixMilli(timestampSigned)
+	utcTime := t.UTC()
+	result := utcTime.Format(time.RFC3339Nano)
 	return result
 }
 
 /*
- * Implements the Read function from io.ReadSeekCloser.
+ * Generate more GeoJSON data.
  */
-func (this *databaseBinarySerializerStruct) Read(buf []byte) (int, error) {
-	result := int(0)
+func (this *databaseGeoJsonSerializerStruct) generateGeoJSON() error {
+	state := this.state
 	errResult := error(nil)
-	this.mutex.Lock()
-	db := this.db
 
-	/*
-	 * Check if serializer is still open.
-	 */
-	if db == nil {
-		errResult = fmt.Errorf("%s", "Database serializer is already closed.")
-	} else {
-		fd := db.fd
+	switch state {
+	case GEOJSON_STREAM_HEADER:
+		this.beginObject()
+		this.generateKeyValuePair("type", "\"FeatureCollection\"")
+		this.nextItem()
+		this.generateObjectKey("features")
+		this.beginList()
+		this.beginObject()
+		this.generateKeyValuePair("type", "\"Feature\"")
+		this.nextItem()
+		this.generateObjectKey("geometry")
+		this.beginObject()
+		this.generateKeyValuePair("type", "\"MultiLineString\"")
+		this.nextItem()
+		this.generateObjectKey("coordinates")
+		this.beginList()
+		state = GEOJSON_STREAM_COORDINATES
+	case GEOJSON_STREAM_COORDINATES, GEOJSON_STREAM_TIMES:
+		err := this.generateGeoJSONForNextEntry()
 
 		/*
-		 * Check if file descriptor is still open.
+		 * Check for errors during serialization.
 		 */
-		if fd == nil {
-			errResult = fmt.Errorf("%s", "Database is already closed.")
+		if err != nil {
+			msg := err.Error()
+			errResult = fmt.Errorf("Error generating entry: %s", msg)
+			state = GEOJSON_STREAM_ERROR
 		} else {
-			locationCount := db.locationCount
-			locationCount64 := uint64(locationCount)
-			size := SIZE_DATABASE_HEADER + (SIZE_DATABASE_ENTRY * locationCount64)
-			offset := this.offset
-			bytesInFile := size - offset
-			bufSize := len(buf)
-			bytesToRead := uint64(bufSize)
-
-			/*
-			 * Limit bytes to read to file size.
-			 */
-			if bytesToRead > bytesInFile {
-				bytesToRead = bytesInFile
-			}
-
-			bufTarget := buf[0:bytesToRead]
-			offsetSigned := int64(offset)
+			moreAvailable := this.hasMoreEntries()
 
 			/*
-			 * Prevent overflow.
+			 * If there are no more entries to be serialized,
+			 * transition to the next section.
 			 */
-			if offsetSigned < 0 {
-				errResult = fmt.Errorf("%s", "Overflow.")
-			} else {
-				bytesRead, err := fd.ReadAt(bufTarget, offsetSigned)
-				bytesRead64 := uint64(bytesRead)
-
-				/*
-				 * Prevent out of bounds errors and implausible results.
-				 */
-				if bytesRead < 0 {
-					bytesRead = 0
-					bytesRead64 = uint64(bytesRead)
-				} else if bytesRead64 > bytesToRead {
-					bytesRead = int(bytesToRead)
-					bytesRead64 = bytesToRead
-				}
-
-				/*
-				 * Handle I/O errors.
-				 */
-				if err == io.EOF {
-
-					/*
-					 * Check if we read as many bytes as expected.
-					 */
-					if bytesRead64 < bytesToRead {
-						errResult = io.ErrUnexpectedEOF
-					}
-
-				} else if err != nil {
-					msg := err.Error()
-					errResult = fmt.Errorf("I/O error: %s", msg)
-					bytesRead = 0
-					bytesRead64 = 0
-				}
-
-				bufToZero := buf[bytesRead:bufSize]
-
-				/*
-				 * Zero out remaining part of the buffer.
-				 */
-				for i := range bufToZero {
-					bufToZero[i] = 0
-				}
-
-				offset += bytesRead64
-				result = bytesRead
+			if !moreAvailable && (state == GEOJSON_STREAM_COORDINATES) {
+				this.endLine()
+				this.endList()
+				this.endObject()
+				this.nextItem()
+				this.generateObjectKey("properties")
+				this.beginObject()
+				this.generateObjectKey("coordTimes")
+				this.beginList()
+				this.entryId = 0
+				this.linesWritten = 0
+				state = GEOJSON_STREAM_TIMES
+			} else if !moreAvailable {
+				state = GEOJSON_STREAM_TRAILER
 			}
 
-			this.offset = offset
 		}
 
+	case GEOJSON_STREAM_TRAILER:
+		this.endLine()
+		this.endList()
+		this.endObject()
+		this.endObject()
+		this.endList()
+		this.endObject()
+		state = GEOJSON_STREAM_EOF
+	case GEOJSON_STREAM_EOF:
+		errResult = io.EOF
+	default:
+		errResult = fmt.Errorf("%s", "Error during GeoJSON serialization.")
 	}
 
-	this.mutex.Unlock()
-	return result, errResult
+	this.state = state
+	return errResult
 }
 
 /*
- * Implements the Seek function from io.ReadSeekCloser.
+ * Generate GeoJSON data for next entry in geographical database.
+ *
+ * While serializing coordinates, this emits a coordinate pair. While
+ * serializing time stamps, this emits a time stamp. Both passes split line
+ * strings at the same entries.
  */
-func (this *databaseBinarySerializerStruct) Seek(offset int64, whence int) (int64, error) {
-	result := int64(0)
+func (this *databaseGeoJsonSerializerStruct) generateGeoJSONForNextEntry() error {
 	errResult := error(nil)
-	this.mutex.Lock()
-	db := this.db
+	moreAvailable := this.hasMoreEntries()
 
 	/*
-	 * Check if serializer is still open.
+	 * Check if more entries are available.
 	 */
-	if db == nil {
-		errResult = fmt.Errorf("%s", "Database serializer is already closed.")
-	} else {
-		fd := db.fd
+	if moreAvailable {
+		db := this.db
+		entryId := this.entryId
+		stride := this.stride
+		skip := (stride > 1) && ((entryId % stride) != 0)
 
 		/*
-		 * Check if file descriptor is still open.
+		 * Only read entries which are not skipped due to stride.
 		 */
-		if fd == nil {
-			errResult = fmt.Errorf("%s", "Database is already closed.")
-		} else {
-			locationCount := db.locationCount
-			locationCount64 := uint64(locationCount)
-			size := SIZE_DATABASE_HEADER + (SIZE_DATABASE_ENTRY * locationCount64)
-			offset64 := uint64(offset)
-			offsetCurrent := this.offset
+		if !skip {
+			entry := databaseEntryStruct{}
+			fd := db.fd
+			endianness := binary.BigEndian
+			offset := uint64(entryId)
+			entrySize := db.entrySize
+			offsetBytes := SIZE_DATABASE_HEADER + (entrySize * offset)
+			offsetBytesSigned := int64(offsetBytes)
+			bufRead := make([]byte, SIZE_DATABASE_ENTRY)
+			bufEntry := bufRead[0:entrySize]
+			numBytesRead, err := fd.ReadAt(bufEntry, offsetBytesSigned)
 
 			/*
-			 * Decide relative to what to seek.
+			 * If we read less bytes than expected, zero out part of the
+			 * buffer.
 			 */
-			switch whence {
-			case io.SeekStart:
+			if numBytesRead < SIZE_DATABASE_ENTRY {
+				zero := bufRead[numBytesRead:SIZE_DATABASE_ENTRY]
 
 				/*
-				 * Check if absolute offset is negative.
+				 * Zero the unused part of the buffer.
 				 */
-				if offset < 0 {
-					errResult = fmt.Errorf("%s", "Cannot seek to negative absolute offset.")
-				} else {
-					offsetCurrent = offset64
-					result = int64(offsetCurrent)
+				for i := range zero {
+					zero[i] = 0
 				}
 
-			case io.SeekCurrent:
-				offsetNew := offsetCurrent + offset64
+			}
+
+			/*
+			 * Check for read error.
+			 */
+			if err != nil {
+				errResult = fmt.Errorf("Error reading from offset: 0x%016x", offsetBytes)
+			} else {
+				rd := bytes.NewReader(bufRead)
+				err = binary.Read(rd, endianness, &entry)
 
 				/*
-				 * Prevent numeric overflow.
+				 * Check if database entry could be deserialized.
 				 */
-				if ((offset > 0) && (offsetNew <= offsetCurrent)) || ((offset < 0) && (offsetNew >= offsetCurrent)) {
-					errResult = fmt.Errorf("%s", "Overflow or negative target offset.")
+				if err != nil {
+					errResult = createError(ErrCorrupt, "Error deserializing entry at offset: 0x%016x", offsetBytes)
 				} else {
-					offsetCurrent = offsetNew
-					result = int64(offsetCurrent)
-				}
+					timestampMSB := entry.TimestampMSB
+					timestampMSB64 := uint64(timestampMSB)
+					timestampLSB := entry.TimestampLSB
+					timestampLSB64 := uint64(timestampLSB)
+					timestamp := (timestampMSB64 << 32) | timestampLSB64
 
-			case io.SeekEnd:
-				offsetNew := size + offset64
+					/*
+					 * Create location.
+					 */
+					loc := Location{
+						Timestamp:   timestamp,
+						LatitudeE7:  entry.LatitudeE7,
+						LongitudeE7: entry.LongitudeE7,
+						Accuracy:    entry.Accuracy,
+					}
+
+					transform := this.transform
+					keep := true
+
+					/*
+					 * Only apply transform if there is one.
+					 */
+					if transform != nil {
+						keep = transform(&loc)
+					}
+
+					/*
+					 * Locations omitted by the transform end the current
+					 * line string, so that no line leads through them.
+					 */
+					if !keep {
+						this.endLine()
+					} else {
+						gap := this.gap
+						timestampPrevious := this.timestampPrevious
+						lineOpen := this.lineOpen
+						pendingOpen := this.pendingOpen
+						inProgress := lineOpen || pendingOpen
+
+						/*
+						 * Check if the time gap to the previous location is
+						 * too large.
+						 */
+						if inProgress && (gap > 0) && (loc.Timestamp > timestampPrevious) && ((loc.Timestamp - timestampPrevious) > gap) {
+							this.endLine()
+						}
+
+						state := this.state
+						value := ""
+
+						/*
+						 * Emit either coordinates or time stamp.
+						 */
+						if state == GEOJSON_STREAM_COORDINATES {
+							longitudeString := this.formatFixedE7(loc.LongitudeE7)
+							latitudeString := this.formatFixedE7(loc.LatitudeE7)
+							pretty := this.pretty
+							separator := ","
+
+							/*
+							 * When pretty-printing, separate values by space.
+							 */
+							if pretty {
+								separator = ", "
+							}
+
+							value = "[" + longitudeString + separator + latitudeString + "]"
+						} else {
+							timestampString := this.formatTimestamp(loc.Timestamp)
+							value = "\"" + timestampString + "\""
+						}
+
+						buffer := this.buffer
+						lineOpen = this.lineOpen
+						pendingOpen = this.pendingOpen
+
+						/*
+						 * Hold back the first location of a line string
+						 * until we know whether a second one follows.
+						 */
+						if lineOpen {
+							this.nextItem()
+							buffer.WriteString(value)
+						} else if pendingOpen {
+							pending := this.pending
+							this.beginLine()
+							buffer.WriteString(pending)
+							this.nextItem()
+							buffer.WriteString(value)
+							this.lineOpen = true
+							this.pendingOpen = false
+						} else {
+							this.pending = value
+							this.pendingOpen = true
+						}
+
+						this.timestampPrevious = loc.Timestamp
+					}
 
-				/*
-				 * Prevent numeric overflow.
-				 */
-				if ((offset > 0) && (offsetNew <= size)) || ((offset < 0) && (offsetNew >= size)) {
-					errResult = fmt.Errorf("%s", "Overflow or negative target offset.")
-				} else {
-					offsetCurrent = offsetNew
-					result = int64(offsetCurrent)
 				}
 
-			default:
-				errResult = fmt.Errorf("Seek: Invalid value for 'whence': %d", whence)
 			}
 
-			this.offset = offsetCurrent
 		}
 
+		entryId++
+		this.entryId = entryId
 	}
 
-	this.mutex.Unlock()
-	return result, errResult
+	return errResult
+}
+
+/*
+ * Generate GeoJSON data for a key-value-pair.
+ *
+ * The value must already be a JSON literal.
+ */
+func (this *databaseGeoJsonSerializerStruct) generateKeyValuePair(key string, valueLiteral string) {
+	buffer := this.buffer
+	this.generateObjectKey(key)
+	buffer.WriteString(valueLiteral)
 }
 
 /*
- * Implements the Close function from io.ReadSeekCloser.
+ * Generate GeoJSON data for object key.
  *
- * This will yield the read lock on the underlying database.
+ * The key must not contain characters requiring escaping.
  */
-func (this *databaseBinarySerializerStruct) Close() error {
-	result := error(nil)
-	this.mutex.Lock()
-	db := this.db
+func (this *databaseGeoJsonSerializerStruct) generateObjectKey(key string) {
+	pretty := this.pretty
+	buffer := this.buffer
+	buffer.WriteRune('"')
+	buffer.WriteString(key)
+	buffer.WriteRune('"')
+	buffer.WriteRune(':')
 
 	/*
-	 * Check if serializer is already closed.
+	 * When pretty-printing, emit space after object key.
 	 */
-	if db == nil {
-		result = fmt.Errorf("%s", "Database serializer is already closed.")
-	} else {
-		db.mutex.RUnlock()
-		this.db = nil
+	if pretty {
+		buffer.WriteRune(' ')
 	}
 
-	this.mutex.Unlock()
-	return result
 }
 
 /*
- * Format timestamp as string value.
+ * Returns whether there are more entries in the database to be serialized.
  */
-func (this *databaseCsvSerializerStruct) formatTimestamp(timestamp uint64) string {
-	timestampSigned := int64(timestamp)
-	t := time.UnixMilli(timestampSigned)
-	utcTime := t.UTC()
-	result := utcTime.Format(time.RFC3339Nano)
+func (this *databaseGeoJsonSerializerStruct) hasMoreEntries() bool {
+	entryId := this.entryId
+	locationCount := this.locationCount
+	result := entryId < locationCount
 	return result
 }
 
 /*
- * Format latitude as string value.
+ * Starts a new item, either in a list or an object.
  */
-func (this *databaseCsvSerializerStruct) formatLatitude(latitudeE7 int32) string {
-	result := "<INVALID>"
-	buf := fmt.Sprintf("%+09d", latitudeE7)
-	bufSize := len(buf)
+func (this *databaseGeoJsonSerializerStruct) nextItem() {
+	buffer := this.buffer
+	buffer.WriteRune(',')
+	pretty := this.pretty
 
 	/*
-	 * Check that buffer has sufficient size.
+	 * For pretty-printing, start new line for each item.
 	 */
-	if bufSize >= 9 {
-		sign := buf[0]
-		direction := '?'
-
-		/*
-		 * Check sign of number.
-		 */
-		switch sign {
-		case byte('+'):
-			direction = 'N'
-		case byte('-'):
-			direction = 'S'
-		}
-
-		posDecimalPoint := bufSize - 7
-		leftOfPoint := buf[1:posDecimalPoint]
-		rightOfPoint := buf[posDecimalPoint:bufSize]
-		outputSize := bufSize + 1
-		builder := strings.Builder{}
-		builder.Grow(outputSize)
-		builder.WriteString(leftOfPoint)
-		builder.WriteRune('.')
-		builder.WriteString(rightOfPoint)
-		builder.WriteRune(direction)
-		result = builder.String()
+	if pretty {
+		this.startLine(JSON_INDENT_NONE)
 	}
 
-	return result
 }
 
 /*
- * Format longitude as string value.
+ * Begins a new line, including indentation.
  */
-func (this *databaseCsvSerializerStruct) formatLongitude(longitudeE7 int32) string {
-	result := "<INVALID>"
-	buf := fmt.Sprintf("%+09d", longitudeE7)
-	bufSize := len(buf)
+func (this *databaseGeoJsonSerializerStruct) startLine(indentationDirection int) {
+	pretty := this.pretty
 
 	/*
-	 * Check that buffer has sufficient size.
+	 * Only do this when pretty-printing JSON.
 	 */
-	if bufSize >= 9 {
-		sign := buf[0]
-		direction := '?'
+	if pretty {
+		this.changeIndent(indentationDirection)
+		indent := this.indent
+		indentByte := uint8(indent)
 
 		/*
-		 * Check sign of number.
+		 * Limit indentation depth.
 		 */
-		switch sign {
-		case byte('+'):
-			direction = 'E'
-		case byte('-'):
-			direction = 'W'
+		if indent > math.MaxUint8 {
+			indentByte = math.MaxUint8
+		}
+
+		buffer := this.buffer
+		buffer.WriteRune('\n')
+
+		/*
+		 * Write indentation.
+		 */
+		for i := uint8(0); i < indentByte; i++ {
+			buffer.WriteRune('\t')
 		}
 
-		posDecimalPoint := bufSize - 7
-		leftOfPoint := buf[1:posDecimalPoint]
-		rightOfPoint := buf[posDecimalPoint:bufSize]
-		outputSize := bufSize + 1
-		builder := strings.Builder{}
-		builder.Grow(outputSize)
-		builder.WriteString(leftOfPoint)
-		builder.WriteRune('.')
-		builder.WriteString(rightOfPoint)
-		builder.WriteRune(direction)
-		result = builder.String()
 	}
 
-	return result
 }
 
 /*
  * Implements the Read function from io.ReadCloser.
  */
-func (this *databaseCsvSerializerStruct) Read(buf []byte) (int, error) {
-	numBytesToRead := len(buf)
-	readBytes := int(0)
+func (this *databaseGeoJsonSerializerStruct) Read(buf []byte) (int, error) {
+	numBytesRead := 0
 	errResult := error(nil)
+	this.mutex.Lock()
+	db := this.db
 
 	/*
-	 * Check if we have to read bytes.
+	 * Check if serializer is already closed.
 	 */
-	if numBytesToRead > 0 {
-		this.mutex.Lock()
-		db := this.db
+	if db == nil {
+		errResult = createError(ErrClosed, "%s", "Database serializer is already closed.")
+	} else {
+		buffer := this.buffer
+		numBytesAvailable := buffer.Len()
+		numBytesToRead := len(buf)
+		err := error(nil)
 
 		/*
-		 * Check if serializer is already closed.
+		 * Generate GeoJSON until enough data is available or error occurs.
 		 */
-		if db == nil {
-			errResult = fmt.Errorf("%s", "Database serializer is already closed.")
-		} else {
-			numEntries := db.locationCount
-			entryId := this.entryId
-			csvWriter := this.csvWriter
-			lineBuffer := this.lineBuffer
-			line := lineBuffer.String()
-			lineLength := len(line)
-			lineOffset := this.lineOffset
-			bufRead := make([]byte, SIZE_DATABASE_ENTRY)
-
-			/*
-			 * Continue until we reach the end of the file or
-			 * filled the read buffer.
-			 */
-			for ((entryId < numEntries) || ((entryId == numEntries) && (lineLength > 0))) && (readBytes < numBytesToRead) && (errResult == nil) {
-				lineFromOffset := line[lineOffset:]
-				bufOffset := buf[readBytes:]
-				n := copy(bufOffset, lineFromOffset)
-				lineOffset += n
-				readBytes += n
-
-				/*
-				 * If no bytes were copied, we have to update our buffers.
-				 */
-				if n == 0 {
-
-					/*
-					 * If there are no more entries, we have to clear our buffers.
-					 *
-					 * Otherwise, we will generate a new line.
-					 */
-					if entryId >= numEntries {
-						lineBuffer.Reset()
-						line = lineBuffer.String()
-						lineLength = len(line)
-						lineOffset = 0
-					} else {
-						entry := databaseEntryStruct{}
-						fd := db.fd
-						endianness := binary.BigEndian
-						offset := uint64(entryId)
-						offsetBytes := SIZE_DATABASE_HEADER + (SIZE_DATABASE_ENTRY * offset)
-						offsetBytesSigned := int64(offsetBytes)
-						numBytesRead, err := fd.ReadAt(bufRead, offsetBytesSigned)
-
-						/*
-						 * If we read less bytes than expected,
-						 * zero out part of the buffer.
-						 */
-						if numBytesRead < SIZE_DATABASE_ENTRY {
-							zero := bufRead[numBytesRead:SIZE_DATABASE_ENTRY]
-
-							/*
-							 * Zero the unused part of the buffer.
-							 */
-							for i := range zero {
-								zero[i] = 0
-							}
-
-						}
-
-						/*
-						 * Check for read error.
-						 */
-						if err != nil {
-							errResult = fmt.Errorf("Error reading from offset: 0x%016x", offsetBytes)
-						} else {
-							rd := bytes.NewReader(bufRead)
-							err = binary.Read(rd, endianness, &entry)
-
-							/*
-							 * Check if database entry could be deserialized.
-							 */
-							if err != nil {
-								errResult = fmt.Errorf("Error deserializing entry at offset: 0x%016x", offsetBytes)
-							} else {
-								timestampMSB := entry.TimestampMSB
-								timestampMSB64 := uint64(timestampMSB)
-								timestampLSB := entry.TimestampLSB
-								timestampLSB64 := uint64(timestampLSB)
-								timestamp := (timestampMSB64 << 32) | timestampLSB64
-								latitudeE7 := entry.LatitudeE7
-								longitudeE7 := entry.LongitudeE7
-								timestampString := this.formatTimestamp(timestamp)
-								latitudeString := this.formatLatitude(latitudeE7)
-								longitudeString := this.formatLongitude(longitudeE7)
-
-								/*
-								 * Create record.
-								 */
-								record := []string{
-									timestampString,
-									latitudeString,
-									longitudeString,
-								}
-
-								lineBuffer.Reset()
-								csvWriter.Write(record)
-								csvWriter.Flush()
-								line = lineBuffer.String()
-								lineLength = len(line)
-								lineOffset = 0
-							}
-
-						}
+		for (numBytesAvailable < numBytesToRead) && (err == nil) {
+			err = this.generateGeoJSON()
+			numBytesAvailable = buffer.Len()
+		}
 
-						entryId++
-					}
+		/*
+		 * Check if error occured.
+		 */
+		if err != nil {
+			errResult = err
+		}
 
-				}
+		bufferContent := buffer.String()
+		bufferBytes := []byte(bufferContent)
+		buffer.Reset()
+		numBytesAvailable = len(bufferBytes)
+		numBytesRead = numBytesToRead
 
-			}
+		/*
+		 * If there are fewer bytes available, then this is the limit.
+		 */
+		if numBytesAvailable < numBytesRead {
+			numBytesRead = numBytesAvailable
+		}
 
-			/*
-			 * Check for end of file condition.
-			 */
-			if (entryId > numEntries) || ((entryId == numEntries) && (lineLength == 0)) {
-				errResult = io.EOF
-			}
+		bufferToCopy := bufferBytes[0:numBytesRead]
+		copy(buf, bufferToCopy)
 
-			this.entryId = entryId
-			this.lineOffset = lineOffset
+		/*
+		 * If there are leftover bytes, we need to keep them.
+		 */
+		if numBytesAvailable > numBytesRead {
+			bufferToKeep := bufferBytes[numBytesRead:numBytesAvailable]
+			buffer.Write(bufferToKeep)
 		}
 
-		this.mutex.Unlock()
 	}
 
-	return readBytes, errResult
+	this.mutex.Unlock()
+	return numBytesRead, errResult
 }
 
 /*
@@ -1317,7 +4325,7 @@ func (this *databaseCsvSerializerStruct) Read(buf []byte) (int, error) {
  *
  * This will yield the read lock on the underlying database.
  */
-func (this *databaseCsvSerializerStruct) Close() error {
+func (this *databaseGeoJsonSerializerStruct) Close() error {
 	result := error(nil)
 	this.mutex.Lock()
 	db := this.db
@@ -1326,9 +4334,9 @@ func (this *databaseCsvSerializerStruct) Close() error {
 	 * Check if serializer is already closed.
 	 */
 	if db == nil {
-		result = fmt.Errorf("%s", "Database serializer is already closed.")
+		result = createError(ErrClosed, "%s", "Database serializer is already closed.")
 	} else {
-		db.mutex.RUnlock()
+		db.snapshotLock.RUnlock()
 		this.db = nil
 	}
 
@@ -1336,35 +4344,17 @@ func (this *databaseCsvSerializerStruct) Close() error {
 	return result
 }
 
-/*
- * Begin a JSON list.
- */
-func (this *databaseJsonSerializerStruct) beginList() {
-	buffer := this.buffer
-	buffer.WriteRune('[')
-	this.startLine(JSON_INDENT_IN)
-}
-
-/*
- * Begin a JSON object.
- */
-func (this *databaseJsonSerializerStruct) beginObject() {
-	buffer := this.buffer
-	buffer.WriteRune('{')
-	this.startLine(JSON_INDENT_IN)
-}
-
 /*
  * Change the indentation depth.
  */
-func (this *databaseJsonSerializerStruct) changeIndent(direction int) {
+func (this *databaseXmlSerializerStruct) changeIndent(direction int) {
 	indent := this.indent
 
 	/*
 	 * Decide on the indentation direction.
 	 */
 	switch direction {
-	case JSON_INDENT_IN:
+	case XML_INDENT_IN:
 
 		/*
 		 * Increase indent, preventing overflow.
@@ -1373,7 +4363,7 @@ func (this *databaseJsonSerializerStruct) changeIndent(direction int) {
 			indent++
 		}
 
-	case JSON_INDENT_OUT:
+	case XML_INDENT_OUT:
 
 		/*
 		 * Decrease indent, preventing underflow.
@@ -1390,128 +4380,183 @@ func (this *databaseJsonSerializerStruct) changeIndent(direction int) {
 }
 
 /*
- * End a JSON list.
+ * Begins a new line, including indentation.
  */
-func (this *databaseJsonSerializerStruct) endList() {
-	this.startLine(JSON_INDENT_OUT)
+func (this *databaseXmlSerializerStruct) startLine(indentationDirection int) {
+	pretty := this.pretty
+
+	/*
+	 * Only do this when pretty-printing XML.
+	 */
+	if pretty {
+		this.changeIndent(indentationDirection)
+		indent := this.indent
+		indentByte := uint8(indent)
+
+		/*
+		 * Limit indentation depth.
+		 */
+		if indent > math.MaxUint8 {
+			indentByte = math.MaxUint8
+		}
+
+		buffer := this.buffer
+		buffer.WriteRune('\n')
+
+		/*
+		 * Write indentation.
+		 */
+		for i := uint8(0); i < indentByte; i++ {
+			buffer.WriteRune('\t')
+		}
+
+	}
+
+}
+
+/*
+ * Write XML declaration.
+ */
+func (this *databaseXmlSerializerStruct) generateXMLDeclaration() {
 	buffer := this.buffer
-	buffer.WriteRune(']')
+	buffer.WriteString("<?xml version=\"1.0\" encoding=\"UTF-8\"?>")
+	this.startLine(XML_INDENT_NONE)
 }
 
 /*
- * End a JSON object.
+ * Generate an opening tag, which will include inner tags.
  */
-func (this *databaseJsonSerializerStruct) endObject() {
-	this.startLine(JSON_INDENT_OUT)
+func (this *databaseXmlSerializerStruct) generateOpeningTag(name string, attributes ...keyValuePairStruct) {
 	buffer := this.buffer
-	buffer.WriteRune('}')
+	buffer.WriteRune('<')
+	buffer.WriteString(name)
+
+	/*
+	 * Serialize key-value pairs.
+	 */
+	for _, kv := range attributes {
+		key := kv.key
+		value := kv.value
+		buffer.WriteRune(' ')
+		buffer.WriteString(key)
+		buffer.WriteString("=\"")
+		buffer.WriteString(value)
+		buffer.WriteRune('"')
+	}
+
+	buffer.WriteRune('>')
+	this.startLine(XML_INDENT_IN)
 }
 
 /*
- * Format timestamp as string value.
+ * Generate a closing tag.
  */
-func (this *databaseJsonSerializerStruct) formatTimestamp(timestamp uint64) string {
-	timestampSigned := int64(timestamp)
-	t := time.UnixMilli(timestampSigned)
-	utcTime := t.UTC()
-	result := utcTime.Format(time.RFC3339Nano)
-	return result
+func (this *databaseXmlSerializerStruct) generateClosingTag(name string) {
+	this.startLine(XML_INDENT_OUT)
+	buffer := this.buffer
+	buffer.WriteString("</")
+	buffer.WriteString(name)
+	buffer.WriteRune('>')
 }
 
 /*
- * Generate more JSON data.
+ * Generate a tag pair with a value text.
  */
-func (this *databaseJsonSerializerStruct) generateJSON() error {
-	state := this.state
-	errResult := error(nil)
+func (this *databaseXmlSerializerStruct) generateTagPairWithValueText(name string, vt string) {
+	buffer := this.buffer
+	buffer.WriteRune('<')
+	buffer.WriteString(name)
+	buffer.WriteRune('>')
+	buffer.WriteString(vt)
+	buffer.WriteString("</")
+	buffer.WriteString(name)
+	buffer.WriteRune('>')
+}
 
-	switch state {
-	case JSON_STREAM_HEADER:
-		this.beginObject()
-		this.generateJSONForObjectKey("locations")
-		this.beginList()
-		state = JSON_STREAM_ENTRIES
-	case JSON_STREAM_ENTRIES:
-		err := this.generateJSONForNextEntry()
+/*
+ * Format fixed-point value with E7 exponent as string.
+ */
+func (this *databaseXmlSerializerStruct) formatFixedE7(valueE7 int32) string {
+	result := "<INVALID>"
+	buf := fmt.Sprintf("%+09d", valueE7)
+	bufSize := len(buf)
+
+	/*
+	 * Check that buffer has sufficient size.
+	 */
+	if bufSize >= 9 {
+		sign := buf[0]
+		negative := sign == byte('-')
+		posDecimalPoint := bufSize - 7
+		leftOfPoint := buf[1:posDecimalPoint]
+		rightOfPoint := buf[posDecimalPoint:bufSize]
+		outputSize := bufSize + 1
 
 		/*
-		 * Check for errors during serialization.
+		 * Negative number needs one byte more for the sign.
 		 */
-		if err != nil {
-			msg := err.Error()
-			errResult = fmt.Errorf("Error generating entry: %s", msg)
-			state = JSON_STREAM_ERROR
-		} else {
-			moreAvailable := this.hasMoreEntries()
+		if negative {
+			outputSize++
+		}
 
-			/*
-			 * If there are more entries to be serialized, write
-			 * separator, otherwise transition to serializing the
-			 * trailer.
-			 */
-			if moreAvailable {
-				this.nextItem()
-			} else {
-				state = JSON_STREAM_TRAILER
-			}
+		builder := strings.Builder{}
+		builder.Grow(outputSize)
 
+		/*
+		 * If number is negative, start with unary minus.
+		 */
+		if negative {
+			builder.WriteRune('-')
 		}
 
-	case JSON_STREAM_TRAILER:
-		this.endList()
-		this.endObject()
-		state = JSON_STREAM_EOF
-	case JSON_STREAM_EOF:
-		errResult = io.EOF
-	default:
-		errResult = fmt.Errorf("%s", "Error during JSON serialization.")
+		builder.WriteString(leftOfPoint)
+		builder.WriteRune('.')
+		builder.WriteString(rightOfPoint)
+		result = builder.String()
 	}
 
-	this.state = state
-	return errResult
+	return result
 }
 
 /*
- * Generate JSON data for a key-value-pair.
+ * Applies the stride and the transform of the serializer to a location.
+ *
+ * Returns whether the location shall be serialized.
  */
-func (this *databaseJsonSerializerStruct) generateJSONForKeyValuePair(key string, value string, valueAsStringLiteral bool) {
-	buffer := this.buffer
-	this.generateJSONForObjectKey(key)
-	valueLiteral := value
+func (this *databaseXmlSerializerStruct) applyTransform(entryId uint32, loc *Location) bool {
+	stride := this.stride
+	transform := this.transform
+	result := true
 
 	/*
-	 * Optionally, encode value as string literal.
+	 * Skip entries between strides and only apply transform if there is
+	 * one.
 	 */
-	if valueAsStringLiteral {
-		valueLiteral = this.toStringLiteral(value)
+	if (stride > 1) && ((entryId % stride) != 0) {
+		result = false
+	} else if transform != nil {
+		result = transform(loc)
 	}
 
-	buffer.WriteString(valueLiteral)
+	return result
 }
 
 /*
- * Generate JSON data for object key.
+ * Format timestamp as string value in the time zone of the serializer.
  */
-func (this *databaseJsonSerializerStruct) generateJSONForObjectKey(key string) {
-	pretty := this.pretty
-	buffer := this.buffer
-	keyLiteral := this.toStringLiteral(key)
-	buffer.WriteString(keyLiteral)
-	buffer.WriteRune(':')
-
-	/*
-	 * When pretty-printing, emit space after object key.
-	 */
-	if pretty {
-		buffer.WriteRune(' ')
-	}
-
+func (this *databaseXmlSerializerStruct) formatTimestamp(timestamp uint64) string {
+	timestampSigned := int64(timestamp)
+	t := time.UnixMilli(timestampSigned)
+	location := this.location
+	localTime := t.In(location)
+	result := localTime.Format(time.RFC3339Nano)
+	return result
 }
 
 /*
- * Generate JSON data for next entry in geographical database.
+ * Generate XML data for next entry in geographical database.
  */
-func (this *databaseJsonSerializerStruct) generateJSONForNextEntry() error {
+func (this *databaseXmlSerializerStruct) generateXMLForNextEntry() error {
 	errResult := error(nil)
 	moreAvailable := this.hasMoreEntries()
 
@@ -1525,10 +4570,12 @@ func (this *databaseJsonSerializerStruct) generateJSONForNextEntry() error {
 		fd := db.fd
 		endianness := binary.BigEndian
 		offset := uint64(entryId)
-		offsetBytes := SIZE_DATABASE_HEADER + (SIZE_DATABASE_ENTRY * offset)
+		entrySize := db.entrySize
+		offsetBytes := SIZE_DATABASE_HEADER + (entrySize * offset)
 		offsetBytesSigned := int64(offsetBytes)
 		bufRead := make([]byte, SIZE_DATABASE_ENTRY)
-		numBytesRead, err := fd.ReadAt(bufRead, offsetBytesSigned)
+		bufEntry := bufRead[0:entrySize]
+		numBytesRead, err := fd.ReadAt(bufEntry, offsetBytesSigned)
 
 		/*
 		 * If we read less bytes than expected, zero out part of the
@@ -1559,28 +4606,66 @@ func (this *databaseJsonSerializerStruct) generateJSONForNextEntry() error {
 			 * Check if database entry could be deserialized.
 			 */
 			if err != nil {
-				errResult = fmt.Errorf("Error deserializing entry at offset: 0x%016x", offsetBytes)
+				errResult = createError(ErrCorrupt, "Error deserializing entry at offset: 0x%016x", offsetBytes)
 			} else {
 				timestampMSB := entry.TimestampMSB
 				timestampMSB64 := uint64(timestampMSB)
 				timestampLSB := entry.TimestampLSB
 				timestampLSB64 := uint64(timestampLSB)
 				timestamp := (timestampMSB64 << 32) | timestampLSB64
-				latitudeE7 := entry.LatitudeE7
-				longitudeE7 := entry.LongitudeE7
-				timestampString := this.formatTimestamp(timestamp)
-				timestampMsString := fmt.Sprintf("%d", timestamp)
-				latitudeE7String := fmt.Sprintf("%d", latitudeE7)
-				longitudeE7String := fmt.Sprintf("%d", longitudeE7)
-				this.beginObject()
-				this.generateJSONForKeyValuePair("timestamp", timestampString, true)
-				this.nextItem()
-				this.generateJSONForKeyValuePair("timestampMs", timestampMsString, true)
-				this.nextItem()
-				this.generateJSONForKeyValuePair("latitudeE7", latitudeE7String, false)
-				this.nextItem()
-				this.generateJSONForKeyValuePair("longitudeE7", longitudeE7String, false)
-				this.endObject()
+
+				/*
+				 * Create location.
+				 */
+				loc := Location{
+					Timestamp:   timestamp,
+					LatitudeE7:  entry.LatitudeE7,
+					LongitudeE7: entry.LongitudeE7,
+					Accuracy:    entry.Accuracy,
+				}
+
+				keep := this.applyTransform(entryId, &loc)
+
+				/*
+				 * Only serialize locations which were not omitted by
+				 * the transform.
+				 */
+				if keep {
+					entriesWritten := this.entriesWritten
+
+					/*
+					 * Start new line for all but the first entry.
+					 */
+					if entriesWritten > 0 {
+						this.startLine(XML_INDENT_NONE)
+					}
+
+					timestampString := this.formatTimestamp(loc.Timestamp)
+					latitudeString := this.formatFixedE7(loc.LatitudeE7)
+					longitudeString := this.formatFixedE7(loc.LongitudeE7)
+
+					/*
+					 * Create latitude attribute.
+					 */
+					attrLatitude := keyValuePairStruct{
+						key:   "lat",
+						value: latitudeString,
+					}
+
+					/*
+					 * Create longitude attribute.
+					 */
+					attrLongitude := keyValuePairStruct{
+						key:   "lon",
+						value: longitudeString,
+					}
+
+					this.generateOpeningTag("trkpt", attrLatitude, attrLongitude)
+					this.generateTagPairWithValueText("time", timestampString)
+					this.generateClosingTag("trkpt")
+					this.entriesWritten = entriesWritten + 1
+				}
+
 			}
 		}
 
@@ -1594,126 +4679,79 @@ func (this *databaseJsonSerializerStruct) generateJSONForNextEntry() error {
 /*
  * Returns whether there are more entries in the database to be serialized.
  */
-func (this *databaseJsonSerializerStruct) hasMoreEntries() bool {
-	db := this.db
+func (this *databaseXmlSerializerStruct) hasMoreEntries() bool {
+	end := this.end
 	entryId := this.entryId
-	locationCount := db.locationCount
-	result := entryId < locationCount
-	return result
-}
-
-/*
- * Returns whether this byte is an ASCII control character.
- */
-func (this *databaseJsonSerializerStruct) isControlCharacter(value rune) bool {
-	result := (value < 0x20) || (value == 0x7f)
+	locationCount := this.locationCount
+	result := (entryId < locationCount) && (entryId < end)
 	return result
 }
 
 /*
- * Starts a new item, either in a list or an object.
- */
-func (this *databaseJsonSerializerStruct) nextItem() {
-	buffer := this.buffer
-	buffer.WriteRune(',')
-	pretty := this.pretty
-
-	/*
-	 * For pretty-printing, start new line for each item.
-	 */
-	if pretty {
-		this.startLine(JSON_INDENT_NONE)
-	}
-
-}
-
-/*
- * Begins a new line, including indentation.
+ * Generate more XML data.
  */
-func (this *databaseJsonSerializerStruct) startLine(indentationDirection int) {
-	pretty := this.pretty
-
-	/*
-	 * Only do this when pretty-printing JSON.
-	 */
-	if pretty {
-		this.changeIndent(indentationDirection)
-		indent := this.indent
-		indentByte := uint8(indent)
-
-		/*
-		 * Limit indentation depth.
-		 */
-		if indent > math.MaxUint8 {
-			indentByte = math.MaxUint8
-		}
+func (this *databaseXmlSerializerStruct) generateXML() error {
+	state := this.state
+	errResult := error(nil)
 
-		buffer := this.buffer
-		buffer.WriteRune('\n')
+	switch state {
+	case XML_STREAM_HEADER:
+		this.generateXMLDeclaration()
 
 		/*
-		 * Write indentation.
+		 * Create attribute for GPX version.
 		 */
-		for i := uint8(0); i < indentByte; i++ {
-			buffer.WriteRune('\t')
+		attrVersion := keyValuePairStruct{
+			key:   "version",
+			value: "1.1",
 		}
 
-	}
-
-}
-
-/*
- * Convert a string value into a JSON string literal.
- */
-func (this *databaseJsonSerializerStruct) toStringLiteral(value string) string {
-	buf := strings.Builder{}
-	buf.WriteRune('"')
-
-	/*
-	 * Iterate over the input string.
-	 */
-	for _, c := range value {
+		this.generateOpeningTag("gpx", attrVersion)
+		this.generateOpeningTag("trk")
+		this.generateOpeningTag("trkseg")
+		state = XML_STREAM_ENTRIES
+	case XML_STREAM_ENTRIES:
+		err := this.generateXMLForNextEntry()
 
 		/*
-		 * Perform action depending on character.
+		 * Check for errors during serialization.
 		 */
-		switch c {
-		case '\\':
-			buf.WriteString("\\\\")
-		case '"':
-			buf.WriteString("\\\"")
-		case '\n':
-			buf.WriteString("\\n")
-		case '\r':
-			buf.WriteString("\\r")
-		case '\t':
-			buf.WriteString("\\t")
-		default:
-			isControl := this.isControlCharacter(c)
+		if err != nil {
+			msg := err.Error()
+			errResult = fmt.Errorf("Error generating entry: %s", msg)
+			state = JSON_STREAM_ERROR
+		} else {
+			moreAvailable := this.hasMoreEntries()
 
 			/*
-			 * Escape control character.
+			 * If there are no more entries to be serialized,
+			 * transition to serializing the trailer.
 			 */
-			if isControl {
-				uc := uint16(c)
-				fmt.Fprintf(&buf, "\\u%04x", uc)
-			} else {
-				buf.WriteRune(c)
+			if !moreAvailable {
+				state = XML_STREAM_TRAILER
 			}
 
 		}
 
+	case XML_STREAM_TRAILER:
+		this.generateClosingTag("trkseg")
+		this.generateClosingTag("trk")
+		this.generateClosingTag("gpx")
+		state = XML_STREAM_EOF
+	case XML_STREAM_EOF:
+		errResult = io.EOF
+	default:
+		errResult = fmt.Errorf("%s", "Error during XML serialization.")
 	}
 
-	buf.WriteRune('"')
-	result := buf.String()
-	return result
+	this.state = state
+	return errResult
 }
 
 /*
  * Implements the Read function from io.ReadCloser.
  */
-func (this *databaseJsonSerializerStruct) Read(buf []byte) (int, error) {
+func (this *databaseXmlSerializerStruct) Read(buf []byte) (int, error) {
 	numBytesRead := 0
 	errResult := error(nil)
 	this.mutex.Lock()
@@ -1723,7 +4761,7 @@ func (this *databaseJsonSerializerStruct) Read(buf []byte) (int, error) {
 	 * Check if serializer is already closed.
 	 */
 	if db == nil {
-		errResult = fmt.Errorf("%s", "Database serializer is already closed.")
+		errResult = createError(ErrClosed, "%s", "Database serializer is already closed.")
 	} else {
 		buffer := this.buffer
 		numBytesAvailable := buffer.Len()
@@ -1731,10 +4769,10 @@ func (this *databaseJsonSerializerStruct) Read(buf []byte) (int, error) {
 		err := error(nil)
 
 		/*
-		 * Generate JSON until enough data is available or error occurs.
+		 * Generate XML until enough data is available or error occurs.
 		 */
 		for (numBytesAvailable < numBytesToRead) && (err == nil) {
-			err = this.generateJSON()
+			err = this.generateXML()
 			numBytesAvailable = buffer.Len()
 		}
 
@@ -1780,7 +4818,7 @@ func (this *databaseJsonSerializerStruct) Read(buf []byte) (int, error) {
  *
  * This will yield the read lock on the underlying database.
  */
-func (this *databaseJsonSerializerStruct) Close() error {
+func (this *databaseXmlSerializerStruct) Close() error {
 	result := error(nil)
 	this.mutex.Lock()
 	db := this.db
@@ -1789,9 +4827,9 @@ func (this *databaseJsonSerializerStruct) Close() error {
 	 * Check if serializer is already closed.
 	 */
 	if db == nil {
-		result = fmt.Errorf("%s", "Database serializer is already closed.")
+		result = createError(ErrClosed, "%s", "Database serializer is already closed.")
 	} else {
-		db.mutex.RUnlock()
+		db.snapshotLock.RUnlock()
 		this.db = nil
 	}
 
@@ -1802,7 +4840,7 @@ func (this *databaseJsonSerializerStruct) Close() error {
 /*
  * Change the indentation depth.
  */
-func (this *databaseXmlSerializerStruct) changeIndent(direction int) {
+func (this *databaseKmlSerializerStruct) changeIndent(direction int) {
 	indent := this.indent
 
 	/*
@@ -1837,11 +4875,11 @@ func (this *databaseXmlSerializerStruct) changeIndent(direction int) {
 /*
  * Begins a new line, including indentation.
  */
-func (this *databaseXmlSerializerStruct) startLine(indentationDirection int) {
+func (this *databaseKmlSerializerStruct) startLine(indentationDirection int) {
 	pretty := this.pretty
 
 	/*
-	 * Only do this when pretty-printing XML.
+	 * Only do this when pretty-printing KML.
 	 */
 	if pretty {
 		this.changeIndent(indentationDirection)
@@ -1872,7 +4910,7 @@ func (this *databaseXmlSerializerStruct) startLine(indentationDirection int) {
 /*
  * Write XML declaration.
  */
-func (this *databaseXmlSerializerStruct) generateXMLDeclaration() {
+func (this *databaseKmlSerializerStruct) generateXMLDeclaration() {
 	buffer := this.buffer
 	buffer.WriteString("<?xml version=\"1.0\" encoding=\"UTF-8\"?>")
 	this.startLine(XML_INDENT_NONE)
@@ -1881,7 +4919,7 @@ func (this *databaseXmlSerializerStruct) generateXMLDeclaration() {
 /*
  * Generate an opening tag, which will include inner tags.
  */
-func (this *databaseXmlSerializerStruct) generateOpeningTag(name string, attributes ...keyValuePairStruct) {
+func (this *databaseKmlSerializerStruct) generateOpeningTag(name string, attributes ...keyValuePairStruct) {
 	buffer := this.buffer
 	buffer.WriteRune('<')
 	buffer.WriteString(name)
@@ -1906,7 +4944,7 @@ func (this *databaseXmlSerializerStruct) generateOpeningTag(name string, attribu
 /*
  * Generate a closing tag.
  */
-func (this *databaseXmlSerializerStruct) generateClosingTag(name string) {
+func (this *databaseKmlSerializerStruct) generateClosingTag(name string) {
 	this.startLine(XML_INDENT_OUT)
 	buffer := this.buffer
 	buffer.WriteString("</")
@@ -1917,7 +4955,7 @@ func (this *databaseXmlSerializerStruct) generateClosingTag(name string) {
 /*
  * Generate a tag pair with a value text.
  */
-func (this *databaseXmlSerializerStruct) generateTagPairWithValueText(name string, vt string) {
+func (this *databaseKmlSerializerStruct) generateTagPairWithValueText(name string, vt string) {
 	buffer := this.buffer
 	buffer.WriteRune('<')
 	buffer.WriteString(name)
@@ -1931,7 +4969,7 @@ func (this *databaseXmlSerializerStruct) generateTagPairWithValueText(name strin
 /*
  * Format fixed-point value with E7 exponent as string.
  */
-func (this *databaseXmlSerializerStruct) formatFixedE7(valueE7 int32) string {
+func (this *databaseKmlSerializerStruct) formatFixedE7(valueE7 int32) string {
 	result := "<INVALID>"
 	buf := fmt.Sprintf("%+09d", valueE7)
 	bufSize := len(buf)
@@ -1974,9 +5012,32 @@ func (this *databaseXmlSerializerStruct) formatFixedE7(valueE7 int32) string {
 }
 
 /*
- * Format timestamp as string value.
+ * Applies the stride and the transform of the serializer to a location.
+ *
+ * Returns whether the location shall be serialized.
  */
-func (this *databaseXmlSerializerStruct) formatTimestamp(timestamp uint64) string {
+func (this *databaseKmlSerializerStruct) applyTransform(entryId uint32, loc *Location) bool {
+	stride := this.stride
+	transform := this.transform
+	result := true
+
+	/*
+	 * Skip entries between strides and only apply transform if there is
+	 * one.
+	 */
+	if (stride > 1) && ((entryId % stride) != 0) {
+		result = false
+	} else if transform != nil {
+		result = transform(loc)
+	}
+
+	return result
+}
+
+/*
+ * Format timestamp as string value in UTC.
+ */
+func (this *databaseKmlSerializerStruct) formatTimestamp(timestamp uint64) string {
 	timestampSigned := int64(timestamp)
 	t := time.UnixMilli(timestampSigned)
 	utcTime := t.UTC()
@@ -1985,9 +5046,13 @@ func (this *databaseXmlSerializerStruct) formatTimestamp(timestamp uint64) strin
 }
 
 /*
- * Generate XML data for next entry in geographical database.
+ * Generate KML data for next entry in geographical database.
+ *
+ * While serializing time stamps, this emits a 'when' element. While
+ * serializing coordinates, this emits a 'gx:coord' element. Both passes skip
+ * the same entries.
  */
-func (this *databaseXmlSerializerStruct) generateXMLForNextEntry() error {
+func (this *databaseKmlSerializerStruct) generateKMLForNextEntry() error {
 	errResult := error(nil)
 	moreAvailable := this.hasMoreEntries()
 
@@ -2001,10 +5066,12 @@ func (this *databaseXmlSerializerStruct) generateXMLForNextEntry() error {
 		fd := db.fd
 		endianness := binary.BigEndian
 		offset := uint64(entryId)
-		offsetBytes := SIZE_DATABASE_HEADER + (SIZE_DATABASE_ENTRY * offset)
+		entrySize := db.entrySize
+		offsetBytes := SIZE_DATABASE_HEADER + (entrySize * offset)
 		offsetBytesSigned := int64(offsetBytes)
 		bufRead := make([]byte, SIZE_DATABASE_ENTRY)
-		numBytesRead, err := fd.ReadAt(bufRead, offsetBytesSigned)
+		bufEntry := bufRead[0:entrySize]
+		numBytesRead, err := fd.ReadAt(bufEntry, offsetBytesSigned)
 
 		/*
 		 * If we read less bytes than expected, zero out part of the
@@ -2035,38 +5102,58 @@ func (this *databaseXmlSerializerStruct) generateXMLForNextEntry() error {
 			 * Check if database entry could be deserialized.
 			 */
 			if err != nil {
-				errResult = fmt.Errorf("Error deserializing entry at offset: 0x%016x", offsetBytes)
+				errResult = createError(ErrCorrupt, "Error deserializing entry at offset: 0x%016x", offsetBytes)
 			} else {
 				timestampMSB := entry.TimestampMSB
 				timestampMSB64 := uint64(timestampMSB)
 				timestampLSB := entry.TimestampLSB
 				timestampLSB64 := uint64(timestampLSB)
 				timestamp := (timestampMSB64 << 32) | timestampLSB64
-				latitudeE7 := entry.LatitudeE7
-				longitudeE7 := entry.LongitudeE7
-				timestampString := this.formatTimestamp(timestamp)
-				latitudeString := this.formatFixedE7(latitudeE7)
-				longitudeString := this.formatFixedE7(longitudeE7)
 
 				/*
-				 * Create latitude attribute.
+				 * Create location.
 				 */
-				attrLatitude := keyValuePairStruct{
-					key:   "lat",
-					value: latitudeString,
+				loc := Location{
+					Timestamp:   timestamp,
+					LatitudeE7:  entry.LatitudeE7,
+					LongitudeE7: entry.LongitudeE7,
+					Accuracy:    entry.Accuracy,
 				}
 
+				keep := this.applyTransform(entryId, &loc)
+
 				/*
-				 * Create longitude attribute.
+				 * Only serialize locations which were not omitted by
+				 * the transform.
 				 */
-				attrLongitude := keyValuePairStruct{
-					key:   "lon",
-					value: longitudeString,
+				if keep {
+					entriesWritten := this.entriesWritten
+
+					/*
+					 * Start new line for all but the first entry.
+					 */
+					if entriesWritten > 0 {
+						this.startLine(XML_INDENT_NONE)
+					}
+
+					state := this.state
+
+					/*
+					 * Emit either time stamp or coordinates.
+					 */
+					if state == KML_STREAM_TIMES {
+						timestampString := this.formatTimestamp(loc.Timestamp)
+						this.generateTagPairWithValueText("when", timestampString)
+					} else {
+						longitudeString := this.formatFixedE7(loc.LongitudeE7)
+						latitudeString := this.formatFixedE7(loc.LatitudeE7)
+						coordString := longitudeString + " " + latitudeString + " 0"
+						this.generateTagPairWithValueText("gx:coord", coordString)
+					}
+
+					this.entriesWritten = entriesWritten + 1
 				}
 
-				this.generateOpeningTag("trkpt", attrLatitude, attrLongitude)
-				this.generateTagPairWithValueText("time", timestampString)
-				this.generateClosingTag("trkpt")
 			}
 		}
 
@@ -2080,39 +5167,47 @@ func (this *databaseXmlSerializerStruct) generateXMLForNextEntry() error {
 /*
  * Returns whether there are more entries in the database to be serialized.
  */
-func (this *databaseXmlSerializerStruct) hasMoreEntries() bool {
-	db := this.db
+func (this *databaseKmlSerializerStruct) hasMoreEntries() bool {
 	entryId := this.entryId
-	locationCount := db.locationCount
+	locationCount := this.locationCount
 	result := entryId < locationCount
 	return result
 }
 
 /*
- * Generate more XML data.
+ * Generate more KML data.
  */
-func (this *databaseXmlSerializerStruct) generateXML() error {
+func (this *databaseKmlSerializerStruct) generateKML() error {
 	state := this.state
 	errResult := error(nil)
 
 	switch state {
-	case XML_STREAM_HEADER:
+	case KML_STREAM_HEADER:
 		this.generateXMLDeclaration()
 
 		/*
-		 * Create attribute for GPX version.
+		 * Create attribute for KML namespace.
 		 */
-		attrVersion := keyValuePairStruct{
-			key:   "version",
-			value: "1.1",
+		attrNamespace := keyValuePairStruct{
+			key:   "xmlns",
+			value: "http://www.opengis.net/kml/2.2",
 		}
 
-		this.generateOpeningTag("gpx", attrVersion)
-		this.generateOpeningTag("trk")
-		this.generateOpeningTag("trkseg")
-		state = XML_STREAM_ENTRIES
-	case XML_STREAM_ENTRIES:
-		err := this.generateXMLForNextEntry()
+		/*
+		 * Create attribute for namespace of Google extensions.
+		 */
+		attrNamespaceGx := keyValuePairStruct{
+			key:   "xmlns:gx",
+			value: "http://www.google.com/kml/ext/2.2",
+		}
+
+		this.generateOpeningTag("kml", attrNamespace, attrNamespaceGx)
+		this.generateOpeningTag("Document")
+		this.generateOpeningTag("Placemark")
+		this.generateOpeningTag("gx:Track")
+		state = KML_STREAM_TIMES
+	case KML_STREAM_TIMES, KML_STREAM_COORDINATES:
+		err := this.generateKMLForNextEntry()
 
 		/*
 		 * Check for errors during serialization.
@@ -2120,31 +5215,33 @@ func (this *databaseXmlSerializerStruct) generateXML() error {
 		if err != nil {
 			msg := err.Error()
 			errResult = fmt.Errorf("Error generating entry: %s", msg)
-			state = JSON_STREAM_ERROR
+			state = KML_STREAM_ERROR
 		} else {
 			moreAvailable := this.hasMoreEntries()
 
 			/*
-			 * If there are more entries to be serialized, create new line,
-			 * otherwise transition to serializing the trailer.
+			 * If there are no more entries to be serialized,
+			 * transition to the next section.
 			 */
-			if moreAvailable {
-				this.startLine(XML_INDENT_NONE)
-			} else {
-				state = XML_STREAM_TRAILER
+			if !moreAvailable && (state == KML_STREAM_TIMES) {
+				this.entryId = 0
+				state = KML_STREAM_COORDINATES
+			} else if !moreAvailable {
+				state = KML_STREAM_TRAILER
 			}
 
 		}
 
-	case XML_STREAM_TRAILER:
-		this.generateClosingTag("trkseg")
-		this.generateClosingTag("trk")
-		this.generateClosingTag("gpx")
-		state = XML_STREAM_EOF
-	case XML_STREAM_EOF:
+	case KML_STREAM_TRAILER:
+		this.generateClosingTag("gx:Track")
+		this.generateClosingTag("Placemark")
+		this.generateClosingTag("Document")
+		this.generateClosingTag("kml")
+		state = KML_STREAM_EOF
+	case KML_STREAM_EOF:
 		errResult = io.EOF
 	default:
-		errResult = fmt.Errorf("%s", "Error during XML serialization.")
+		errResult = fmt.Errorf("%s", "Error during KML serialization.")
 	}
 
 	this.state = state
@@ -2154,7 +5251,7 @@ func (this *databaseXmlSerializerStruct) generateXML() error {
 /*
  * Implements the Read function from io.ReadCloser.
  */
-func (this *databaseXmlSerializerStruct) Read(buf []byte) (int, error) {
+func (this *databaseKmlSerializerStruct) Read(buf []byte) (int, error) {
 	numBytesRead := 0
 	errResult := error(nil)
 	this.mutex.Lock()
@@ -2164,7 +5261,7 @@ func (this *databaseXmlSerializerStruct) Read(buf []byte) (int, error) {
 	 * Check if serializer is already closed.
 	 */
 	if db == nil {
-		errResult = fmt.Errorf("%s", "Database serializer is already closed.")
+		errResult = createError(ErrClosed, "%s", "Database serializer is already closed.")
 	} else {
 		buffer := this.buffer
 		numBytesAvailable := buffer.Len()
@@ -2172,10 +5269,10 @@ func (this *databaseXmlSerializerStruct) Read(buf []byte) (int, error) {
 		err := error(nil)
 
 		/*
-		 * Generate XML until enough data is available or error occurs.
+		 * Generate KML until enough data is available or error occurs.
 		 */
 		for (numBytesAvailable < numBytesToRead) && (err == nil) {
-			err = this.generateXML()
+			err = this.generateKML()
 			numBytesAvailable = buffer.Len()
 		}
 
@@ -2221,7 +5318,7 @@ func (this *databaseXmlSerializerStruct) Read(buf []byte) (int, error) {
  *
  * This will yield the read lock on the underlying database.
  */
-func (this *databaseXmlSerializerStruct) Close() error {
+func (this *databaseKmlSerializerStruct) Close() error {
 	result := error(nil)
 	this.mutex.Lock()
 	db := this.db
@@ -2230,9 +5327,9 @@ func (this *databaseXmlSerializerStruct) Close() error {
 	 * Check if serializer is already closed.
 	 */
 	if db == nil {
-		result = fmt.Errorf("%s", "Database serializer is already closed.")
+		result = createError(ErrClosed, "%s", "Database serializer is already closed.")
 	} else {
-		db.mutex.RUnlock()
+		db.snapshotLock.RUnlock()
 		this.db = nil
 	}
 
@@ -2282,6 +5379,24 @@ func (this *databaseSorterStruct) Len() int {
  */
 func (this *databaseSorterStruct) Less(i int, j int) bool {
 	result := false
+	ctx := this.ctx
+	errCtx := ctx.Err()
+
+	/*
+	 * Abort sorting if context was cancelled.
+	 */
+	if errCtx != nil {
+
+		/*
+		 * Signal cancellation.
+		 */
+		cancelled := contextCancelledStruct{
+			cause: errCtx,
+		}
+
+		panic(cancelled)
+	}
+
 	db := this.db
 
 	/*
@@ -2308,8 +5423,10 @@ func (this *databaseSorterStruct) Less(i int, j int) bool {
 		} else {
 			i64 := int64(i)
 			j64 := int64(j)
-			offsetI := SIZE_DATABASE_HEADER + (SIZE_DATABASE_ENTRY * i64)
-			offsetJ := SIZE_DATABASE_HEADER + (SIZE_DATABASE_ENTRY * j64)
+			entrySize := db.entrySize
+			entrySize64 := int64(entrySize)
+			offsetI := SIZE_DATABASE_HEADER + (entrySize64 * i64)
+			offsetJ := SIZE_DATABASE_HEADER + (entrySize64 * j64)
 			arrTimestampI := [SIZE_TIMESTAMP]byte{}
 			arrTimestampJ := [SIZE_TIMESTAMP]byte{}
 			bufTimestampI := arrTimestampI[:]
@@ -2391,12 +5508,15 @@ func (this *databaseSorterStruct) Swap(i int, j int) {
 		} else {
 			i64 := int64(i)
 			j64 := int64(j)
-			offsetI := SIZE_DATABASE_HEADER + (SIZE_DATABASE_ENTRY * i64)
-			offsetJ := SIZE_DATABASE_HEADER + (SIZE_DATABASE_ENTRY * j64)
+			entrySize := db.entrySize
+			entrySize64 := int64(entrySize)
+			entrySizeInt := int(entrySize)
+			offsetI := SIZE_DATABASE_HEADER + (entrySize64 * i64)
+			offsetJ := SIZE_DATABASE_HEADER + (entrySize64 * j64)
 			arrEntryI := [SIZE_DATABASE_ENTRY]byte{}
 			arrEntryJ := [SIZE_DATABASE_ENTRY]byte{}
-			bufEntryI := arrEntryI[:]
-			bufEntryJ := arrEntryJ[:]
+			bufEntryI := arrEntryI[0:entrySize]
+			bufEntryJ := arrEntryJ[0:entrySize]
 			fd := db.fd
 			numBytesI, errI := fd.ReadAt(bufEntryI, offsetI)
 			numBytesJ, errJ := fd.ReadAt(bufEntryJ, offsetJ)
@@ -2404,7 +5524,7 @@ func (this *databaseSorterStruct) Swap(i int, j int) {
 			/*
 			 * Make sure that we read both values.
 			 */
-			if ((errI != nil) || (numBytesI != SIZE_DATABASE_ENTRY)) || ((errJ != nil) || (numBytesJ != SIZE_DATABASE_ENTRY)) {
+			if ((errI != nil) || (numBytesI != entrySizeInt)) || ((errJ != nil) || (numBytesJ != entrySizeInt)) {
 				msg := fmt.Sprintf("Error reading from offsets 0x%016x and 0x%016x!", offsetI, offsetJ)
 				panic(msg)
 			} else {
@@ -2415,7 +5535,7 @@ func (this *databaseSorterStruct) Swap(i int, j int) {
 				/*
 				 * Make sure that we wrote both values.
 				 */
-				if ((errI != nil) || (numBytesI != SIZE_DATABASE_ENTRY)) || ((errJ != nil) || (numBytesJ != SIZE_DATABASE_ENTRY)) {
+				if ((errI != nil) || (numBytesI != entrySizeInt)) || ((errJ != nil) || (numBytesJ != entrySizeInt)) {
 					msg := fmt.Sprintf("Error writing to offsets 0x%016x and 0x%016x! The geo database might have become corrupted.", offsetI, offsetJ)
 					panic(msg)
 				}
@@ -2428,13 +5548,101 @@ func (this *databaseSorterStruct) Swap(i int, j int) {
 
 }
 
+/*
+ * Rounds a fixed-point coordinate to the nearest multiple of a quantum, with
+ * ties rounded away from zero.
+ *
+ * Results which cannot be represented are rounded towards zero instead.
+ */
+func quantizeE7(valueE7 int32, quantum int64) int32 {
+
+	/*
+	 * A quantum of one keeps full precision.
+	 */
+	if quantum <= 1 {
+		return valueE7
+	} else {
+		value := int64(valueE7)
+		magnitude := value
+		half := quantum / 2
+
+		/*
+		 * Round the magnitude, so that both signs are treated alike.
+		 */
+		if value < 0 {
+			magnitude = -value
+		}
+
+		rounded := ((magnitude + half) / quantum) * quantum
+
+		/*
+		 * Stay within the range of the fixed-point value.
+		 */
+		if rounded > math.MaxInt32 {
+			rounded -= quantum
+		}
+
+		/*
+		 * Restore the sign.
+		 */
+		if value < 0 {
+			rounded = -rounded
+		}
+
+		result := int32(rounded)
+		return result
+	}
+
+}
+
+/*
+ * Calculates the great-circle distance in meters between two locations.
+ */
+func distanceMeters(a *Location, b *Location) float64 {
+	latitudeA := DEGREES_E7_TO_RADIANS * float64(a.LatitudeE7)
+	longitudeA := DEGREES_E7_TO_RADIANS * float64(a.LongitudeE7)
+	latitudeB := DEGREES_E7_TO_RADIANS * float64(b.LatitudeE7)
+	longitudeB := DEGREES_E7_TO_RADIANS * float64(b.LongitudeE7)
+	sinHalfDeltaLatitude := math.Sin(0.5 * (latitudeB - latitudeA))
+	sinHalfDeltaLongitude := math.Sin(0.5 * (longitudeB - longitudeA))
+	cosLatitudeA := math.Cos(latitudeA)
+	cosLatitudeB := math.Cos(latitudeB)
+	h := (sinHalfDeltaLatitude * sinHalfDeltaLatitude) + (cosLatitudeA * cosLatitudeB * sinHalfDeltaLongitude * sinHalfDeltaLongitude)
+	h = math.Min(h, 1.0)
+	sqrtH := math.Sqrt(h)
+	angle := 2.0 * math.Asin(sqrtH)
+	result := EARTH_RADIUS_METERS * angle
+	return result
+}
+
+/*
+ * Creates an error belonging to a category, with a formatted message.
+ */
+func createError(kind error, format string, args ...interface{}) error {
+	msg := fmt.Sprintf(format, args...)
+
+	/*
+	 * Create database error.
+	 */
+	err := databaseErrorStruct{
+		kind: kind,
+		msg:  msg,
+	}
+
+	return &err
+}
+
 /*
  * Prepare storage for accessing geographic data, either by writing a new
  * header to an empty file or verifying the header of an already pre-filled
  * file.
+ *
+ * Returns the file size and the size of each entry, which depends on the
+ * version of the database.
  */
-func prepareStorage(fd Storage) (int64, error) {
+func prepareStorage(fd Storage) (int64, uint64, error) {
 	fileSize := int64(0)
+	entrySize := uint64(SIZE_DATABASE_ENTRY)
 	errResult := error(nil)
 
 	/*
@@ -2466,7 +5674,7 @@ func prepareStorage(fd Storage) (int64, error) {
 				 * Check file size.
 				 */
 				if (fileSize != 0) && (fileSize < SIZE_DATABASE_HEADER) {
-					errResult = fmt.Errorf("Illegal file size: Expected either zero or at least %d, but was %d.", SIZE_DATABASE_HEADER, fileSize)
+					errResult = createError(ErrCorrupt, "Illegal file size: Expected either zero or at least %d, but was %d.", SIZE_DATABASE_HEADER, fileSize)
 				} else {
 					posStart, err := fd.Seek(0, io.SeekStart)
 
@@ -2551,9 +5759,11 @@ func prepareStorage(fd Storage) (int64, error) {
 									reason := err.Error()
 									errResult = fmt.Errorf("Failed to read database header: %s", reason)
 								} else if hdrMagic != MAGIC_NUMBER {
-									errResult = fmt.Errorf("File is not a geographical database. Expected magic number 0x%016x, but found 0x%016x.", MAGIC_NUMBER, hdrMagic)
-								} else if (hdrVersionMajor != VERSION_MAJOR) || (hdrVersionMinor < VERSION_MINOR) {
-									errResult = fmt.Errorf("File is in version %d.%d, but we expect %d.x (at least %d.%d).", hdrVersionMajor, hdrVersionMinor, VERSION_MAJOR, VERSION_MAJOR, VERSION_MINOR)
+									errResult = createError(ErrCorrupt, "File is not a geographical database. Expected magic number 0x%016x, but found 0x%016x.", MAGIC_NUMBER, hdrMagic)
+								} else if hdrVersionMajor != VERSION_MAJOR {
+									errResult = fmt.Errorf("File is in version %d.%d, but we expect %d.x.", hdrVersionMajor, hdrVersionMinor, VERSION_MAJOR)
+								} else if hdrVersionMinor < VERSION_MINOR {
+									entrySize = SIZE_DATABASE_ENTRY_LEGACY
 								}
 
 							}
@@ -2582,7 +5792,7 @@ func prepareStorage(fd Storage) (int64, error) {
 
 	}
 
-	return fileSize, errResult
+	return fileSize, entrySize, errResult
 }
 
 /*
@@ -2592,14 +5802,13 @@ func prepareStorage(fd Storage) (int64, error) {
 func Create(fd Storage) (Database, error) {
 	result := (*databaseStruct)(nil)
 	errResult := error(nil)
-	fileSize, err := prepareStorage(fd)
+	fileSize, entrySize, err := prepareStorage(fd)
 
 	/*
 	 * Check if storage was prepared.
 	 */
 	if err != nil {
-		reason := err.Error()
-		errResult = fmt.Errorf("Failed to prepare storage: %s", reason)
+		errResult = fmt.Errorf("Failed to prepare storage: %w", err)
 	} else {
 		fileSize64 := uint64(fileSize)
 		locationCount := uint32(0)
@@ -2608,7 +5817,7 @@ func Create(fd Storage) (Database, error) {
 		 * Calculate location count.
 		 */
 		if fileSize64 >= SIZE_DATABASE_HEADER {
-			locationCount64 := (fileSize64 - SIZE_DATABASE_HEADER) / SIZE_DATABASE_ENTRY
+			locationCount64 := (fileSize64 - SIZE_DATABASE_HEADER) / entrySize
 
 			/*
 			 * Limit to 32 bit field.
@@ -2621,12 +5830,19 @@ func Create(fd Storage) (Database, error) {
 
 		}
 
+		creationTime := time.Now()
+		creationTimeNanos := creationTime.UnixNano()
+		revision := uint64(creationTimeNanos)
+
 		/*
 		 * Create database accessor.
 		 */
 		result = &databaseStruct{
 			fd:            fd,
+			entrySize:     entrySize,
 			locationCount: locationCount,
+			quantum:       1,
+			revision:      revision,
 		}
 
 	}
@@ -3,6 +3,7 @@ package geojson
 import (
 	"encoding/json"
 	"fmt"
+	"math"
 	"strconv"
 	"time"
 
@@ -13,6 +14,7 @@ import (
  * Data structure representing a GeoJSON location.
  */
 type locationStruct struct {
+	AccuracyM    int32  `json:"accuracy"`
 	LatitudeE7   int32  `json:"latitudeE7"`
 	LongitudeE7  int32  `json:"longitudeE7"`
 	TimestampMs  string `json:"timestampMs"`
@@ -26,6 +28,27 @@ type databaseStruct struct {
 	Locations []locationStruct `json:"locations"`
 }
 
+/*
+ * Returns the horizontal accuracy (in meters) of this location.
+ *
+ * Values which do not fit into 16 bits are clamped.
+ */
+func (this *locationStruct) Accuracy() uint16 {
+	accuracyM := this.AccuracyM
+	accuracy := uint16(0)
+
+	/*
+	 * Clamp accuracy to the range which can be stored.
+	 */
+	if accuracyM > math.MaxUint16 {
+		accuracy = math.MaxUint16
+	} else if accuracyM > 0 {
+		accuracy = uint16(accuracyM)
+	}
+
+	return accuracy
+}
+
 /*
  * Returns the latitude of this location.
  */
@@ -108,6 +131,15 @@ func (this *databaseStruct) LocationCount() int {
 	return numLocs
 }
 
+/*
+ * The number of records skipped while parsing, since they could not be parsed.
+ *
+ * This parser never skips records, so this is always zero.
+ */
+func (this *databaseStruct) SkippedCount() int {
+	return 0
+}
+
 /*
  * Create GeoJSON database from byte slice.
  */
@@ -0,0 +1,608 @@
+package geokml
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/andrepxx/location-visualizer/geo"
+)
+
+/*
+ * Data structure representing a time stamp in XML.
+ */
+type xmlTimeStampStruct struct {
+	When string `xml:"when"`
+}
+
+/*
+ * Data structure representing the coordinates of a point or a line string
+ * in XML.
+ */
+type xmlCoordinatesStruct struct {
+	Coordinates string `xml:"coordinates"`
+}
+
+/*
+ * Data structure representing a track (gx:Track) in XML.
+ *
+ * The n-th time stamp belongs to the n-th coordinate.
+ */
+type xmlTrackStruct struct {
+	When  []string `xml:"when"`
+	Coord []string `xml:"coord"`
+}
+
+/*
+ * Data structure representing geometries in XML.
+ *
+ * This is used for placemarks, multi-geometries and multi-tracks.
+ */
+type xmlGeometryStruct struct {
+	Points          []xmlCoordinatesStruct `xml:"Point"`
+	LineStrings     []xmlCoordinatesStruct `xml:"LineString"`
+	Tracks          []xmlTrackStruct       `xml:"Track"`
+	MultiTracks     []xmlGeometryStruct    `xml:"MultiTrack"`
+	MultiGeometries []xmlGeometryStruct    `xml:"MultiGeometry"`
+}
+
+/*
+ * Data structure representing a placemark in XML.
+ */
+type xmlPlacemarkStruct struct {
+	TimeStamp xmlTimeStampStruct `xml:"TimeStamp"`
+	xmlGeometryStruct
+}
+
+/*
+ * Data structure representing a document or folder in XML.
+ */
+type xmlContainerStruct struct {
+	Documents  []xmlContainerStruct `xml:"Document"`
+	Folders    []xmlContainerStruct `xml:"Folder"`
+	Placemarks []xmlPlacemarkStruct `xml:"Placemark"`
+}
+
+/*
+ * Data structure representing the XML root element.
+ */
+type xmlRootStruct struct {
+	XMLName    xml.Name             `xml:"kml"`
+	Documents  []xmlContainerStruct `xml:"Document"`
+	Folders    []xmlContainerStruct `xml:"Folder"`
+	Placemarks []xmlPlacemarkStruct `xml:"Placemark"`
+}
+
+/*
+ * Data structure representing a KML location.
+ */
+type locationStruct struct {
+	timestamp   uint64
+	latitudeE7  int32
+	longitudeE7 int32
+}
+
+/*
+ * Data structure representing a location database imported from KML.
+ */
+type databaseStruct struct {
+	locations        []locationStruct
+	skipped          int
+	errFirst         error
+	defaultTimestamp uint64
+	hasDefault       bool
+}
+
+/*
+ * Returns the horizontal accuracy (in meters) of this location.
+ *
+ * KML does not provide the accuracy, so this is always zero.
+ */
+func (this *locationStruct) Accuracy() uint16 {
+	return 0
+}
+
+/*
+ * Returns the latitude of this location.
+ */
+func (this *locationStruct) Latitude() int32 {
+	latitudeE7 := this.latitudeE7
+	return latitudeE7
+}
+
+/*
+ * Returns the longitude of this location.
+ */
+func (this *locationStruct) Longitude() int32 {
+	longitudeE7 := this.longitudeE7
+	return longitudeE7
+}
+
+/*
+ * Returns the timestamp (in milliseconds since the Epoch) when
+ * this location was recorded.
+ */
+func (this *locationStruct) Timestamp() uint64 {
+	timestamp := this.timestamp
+	return timestamp
+}
+
+/*
+ * Parse 32-bit fixed-point number.
+ */
+func parseFixed32(number string, decimalPlaces uint8) (int32, error) {
+	numberTrimmed := strings.TrimSpace(number)
+	integerPartString, fractionalPartString, hasFractionalPart := strings.Cut(numberTrimmed, ".")
+	negativeNumber := strings.HasPrefix(integerPartString, "-")
+	value, err := strconv.ParseInt(integerPartString, 10, 32)
+
+	/*
+	 * Check if we could parse the integer part of the number.
+	 */
+	if err != nil {
+		return 0, fmt.Errorf("%s", "Parse error")
+	} else {
+
+		/*
+		 * Shift value by the required number of decimal places.
+		 */
+		for i := uint8(0); i < decimalPlaces; i++ {
+			value *= 10
+		}
+
+		/*
+		 * Handle fractional part, if present.
+		 */
+		if hasFractionalPart {
+			lenFractionalPart := len(fractionalPartString)
+			decimalPlacesInt := int(decimalPlaces)
+
+			/*
+			 * If fractional part is longer than number of decimal places, trim it.
+			 */
+			if lenFractionalPart > decimalPlacesInt {
+				fractionalPartString = fractionalPartString[:decimalPlacesInt]
+				lenFractionalPart = decimalPlacesInt
+			}
+
+			fractionalPart, err := strconv.ParseUint(fractionalPartString, 10, 32)
+
+			/*
+			 * Check if we could parse the fractional part of the number.
+			 */
+			if err != nil {
+				return 0, fmt.Errorf("%s", "Parse error")
+			} else {
+
+				/*
+				 * Shift the fractional part in case it's too short.
+				 */
+				for i := lenFractionalPart; i < decimalPlacesInt; i++ {
+					fractionalPart *= 10
+				}
+
+				fractionalPartSigned := int64(fractionalPart)
+
+				/*
+				 * Subtract or add fractional part from or to value.
+				 */
+				if negativeNumber {
+					value -= fractionalPartSigned
+				} else {
+					value += fractionalPartSigned
+				}
+
+			}
+
+		}
+
+		result := int32(value)
+		return result, nil
+	}
+
+}
+
+/*
+ * Parses a time stamp from a 'when' element.
+ *
+ * Time stamps without a time zone are taken as UTC. Time stamps consisting of
+ * a date only refer to the beginning of that day.
+ */
+func (this *databaseStruct) parseTimestamp(timestampString string) (uint64, error) {
+	timestampString = strings.TrimSpace(timestampString)
+	layouts := []string{time.RFC3339Nano, "2006-01-02T15:04:05.999999999", "2006-01-02"}
+	location := time.UTC
+	parsedTime := time.Time{}
+	err := fmt.Errorf("Failed to parse time stamp '%s'.", timestampString)
+
+	/*
+	 * Try all supported layouts until one matches.
+	 */
+	for _, layout := range layouts {
+
+		/*
+		 * Only try further layouts if no match was found yet.
+		 */
+		if err != nil {
+			t, errParse := time.ParseInLocation(layout, timestampString, location)
+
+			/*
+			 * ParseInLocation does not specify the result on error.
+			 */
+			if errParse == nil {
+				parsedTime = t
+				err = nil
+			}
+
+		}
+
+	}
+
+	/*
+	 * Check if time stamp could be parsed.
+	 */
+	if err != nil {
+		return 0, err
+	} else {
+		unixMs := parsedTime.UnixMilli()
+
+		/*
+		 * Locations cannot be recorded before the Epoch.
+		 */
+		if unixMs < 0 {
+			return 0, fmt.Errorf("Time stamp '%s' lies before the Epoch.", timestampString)
+		} else {
+			timestamp := uint64(unixMs)
+			return timestamp, nil
+		}
+
+	}
+
+}
+
+/*
+ * Adds a location to the database.
+ *
+ * Locations which cannot be parsed are skipped and counted. The same applies
+ * to locations without a time stamp, unless a default time stamp is set.
+ */
+func (this *databaseStruct) addLocation(longitudeString string, latitudeString string, timestampString string) {
+	latitudeE7, errLatitude := parseFixed32(latitudeString, 7)
+	longitudeE7, errLongitude := parseFixed32(longitudeString, 7)
+	timestamp := this.defaultTimestamp
+	errTimestamp := error(nil)
+	hasTimestamp := timestampString != ""
+	errLocation := error(nil)
+
+	/*
+	 * Parse time stamp if present.
+	 */
+	if hasTimestamp {
+		timestamp, errTimestamp = this.parseTimestamp(timestampString)
+	}
+
+	/*
+	 * Check for parse errors.
+	 */
+	if errLatitude != nil {
+		errLocation = fmt.Errorf("Failed to parse latitude '%s'.", latitudeString)
+	} else if (latitudeE7 < -900000000) || (latitudeE7 > 900000000) {
+		errLocation = fmt.Errorf("Latitude '%s' is out of range.", latitudeString)
+	} else if errLongitude != nil {
+		errLocation = fmt.Errorf("Failed to parse longitude '%s'.", longitudeString)
+	} else if (longitudeE7 < -1800000000) || (longitudeE7 > 1800000000) {
+		errLocation = fmt.Errorf("Longitude '%s' is out of range.", longitudeString)
+	} else if errTimestamp != nil {
+		errLocation = errTimestamp
+	} else if !hasTimestamp && !this.hasDefault {
+		errLocation = fmt.Errorf("%s", "Location has no time stamp.")
+	} else {
+
+		/*
+		 * Create location.
+		 */
+		loc := locationStruct{
+			timestamp:   timestamp,
+			latitudeE7:  latitudeE7,
+			longitudeE7: longitudeE7,
+		}
+
+		this.locations = append(this.locations, loc)
+	}
+
+	/*
+	 * Skip locations which could not be parsed, but keep the first error.
+	 */
+	if errLocation != nil {
+		this.skipped++
+
+		/*
+		 * Store the first parse error.
+		 */
+		if this.errFirst == nil {
+			this.errFirst = errLocation
+		}
+
+	}
+
+}
+
+/*
+ * Adds the locations from the content of a 'coordinates' element.
+ *
+ * The content consists of tuples of longitude, latitude and optional altitude,
+ * separated by commas. Tuples are separated by white space. All of them share
+ * the same time stamp.
+ */
+func (this *databaseStruct) addCoordinates(coordinates string, timestampString string) {
+	tuples := strings.Fields(coordinates)
+
+	/*
+	 * Iterate over the tuples.
+	 */
+	for _, tuple := range tuples {
+		fields := strings.Split(tuple, ",")
+		numFields := len(fields)
+
+		/*
+		 * Check if tuple has the expected number of fields.
+		 */
+		if (numFields != 2) && (numFields != 3) {
+			this.skipped++
+
+			/*
+			 * Store the first parse error.
+			 */
+			if this.errFirst == nil {
+				this.errFirst = fmt.Errorf("Expected %d or %d fields in coordinates '%s', but found %d.", 2, 3, tuple, numFields)
+			}
+
+		} else {
+			longitudeString := fields[0]
+			latitudeString := fields[1]
+			this.addLocation(longitudeString, latitudeString, timestampString)
+		}
+
+	}
+
+}
+
+/*
+ * Adds the locations from a track.
+ *
+ * Each 'coord' element consists of longitude, latitude and optional altitude,
+ * separated by white space.
+ */
+func (this *databaseStruct) addTrack(track *xmlTrackStruct) {
+	whens := track.When
+	numWhens := len(whens)
+	coords := track.Coord
+
+	/*
+	 * Iterate over the coordinates.
+	 */
+	for i, coord := range coords {
+		fields := strings.Fields(coord)
+		numFields := len(fields)
+		timestampString := ""
+
+		/*
+		 * Find the time stamp belonging to the coordinates.
+		 */
+		if i < numWhens {
+			timestampString = whens[i]
+		}
+
+		/*
+		 * Check if coordinates have the expected number of fields.
+		 */
+		if (numFields != 2) && (numFields != 3) {
+			this.skipped++
+
+			/*
+			 * Store the first parse error.
+			 */
+			if this.errFirst == nil {
+				this.errFirst = fmt.Errorf("Expected %d or %d fields in coordinates '%s', but found %d.", 2, 3, coord, numFields)
+			}
+
+		} else {
+			longitudeString := fields[0]
+			latitudeString := fields[1]
+			this.addLocation(longitudeString, latitudeString, timestampString)
+		}
+
+	}
+
+}
+
+/*
+ * Adds the locations from geometries, which may be nested.
+ *
+ * Points and line strings carry the time stamp of the enclosing placemark,
+ * while tracks carry their own time stamps.
+ */
+func (this *databaseStruct) addGeometry(geometry *xmlGeometryStruct, timestampString string) {
+
+	/*
+	 * Iterate over the points.
+	 */
+	for i := range geometry.Points {
+		point := &geometry.Points[i]
+		this.addCoordinates(point.Coordinates, timestampString)
+	}
+
+	/*
+	 * Iterate over the line strings.
+	 */
+	for i := range geometry.LineStrings {
+		lineString := &geometry.LineStrings[i]
+		this.addCoordinates(lineString.Coordinates, timestampString)
+	}
+
+	/*
+	 * Iterate over the tracks.
+	 */
+	for i := range geometry.Tracks {
+		track := &geometry.Tracks[i]
+		this.addTrack(track)
+	}
+
+	/*
+	 * Iterate over the multi-tracks.
+	 */
+	for i := range geometry.MultiTracks {
+		multiTrack := &geometry.MultiTracks[i]
+		this.addGeometry(multiTrack, timestampString)
+	}
+
+	/*
+	 * Iterate over the multi-geometries.
+	 */
+	for i := range geometry.MultiGeometries {
+		multiGeometry := &geometry.MultiGeometries[i]
+		this.addGeometry(multiGeometry, timestampString)
+	}
+
+}
+
+/*
+ * Adds the locations from placemarks and from documents and folders, which
+ * may be nested.
+ */
+func (this *databaseStruct) addPlacemarks(documents []xmlContainerStruct, folders []xmlContainerStruct, placemarks []xmlPlacemarkStruct) {
+
+	/*
+	 * Iterate over the placemarks.
+	 */
+	for i := range placemarks {
+		placemark := &placemarks[i]
+		timestampString := placemark.TimeStamp.When
+		timestampString = strings.TrimSpace(timestampString)
+		this.addGeometry(&placemark.xmlGeometryStruct, timestampString)
+	}
+
+	/*
+	 * Iterate over the documents.
+	 */
+	for i := range documents {
+		document := &documents[i]
+		this.addPlacemarks(document.Documents, document.Folders, document.Placemarks)
+	}
+
+	/*
+	 * Iterate over the folders.
+	 */
+	for i := range folders {
+		folder := &folders[i]
+		this.addPlacemarks(folder.Documents, folder.Folders, folder.Placemarks)
+	}
+
+}
+
+/*
+ * The location stored at the given index in this database.
+ */
+func (this *databaseStruct) LocationAt(idx int) (geo.Location, error) {
+	locs := this.locations
+	numLocs := len(locs)
+
+	/*
+	 * Check if index is in valid range.
+	 */
+	if (idx < 0) || (idx >= numLocs) {
+		lastIdx := numLocs - 1
+		return nil, fmt.Errorf("Index must be in [%d, %d].", 0, lastIdx)
+	} else {
+		ptr := &locs[idx]
+		return ptr, nil
+	}
+
+}
+
+/*
+ * The number of locations stored in this database.
+ */
+func (this *databaseStruct) LocationCount() int {
+	locs := this.locations
+	numLocs := len(locs)
+	return numLocs
+}
+
+/*
+ * The number of locations skipped while parsing, since they could not be
+ * parsed or had no time stamp.
+ */
+func (this *databaseStruct) SkippedCount() int {
+	skipped := this.skipped
+	return skipped
+}
+
+/*
+ * Internal function to create KML database from byte slice.
+ */
+func fromBytes(data []byte, defaultTimestamp uint64, hasDefault bool) (geo.Database, error) {
+	root := xmlRootStruct{}
+	err := xml.Unmarshal(data, &root)
+
+	/*
+	 * Check if an error occured during unmarshalling.
+	 */
+	if err != nil {
+		msg := err.Error()
+		return nil, fmt.Errorf("Error occured during unmarshalling: %s", msg)
+	} else {
+
+		/*
+		 * Create new database.
+		 */
+		db := databaseStruct{
+			locations:        []locationStruct{},
+			skipped:          0,
+			errFirst:         nil,
+			defaultTimestamp: defaultTimestamp,
+			hasDefault:       hasDefault,
+		}
+
+		db.addPlacemarks(root.Documents, root.Folders, root.Placemarks)
+		numLocations := len(db.locations)
+		errFirst := db.errFirst
+
+		/*
+		 * Fail if locations were present, but none could be parsed.
+		 */
+		if (numLocations == 0) && (errFirst != nil) {
+			return nil, errFirst
+		} else {
+			return &db, nil
+		}
+
+	}
+
+}
+
+/*
+ * Create KML database from byte slice.
+ *
+ * Time stamps are taken from the 'when' elements of tracks and placemarks.
+ * Locations without a time stamp, as well as locations which cannot be
+ * parsed, are skipped and counted. Parsing only fails if no location could be
+ * parsed at all.
+ */
+func FromBytes(data []byte) (geo.Database, error) {
+	db, err := fromBytes(data, 0, false)
+	return db, err
+}
+
+/*
+ * Create KML database from byte slice, assigning a default time stamp (in
+ * milliseconds since the Epoch) to locations without a time stamp.
+ *
+ * Otherwise, this behaves like FromBytes.
+ */
+func FromBytesWithDefaultTimestamp(data []byte, defaultTimestamp uint64) (geo.Database, error) {
+	db, err := fromBytes(data, defaultTimestamp, true)
+	return db, err
+}
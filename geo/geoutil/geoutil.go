@@ -1,8 +1,10 @@
 package geoutil
 
 import (
+	"context"
 	"fmt"
 	"math"
+	"sort"
 	"time"
 
 	"github.com/andrepxx/location-visualizer/geo"
@@ -11,15 +13,54 @@ import (
 
 const (
 	BLOCK_SIZE                  = 1024
+	BUCKET_DAY                  = 0
+	BUCKET_MONTH                = 2
+	BUCKET_WEEK                 = 1
+	BUCKET_YEAR                 = 3
 	DEGREES_TO_RADIANS          = math.Pi / 180.0
 	DEGREES_E7_TO_RADIANS       = DEGREES_TO_RADIANS * 1e-7
+	EARTH_RADIUS_METERS         = 6371008.8
 	IMPORT_ALL                  = 1
 	IMPORT_NEWER                = 2
 	IMPORT_NONE                 = 0
+	METERS_PER_KILOMETER        = 1000.0
+	MILLISECONDS_PER_HOUR       = 3600000.0
 	MILLISECONDS_PER_SECOND     = 1000
 	NANOSECONDS_PER_MILLISECOND = 1000000
 )
 
+/*
+ * The geographic bounding box of a dataset.
+ *
+ * Coordinates are given in degrees times 10^7. The coordinates are undefined
+ * if the dataset is empty.
+ */
+type Bounds interface {
+	Empty() bool
+	LatitudeMax() int32
+	LatitudeMin() int32
+	LongitudeMax() int32
+	LongitudeMin() int32
+}
+
+/*
+ * A bucket of a histogram, counting the locations from its start (inclusive)
+ * to the start of the next bucket (exclusive).
+ */
+type HistogramBucket struct {
+	Start time.Time
+	Count uint32
+}
+
+/*
+ * A histogram of the number of locations over time.
+ */
+type Histogram interface {
+	Buckets() []HistogramBucket
+	Complete() bool
+	FullScan() bool
+}
+
 /*
  * Statistics for a geographical dataset.
  */
@@ -31,6 +72,14 @@ type DatasetStats interface {
 	TimestampLatest() uint64
 }
 
+/*
+ * Duplicate statistics for a geographical dataset.
+ */
+type DuplicateStats interface {
+	Duplicates() uint32
+	TimestampsUnique() bool
+}
+
 /*
  * A report for a data migration.
  */
@@ -46,10 +95,41 @@ type MigrationReport interface {
  */
 type Util interface {
 	DegreesE7ToRadians(degreesE7 int32) float64
+	DistanceE7(latitudeAE7 int32, longitudeAE7 int32, latitudeBE7 int32, longitudeBE7 int32) float64
+	GeoDBBounds(db geodb.Database) (Bounds, error)
+	GeoDBBoundsSince(db geodb.Database, timestampBegin uint64) (Bounds, error)
+	GeoDBCount(db geodb.Database, timestampBegin uint64, timestampEnd uint64) (uint32, error)
+	GeoDBDistanceKM(db geodb.Database, maxSpeedKMH float64) (float64, error)
+	GeoDBDuplicates(db geodb.Database) (DuplicateStats, error)
+	GeoDBHistogram(db geodb.Database, bucket int, location *time.Location, maxBuckets uint32) (Histogram, error)
+	GeoDBMerge(dst geodb.Database, src geodb.Database) (uint32, error)
+	GeoDBRange(db geodb.Database, timestampBegin uint64, timestampEnd uint64) ([]geodb.Location, error)
 	GeoDBStats(db geodb.Database) (DatasetStats, error)
 	GeoJSONOrGPXStats(db geo.Database) (DatasetStats, error)
-	Migrate(dst geodb.Database, src geo.Database, importStrategy int) (MigrationReport, error)
+	Migrate(ctx context.Context, dst geodb.Database, src geo.Database, importStrategy int, maxLocations uint32) (MigrationReport, error)
 	MillisecondsToTime(ms uint64) time.Time
+	TimeToMilliseconds(t time.Time) uint64
+	TruncateE7(degreesE7 int32, decimals uint8) int32
+}
+
+/*
+ * Data structure representing the bounding box of a geographical dataset.
+ */
+type boundsStruct struct {
+	empty        bool
+	latitudeMax  int32
+	latitudeMin  int32
+	longitudeMax int32
+	longitudeMin int32
+}
+
+/*
+ * Data structure representing a histogram of the number of locations.
+ */
+type histogramStruct struct {
+	buckets  []HistogramBucket
+	complete bool
+	fullScan bool
 }
 
 /*
@@ -63,6 +143,25 @@ type datasetStatsStruct struct {
 	timestampLatest   uint64
 }
 
+/*
+ * Data structure representing the state while summing up the distance
+ * travelled.
+ */
+type distanceStruct struct {
+	maxSpeedKMH float64
+	meters      float64
+	previous    geodb.Location
+	started     bool
+}
+
+/*
+ * Data structure representing duplicate statistics for a geographical dataset.
+ */
+type duplicateStatsStruct struct {
+	duplicates       uint32
+	timestampsUnique bool
+}
+
 /*
  * Data structure representing a migration report.
  */
@@ -79,6 +178,75 @@ type migrationReportStruct struct {
 type utilStruct struct {
 }
 
+/*
+ * Returns whether the data set is empty, i. e. has no bounding box.
+ */
+func (this *boundsStruct) Empty() bool {
+	empty := this.empty
+	return empty
+}
+
+/*
+ * Returns the northernmost latitude in the data set.
+ */
+func (this *boundsStruct) LatitudeMax() int32 {
+	latitudeMax := this.latitudeMax
+	return latitudeMax
+}
+
+/*
+ * Returns the southernmost latitude in the data set.
+ */
+func (this *boundsStruct) LatitudeMin() int32 {
+	latitudeMin := this.latitudeMin
+	return latitudeMin
+}
+
+/*
+ * Returns the easternmost longitude in the data set.
+ */
+func (this *boundsStruct) LongitudeMax() int32 {
+	longitudeMax := this.longitudeMax
+	return longitudeMax
+}
+
+/*
+ * Returns the westernmost longitude in the data set.
+ */
+func (this *boundsStruct) LongitudeMin() int32 {
+	longitudeMin := this.longitudeMin
+	return longitudeMin
+}
+
+/*
+ * Returns the non-empty buckets of the histogram in chronological order.
+ */
+func (this *histogramStruct) Buckets() []HistogramBucket {
+	buckets := this.buckets
+	numBuckets := len(buckets)
+	result := make([]HistogramBucket, numBuckets)
+	copy(result, buckets)
+	return result
+}
+
+/*
+ * Returns whether the histogram contains all non-empty buckets, i. e. its
+ * creation was not stopped early after the maximum number of buckets.
+ */
+func (this *histogramStruct) Complete() bool {
+	complete := this.complete
+	return complete
+}
+
+/*
+ * Returns whether the histogram had to be created by scanning the entire
+ * data set, since it was not ordered by time stamp.
+ */
+func (this *histogramStruct) FullScan() bool {
+	fullScan := this.fullScan
+	return fullScan
+}
+
 /*
  * Returns the number of locations in the data set.
  */
@@ -125,6 +293,25 @@ func (this *datasetStatsStruct) TimestampLatest() uint64 {
 	return timestampLatest
 }
 
+/*
+ * Returns the number of locations which are exact duplicates of another
+ * location in the data set, i. e. the number of locations deduplication
+ * would remove.
+ */
+func (this *duplicateStatsStruct) Duplicates() uint32 {
+	duplicates := this.duplicates
+	return duplicates
+}
+
+/*
+ * Returns whether all time stamps in the data set are unique once duplicates
+ * are removed.
+ */
+func (this *duplicateStatsStruct) TimestampsUnique() bool {
+	timestampsUnique := this.timestampsUnique
+	return timestampsUnique
+}
+
 /*
  * Returns statistics about the state of the target data set after migration
  * was finished.
@@ -160,6 +347,329 @@ func (this *migrationReportStruct) Source() DatasetStats {
 	return source
 }
 
+/*
+ * Returns the start of the bucket containing a point in time.
+ *
+ * Weeks start on Monday.
+ */
+func (this *utilStruct) bucketStart(t time.Time, bucket int) time.Time {
+	location := t.Location()
+	year, month, day := t.Date()
+
+	/*
+	 * Decide on bucket size.
+	 */
+	switch bucket {
+	case BUCKET_WEEK:
+		weekday := t.Weekday()
+		daysSinceMonday := (int(weekday) + 6) % 7
+		return time.Date(year, month, day-daysSinceMonday, 0, 0, 0, 0, location)
+	case BUCKET_MONTH:
+		return time.Date(year, month, 1, 0, 0, 0, 0, location)
+	case BUCKET_YEAR:
+		return time.Date(year, time.January, 1, 0, 0, 0, 0, location)
+	default:
+		return time.Date(year, month, day, 0, 0, 0, 0, location)
+	}
+
+}
+
+/*
+ * Returns the start of the bucket following the bucket starting at start.
+ */
+func (this *utilStruct) bucketNext(start time.Time, bucket int) time.Time {
+
+	/*
+	 * Decide on bucket size.
+	 */
+	switch bucket {
+	case BUCKET_WEEK:
+		return start.AddDate(0, 0, 7)
+	case BUCKET_MONTH:
+		return start.AddDate(0, 1, 0)
+	case BUCKET_YEAR:
+		return start.AddDate(1, 0, 0)
+	default:
+		return start.AddDate(0, 0, 1)
+	}
+
+}
+
+/*
+ * Creates a histogram by scanning the entire GeoDB database.
+ */
+func (this *utilStruct) geoDBHistogramScan(db geodb.Database, bucket int, location *time.Location) ([]HistogramBucket, error) {
+	locationCount := db.LocationCount()
+	locations := make([]geodb.Location, BLOCK_SIZE)
+	buckets := []HistogramBucket{}
+	bucketIds := map[int64]int{}
+	idx := uint32(0)
+	errDatabase := error(nil)
+
+	/*
+	 * Read until end or database error occurs.
+	 */
+	for (idx < locationCount) && (errDatabase == nil) {
+		n, err := db.ReadLocations(idx, locations)
+
+		/*
+		 * Iterate over the locations.
+		 */
+		for i := uint32(0); i < n; i++ {
+			timestamp := locations[i].Timestamp
+			t := this.MillisecondsToTime(timestamp)
+			tLocal := t.In(location)
+			start := this.bucketStart(tLocal, bucket)
+			key := start.UnixNano()
+			id, ok := bucketIds[key]
+
+			/*
+			 * Create bucket if it does not exist yet.
+			 */
+			if !ok {
+				id = len(buckets)
+				bucketIds[key] = id
+
+				/*
+				 * Create histogram bucket.
+				 */
+				b := HistogramBucket{
+					Start: start,
+				}
+
+				buckets = append(buckets, b)
+			}
+
+			buckets[id].Count++
+		}
+
+		idx += n
+		errDatabase = err
+	}
+
+	/*
+	 * Order buckets chronologically.
+	 */
+	sort.Slice(buckets, func(i int, j int) bool {
+		return buckets[i].Start.Before(buckets[j].Start)
+	})
+
+	return buckets, errDatabase
+}
+
+/*
+ * Creates a histogram of an ordered GeoDB database.
+ *
+ * Uses binary search to find the end of each bucket, so that the number of
+ * entries read only depends on the number of non-empty buckets.
+ *
+ * If maxBuckets is non-zero, stops after that many buckets. Also returns
+ * whether all buckets were created.
+ */
+func (this *utilStruct) geoDBHistogramSearch(db geodb.Database, bucket int, location *time.Location, maxBuckets uint32) ([]HistogramBucket, bool, error) {
+	locationCount := db.LocationCount()
+	buckets := []HistogramBucket{}
+	numBuckets := uint32(0)
+	idx := uint32(0)
+	errResult := error(nil)
+
+	/*
+	 * Create one bucket per iteration until end, error or the maximum
+	 * number of buckets.
+	 */
+	for (idx < locationCount) && (errResult == nil) && ((maxBuckets == 0) || (numBuckets < maxBuckets)) {
+		timestamp, err := this.readTimestamp(db, idx)
+
+		/*
+		 * Check if time stamp could be read.
+		 */
+		if err != nil {
+			errResult = err
+		} else {
+			t := this.MillisecondsToTime(timestamp)
+			tLocal := t.In(location)
+			start := this.bucketStart(tLocal, bucket)
+			next := this.bucketNext(start, bucket)
+			nextMilli := this.TimeToMilliseconds(next)
+			end, err := this.searchTimestamp(db, idx+1, locationCount, nextMilli)
+
+			/*
+			 * Check if end of bucket could be found.
+			 */
+			if err != nil {
+				errResult = err
+			} else {
+
+				/*
+				 * Create histogram bucket.
+				 */
+				b := HistogramBucket{
+					Start: start,
+					Count: end - idx,
+				}
+
+				buckets = append(buckets, b)
+				numBuckets++
+				idx = end
+			}
+
+		}
+
+	}
+
+	complete := idx >= locationCount
+	return buckets, complete, errResult
+}
+
+/*
+ * Reads the time stamp of a single entry of a GeoDB database.
+ */
+func (this *utilStruct) readTimestamp(db geodb.Database, idx uint32) (uint64, error) {
+	locations := make([]geodb.Location, 1)
+	n, err := db.ReadLocations(idx, locations)
+
+	/*
+	 * Check if entry could be read.
+	 */
+	if n != 1 {
+
+		/*
+		 * Report underlying error if there is one.
+		 */
+		if err != nil {
+			msg := err.Error()
+			return 0, fmt.Errorf("Failed to read entry %d: %s", idx, msg)
+		} else {
+			return 0, fmt.Errorf("Failed to read entry %d.", idx)
+		}
+
+	} else {
+		timestamp := locations[0].Timestamp
+		return timestamp, nil
+	}
+
+}
+
+/*
+ * Finds the first entry in [lower, upper) of an ordered GeoDB database with a
+ * time stamp of at least timestamp.
+ *
+ * Returns upper if there is no such entry.
+ */
+func (this *utilStruct) searchTimestamp(db geodb.Database, lower uint32, upper uint32, timestamp uint64) (uint32, error) {
+	errResult := error(nil)
+
+	/*
+	 * Narrow down the range until it is empty.
+	 */
+	for (lower < upper) && (errResult == nil) {
+		middle := lower + ((upper - lower) / 2)
+		timestampMiddle, err := this.readTimestamp(db, middle)
+
+		/*
+		 * Check if time stamp could be read.
+		 */
+		if err != nil {
+			errResult = err
+		} else if timestampMiddle < timestamp {
+			lower = middle + 1
+		} else {
+			upper = middle
+		}
+
+	}
+
+	return lower, errResult
+}
+
+/*
+ * Internal function to account for a location while counting duplicates.
+ *
+ * Locations must be passed ordered by time stamp. The group contains all
+ * distinct locations seen so far with the time stamp of the last location and
+ * is returned updated.
+ */
+func (this *utilStruct) countDuplicate(stats *duplicateStatsStruct, group []geodb.Location, location *geodb.Location) []geodb.Location {
+	numGroup := len(group)
+	timestamp := location.Timestamp
+
+	/*
+	 * Start a new group if time stamp differs from the current group.
+	 */
+	if (numGroup == 0) || (group[0].Timestamp != timestamp) {
+		group = group[:0]
+		group = append(group, *location)
+	} else {
+		duplicate := false
+
+		/*
+		 * Check if location was already seen within the group.
+		 *
+		 * The accuracy is not compared.
+		 */
+		for i := range group {
+			other := &group[i]
+			equal := (other.LatitudeE7 == location.LatitudeE7) && (other.LongitudeE7 == location.LongitudeE7)
+			duplicate = duplicate || equal
+		}
+
+		/*
+		 * Either count duplicate or add distinct location to group.
+		 */
+		if duplicate {
+			stats.duplicates++
+		} else {
+			stats.timestampsUnique = false
+			group = append(group, *location)
+		}
+
+	}
+
+	return group
+}
+
+/*
+ * Internal function to account for a location while summing up the distance
+ * travelled.
+ *
+ * Locations must be passed ordered by time stamp. The segment from the
+ * previous location is skipped if it implies a speed above the maximum speed,
+ * unless the maximum speed is zero.
+ */
+func (this *utilStruct) addDistance(state *distanceStruct, location *geodb.Location) {
+
+	/*
+	 * The first location only serves as the start of the first segment.
+	 */
+	if state.started {
+		previous := &state.previous
+		meters := this.DistanceE7(previous.LatitudeE7, previous.LongitudeE7, location.LatitudeE7, location.LongitudeE7)
+		durationMs := location.Timestamp - previous.Timestamp
+		durationMsFloat := float64(durationMs)
+		maxSpeedKMH := state.maxSpeedKMH
+		plausible := true
+
+		/*
+		 * Check if the segment implies an implausible speed.
+		 */
+		if (maxSpeedKMH > 0.0) && (meters > 0.0) {
+			maxMeters := (maxSpeedKMH * METERS_PER_KILOMETER * durationMsFloat) / MILLISECONDS_PER_HOUR
+			plausible = meters <= maxMeters
+		}
+
+		/*
+		 * Only count plausible segments.
+		 */
+		if plausible {
+			state.meters += meters
+		}
+
+	}
+
+	state.previous = *location
+	state.started = true
+}
+
 /*
  * Internal function to create statistics from a GeoDB database.
  *
@@ -345,46 +855,828 @@ func (this *utilStruct) DegreesE7ToRadians(degreesE7 int32) float64 {
 }
 
 /*
- * Create statistics from a GeoDB database.
- *
- * The contents of the GeoDB database may not change while this function runs,
- * i. e. the GeoDB database must be locked for reading.
+ * Calculates the great-circle distance in meters between two points given in
+ * degrees in fixed-point representation with a fixed exponent of seven.
  */
-func (this *utilStruct) GeoDBStats(db geodb.Database) (DatasetStats, error) {
-	stats, err := this.geoDBStats(db)
-
-	/*
-	 * Return nil stats if error occured.
-	 */
-	if err != nil {
-		return nil, err
-	} else {
-		return &stats, nil
-	}
-
+func (this *utilStruct) DistanceE7(latitudeAE7 int32, longitudeAE7 int32, latitudeBE7 int32, longitudeBE7 int32) float64 {
+	latitudeA := this.DegreesE7ToRadians(latitudeAE7)
+	longitudeA := this.DegreesE7ToRadians(longitudeAE7)
+	latitudeB := this.DegreesE7ToRadians(latitudeBE7)
+	longitudeB := this.DegreesE7ToRadians(longitudeBE7)
+	sinHalfDeltaLatitude := math.Sin(0.5 * (latitudeB - latitudeA))
+	sinHalfDeltaLongitude := math.Sin(0.5 * (longitudeB - longitudeA))
+	cosLatitudeA := math.Cos(latitudeA)
+	cosLatitudeB := math.Cos(latitudeB)
+	h := (sinHalfDeltaLatitude * sinHalfDeltaLatitude) + (cosLatitudeA * cosLatitudeB * sinHalfDeltaLongitude * sinHalfDeltaLongitude)
+	h = math.Min(h, 1.0)
+	sqrtH := math.Sqrt(h)
+	angle := 2.0 * math.Asin(sqrtH)
+	result := EARTH_RADIUS_METERS * angle
+	return result
 }
 
 /*
- * Create statistics from a GeoJSON or GPX database.
+ * Determines the bounding box of the locations of a GeoDB database, starting
+ * at index idxBegin, with a time stamp of at least timestampBegin.
  */
-func (this *utilStruct) GeoJSONOrGPXStats(db geo.Database) (DatasetStats, error) {
-	stats, err := this.geoJSONOrGPXStats(db)
+func (this *utilStruct) geoDBBounds(db geodb.Database, idxBegin uint32, timestampBegin uint64) (Bounds, error) {
+	locationCount := db.LocationCount()
+	empty := true
+	latitudeMax := int32(math.MinInt32)
+	latitudeMin := int32(math.MaxInt32)
+	longitudeMax := int32(math.MinInt32)
+	longitudeMin := int32(math.MaxInt32)
+	locations := make([]geodb.Location, BLOCK_SIZE)
+	idx := idxBegin
+	errDatabase := error(nil)
 
 	/*
-	 * Return nil stats if error occured.
+	 * Read until end or database error occurs.
 	 */
-	if err != nil {
-		return nil, err
-	} else {
-		return &stats, nil
-	}
+	for (idx < locationCount) && (errDatabase == nil) {
+		n, err := db.ReadLocations(idx, locations)
 
-}
+		/*
+		 * Iterate over the locations.
+		 */
+		for i := uint32(0); i < n; i++ {
+			location := &locations[i]
+			timestamp := location.Timestamp
+			latitude := location.LatitudeE7
+			longitude := location.LongitudeE7
 
-/*
- * Migrate data from a GeoJSON / GPX database to a GeoDB database.
+			/*
+			 * Only consider locations which are recent enough.
+			 */
+			if timestamp >= timestampBegin {
+				empty = false
+
+				/*
+				 * Check if we found a more northern latitude.
+				 */
+				if latitude > latitudeMax {
+					latitudeMax = latitude
+				}
+
+				/*
+				 * Check if we found a more southern latitude.
+				 */
+				if latitude < latitudeMin {
+					latitudeMin = latitude
+				}
+
+				/*
+				 * Check if we found a more eastern longitude.
+				 */
+				if longitude > longitudeMax {
+					longitudeMax = longitude
+				}
+
+				/*
+				 * Check if we found a more western longitude.
+				 */
+				if longitude < longitudeMin {
+					longitudeMin = longitude
+				}
+
+			}
+
+		}
+
+		idx += n
+		errDatabase = err
+	}
+
+	/*
+	 * Check if database error occured.
+	 */
+	if errDatabase != nil {
+		msg := errDatabase.Error()
+		return nil, fmt.Errorf("Error accessing database: %s", msg)
+	} else {
+
+		/*
+		 * Create data structure for bounding box.
+		 */
+		bounds := boundsStruct{
+			empty: empty,
+		}
+
+		/*
+		 * Coordinates are only defined for non-empty data sets.
+		 */
+		if !empty {
+			bounds.latitudeMax = latitudeMax
+			bounds.latitudeMin = latitudeMin
+			bounds.longitudeMax = longitudeMax
+			bounds.longitudeMin = longitudeMin
+		}
+
+		return &bounds, nil
+	}
+
+}
+
+/*
+ * Determines the bounding box of all locations in a GeoDB database.
+ *
+ * The contents of the GeoDB database may not change while this function runs,
+ * i. e. the GeoDB database must be locked for reading.
+ */
+func (this *utilStruct) GeoDBBounds(db geodb.Database) (Bounds, error) {
+
+	/*
+	 * Query database if it is non-nil.
+	 */
+	if db == nil {
+		return nil, fmt.Errorf("%s", "Database is nil!")
+	} else {
+		bounds, err := this.geoDBBounds(db, 0, 0)
+		return bounds, err
+	}
+
+}
+
+/*
+ * Determines the bounding box of all locations in a GeoDB database with a
+ * time stamp of at least timestampBegin.
+ *
+ * If the database is ordered by time stamp, the first of these locations is
+ * found using binary search. Otherwise, the entire database is scanned.
+ *
+ * The contents of the GeoDB database may not change while this function runs,
+ * i. e. the GeoDB database must be locked for reading.
+ */
+func (this *utilStruct) GeoDBBoundsSince(db geodb.Database, timestampBegin uint64) (Bounds, error) {
+
+	/*
+	 * Query database if it is non-nil.
+	 */
+	if db == nil {
+		return nil, fmt.Errorf("%s", "Database is nil!")
+	} else {
+		ordered, err := db.Ordered()
+
+		/*
+		 * Check if order of database could be determined.
+		 */
+		if err != nil {
+			msg := err.Error()
+			return nil, fmt.Errorf("Error determining order of database: %s", msg)
+		} else {
+			locationCount := db.LocationCount()
+			idxBegin := uint32(0)
+
+			/*
+			 * Use binary search on ordered databases.
+			 */
+			if ordered {
+				idxBegin, err = this.searchTimestamp(db, 0, locationCount, timestampBegin)
+			}
+
+			/*
+			 * Check if error occured during search.
+			 */
+			if err != nil {
+				msg := err.Error()
+				return nil, fmt.Errorf("Error accessing database: %s", msg)
+			} else {
+				bounds, err := this.geoDBBounds(db, idxBegin, timestampBegin)
+				return bounds, err
+			}
+
+		}
+
+	}
+
+}
+
+/*
+ * Counts the locations in a GeoDB database with a time stamp of at least
+ * timestampBegin, but less than timestampEnd.
+ *
+ * If the database is ordered by time stamp, the range is found using binary
+ * search. Otherwise, the entire database is scanned.
+ */
+func (this *utilStruct) GeoDBCount(db geodb.Database, timestampBegin uint64, timestampEnd uint64) (uint32, error) {
+
+	/*
+	 * Query database if it is non-nil.
+	 */
+	if db == nil {
+		return 0, fmt.Errorf("%s", "Database is nil!")
+	} else if timestampBegin >= timestampEnd {
+		return 0, nil
+	} else {
+		ordered, err := db.Ordered()
+
+		/*
+		 * Check if order of database could be determined.
+		 */
+		if err != nil {
+			msg := err.Error()
+			return 0, fmt.Errorf("Error determining order of database: %s", msg)
+		} else {
+			locationCount := db.LocationCount()
+			result := uint32(0)
+
+			/*
+			 * Use binary search on ordered databases.
+			 */
+			if ordered {
+				idxBegin, errBegin := this.searchTimestamp(db, 0, locationCount, timestampBegin)
+				idxEnd, errEnd := this.searchTimestamp(db, idxBegin, locationCount, timestampEnd)
+				result = idxEnd - idxBegin
+
+				/*
+				 * Check if error occured during search.
+				 */
+				if errBegin != nil {
+					err = errBegin
+				} else {
+					err = errEnd
+				}
+
+			} else {
+				locations := make([]geodb.Location, BLOCK_SIZE)
+				idx := uint32(0)
+
+				/*
+				 * Read until end or database error occurs.
+				 */
+				for (idx < locationCount) && (err == nil) {
+					n, errRead := db.ReadLocations(idx, locations)
+
+					/*
+					 * Iterate over the locations.
+					 */
+					for i := uint32(0); i < n; i++ {
+						timestamp := locations[i].Timestamp
+
+						/*
+						 * Count location if it lies within range.
+						 */
+						if (timestamp >= timestampBegin) && (timestamp < timestampEnd) {
+							result++
+						}
+
+					}
+
+					idx += n
+					err = errRead
+				}
+
+			}
+
+			/*
+			 * Check if database error occured.
+			 */
+			if err != nil {
+				msg := err.Error()
+				return 0, fmt.Errorf("Error accessing database: %s", msg)
+			} else {
+				return result, nil
+			}
+
+		}
+
+	}
+
+}
+
+/*
+ * Sums up the great-circle distance in kilometers between consecutive
+ * locations of a GeoDB database, ordered by time stamp.
+ *
+ * Segments implying a speed above maxSpeedKMH are considered implausible and
+ * skipped. If maxSpeedKMH is zero, all segments are counted.
+ *
+ * If the database is ordered by time stamp, it is scanned once. Otherwise, all
+ * locations are read into memory and sorted there.
+ */
+func (this *utilStruct) GeoDBDistanceKM(db geodb.Database, maxSpeedKMH float64) (float64, error) {
+
+	/*
+	 * Query database if it is non-nil.
+	 */
+	if db == nil {
+		return 0.0, fmt.Errorf("%s", "Database is nil!")
+	} else if (maxSpeedKMH < 0.0) || math.IsNaN(maxSpeedKMH) {
+		return 0.0, fmt.Errorf("%s", "Maximum speed must be non-negative.")
+	} else {
+		ordered, err := db.Ordered()
+
+		/*
+		 * Check if order of database could be determined.
+		 */
+		if err != nil {
+			msg := err.Error()
+			return 0.0, fmt.Errorf("Error determining order of database: %s", msg)
+		} else {
+			locationCount := db.LocationCount()
+
+			/*
+			 * Create state for summing up the distance.
+			 */
+			state := distanceStruct{
+				maxSpeedKMH: maxSpeedKMH,
+				meters:      0.0,
+				started:     false,
+			}
+
+			/*
+			 * Scan ordered databases block by block, otherwise sort a copy.
+			 */
+			if ordered {
+				locations := make([]geodb.Location, BLOCK_SIZE)
+				idx := uint32(0)
+
+				/*
+				 * Read until end or database error occurs.
+				 */
+				for (idx < locationCount) && (err == nil) {
+					n, errRead := db.ReadLocations(idx, locations)
+
+					/*
+					 * Iterate over the locations.
+					 */
+					for i := uint32(0); i < n; i++ {
+						location := &locations[i]
+						this.addDistance(&state, location)
+					}
+
+					idx += n
+					err = errRead
+				}
+
+			} else {
+				locations := make([]geodb.Location, locationCount)
+				n, errRead := db.ReadLocations(0, locations)
+				locations = locations[:n]
+				err = errRead
+
+				/*
+				 * Order locations by time stamp.
+				 */
+				sort.SliceStable(locations, func(i int, j int) bool {
+					return locations[i].Timestamp < locations[j].Timestamp
+				})
+
+				/*
+				 * Iterate over the locations.
+				 */
+				for i := range locations {
+					location := &locations[i]
+					this.addDistance(&state, location)
+				}
+
+			}
+
+			/*
+			 * Check if database error occured.
+			 */
+			if err != nil {
+				msg := err.Error()
+				return 0.0, fmt.Errorf("Error accessing database: %s", msg)
+			} else {
+				result := state.meters / METERS_PER_KILOMETER
+				return result, nil
+			}
+
+		}
+
+	}
+
+}
+
+/*
+ * Counts the locations in a GeoDB database which are exact duplicates of
+ * another location, without modifying the database.
+ *
+ * If the database is ordered by time stamp, it is scanned once. Otherwise, all
+ * locations are read into memory and sorted there.
+ */
+func (this *utilStruct) GeoDBDuplicates(db geodb.Database) (DuplicateStats, error) {
+
+	/*
+	 * Query database if it is non-nil.
+	 */
+	if db == nil {
+		return nil, fmt.Errorf("%s", "Database is nil!")
+	} else {
+		ordered, err := db.Ordered()
+
+		/*
+		 * Check if order of database could be determined.
+		 */
+		if err != nil {
+			msg := err.Error()
+			return nil, fmt.Errorf("Error determining order of database: %s", msg)
+		} else {
+			locationCount := db.LocationCount()
+			group := []geodb.Location{}
+
+			/*
+			 * Create data structure for duplicate statistics.
+			 */
+			stats := duplicateStatsStruct{
+				duplicates:       0,
+				timestampsUnique: true,
+			}
+
+			/*
+			 * Scan ordered databases block by block, otherwise sort a copy.
+			 */
+			if ordered {
+				locations := make([]geodb.Location, BLOCK_SIZE)
+				idx := uint32(0)
+
+				/*
+				 * Read until end or database error occurs.
+				 */
+				for (idx < locationCount) && (err == nil) {
+					n, errRead := db.ReadLocations(idx, locations)
+
+					/*
+					 * Iterate over the locations.
+					 */
+					for i := uint32(0); i < n; i++ {
+						location := &locations[i]
+						group = this.countDuplicate(&stats, group, location)
+					}
+
+					idx += n
+					err = errRead
+				}
+
+			} else {
+				locations := make([]geodb.Location, locationCount)
+				n, errRead := db.ReadLocations(0, locations)
+				locations = locations[:n]
+				err = errRead
+
+				/*
+				 * Order locations by time stamp and coordinates, so that
+				 * duplicates are adjacent.
+				 */
+				sort.Slice(locations, func(i int, j int) bool {
+					a := &locations[i]
+					b := &locations[j]
+
+					/*
+					 * Compare time stamp first, then coordinates.
+					 */
+					if a.Timestamp != b.Timestamp {
+						return a.Timestamp < b.Timestamp
+					} else if a.LatitudeE7 != b.LatitudeE7 {
+						return a.LatitudeE7 < b.LatitudeE7
+					} else {
+						return a.LongitudeE7 < b.LongitudeE7
+					}
+
+				})
+
+				/*
+				 * Iterate over the locations.
+				 */
+				for i := range locations {
+					location := &locations[i]
+					group = this.countDuplicate(&stats, group, location)
+				}
+
+			}
+
+			/*
+			 * Check if database error occured.
+			 */
+			if err != nil {
+				msg := err.Error()
+				return nil, fmt.Errorf("Error accessing database: %s", msg)
+			} else {
+				return &stats, nil
+			}
+
+		}
+
+	}
+
+}
+
+/*
+ * Creates a histogram of the number of locations in a GeoDB database.
+ *
+ * Buckets are days, weeks, months or years in the provided time zone. If
+ * location is nil, UTC is used. Only non-empty buckets are returned.
+ *
+ * If the database is ordered by time stamp, bucket boundaries are found using
+ * binary search. Otherwise, the entire database is scanned.
+ *
+ * If maxBuckets is non-zero and the database is ordered, creation stops after
+ * that many buckets, so that the histogram may be incomplete. Unordered
+ * databases have to be scanned entirely anyway, so their histogram is always
+ * complete.
+ */
+func (this *utilStruct) GeoDBHistogram(db geodb.Database, bucket int, location *time.Location, maxBuckets uint32) (Histogram, error) {
+
+	/*
+	 * Query database if it is non-nil.
+	 */
+	if db == nil {
+		return nil, fmt.Errorf("%s", "Database is nil!")
+	} else {
+
+		/*
+		 * Default to UTC.
+		 */
+		if location == nil {
+			location = time.UTC
+		}
+
+		ordered, err := db.Ordered()
+
+		/*
+		 * Check if order of database could be determined.
+		 */
+		if err != nil {
+			msg := err.Error()
+			return nil, fmt.Errorf("Error determining order of database: %s", msg)
+		} else {
+			buckets := []HistogramBucket(nil)
+			complete := true
+
+			/*
+			 * Use binary search on ordered databases.
+			 */
+			if ordered {
+				buckets, complete, err = this.geoDBHistogramSearch(db, bucket, location, maxBuckets)
+			} else {
+				buckets, err = this.geoDBHistogramScan(db, bucket, location)
+			}
+
+			/*
+			 * Check if database error occured.
+			 */
+			if err != nil {
+				msg := err.Error()
+				return nil, fmt.Errorf("Error accessing database: %s", msg)
+			} else {
+
+				/*
+				 * Create histogram.
+				 */
+				histogram := histogramStruct{
+					buckets:  buckets,
+					complete: complete,
+					fullScan: !ordered,
+				}
+
+				return &histogram, nil
+			}
+
+		}
+
+	}
+
+}
+
+/*
+ * Appends all locations from the source database to the destination database.
+ *
+ * Locations are read from the source database block by block and appended to
+ * the destination database in batches. The order of the destination database
+ * is not restored, so it may have to be sorted afterwards.
+ *
+ * Returns the number of locations appended. If an error occurs, the
+ * destination database contains the locations appended before the error.
+ */
+func (this *utilStruct) GeoDBMerge(dst geodb.Database, src geodb.Database) (uint32, error) {
+
+	/*
+	 * Merge databases if both are non-nil and distinct.
+	 */
+	if (dst == nil) || (src == nil) {
+		return 0, fmt.Errorf("%s", "Database is nil!")
+	} else if dst == src {
+		return 0, fmt.Errorf("%s", "Cannot merge a database into itself.")
+	} else {
+		locationCount := src.LocationCount()
+		locations := make([]geodb.Location, BLOCK_SIZE)
+		idx := uint32(0)
+		err := error(nil)
+
+		/*
+		 * Copy until end or database error occurs.
+		 */
+		for (idx < locationCount) && (err == nil) {
+			n, errRead := src.ReadLocations(idx, locations)
+			block := locations[0:n]
+
+			/*
+			 * Check if locations could be read.
+			 */
+			if errRead != nil {
+				msg := errRead.Error()
+				err = fmt.Errorf("Error reading from source database: %s", msg)
+			} else if n == 0 {
+				err = fmt.Errorf("Unexpected end of source database at offset %d.", idx)
+			} else {
+				numAppended, errAppend := dst.AppendBatch(block)
+				idx += numAppended
+
+				/*
+				 * Check if locations could be appended.
+				 */
+				if errAppend != nil {
+					msg := errAppend.Error()
+					err = fmt.Errorf("Error writing to destination database: %s", msg)
+				}
+
+			}
+
+		}
+
+		return idx, err
+	}
+
+}
+
+/*
+ * Reads the locations in a GeoDB database with a time stamp of at least
+ * timestampBegin, but less than timestampEnd, ordered by time stamp.
+ *
+ * If the database is ordered by time stamp, the range is found using binary
+ * search. Otherwise, the entire database is scanned and the matching locations
+ * are sorted.
  */
-func (this *utilStruct) Migrate(dst geodb.Database, src geo.Database, importStrategy int) (MigrationReport, error) {
+func (this *utilStruct) GeoDBRange(db geodb.Database, timestampBegin uint64, timestampEnd uint64) ([]geodb.Location, error) {
+
+	/*
+	 * Query database if it is non-nil.
+	 */
+	if db == nil {
+		return nil, fmt.Errorf("%s", "Database is nil!")
+	} else if timestampBegin >= timestampEnd {
+		return []geodb.Location{}, nil
+	} else {
+		ordered, err := db.Ordered()
+
+		/*
+		 * Check if order of database could be determined.
+		 */
+		if err != nil {
+			msg := err.Error()
+			return nil, fmt.Errorf("Error determining order of database: %s", msg)
+		} else {
+			locationCount := db.LocationCount()
+			result := []geodb.Location{}
+
+			/*
+			 * Use binary search on ordered databases.
+			 */
+			if ordered {
+				idxBegin, errBegin := this.searchTimestamp(db, 0, locationCount, timestampBegin)
+				idxEnd, errEnd := this.searchTimestamp(db, idxBegin, locationCount, timestampEnd)
+
+				/*
+				 * Check if error occured during search.
+				 */
+				if errBegin != nil {
+					err = errBegin
+				} else if errEnd != nil {
+					err = errEnd
+				} else {
+					count := idxEnd - idxBegin
+					result = make([]geodb.Location, count)
+					idx := uint32(0)
+
+					/*
+					 * Read until all locations in range are read or
+					 * database error occurs.
+					 */
+					for (idx < count) && (err == nil) {
+						target := result[idx:]
+						offset := idxBegin + idx
+						n, errRead := db.ReadLocations(offset, target)
+
+						/*
+						 * Stop if no locations could be read.
+						 */
+						if (n == 0) && (errRead == nil) {
+							errRead = fmt.Errorf("Failed to read entry %d.", offset)
+						}
+
+						idx += n
+						err = errRead
+					}
+
+				}
+
+			} else {
+				locations := make([]geodb.Location, BLOCK_SIZE)
+				idx := uint32(0)
+
+				/*
+				 * Read until end or database error occurs.
+				 */
+				for (idx < locationCount) && (err == nil) {
+					n, errRead := db.ReadLocations(idx, locations)
+
+					/*
+					 * Iterate over the locations.
+					 */
+					for i := uint32(0); i < n; i++ {
+						location := locations[i]
+						timestamp := location.Timestamp
+
+						/*
+						 * Keep location if it lies within range.
+						 */
+						if (timestamp >= timestampBegin) && (timestamp < timestampEnd) {
+							result = append(result, location)
+						}
+
+					}
+
+					idx += n
+					err = errRead
+				}
+
+				/*
+				 * Order locations by time stamp.
+				 */
+				sort.SliceStable(result, func(i int, j int) bool {
+					return result[i].Timestamp < result[j].Timestamp
+				})
+
+			}
+
+			/*
+			 * Check if database error occured.
+			 */
+			if err != nil {
+				msg := err.Error()
+				return nil, fmt.Errorf("Error accessing database: %s", msg)
+			} else {
+				return result, nil
+			}
+
+		}
+
+	}
+
+}
+
+/*
+ * Create statistics from a GeoDB database.
+ *
+ * The contents of the GeoDB database may not change while this function runs,
+ * i. e. the GeoDB database must be locked for reading.
+ */
+func (this *utilStruct) GeoDBStats(db geodb.Database) (DatasetStats, error) {
+	stats, err := this.geoDBStats(db)
+
+	/*
+	 * Return nil stats if error occured.
+	 */
+	if err != nil {
+		return nil, err
+	} else {
+		return &stats, nil
+	}
+
+}
+
+/*
+ * Create statistics from a GeoJSON or GPX database.
+ */
+func (this *utilStruct) GeoJSONOrGPXStats(db geo.Database) (DatasetStats, error) {
+	stats, err := this.geoJSONOrGPXStats(db)
+
+	/*
+	 * Return nil stats if error occured.
+	 */
+	if err != nil {
+		return nil, err
+	} else {
+		return &stats, nil
+	}
+
+}
+
+/*
+ * Migrate data from a GeoJSON / GPX database to a GeoDB database.
+ *
+ * Locations are appended to the GeoDB database in blocks, so that each block
+ * is written at once.
+ *
+ * Cancelling the context stops the migration. Locations migrated up to this
+ * point remain in the target database and are accounted for in the report.
+ *
+ * If maxLocations is non-zero, the migration stops with an error as soon as
+ * more than maxLocations locations would be imported. The locations imported
+ * up to this point remain in the target database, just like on cancellation.
+ */
+func (this *utilStruct) Migrate(ctx context.Context, dst geodb.Database, src geo.Database, importStrategy int, maxLocations uint32) (MigrationReport, error) {
 	errResult := error(nil)
 	statsImported := datasetStatsStruct{}
 	statsBefore, errBefore := this.geoDBStats(dst)
@@ -408,13 +1700,18 @@ func (this *utilStruct) Migrate(dst geodb.Database, src geo.Database, importStra
 		timestampOld := uint64(0)
 		errDatabaseSource := error(nil)
 		errDatabaseTarget := error(nil)
+		errCancelled := error(nil)
+		errLimit := error(nil)
+		maxLocations64 := uint64(maxLocations)
 		locationCountSource := src.LocationCount()
 		timestampLatestBeforeImport := statsBefore.TimestampLatest()
+		batch := make([]geodb.Location, 0, BLOCK_SIZE)
 
 		/*
-		 * Import locations from GeoJSON database.
+		 * Import locations from GeoJSON database until done, cancelled or
+		 * the limit is exceeded.
 		 */
-		for i := 0; i < locationCountSource; i++ {
+		for i := 0; (i < locationCountSource) && (errCancelled == nil) && (errLimit == nil); i++ {
 			locationSource, errRead := src.LocationAt(i)
 
 			/*
@@ -438,6 +1735,18 @@ func (this *utilStruct) Migrate(dst geodb.Database, src geo.Database, importStra
 					// Do nothing.
 				}
 
+				numPending := len(batch)
+				numPending64 := uint64(numPending)
+				locationCountPending := locationCount + numPending64
+
+				/*
+				 * Check if importing this record would exceed the limit.
+				 */
+				if migrate && (maxLocations64 != 0) && (locationCountPending >= maxLocations64) {
+					errLimit = fmt.Errorf("Import limit exceeded: At most %d locations may be imported per request.", maxLocations)
+					migrate = false
+				}
+
 				/*
 				 * Check if we shall migrate this record.
 				 */
@@ -462,6 +1771,7 @@ func (this *utilStruct) Migrate(dst geodb.Database, src geo.Database, importStra
 					timestampOld = timestamp
 					latitude := locationSource.Latitude()
 					longitude := locationSource.Longitude()
+					accuracy := locationSource.Accuracy()
 
 					/*
 					 * Create GeoDB location.
@@ -470,29 +1780,72 @@ func (this *utilStruct) Migrate(dst geodb.Database, src geo.Database, importStra
 						Timestamp:   timestamp,
 						LatitudeE7:  latitude,
 						LongitudeE7: longitude,
+						Accuracy:    accuracy,
 					}
 
-					errWrite := dst.Append(&locationTarget)
+					batch = append(batch, locationTarget)
+				}
 
-					/*
-					 * Check for write errors.
-					 */
-					if errWrite != nil {
-						errDatabaseTarget = errWrite
-					} else {
-						locationCount++
-					}
+			}
+
+			numPending := len(batch)
+
+			/*
+			 * Write locations once the batch is full.
+			 */
+			if numPending >= BLOCK_SIZE {
+				numWritten, errWrite := dst.AppendBatch(batch)
+				numWritten64 := uint64(numWritten)
+				locationCount += numWritten64
+				batch = batch[:0]
 
+				/*
+				 * Check for write errors.
+				 */
+				if errWrite != nil {
+					errDatabaseTarget = errWrite
 				}
 
 			}
 
+			numProcessed := i + 1
+
+			/*
+			 * Check for cancellation once per block.
+			 */
+			if (numProcessed % BLOCK_SIZE) == 0 {
+				errCancelled = ctx.Err()
+			}
+
+		}
+
+		numPending := len(batch)
+
+		/*
+		 * Write the remaining locations.
+		 */
+		if numPending > 0 {
+			numWritten, errWrite := dst.AppendBatch(batch)
+			numWritten64 := uint64(numWritten)
+			locationCount += numWritten64
+
+			/*
+			 * Check for write errors.
+			 */
+			if errWrite != nil {
+				errDatabaseTarget = errWrite
+			}
+
 		}
 
 		/*
-		 * Check for database error.
+		 * Check for cancellation or database error.
 		 */
-		if errDatabaseSource != nil {
+		if errCancelled != nil {
+			errResult = fmt.Errorf("Import was cancelled: %w", errCancelled)
+		} else if errLimit != nil {
+			errResult = errLimit
+		} else if errDatabaseSource != nil {
 			msg := errDatabaseSource.Error()
 			errResult = fmt.Errorf("Error reading from GeoJSON database: %s", msg)
 		} else if errDatabaseTarget != nil {
@@ -556,6 +1909,57 @@ func (this *utilStruct) MillisecondsToTime(ms uint64) time.Time {
 	return utc
 }
 
+/*
+ * Converts a point in time into milliseconds since the Epoch.
+ *
+ * Points in time before the Epoch are mapped to zero.
+ */
+func (this *utilStruct) TimeToMilliseconds(t time.Time) uint64 {
+	ns := t.UnixNano()
+
+	/*
+	 * Time stamps cannot be negative.
+	 */
+	if ns < 0 {
+		return 0
+	} else {
+		ms := ns / NANOSECONDS_PER_MILLISECOND
+		result := uint64(ms)
+		return result
+	}
+
+}
+
+/*
+ * Truncates an angle in degrees in fixed-point representation with a fixed
+ * exponent of seven to a certain number of decimal places.
+ *
+ * Truncation is towards zero. Seven or more decimal places leave the angle
+ * unchanged.
+ */
+func (this *utilStruct) TruncateE7(degreesE7 int32, decimals uint8) int32 {
+	result := degreesE7
+
+	/*
+	 * Only truncate if precision is reduced.
+	 */
+	if decimals < 7 {
+		factor := int32(1)
+
+		/*
+		 * Calculate the factor corresponding to the dropped places.
+		 */
+		for i := decimals; i < 7; i++ {
+			factor *= 10
+		}
+
+		remainder := degreesE7 % factor
+		result = degreesE7 - remainder
+	}
+
+	return result
+}
+
 /*
  * Creates a utility for working with geographic databases.
  */
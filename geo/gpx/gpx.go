@@ -60,6 +60,15 @@ type databaseStruct struct {
 	Locations []locationStruct
 }
 
+/*
+ * Returns the horizontal accuracy (in meters) of this location.
+ *
+ * GPX does not provide the accuracy, so this is always zero.
+ */
+func (this *locationStruct) Accuracy() uint16 {
+	return 0
+}
+
 /*
  * Returns the latitude of this location.
  */
@@ -114,6 +123,15 @@ func (this *databaseStruct) LocationCount() int {
 	return numLocs
 }
 
+/*
+ * The number of records skipped while parsing, since they could not be parsed.
+ *
+ * This parser never skips records, so this is always zero.
+ */
+func (this *databaseStruct) SkippedCount() int {
+	return 0
+}
+
 /*
  * Parse 32-bit fixed-point number.
  */
@@ -10,13 +10,15 @@ import (
 )
 
 const (
-	BITS_PER_BYTE        = 8
-	MAGIC_NUMBER         = 0x47656f44420a0004
-	SIZE_DATABASE_ENTRY  = 14
-	SIZE_DATABASE_HEADER = 10
-	SIZE_MAGIC           = 8
-	SIZE_TIMESTAMP       = 6
-	SIZE_COORDINATE      = 4
+	BITS_PER_BYTE              = 8
+	MAGIC_NUMBER               = 0x47656f44420a0004
+	SIZE_ACCURACY              = 2
+	SIZE_DATABASE_ENTRY        = 16
+	SIZE_DATABASE_ENTRY_LEGACY = 14
+	SIZE_DATABASE_HEADER       = 10
+	SIZE_MAGIC                 = 8
+	SIZE_TIMESTAMP             = 6
+	SIZE_COORDINATE            = 4
 )
 
 /*
@@ -26,6 +28,15 @@ type locationStruct struct {
 	timestampMs uint64
 	latitudeE7  int32
 	longitudeE7 int32
+	accuracy    uint16
+}
+
+/*
+ * Returns the horizontal accuracy (in meters) of this location.
+ */
+func (this *locationStruct) Accuracy() uint16 {
+	accuracy := this.accuracy
+	return accuracy
 }
 
 /*
@@ -55,9 +66,12 @@ func (this *locationStruct) Timestamp() uint64 {
 
 /*
  * Data structure representing a geo database in OpenGeoDB format.
+ *
+ * Entries in version 1.0 do not store the accuracy and are therefore shorter.
  */
 type databaseStruct struct {
-	fd *bytes.Reader
+	fd        *bytes.Reader
+	entrySize int64
 }
 
 /*
@@ -122,9 +136,11 @@ func (this *databaseStruct) LocationAt(idx int) (geo.Location, error) {
 		return nil, fmt.Errorf("Index %d out of range", idx)
 	} else {
 		idx64 := int64(idx)
-		offset := SIZE_DATABASE_HEADER + (idx64 * SIZE_DATABASE_ENTRY)
+		entrySize := this.entrySize
+		entrySizeInt := int(entrySize)
+		offset := SIZE_DATABASE_HEADER + (idx64 * entrySize)
 		fd := this.fd
-		entry := make([]byte, SIZE_DATABASE_ENTRY)
+		entry := make([]byte, entrySize)
 		n, err := fd.ReadAt(entry, offset)
 
 		/*
@@ -133,8 +149,8 @@ func (this *databaseStruct) LocationAt(idx int) (geo.Location, error) {
 		if err != nil {
 			msg := err.Error()
 			return nil, fmt.Errorf("Error reading entry number %d: %s", idx, msg)
-		} else if n != SIZE_DATABASE_ENTRY {
-			return nil, fmt.Errorf("Error reading entry number %d: Expected %d bytes, read %d.", idx, SIZE_DATABASE_ENTRY, n)
+		} else if n != entrySizeInt {
+			return nil, fmt.Errorf("Error reading entry number %d: Expected %d bytes, read %d.", idx, entrySizeInt, n)
 		} else {
 			timestamp := uint64(0)
 			base := int(0)
@@ -181,11 +197,31 @@ func (this *databaseStruct) LocationAt(idx int) (geo.Location, error) {
 
 			latitudeSigned := int32(latitude)
 			base += SIZE_COORDINATE
+			accuracy := uint16(0)
+
+			/*
+			 * Read accuracy, if the entry contains it.
+			 */
+			if entrySizeInt >= SIZE_DATABASE_ENTRY {
+
+				/*
+				 * Read accuracy.
+				 */
+				for i := 0; i < SIZE_ACCURACY; i++ {
+					offs := base + i
+					byt := entry[offs]
+					byt16 := uint16(byt)
+					accuracy <<= BITS_PER_BYTE
+					accuracy |= byt16
+				}
+
+				base += SIZE_ACCURACY
+			}
 
 			/*
 			 * Check if we arrive at desired entry size.
 			 */
-			if base != SIZE_DATABASE_ENTRY {
+			if base != entrySizeInt {
 				panic("Database entry size does not match!")
 			}
 
@@ -196,6 +232,7 @@ func (this *databaseStruct) LocationAt(idx int) (geo.Location, error) {
 				timestampMs: timestamp,
 				latitudeE7:  longitudeSigned,
 				longitudeE7: latitudeSigned,
+				accuracy:    accuracy,
 			}
 
 			return &loc, nil
@@ -218,7 +255,8 @@ func (this *databaseStruct) LocationCount() int {
 	 */
 	if numBytes >= SIZE_DATABASE_HEADER {
 		sizeEntries := numBytes - SIZE_DATABASE_HEADER
-		numEntries64 := sizeEntries / SIZE_DATABASE_ENTRY
+		entrySize := this.entrySize
+		numEntries64 := sizeEntries / entrySize
 
 		/*
 		 * Store number of entries, if it fits into integer.
@@ -232,6 +270,15 @@ func (this *databaseStruct) LocationCount() int {
 	return numEntries
 }
 
+/*
+ * The number of records skipped while parsing, since they could not be parsed.
+ *
+ * This parser never skips records, so this is always zero.
+ */
+func (this *databaseStruct) SkippedCount() int {
+	return 0
+}
+
 /*
  * Create OpenGeoDB database from byte slice.
  */
@@ -259,6 +306,10 @@ func FromBytes(data []byte) (geo.Database, error) {
 		 */
 		switch {
 		case major == 1 && minor == 0:
+			db.entrySize = SIZE_DATABASE_ENTRY_LEGACY
+			return &db, nil
+		case major == 1 && minor == 1:
+			db.entrySize = SIZE_DATABASE_ENTRY
 			return &db, nil
 		default:
 			return nil, fmt.Errorf("Unsupported version: v%d.%d", major, minor)
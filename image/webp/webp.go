@@ -0,0 +1,1045 @@
+package webp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"math/bits"
+	"sort"
+)
+
+/*
+ * Constants for the WebP lossless (VP8L) encoder.
+ */
+const (
+	COLOR_CACHE_BITS            = 10
+	COLOR_CACHE_MULTIPLIER      = 0x1e35a7bd
+	DISTANCE_ABOVE              = 1
+	DISTANCE_LEFT               = 2
+	MAX_CODE_LENGTH             = 15
+	MAX_CODE_LENGTH_CODE_LENGTH = 7
+	MAX_COPY_LENGTH             = 4096
+	MAX_DIMENSION               = 16384
+	MAX_ZERO_RUN_LONG           = 138
+	MAX_ZERO_RUN_SHORT          = 10
+	MIN_COPY_LENGTH             = 3
+	MIN_ZERO_RUN                = 3
+	NUM_CODE_LENGTH_CODES       = 19
+	NUM_DISTANCE_CODES          = 40
+	NUM_LENGTH_CODES            = 24
+	NUM_LITERALS                = 256
+	QUALITY_LOSSLESS            = 100
+	QUALITY_STEP                = 20
+	SYMBOL_ZERO_RUN_LONG        = 18
+	SYMBOL_ZERO_RUN_SHORT       = 17
+	VP8L_SIGNATURE              = 0x2f
+)
+
+/*
+ * The order in which the code lengths of the code length code are stored.
+ */
+var g_codeLengthCodeOrder = [NUM_CODE_LENGTH_CODES]int{
+	17, 18, 0, 1, 2, 3, 4, 5, 16, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15,
+}
+
+/*
+ * Writes bits to a byte buffer, least significant bit first.
+ */
+type bitWriterStruct struct {
+	buf   []byte
+	acc   uint64
+	count uint
+}
+
+/*
+ * A node of a Huffman tree.
+ */
+type huffmanNodeStruct struct {
+	weight uint64
+	left   int
+	right  int
+}
+
+/*
+ * A prefix code, ready for emitting symbols.
+ *
+ * The codes are stored in reversed bit order, since the bit stream is written
+ * least significant bit first.
+ */
+type prefixCodeStruct struct {
+	codes   []uint32
+	lengths []uint8
+}
+
+/*
+ * A token of the entropy-coded image.
+ *
+ * The green symbol determines the kind of token. Symbols below 256 are
+ * literal pixels, the next 24 symbols are backward references and the
+ * remaining symbols are color cache lookups.
+ */
+type tokenStruct struct {
+	green             uint32
+	red               uint32
+	blue              uint32
+	alpha             uint32
+	lengthExtra       uint32
+	lengthExtraBits   uint8
+	distanceSymbol    uint32
+	distanceExtra     uint32
+	distanceExtraBits uint8
+}
+
+/*
+ * Writes the n least significant bits of a value to the bit stream.
+ */
+func (this *bitWriterStruct) writeBits(value uint32, n uint8) {
+	value64 := uint64(value)
+	this.acc |= value64 << this.count
+	this.count += uint(n)
+
+	/*
+	 * Move completed bytes to the buffer.
+	 */
+	for this.count >= 8 {
+		b := byte(this.acc)
+		this.buf = append(this.buf, b)
+		this.acc >>= 8
+		this.count -= 8
+	}
+
+}
+
+/*
+ * Pads the bit stream to a full byte and returns its contents.
+ */
+func (this *bitWriterStruct) bytes() []byte {
+
+	/*
+	 * Flush remaining bits.
+	 */
+	if this.count > 0 {
+		b := byte(this.acc)
+		this.buf = append(this.buf, b)
+		this.acc = 0
+		this.count = 0
+	}
+
+	return this.buf
+}
+
+/*
+ * Writes a symbol to the bit stream using this prefix code.
+ */
+func (this *prefixCodeStruct) write(w *bitWriterStruct, symbol uint32) {
+	code := this.codes[symbol]
+	length := this.lengths[symbol]
+	w.writeBits(code, length)
+}
+
+/*
+ * Splits a value into a prefix code and extra bits, as used for lengths and
+ * distances of backward references.
+ */
+func prefixEncode(value uint32) (uint32, uint32, uint8) {
+
+	/*
+	 * Small values are represented by the prefix code alone.
+	 */
+	if value <= 4 {
+		return value - 1, 0, 0
+	} else {
+		d := value - 1
+		highest := uint32(bits.Len32(d) - 1)
+		second := (d >> (highest - 1)) & 1
+		extraBits := highest - 1
+		prefix := (2 * highest) + second
+		mask := (uint32(1) << extraBits) - 1
+		extra := d & mask
+		return prefix, extra, uint8(extraBits)
+	}
+
+}
+
+/*
+ * Calculates the code lengths of an unrestricted Huffman code.
+ */
+func huffmanLengths(freq []uint64) []uint8 {
+	numSymbols := len(freq)
+	lengths := make([]uint8, numSymbols)
+	nodes := []huffmanNodeStruct{}
+	symbols := []int{}
+
+	/*
+	 * Create a leaf for each symbol in use.
+	 */
+	for symbol, weight := range freq {
+
+		/*
+		 * Only symbols in use are part of the tree.
+		 */
+		if weight > 0 {
+
+			/*
+			 * Create leaf node.
+			 */
+			node := huffmanNodeStruct{
+				weight: weight,
+				left:   -1,
+				right:  -1,
+			}
+
+			nodes = append(nodes, node)
+			symbols = append(symbols, symbol)
+		}
+
+	}
+
+	numLeaves := len(nodes)
+
+	/*
+	 * A single symbol gets a companion, so that the code is complete.
+	 */
+	if numLeaves == 1 {
+		symbol := symbols[0]
+		companion := 0
+
+		/*
+		 * Make sure that companion differs from symbol.
+		 */
+		if symbol == 0 {
+			companion = 1
+		}
+
+		lengths[symbol] = 1
+		lengths[companion] = 1
+	} else if numLeaves > 1 {
+		leaves := make([]int, numLeaves)
+
+		/*
+		 * Initialize leaf indices.
+		 */
+		for i := range leaves {
+			leaves[i] = i
+		}
+
+		/*
+		 * Sort leaves by ascending weight.
+		 */
+		sort.SliceStable(leaves, func(i int, j int) bool {
+			idxI := leaves[i]
+			idxJ := leaves[j]
+			weightI := nodes[idxI].weight
+			weightJ := nodes[idxJ].weight
+			return weightI < weightJ
+		})
+
+		internal := []int{}
+		nextLeaf := 0
+		nextInternal := 0
+
+		/*
+		 * Take the node with the lowest weight from either queue.
+		 */
+		pick := func() int {
+			numInternal := len(internal)
+			leafAvailable := nextLeaf < numLeaves
+			internalAvailable := nextInternal < numInternal
+
+			/*
+			 * Prefer leaves over internal nodes of the same weight.
+			 */
+			if leafAvailable && (!internalAvailable || (nodes[leaves[nextLeaf]].weight <= nodes[internal[nextInternal]].weight)) {
+				idx := leaves[nextLeaf]
+				nextLeaf++
+				return idx
+			} else {
+				idx := internal[nextInternal]
+				nextInternal++
+				return idx
+			}
+
+		}
+
+		/*
+		 * Merge nodes until only the root remains.
+		 */
+		for i := 1; i < numLeaves; i++ {
+			left := pick()
+			right := pick()
+			weightLeft := nodes[left].weight
+			weightRight := nodes[right].weight
+
+			/*
+			 * Create internal node.
+			 */
+			node := huffmanNodeStruct{
+				weight: weightLeft + weightRight,
+				left:   left,
+				right:  right,
+			}
+
+			idx := len(nodes)
+			nodes = append(nodes, node)
+			internal = append(internal, idx)
+		}
+
+		numNodes := len(nodes)
+		depths := make([]uint8, numNodes)
+
+		/*
+		 * Internal nodes are created after their children, so walk the
+		 * tree from the root downwards.
+		 */
+		for idx := numNodes - 1; idx >= numLeaves; idx-- {
+			node := nodes[idx]
+			depth := depths[idx] + 1
+			depths[node.left] = depth
+			depths[node.right] = depth
+		}
+
+		/*
+		 * Assign the depth of each leaf to its symbol.
+		 */
+		for i, symbol := range symbols {
+			lengths[symbol] = depths[i]
+		}
+
+	}
+
+	return lengths
+}
+
+/*
+ * Calculates the code lengths of a Huffman code, limited to a maximum length.
+ *
+ * If the code gets too long, the frequencies of rare symbols are raised until
+ * the code fits.
+ */
+func limitedLengths(freq []uint32, limit uint8) []uint8 {
+	numSymbols := len(freq)
+	adjusted := make([]uint64, numSymbols)
+	countMin := uint64(1)
+
+	/*
+	 * Retry until the code lengths are within the limit.
+	 */
+	for {
+
+		/*
+		 * Raise frequency of rare symbols.
+		 */
+		for symbol, f := range freq {
+			f64 := uint64(f)
+
+			/*
+			 * Only consider symbols in use.
+			 */
+			if (f64 > 0) && (f64 < countMin) {
+				f64 = countMin
+			}
+
+			adjusted[symbol] = f64
+		}
+
+		lengths := huffmanLengths(adjusted)
+		maxLength := uint8(0)
+
+		/*
+		 * Find the longest code.
+		 */
+		for _, length := range lengths {
+
+			/*
+			 * Check if we found a longer code.
+			 */
+			if length > maxLength {
+				maxLength = length
+			}
+
+		}
+
+		/*
+		 * Check if code lengths are within limit.
+		 */
+		if maxLength <= limit {
+			return lengths
+		}
+
+		countMin *= 2
+	}
+
+}
+
+/*
+ * Assigns canonical codes to symbols based on their code lengths.
+ */
+func canonicalCodes(lengths []uint8) prefixCodeStruct {
+	numSymbols := len(lengths)
+	count := make([]uint32, MAX_CODE_LENGTH+1)
+
+	/*
+	 * Count the number of codes of each length.
+	 */
+	for _, length := range lengths {
+
+		/*
+		 * Only count symbols in use.
+		 */
+		if length > 0 {
+			count[length]++
+		}
+
+	}
+
+	nextCode := make([]uint32, MAX_CODE_LENGTH+1)
+	code := uint32(0)
+
+	/*
+	 * Calculate the first code of each length.
+	 */
+	for length := 1; length <= MAX_CODE_LENGTH; length++ {
+		code = (code + count[length-1]) << 1
+		nextCode[length] = code
+	}
+
+	codes := make([]uint32, numSymbols)
+
+	/*
+	 * Assign codes in order of symbols, storing them bit-reversed.
+	 */
+	for symbol, length := range lengths {
+
+		/*
+		 * Only assign codes to symbols in use.
+		 */
+		if length > 0 {
+			code := nextCode[length]
+			nextCode[length]++
+			reversed := bits.Reverse32(code)
+			shift := 32 - length
+			codes[symbol] = reversed >> shift
+		}
+
+	}
+
+	/*
+	 * Create prefix code.
+	 */
+	result := prefixCodeStruct{
+		codes:   codes,
+		lengths: lengths,
+	}
+
+	return result
+}
+
+/*
+ * Writes the code lengths of a normal prefix code to the bit stream.
+ *
+ * Runs of zeros are run-length encoded, everything else is stored literally.
+ */
+func writeCodeLengths(w *bitWriterStruct, lengths []uint8) {
+	numSymbols := len(lengths)
+	tokens := []uint32{}
+	extras := []uint32{}
+	freq := make([]uint32, NUM_CODE_LENGTH_CODES)
+
+	/*
+	 * Convert code lengths into tokens.
+	 */
+	for i := 0; i < numSymbols; {
+		length := lengths[i]
+		run := 1
+
+		/*
+		 * Determine length of zero runs.
+		 */
+		if length == 0 {
+
+			/*
+			 * Count consecutive zeros.
+			 */
+			for ((i + run) < numSymbols) && (lengths[i+run] == 0) && (run < MAX_ZERO_RUN_LONG) {
+				run++
+			}
+
+		}
+
+		/*
+		 * Decide on the token representing the code length.
+		 */
+		if (length != 0) || (run < MIN_ZERO_RUN) {
+			token := uint32(length)
+			tokens = append(tokens, token)
+			extras = append(extras, 0)
+			freq[token]++
+			run = 1
+		} else if run <= MAX_ZERO_RUN_SHORT {
+			extra := uint32(run - MIN_ZERO_RUN)
+			tokens = append(tokens, SYMBOL_ZERO_RUN_SHORT)
+			extras = append(extras, extra)
+			freq[SYMBOL_ZERO_RUN_SHORT]++
+		} else {
+			extra := uint32(run - MAX_ZERO_RUN_SHORT - 1)
+			tokens = append(tokens, SYMBOL_ZERO_RUN_LONG)
+			extras = append(extras, extra)
+			freq[SYMBOL_ZERO_RUN_LONG]++
+		}
+
+		i += run
+	}
+
+	codeLengths := limitedLengths(freq, MAX_CODE_LENGTH_CODE_LENGTH)
+	code := canonicalCodes(codeLengths)
+	numCodes := 4
+
+	/*
+	 * Find the last code length code in use.
+	 */
+	for i, symbol := range g_codeLengthCodeOrder {
+
+		/*
+		 * Check if code length code is in use.
+		 */
+		if (codeLengths[symbol] != 0) && (i >= numCodes) {
+			numCodes = i + 1
+		}
+
+	}
+
+	numCodes32 := uint32(numCodes)
+	w.writeBits(numCodes32-4, 4)
+
+	/*
+	 * Write code lengths of the code length code.
+	 */
+	for i := 0; i < numCodes; i++ {
+		symbol := g_codeLengthCodeOrder[i]
+		codeLength := codeLengths[symbol]
+		codeLength32 := uint32(codeLength)
+		w.writeBits(codeLength32, 3)
+	}
+
+	/*
+	 * Code lengths are provided for the entire alphabet.
+	 */
+	w.writeBits(0, 1)
+
+	/*
+	 * Write the tokens.
+	 */
+	for i, token := range tokens {
+		code.write(w, token)
+		extra := extras[i]
+
+		/*
+		 * Write extra bits of run-length tokens.
+		 */
+		switch token {
+		case SYMBOL_ZERO_RUN_SHORT:
+			w.writeBits(extra, 3)
+		case SYMBOL_ZERO_RUN_LONG:
+			w.writeBits(extra, 7)
+		}
+
+	}
+
+}
+
+/*
+ * Creates a prefix code from symbol frequencies and writes it to the bit
+ * stream.
+ */
+func writePrefixCode(w *bitWriterStruct, freq []uint32) prefixCodeStruct {
+	numSymbols := len(freq)
+	used := []uint32{}
+
+	/*
+	 * Find the symbols in use.
+	 */
+	for symbol, f := range freq {
+
+		/*
+		 * Check if symbol is in use.
+		 */
+		if f > 0 {
+			symbol32 := uint32(symbol)
+			used = append(used, symbol32)
+		}
+
+	}
+
+	numUsed := len(used)
+
+	/*
+	 * An empty alphabet is represented by a single symbol.
+	 */
+	if numUsed == 0 {
+		used = append(used, 0)
+		numUsed = 1
+	}
+
+	lastUsed := used[numUsed-1]
+
+	/*
+	 * Use a simple code for up to two literal symbols.
+	 */
+	if (numUsed <= 2) && (lastUsed < NUM_LITERALS) {
+
+		/*
+		 * Create prefix code.
+		 */
+		code := prefixCodeStruct{
+			codes:   make([]uint32, numSymbols),
+			lengths: make([]uint8, numSymbols),
+		}
+
+		numUsed32 := uint32(numUsed)
+		first := used[0]
+		w.writeBits(1, 1)
+		w.writeBits(numUsed32-1, 1)
+
+		/*
+		 * Store the first symbol in either one or eight bits.
+		 */
+		if first < 2 {
+			w.writeBits(0, 1)
+			w.writeBits(first, 1)
+		} else {
+			w.writeBits(1, 1)
+			w.writeBits(first, 8)
+		}
+
+		/*
+		 * A second symbol requires one bit to tell both apart.
+		 */
+		if numUsed == 2 {
+			second := used[1]
+			w.writeBits(second, 8)
+			code.lengths[first] = 1
+			code.lengths[second] = 1
+			code.codes[second] = 1
+		}
+
+		return code
+	} else {
+		lengths := limitedLengths(freq, MAX_CODE_LENGTH)
+		code := canonicalCodes(lengths)
+		w.writeBits(0, 1)
+		writeCodeLengths(w, lengths)
+		return code
+	}
+
+}
+
+/*
+ * Converts an image into ARGB pixels.
+ *
+ * The second return value indicates whether any pixel is not fully opaque.
+ */
+func argbPixels(img image.Image) ([]uint32, bool) {
+	bounds := img.Bounds()
+	minX := bounds.Min.X
+	minY := bounds.Min.Y
+	width := bounds.Dx()
+	height := bounds.Dy()
+	numPixels := width * height
+	pixels := make([]uint32, numPixels)
+	alphaUsed := false
+	model := color.NRGBAModel
+	idx := 0
+
+	/*
+	 * Iterate over rows.
+	 */
+	for y := 0; y < height; y++ {
+
+		/*
+		 * Iterate over columns.
+		 */
+		for x := 0; x < width; x++ {
+			posX := minX + x
+			posY := minY + y
+			c := img.At(posX, posY)
+			cc := model.Convert(c)
+			nc := cc.(color.NRGBA)
+			a := uint32(nc.A)
+			r := uint32(nc.R)
+			g := uint32(nc.G)
+			b := uint32(nc.B)
+			pixels[idx] = (a << 24) | (r << 16) | (g << 8) | b
+			alphaUsed = alphaUsed || (a != 0xff)
+			idx++
+		}
+
+	}
+
+	return pixels, alphaUsed
+}
+
+/*
+ * Rounds a color component to a multiple of 2^bits, without exceeding the
+ * range of a byte.
+ */
+func quantizeComponent(value uint32, bits uint8) uint32 {
+	half := uint32(1) << bits >> 1
+	rounded := ((value + half) >> bits) << bits
+
+	/*
+	 * Rounding up must not overflow the component.
+	 */
+	if rounded > 0xff {
+		rounded = 0xff
+	}
+
+	return rounded
+}
+
+/*
+ * Reduces the precision of ARGB pixels, so that they compress better.
+ *
+ * Each color component is rounded to a multiple of 2^bits. The color of fully
+ * transparent pixels is discarded, since it is invisible anyway.
+ */
+func quantizePixels(pixels []uint32, bits uint8) {
+
+	/*
+	 * Iterate over the pixels.
+	 */
+	for i, argb := range pixels {
+		a := argb >> 24
+
+		/*
+		 * Fully transparent pixels become transparent black.
+		 */
+		if a == 0 {
+			pixels[i] = 0
+		} else {
+			r := (argb >> 16) & 0xff
+			g := (argb >> 8) & 0xff
+			b := argb & 0xff
+			a = quantizeComponent(a, bits)
+			r = quantizeComponent(r, bits)
+			g = quantizeComponent(g, bits)
+			b = quantizeComponent(b, bits)
+			pixels[i] = (a << 24) | (r << 16) | (g << 8) | b
+		}
+
+	}
+
+}
+
+/*
+ * Calculates the index of a pixel in the color cache.
+ */
+func cacheIndex(argb uint32) uint32 {
+	hash := argb * COLOR_CACHE_MULTIPLIER
+	return hash >> (32 - COLOR_CACHE_BITS)
+}
+
+/*
+ * Converts ARGB pixels into tokens.
+ *
+ * Runs of pixels repeating their left or upper neighbour are expressed as
+ * backward references, other pixels are either looked up from the color
+ * cache or stored literally.
+ */
+func tokenize(pixels []uint32, width int) []tokenStruct {
+	numPixels := len(pixels)
+	cacheSize := 1 << COLOR_CACHE_BITS
+	cache := make([]uint32, cacheSize)
+	cacheValid := make([]bool, cacheSize)
+	tokens := []tokenStruct{}
+
+	/*
+	 * Insert a pixel into the color cache.
+	 */
+	insert := func(argb uint32) {
+		key := cacheIndex(argb)
+		cache[key] = argb
+		cacheValid[key] = true
+	}
+
+	/*
+	 * Process all pixels.
+	 */
+	for i := 0; i < numPixels; {
+		runLeft := 0
+		runAbove := 0
+
+		/*
+		 * Count pixels repeating their left neighbour.
+		 */
+		if i > 0 {
+
+			/*
+			 * Extend run as far as possible.
+			 */
+			for ((i + runLeft) < numPixels) && (runLeft < MAX_COPY_LENGTH) && (pixels[i+runLeft] == pixels[i+runLeft-1]) {
+				runLeft++
+			}
+
+		}
+
+		/*
+		 * Count pixels repeating their upper neighbour.
+		 */
+		if i >= width {
+
+			/*
+			 * Extend run as far as possible.
+			 */
+			for ((i + runAbove) < numPixels) && (runAbove < MAX_COPY_LENGTH) && (pixels[i+runAbove] == pixels[i+runAbove-width]) {
+				runAbove++
+			}
+
+		}
+
+		run := runLeft
+		distance := uint32(DISTANCE_LEFT)
+
+		/*
+		 * Prefer the longer run.
+		 */
+		if runAbove > runLeft {
+			run = runAbove
+			distance = DISTANCE_ABOVE
+		}
+
+		/*
+		 * Decide whether to emit a backward reference or a single pixel.
+		 */
+		if run >= MIN_COPY_LENGTH {
+			run32 := uint32(run)
+			lengthPrefix, lengthExtra, lengthExtraBits := prefixEncode(run32)
+			distancePrefix, distanceExtra, distanceExtraBits := prefixEncode(distance)
+
+			/*
+			 * Create backward reference.
+			 */
+			token := tokenStruct{
+				green:             NUM_LITERALS + lengthPrefix,
+				lengthExtra:       lengthExtra,
+				lengthExtraBits:   lengthExtraBits,
+				distanceSymbol:    distancePrefix,
+				distanceExtra:     distanceExtra,
+				distanceExtraBits: distanceExtraBits,
+			}
+
+			tokens = append(tokens, token)
+
+			/*
+			 * Copied pixels are inserted into the color cache as well.
+			 */
+			for j := 0; j < run; j++ {
+				argb := pixels[i+j]
+				insert(argb)
+			}
+
+			i += run
+		} else {
+			argb := pixels[i]
+			key := cacheIndex(argb)
+
+			/*
+			 * Check if pixel can be looked up from the color cache.
+			 */
+			if cacheValid[key] && (cache[key] == argb) {
+
+				/*
+				 * Create color cache lookup.
+				 */
+				token := tokenStruct{
+					green: NUM_LITERALS + NUM_LENGTH_CODES + key,
+				}
+
+				tokens = append(tokens, token)
+			} else {
+
+				/*
+				 * Create literal pixel.
+				 */
+				token := tokenStruct{
+					green: (argb >> 8) & 0xff,
+					red:   (argb >> 16) & 0xff,
+					blue:  argb & 0xff,
+					alpha: (argb >> 24) & 0xff,
+				}
+
+				tokens = append(tokens, token)
+			}
+
+			insert(argb)
+			i++
+		}
+
+	}
+
+	return tokens
+}
+
+/*
+ * Encodes an image in lossless WebP format and writes it to a stream.
+ */
+func Encode(w io.Writer, img image.Image) error {
+	err := EncodeWithQuality(w, img, QUALITY_LOSSLESS)
+	return err
+}
+
+/*
+ * Encodes an image in WebP format and writes it to a stream.
+ *
+ * The quality ranges from 0 to 100. At a quality of 100, the image is encoded
+ * losslessly. Below that, the precision of the pixels is reduced before they
+ * are encoded losslessly. Each color component loses one bit of precision for
+ * every started step of 20 below 100, up to five bits at a quality of 0. This
+ * is also known as near-lossless encoding.
+ */
+func EncodeWithQuality(w io.Writer, img image.Image, quality uint8) error {
+	bounds := img.Bounds()
+	width := bounds.Dx()
+	height := bounds.Dy()
+
+	/*
+	 * Check image dimensions and quality.
+	 */
+	if (width < 1) || (height < 1) {
+		return fmt.Errorf("%s", "Image must not be empty.")
+	} else if (width > MAX_DIMENSION) || (height > MAX_DIMENSION) {
+		return fmt.Errorf("Image dimensions must not exceed %d pixels.", MAX_DIMENSION)
+	} else if quality > QUALITY_LOSSLESS {
+		return fmt.Errorf("Quality must not exceed %d, but was %d.", QUALITY_LOSSLESS, quality)
+	} else {
+		pixels, alphaUsed := argbPixels(img)
+		loss := QUALITY_LOSSLESS - quality
+		bits := (loss + QUALITY_STEP - 1) / QUALITY_STEP
+
+		/*
+		 * Reduce precision of pixels unless encoding losslessly.
+		 */
+		if bits > 0 {
+			quantizePixels(pixels, bits)
+		}
+
+		tokens := tokenize(pixels, width)
+		cacheSize := 1 << COLOR_CACHE_BITS
+		numGreen := NUM_LITERALS + NUM_LENGTH_CODES + cacheSize
+		freqGreen := make([]uint32, numGreen)
+		freqRed := make([]uint32, NUM_LITERALS)
+		freqBlue := make([]uint32, NUM_LITERALS)
+		freqAlpha := make([]uint32, NUM_LITERALS)
+		freqDistance := make([]uint32, NUM_DISTANCE_CODES)
+
+		/*
+		 * Calculate symbol frequencies.
+		 */
+		for _, token := range tokens {
+			green := token.green
+			freqGreen[green]++
+
+			/*
+			 * Literal pixels use the other alphabets, backward references
+			 * use the distance alphabet.
+			 */
+			if green < NUM_LITERALS {
+				freqRed[token.red]++
+				freqBlue[token.blue]++
+				freqAlpha[token.alpha]++
+			} else if green < (NUM_LITERALS + NUM_LENGTH_CODES) {
+				freqDistance[token.distanceSymbol]++
+			}
+
+		}
+
+		bw := &bitWriterStruct{}
+		width32 := uint32(width)
+		height32 := uint32(height)
+		alphaBit := uint32(0)
+
+		/*
+		 * Indicate whether image uses alpha channel.
+		 */
+		if alphaUsed {
+			alphaBit = 1
+		}
+
+		bw.writeBits(VP8L_SIGNATURE, 8)
+		bw.writeBits(width32-1, 14)
+		bw.writeBits(height32-1, 14)
+		bw.writeBits(alphaBit, 1)
+		bw.writeBits(0, 3)
+		bw.writeBits(0, 1)
+		bw.writeBits(1, 1)
+		bw.writeBits(COLOR_CACHE_BITS, 4)
+		bw.writeBits(0, 1)
+		codeGreen := writePrefixCode(bw, freqGreen)
+		codeRed := writePrefixCode(bw, freqRed)
+		codeBlue := writePrefixCode(bw, freqBlue)
+		codeAlpha := writePrefixCode(bw, freqAlpha)
+		codeDistance := writePrefixCode(bw, freqDistance)
+
+		/*
+		 * Write the tokens.
+		 */
+		for _, token := range tokens {
+			green := token.green
+			codeGreen.write(bw, green)
+
+			/*
+			 * Write the remaining components of the token.
+			 */
+			if green < NUM_LITERALS {
+				codeRed.write(bw, token.red)
+				codeBlue.write(bw, token.blue)
+				codeAlpha.write(bw, token.alpha)
+			} else if green < (NUM_LITERALS + NUM_LENGTH_CODES) {
+				bw.writeBits(token.lengthExtra, token.lengthExtraBits)
+				codeDistance.write(bw, token.distanceSymbol)
+				bw.writeBits(token.distanceExtra, token.distanceExtraBits)
+			}
+
+		}
+
+		data := bw.bytes()
+		dataSize := len(data)
+		paddedSize := dataSize + (dataSize & 1)
+		riffSize := 12 + paddedSize
+		header := make([]byte, 20)
+		copy(header[0:4], "RIFF")
+		binary.LittleEndian.PutUint32(header[4:8], uint32(riffSize))
+		copy(header[8:12], "WEBP")
+		copy(header[12:16], "VP8L")
+		binary.LittleEndian.PutUint32(header[16:20], uint32(dataSize))
+		_, err := w.Write(header)
+
+		/*
+		 * Write image data if header was written.
+		 */
+		if err == nil {
+			_, err = w.Write(data)
+		}
+
+		/*
+		 * Pad chunk to an even size.
+		 */
+		if (err == nil) && (paddedSize != dataSize) {
+			padding := []byte{0}
+			_, err = w.Write(padding)
+		}
+
+		/*
+		 * Check if image could be written.
+		 */
+		if err != nil {
+			msg := err.Error()
+			return fmt.Errorf("Failed to write image: %s", msg)
+		} else {
+			return nil
+		}
+
+	}
+
+}
@@ -3,6 +3,9 @@ package main
 import (
 	"flag"
 	"fmt"
+	"strconv"
+	"strings"
+	_ "time/tzdata"
 
 	"github.com/andrepxx/location-visualizer/controller"
 )
@@ -11,15 +14,95 @@ const (
 	PREFETCH_LIMIT = 8
 )
 
+/*
+ * A bounding box in degrees.
+ */
+type boundingBoxStruct struct {
+	south float64
+	west  float64
+	north float64
+	east  float64
+}
+
+/*
+ * Parses a bounding box given as "south,west,north,east" in degrees, which is
+ * the order used by OSM and Overpass.
+ */
+func parseBoundingBox(value string) (boundingBoxStruct, error) {
+	fields := strings.Split(value, ",")
+	numFields := len(fields)
+
+	/*
+	 * Check if we have the right number of fields.
+	 */
+	if numFields != 4 {
+		return boundingBoxStruct{}, fmt.Errorf("Bounding box must consist of four comma-separated values (south,west,north,east), but had %d.", numFields)
+	} else {
+		values := [4]float64{}
+
+		/*
+		 * Parse each field.
+		 */
+		for i, field := range fields {
+			field = strings.TrimSpace(field)
+			v, err := strconv.ParseFloat(field, 64)
+
+			/*
+			 * Check if field could be parsed.
+			 */
+			if err != nil {
+				return boundingBoxStruct{}, fmt.Errorf("Failed to parse value '%s' of bounding box.", field)
+			}
+
+			values[i] = v
+		}
+
+		/*
+		 * Create bounding box.
+		 */
+		box := boundingBoxStruct{
+			south: values[0],
+			west:  values[1],
+			north: values[2],
+			east:  values[3],
+		}
+
+		/*
+		 * Validate bounding box.
+		 */
+		if (box.south < -90.0) || (box.south > 90.0) || (box.north < -90.0) || (box.north > 90.0) {
+			return boundingBoxStruct{}, fmt.Errorf("%s", "Latitudes of bounding box must be between -90 and 90 degrees.")
+		} else if (box.west < -180.0) || (box.west > 180.0) || (box.east < -180.0) || (box.east > 180.0) {
+			return boundingBoxStruct{}, fmt.Errorf("%s", "Longitudes of bounding box must be between -180 and 180 degrees.")
+		} else if box.south > box.north {
+			return boundingBoxStruct{}, fmt.Errorf("South (%f) is north of north (%f). Bounding box must be given as south,west,north,east.", box.south, box.north)
+		} else if (box.south == box.north) || (box.west == box.east) {
+			return boundingBoxStruct{}, fmt.Errorf("%s", "Bounding box must not have zero area.")
+		} else if box.west > box.east {
+			return boundingBoxStruct{}, fmt.Errorf("West (%f) is east of east (%f). Bounding boxes crossing the antimeridian are not supported, split them into two boxes instead.", box.west, box.east)
+		} else {
+			return box, nil
+		}
+
+	}
+
+}
+
 /*
  * The entry point of our program.
  */
 func main() {
 	prefetch := flag.Int("prefetch", -1, "Prefetch tile data from OSM up to this zoom level")
 	hard := flag.Bool("hard", false, "Disable the limitation of pre-fetching only low zoom levels")
+	bbox := flag.String("bbox", "", "Only prefetch tiles within this bounding box, given as south,west,north,east in degrees")
+	recent := flag.Uint("recent", 0, "Only prefetch tiles around the locations of this many most recent days")
+	minZoom := flag.Int("minzoom", 0, "Start prefetching tiles of a region at this zoom level")
 	flag.Parse()
 	prefetchZoom := *prefetch
 	hardFlag := *hard
+	bboxValue := *bbox
+	recentDays := *recent
+	minZoomLevel := *minZoom
 	cn := controller.CreateController()
 
 	/*
@@ -37,7 +120,44 @@ func main() {
 		}
 
 		zoomLevel := uint8(prefetchZoom)
-		cn.Prefetch(zoomLevel)
+
+		/*
+		 * Check whether to pre-fetch the entire world or a region.
+		 */
+		if (bboxValue != "") && (recentDays > 0) {
+			fmt.Printf("%s\n", "Options -bbox and -recent cannot be combined.")
+		} else if (minZoomLevel < 0) || (minZoomLevel > prefetchZoom) {
+			fmt.Printf("Minimum zoom level must be between 0 and %d, but was %d.\n", prefetchZoom, minZoomLevel)
+		} else if recentDays > 0 {
+			minZoomLevel8 := uint8(minZoomLevel)
+			cn.PrefetchRecent(recentDays, minZoomLevel8, zoomLevel)
+		} else if bboxValue == "" {
+
+			/*
+			 * The entire world is always pre-fetched from zoom level 0.
+			 */
+			if minZoomLevel > 0 {
+				fmt.Printf("%s\n", "Option -minzoom requires -bbox or -recent.")
+			} else {
+				cn.Prefetch(zoomLevel)
+			}
+
+		} else {
+			box, err := parseBoundingBox(bboxValue)
+
+			/*
+			 * Check if bounding box is valid.
+			 */
+			if err != nil {
+				msg := err.Error()
+				fmt.Printf("Invalid bounding box: %s\n", msg)
+			} else {
+				minZoomLevel8 := uint8(minZoomLevel)
+				cn.PrefetchRegion(minZoomLevel8, zoomLevel, box.south, box.west, box.north, box.east)
+			}
+
+		}
+
 	} else {
 		args := flag.Args()
 		cn.Operate(args)
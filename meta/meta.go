@@ -15,6 +15,19 @@ import (
 	"time"
 
 	"github.com/andrepxx/location-visualizer/filter"
+	"github.com/andrepxx/location-visualizer/geo/geoutil"
+	"github.com/andrepxx/location-visualizer/geo/gpx"
+)
+
+/*
+ * States of the JSON serializer.
+ */
+const (
+	JSON_STREAM_HEADER = iota
+	JSON_STREAM_ENTRIES
+	JSON_STREAM_TRAILER
+	JSON_STREAM_EOF
+	JSON_STREAM_ERROR
 )
 
 /*
@@ -22,8 +35,19 @@ import (
  */
 const (
 	EXPECTED_NUM_FIELDS = 10
+	FIELDS_PER_CUSTOM   = 4
+	KJ_PER_KCAL         = 4.184
 	LOWER_BEFORE_SHIFT  = (math.MaxUint64 / 10) + 1
+	PERIOD_MONTH        = "month"
+	PERIOD_WEEK         = "week"
+	PERIOD_YEAR         = "year"
+	PREVIEW_COLLISION   = 1
+	PREVIEW_MALFORMED   = 2
+	PREVIEW_NEW         = 0
+	REX_ACTIVITY_NAME   = "^[a-z][a-z0-9]*$"
 	REX_FLOAT           = "^\\s*\\d*\\.?\\d*\\s*$"
+	TCX_NAMESPACE       = "http://www.garmin.com/xmlschemas/TrainingCenterDatabase/v2"
+	TCX_TIME_FORMAT     = "2006-01-02T15:04:05.000Z"
 	TIME_DAY            = 24 * time.Hour
 )
 
@@ -56,12 +80,24 @@ type OtherActivity interface {
 	Zero() bool
 }
 
+/*
+ * A user-defined activity, like rowing or hiking, identified by its name.
+ */
+type CustomActivity interface {
+	DistanceKM() string
+	Duration() time.Duration
+	EnergyKJ() uint64
+	Name() string
+	Zero() bool
+}
+
 /*
  * An activity group is a set of activities carried out within a specific time
  * interval, typically a day.
  */
 type ActivityGroup interface {
 	Begin() time.Time
+	Custom() []CustomActivity
 	Cycling() CyclingActivity
 	Other() OtherActivity
 	Running() RunningActivity
@@ -72,16 +108,47 @@ type ActivityGroup interface {
  * Activity stats carry cumulative statistics about activities.
  */
 type ActivityStatistics interface {
+	Custom() []CustomActivity
+	Cycling() CyclingActivity
+	Other() OtherActivity
+	Running() RunningActivity
+}
+
+/*
+ * Activity statistics about the activity groups beginning within a calendar
+ * period, like a week, month or year.
+ *
+ * The period begins at Begin and ends just before End, both in UTC.
+ */
+type PeriodStatistics interface {
+	Begin() time.Time
+	Custom() []CustomActivity
 	Cycling() CyclingActivity
+	End() time.Time
+	GroupCount() uint32
 	Other() OtherActivity
 	Running() RunningActivity
 }
 
+/*
+ * Data structure to obtain information about a user-defined activity from
+ * external caller.
+ */
+type CustomActivityInfo struct {
+	Name       string
+	Duration   time.Duration
+	DistanceKM string
+	EnergyKJ   uint64
+}
+
 /*
  * Data structure to obtain information about activities from external caller.
  *
  * This is used to reduce the number of parameters passed to the method
  * Activities.Add(...).
+ *
+ * User-defined activities are omitted from JSON if there are none, so that
+ * activity data without them keeps its format.
  */
 type ActivityInfo struct {
 	Begin             time.Time
@@ -94,8 +161,59 @@ type ActivityInfo struct {
 	CyclingDistanceKM string
 	CyclingEnergyKJ   uint64
 	OtherEnergyKJ     uint64
+	Custom            []CustomActivityInfo `json:",omitempty"`
+}
+
+/*
+ * The outcome of importing a single record of activity data, as determined by
+ * a preview.
+ *
+ * Row is the index of the record within the imported data. If the record
+ * collides with an existing activity group, Existing is the index of that
+ * group, otherwise it is -1. Reason describes why a record is malformed or
+ * collides.
+ */
+type ImportPreviewEntry struct {
+	Row      uint32
+	Status   int
+	Begin    time.Time
+	Existing int
+	Reason   string
 }
 
+/*
+ * A problem found while verifying activity data.
+ *
+ * Group is the index of the activity group within the verified data.
+ */
+type VerificationIssue struct {
+	Group  uint32
+	Begin  time.Time
+	Reason string
+}
+
+/*
+ * The outcome of verifying activity data.
+ */
+type VerificationReport struct {
+	GroupCount uint32
+	Issues     []VerificationIssue
+}
+
+/*
+ * A location recorded during an activity.
+ */
+type TrackPoint struct {
+	Time        time.Time
+	LatitudeE7  int32
+	LongitudeE7 int32
+}
+
+/*
+ * Provides the track points recorded at or after begin, but before end.
+ */
+type TrackFunc func(begin time.Time, end time.Time) ([]TrackPoint, error)
+
 /*
  * All activities about which information can be stored.
  */
@@ -104,14 +222,23 @@ type Activities interface {
 	End(id uint32) (time.Time, error)
 	Export() ([]byte, error)
 	ExportCSV() (io.ReadSeeker, error)
+	ExportTCX(track TrackFunc) (io.ReadSeeker, error)
 	Get(id uint32) (ActivityGroup, error)
 	Import(buf []byte) error
+	ImportActivityGPX(data []byte, sport string) error
 	ImportCSV(data string) error
 	Length() uint32
+	PreviewCSV(data string) ([]ImportPreviewEntry, error)
+	Range(from time.Time, to time.Time) (uint32, uint32)
 	Remove(id uint32) error
+	RemoveRange(begin time.Time, end time.Time) (uint32, error)
 	Replace(id uint32, info *ActivityInfo) error
 	Revision() uint64
+	SerializeJSON() io.ReadCloser
 	Statistics() ActivityStatistics
+	StatisticsByPeriod(period string) ([]PeriodStatistics, error)
+	StatisticsRange(first uint32, end uint32) ActivityStatistics
+	Verify() VerificationReport
 }
 
 /*
@@ -154,11 +281,23 @@ type otherActivityStruct struct {
 	energyKJ uint64
 }
 
+/*
+ * Data structure storing information about a user-defined activity.
+ */
+type customActivityStruct struct {
+	name       string
+	duration   time.Duration
+	distanceKM unsignedFixed
+	energyKJ   uint64
+}
+
 /*
  * Data structure representing an activity group.
  *
  * An activity group is a set of activities carried out within a specific time
  * interval, typically a day.
+ *
+ * User-defined activities are ordered by their name and never zero.
  */
 type activityGroupStruct struct {
 	begin    time.Time
@@ -166,6 +305,7 @@ type activityGroupStruct struct {
 	running  runningActivityStruct
 	cycling  cyclingActivityStruct
 	other    otherActivityStruct
+	custom   []customActivityStruct
 }
 
 /*
@@ -176,15 +316,48 @@ type activityStatisticsStruct struct {
 	running runningActivityStruct
 	cycling cyclingActivityStruct
 	other   otherActivityStruct
+	custom  []customActivityStruct
+}
+
+/*
+ * Data structure representing statistics about the activity groups beginning
+ * within a calendar period.
+ */
+type periodStatisticsStruct struct {
+	begin      time.Time
+	end        time.Time
+	groupCount uint32
+	stats      *activityStatisticsStruct
+}
+
+/*
+ * Data structure representing the part of a track recorded on a single day.
+ */
+type trackDayStruct struct {
+	begin          time.Time
+	end            time.Time
+	distanceMeters float64
 }
 
 /*
  * Data structure storing all activities.
  */
 type activitiesStruct struct {
-	mutex    sync.RWMutex
-	groups   []activityGroupStruct
-	revision uint64
+	mutex     sync.RWMutex
+	groups    []activityGroupStruct
+	maxGroups uint64
+	revision  uint64
+}
+
+/*
+ * Data structure for serializing activities into JSON.
+ */
+type activitiesJsonSerializerStruct struct {
+	mutex      sync.Mutex
+	activities *activitiesStruct
+	buffer     *bytes.Buffer
+	groupId    int
+	state      int
 }
 
 /*
@@ -619,6 +792,93 @@ func (this *otherActivityStruct) Zero() bool {
 	return result
 }
 
+/*
+ * The distance travelled performing a user-defined activity.
+ */
+func (this *customActivityStruct) DistanceKM() string {
+	dist := &this.distanceKM
+	s := dist.String()
+	return s
+}
+
+/*
+ * The duration spent performing a user-defined activity.
+ */
+func (this *customActivityStruct) Duration() time.Duration {
+	dur := this.duration
+	return dur
+}
+
+/*
+ * The energy consumed performing a user-defined activity.
+ */
+func (this *customActivityStruct) EnergyKJ() uint64 {
+	e := this.energyKJ
+	return e
+}
+
+/*
+ * The name of a user-defined activity.
+ */
+func (this *customActivityStruct) Name() string {
+	name := this.name
+	return name
+}
+
+/*
+ * Checks whether this is the zero value of a user-defined activity.
+ */
+func (this *customActivityStruct) Zero() bool {
+	duration := this.duration
+	distanceKM := this.distanceKM
+	distanceKMZero := distanceKM.zero()
+	energyKJ := this.energyKJ
+	result := (duration == 0) && (distanceKMZero) && (energyKJ == 0)
+	return result
+}
+
+/*
+ * Provides access to user-defined activities.
+ */
+func customActivities(custom []customActivityStruct) []CustomActivity {
+	numCustom := len(custom)
+	result := make([]CustomActivity, numCustom)
+
+	/*
+	 * Provide a copy of each activity, so that it cannot be modified.
+	 */
+	for i, c := range custom {
+		cCopy := c
+		result[i] = &cCopy
+	}
+
+	return result
+}
+
+/*
+ * Checks whether a name may be used for a user-defined activity.
+ *
+ * Names consist of lower-case letters and digits, beginning with a letter, and
+ * must not be the name of a built-in activity.
+ */
+func ValidateActivityName(name string) error {
+	rex, _ := regexp.Compile(REX_ACTIVITY_NAME)
+
+	/*
+	 * Check if regular expression compiles and name is valid.
+	 */
+	if rex == nil {
+		return fmt.Errorf("Failed to compile regular expression: '%s'", REX_ACTIVITY_NAME)
+	} else if !rex.MatchString(name) {
+		return fmt.Errorf("Activity name '%s' does not match regular expression '%s'.", name, REX_ACTIVITY_NAME)
+	} else if (name == "cycling") || (name == "other") || (name == "running") {
+		return fmt.Errorf("Activity name '%s' is reserved for a built-in activity.", name)
+	} else {
+		return nil
+	}
+
+}
+
 /*
  * The point in time when the activities in this group began.
  */
@@ -627,6 +887,15 @@ func (this *activityGroupStruct) Begin() time.Time {
 	return b
 }
 
+/*
+ * The user-defined activities performed in this group, ordered by their name.
+ */
+func (this *activityGroupStruct) Custom() []CustomActivity {
+	custom := this.custom
+	result := customActivities(custom)
+	return result
+}
+
 /*
  * The cycling activity performed in this group.
  */
@@ -660,6 +929,68 @@ func (this *activityGroupStruct) WeightKG() string {
 	return s
 }
 
+/*
+ * Create activity info from this activity group.
+ */
+func (this *activityGroupStruct) info() ActivityInfo {
+	begin := this.Begin()
+	weightKG := this.WeightKG()
+	running := this.Running()
+	runningDuration := running.Duration()
+	runningDistanceKM := running.DistanceKM()
+	runningStepCount := running.StepCount()
+	runningEnergyKJ := running.EnergyKJ()
+	cycling := this.Cycling()
+	cyclingDuration := cycling.Duration()
+	cyclingDistanceKM := cycling.DistanceKM()
+	cyclingEnergyKJ := cycling.EnergyKJ()
+	other := this.Other()
+	otherEnergyKJ := other.EnergyKJ()
+	custom := []CustomActivityInfo(nil)
+
+	/*
+	 * Create info for each user-defined activity.
+	 */
+	for i := range this.custom {
+		c := &this.custom[i]
+		name := c.Name()
+		duration := c.Duration()
+		distanceKM := c.DistanceKM()
+		energyKJ := c.EnergyKJ()
+
+		/*
+		 * Create user-defined activity info.
+		 */
+		customInfo := CustomActivityInfo{
+			Name:       name,
+			Duration:   duration,
+			DistanceKM: distanceKM,
+			EnergyKJ:   energyKJ,
+		}
+
+		custom = append(custom, customInfo)
+	}
+
+	/*
+	 * Create activity info.
+	 */
+	info := ActivityInfo{
+		Begin:             begin,
+		WeightKG:          weightKG,
+		RunningDuration:   runningDuration,
+		RunningDistanceKM: runningDistanceKM,
+		RunningStepCount:  runningStepCount,
+		RunningEnergyKJ:   runningEnergyKJ,
+		CyclingDuration:   cyclingDuration,
+		CyclingDistanceKM: cyclingDistanceKM,
+		CyclingEnergyKJ:   cyclingEnergyKJ,
+		OtherEnergyKJ:     otherEnergyKJ,
+		Custom:            custom,
+	}
+
+	return info
+}
+
 /*
  * Create activity group from activity info.
  */
@@ -734,6 +1065,82 @@ func createActivityGroup(info *ActivityInfo) (activityGroupStruct, error) {
 		errResult = fmt.Errorf("Failed to parse weight: %s", msg)
 	}
 
+	customInfos := info.Custom
+	custom := []customActivityStruct{}
+	names := map[string]bool{}
+
+	/*
+	 * Create user-defined activities.
+	 */
+	for _, customInfo := range customInfos {
+		name := customInfo.Name
+		err := ValidateActivityName(name)
+
+		/*
+		 * Check if this is the first error.
+		 */
+		if errResult == nil && err != nil {
+			msg := err.Error()
+			errResult = fmt.Errorf("Invalid user-defined activity: %s", msg)
+		}
+
+		/*
+		 * Check if this is the first error.
+		 */
+		if errResult == nil && names[name] {
+			errResult = fmt.Errorf("User-defined activity '%s' occurs more than once.", name)
+		}
+
+		names[name] = true
+		duration := customInfo.Duration
+		distanceKMString := customInfo.DistanceKM
+		distanceKM, err := parseUnsignedFixed(distanceKMString, 1)
+
+		/*
+		 * Check if this is the first error.
+		 */
+		if errResult == nil && err != nil {
+			msg := err.Error()
+			errResult = fmt.Errorf("Failed to parse distance of '%s': %s", name, msg)
+		}
+
+		energyKJ := customInfo.EnergyKJ
+
+		/*
+		 * Create user-defined activity.
+		 */
+		customActivity := customActivityStruct{
+			name:       name,
+			duration:   duration,
+			distanceKM: distanceKM,
+			energyKJ:   energyKJ,
+		}
+
+		zero := customActivity.Zero()
+
+		/*
+		 * Only store user-defined activities which were performed.
+		 */
+		if !zero {
+			custom = append(custom, customActivity)
+		}
+
+	}
+
+	/*
+	 * Comparison function for sorting algorithm.
+	 */
+	less := func(i int, j int) bool {
+		ci := custom[i]
+		ciName := ci.name
+		cj := custom[j]
+		cjName := cj.name
+		result := ciName < cjName
+		return result
+	}
+
+	sort.Slice(custom, less)
+
 	/*
 	 * Create activity group.
 	 */
@@ -743,11 +1150,21 @@ func createActivityGroup(info *ActivityInfo) (activityGroupStruct, error) {
 		running:  runningActivity,
 		cycling:  cyclingActivity,
 		other:    otherActivity,
+		custom:   custom,
 	}
 
 	return g, errResult
 }
 
+/*
+ * Statistics about user-defined activities, ordered by their name.
+ */
+func (this *activityStatisticsStruct) Custom() []CustomActivity {
+	custom := this.custom
+	result := customActivities(custom)
+	return result
+}
+
 /*
  * Statistics about cycling activities.
  */
@@ -773,38 +1190,278 @@ func (this *activityStatisticsStruct) Running() RunningActivity {
 }
 
 /*
- * Search an activity group by its beginning time.
- *
- * If an activity group with this exact beginning time exists, the first return
- * value is the index of the activity group within the groups slice and the
- * second return value is true.
+ * The beginning of the period.
+ */
+func (this *periodStatisticsStruct) Begin() time.Time {
+	b := this.begin
+	return b
+}
+
+/*
+ * Statistics about user-defined activities within the period, ordered by
+ * their name.
+ */
+func (this *periodStatisticsStruct) Custom() []CustomActivity {
+	stats := this.stats
+	c := stats.Custom()
+	return c
+}
+
+/*
+ * Statistics about cycling activities within the period.
+ */
+func (this *periodStatisticsStruct) Cycling() CyclingActivity {
+	stats := this.stats
+	c := stats.Cycling()
+	return c
+}
+
+/*
+ * The end of the period, which is the beginning of the next period.
+ */
+func (this *periodStatisticsStruct) End() time.Time {
+	e := this.end
+	return e
+}
+
+/*
+ * The number of activity groups beginning within the period.
+ */
+func (this *periodStatisticsStruct) GroupCount() uint32 {
+	n := this.groupCount
+	return n
+}
+
+/*
+ * Statistics about other activities within the period.
+ */
+func (this *periodStatisticsStruct) Other() OtherActivity {
+	stats := this.stats
+	o := stats.Other()
+	return o
+}
+
+/*
+ * Statistics about running activities within the period.
+ */
+func (this *periodStatisticsStruct) Running() RunningActivity {
+	stats := this.stats
+	r := stats.Running()
+	return r
+}
+
+/*
+ * Determine the beginning and end of the period of the given kind, which
+ * contains a point in time.
  *
- * If an activity group with this exact beginning time does not exist, the
- * first return value is the index of the first activity group that starts
- * later, i. e. the index at which an activity group with the given beginning
- * time shall be inserted into the groups slice, and the second return value is
- * false.
+ * Periods are determined in UTC. Weeks begin on Monday, as in ISO 8601.
  */
-func (this *activitiesStruct) searchActivity(begin time.Time) (int, bool) {
-	groups := this.groups
-	numGroups := len(groups)
-	idxLeft := int(0)
+func periodBounds(t time.Time, period string) (time.Time, time.Time, error) {
+	tUTC := t.UTC()
+	year, month, day := tUTC.Date()
 
 	/*
-	 * The case that there are no groups requires special handling.
+	 * Determine bounds depending on the kind of period.
 	 */
-	if numGroups > 0 {
-		idxRight := numGroups - 1
+	switch period {
+	case PERIOD_MONTH:
+		begin := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+		end := begin.AddDate(0, 1, 0)
+		return begin, end, nil
+	case PERIOD_WEEK:
+		weekday := tUTC.Weekday()
+		weekdayInt := int(weekday)
+		daysSinceMonday := (weekdayInt + 6) % 7
+		midnight := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+		begin := midnight.AddDate(0, 0, -daysSinceMonday)
+		end := begin.AddDate(0, 0, 7)
+		return begin, end, nil
+	case PERIOD_YEAR:
+		begin := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+		end := begin.AddDate(1, 0, 0)
+		return begin, end, nil
+	default:
+		return time.Time{}, time.Time{}, fmt.Errorf("Unknown period '%s'. Must be '%s', '%s' or '%s'.", period, PERIOD_WEEK, PERIOD_MONTH, PERIOD_YEAR)
+	}
+
+}
+
+/*
+ * Generate JSON data and append it to the buffer.
+ *
+ * The output is identical to that of the Export function, but is generated
+ * one activity group at a time.
+ */
+func (this *activitiesJsonSerializerStruct) generateJSON() error {
+	state := this.state
+	buffer := this.buffer
+	errResult := error(nil)
+
+	switch state {
+	case JSON_STREAM_HEADER:
+		buffer.WriteRune('[')
+		state = JSON_STREAM_ENTRIES
+	case JSON_STREAM_ENTRIES:
+		activities := this.activities
+		groups := activities.groups
+		numGroups := len(groups)
+		groupId := this.groupId
 
 		/*
-		 * Binary search algorithm.
+		 * Check if there are more activity groups to serialize.
 		 */
-		for idxLeft <= idxRight {
-			diff := idxRight - idxLeft
-			diffHalf := diff / 2
-			idxPivot := idxLeft + diffHalf
-			pivot := groups[idxPivot]
-			pivotBegin := pivot.begin
+		if groupId >= numGroups {
+			state = JSON_STREAM_TRAILER
+		} else {
+			g := &groups[groupId]
+			info := g.info()
+			buf, err := json.MarshalIndent(info, "\t", "\t")
+
+			/*
+			 * Check if error occured during serialization.
+			 */
+			if err != nil {
+				msg := err.Error()
+				errResult = fmt.Errorf("Activity data serialization failed: %s", msg)
+				state = JSON_STREAM_ERROR
+			} else {
+
+				/*
+				 * Separate this activity group from the previous one.
+				 */
+				if groupId > 0 {
+					buffer.WriteRune(',')
+				}
+
+				buffer.WriteString("\n\t")
+				buffer.Write(buf)
+				this.groupId = groupId + 1
+			}
+
+		}
+
+	case JSON_STREAM_TRAILER:
+		groupId := this.groupId
+
+		/*
+		 * Non-empty lists end on a separate line.
+		 */
+		if groupId > 0 {
+			buffer.WriteRune('\n')
+		}
+
+		buffer.WriteRune(']')
+		state = JSON_STREAM_EOF
+	case JSON_STREAM_EOF:
+		errResult = io.EOF
+	default:
+		errResult = fmt.Errorf("%s", "Error during JSON serialization.")
+	}
+
+	this.state = state
+	return errResult
+}
+
+/*
+ * Implements the Read function from io.ReadCloser.
+ */
+func (this *activitiesJsonSerializerStruct) Read(buf []byte) (int, error) {
+	numBytesRead := 0
+	errResult := error(nil)
+	this.mutex.Lock()
+	activities := this.activities
+
+	/*
+	 * Check if serializer is already closed.
+	 */
+	if activities == nil {
+		errResult = fmt.Errorf("%s", "Activity serializer is already closed.")
+	} else {
+		buffer := this.buffer
+		numBytesAvailable := buffer.Len()
+		numBytesToRead := len(buf)
+		err := error(nil)
+
+		/*
+		 * Generate JSON until enough data is available or error occurs.
+		 */
+		for (numBytesAvailable < numBytesToRead) && (err == nil) {
+			err = this.generateJSON()
+			numBytesAvailable = buffer.Len()
+		}
+
+		numBytesRead, _ = buffer.Read(buf)
+
+		/*
+		 * Report errors only after the buffer has been drained.
+		 */
+		if (err != nil) && (buffer.Len() == 0) {
+			errResult = err
+		}
+
+	}
+
+	this.mutex.Unlock()
+	return numBytesRead, errResult
+}
+
+/*
+ * Implements the Close function from io.ReadCloser.
+ *
+ * This will yield the read lock on the underlying activities.
+ */
+func (this *activitiesJsonSerializerStruct) Close() error {
+	result := error(nil)
+	this.mutex.Lock()
+	activities := this.activities
+
+	/*
+	 * Check if serializer is already closed.
+	 */
+	if activities == nil {
+		result = fmt.Errorf("%s", "Activity serializer is already closed.")
+	} else {
+		activities.mutex.RUnlock()
+		this.activities = nil
+	}
+
+	this.mutex.Unlock()
+	return result
+}
+
+/*
+ * Search an activity group by its beginning time.
+ *
+ * If an activity group with this exact beginning time exists, the first return
+ * value is the index of the activity group within the groups slice and the
+ * second return value is true.
+ *
+ * If an activity group with this exact beginning time does not exist, the
+ * first return value is the index of the first activity group that starts
+ * later, i. e. the index at which an activity group with the given beginning
+ * time shall be inserted into the groups slice, and the second return value is
+ * false.
+ */
+func (this *activitiesStruct) searchActivity(begin time.Time) (int, bool) {
+	groups := this.groups
+	numGroups := len(groups)
+	idxLeft := int(0)
+
+	/*
+	 * The case that there are no groups requires special handling.
+	 */
+	if numGroups > 0 {
+		idxRight := numGroups - 1
+
+		/*
+		 * Binary search algorithm.
+		 */
+		for idxLeft <= idxRight {
+			diff := idxRight - idxLeft
+			diffHalf := diff / 2
+			idxPivot := idxLeft + diffHalf
+			pivot := groups[idxPivot]
+			pivotBegin := pivot.begin
 
 			/*
 			 * Check if we have to continue searching in left or
@@ -859,12 +1516,14 @@ func (this *activitiesStruct) Add(info *ActivityInfo) error {
 		this.mutex.Lock()
 		groups := this.groups
 		numGroups := len(groups)
+		numGroups64 := uint64(numGroups)
+		maxGroups := this.maxGroups
 
 		/*
 		 * Limit number of groups.
 		 */
-		if numGroups > math.MaxUint32 {
-			err = fmt.Errorf("There cannot be more than %d activity groups.", math.MaxUint32)
+		if numGroups64 >= maxGroups {
+			err = fmt.Errorf("There cannot be more than %d activity groups.", maxGroups)
 		} else {
 			begin := info.Begin
 			beginUTC := begin.UTC()
@@ -946,36 +1605,7 @@ func (this *activitiesStruct) Export() ([]byte, error) {
 	 * Iterate over all activity groups.
 	 */
 	for idx, g := range groups {
-		begin := g.Begin()
-		weightKG := g.WeightKG()
-		running := g.Running()
-		runningDuration := running.Duration()
-		runningDistanceKM := running.DistanceKM()
-		runningStepCount := running.StepCount()
-		runningEnergyKJ := running.EnergyKJ()
-		cycling := g.Cycling()
-		cyclingDuration := cycling.Duration()
-		cyclingDistanceKM := cycling.DistanceKM()
-		cyclingEnergyKJ := cycling.EnergyKJ()
-		other := g.Other()
-		otherEnergyKJ := other.EnergyKJ()
-
-		/*
-		 * Create activity info.
-		 */
-		info := ActivityInfo{
-			Begin:             begin,
-			WeightKG:          weightKG,
-			RunningDuration:   runningDuration,
-			RunningDistanceKM: runningDistanceKM,
-			RunningStepCount:  runningStepCount,
-			RunningEnergyKJ:   runningEnergyKJ,
-			CyclingDuration:   cyclingDuration,
-			CyclingDistanceKM: cyclingDistanceKM,
-			CyclingEnergyKJ:   cyclingEnergyKJ,
-			OtherEnergyKJ:     otherEnergyKJ,
-		}
-
+		info := g.info()
 		infos[idx] = info
 	}
 
@@ -996,6 +1626,10 @@ func (this *activitiesStruct) Export() ([]byte, error) {
 
 /*
  * Serialize activities to CSV structure.
+ *
+ * User-defined activities are appended to the fields of the built-in
+ * activities, with four fields each, namely the name, the duration, the
+ * distance and the energy, so records may differ in length.
  */
 func (this *activitiesStruct) ExportCSV() (io.ReadSeeker, error) {
 	buf := bytes.NewBuffer(nil)
@@ -1078,6 +1712,22 @@ func (this *activitiesStruct) ExportCSV() (io.ReadSeeker, error) {
 			otherEnergyKJString,
 		}
 
+		custom := group.custom
+
+		/*
+		 * Append fields for user-defined activities.
+		 */
+		for j := range custom {
+			c := &custom[j]
+			name := c.Name()
+			duration := c.Duration()
+			durationString := duration.String()
+			distanceKMString := c.DistanceKM()
+			energyKJ := c.EnergyKJ()
+			energyKJString := fmt.Sprintf("%d", energyKJ)
+			record = append(record, name, durationString, distanceKMString, energyKJString)
+		}
+
 		err = w.Write(record)
 	}
 
@@ -1109,6 +1759,206 @@ func (this *activitiesStruct) ExportCSV() (io.ReadSeeker, error) {
 
 }
 
+/*
+ * Write a single activity to a TCX document.
+ *
+ * The activity consists of a single lap, which carries the totals for
+ * duration, distance and energy. Track points are only written if there are
+ * any.
+ */
+func writeTCXActivity(buf *bytes.Buffer, sport string, begin time.Time, duration time.Duration, distanceKM string, energyKJ uint64, points []TrackPoint) error {
+	distanceKMFloat := float64(0.0)
+	err := error(nil)
+
+	/*
+	 * Parse distance if it was provided.
+	 */
+	if distanceKM != "" {
+		distanceKMFloat, err = strconv.ParseFloat(distanceKM, 64)
+	}
+
+	/*
+	 * Check if distance could be parsed.
+	 */
+	if err != nil {
+		msg := err.Error()
+		return fmt.Errorf("Failed to parse distance: %s", msg)
+	} else {
+		beginUTC := begin.UTC()
+		beginString := beginUTC.Format(TCX_TIME_FORMAT)
+		seconds := duration.Seconds()
+		meters := 1000.0 * distanceKMFloat
+		energyKJFloat := float64(energyKJ)
+		calories := math.Round(energyKJFloat / KJ_PER_KCAL)
+
+		/*
+		 * TCX stores calories as an unsigned 16-bit integer.
+		 */
+		if calories > math.MaxUint16 {
+			calories = math.MaxUint16
+		}
+
+		fmt.Fprintf(buf, "\t\t<Activity Sport=\"%s\">\n", sport)
+		fmt.Fprintf(buf, "\t\t\t<Id>%s</Id>\n", beginString)
+		fmt.Fprintf(buf, "\t\t\t<Lap StartTime=\"%s\">\n", beginString)
+		fmt.Fprintf(buf, "\t\t\t\t<TotalTimeSeconds>%.3f</TotalTimeSeconds>\n", seconds)
+		fmt.Fprintf(buf, "\t\t\t\t<DistanceMeters>%.1f</DistanceMeters>\n", meters)
+		fmt.Fprintf(buf, "\t\t\t\t<Calories>%.0f</Calories>\n", calories)
+		buf.WriteString("\t\t\t\t<Intensity>Active</Intensity>\n")
+		buf.WriteString("\t\t\t\t<TriggerMethod>Manual</TriggerMethod>\n")
+		numPoints := len(points)
+
+		/*
+		 * Only write track if there are track points.
+		 */
+		if numPoints > 0 {
+			buf.WriteString("\t\t\t\t<Track>\n")
+
+			/*
+			 * Write each track point.
+			 */
+			for _, point := range points {
+				t := point.Time
+				tUTC := t.UTC()
+				timeString := tUTC.Format(TCX_TIME_FORMAT)
+				latitude := float64(point.LatitudeE7) * 1e-7
+				longitude := float64(point.LongitudeE7) * 1e-7
+				buf.WriteString("\t\t\t\t\t<Trackpoint>\n")
+				fmt.Fprintf(buf, "\t\t\t\t\t\t<Time>%s</Time>\n", timeString)
+				buf.WriteString("\t\t\t\t\t\t<Position>\n")
+				fmt.Fprintf(buf, "\t\t\t\t\t\t\t<LatitudeDegrees>%.7f</LatitudeDegrees>\n", latitude)
+				fmt.Fprintf(buf, "\t\t\t\t\t\t\t<LongitudeDegrees>%.7f</LongitudeDegrees>\n", longitude)
+				buf.WriteString("\t\t\t\t\t\t</Position>\n")
+				buf.WriteString("\t\t\t\t\t</Trackpoint>\n")
+			}
+
+			buf.WriteString("\t\t\t\t</Track>\n")
+		}
+
+		buf.WriteString("\t\t\t</Lap>\n")
+		buf.WriteString("\t\t</Activity>\n")
+		return nil
+	}
+
+}
+
+/*
+ * Serialize activities to a Training Center XML (TCX) document.
+ *
+ * Each running and cycling activity becomes an activity with the sport
+ * "Running" or "Biking", respectively, and a single lap carrying its totals.
+ * Groups without running or cycling, but with energy consumed by other
+ * activities, become an activity with the sport "Other".
+ *
+ * If track is not nil, it is called with the time range of each group, which
+ * extends up to the beginning of the next group, or one day for the last
+ * group. The track points it returns are attached to the activity of the
+ * group, unless the group contains both running and cycling, since the track
+ * cannot be split between them.
+ *
+ * User-defined activities are not exported.
+ */
+func (this *activitiesStruct) ExportTCX(track TrackFunc) (io.ReadSeeker, error) {
+	this.mutex.RLock()
+	groups := this.groups
+	numGroups := len(groups)
+	groupsCopy := make([]activityGroupStruct, numGroups)
+	copy(groupsCopy, groups)
+	this.mutex.RUnlock()
+	buf := bytes.NewBuffer(nil)
+	buf.WriteString("<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n")
+	fmt.Fprintf(buf, "<TrainingCenterDatabase xmlns=\"%s\">\n", TCX_NAMESPACE)
+	buf.WriteString("\t<Activities>\n")
+	err := error(nil)
+
+	/*
+	 * Iterate over all activity groups.
+	 */
+	for i := int(0); (i < numGroups) && (err == nil); i++ {
+		group := &groupsCopy[i]
+		begin := group.Begin()
+		end := begin.Add(TIME_DAY)
+		next := i + 1
+
+		/*
+		 * The group ends when the next group begins.
+		 */
+		if next < numGroups {
+			nextGroup := &groupsCopy[next]
+			end = nextGroup.Begin()
+		}
+
+		running := group.Running()
+		runningZero := running.Zero()
+		cycling := group.Cycling()
+		cyclingZero := cycling.Zero()
+		other := group.Other()
+		otherZero := other.Zero()
+		points := []TrackPoint(nil)
+
+		/*
+		 * Fetch track if the group contains a single sport.
+		 */
+		if (track != nil) && (runningZero != cyclingZero) {
+			points, err = track(begin, end)
+
+			/*
+			 * Check if track could be obtained.
+			 */
+			if err != nil {
+				msg := err.Error()
+				beginString := begin.Format(time.RFC3339)
+				err = fmt.Errorf("Failed to obtain track for activity group beginning at %s: %s", beginString, msg)
+			}
+
+		}
+
+		/*
+		 * Write running activity, if non-zero.
+		 */
+		if (err == nil) && !runningZero {
+			duration := running.Duration()
+			distanceKM := running.DistanceKM()
+			energyKJ := running.EnergyKJ()
+			err = writeTCXActivity(buf, "Running", begin, duration, distanceKM, energyKJ, points)
+		}
+
+		/*
+		 * Write cycling activity, if non-zero.
+		 */
+		if (err == nil) && !cyclingZero {
+			duration := cycling.Duration()
+			distanceKM := cycling.DistanceKM()
+			energyKJ := cycling.EnergyKJ()
+			err = writeTCXActivity(buf, "Biking", begin, duration, distanceKM, energyKJ, points)
+		}
+
+		/*
+		 * Write other activities, if they are the only ones in this group.
+		 */
+		if (err == nil) && runningZero && cyclingZero && !otherZero {
+			energyKJ := other.EnergyKJ()
+			err = writeTCXActivity(buf, "Other", begin, 0, "", energyKJ, nil)
+		}
+
+	}
+
+	/*
+	 * Check if error occured during serialization.
+	 */
+	if err != nil {
+		msg := err.Error()
+		return nil, fmt.Errorf("Error during serialization: %s", msg)
+	} else {
+		buf.WriteString("\t</Activities>\n")
+		buf.WriteString("</TrainingCenterDatabase>\n")
+		content := buf.Bytes()
+		r := bytes.NewReader(content)
+		return r, nil
+	}
+
+}
+
 /*
  * Obtain a certain activity group.
  */
@@ -1147,69 +1997,84 @@ func (this *activitiesStruct) Import(buf []byte) error {
 		msg := err.Error()
 		return fmt.Errorf("Error deserializing activity data: %s", msg)
 	} else {
+		numInfos := len(infos)
+		numInfos64 := uint64(numInfos)
 		this.mutex.Lock()
 		groups := this.groups
-		firstError := error(nil)
-		idxFirstErr := uint64(0)
-		numErrors := uint64(0)
+		numGroups := len(groups)
+		numGroups64 := uint64(numGroups)
+		maxGroups := this.maxGroups
 
 		/*
-		 * Iterate over activity infos.
+		 * Limit number of groups.
 		 */
-		for idx, info := range infos {
-			g, err := createActivityGroup(&info)
+		if numGroups64+numInfos64 > maxGroups {
+			this.mutex.Unlock()
+			return fmt.Errorf("There cannot be more than %d activity groups.", maxGroups)
+		} else {
+			firstError := error(nil)
+			idxFirstErr := uint64(0)
+			numErrors := uint64(0)
 
 			/*
-			 * Check if activity group could be parsed.
+			 * Iterate over activity infos.
 			 */
-			if err != nil {
+			for idx, info := range infos {
+				g, err := createActivityGroup(&info)
 
 				/*
-				 * Store first error occuring.
+				 * Check if activity group could be parsed.
 				 */
-				if firstError == nil {
-					firstError = err
-					idxFirstErr = uint64(idx)
-				}
+				if err != nil {
 
-				/*
-				 * Increment error count.
-				 */
-				if numErrors < math.MaxUint64 {
-					numErrors++
+					/*
+					 * Store first error occuring.
+					 */
+					if firstError == nil {
+						firstError = err
+						idxFirstErr = uint64(idx)
+					}
+
+					/*
+					 * Increment error count.
+					 */
+					if numErrors < math.MaxUint64 {
+						numErrors++
+					}
+
+				} else {
+					groups = append(groups, g)
 				}
 
-			} else {
-				groups = append(groups, g)
 			}
 
-		}
+			/*
+			 * Comparison function for sorting algorithm.
+			 */
+			less := func(i int, j int) bool {
+				gi := groups[i]
+				giBegin := gi.begin
+				gj := groups[j]
+				gjBegin := gj.begin
+				result := giBegin.Before(gjBegin)
+				return result
+			}
 
-		/*
-		 * Comparison function for sorting algorithm.
-		 */
-		less := func(i int, j int) bool {
-			gi := groups[i]
-			giBegin := gi.begin
-			gj := groups[j]
-			gjBegin := gj.begin
-			result := giBegin.Before(gjBegin)
-			return result
-		}
+			sort.SliceStable(groups, less)
+			this.groups = groups
+			this.revision++
+			this.mutex.Unlock()
 
-		sort.SliceStable(groups, less)
-		this.groups = groups
-		this.revision++
-		this.mutex.Unlock()
+			/*
+			 * Check if error occured.
+			 */
+			if firstError != nil {
+				msg := firstError.Error()
+				return fmt.Errorf("Error deserializing activity data: %d erroneous activity groups, first at group number %d: %s", numErrors, idxFirstErr, msg)
+			} else {
+				return nil
+			}
 
-		/*
-		 * Check if error occured.
-		 */
-		if firstError != nil {
-			msg := firstError.Error()
-			return fmt.Errorf("Error deserializing activity data: %d erroneous activity groups, first at group number %d: %s", numErrors, idxFirstErr, msg)
-		} else {
-			return nil
 		}
 
 	}
@@ -1217,339 +2082,676 @@ func (this *activitiesStruct) Import(buf []byte) error {
 }
 
 /*
- * Import activities from CSV.
+ * Parse a single record of activity data in CSV format.
+ *
+ * The fields of the built-in activities may be followed by four fields for
+ * each user-defined activity, as written by ExportCSV.
  */
-func (this *activitiesStruct) ImportCSV(data string) error {
-	rstr := strings.NewReader(data)
-	rcsv := csv.NewReader(rstr)
-	records, err := rcsv.ReadAll()
+func parseCSVRecord(record []string) (activityGroupStruct, error) {
+	numFields := len(record)
+	numCustomFields := numFields - EXPECTED_NUM_FIELDS
 
 	/*
-	 * Check if an error occured.
+	 * Check that sufficient number of fields is present.
 	 */
-	if err != nil {
-		msg := err.Error()
-		return fmt.Errorf("Error importing activity data from CSV: %s", msg)
+	if numFields < EXPECTED_NUM_FIELDS {
+		return activityGroupStruct{}, fmt.Errorf("Expected %d fields, found %d.", EXPECTED_NUM_FIELDS, numFields)
+	} else if (numCustomFields % FIELDS_PER_CUSTOM) != 0 {
+		return activityGroupStruct{}, fmt.Errorf("Expected %d fields plus %d fields per user-defined activity, found %d.", EXPECTED_NUM_FIELDS, FIELDS_PER_CUSTOM, numFields)
 	} else {
-		this.mutex.Lock()
-		groups := this.groups
-		numGroups := len(groups)
-		groupsCopy := make([]activityGroupStruct, numGroups)
-		copy(groupsCopy, groups)
-		firstError := error(nil)
-		idxFirstErr := uint64(0)
-		numErrors := uint64(0)
+		errResult := error(nil)
+		beginString := record[0]
+		begin, err := filter.ParseTime(beginString, false, false)
 
 		/*
-		 * Iterate over all records and parse activity data.
+		 * Check if begin time could be parsed.
 		 */
-		for idx, record := range records {
-			recordHasErrors := false
-			numFields := len(record)
+		if err != nil {
+
+			/*
+			 * Store first error occuring.
+			 */
+			if errResult == nil {
+				msg := err.Error()
+				errResult = fmt.Errorf("Failed to parse begin time stamp: %s", msg)
+			}
+
+		}
+
+		weightKG := record[1]
+
+		/*
+		 * Allow for empty weight.
+		 */
+		if weightKG == "" {
+			weightKG = "0.0"
+		}
+
+		runningDurationString := record[2]
+		runningDuration := time.Duration(0)
+
+		/*
+		 * Allow for empty running duration.
+		 */
+		if runningDurationString != "" {
+			runningDuration, err = time.ParseDuration(runningDurationString)
 
 			/*
-			 * Check that sufficient number of fields is present.
+			 * Check if running duration could be parsed.
 			 */
-			if numFields < EXPECTED_NUM_FIELDS {
+			if err != nil {
 
 				/*
 				 * Store first error occuring.
 				 */
-				if firstError == nil {
-					firstError = fmt.Errorf("Expected %d fields, found %d.", EXPECTED_NUM_FIELDS, numFields)
-					idxFirstErr = uint64(idx)
+				if errResult == nil {
+					msg := err.Error()
+					errResult = fmt.Errorf("Failed to parse running duration: %s", msg)
 				}
 
+			}
+
+		}
+
+		runningDistanceKM := record[3]
+
+		/*
+		 * Allow for empty running distance.
+		 */
+		if runningDistanceKM == "" {
+			runningDistanceKM = "0.0"
+		}
+
+		runningStepCountString := record[4]
+		runningStepCount := uint64(0)
+
+		/*
+		 * Allow for empty running step count.
+		 */
+		if runningStepCountString != "" {
+			runningStepCount, err = strconv.ParseUint(runningStepCountString, 10, 64)
+
+			/*
+			 * Check if running step count could be parsed.
+			 */
+			if err != nil {
+
 				/*
-				 * Increment error count.
+				 * Store first error occuring.
 				 */
-				if !recordHasErrors && numErrors < math.MaxUint64 {
-					numErrors++
-					recordHasErrors = true
+				if errResult == nil {
+					msg := err.Error()
+					errResult = fmt.Errorf("Failed to parse running step count: %s", msg)
 				}
 
-			} else {
-				beginString := record[0]
-				begin, err := filter.ParseTime(beginString, false, false)
+			}
+
+		}
+
+		runningEnergyKJString := record[5]
+		runningEnergyKJ := uint64(0)
+
+		/*
+		 * Allow for empty running energy.
+		 */
+		if runningEnergyKJString != "" {
+			runningEnergyKJ, err = strconv.ParseUint(runningEnergyKJString, 10, 64)
+
+			/*
+			 * Check if running energy could be parsed.
+			 */
+			if err != nil {
 
 				/*
-				 * Check if begin time could be parsed.
+				 * Store first error occuring.
 				 */
-				if err != nil {
+				if errResult == nil {
+					msg := err.Error()
+					errResult = fmt.Errorf("Failed to parse running energy: %s", msg)
+				}
 
-					/*
-					 * Store first error occuring.
-					 */
-					if firstError == nil {
-						msg := err.Error()
-						firstError = fmt.Errorf("Failed to parse begin time stamp: %s", msg)
-						idxFirstErr = uint64(idx)
-					}
+			}
 
-					/*
-					 * Increment error count.
-					 */
-					if !recordHasErrors && numErrors < math.MaxUint64 {
-						numErrors++
-						recordHasErrors = true
-					}
+		}
 
-				}
+		cyclingDurationString := record[6]
+		cyclingDuration := time.Duration(0)
 
-				weightKG := record[1]
+		/*
+		 * Allow for empty cycling duration.
+		 */
+		if cyclingDurationString != "" {
+			cyclingDuration, err = time.ParseDuration(cyclingDurationString)
+
+			/*
+			 * Check if cycling duration could be parsed.
+			 */
+			if err != nil {
 
 				/*
-				 * Allow for empty weight.
+				 * Store first error occuring.
 				 */
-				if weightKG == "" {
-					weightKG = "0.0"
+				if errResult == nil {
+					msg := err.Error()
+					errResult = fmt.Errorf("Failed to parse cycling duration: %s", msg)
 				}
 
-				runningDurationString := record[2]
-				runningDuration := time.Duration(0)
+			}
 
-				/*
-				 * Allow for empty running duration.
-				 */
-				if runningDurationString != "" {
-					runningDuration, err = time.ParseDuration(runningDurationString)
+		}
 
-					/*
-					 * Check if running duration could be parsed.
-					 */
-					if err != nil {
+		cyclingDistanceKM := record[7]
 
-						/*
-						 * Store first error occuring.
-						 */
-						if firstError == nil {
-							msg := err.Error()
-							firstError = fmt.Errorf("Failed to parse running duration: %s", msg)
-							idxFirstErr = uint64(idx)
-						}
+		/*
+		 * Allow for empty cycling distance.
+		 */
+		if cyclingDistanceKM == "" {
+			cyclingDistanceKM = "0.0"
+		}
 
-						/*
-						 * Increment error count.
-						 */
-						if !recordHasErrors && numErrors < math.MaxUint64 {
-							numErrors++
-							recordHasErrors = true
-						}
+		cyclingEnergyKJString := record[8]
+		cyclingEnergyKJ := uint64(0)
 
-					}
+		/*
+		 * Allow for empty cycling energy.
+		 */
+		if cyclingEnergyKJString != "" {
+			cyclingEnergyKJ, err = strconv.ParseUint(cyclingEnergyKJString, 10, 64)
 
+			/*
+			 * Check if cycling energy could be parsed.
+			 */
+			if err != nil {
+
+				/*
+				 * Store first error occuring.
+				 */
+				if errResult == nil {
+					msg := err.Error()
+					errResult = fmt.Errorf("Failed to parse cycling energy: %s", msg)
 				}
 
-				runningDistanceKM := record[3]
+			}
+
+		}
+
+		otherEnergyKJString := record[9]
+		otherEnergyKJ := uint64(0)
+
+		/*
+		 * Allow for empty other energy.
+		 */
+		if otherEnergyKJString != "" {
+			otherEnergyKJ, err = strconv.ParseUint(otherEnergyKJString, 10, 64)
+
+			/*
+			 * Check if other energy could be parsed.
+			 */
+			if err != nil {
 
 				/*
-				 * Allow for empty running distance.
+				 * Store first error occuring.
 				 */
-				if runningDistanceKM == "" {
-					runningDistanceKM = "0.0"
+				if errResult == nil {
+					msg := err.Error()
+					errResult = fmt.Errorf("Failed to parse other energy: %s", msg)
 				}
 
-				runningStepCountString := record[4]
-				runningStepCount := uint64(0)
+			}
+
+		}
+
+		custom := []CustomActivityInfo(nil)
+
+		/*
+		 * Parse fields of user-defined activities.
+		 */
+		for i := EXPECTED_NUM_FIELDS; i < numFields; i += FIELDS_PER_CUSTOM {
+			name := record[i]
+			durationString := record[i+1]
+			duration := time.Duration(0)
+
+			/*
+			 * Allow for empty duration.
+			 */
+			if durationString != "" {
+				duration, err = time.ParseDuration(durationString)
 
 				/*
-				 * Allow for empty running step count.
+				 * Check if duration could be parsed.
 				 */
-				if runningStepCountString != "" {
-					runningStepCount, err = strconv.ParseUint(runningStepCountString, 10, 64)
+				if err != nil {
 
 					/*
-					 * Check if running step count could be parsed.
+					 * Store first error occuring.
 					 */
-					if err != nil {
+					if errResult == nil {
+						msg := err.Error()
+						errResult = fmt.Errorf("Failed to parse duration of '%s': %s", name, msg)
+					}
 
-						/*
-						 * Store first error occuring.
-						 */
-						if firstError == nil {
-							msg := err.Error()
-							firstError = fmt.Errorf("Failed to parse running step count: %s", msg)
-							idxFirstErr = uint64(idx)
-						}
+				}
 
-						/*
-						 * Increment error count.
-						 */
-						if !recordHasErrors && numErrors < math.MaxUint64 {
-							numErrors++
-							recordHasErrors = true
-						}
+			}
 
-					}
+			distanceKM := record[i+2]
 
-				}
+			/*
+			 * Allow for empty distance.
+			 */
+			if distanceKM == "" {
+				distanceKM = "0.0"
+			}
+
+			energyKJString := record[i+3]
+			energyKJ := uint64(0)
 
-				runningEnergyKJString := record[5]
-				runningEnergyKJ := uint64(0)
+			/*
+			 * Allow for empty energy.
+			 */
+			if energyKJString != "" {
+				energyKJ, err = strconv.ParseUint(energyKJString, 10, 64)
 
 				/*
-				 * Allow for empty running energy.
+				 * Check if energy could be parsed.
 				 */
-				if runningEnergyKJString != "" {
-					runningEnergyKJ, err = strconv.ParseUint(runningEnergyKJString, 10, 64)
+				if err != nil {
 
 					/*
-					 * Check if running energy could be parsed.
+					 * Store first error occuring.
 					 */
-					if err != nil {
+					if errResult == nil {
+						msg := err.Error()
+						errResult = fmt.Errorf("Failed to parse energy of '%s': %s", name, msg)
+					}
 
-						/*
-						 * Store first error occuring.
-						 */
-						if firstError == nil {
-							msg := err.Error()
-							firstError = fmt.Errorf("Failed to parse running energy: %s", msg)
-							idxFirstErr = uint64(idx)
-						}
+				}
 
-						/*
-						 * Increment error count.
-						 */
-						if !recordHasErrors && numErrors < math.MaxUint64 {
-							numErrors++
-							recordHasErrors = true
-						}
+			}
 
-					}
+			/*
+			 * Create user-defined activity info.
+			 */
+			customInfo := CustomActivityInfo{
+				Name:       name,
+				Duration:   duration,
+				DistanceKM: distanceKM,
+				EnergyKJ:   energyKJ,
+			}
 
-				}
+			custom = append(custom, customInfo)
+		}
+
+		/*
+		 * Create activity info.
+		 */
+		info := ActivityInfo{
+			Begin:             begin,
+			WeightKG:          weightKG,
+			RunningDuration:   runningDuration,
+			RunningDistanceKM: runningDistanceKM,
+			RunningStepCount:  runningStepCount,
+			RunningEnergyKJ:   runningEnergyKJ,
+			CyclingDuration:   cyclingDuration,
+			CyclingDistanceKM: cyclingDistanceKM,
+			CyclingEnergyKJ:   cyclingEnergyKJ,
+			OtherEnergyKJ:     otherEnergyKJ,
+			Custom:            custom,
+		}
+
+		/*
+		 * Only create activity group if there were no errors so far.
+		 */
+		if errResult != nil {
+			return activityGroupStruct{}, errResult
+		} else {
+			g, err := createActivityGroup(&info)
+			return g, err
+		}
+
+	}
+
+}
+
+/*
+ * Split a track into the parts recorded on each day in UTC.
+ *
+ * The track points must be ordered by their time stamp. The distance of each
+ * part is the sum of the great-circle distances between consecutive track
+ * points of that day.
+ */
+func splitTrackByDay(points []TrackPoint) []trackDayStruct {
+	days := []trackDayStruct{}
+	gu := geoutil.Create()
+	previous := TrackPoint{}
+
+	/*
+	 * Iterate over all track points.
+	 */
+	for i, point := range points {
+		t := point.Time
+		year, month, day := t.Date()
+		previousTime := previous.Time
+		previousYear, previousMonth, previousDay := previousTime.Date()
+		sameDay := (year == previousYear) && (month == previousMonth) && (day == previousDay)
+
+		/*
+		 * Start a new day or extend the current one.
+		 */
+		if (i == 0) || !sameDay {
 
-				cyclingDurationString := record[6]
-				cyclingDuration := time.Duration(0)
+			/*
+			 * Create part of track for this day.
+			 */
+			trackDay := trackDayStruct{
+				begin: t,
+				end:   t,
+			}
+
+			days = append(days, trackDay)
+		} else {
+			idxLast := len(days) - 1
+			trackDay := &days[idxLast]
+			meters := gu.DistanceE7(previous.LatitudeE7, previous.LongitudeE7, point.LatitudeE7, point.LongitudeE7)
+			trackDay.end = t
+			trackDay.distanceMeters += meters
+		}
+
+		previous = point
+	}
+
+	return days
+}
+
+/*
+ * Import activities derived from a track in GPX format.
+ *
+ * The sport is either "running" or "cycling". One activity group is created
+ * for each day in UTC on which track points were recorded. It begins at the
+ * first track point of that day. Its duration is the time between the first
+ * and the last track point of that day and its distance is the sum of the
+ * great-circle distances between consecutive track points. Track points
+ * without a time stamp are ignored, as are days on which neither time passed
+ * nor distance was covered.
+ *
+ * Fails as a whole if an activity group with the same beginning as one of the
+ * derived groups already exists.
+ */
+func (this *activitiesStruct) ImportActivityGPX(data []byte, sport string) error {
+
+	/*
+	 * Check if sport is supported.
+	 */
+	if (sport != "cycling") && (sport != "running") {
+		return fmt.Errorf("Unknown sport '%s'. Must be 'cycling' or 'running'.", sport)
+	} else {
+		db, err := gpx.FromBytes(data)
+
+		/*
+		 * Check if GPX data could be parsed.
+		 */
+		if err != nil {
+			msg := err.Error()
+			return fmt.Errorf("Error importing activity data from GPX: %s", msg)
+		} else {
+			numLocations := db.LocationCount()
+			points := []TrackPoint{}
+
+			/*
+			 * Collect all track points with a time stamp.
+			 */
+			for i := 0; i < numLocations; i++ {
+				location, err := db.LocationAt(i)
 
 				/*
-				 * Allow for empty cycling duration.
+				 * Skip locations which cannot be read or have no
+				 * time stamp.
 				 */
-				if cyclingDurationString != "" {
-					cyclingDuration, err = time.ParseDuration(cyclingDurationString)
+				if err == nil {
+					timestamp := location.Timestamp()
 
 					/*
-					 * Check if cycling duration could be parsed.
+					 * Check if location has a time stamp.
 					 */
-					if err != nil {
+					if timestamp != 0 {
+						timestampSigned := int64(timestamp)
+						t := time.UnixMilli(timestampSigned)
+						tUTC := t.UTC()
+						latitudeE7 := location.Latitude()
+						longitudeE7 := location.Longitude()
 
 						/*
-						 * Store first error occuring.
+						 * Create track point.
 						 */
-						if firstError == nil {
-							msg := err.Error()
-							firstError = fmt.Errorf("Failed to parse cycling duration: %s", msg)
-							idxFirstErr = uint64(idx)
-						}
-
-						/*
-						 * Increment error count.
-						 */
-						if !recordHasErrors && numErrors < math.MaxUint64 {
-							numErrors++
-							recordHasErrors = true
+						point := TrackPoint{
+							Time:        tUTC,
+							LatitudeE7:  latitudeE7,
+							LongitudeE7: longitudeE7,
 						}
 
+						points = append(points, point)
 					}
 
 				}
 
-				cyclingDistanceKM := record[7]
+			}
+
+			/*
+			 * Comparison function for sorting algorithm.
+			 */
+			less := func(i int, j int) bool {
+				pi := points[i]
+				piTime := pi.Time
+				pj := points[j]
+				pjTime := pj.Time
+				result := piTime.Before(pjTime)
+				return result
+			}
+
+			sort.SliceStable(points, less)
+			days := splitTrackByDay(points)
+			groups := []activityGroupStruct{}
+			errResult := error(nil)
+
+			/*
+			 * Create an activity group for each day.
+			 */
+			for _, trackDay := range days {
+				begin := trackDay.begin
+				end := trackDay.end
+				duration := end.Sub(begin)
+				distanceKMFloat := trackDay.distanceMeters / 1000.0
+				distanceKM := fmt.Sprintf("%.1f", distanceKMFloat)
+
+				/*
+				 * Create activity info.
+				 */
+				info := ActivityInfo{
+					Begin: begin,
+				}
 
 				/*
-				 * Allow for empty cycling distance.
+				 * Fill in the activity of the chosen sport.
 				 */
-				if cyclingDistanceKM == "" {
-					cyclingDistanceKM = "0.0"
+				if sport == "cycling" {
+					info.CyclingDuration = duration
+					info.CyclingDistanceKM = distanceKM
+				} else {
+					info.RunningDuration = duration
+					info.RunningDistanceKM = distanceKM
 				}
 
-				cyclingEnergyKJString := record[8]
-				cyclingEnergyKJ := uint64(0)
+				g, err := createActivityGroup(&info)
 
 				/*
-				 * Allow for empty cycling energy.
+				 * Check if this is the first error.
 				 */
-				if cyclingEnergyKJString != "" {
-					cyclingEnergyKJ, err = strconv.ParseUint(cyclingEnergyKJString, 10, 64)
+				if errResult == nil && err != nil {
+					errResult = err
+				}
 
-					/*
-					 * Check if cycling energy could be parsed.
-					 */
-					if err != nil {
+				running := g.Running()
+				runningZero := running.Zero()
+				cycling := g.Cycling()
+				cyclingZero := cycling.Zero()
 
-						/*
-						 * Store first error occuring.
-						 */
-						if firstError == nil {
-							msg := err.Error()
-							firstError = fmt.Errorf("Failed to parse cycling energy: %s", msg)
-							idxFirstErr = uint64(idx)
-						}
+				/*
+				 * Only keep groups in which some activity took place.
+				 */
+				if !runningZero || !cyclingZero {
+					groups = append(groups, g)
+				}
 
-						/*
-						 * Increment error count.
-						 */
-						if !recordHasErrors && numErrors < math.MaxUint64 {
-							numErrors++
-							recordHasErrors = true
-						}
+			}
+
+			numGroups := len(groups)
+
+			/*
+			 * Check if activity groups could be derived.
+			 */
+			if errResult != nil {
+				msg := errResult.Error()
+				return fmt.Errorf("Error importing activity data from GPX: %s", msg)
+			} else if numGroups == 0 {
+				return fmt.Errorf("%s", "Error importing activity data from GPX: Track contains no movement with time stamps.")
+			} else {
+				this.mutex.Lock()
+				groupsExisting := this.groups
+				numGroupsExisting := len(groupsExisting)
+				numGroupsExisting64 := uint64(numGroupsExisting)
+				numGroups64 := uint64(numGroups)
+				errInsert := error(nil)
 
+				/*
+				 * Reject activity groups with the same beginning as
+				 * an existing group, just like Add does.
+				 */
+				for _, g := range groups {
+					begin := g.begin
+					_, exists := this.searchActivity(begin)
+
+					/*
+					 * Check if this is the first collision.
+					 */
+					if errInsert == nil && exists {
+						beginString := begin.Format(time.RFC3339)
+						errInsert = fmt.Errorf("Activity group beginning at %s already exists.", beginString)
 					}
 
 				}
 
-				otherEnergyKJString := record[9]
-				otherEnergyKJ := uint64(0)
+				maxGroups := this.maxGroups
+
+				/*
+				 * Limit number of groups.
+				 */
+				if numGroupsExisting64+numGroups64 > maxGroups {
+					errInsert = fmt.Errorf("There cannot be more than %d activity groups.", maxGroups)
+				}
 
 				/*
-				 * Allow for empty other energy.
+				 * Only modify activity groups if no error occured.
 				 */
-				if otherEnergyKJString != "" {
-					otherEnergyKJ, err = strconv.ParseUint(otherEnergyKJString, 10, 64)
+				if errInsert == nil {
+					groupsExisting = append(groupsExisting, groups...)
 
 					/*
-					 * Check if other energy could be parsed.
+					 * Comparison function for sorting algorithm.
 					 */
-					if err != nil {
+					less := func(i int, j int) bool {
+						gi := groupsExisting[i]
+						giBegin := gi.begin
+						gj := groupsExisting[j]
+						gjBegin := gj.begin
+						result := giBegin.Before(gjBegin)
+						return result
+					}
 
-						/*
-						 * Store first error occuring.
-						 */
-						if firstError == nil {
-							msg := err.Error()
-							firstError = fmt.Errorf("Failed to parse other energy: %s", msg)
-							idxFirstErr = uint64(idx)
-						}
+					sort.SliceStable(groupsExisting, less)
+					this.groups = groupsExisting
+					this.revision++
+				}
 
-						/*
-						 * Increment error count.
-						 */
-						if !recordHasErrors && numErrors < math.MaxUint64 {
-							numErrors++
-							recordHasErrors = true
-						}
+				this.mutex.Unlock()
+				return errInsert
+			}
 
-					}
+		}
 
-				}
+	}
+
+}
+
+/*
+ * Import activities from CSV.
+ */
+func (this *activitiesStruct) ImportCSV(data string) error {
+	rstr := strings.NewReader(data)
+	rcsv := csv.NewReader(rstr)
+
+	/*
+	 * Records differ in length, depending on the number of user-defined
+	 * activities.
+	 */
+	rcsv.FieldsPerRecord = -1
+	records, err := rcsv.ReadAll()
+
+	/*
+	 * Check if an error occured.
+	 */
+	if err != nil {
+		msg := err.Error()
+		return fmt.Errorf("Error importing activity data from CSV: %s", msg)
+	} else {
+		numRecords := len(records)
+		numRecords64 := uint64(numRecords)
+		this.mutex.Lock()
+		groups := this.groups
+		numGroups := len(groups)
+		numGroups64 := uint64(numGroups)
+		maxGroups := this.maxGroups
+
+		/*
+		 * Limit number of groups.
+		 */
+		if numGroups64+numRecords64 > maxGroups {
+			this.mutex.Unlock()
+			return fmt.Errorf("There cannot be more than %d activity groups.", maxGroups)
+		} else {
+			groupsCopy := make([]activityGroupStruct, numGroups)
+			copy(groupsCopy, groups)
+			firstError := error(nil)
+			idxFirstErr := uint64(0)
+			numErrors := uint64(0)
+			imported := map[int64]bool{}
+
+			/*
+			 * Iterate over all records and parse activity data.
+			 */
+			for idx, record := range records {
+				g, err := parseCSVRecord(record)
 
 				/*
-				 * Create activity info.
+				 * Reject activity groups with the same beginning as an existing
+				 * group or a previous record, just like Add does.
 				 */
-				info := ActivityInfo{
-					Begin:             begin,
-					WeightKG:          weightKG,
-					RunningDuration:   runningDuration,
-					RunningDistanceKM: runningDistanceKM,
-					RunningStepCount:  runningStepCount,
-					RunningEnergyKJ:   runningEnergyKJ,
-					CyclingDuration:   cyclingDuration,
-					CyclingDistanceKM: cyclingDistanceKM,
-					CyclingEnergyKJ:   cyclingEnergyKJ,
-					OtherEnergyKJ:     otherEnergyKJ,
-				}
+				if err == nil {
+					begin := g.begin
+					beginUTC := begin.UTC()
+					_, exists := this.searchActivity(beginUTC)
+					key := beginUTC.UnixNano()
+					duplicate := imported[key]
 
-				g, err := createActivityGroup(&info)
+					/*
+					 * Check if an activity group with this beginning exists.
+					 */
+					if exists || duplicate {
+						err = fmt.Errorf("%s", "Activity group with this beginning already exists.")
+					} else {
+						imported[key] = true
+					}
+
+				}
 
 				/*
 				 * Check if activity group could be parsed.
@@ -1567,7 +2769,7 @@ func (this *activitiesStruct) ImportCSV(data string) error {
 					/*
 					 * Increment error count.
 					 */
-					if !recordHasErrors && numErrors < math.MaxUint64 {
+					if numErrors < math.MaxUint64 {
 						numErrors++
 					}
 
@@ -1577,40 +2779,40 @@ func (this *activitiesStruct) ImportCSV(data string) error {
 
 			}
 
-		}
-
-		/*
-		 * Only modify activity groups if no error occured.
-		 */
-		if firstError == nil {
-
 			/*
-			 * Comparison function for sorting algorithm.
+			 * Only modify activity groups if no error occured.
 			 */
-			less := func(i int, j int) bool {
-				gi := groupsCopy[i]
-				giBegin := gi.begin
-				gj := groupsCopy[j]
-				gjBegin := gj.begin
-				result := giBegin.Before(gjBegin)
-				return result
+			if firstError == nil {
+
+				/*
+				 * Comparison function for sorting algorithm.
+				 */
+				less := func(i int, j int) bool {
+					gi := groupsCopy[i]
+					giBegin := gi.begin
+					gj := groupsCopy[j]
+					gjBegin := gj.begin
+					result := giBegin.Before(gjBegin)
+					return result
+				}
+
+				sort.SliceStable(groupsCopy, less)
+				this.groups = groupsCopy
+				this.revision++
 			}
 
-			sort.SliceStable(groupsCopy, less)
-			this.groups = groupsCopy
-			this.revision++
-		}
+			this.mutex.Unlock()
 
-		this.mutex.Unlock()
+			/*
+			 * Check if error occured.
+			 */
+			if firstError != nil {
+				msg := firstError.Error()
+				return fmt.Errorf("Error deserializing activity data: %d erroneous activity groups, first at group number %d: %s", numErrors, idxFirstErr, msg)
+			} else {
+				return nil
+			}
 
-		/*
-		 * Check if error occured.
-		 */
-		if firstError != nil {
-			msg := firstError.Error()
-			return fmt.Errorf("Error deserializing activity data: %d erroneous activity groups, first at group number %d: %s", numErrors, idxFirstErr, msg)
-		} else {
-			return nil
 		}
 
 	}
@@ -1629,6 +2831,128 @@ func (this *activitiesStruct) Length() uint32 {
 	return length32
 }
 
+/*
+ * Determine the outcome of importing activities from CSV without modifying
+ * any activity groups.
+ *
+ * Each record is either new, malformed or collides with an activity group
+ * with the same beginning. This may be an existing group or a previous record
+ * within the same data.
+ */
+func (this *activitiesStruct) PreviewCSV(data string) ([]ImportPreviewEntry, error) {
+	rstr := strings.NewReader(data)
+	rcsv := csv.NewReader(rstr)
+
+	/*
+	 * Report records with a wrong number of fields as malformed instead of
+	 * rejecting the data as a whole.
+	 */
+	rcsv.FieldsPerRecord = -1
+	records, err := rcsv.ReadAll()
+
+	/*
+	 * Check if an error occured.
+	 */
+	if err != nil {
+		msg := err.Error()
+		return nil, fmt.Errorf("Error previewing activity data from CSV: %s", msg)
+	} else {
+		numRecords := len(records)
+		entries := make([]ImportPreviewEntry, numRecords)
+		rowsByBegin := map[int64]int{}
+		this.mutex.RLock()
+
+		/*
+		 * Iterate over all records and classify them.
+		 */
+		for idx, record := range records {
+			g, err := parseCSVRecord(record)
+			row := uint32(idx)
+
+			/*
+			 * Create preview entry.
+			 */
+			entry := ImportPreviewEntry{
+				Row:      row,
+				Status:   PREVIEW_NEW,
+				Existing: -1,
+			}
+
+			/*
+			 * Check if activity group could be parsed.
+			 */
+			if err != nil {
+				msg := err.Error()
+				entry.Status = PREVIEW_MALFORMED
+				entry.Reason = msg
+			} else {
+				begin := g.begin
+				beginUTC := begin.UTC()
+				entry.Begin = beginUTC
+				idxExisting, exists := this.searchActivity(beginUTC)
+				key := beginUTC.UnixNano()
+				rowPrevious, duplicate := rowsByBegin[key]
+
+				/*
+				 * Check if record collides with an existing activity group
+				 * or a previous record.
+				 */
+				if exists {
+					entry.Status = PREVIEW_COLLISION
+					entry.Existing = idxExisting
+					entry.Reason = "Activity group with this beginning already exists."
+				} else if duplicate {
+					entry.Status = PREVIEW_COLLISION
+					entry.Reason = fmt.Sprintf("Record %d has the same beginning.", rowPrevious)
+				} else {
+					rowsByBegin[key] = idx
+				}
+
+			}
+
+			entries[idx] = entry
+		}
+
+		this.mutex.RUnlock()
+		return entries, nil
+	}
+
+}
+
+/*
+ * Determine the indices of the activity groups beginning at or after from,
+ * but not after to.
+ *
+ * Returns the index of the first of these groups and the index just past the
+ * last of them. Both are equal if there are no such groups.
+ */
+func (this *activitiesStruct) Range(from time.Time, to time.Time) (uint32, uint32) {
+	fromUTC := from.UTC()
+	toUTC := to.UTC()
+	this.mutex.RLock()
+	idxFirst, _ := this.searchActivity(fromUTC)
+	idxEnd, exists := this.searchActivity(toUTC)
+	this.mutex.RUnlock()
+
+	/*
+	 * A group beginning exactly at the end of the range is included.
+	 */
+	if exists {
+		idxEnd++
+	}
+
+	/*
+	 * An inverted range contains no groups.
+	 */
+	if idxEnd < idxFirst {
+		idxEnd = idxFirst
+	}
+
+	first := uint32(idxFirst)
+	end := uint32(idxEnd)
+	return first, end
+}
+
 /*
  * Removes an activity group.
  */
@@ -1656,6 +2980,46 @@ func (this *activitiesStruct) Remove(id uint32) error {
 	return err
 }
 
+/*
+ * Removes all activity groups beginning at or after begin, but before end.
+ *
+ * Returns the number of activity groups removed.
+ */
+func (this *activitiesStruct) RemoveRange(begin time.Time, end time.Time) (uint32, error) {
+	numRemoved := uint32(0)
+	err := error(nil)
+
+	/*
+	 * Check if the time window is valid.
+	 */
+	if end.Before(begin) {
+		err = fmt.Errorf("%s", "End of time window must not be before its beginning.")
+	} else {
+		beginUTC := begin.UTC()
+		endUTC := end.UTC()
+		this.mutex.Lock()
+		idxBegin, _ := this.searchActivity(beginUTC)
+		idxEnd, _ := this.searchActivity(endUTC)
+
+		/*
+		 * Remove activity groups only if there are any inside the
+		 * time window.
+		 */
+		if idxEnd > idxBegin {
+			groups := this.groups
+			groups = append(groups[:idxBegin], groups[idxEnd:]...)
+			this.groups = groups
+			numRemovedInt := idxEnd - idxBegin
+			numRemoved = uint32(numRemovedInt)
+			this.revision++
+		}
+
+		this.mutex.Unlock()
+	}
+
+	return numRemoved, err
+}
+
 /*
  * Replaces an activity group with a newly created one.
  */
@@ -1718,9 +3082,35 @@ func (this *activitiesStruct) Revision() uint64 {
 }
 
 /*
- * Create statistics about all activities.
+ * Locks the activities for read access and provides a ReadCloser granting
+ * sequential access to all activity groups in JSON format.
+ *
+ * JSON data will be generated on-the-fly, one activity group at a time, while
+ * reading from the provided ReadCloser.
+ *
+ * Closing the returned ReadCloser yields the lock on the activities.
  */
-func (this *activitiesStruct) Statistics() ActivityStatistics {
+func (this *activitiesStruct) SerializeJSON() io.ReadCloser {
+	this.mutex.RLock()
+	buf := &bytes.Buffer{}
+
+	/*
+	 * Create activities JSON serializer.
+	 */
+	s := activitiesJsonSerializerStruct{
+		activities: this,
+		buffer:     buf,
+		groupId:    0,
+		state:      JSON_STREAM_HEADER,
+	}
+
+	return &s
+}
+
+/*
+ * Create statistics about activity groups.
+ */
+func statistics(groups []activityGroupStruct) *activityStatisticsStruct {
 	runningDurationSum := time.Duration(0)
 	runningDistanceKMSum := createUnsignedFixed(1)
 	runningStepCountSum := uint64(0)
@@ -1729,8 +3119,7 @@ func (this *activitiesStruct) Statistics() ActivityStatistics {
 	cyclingDistanceKMSum := createUnsignedFixed(1)
 	cyclingEnergyKJSum := uint64(0)
 	otherEnergyKJSum := uint64(0)
-	this.mutex.RLock()
-	groups := this.groups
+	customSums := map[string]*customActivityStruct{}
 
 	/*
 	 * Iterate over all activity groups and calculate sums.
@@ -1809,9 +3198,83 @@ func (this *activitiesStruct) Statistics() ActivityStatistics {
 			otherEnergyKJSum = math.MaxUint64
 		}
 
+		/*
+		 * Add up user-defined activities by their name.
+		 */
+		for _, c := range g.custom {
+			name := c.name
+			sum, ok := customSums[name]
+
+			/*
+			 * Create sum for activities not seen before.
+			 */
+			if !ok {
+				distanceKMZero := createUnsignedFixed(1)
+
+				/*
+				 * Create cumulative user-defined activity.
+				 */
+				sum = &customActivityStruct{
+					name:       name,
+					distanceKM: distanceKMZero,
+				}
+
+				customSums[name] = sum
+			}
+
+			duration := c.duration
+			durationSumOld := sum.duration
+			durationSum := durationSumOld + duration
+
+			/*
+			 * Prevent overflow.
+			 */
+			if durationSum < durationSumOld {
+				durationSum = math.MaxInt64
+			}
+
+			sum.duration = durationSum
+			distanceKM := c.distanceKM
+			sum.distanceKM, _ = sum.distanceKM.add(distanceKM)
+			energyKJ := c.energyKJ
+			energyKJSumOld := sum.energyKJ
+			energyKJSum := energyKJSumOld + energyKJ
+
+			/*
+			 * Prevent overflow.
+			 */
+			if energyKJSum < energyKJSumOld {
+				energyKJSum = math.MaxUint64
+			}
+
+			sum.energyKJ = energyKJSum
+		}
+
 	}
 
-	this.mutex.RUnlock()
+	numCustom := len(customSums)
+	custom := make([]customActivityStruct, 0, numCustom)
+
+	/*
+	 * Collect cumulative user-defined activities.
+	 */
+	for _, sum := range customSums {
+		custom = append(custom, *sum)
+	}
+
+	/*
+	 * Comparison function for sorting algorithm.
+	 */
+	less := func(i int, j int) bool {
+		ci := custom[i]
+		ciName := ci.name
+		cj := custom[j]
+		cjName := cj.name
+		result := ciName < cjName
+		return result
+	}
+
+	sort.Slice(custom, less)
 
 	/*
 	 * Create cumulative running activity.
@@ -1846,11 +3309,363 @@ func (this *activitiesStruct) Statistics() ActivityStatistics {
 		running: runningActivity,
 		cycling: cyclingActivity,
 		other:   otherActivity,
+		custom:  custom,
 	}
 
 	return &stats
 }
 
+/*
+ * Create statistics about all activities.
+ */
+func (this *activitiesStruct) Statistics() ActivityStatistics {
+	this.mutex.RLock()
+	groups := this.groups
+	stats := statistics(groups)
+	this.mutex.RUnlock()
+	return stats
+}
+
+/*
+ * Create statistics about activities for each calendar period of the given
+ * kind, which is either "week", "month" or "year".
+ *
+ * Each activity group is attributed to the period containing its beginning,
+ * even if it extends into the next period. Only periods containing activity
+ * groups are reported, in chronological order.
+ */
+func (this *activitiesStruct) StatisticsByPeriod(period string) ([]PeriodStatistics, error) {
+	_, _, err := periodBounds(time.Time{}, period)
+
+	/*
+	 * Check if kind of period is valid.
+	 */
+	if err != nil {
+		return nil, err
+	} else {
+		result := []PeriodStatistics{}
+		this.mutex.RLock()
+		groups := this.groups
+		numGroups := len(groups)
+		idxFirst := 0
+
+		/*
+		 * Collect activity groups into periods. Since groups are
+		 * ordered by their beginning, each period is a contiguous range
+		 * of groups.
+		 */
+		for idxFirst < numGroups {
+			first := groups[idxFirst]
+			firstBegin := first.begin
+			begin, end, _ := periodBounds(firstBegin, period)
+			idxEnd := idxFirst + 1
+			inPeriod := true
+
+			/*
+			 * Find the first group beginning after this period.
+			 */
+			for (idxEnd < numGroups) && inPeriod {
+				next := groups[idxEnd]
+				nextBegin := next.begin
+				inPeriod = nextBegin.Before(end)
+
+				/*
+				 * Include group if it begins within this period.
+				 */
+				if inPeriod {
+					idxEnd++
+				}
+
+			}
+
+			groupsPeriod := groups[idxFirst:idxEnd]
+			stats := statistics(groupsPeriod)
+			groupCount := idxEnd - idxFirst
+			groupCount32 := uint32(groupCount)
+
+			/*
+			 * Create statistics for this period.
+			 */
+			periodStats := &periodStatisticsStruct{
+				begin:      begin,
+				end:        end,
+				groupCount: groupCount32,
+				stats:      stats,
+			}
+
+			result = append(result, periodStats)
+			idxFirst = idxEnd
+		}
+
+		this.mutex.RUnlock()
+		return result, nil
+	}
+
+}
+
+/*
+ * Create statistics about the activity groups with indices from first up to,
+ * but not including, end, as determined by Range.
+ *
+ * Indices beyond the last activity group are ignored.
+ */
+func (this *activitiesStruct) StatisticsRange(first uint32, end uint32) ActivityStatistics {
+	this.mutex.RLock()
+	groups := this.groups
+	numGroups := len(groups)
+	numGroups64 := uint64(numGroups)
+	first64 := uint64(first)
+	end64 := uint64(end)
+
+	/*
+	 * Limit range to existing activity groups.
+	 */
+	if end64 > numGroups64 {
+		end64 = numGroups64
+	}
+
+	/*
+	 * Make sure that range is not inverted.
+	 */
+	if first64 > end64 {
+		first64 = end64
+	}
+
+	groupsRange := groups[first64:end64]
+	stats := statistics(groupsRange)
+	this.mutex.RUnlock()
+	return stats
+}
+
+/*
+ * Verify a single activity group, given the previous one, which may be nil.
+ *
+ * Returns the reasons why the group is invalid, if any.
+ */
+func verifyGroup(g *activityGroupStruct, previous *activityGroupStruct) []string {
+	reasons := []string{}
+
+	/*
+	 * Check ordering if there is a previous group.
+	 */
+	if previous != nil {
+		begin := g.begin
+		previousBegin := previous.begin
+		previousBeginString := previousBegin.Format(time.RFC3339)
+
+		/*
+		 * Groups must be strictly ordered by their beginning.
+		 */
+		if begin.Equal(previousBegin) {
+			reasons = append(reasons, "Activity group has the same beginning as the previous one.")
+		} else if begin.Before(previousBegin) {
+			reason := fmt.Sprintf("Activity group begins before the previous one, which begins at %s.", previousBeginString)
+			reasons = append(reasons, reason)
+		}
+
+	}
+
+	info := g.info()
+	buf, err := json.Marshal(info)
+
+	/*
+	 * Check if activity group could be serialized.
+	 */
+	if err != nil {
+		msg := err.Error()
+		reason := fmt.Sprintf("Activity group cannot be serialized: %s", msg)
+		reasons = append(reasons, reason)
+	} else {
+		infoParsed := ActivityInfo{}
+		err = json.Unmarshal(buf, &infoParsed)
+
+		/*
+		 * Check if serialized activity group could be deserialized.
+		 */
+		if err != nil {
+			msg := err.Error()
+			reason := fmt.Sprintf("Activity group cannot be deserialized: %s", msg)
+			reasons = append(reasons, reason)
+		} else {
+			gParsed, err := createActivityGroup(&infoParsed)
+
+			/*
+			 * Check if activity group could be parsed back and
+			 * remains the same.
+			 */
+			if err != nil {
+				msg := err.Error()
+				reason := fmt.Sprintf("Activity group cannot be parsed back: %s", msg)
+				reasons = append(reasons, reason)
+			} else {
+				infoBack := gParsed.info()
+				begin := info.Begin
+				beginBack := infoBack.Begin
+				sameBegin := begin.Equal(beginBack)
+				infoBack.Begin = begin
+				bufBack, err := json.Marshal(infoBack)
+				same := (err == nil) && bytes.Equal(buf, bufBack)
+
+				/*
+				 * Check if activity group changed.
+				 */
+				if !sameBegin || !same {
+					reasons = append(reasons, "Activity group changes when it is serialized and parsed back.")
+				}
+
+			}
+
+		}
+
+	}
+
+	return reasons
+}
+
+/*
+ * Verify that activity groups are strictly ordered by their beginning, that no
+ * two groups begin at the same time and that each group can be serialized and
+ * parsed back without changes.
+ */
+func (this *activitiesStruct) Verify() VerificationReport {
+	this.mutex.RLock()
+	groups := this.groups
+	numGroups := len(groups)
+	issues := []VerificationIssue{}
+	previous := (*activityGroupStruct)(nil)
+
+	/*
+	 * Verify each activity group.
+	 */
+	for idx := range groups {
+		g := &groups[idx]
+		reasons := verifyGroup(g, previous)
+		idx32 := uint32(idx)
+		begin := g.begin
+
+		/*
+		 * Report each problem found.
+		 */
+		for _, reason := range reasons {
+
+			/*
+			 * Create verification issue.
+			 */
+			issue := VerificationIssue{
+				Group:  idx32,
+				Begin:  begin,
+				Reason: reason,
+			}
+
+			issues = append(issues, issue)
+		}
+
+		previous = g
+	}
+
+	this.mutex.RUnlock()
+	numGroups32 := uint32(numGroups)
+
+	/*
+	 * Create verification report.
+	 */
+	report := VerificationReport{
+		GroupCount: numGroups32,
+		Issues:     issues,
+	}
+
+	return report
+}
+
+/*
+ * Verify activity data in JSON format, as produced by Export.
+ *
+ * Unlike Import, this does not sort the activity groups, so that groups which
+ * are out of order are reported. Groups which cannot be parsed are reported
+ * as well and are not considered when checking the order of the remaining
+ * groups. Only fails if the data cannot be deserialized at all.
+ */
+func VerifyJSON(buf []byte) (VerificationReport, error) {
+	infos := []ActivityInfo{}
+	err := json.Unmarshal(buf, &infos)
+
+	/*
+	 * Check if data could be deserialized.
+	 */
+	if err != nil {
+		msg := err.Error()
+		return VerificationReport{}, fmt.Errorf("Error deserializing activity data: %s", msg)
+	} else {
+		numInfos := len(infos)
+		numInfos64 := uint64(numInfos)
+
+		/*
+		 * Limit number of groups.
+		 */
+		if numInfos64 > math.MaxUint32 {
+			return VerificationReport{}, fmt.Errorf("There cannot be more than %d activity groups.", math.MaxUint32)
+		} else {
+			issues := []VerificationIssue{}
+			previous := (*activityGroupStruct)(nil)
+
+			/*
+			 * Verify each activity group.
+			 */
+			for idx := range infos {
+				info := &infos[idx]
+				g, err := createActivityGroup(info)
+				idx32 := uint32(idx)
+				begin := info.Begin
+				reasons := []string{}
+
+				/*
+				 * Check if activity group could be parsed.
+				 */
+				if err != nil {
+					msg := err.Error()
+					reason := fmt.Sprintf("Activity group cannot be parsed: %s", msg)
+					reasons = append(reasons, reason)
+				} else {
+					reasons = verifyGroup(&g, previous)
+					previous = &g
+				}
+
+				/*
+				 * Report each problem found.
+				 */
+				for _, reason := range reasons {
+
+					/*
+					 * Create verification issue.
+					 */
+					issue := VerificationIssue{
+						Group:  idx32,
+						Begin:  begin,
+						Reason: reason,
+					}
+
+					issues = append(issues, issue)
+				}
+
+			}
+
+			numInfos32 := uint32(numInfos)
+
+			/*
+			 * Create verification report.
+			 */
+			report := VerificationReport{
+				GroupCount: numInfos32,
+				Issues:     issues,
+			}
+
+			return report, nil
+		}
+
+	}
+
+}
+
 /*
  * Create data structure storing activities.
  */
@@ -1861,8 +3676,9 @@ func CreateActivities() Activities {
 	 * Create activity storage.
 	 */
 	a := activitiesStruct{
-		groups:   g,
-		revision: 0,
+		groups:    g,
+		maxGroups: math.MaxUint32,
+		revision:  0,
 	}
 
 	return &a
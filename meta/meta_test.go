@@ -0,0 +1,193 @@
+package meta
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+/*
+ * Maximum number of activity groups used in tests.
+ */
+const TEST_MAX_GROUPS = 3
+
+/*
+ * Creates activity storage which holds at most TEST_MAX_GROUPS activity
+ * groups.
+ */
+func createLimitedActivities() *activitiesStruct {
+	a := CreateActivities()
+	as := a.(*activitiesStruct)
+	as.maxGroups = TEST_MAX_GROUPS
+	return as
+}
+
+/*
+ * Creates activity data for a number of consecutive days in JSON format.
+ */
+func createJSON(t *testing.T, numGroups int) []byte {
+	infos := []ActivityInfo{}
+	begin := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	/*
+	 * Create one activity group per day.
+	 */
+	for i := 0; i < numGroups; i++ {
+		offset := time.Duration(i) * TIME_DAY
+		groupBegin := begin.Add(offset)
+
+		/*
+		 * Create activity info.
+		 */
+		info := ActivityInfo{
+			Begin:             groupBegin,
+			WeightKG:          "70.0",
+			RunningDuration:   time.Hour,
+			RunningDistanceKM: "10.0",
+			CyclingDistanceKM: "0.0",
+		}
+
+		infos = append(infos, info)
+	}
+
+	buf, err := json.Marshal(infos)
+
+	/*
+	 * Check if activity data could be serialized.
+	 */
+	if err != nil {
+		t.Fatalf("Failed to serialize activity data: %s", err.Error())
+	}
+
+	return buf
+}
+
+/*
+ * Creates activity data for a number of consecutive days in CSV format.
+ */
+func createCSV(numGroups int) string {
+	builder := strings.Builder{}
+	begin := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	/*
+	 * Create one record per day.
+	 */
+	for i := 0; i < numGroups; i++ {
+		offset := time.Duration(i) * TIME_DAY
+		groupBegin := begin.Add(offset)
+		beginString := groupBegin.Format(time.RFC3339)
+		record := fmt.Sprintf("%s,70.0,1h0m0s,10.0,,,,,,\n", beginString)
+		builder.WriteString(record)
+	}
+
+	result := builder.String()
+	return result
+}
+
+/*
+ * Importing activity data in JSON format must succeed at the limit and fail
+ * gracefully just over it.
+ */
+func TestImportLimit(t *testing.T) {
+	a := createLimitedActivities()
+	buf := createJSON(t, TEST_MAX_GROUPS)
+	err := a.Import(buf)
+	length := a.Length()
+
+	/*
+	 * Activity data at the limit must be imported.
+	 */
+	if err != nil {
+		t.Errorf("Expected %d activity groups to be imported, got error: %s", TEST_MAX_GROUPS, err.Error())
+	} else if length != TEST_MAX_GROUPS {
+		t.Errorf("Expected %d activity groups, got %d.", TEST_MAX_GROUPS, length)
+	}
+
+	a = createLimitedActivities()
+	buf = createJSON(t, TEST_MAX_GROUPS+1)
+	err = a.Import(buf)
+	length = a.Length()
+
+	/*
+	 * Activity data over the limit must be rejected as a whole.
+	 */
+	if err == nil {
+		t.Errorf("Expected %d activity groups to be rejected.", TEST_MAX_GROUPS+1)
+	} else if length != 0 {
+		t.Errorf("Expected no activity groups after rejected import, got %d.", length)
+	}
+
+	a = createLimitedActivities()
+	buf = createJSON(t, TEST_MAX_GROUPS)
+	a.Import(buf)
+	buf = createJSON(t, 1)
+	err = a.Import(buf)
+	length = a.Length()
+
+	/*
+	 * Activity data exceeding the limit together with existing groups must
+	 * be rejected as well.
+	 */
+	if err == nil {
+		t.Errorf("%s", "Expected import exceeding the limit together with existing activity groups to be rejected.")
+	} else if length != TEST_MAX_GROUPS {
+		t.Errorf("Expected %d activity groups after rejected import, got %d.", TEST_MAX_GROUPS, length)
+	}
+
+}
+
+/*
+ * Importing activity data in CSV format must succeed at the limit and fail
+ * gracefully just over it.
+ */
+func TestImportCSVLimit(t *testing.T) {
+	a := createLimitedActivities()
+	data := createCSV(TEST_MAX_GROUPS)
+	err := a.ImportCSV(data)
+	length := a.Length()
+
+	/*
+	 * Activity data at the limit must be imported.
+	 */
+	if err != nil {
+		t.Errorf("Expected %d activity groups to be imported, got error: %s", TEST_MAX_GROUPS, err.Error())
+	} else if length != TEST_MAX_GROUPS {
+		t.Errorf("Expected %d activity groups, got %d.", TEST_MAX_GROUPS, length)
+	}
+
+	a = createLimitedActivities()
+	data = createCSV(TEST_MAX_GROUPS + 1)
+	err = a.ImportCSV(data)
+	length = a.Length()
+
+	/*
+	 * Activity data over the limit must be rejected as a whole.
+	 */
+	if err == nil {
+		t.Errorf("Expected %d activity groups to be rejected.", TEST_MAX_GROUPS+1)
+	} else if length != 0 {
+		t.Errorf("Expected no activity groups after rejected import, got %d.", length)
+	}
+
+	a = createLimitedActivities()
+	data = createCSV(TEST_MAX_GROUPS)
+	a.ImportCSV(data)
+	data = createCSV(TEST_MAX_GROUPS + 1)
+	lines := strings.SplitAfter(data, "\n")
+	data = lines[TEST_MAX_GROUPS]
+	err = a.ImportCSV(data)
+	length = a.Length()
+
+	/*
+	 * Activity data exceeding the limit together with existing groups must
+	 * be rejected as well.
+	 */
+	if err == nil {
+		t.Errorf("%s", "Expected import exceeding the limit together with existing activity groups to be rejected.")
+	} else if length != TEST_MAX_GROUPS {
+		t.Errorf("Expected %d activity groups after rejected import, got %d.", TEST_MAX_GROUPS, length)
+	}
+
+}
@@ -0,0 +1,1163 @@
+package remote
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andrepxx/location-visualizer/auth/publickey"
+	"github.com/andrepxx/location-visualizer/auth/rand"
+	"github.com/andrepxx/location-visualizer/meta"
+)
+
+/*
+ * Constants for the remote client.
+ */
+const (
+	CGI_PATH          = "/cgi-bin/locviz"
+	SESSION_NOT_FOUND = "No session with this token found."
+)
+
+/*
+ * The error returned when the remote instance no longer knows the session,
+ * e. g. because it expired or the server was restarted.
+ */
+var ErrSessionExpired = fmt.Errorf("%s", "Session expired.")
+
+/*
+ * Indicates whether a request was successful or not.
+ */
+type webResponseStruct struct {
+	Success bool
+	Reason  string
+}
+
+/*
+ * Web representation of an authentication challenge.
+ */
+type webAuthChallengeStruct struct {
+	webResponseStruct
+	Nonce string
+	Salt  string
+}
+
+/*
+ * Web representation of a session token.
+ */
+type webTokenStruct struct {
+	webResponseStruct
+	Token string
+}
+
+/*
+ * Web representation of a migration report.
+ *
+ * Only the status is evaluated by the client.
+ */
+type webMigrationReportStruct struct {
+	Status webResponseStruct
+}
+
+/*
+ * Parameters for rendering a visualization on the remote instance.
+ *
+ * Time limits may be empty to leave either or both of the bounds open. They
+ * may also be one of the keywords "now", "today" or "yesterday", which the
+ * remote instance resolves in the time zone given by TimeZone (UTC if empty).
+ */
+type RenderRequest struct {
+	XRes     uint32
+	YRes     uint32
+	XPos     float64
+	YPos     float64
+	Zoom     uint8
+	MinTime  string
+	MaxTime  string
+	TimeZone string
+	FgColor  string
+	Spread   uint8
+}
+
+/*
+ * An error reported by the remote instance in response to a request.
+ */
+type ServerError struct {
+	Reason string
+}
+
+/*
+ * Returns the reason reported by the remote instance.
+ */
+func (this *ServerError) Error() string {
+	reason := this.Reason
+	msg := fmt.Sprintf("Server reported failure: %s", reason)
+	return msg
+}
+
+/*
+ * A connection to a remote location-visualizer instance.
+ */
+type Connection interface {
+	Login(name string, password string, code string) (Session, error)
+	LoginPrivateKey(name string, key *rsa.PrivateKey) (Session, error)
+	Resume(token string) Session
+}
+
+/*
+ * An authenticated session on a remote location-visualizer instance.
+ */
+type Session interface {
+	AddActivity(info meta.ActivityInfo) error
+	BackupIncremental(revision uint64, sinceCount uint32) (io.ReadCloser, uint64, bool, error)
+	ExportActivitiesCsv() (io.ReadCloser, error)
+	ExportGeoData(format string) (io.ReadCloser, error)
+	ImportActivityCsv(csv string) error
+	ImportGeoData(format string, strategy string, r io.Reader) error
+	Logout() error
+	Render(req RenderRequest) (io.ReadCloser, error)
+	SetAutoRenew(enabled bool)
+	Token() string
+}
+
+/*
+ * Data structure representing a connection to a remote instance.
+ */
+type connectionStruct struct {
+	client *http.Client
+	uri    string
+}
+
+/*
+ * Data structure representing an authenticated session.
+ */
+type sessionStruct struct {
+	autoRenew bool
+	conn      *connectionStruct
+	mutex     sync.Mutex
+	renew     func() (string, error)
+	token     string
+}
+
+/*
+ * Creates an error from a failure reported by the remote instance.
+ *
+ * If the remote instance does not know the session, this is ErrSessionExpired,
+ * otherwise a *ServerError.
+ */
+func createServerError(reason string) error {
+	expired := strings.Contains(reason, SESSION_NOT_FOUND)
+
+	/*
+	 * Check if session expired.
+	 */
+	if expired {
+		return ErrSessionExpired
+	} else {
+
+		/*
+		 * Create server error.
+		 */
+		errServer := ServerError{
+			Reason: reason,
+		}
+
+		return &errServer
+	}
+
+}
+
+/*
+ * Creates an error from an unexpected response of the remote instance.
+ */
+func createResponseError(content []byte) error {
+	msg := string(content)
+	msg = strings.TrimSpace(msg)
+	expired := strings.Contains(msg, SESSION_NOT_FOUND)
+
+	/*
+	 * Check if session expired.
+	 */
+	if expired {
+		return ErrSessionExpired
+	} else {
+		return fmt.Errorf("Unexpected response from server: %s", msg)
+	}
+
+}
+
+/*
+ * Sends a request to the CGI of the remote instance and returns the response.
+ *
+ * Parameters are encoded as a multipart form. If a file is provided, its
+ * contents are attached to the form as the field 'file'.
+ *
+ * The caller is expected to close the body of the response.
+ */
+func (this *connectionStruct) send(params map[string]string, file io.Reader) (*http.Response, error) {
+	buf := &bytes.Buffer{}
+	w := multipart.NewWriter(buf)
+	errResult := error(nil)
+
+	/*
+	 * Write all parameters into the form.
+	 */
+	for key, value := range params {
+		err := w.WriteField(key, value)
+
+		/*
+		 * Store the first error.
+		 */
+		if (err != nil) && (errResult == nil) {
+			msg := err.Error()
+			errResult = fmt.Errorf("Failed to write form field '%s': %s", key, msg)
+		}
+
+	}
+
+	/*
+	 * Attach file to the form if one was provided.
+	 */
+	if (file != nil) && (errResult == nil) {
+		fw, err := w.CreateFormFile("file", "upload")
+
+		/*
+		 * Check if form file was created.
+		 */
+		if err != nil {
+			msg := err.Error()
+			errResult = fmt.Errorf("Failed to create form file: %s", msg)
+		} else {
+			_, err = io.Copy(fw, file)
+
+			/*
+			 * Check if file was copied into the form.
+			 */
+			if err != nil {
+				msg := err.Error()
+				errResult = fmt.Errorf("Failed to write form file: %s", msg)
+			}
+
+		}
+
+	}
+
+	err := w.Close()
+
+	/*
+	 * Check if error occured and it's the first one.
+	 */
+	if (err != nil) && (errResult == nil) {
+		msg := err.Error()
+		errResult = fmt.Errorf("Failed to finish form: %s", msg)
+	}
+
+	/*
+	 * Check if form was created.
+	 */
+	if errResult != nil {
+		return nil, errResult
+	} else {
+		client := this.client
+		uri := this.uri
+		target := uri + CGI_PATH
+		contentType := w.FormDataContentType()
+		resp, err := client.Post(target, contentType, buf)
+
+		/*
+		 * Check if request was successful.
+		 */
+		if err != nil {
+			msg := err.Error()
+			return nil, fmt.Errorf("Request failed: %s", msg)
+		} else {
+			statusCode := resp.StatusCode
+
+			/*
+			 * Check if server responded with success.
+			 */
+			if statusCode != http.StatusOK {
+				body := resp.Body
+				body.Close()
+				return nil, fmt.Errorf("Server responded with status code %d.", statusCode)
+			} else {
+				return resp, nil
+			}
+
+		}
+
+	}
+
+}
+
+/*
+ * Sends a request to the CGI of the remote instance and returns the body of
+ * the response.
+ */
+func (this *connectionStruct) request(params map[string]string, file io.Reader) ([]byte, error) {
+	resp, err := this.send(params, file)
+
+	/*
+	 * Check if request was successful.
+	 */
+	if err != nil {
+		return nil, err
+	} else {
+		body := resp.Body
+		content, err := io.ReadAll(body)
+		body.Close()
+
+		/*
+		 * Check if response could be read.
+		 */
+		if err != nil {
+			msg := err.Error()
+			return nil, fmt.Errorf("Failed to read response: %s", msg)
+		} else {
+			return content, nil
+		}
+
+	}
+
+}
+
+/*
+ * Sends a request to the CGI of the remote instance and returns a stream
+ * providing the content of the response, as well as the response headers.
+ *
+ * The content type of the response has to start with the expected content
+ * type. Otherwise, the response is treated as an error message.
+ */
+func (this *connectionStruct) download(params map[string]string, expectedType string) (io.ReadCloser, http.Header, error) {
+	resp, err := this.send(params, nil)
+
+	/*
+	 * Check if request was successful.
+	 */
+	if err != nil {
+		return nil, nil, err
+	} else {
+		hdr := resp.Header
+		contentType := hdr.Get("Content-type")
+		body := resp.Body
+
+		/*
+		 * Check if we received the expected content.
+		 */
+		if strings.HasPrefix(contentType, expectedType) {
+			return body, hdr, nil
+		} else {
+			content, _ := io.ReadAll(body)
+			body.Close()
+			err := createResponseError(content)
+			return nil, nil, err
+		}
+
+	}
+
+}
+
+/*
+ * Sends a request to the CGI of the remote instance and decodes the JSON
+ * response into the target object.
+ */
+func (this *connectionStruct) requestJSON(params map[string]string, file io.Reader, target interface{}) error {
+	content, err := this.request(params, file)
+
+	/*
+	 * Check if request was successful.
+	 */
+	if err != nil {
+		return err
+	} else {
+		err = json.Unmarshal(content, target)
+
+		/*
+		 * The server responds with plain text when a request fails
+		 * before producing a JSON response.
+		 */
+		if err != nil {
+			err = createResponseError(content)
+			return err
+		} else {
+			return nil
+		}
+
+	}
+
+}
+
+/*
+ * Requests an authentication challenge for a user.
+ *
+ * Returns the nonce and the salt of the challenge.
+ */
+func (this *connectionStruct) requestChallenge(name string) ([]byte, []byte, error) {
+
+	/*
+	 * Request parameters for authentication challenge.
+	 */
+	params := map[string]string{
+		"cgi":  "auth-request",
+		"name": name,
+	}
+
+	challenge := webAuthChallengeStruct{}
+	err := this.requestJSON(params, nil, &challenge)
+
+	/*
+	 * Check if challenge was obtained.
+	 */
+	if err != nil {
+		msg := err.Error()
+		return nil, nil, fmt.Errorf("Failed to obtain authentication challenge: %s", msg)
+	} else if !challenge.Success {
+		reason := challenge.Reason
+		return nil, nil, fmt.Errorf("Failed to obtain authentication challenge: %s", reason)
+	} else {
+		enc := base64.StdEncoding
+		nonceIn := challenge.Nonce
+		nonce, errNonce := enc.DecodeString(nonceIn)
+		saltIn := challenge.Salt
+		salt, errSalt := enc.DecodeString(saltIn)
+
+		/*
+		 * Check if nonce and salt could be decoded.
+		 */
+		if errNonce != nil {
+			return nil, nil, fmt.Errorf("%s", "Failed to decode nonce.")
+		} else if errSalt != nil {
+			return nil, nil, fmt.Errorf("%s", "Failed to decode salt.")
+		} else {
+			return nonce, salt, nil
+		}
+
+	}
+
+}
+
+/*
+ * Sends an authentication response and returns the session token issued by
+ * the server.
+ */
+func (this *connectionStruct) requestSession(params map[string]string) (string, error) {
+	token := webTokenStruct{}
+	err := this.requestJSON(params, nil, &token)
+
+	/*
+	 * Check if session token was obtained.
+	 */
+	if err != nil {
+		msg := err.Error()
+		return "", fmt.Errorf("Failed to obtain session token: %s", msg)
+	} else if !token.Success {
+		reason := token.Reason
+		return "", fmt.Errorf("Failed to obtain session token: %s", reason)
+	} else {
+		tokenString := token.Token
+		return tokenString, nil
+	}
+
+}
+
+/*
+ * Creates a session, given a session token.
+ *
+ * The renewal function may be nil if the session cannot be renewed.
+ */
+func (this *connectionStruct) createSession(token string, renew func() (string, error)) *sessionStruct {
+
+	/*
+	 * Create session.
+	 */
+	s := sessionStruct{
+		autoRenew: false,
+		conn:      this,
+		renew:     renew,
+		token:     token,
+	}
+
+	return &s
+}
+
+/*
+ * Authenticates a user and returns a session token.
+ *
+ * The code is the time-based one-time password of users with two-factor
+ * authentication enabled and ignored for other users.
+ */
+func (this *connectionStruct) loginPassword(name string, password string, code string) (string, error) {
+	nonce, salt, err := this.requestChallenge(name)
+
+	/*
+	 * Check if challenge was obtained.
+	 */
+	if err != nil {
+		return "", err
+	} else {
+		pwdBytes := []byte(password)
+		pwdHash := sha512.Sum512(pwdBytes)
+		saltAndHash := append(salt, pwdHash[:]...)
+		innerHash := sha512.Sum512(saltAndHash)
+		nonceAndHash := append(nonce, innerHash[:]...)
+		outerHash := sha512.Sum512(nonceAndHash)
+		enc := base64.StdEncoding
+		hash := enc.EncodeToString(outerHash[:])
+
+		/*
+		 * Request parameters for authentication response.
+		 */
+		params := map[string]string{
+			"cgi":  "auth-response",
+			"name": name,
+			"hash": hash,
+			"code": code,
+		}
+
+		token, err := this.requestSession(params)
+		return token, err
+	}
+
+}
+
+/*
+ * Authenticates a user using his / her RSA private key and returns a session
+ * token.
+ */
+func (this *connectionStruct) loginPrivateKey(name string, key *rsa.PrivateKey) (string, error) {
+	nonce, _, err := this.requestChallenge(name)
+
+	/*
+	 * Check if challenge was obtained.
+	 */
+	if err != nil {
+		return "", err
+	} else {
+		prng := rand.SystemPRNG()
+		signature, err := publickey.SignPSS(prng, key, nonce)
+
+		/*
+		 * Check if challenge was signed.
+		 */
+		if err != nil {
+			msg := err.Error()
+			return "", fmt.Errorf("Failed to sign authentication challenge: %s", msg)
+		} else {
+			enc := base64.StdEncoding
+			signatureString := enc.EncodeToString(signature)
+
+			/*
+			 * Request parameters for authentication response.
+			 */
+			params := map[string]string{
+				"cgi":       "auth-response-public-key",
+				"name":      name,
+				"signature": signatureString,
+			}
+
+			token, err := this.requestSession(params)
+			return token, err
+		}
+
+	}
+
+}
+
+/*
+ * Authenticates a user and creates a session.
+ *
+ * Users with two-factor authentication enabled have to provide the current
+ * time-based one-time password as code, while other users pass an empty
+ * string. Since the server accepts each one-time password only once,
+ * sessions created with a code cannot be renewed automatically.
+ */
+func (this *connectionStruct) Login(name string, password string, code string) (Session, error) {
+	token, err := this.loginPassword(name, password, code)
+
+	/*
+	 * Check if session token was obtained.
+	 */
+	if err != nil {
+		return nil, err
+	} else {
+		renew := (func() (string, error))(nil)
+
+		/*
+		 * Only sessions without a one-time password can be renewed.
+		 */
+		if code == "" {
+
+			/*
+			 * Authenticates again with the same credentials.
+			 */
+			renew = func() (string, error) {
+				token, err := this.loginPassword(name, password, code)
+				return token, err
+			}
+
+		}
+
+		s := this.createSession(token, renew)
+		return s, nil
+	}
+
+}
+
+/*
+ * Authenticates a user using his / her RSA private key and creates a session.
+ *
+ * The public key has to be registered for the user on the server.
+ */
+func (this *connectionStruct) LoginPrivateKey(name string, key *rsa.PrivateKey) (Session, error) {
+	token, err := this.loginPrivateKey(name, key)
+
+	/*
+	 * Check if session token was obtained.
+	 */
+	if err != nil {
+		return nil, err
+	} else {
+
+		/*
+		 * Authenticates again with the same key.
+		 */
+		renew := func() (string, error) {
+			token, err := this.loginPrivateKey(name, key)
+			return token, err
+		}
+
+		s := this.createSession(token, renew)
+		return s, nil
+	}
+
+}
+
+/*
+ * Resumes a session, given a session token obtained earlier.
+ *
+ * Resumed sessions cannot be renewed automatically, since no credentials are
+ * known for them.
+ */
+func (this *connectionStruct) Resume(token string) Session {
+	s := this.createSession(token, nil)
+	return s
+}
+
+/*
+ * Returns the current token of this session.
+ */
+func (this *sessionStruct) currentToken() string {
+	this.mutex.Lock()
+	token := this.token
+	this.mutex.Unlock()
+	return token
+}
+
+/*
+ * Performs an operation within this session.
+ *
+ * If the session expired and automatic renewal is enabled, the session is
+ * renewed and the operation is retried once.
+ */
+func (this *sessionStruct) perform(operation func(token string) error) error {
+	token := this.currentToken()
+	err := operation(token)
+
+	/*
+	 * Check if session expired.
+	 */
+	if err == ErrSessionExpired {
+		this.mutex.Lock()
+		autoRenew := this.autoRenew
+		renew := this.renew
+		current := this.token
+		renewed := false
+
+		/*
+		 * Check if session was already renewed concurrently.
+		 */
+		if current != token {
+			token = current
+			renewed = true
+		} else if autoRenew && (renew != nil) {
+			newToken, errRenew := renew()
+
+			/*
+			 * Check if session was renewed.
+			 */
+			if errRenew == nil {
+				this.token = newToken
+				token = newToken
+				renewed = true
+			}
+
+		}
+
+		this.mutex.Unlock()
+
+		/*
+		 * Retry operation with the new token.
+		 */
+		if renewed {
+			err = operation(token)
+		}
+
+	}
+
+	return err
+}
+
+/*
+ * Sends a request with a session token and checks the response for success.
+ *
+ * If the remote instance reports a failure, the error is a *ServerError.
+ */
+func (this *sessionStruct) callToken(params map[string]string, token string) error {
+	conn := this.conn
+	params["token"] = token
+	wr := webResponseStruct{}
+	err := conn.requestJSON(params, nil, &wr)
+
+	/*
+	 * Check if request was successful.
+	 */
+	if err != nil {
+		return err
+	} else if !wr.Success {
+		reason := wr.Reason
+		err = createServerError(reason)
+		return err
+	} else {
+		return nil
+	}
+
+}
+
+/*
+ * Sends a request within this session and checks the response for success.
+ *
+ * If the remote instance reports a failure, the error is a *ServerError.
+ */
+func (this *sessionStruct) call(params map[string]string) error {
+
+	/*
+	 * Send request with the current token.
+	 */
+	operation := func(token string) error {
+		err := this.callToken(params, token)
+		return err
+	}
+
+	err := this.perform(operation)
+	return err
+}
+
+/*
+ * Downloads content within this session.
+ */
+func (this *sessionStruct) download(params map[string]string, expectedType string) (io.ReadCloser, http.Header, error) {
+	conn := this.conn
+	r := io.ReadCloser(nil)
+	header := http.Header(nil)
+
+	/*
+	 * Send request with the current token.
+	 */
+	operation := func(token string) error {
+		params["token"] = token
+		rc, hdr, err := conn.download(params, expectedType)
+		r = rc
+		header = hdr
+		return err
+	}
+
+	err := this.perform(operation)
+	return r, header, err
+}
+
+/*
+ * Adds activity information on the remote instance.
+ */
+func (this *sessionStruct) AddActivity(info meta.ActivityInfo) error {
+	begin := info.Begin
+	beginString := begin.Format(time.RFC3339)
+	runningDuration := info.RunningDuration
+	runningDurationString := runningDuration.String()
+	runningStepCount := info.RunningStepCount
+	runningStepCountString := strconv.FormatUint(runningStepCount, 10)
+	runningEnergyKJ := info.RunningEnergyKJ
+	runningEnergyKJString := strconv.FormatUint(runningEnergyKJ, 10)
+	cyclingDuration := info.CyclingDuration
+	cyclingDurationString := cyclingDuration.String()
+	cyclingEnergyKJ := info.CyclingEnergyKJ
+	cyclingEnergyKJString := strconv.FormatUint(cyclingEnergyKJ, 10)
+	otherEnergyKJ := info.OtherEnergyKJ
+	otherEnergyKJString := strconv.FormatUint(otherEnergyKJ, 10)
+
+	/*
+	 * Request parameters for adding activity.
+	 */
+	params := map[string]string{
+		"cgi":               "add-activity",
+		"begin":             beginString,
+		"weightkg":          info.WeightKG,
+		"runningduration":   runningDurationString,
+		"runningdistancekm": info.RunningDistanceKM,
+		"runningstepcount":  runningStepCountString,
+		"runningenergykj":   runningEnergyKJString,
+		"cyclingduration":   cyclingDurationString,
+		"cyclingdistancekm": info.CyclingDistanceKM,
+		"cyclingenergykj":   cyclingEnergyKJString,
+		"otherenergykj":     otherEnergyKJString,
+	}
+
+	/*
+	 * Add parameters for each user-defined activity.
+	 */
+	for _, custom := range info.Custom {
+		name := custom.Name
+		duration := custom.Duration
+		durationString := duration.String()
+		energyKJ := custom.EnergyKJ
+		energyKJString := strconv.FormatUint(energyKJ, 10)
+		params[name+"duration"] = durationString
+		params[name+"distancekm"] = custom.DistanceKM
+		params[name+"energykj"] = energyKJString
+	}
+
+	err := this.call(params)
+
+	/*
+	 * Check if activity was added.
+	 */
+	if err == ErrSessionExpired {
+		return err
+	} else if err != nil {
+		msg := err.Error()
+		return fmt.Errorf("Failed to add activity: %s", msg)
+	} else {
+		return nil
+	}
+
+}
+
+/*
+ * Backs up the geographical database of the remote instance incrementally.
+ *
+ * The revision and sinceCount arguments describe the state of the database
+ * at the time of the previous backup. If the database was not rewritten since
+ * then, the returned stream only provides the entries appended since, which
+ * have to be appended to the previous backup. Otherwise, it provides the
+ * entire database in binary format. Pass a revision of zero to request a full
+ * backup.
+ *
+ * Also returns the current revision of the database, which has to be provided
+ * for the next backup, and whether only the appended entries are provided.
+ *
+ * The caller is expected to close the stream.
+ */
+func (this *sessionStruct) BackupIncremental(revision uint64, sinceCount uint32) (io.ReadCloser, uint64, bool, error) {
+	revisionString := strconv.FormatUint(revision, 10)
+	sinceCount64 := uint64(sinceCount)
+	sinceCountString := strconv.FormatUint(sinceCount64, 10)
+
+	/*
+	 * Request parameters for an incremental backup.
+	 */
+	params := map[string]string{
+		"cgi":      "download-geodb-content",
+		"format":   "binary",
+		"revision": revisionString,
+		"since":    sinceCountString,
+	}
+
+	r, hdr, err := this.download(params, "application/octet-stream")
+
+	/*
+	 * Check if download was successful.
+	 */
+	if err != nil {
+		return nil, 0, false, err
+	} else {
+		currentRevisionString := hdr.Get("X-Geodb-Revision")
+		currentRevision, errRevision := strconv.ParseUint(currentRevisionString, 10, 64)
+		incrementalString := hdr.Get("X-Geodb-Incremental")
+		incremental, errIncremental := strconv.ParseBool(incrementalString)
+
+		/*
+		 * Check if the remote instance supports incremental backups.
+		 */
+		if (errRevision != nil) || (errIncremental != nil) {
+			r.Close()
+			return nil, 0, false, fmt.Errorf("%s", "Remote instance does not support incremental backups.")
+		} else {
+			return r, currentRevision, incremental, nil
+		}
+
+	}
+
+}
+
+/*
+ * Exports activity data from the remote instance in CSV format.
+ *
+ * The caller is expected to close the stream.
+ */
+func (this *sessionStruct) ExportActivitiesCsv() (io.ReadCloser, error) {
+
+	/*
+	 * Request parameters for exporting activity data.
+	 */
+	params := map[string]string{
+		"cgi": "export-activities-csv",
+	}
+
+	r, _, err := this.download(params, "text/csv")
+	return r, err
+}
+
+/*
+ * Exports the contents of the geographical database from the remote instance.
+ *
+ * Supported formats are "binary", "csv", "gpx", "gpx-pretty", "json",
+ * "json-pretty", "kml" and "kml-pretty".
+ *
+ * The caller is expected to close the stream.
+ */
+func (this *sessionStruct) ExportGeoData(format string) (io.ReadCloser, error) {
+	expectedType := ""
+
+	/*
+	 * Decide on the content type the server will respond with.
+	 */
+	switch format {
+	case "binary":
+		expectedType = "application/octet-stream"
+	case "csv":
+		expectedType = "text/csv"
+	case "gpx", "gpx-pretty":
+		expectedType = "application/gpx+xml"
+	case "json", "json-pretty":
+		expectedType = "application/json"
+	case "kml", "kml-pretty":
+		expectedType = "application/vnd.google-earth.kml+xml"
+	}
+
+	/*
+	 * Check if format is supported.
+	 */
+	if expectedType == "" {
+		return nil, fmt.Errorf("Unknown format: '%s'", format)
+	} else {
+
+		/*
+		 * Request parameters for exporting geographical data.
+		 */
+		params := map[string]string{
+			"cgi":    "download-geodb-content",
+			"format": format,
+		}
+
+		r, _, err := this.download(params, expectedType)
+		return r, err
+	}
+
+}
+
+/*
+ * Imports activity data in CSV format on the remote instance.
+ *
+ * If the remote instance rejects the data, the error is a *ServerError.
+ */
+func (this *sessionStruct) ImportActivityCsv(csv string) error {
+
+	/*
+	 * Request parameters for importing activity data.
+	 */
+	params := map[string]string{
+		"cgi":  "import-activity-csv",
+		"data": csv,
+	}
+
+	err := this.call(params)
+	return err
+}
+
+/*
+ * Imports geographical data into the database of the remote instance.
+ *
+ * Supported formats are "binary", "csv", "gpx", "json" and "kml". Supported
+ * strategies are "all", "newer" and "none".
+ *
+ * If the remote instance reports a failure, the error is a *ServerError.
+ *
+ * Since the data is streamed to the remote instance, an expired session is
+ * not renewed automatically.
+ */
+func (this *sessionStruct) ImportGeoData(format string, strategy string, r io.Reader) error {
+	conn := this.conn
+	token := this.currentToken()
+
+	/*
+	 * Request parameters for importing geographical data.
+	 */
+	params := map[string]string{
+		"cgi":      "import-geodata",
+		"format":   format,
+		"strategy": strategy,
+		"token":    token,
+	}
+
+	report := webMigrationReportStruct{}
+	err := conn.requestJSON(params, r, &report)
+
+	/*
+	 * Check if data was imported.
+	 */
+	if err != nil {
+		return err
+	} else {
+		status := report.Status
+
+		/*
+		 * Check if server reported success.
+		 */
+		if !status.Success {
+			reason := status.Reason
+			err = createServerError(reason)
+			return err
+		} else {
+			return nil
+		}
+
+	}
+
+}
+
+/*
+ * Terminates this session.
+ */
+func (this *sessionStruct) Logout() error {
+
+	/*
+	 * Request parameters for terminating session.
+	 */
+	params := map[string]string{
+		"cgi": "auth-logout",
+	}
+
+	token := this.currentToken()
+	err := this.callToken(params, token)
+
+	/*
+	 * Check if session was terminated.
+	 */
+	if err == ErrSessionExpired {
+		return err
+	} else if err != nil {
+		msg := err.Error()
+		return fmt.Errorf("Failed to terminate session: %s", msg)
+	} else {
+		return nil
+	}
+
+}
+
+/*
+ * Renders a visualization of the geographical data on the remote instance.
+ *
+ * The stream provides an image in PNG format. The caller is expected to close
+ * the stream.
+ */
+func (this *sessionStruct) Render(req RenderRequest) (io.ReadCloser, error) {
+	xres64 := uint64(req.XRes)
+	xresString := strconv.FormatUint(xres64, 10)
+	yres64 := uint64(req.YRes)
+	yresString := strconv.FormatUint(yres64, 10)
+	xposString := strconv.FormatFloat(req.XPos, 'f', -1, 64)
+	yposString := strconv.FormatFloat(req.YPos, 'f', -1, 64)
+	zoom64 := uint64(req.Zoom)
+	zoomString := strconv.FormatUint(zoom64, 10)
+	spread64 := uint64(req.Spread)
+	spreadString := strconv.FormatUint(spread64, 10)
+
+	/*
+	 * Request parameters for rendering.
+	 */
+	params := map[string]string{
+		"cgi":     "render",
+		"xres":    xresString,
+		"yres":    yresString,
+		"xpos":    xposString,
+		"ypos":    yposString,
+		"zoom":    zoomString,
+		"mintime": req.MinTime,
+		"maxtime": req.MaxTime,
+		"tz":      req.TimeZone,
+		"fgcolor": req.FgColor,
+		"spread":  spreadString,
+	}
+
+	r, _, err := this.download(params, "image/png")
+	return r, err
+}
+
+/*
+ * Enables or disables automatic renewal of this session.
+ *
+ * When enabled, an expired session is renewed by authenticating again with
+ * the credentials used to create it, which are kept in memory for this
+ * purpose. Sessions obtained via Resume cannot be renewed.
+ */
+func (this *sessionStruct) SetAutoRenew(enabled bool) {
+	this.mutex.Lock()
+	this.autoRenew = enabled
+	this.mutex.Unlock()
+}
+
+/*
+ * Returns the token identifying this session.
+ *
+ * The token changes when the session is renewed.
+ */
+func (this *sessionStruct) Token() string {
+	token := this.currentToken()
+	return token
+}
+
+/*
+ * Creates a connection to a remote location-visualizer instance.
+ *
+ * The URI is the base URI of the instance, e. g. "https://localhost:8443".
+ */
+func CreateConnection(uri string, client *http.Client) (Connection, error) {
+
+	/*
+	 * Check if HTTP client was provided.
+	 */
+	if client == nil {
+		return nil, fmt.Errorf("%s", "HTTP client must not be nil!")
+	} else {
+		uri = strings.TrimSuffix(uri, "/")
+
+		/*
+		 * Create connection.
+		 */
+		conn := connectionStruct{
+			client: client,
+			uri:    uri,
+		}
+
+		return &conn, nil
+	}
+
+}
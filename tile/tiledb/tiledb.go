@@ -14,13 +14,19 @@ import (
 )
 
 const (
-	MAGIC_IMAGEDB      = 0x496d616765444204
-	MAGIC_INDEXDB      = 0x496e646578444204
-	SIZE_BUFFER        = 8192
-	SIZE_HASH          = 64
-	SIZE_INDEXDB_ENTRY = 81
-	SIZE_LENGTH_FIELD  = 4
-	SIZE_MAGIC         = 8
+	MAGIC_CONTAINER        = 0x54696c6544420004
+	MAGIC_IMAGEDB          = 0x496d616765444204
+	MAGIC_INDEXDB          = 0x496e646578444204
+	NUM_SEGMENTS           = 2
+	SEGMENT_IMAGEDB        = 0
+	SEGMENT_INDEXDB        = 1
+	SIZE_BUFFER            = 8192
+	SIZE_CONTAINER_HEADER  = 56
+	SIZE_HASH              = 64
+	SIZE_INDEXDB_ENTRY     = 81
+	SIZE_LENGTH_FIELD      = 4
+	SIZE_MAGIC             = 8
+	SIZE_SEGMENT_ALIGNMENT = 65536
 )
 
 /*
@@ -37,15 +43,19 @@ type ImageHandle [SIZE_HASH]byte
 type ImageDatabase interface {
 	Cleanup(keep func(ImageHandle) bool) error
 	Close() error
+	Count() (uint64, error)
 	Insert(buf []byte) (ImageHandle, error)
 	Open(handle ImageHandle) (tile.Image, error)
+	Size() (uint64, error)
 }
 
 /*
  * A database mapping OSM tile IDs to image handles.
  */
 type IndexDatabase interface {
+	Cleanup(keep func(tile.Id, TileMetadata) bool) (uint64, error)
 	Close() error
+	Compact() error
 	Entry(idx uint64) (tile.Id, TileMetadata, error)
 	Insert(id tile.Id, metadata TileMetadata) error
 	Length() (uint64, error)
@@ -53,6 +63,15 @@ type IndexDatabase interface {
 	Sort() error
 }
 
+/*
+ * A container bundling the storage of an image database and an index database
+ * inside a single underlying storage.
+ */
+type Container interface {
+	ImageStorage() Storage
+	IndexStorage() Storage
+}
+
 /*
  * Interface that a storage backing a database will have to implement.
  *
@@ -61,6 +80,7 @@ type IndexDatabase interface {
 type Storage interface {
 	ReadAt(buf []byte, offset int64) (int, error)
 	Seek(offset int64, whence int) (int64, error)
+	Sync() error
 	Truncate(size int64) error
 	WriteAt(buf []byte, offset int64) (int, error)
 }
@@ -554,6 +574,9 @@ func (this *imageDatabaseStruct) Cleanup(keep func(ImageHandle) bool) error {
 /*
  * Closes the image database, releasing the associated file descriptor.
  *
+ * Pending writes are synced to the underlying storage first. The database is
+ * closed even if this fails.
+ *
  * Closing an image database, which has already been closed, is an error.
  */
 func (this *imageDatabaseStruct) Close() error {
@@ -567,6 +590,16 @@ func (this *imageDatabaseStruct) Close() error {
 	if fd == nil {
 		errResult = fmt.Errorf("%s", "Image database is already closed.")
 	} else {
+		err := fd.Sync()
+
+		/*
+		 * Check if pending writes could be synced.
+		 */
+		if err != nil {
+			msg := err.Error()
+			errResult = fmt.Errorf("Failed to sync image database: %s", msg)
+		}
+
 		this.fd = nil
 	}
 
@@ -574,6 +607,29 @@ func (this *imageDatabaseStruct) Close() error {
 	return errResult
 }
 
+/*
+ * Returns the number of distinct images stored in this image database.
+ */
+func (this *imageDatabaseStruct) Count() (uint64, error) {
+	result, errResult := uint64(0), error(nil)
+	this.mutex.RLock()
+	fd := this.fd
+
+	/*
+	 * Check if file is open.
+	 */
+	if fd == nil {
+		errResult = fmt.Errorf("%s", "Image database is not open.")
+	} else {
+		index := this.index
+		numImages := len(index)
+		result = uint64(numImages)
+	}
+
+	this.mutex.RUnlock()
+	return result, errResult
+}
+
 /*
  * Inserts an image into the database, yielding a handle and, potentially, an
  * error.
@@ -783,6 +839,28 @@ func (this *imageDatabaseStruct) Open(handle ImageHandle) (tile.Image, error) {
 	return result, errResult
 }
 
+/*
+ * Returns the number of bytes occupied by this image database in the
+ * underlying storage.
+ */
+func (this *imageDatabaseStruct) Size() (uint64, error) {
+	result, errResult := uint64(0), error(nil)
+	this.mutex.RLock()
+	fd := this.fd
+
+	/*
+	 * Check if file is open.
+	 */
+	if fd == nil {
+		errResult = fmt.Errorf("%s", "Image database is not open.")
+	} else {
+		result = this.size
+	}
+
+	this.mutex.RUnlock()
+	return result, errResult
+}
+
 /*
  * Creates an image database backed by Storage.
  */
@@ -973,9 +1051,165 @@ func (this *indexDatabaseStruct) numEntries(fd Storage) (uint64, error) {
 
 }
 
+/*
+ * Removes all entries for which the keep function returns false from the
+ * index database and rebuilds the index.
+ *
+ * The keep function is passed the position of each entry, as well as its
+ * contents. The remaining entries keep their order. Returns the number of
+ * entries removed.
+ *
+ * This function assumes that the database is locked for writing.
+ */
+func (this *indexDatabaseStruct) compact(fd Storage, keep func(uint64, tile.Id, TileMetadata) bool) (uint64, error) {
+	errResult := error(nil)
+	numRemoved := uint64(0)
+
+	/*
+	 * Check if database is still open.
+	 */
+	if fd == nil {
+		errResult = fmt.Errorf("%s", "Index database is already closed.")
+	} else {
+		numEntries, err := this.numEntries(fd)
+
+		/*
+		 * Check if number of entries could be retrieved.
+		 */
+		if err != nil {
+			msg := err.Error()
+			errResult = fmt.Errorf("Failed to retrieve number of entries from index database: %s", msg)
+		} else {
+			entry := indexDatabaseEntryStruct{}
+			idxWrite := uint64(0)
+
+			/*
+			 * Move entries to keep towards the beginning of the database.
+			 */
+			for idx := uint64(0); (idx < numEntries) && (errResult == nil); idx++ {
+				err := this.readEntry(fd, idx, &entry)
+
+				/*
+				 * Check if error occured reading entry.
+				 */
+				if err != nil {
+					msg := err.Error()
+					errResult = fmt.Errorf("Error occured while reading entry %d from index database: %s", idx, msg)
+				} else {
+					x := entry.X
+					y := entry.Y
+					z := entry.Z
+					tileId := tile.CreateId(z, x, y)
+					timestamp := entry.TimestampMs
+					h := entry.Hash
+					img := ImageHandle(h)
+
+					/*
+					 * Create tile metadata.
+					 */
+					tileMetadata := TileMetadata{
+						handle:      img,
+						timestampMs: timestamp,
+					}
+
+					kept := keep(idx, tileId, tileMetadata)
+
+					/*
+					 * Check if entry should be kept.
+					 */
+					if !kept {
+						numRemoved++
+					} else {
+
+						/*
+						 * Only write entry if it has to be moved.
+						 */
+						if idxWrite != idx {
+							err := this.writeEntry(fd, idxWrite, &entry)
+
+							/*
+							 * Check if error occured writing entry.
+							 */
+							if err != nil {
+								msg := err.Error()
+								errResult = fmt.Errorf("Failed to write entry %d to index database: %s", idxWrite, msg)
+							}
+
+						}
+
+						idxWrite++
+					}
+
+				}
+
+			}
+
+			/*
+			 * Remove entries left over at the end of the database.
+			 */
+			if (errResult == nil) && (numRemoved > 0) {
+				size := this.calculateOffset(idxWrite)
+				err := fd.Truncate(size)
+
+				/*
+				 * Check if database could be truncated.
+				 */
+				if err != nil {
+					msg := err.Error()
+					errResult = fmt.Errorf("Failed to truncate index database: %s", msg)
+				}
+
+			}
+
+			errIndex := this.buildIndex(fd)
+
+			/*
+			 * If no error occured during cleanup, but an error occured
+			 * during indexing, report the latter.
+			 */
+			if (errResult == nil) && (errIndex != nil) {
+				errResult = errIndex
+			}
+
+		}
+
+	}
+
+	return numRemoved, errResult
+}
+
+/*
+ * Removes all entries for which the keep function returns false from the
+ * index database.
+ *
+ * The remaining entries keep their order. Returns the number of entries
+ * removed.
+ *
+ * This locks the database for write access.
+ */
+func (this *indexDatabaseStruct) Cleanup(keep func(tile.Id, TileMetadata) bool) (uint64, error) {
+
+	/*
+	 * Ignore the position of the entries.
+	 */
+	keepEntry := func(idx uint64, id tile.Id, metadata TileMetadata) bool {
+		result := keep(id, metadata)
+		return result
+	}
+
+	this.mutex.Lock()
+	fd := this.fd
+	numRemoved, err := this.compact(fd, keepEntry)
+	this.mutex.Unlock()
+	return numRemoved, err
+}
+
 /*
  * Closes the index database, releasing the associated file descriptor.
  *
+ * Pending writes are synced to the underlying storage first. The database is
+ * closed even if this fails.
+ *
  * Closing an index database, which has already been closed, is an error.
  */
 func (this *indexDatabaseStruct) Close() error {
@@ -989,6 +1223,16 @@ func (this *indexDatabaseStruct) Close() error {
 	if fd == nil {
 		errResult = fmt.Errorf("%s", "Index database is already closed.")
 	} else {
+		err := fd.Sync()
+
+		/*
+		 * Check if pending writes could be synced.
+		 */
+		if err != nil {
+			msg := err.Error()
+			errResult = fmt.Errorf("Failed to sync index database: %s", msg)
+		}
+
 		this.fd = nil
 	}
 
@@ -996,6 +1240,34 @@ func (this *indexDatabaseStruct) Close() error {
 	return errResult
 }
 
+/*
+ * Removes all entries from the index database, which are no longer referenced
+ * by the index, and truncates the database afterwards.
+ *
+ * Such entries are left behind if the same tile was stored more than once,
+ * for example when a write was interrupted.
+ *
+ * This locks the database for write access.
+ */
+func (this *indexDatabaseStruct) Compact() error {
+	this.mutex.Lock()
+	fd := this.fd
+	index := this.index
+
+	/*
+	 * Only keep entries which are referenced by the index.
+	 */
+	keepEntry := func(idx uint64, id tile.Id, metadata TileMetadata) bool {
+		idxLive, found := index[id]
+		result := found && (idxLive == idx)
+		return result
+	}
+
+	_, err := this.compact(fd, keepEntry)
+	this.mutex.Unlock()
+	return err
+}
+
 /*
  * Retrieves an entry from the index database by index.
  */
@@ -1693,3 +1965,669 @@ func CreateTileMetadata(timestampMs int64, handle ImageHandle) TileMetadata {
 
 	return m
 }
+
+/*
+ * Data structure describing the location of a segment inside a container.
+ *
+ * The offset and capacity describe the area reserved for the segment, while
+ * the size describes how much of this area is actually in use.
+ */
+type containerSegmentStruct struct {
+	Offset   uint64
+	Capacity uint64
+	Size     uint64
+}
+
+/*
+ * Data structure representing the header of a container.
+ */
+type containerHeaderStruct struct {
+	Magic    uint64
+	Segments [NUM_SEGMENTS]containerSegmentStruct
+}
+
+/*
+ * Data structure representing a Container.
+ */
+type containerStruct struct {
+	mutex    sync.RWMutex
+	fd       Storage
+	header   containerHeaderStruct
+	storages [NUM_SEGMENTS]*segmentStorageStruct
+}
+
+/*
+ * Data structure representing a Storage backed by a segment of a Container.
+ */
+type segmentStorageStruct struct {
+	container *containerStruct
+	segment   int
+	position  int64
+}
+
+/*
+ * Copies data from one location inside the underlying storage to another.
+ *
+ * Source and destination areas must not overlap.
+ *
+ * This function assumes that the container is locked for writing.
+ */
+func (this *containerStruct) copyData(src uint64, dst uint64, length uint64) error {
+	fd := this.fd
+	buf := make([]byte, SIZE_BUFFER)
+	copied := uint64(0)
+	errResult := error(nil)
+
+	/*
+	 * Copy data block by block.
+	 */
+	for (copied < length) && (errResult == nil) {
+		remaining := length - copied
+		chunk := buf
+
+		/*
+		 * Only copy the remaining data in the last block.
+		 */
+		if remaining < SIZE_BUFFER {
+			chunk = buf[:remaining]
+		}
+
+		chunkSize := len(chunk)
+		offsetRead := int64(src + copied)
+		n, err := fd.ReadAt(chunk, offsetRead)
+
+		/*
+		 * Check if block was read.
+		 */
+		if (n != chunkSize) && (err != nil) {
+			msg := err.Error()
+			errResult = fmt.Errorf("Failed to read from offset 0x%016x: %s", offsetRead, msg)
+		} else if n != chunkSize {
+			errResult = fmt.Errorf("Failed to read from offset 0x%016x: Expected %d bytes, got %d.", offsetRead, chunkSize, n)
+		} else {
+			offsetWrite := int64(dst + copied)
+			n, err = fd.WriteAt(chunk, offsetWrite)
+
+			/*
+			 * Check if block was written.
+			 */
+			if err != nil {
+				msg := err.Error()
+				errResult = fmt.Errorf("Failed to write to offset 0x%016x: %s", offsetWrite, msg)
+			} else if n != chunkSize {
+				errResult = fmt.Errorf("Failed to write to offset 0x%016x: Expected %d bytes, wrote %d.", offsetWrite, chunkSize, n)
+			} else {
+				copied += uint64(chunkSize)
+			}
+
+		}
+
+	}
+
+	return errResult
+}
+
+/*
+ * Grows a segment so that it can hold at least the required amount of bytes.
+ *
+ * A segment located at the end of the container simply extends its capacity.
+ * Otherwise, the smaller of the two segments is relocated to the end of the
+ * container, so that only little data has to be copied. The header is only
+ * updated after the data has been copied, so that an interrupted relocation
+ * leaves the container in its previous state.
+ *
+ * This function assumes that the container is locked for writing.
+ */
+func (this *containerStruct) grow(idx int, required uint64) error {
+	const MAX_SIZE = math.MaxInt64 / 4
+	errResult := error(nil)
+
+	/*
+	 * Check if required size is in range.
+	 */
+	if required > MAX_SIZE {
+		errResult = fmt.Errorf("Segment too large: 0x%016x (Maximum allowed is 0x%016x.)", required, uint64(MAX_SIZE))
+	} else {
+		capacity := required + (required / 4)
+		capacity = ((capacity + SIZE_SEGMENT_ALIGNMENT - 1) / SIZE_SEGMENT_ALIGNMENT) * SIZE_SEGMENT_ALIGNMENT
+		segments := &this.header.Segments
+		segment := &segments[idx]
+		otherIdx := (idx + 1) % NUM_SEGMENTS
+		other := &segments[otherIdx]
+		segmentEnd := segment.Offset + segment.Capacity
+		otherEnd := other.Offset + other.Capacity
+
+		/*
+		 * Decide how to make room for the segment.
+		 */
+		if segmentEnd >= otherEnd {
+			end := segment.Offset + capacity
+
+			/*
+			 * Check if segment end is still in range.
+			 */
+			if end > math.MaxInt64 {
+				errResult = fmt.Errorf("Segment end too large: 0x%016x (Maximum allowed is 0x%016x.)", end, uint64(math.MaxInt64))
+			} else {
+				segment.Capacity = capacity
+			}
+
+		} else if other.Size <= segment.Size {
+			target := segment.Offset + capacity
+
+			/*
+			 * Never move the other segment backwards.
+			 */
+			if target < otherEnd {
+				target = otherEnd
+			}
+
+			end := target + other.Capacity
+
+			/*
+			 * Check if segment end is still in range.
+			 */
+			if end > math.MaxInt64 {
+				errResult = fmt.Errorf("Segment end too large: 0x%016x (Maximum allowed is 0x%016x.)", end, uint64(math.MaxInt64))
+			} else {
+				errResult = this.copyData(other.Offset, target, other.Size)
+
+				/*
+				 * Check if data was relocated.
+				 */
+				if errResult == nil {
+					other.Offset = target
+					segment.Capacity = capacity
+				}
+
+			}
+
+		} else {
+			target := otherEnd
+			end := target + capacity
+
+			/*
+			 * Check if segment end is still in range.
+			 */
+			if end > math.MaxInt64 {
+				errResult = fmt.Errorf("Segment end too large: 0x%016x (Maximum allowed is 0x%016x.)", end, uint64(math.MaxInt64))
+			} else {
+				errResult = this.copyData(segment.Offset, target, segment.Size)
+
+				/*
+				 * Check if data was relocated.
+				 */
+				if errResult == nil {
+					segment.Offset = target
+					segment.Capacity = capacity
+				}
+
+			}
+
+		}
+
+		/*
+		 * Persist new layout.
+		 */
+		if errResult == nil {
+			errResult = this.writeHeader()
+		}
+
+	}
+
+	return errResult
+}
+
+/*
+ * Initialize container by either writing header to file descriptor (if file
+ * is empty) or reading and validating the header.
+ */
+func (this *containerStruct) initialize() error {
+	errResult := error(nil)
+	fd := this.fd
+
+	/*
+	 * Verify that file descriptor is not nil.
+	 */
+	if fd == nil {
+		errResult = fmt.Errorf("%s", "File descriptor must not be nil.")
+	} else {
+		size, err := fd.Seek(0, io.SeekEnd)
+
+		/*
+		 * Check if determining file size was successful.
+		 */
+		if (size < 0) || (err != nil) {
+			errResult = fmt.Errorf("%s", "Failed to seek to end of file.")
+		} else if size == 0 {
+			header := &this.header
+			header.Magic = MAGIC_CONTAINER
+			offset := uint64(SIZE_CONTAINER_HEADER)
+
+			/*
+			 * Reserve some space for each segment.
+			 */
+			for i := range header.Segments {
+
+				/*
+				 * Create segment.
+				 */
+				header.Segments[i] = containerSegmentStruct{
+					Offset:   offset,
+					Capacity: SIZE_SEGMENT_ALIGNMENT,
+				}
+
+				offset += SIZE_SEGMENT_ALIGNMENT
+			}
+
+			errResult = this.writeHeader()
+		} else if size < SIZE_CONTAINER_HEADER {
+			errResult = fmt.Errorf("File too small: Should have at least %d bytes.", SIZE_CONTAINER_HEADER)
+		} else {
+			endian := binary.BigEndian
+			r := io.NewSectionReader(fd, 0, SIZE_CONTAINER_HEADER)
+			header := &this.header
+			err := binary.Read(r, endian, header)
+
+			/*
+			 * Verify header was read correctly.
+			 */
+			if err != nil {
+				errResult = fmt.Errorf("%s", "Failed to read header from file.")
+			} else if header.Magic != MAGIC_CONTAINER {
+				errResult = fmt.Errorf("Failed to read magic number from file: Expected 0x%016x, found 0x%016x.", MAGIC_CONTAINER, header.Magic)
+			} else {
+				errResult = this.validate(size)
+			}
+
+		}
+
+	}
+
+	return errResult
+}
+
+/*
+ * Verifies that the segments described by the header are consistent with each
+ * other and with the size of the underlying storage.
+ */
+func (this *containerStruct) validate(size int64) error {
+	segments := this.header.Segments
+	size64 := uint64(size)
+
+	/*
+	 * Check each segment.
+	 */
+	for i, segment := range segments {
+		offset := segment.Offset
+		capacity := segment.Capacity
+
+		/*
+		 * Check if segment lies within the container.
+		 */
+		if offset < SIZE_CONTAINER_HEADER {
+			return fmt.Errorf("Segment %d overlaps header.", i)
+		} else if (offset > math.MaxInt64) || (capacity > (math.MaxInt64 - offset)) {
+			return fmt.Errorf("Segment %d exceeds maximum size.", i)
+		} else if segment.Size > capacity {
+			return fmt.Errorf("Segment %d is larger than its capacity.", i)
+		} else if (offset + segment.Size) > size64 {
+			return fmt.Errorf("Segment %d extends beyond end of file.", i)
+		}
+
+		/*
+		 * Check if segment overlaps any of the segments following it.
+		 */
+		for j := i + 1; j < NUM_SEGMENTS; j++ {
+			other := segments[j]
+			otherOffset := other.Offset
+			end := offset + capacity
+			otherEnd := otherOffset + other.Capacity
+
+			/*
+			 * Segments must be disjoint.
+			 */
+			if (end > otherOffset) && (otherEnd > offset) {
+				return fmt.Errorf("Segments %d and %d overlap.", i, j)
+			}
+
+		}
+
+	}
+
+	return nil
+}
+
+/*
+ * Writes the header to the underlying storage.
+ *
+ * This function assumes that the container is locked for writing.
+ */
+func (this *containerStruct) writeHeader() error {
+	fd := this.fd
+	w := io.NewOffsetWriter(fd, 0)
+	endian := binary.BigEndian
+	err := binary.Write(w, endian, &this.header)
+
+	/*
+	 * Check if header could be written.
+	 */
+	if err != nil {
+		msg := err.Error()
+		return fmt.Errorf("Failed to write container header: %s", msg)
+	} else {
+		return nil
+	}
+
+}
+
+/*
+ * Returns the storage for the image database inside this container.
+ */
+func (this *containerStruct) ImageStorage() Storage {
+	return this.storages[SEGMENT_IMAGEDB]
+}
+
+/*
+ * Returns the storage for the index database inside this container.
+ */
+func (this *containerStruct) IndexStorage() Storage {
+	return this.storages[SEGMENT_INDEXDB]
+}
+
+/*
+ * Reads data from the segment at a certain offset.
+ */
+func (this *segmentStorageStruct) ReadAt(buf []byte, offset int64) (int, error) {
+	c := this.container
+	n := 0
+	errResult := error(nil)
+	c.mutex.RLock()
+	segment := c.header.Segments[this.segment]
+	size := int64(segment.Size)
+
+	/*
+	 * Check if offset is within the segment.
+	 */
+	if offset < 0 {
+		errResult = fmt.Errorf("%s", "Offset must not be negative.")
+	} else if offset >= size {
+		errResult = io.EOF
+	} else {
+		remaining := size - offset
+		bufSize := len(buf)
+		bufSize64 := int64(bufSize)
+		chunk := buf
+
+		/*
+		 * Do not read beyond the end of the segment.
+		 */
+		if bufSize64 > remaining {
+			chunk = buf[:remaining]
+		}
+
+		chunkSize := len(chunk)
+		offsetPhysical := int64(segment.Offset) + offset
+		n, errResult = c.fd.ReadAt(chunk, offsetPhysical)
+
+		/*
+		 * The end of the underlying storage is not the end of the segment.
+		 */
+		if (n == chunkSize) && (errResult == io.EOF) {
+			errResult = nil
+		}
+
+		/*
+		 * Signal end of segment if data is missing.
+		 */
+		if (errResult == nil) && (n < bufSize) {
+			errResult = io.EOF
+		}
+
+	}
+
+	c.mutex.RUnlock()
+	return n, errResult
+}
+
+/*
+ * Sets the offset for the next Read or Write operation on the segment.
+ */
+func (this *segmentStorageStruct) Seek(offset int64, whence int) (int64, error) {
+	c := this.container
+	result := int64(0)
+	errResult := error(nil)
+	c.mutex.Lock()
+	segment := c.header.Segments[this.segment]
+	base := int64(0)
+
+	/*
+	 * Determine the position the offset is relative to.
+	 */
+	switch whence {
+	case io.SeekStart:
+		base = 0
+	case io.SeekCurrent:
+		base = this.position
+	case io.SeekEnd:
+		base = int64(segment.Size)
+	default:
+		errResult = fmt.Errorf("Invalid whence: %d", whence)
+	}
+
+	/*
+	 * Calculate new position.
+	 */
+	if errResult == nil {
+		target := base + offset
+
+		/*
+		 * Check if new position is valid.
+		 */
+		if target < 0 {
+			errResult = fmt.Errorf("%s", "Resulting offset must not be negative.")
+		} else {
+			this.position = target
+			result = target
+		}
+
+	}
+
+	c.mutex.Unlock()
+	return result, errResult
+}
+
+/*
+ * Commits the contents of the underlying storage to stable storage.
+ *
+ * Since all segments share the underlying storage, this syncs all of them.
+ */
+func (this *segmentStorageStruct) Sync() error {
+	c := this.container
+	c.mutex.RLock()
+	fd := c.fd
+	err := fd.Sync()
+	c.mutex.RUnlock()
+	return err
+}
+
+/*
+ * Changes the size of the segment.
+ *
+ * Extending the segment fills the new area with zeroes.
+ */
+func (this *segmentStorageStruct) Truncate(size int64) error {
+	c := this.container
+	errResult := error(nil)
+	c.mutex.Lock()
+
+	/*
+	 * Check if size is valid.
+	 */
+	if size < 0 {
+		errResult = fmt.Errorf("%s", "Size must not be negative.")
+	} else {
+		segment := &c.header.Segments[this.segment]
+		size64 := uint64(size)
+
+		/*
+		 * Make room for the segment if required.
+		 */
+		if size64 > segment.Capacity {
+			errResult = c.grow(this.segment, size64)
+		}
+
+		/*
+		 * Fill new area with zeroes.
+		 */
+		if (errResult == nil) && (size64 > segment.Size) {
+			buf := make([]byte, SIZE_BUFFER)
+			offset := segment.Size
+
+			/*
+			 * Write zeroes block by block.
+			 */
+			for (offset < size64) && (errResult == nil) {
+				remaining := size64 - offset
+				chunk := buf
+
+				/*
+				 * Only write the remaining zeroes in the last block.
+				 */
+				if remaining < SIZE_BUFFER {
+					chunk = buf[:remaining]
+				}
+
+				offsetPhysical := int64(segment.Offset + offset)
+				n, err := c.fd.WriteAt(chunk, offsetPhysical)
+
+				/*
+				 * Check if zeroes were written.
+				 */
+				if err != nil {
+					msg := err.Error()
+					errResult = fmt.Errorf("Failed to write to offset 0x%016x: %s", offsetPhysical, msg)
+				} else {
+					offset += uint64(n)
+				}
+
+			}
+
+		}
+
+		/*
+		 * Persist new segment size.
+		 */
+		if errResult == nil {
+			segment.Size = size64
+			errResult = c.writeHeader()
+		}
+
+	}
+
+	c.mutex.Unlock()
+	return errResult
+}
+
+/*
+ * Writes data to the segment at a certain offset, growing the segment if
+ * required.
+ */
+func (this *segmentStorageStruct) WriteAt(buf []byte, offset int64) (int, error) {
+	c := this.container
+	n := 0
+	errResult := error(nil)
+	c.mutex.Lock()
+
+	/*
+	 * Check if offset is valid.
+	 */
+	if offset < 0 {
+		errResult = fmt.Errorf("%s", "Offset must not be negative.")
+	} else {
+		segment := &c.header.Segments[this.segment]
+		bufSize := len(buf)
+		offset64 := uint64(offset)
+		end := offset64 + uint64(bufSize)
+
+		/*
+		 * Make room for the data if required.
+		 */
+		if end > segment.Capacity {
+			errResult = c.grow(this.segment, end)
+		}
+
+		/*
+		 * Write data and update segment size.
+		 */
+		if errResult == nil {
+			offsetPhysical := int64(segment.Offset) + offset
+			n, errResult = c.fd.WriteAt(buf, offsetPhysical)
+			written := offset64 + uint64(n)
+
+			/*
+			 * Check if segment grew.
+			 */
+			if written > segment.Size {
+				segment.Size = written
+				err := c.writeHeader()
+
+				/*
+				 * Check if error occured and it's the first one.
+				 */
+				if (err != nil) && (errResult == nil) {
+					errResult = err
+				}
+
+			}
+
+		}
+
+	}
+
+	c.mutex.Unlock()
+	return n, errResult
+}
+
+/*
+ * Creates a container backed by Storage, which holds both an image database
+ * and an index database.
+ *
+ * The databases are created by passing the storages provided by the container
+ * to CreateImageDatabase and CreateIndexDatabase.
+ */
+func CreateContainer(fd Storage) (Container, error) {
+
+	/*
+	 * Create container.
+	 */
+	c := &containerStruct{
+		fd: fd,
+	}
+
+	/*
+	 * Create a storage for each segment.
+	 */
+	for i := range c.storages {
+
+		/*
+		 * Create segment storage.
+		 */
+		c.storages[i] = &segmentStorageStruct{
+			container: c,
+			segment:   i,
+		}
+
+	}
+
+	err := c.initialize()
+
+	/*
+	 * If an error occured during initialization, destroy container.
+	 */
+	if err != nil {
+		c = nil
+	}
+
+	return c, err
+}
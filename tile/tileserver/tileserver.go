@@ -10,18 +10,23 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
+	lsync "github.com/andrepxx/location-visualizer/sync"
 	"github.com/andrepxx/location-visualizer/tile"
 )
 
 const (
-	ALL            = -1
-	BASE_DECIMAL   = 10
-	MAX_ZOOM_LEVEL = 19
-	TEMPLATE_X     = "${x}"
-	TEMPLATE_Y     = "${y}"
-	TEMPLATE_ZOOM  = "${z}"
-	TILE_SIZE      = 256
+	ALL                = -1
+	BASE_DECIMAL       = 10
+	DEFAULT_USER_AGENT = "location-visualizer"
+	MAX_ATTEMPTS       = 10
+	MAX_ZOOM_LEVEL     = 19
+	TEMPLATE_SUBDOMAIN = "${s}"
+	TEMPLATE_X         = "${x}"
+	TEMPLATE_Y         = "${y}"
+	TEMPLATE_ZOOM      = "${z}"
+	TILE_SIZE          = 256
 )
 
 /*
@@ -29,6 +34,7 @@ const (
  */
 type OSMTileServer interface {
 	Get(z uint8, x uint32, y uint32) (tile.Image, error)
+	GetConditional(z uint8, x uint32, y uint32, modifiedSince time.Time, etag string) (tile.Image, string, bool, error)
 }
 
 /*
@@ -50,30 +56,304 @@ func (this *readSeekerReaderAtWithNopCloserStruct) Close() error {
  * Data structure representing the remote tile server.
  */
 type osmTileServerStruct struct {
-	mutex sync.Mutex
-	uri   string
+	connections    lsync.Semaphore
+	mutexRequest   sync.Mutex
+	lastRequest    time.Time
+	maxAttempts    uint32
+	minInterval    time.Duration
+	retryDelay     time.Duration
+	subdomainIndex int
+	subdomains     []string
+	uris           []string
+	userAgent      string
 }
 
 /*
- * Build a tile path from a template, zoom level, x and y coordinate.
+ * Returns the next subdomain to use, rotating through all configured
+ * subdomains.
+ *
+ * Returns an empty string if no subdomains are configured.
  */
-func (this *osmTileServerStruct) tilePath(template string, zoom uint8, x uint32, y uint32) string {
+func (this *osmTileServerStruct) nextSubdomain() string {
+	subdomains := this.subdomains
+	numSubdomains := len(subdomains)
+	result := ""
+
+	/*
+	 * Only rotate if there are subdomains.
+	 */
+	if numSubdomains > 0 {
+		this.mutexRequest.Lock()
+		idx := this.subdomainIndex
+		result = subdomains[idx]
+		this.subdomainIndex = (idx + 1) % numSubdomains
+		this.mutexRequest.Unlock()
+	}
+
+	return result
+}
+
+/*
+ * Build a tile path from a template, subdomain, zoom level, x and y
+ * coordinate.
+ */
+func (this *osmTileServerStruct) tilePath(template string, subdomain string, zoom uint8, x uint32, y uint32) string {
 	zoom64 := uint64(zoom)
 	zoomString := strconv.FormatUint(zoom64, BASE_DECIMAL)
 	x64 := uint64(x)
 	xString := strconv.FormatUint(x64, BASE_DECIMAL)
 	y64 := uint64(y)
 	yString := strconv.FormatUint(y64, BASE_DECIMAL)
+	template = strings.Replace(template, TEMPLATE_SUBDOMAIN, subdomain, ALL)
 	template = strings.Replace(template, TEMPLATE_ZOOM, zoomString, ALL)
 	template = strings.Replace(template, TEMPLATE_X, xString, ALL)
 	template = strings.Replace(template, TEMPLATE_Y, yString, ALL)
 	return template
 }
 
+/*
+ * Wait until the minimum interval since the previous request to the server
+ * has passed.
+ *
+ * Each caller reserves the next free slot before waiting, so that concurrent
+ * requests are spread out evenly.
+ */
+func (this *osmTileServerStruct) throttle() {
+	minInterval := this.minInterval
+
+	/*
+	 * Only throttle requests if a minimum interval is configured.
+	 */
+	if minInterval > 0 {
+		this.mutexRequest.Lock()
+		now := time.Now()
+		next := this.lastRequest.Add(minInterval)
+
+		/*
+		 * Send request immediately if the interval has already passed.
+		 */
+		if next.Before(now) {
+			next = now
+		}
+
+		this.lastRequest = next
+		this.mutexRequest.Unlock()
+		wait := next.Sub(now)
+		time.Sleep(wait)
+	}
+
+}
+
+/*
+ * Send a request for a tile to an OpenStreetMaps tile server.
+ *
+ * Returns the content of the tile, the entity tag provided by the server (if
+ * any), whether the tile was modified and whether the request may succeed if
+ * it is retried, which is the case for network errors and server errors.
+ */
+func (this *osmTileServerStruct) send(client *http.Client, req *http.Request, etag string) ([]byte, string, bool, bool, error) {
+	content := []byte(nil)
+	etagResult := ""
+	modified := false
+	retry := false
+	errResult := error(nil)
+	connections := this.connections
+	connections.Acquire()
+	this.throttle()
+	resp, err := client.Do(req)
+
+	/*
+	 * Check if we got a response.
+	 */
+	if err != nil {
+		msg := err.Error()
+		errResult = fmt.Errorf("Failed to fetch tile: %s", msg)
+		retry = true
+	} else {
+		body := resp.Body
+		statusCode := resp.StatusCode
+		header := resp.Header
+		etagResult = header.Get("ETag")
+
+		/*
+		 * Check status code of response.
+		 */
+		if statusCode == http.StatusNotModified {
+
+			/*
+			 * Keep entity tag if server did not repeat it.
+			 */
+			if etagResult == "" {
+				etagResult = etag
+			}
+
+		} else if statusCode == http.StatusTooManyRequests {
+			retryAfter := header.Get("Retry-After")
+
+			/*
+			 * Tell when to retry if the server told us.
+			 */
+			if retryAfter != "" {
+				errResult = fmt.Errorf("Server rejected request due to rate limiting (429 Too Many Requests). Retry after: %s. Consider increasing MapServerMinInterval.", retryAfter)
+			} else {
+				errResult = fmt.Errorf("%s", "Server rejected request due to rate limiting (429 Too Many Requests). Consider increasing MapServerMinInterval.")
+			}
+
+		} else if statusCode != http.StatusOK {
+			status := resp.Status
+			errResult = fmt.Errorf("Server responded with status '%s'.", status)
+			retry = statusCode >= http.StatusInternalServerError
+		} else {
+			buf, err := io.ReadAll(body)
+
+			/*
+			 * Check if image was loaded.
+			 */
+			if err != nil {
+				msg := err.Error()
+				errResult = fmt.Errorf("Failed to read tile: %s", msg)
+				retry = true
+			} else {
+				content = buf
+				modified = true
+			}
+
+		}
+
+		body.Close()
+	}
+
+	connections.Release()
+	return content, etagResult, modified, retry, errResult
+}
+
+/*
+ * Download a tile from an OpenStreetMaps tile server, given by a URI template.
+ *
+ * If modifiedSince is non-zero or etag is non-empty, the request is made
+ * conditional. Returns the content of the tile, the entity tag provided by the
+ * server (if any) and whether the tile was modified. If the server responds
+ * with "304 Not Modified", no content is returned.
+ */
+func (this *osmTileServerStruct) downloadFrom(templateUri string, id tile.Id, modifiedSince time.Time, etag string) ([]byte, string, bool, error) {
+	x := id.X()
+	y := id.Y()
+	z := id.Z()
+	subdomain := ""
+
+	/*
+	 * Only rotate subdomains if the template makes use of them.
+	 */
+	if strings.Contains(templateUri, TEMPLATE_SUBDOMAIN) {
+		subdomain = this.nextSubdomain()
+	}
+
+	pathUri := this.tilePath(templateUri, subdomain, z, x, y)
+	fmt.Printf("Fetching from URI: %s\n", pathUri)
+	client := &http.Client{}
+	req, err := http.NewRequest("GET", pathUri, nil)
+
+	/*
+	 * Check if we have a valid request.
+	 */
+	if err != nil {
+		msg := err.Error()
+		return nil, "", false, fmt.Errorf("Failed to create request: %s", msg)
+	} else {
+		userAgent := this.userAgent
+		req.Header.Set("User-Agent", userAgent)
+
+		/*
+		 * Ask server to only send tile if it was modified.
+		 */
+		if !modifiedSince.IsZero() {
+			modifiedSinceUtc := modifiedSince.UTC()
+			modifiedSinceString := modifiedSinceUtc.Format(http.TimeFormat)
+			req.Header.Set("If-Modified-Since", modifiedSinceString)
+		}
+
+		/*
+		 * Ask server to only send tile if entity tag does not match.
+		 */
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+
+		maxAttempts := this.maxAttempts
+		retryDelay := this.retryDelay
+		content := []byte(nil)
+		etagResult := ""
+		modified := false
+		retry := true
+		errResult := error(nil)
+		attempt := uint32(0)
+
+		/*
+		 * Send request until it succeeds, fails permanently or the maximum
+		 * number of attempts is reached.
+		 */
+		for (attempt < maxAttempts) && retry {
+
+			/*
+			 * Wait before retrying, doubling the delay with every attempt.
+			 */
+			if attempt > 0 {
+				delay := retryDelay << (attempt - 1)
+				msg := errResult.Error()
+				fmt.Printf("Retrying in %s: %s\n", delay, msg)
+				time.Sleep(delay)
+			}
+
+			content, etagResult, modified, retry, errResult = this.send(client, req, etag)
+			attempt++
+		}
+
+		/*
+		 * Report number of attempts if the request was retried.
+		 */
+		if (errResult != nil) && (attempt > 1) {
+			msg := errResult.Error()
+			errResult = fmt.Errorf("Failed after %d attempts: %s", attempt, msg)
+		}
+
+		return content, etagResult, modified, errResult
+	}
+
+}
+
+/*
+ * Download a tile from the OpenStreetMaps tile servers.
+ *
+ * The servers are tried in the order they were configured. If a server fails
+ * to provide the tile, the next one is tried. Returns the result of the first
+ * server which provided the tile, or the error of the last server if none of
+ * them did.
+ */
+func (this *osmTileServerStruct) download(id tile.Id, modifiedSince time.Time, etag string) ([]byte, string, bool, error) {
+	uris := this.uris
+	numUris := len(uris)
+	content := []byte(nil)
+	etagResult := ""
+	modified := false
+	errResult := fmt.Errorf("%s", "No map server configured.")
+
+	/*
+	 * Try each server until one provides the tile.
+	 */
+	for i := 0; (i < numUris) && (errResult != nil); i++ {
+		templateUri := uris[i]
+		content, etagResult, modified, errResult = this.downloadFrom(templateUri, id, modifiedSince, etag)
+	}
+
+	return content, etagResult, modified, errResult
+}
+
 /*
  * Obtain a tile from an OpenStreetMaps tile server.
+ *
+ * Fails if the tile could not be downloaded, so that no empty tile is cached.
  */
-func (this *osmTileServerStruct) getTile(id tile.Id) *bytes.Reader {
+func (this *osmTileServerStruct) getTile(id tile.Id) (*bytes.Reader, error) {
 	x := id.X()
 	y := id.Y()
 	z := id.Z()
@@ -97,52 +377,32 @@ func (this *osmTileServerStruct) getTile(id tile.Id) *bytes.Reader {
 		encoder.Encode(buf, img)
 		content := buf.Bytes()
 		r := bytes.NewReader(content)
-		return r
+		return r, nil
 	} else {
-		templateUri := this.uri
+		uris := this.uris
+		numUris := len(uris)
 		content := []byte{}
+		errResult := error(nil)
 
 		/*
-		 * Only download from OpenStreetMaps server if URI is not empty.
+		 * Only download from OpenStreetMaps server if one is configured.
 		 */
-		if templateUri != "" {
-			pathUri := this.tilePath(templateUri, z, x, y)
-			fmt.Printf("Fetching from URI: %s\n", pathUri)
-			client := &http.Client{}
-			req, err := http.NewRequest("GET", pathUri, nil)
+		if numUris > 0 {
+			buf, _, _, err := this.download(id, time.Time{}, "")
 
 			/*
-			 * Check if we have a valid request.
+			 * Check if image was loaded.
 			 */
-			if err == nil {
-				this.mutex.Lock()
-				req.Header.Set("User-Agent", "location-visualizer")
-				resp, err := client.Do(req)
-
-				/*
-				 * Check if we got a response and store it in cache.
-				 */
-				if err == nil {
-					body := resp.Body
-					buf, err := io.ReadAll(body)
-
-					/*
-					 * Check if image was loaded.
-					 */
-					if err == nil {
-						content = buf
-					}
-
-					body.Close()
-				}
-
-				this.mutex.Unlock()
+			if err != nil {
+				errResult = err
+			} else {
+				content = buf
 			}
 
 		}
 
 		r := bytes.NewReader(content)
-		return r
+		return r, errResult
 	}
 
 }
@@ -171,7 +431,71 @@ func (this *osmTileServerStruct) Get(z uint8, x uint32, y uint32) (tile.Image, e
 			return nil, err
 		} else {
 			tileId := tile.CreateId(z, x, y)
-			t := this.getTile(tileId)
+			t, err := this.getTile(tileId)
+
+			/*
+			 * Check if tile could be obtained.
+			 */
+			if err != nil {
+				return nil, err
+			} else {
+
+				/*
+				 * Provide "close" method.
+				 */
+				result := &readSeekerReaderAtWithNopCloserStruct{
+					t,
+					t,
+				}
+
+				return result, nil
+			}
+
+		}
+
+	}
+
+}
+
+/*
+ * Fetch a map tile from an OpenStreetMaps tile server, unless it was not
+ * modified since a certain point in time or still matches an entity tag.
+ *
+ * Returns the tile (nil if it was not modified), the entity tag provided by
+ * the server and whether the tile was modified. Unlike Get, this fails if the
+ * tile could not be downloaded, so that a cached copy can be kept instead.
+ */
+func (this *osmTileServerStruct) GetConditional(z uint8, x uint32, y uint32, modifiedSince time.Time, etag string) (tile.Image, string, bool, error) {
+	tilesPerAxis := uint32(1) << z
+	maxTileId := tilesPerAxis - 1
+	uris := this.uris
+	numUris := len(uris)
+
+	/*
+	 * Check if zoom level and tile IDs are in range.
+	 */
+	if z > MAX_ZOOM_LEVEL {
+		err := fmt.Errorf("Zoom level %d not allowed. (Maximum: %d)", z, MAX_ZOOM_LEVEL)
+		return nil, "", false, err
+	} else if (x > maxTileId) || (y > maxTileId) {
+		msg := "Cannot fetch tile (%d, %d). Maximum tile ID is (%d, %d) at zoom level %d."
+		err := fmt.Errorf(msg, x, y, maxTileId, maxTileId, z)
+		return nil, "", false, err
+	} else if numUris == 0 {
+		return nil, "", false, fmt.Errorf("%s", "No map server configured.")
+	} else {
+		tileId := tile.CreateId(z, x, y)
+		content, etagResponse, modified, err := this.download(tileId, modifiedSince, etag)
+
+		/*
+		 * Check if tile could be downloaded.
+		 */
+		if err != nil {
+			return nil, "", false, err
+		} else if !modified {
+			return nil, etagResponse, false, nil
+		} else {
+			t := bytes.NewReader(content)
 
 			/*
 			 * Provide "close" method.
@@ -181,7 +505,7 @@ func (this *osmTileServerStruct) Get(z uint8, x uint32, y uint32) (tile.Image, e
 				t,
 			}
 
-			return result, nil
+			return result, etagResponse, true, nil
 		}
 
 	}
@@ -189,15 +513,74 @@ func (this *osmTileServerStruct) Get(z uint8, x uint32, y uint32) (tile.Image, e
 }
 
 /*
- * Creates a connection to a remote tile server serving OpenStreetMaps data.
+ * Creates a connection to remote tile servers serving OpenStreetMaps data.
+ *
+ * The servers are given as URI templates and tried in order until one of them
+ * provides a tile. Empty templates are ignored. Occurrences of "${s}" are
+ * replaced by the subdomains, rotating through them with every request.
+ *
+ * At most the given number of tiles are downloaded concurrently, but at least
+ * one. Requests are sent with the given user agent, or a default one if it is
+ * empty, and at least the minimum interval apart.
+ *
+ * Requests failing due to network or server errors are sent up to the given
+ * number of attempts, but at least once and at most MAX_ATTEMPTS times. The
+ * delay before each retry starts at the retry delay and doubles with every
+ * attempt.
  */
-func CreateOSMTileServer(uri string) OSMTileServer {
+func CreateOSMTileServer(uris []string, subdomains []string, userAgent string, maxConnections uint32, minInterval time.Duration, maxAttempts uint32, retryDelay time.Duration) OSMTileServer {
+	templates := []string{}
+
+	/*
+	 * Ignore empty URI templates.
+	 */
+	for _, uri := range uris {
+
+		/*
+		 * Check if URI template is not empty.
+		 */
+		if uri != "" {
+			templates = append(templates, uri)
+		}
+
+	}
+
+	/*
+	 * Use default user agent if none is configured.
+	 */
+	if userAgent == "" {
+		userAgent = DEFAULT_USER_AGENT
+	}
+
+	/*
+	 * Allow at least one connection.
+	 */
+	if maxConnections < 1 {
+		maxConnections = 1
+	}
+
+	/*
+	 * Limit number of attempts to allowed range.
+	 */
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	} else if maxAttempts > MAX_ATTEMPTS {
+		maxAttempts = MAX_ATTEMPTS
+	}
+
+	connections := lsync.CreateSemaphore(maxConnections)
 
 	/*
 	 * Create remote OpenStreetMaps tile server.
 	 */
 	src := osmTileServerStruct{
-		uri: uri,
+		connections: connections,
+		maxAttempts: maxAttempts,
+		minInterval: minInterval,
+		retryDelay:  retryDelay,
+		subdomains:  subdomains,
+		uris:        templates,
+		userAgent:   userAgent,
 	}
 
 	return &src
@@ -5,10 +5,12 @@ import (
 	"compress/gzip"
 	"fmt"
 	"io"
+	"math"
 	"path"
 	"regexp"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/andrepxx/location-visualizer/tile"
@@ -17,12 +19,14 @@ import (
 )
 
 const (
-	REX_OSM_TILE_NAME = "^osm-(\\d*)-(\\d*)-(\\d*)\\.png$"
-	MAX_TILE_SIZE     = 1048576
-	MAX_ZOOM_LEVEL    = 19
-	MODE_DIR          = 0755
-	MODE_FILE         = 0644
-	SIZE_BUFFER       = 8096
+	REX_OSM_TILE_NAME          = "^osm-(\\d*)-(\\d*)-(\\d*)\\.png$"
+	MAX_LATITUDE               = 85.0511287798066
+	MAX_TILE_SIZE              = 1048576
+	MAX_ZOOM_LEVEL             = 19
+	MODE_DIR                   = 0755
+	MODE_FILE                  = 0644
+	PREFETCH_PROGRESS_INTERVAL = 10 * time.Second
+	SIZE_BUFFER                = 8096
 )
 
 /*
@@ -30,101 +34,350 @@ const (
  */
 type TileUtil interface {
 	Cleanup() error
+	Close() error
+	DatabaseStats() (DatabaseStats, error)
+	EvictOlderThan(cutoffMs int64) (uint64, error)
 	Export(w io.Writer, creationTime time.Time) error
 	Fetch(server tileserver.OSMTileServer, id tile.Id) (tile.Image, error)
 	Import(r io.Reader) error
 	Prefetch(server tileserver.OSMTileServer, maxZoom uint8)
+	PrefetchRegion(server tileserver.OSMTileServer, minZoom uint8, maxZoom uint8, south float64, west float64, north float64, east float64)
+	SetMaxAge(maxAge time.Duration)
+	SetPrefetchLimits(workers uint32, minDelay time.Duration)
+	Stats() Stats
+}
+
+/*
+ * Statistics on the contents of the tile database.
+ */
+type DatabaseStats interface {
+	ImageCount() uint64
+	ImageSize() uint64
+	TileCount() uint64
+}
+
+/*
+ * Data structure representing statistics on the contents of the tile
+ * database.
+ */
+type databaseStatsStruct struct {
+	imageCount uint64
+	imageSize  uint64
+	tileCount  uint64
+}
+
+/*
+ * The number of distinct images stored in the image database.
+ */
+func (this *databaseStatsStruct) ImageCount() uint64 {
+	return this.imageCount
+}
+
+/*
+ * The number of bytes occupied by the image database.
+ */
+func (this *databaseStatsStruct) ImageSize() uint64 {
+	return this.imageSize
+}
+
+/*
+ * The number of tiles stored in the index database.
+ */
+func (this *databaseStatsStruct) TileCount() uint64 {
+	return this.tileCount
+}
+
+/*
+ * Statistics on the usage of the tile cache.
+ */
+type Stats interface {
+	FetchErrors() uint64
+	Fetches() uint64
+	Hits() uint64
+	Misses() uint64
+}
+
+/*
+ * Data structure representing statistics on the usage of the tile cache.
+ *
+ * The counters are accessed atomically.
+ */
+type statsStruct struct {
+	fetchErrors uint64
+	fetches     uint64
+	hits        uint64
+	misses      uint64
+}
+
+/*
+ * The number of tiles which could not be fetched from the server.
+ */
+func (this *statsStruct) FetchErrors() uint64 {
+	return this.fetchErrors
+}
+
+/*
+ * The number of tiles fetched or revalidated from the server.
+ */
+func (this *statsStruct) Fetches() uint64 {
+	return this.fetches
+}
+
+/*
+ * The number of tiles found in the cache.
+ */
+func (this *statsStruct) Hits() uint64 {
+	return this.hits
+}
+
+/*
+ * The number of tiles not found in the cache.
+ */
+func (this *statsStruct) Misses() uint64 {
+	return this.misses
 }
 
 /*
  * Data structure representing the utility.
+ *
+ * The statistics come first, so that they are aligned for atomic access.
  */
 type tileUtilStruct struct {
-	mutex         sync.RWMutex
-	imageDatabase tiledb.ImageDatabase
-	indexDatabase tiledb.IndexDatabase
+	stats           statsStruct
+	mutex           sync.RWMutex
+	etags           map[tile.Id]string
+	imageDatabase   tiledb.ImageDatabase
+	indexDatabase   tiledb.IndexDatabase
+	maxAge          time.Duration
+	prefetchDelay   time.Duration
+	prefetchWorkers uint32
 }
 
 /*
- * Remove all images from ImageDatabase that are no longer referenced from IndexDatabase.
+ * Removes all images, which are not referenced by the index database, from
+ * the image database.
+ *
+ * This assumes that the databases are locked for writing.
  */
-func (this *tileUtilStruct) Cleanup() error {
+func (this *tileUtilStruct) cleanupImages() error {
 	errResult := error(nil)
-	this.mutex.Lock()
 	imgdb := this.imageDatabase
 	idxdb := this.indexDatabase
-	err := idxdb.Sort()
+	numEntries, err := idxdb.Length()
 
 	/*
-	 * Check if index database got sorted.
+	 * Check if we could get the number of entries from the index database.
 	 */
 	if err != nil {
 		msg := err.Error()
-		return fmt.Errorf("Failed sort index database: %s", msg)
+		errResult = fmt.Errorf("Failed to get number of entries from index database: %s", msg)
 	} else {
-		numEntries, err := idxdb.Length()
+		allHandles := make(map[tiledb.ImageHandle]bool)
 
 		/*
-		 * Check if we could get the number of entries from the index database.
+		 * Iterate over all entries in index database and collect all handles.
 		 */
-		if err != nil {
-			msg := err.Error()
-			return fmt.Errorf("Failed to get number of entries from index database: %s", msg)
-		} else {
-			allHandles := make(map[tiledb.ImageHandle]bool)
+		for idx := uint64(0); (idx < numEntries) && (errResult == nil); idx++ {
+			_, metadata, err := idxdb.Entry(idx)
 
 			/*
-			 * Iterate over all entries in index database and collect all handles.
+			 * Check if error occured retrieving entry from index database.
 			 */
-			for idx := uint64(0); (idx < numEntries) && (errResult == nil); idx++ {
-				_, metadata, err := idxdb.Entry(idx)
+			if err != nil {
+				msg := err.Error()
+				errResult = fmt.Errorf("Failed to get entry %d from index database: %s", idx, msg)
+			} else {
+				handle := metadata.Handle()
+				allHandles[handle] = true
+			}
 
-				/*
-				 * Check if error occured retrieving entry from index database.
-				 */
-				if err != nil {
-					msg := err.Error()
-					errResult = fmt.Errorf("Failed to get entry %d from index database: %s", idx, msg)
-				} else {
-					handle := metadata.Handle()
-					allHandles[handle] = true
-				}
+		}
 
+		/*
+		 * If no error occured so far, continue to cleanup image database.
+		 */
+		if errResult == nil {
+
+			/*
+			 * The cleanup condition.
+			 */
+			condition := func(handle tiledb.ImageHandle) bool {
+				result := allHandles[handle]
+				return result
 			}
 
+			err := imgdb.Cleanup(condition)
+
 			/*
-			 * If no error occured so far, continue to cleanup image database.
+			 * Check if error occured during cleanup.
 			 */
-			if errResult == nil {
+			if err != nil {
+				msg := err.Error()
+				errResult = fmt.Errorf("Error occured during image database cleanup: %s", msg)
+			}
 
-				/*
-				 * The cleanup condition.
-				 */
-				condition := func(handle tiledb.ImageHandle) bool {
-					result := allHandles[handle]
-					return result
-				}
+		}
 
-				err := imgdb.Cleanup(condition)
+	}
 
-				/*
-				 * Check if error occured during cleanup.
-				 */
-				if err != nil {
-					msg := err.Error()
-					errResult = fmt.Errorf("Error occured during image database cleanup: %s", msg)
-				}
+	return errResult
+}
 
-			}
+/*
+ * Remove all images from ImageDatabase that are no longer referenced from IndexDatabase.
+ *
+ * Entries of IndexDatabase, which are no longer referenced, are removed first.
+ */
+func (this *tileUtilStruct) Cleanup() error {
+	errResult := error(nil)
+	this.mutex.Lock()
+	idxdb := this.indexDatabase
+	errCompact := idxdb.Compact()
+	errSort := error(nil)
 
-		}
+	/*
+	 * Only sort index database if it could be compacted.
+	 */
+	if errCompact == nil {
+		errSort = idxdb.Sort()
+	}
 
+	/*
+	 * Check if index database got compacted and sorted.
+	 */
+	if errCompact != nil {
+		msg := errCompact.Error()
+		errResult = fmt.Errorf("Failed to compact index database: %s", msg)
+	} else if errSort != nil {
+		msg := errSort.Error()
+		errResult = fmt.Errorf("Failed sort index database: %s", msg)
+	} else {
+		errResult = this.cleanupImages()
 	}
 
 	this.mutex.Unlock()
 	return errResult
 }
 
+/*
+ * Closes the index and image databases.
+ *
+ * Since tiles are stored while holding the lock, this waits for tiles which
+ * are currently being stored, so that no tile is only partially stored when
+ * the databases are closed.
+ */
+func (this *tileUtilStruct) Close() error {
+	this.mutex.Lock()
+	idxdb := this.indexDatabase
+	imgdb := this.imageDatabase
+	errIndex := idxdb.Close()
+	errImage := imgdb.Close()
+	this.mutex.Unlock()
+
+	/*
+	 * Check if databases could be closed.
+	 */
+	if errIndex != nil {
+		msg := errIndex.Error()
+		return fmt.Errorf("Failed to close index database: %s", msg)
+	} else if errImage != nil {
+		msg := errImage.Error()
+		return fmt.Errorf("Failed to close image database: %s", msg)
+	} else {
+		return nil
+	}
+
+}
+
+/*
+ * Returns statistics on the contents of the tile database.
+ *
+ * Since multiple tiles may share the same image, the number of images may be
+ * smaller than the number of tiles.
+ */
+func (this *tileUtilStruct) DatabaseStats() (DatabaseStats, error) {
+	this.mutex.RLock()
+	idxdb := this.indexDatabase
+	imgdb := this.imageDatabase
+	tileCount, errIndex := idxdb.Length()
+	imageCount, errCount := imgdb.Count()
+	imageSize, errSize := imgdb.Size()
+	this.mutex.RUnlock()
+
+	/*
+	 * Check if statistics could be obtained.
+	 */
+	if errIndex != nil {
+		msg := errIndex.Error()
+		return nil, fmt.Errorf("Failed to obtain length of index database: %s", msg)
+	} else if errCount != nil {
+		msg := errCount.Error()
+		return nil, fmt.Errorf("Failed to obtain number of images: %s", msg)
+	} else if errSize != nil {
+		msg := errSize.Error()
+		return nil, fmt.Errorf("Failed to obtain size of image database: %s", msg)
+	} else {
+
+		/*
+		 * Create database statistics.
+		 */
+		result := databaseStatsStruct{
+			imageCount: imageCount,
+			imageSize:  imageSize,
+			tileCount:  tileCount,
+		}
+
+		return &result, nil
+	}
+
+}
+
+/*
+ * Evict all tiles cached before a point in time, given in milliseconds since
+ * the epoch, from the tile database.
+ *
+ * Images which are no longer referenced afterwards are removed from the image
+ * database. Returns the number of tiles evicted.
+ */
+func (this *tileUtilStruct) EvictOlderThan(cutoffMs int64) (uint64, error) {
+	this.mutex.Lock()
+	etags := this.etags
+	idxdb := this.indexDatabase
+
+	/*
+	 * Keep tiles cached at or after the cutoff.
+	 */
+	keep := func(id tile.Id, metadata tiledb.TileMetadata) bool {
+		timestamp := metadata.TimestampMs()
+		result := timestamp >= cutoffMs
+
+		/*
+		 * Forget entity tag of evicted tile.
+		 */
+		if !result {
+			delete(etags, id)
+		}
+
+		return result
+	}
+
+	numEvicted, err := idxdb.Cleanup(keep)
+	errResult := error(nil)
+
+	/*
+	 * Check if tiles could be evicted from index database.
+	 */
+	if err != nil {
+		msg := err.Error()
+		errResult = fmt.Errorf("Failed to evict tiles from index database: %s", msg)
+	} else {
+		errResult = this.cleanupImages()
+	}
+
+	this.mutex.Unlock()
+	return numEvicted, errResult
+}
+
 /*
  * Export a single entry from index database into a tarball.
  */
@@ -214,6 +467,11 @@ func (this *tileUtilStruct) exportEntry(w *tar.Writer, idx uint64, tilesPath str
 
 			}
 
+			/*
+			 * Release the image, since it holds a lock on the image
+			 * database.
+			 */
+			img.Close()
 		}
 
 	}
@@ -323,8 +581,9 @@ func (this *tileUtilStruct) Export(w io.Writer, creationTime time.Time) error {
  *
  * This assumes that the databases are locked for either reading or writing.
  */
-func (this *tileUtilStruct) fetchFromCache(id tile.Id) (tile.Image, error) {
+func (this *tileUtilStruct) fetchFromCache(id tile.Id) (tile.Image, tiledb.TileMetadata, error) {
 	result := tile.Image(nil)
+	resultMetadata := tiledb.TileMetadata{}
 	errResult := error(nil)
 	idxdb := this.indexDatabase
 	idx, found := idxdb.Search(id)
@@ -364,32 +623,58 @@ func (this *tileUtilStruct) fetchFromCache(id tile.Id) (tile.Image, error) {
 				errResult = fmt.Errorf("Failed to open image: %s", msg)
 			} else {
 				result = img
+				resultMetadata = metadata
 			}
 
 		}
 
 	}
 
-	return result, errResult
+	return result, resultMetadata, errResult
 }
 
 /*
- * Fetch tile from server.
+ * Fetch tile from server and store it in the cache.
  *
- * This assumes that the databases are locked for writing.
+ * If server is nil, tiles are served from the cache only and every cache miss
+ * results in an error.
+ *
+ * The tile is downloaded without holding a lock, so that multiple tiles can
+ * be downloaded concurrently. If the tile was stored in the cache in the
+ * meantime, the cached tile is returned instead, unless forceUpdate is set.
+ *
+ * This assumes that the databases are not locked.
  */
-func (this *tileUtilStruct) fetchFromServer(server tileserver.OSMTileServer, id tile.Id) (tile.Image, error) {
+func (this *tileUtilStruct) fetchFromServer(server tileserver.OSMTileServer, id tile.Id, forceUpdate bool) (tile.Image, error) {
+
+	/*
+	 * Without a server, tiles cannot be fetched.
+	 */
+	if server == nil {
+		return nil, fmt.Errorf("%s", "Tile is not cached and no map server is available.")
+	}
+
 	z := id.Z()
 	x := id.X()
 	y := id.Y()
 	result, errResult := server.Get(z, x, y)
+	atomic.AddUint64(&this.stats.fetches, 1)
 
 	/*
 	 * If we could fetch tile from server, store it in cache.
 	 */
-	if errResult == nil {
+	if errResult != nil {
+		atomic.AddUint64(&this.stats.fetchErrors, 1)
+	} else {
 		content, err := io.ReadAll(result)
 
+		/*
+		 * Rewind tile, so that callers can read it again.
+		 */
+		if err == nil {
+			_, err = result.Seek(0, io.SeekStart)
+		}
+
 		/*
 		 * Check if tile content could be read.
 		 */
@@ -397,28 +682,209 @@ func (this *tileUtilStruct) fetchFromServer(server tileserver.OSMTileServer, id
 			msg := err.Error()
 			errResult = fmt.Errorf("Failed to read tile content: %s", msg)
 		} else {
-			imgdb := this.imageDatabase
-			handle, err := imgdb.Insert(content)
+			this.mutex.Lock()
+			cached := tile.Image(nil)
+			isCached := false
 
 			/*
-			 * Check if tile was inserted into image database.
+			 * Check if tile was cached in the meantime, unless we shall
+			 * perform a forced update.
+			 */
+			if !forceUpdate {
+				img, _, err := this.fetchFromCache(id)
+				cached = img
+				isCached = err == nil
+			}
+
+			/*
+			 * Only store tile if it was not cached in the meantime.
+			 */
+			if isCached {
+				result.Close()
+				result = cached
+			} else {
+				imgdb := this.imageDatabase
+				handle, err := imgdb.Insert(content)
+
+				/*
+				 * Check if tile was inserted into image database.
+				 */
+				if err != nil {
+					msg := err.Error()
+					errResult = fmt.Errorf("Failed to insert tile into image database: %s", msg)
+				} else {
+					t := time.Now()
+					timestamp := t.UnixMilli()
+					metadata := tiledb.CreateTileMetadata(timestamp, handle)
+					idxdb := this.indexDatabase
+					err := idxdb.Insert(id, metadata)
+
+					/*
+					 * Check if tile was inserted into index database.
+					 */
+					if err != nil {
+						msg := err.Error()
+						errResult = fmt.Errorf("Failed to insert tile into index database: %s", msg)
+					}
+
+				}
+
+			}
+
+			this.mutex.Unlock()
+		}
+
+	}
+
+	return result, errResult
+}
+
+/*
+ * Checks whether a tile is cached and does not need to be revalidated with
+ * the server.
+ */
+func (this *tileUtilStruct) isCached(id tile.Id) bool {
+	this.mutex.RLock()
+	img, metadata, err := this.fetchFromCache(id)
+	result := false
+
+	/*
+	 * Check if tile was found in cache.
+	 */
+	if err == nil {
+		img.Close()
+		stale := this.isStale(metadata)
+		result = !stale
+	}
+
+	this.mutex.RUnlock()
+	return result
+}
+
+/*
+ * Check whether a cached tile is older than the maximum age.
+ *
+ * This assumes that the databases are locked for either reading or writing.
+ */
+func (this *tileUtilStruct) isStale(metadata tiledb.TileMetadata) bool {
+	maxAge := this.maxAge
+
+	/*
+	 * Tiles never expire if no maximum age is set.
+	 */
+	if maxAge <= 0 {
+		return false
+	} else {
+		timestamp := metadata.TimestampMs()
+		modTime := time.UnixMilli(timestamp)
+		age := time.Since(modTime)
+		result := age > maxAge
+		return result
+	}
+
+}
+
+/*
+ * Revalidate a cached tile with the server.
+ *
+ * The server is asked to only send the tile if it was modified since it was
+ * cached or if its entity tag changed. If it was not modified, only the
+ * timestamp of the cached tile is updated. If the server cannot be reached,
+ * the cached tile is kept.
+ *
+ * This assumes that the databases are locked for writing.
+ */
+func (this *tileUtilStruct) revalidate(server tileserver.OSMTileServer, id tile.Id, cached tile.Image, metadata tiledb.TileMetadata) (tile.Image, error) {
+	z := id.Z()
+	x := id.X()
+	y := id.Y()
+	timestamp := metadata.TimestampMs()
+	modTime := time.UnixMilli(timestamp)
+	etags := this.etags
+	etag := etags[id]
+	img, etagNew, modified, err := server.GetConditional(z, x, y, modTime, etag)
+	atomic.AddUint64(&this.stats.fetches, 1)
+
+	/*
+	 * Check if tile could be revalidated.
+	 */
+	if err != nil {
+		atomic.AddUint64(&this.stats.fetchErrors, 1)
+		msg := err.Error()
+		fmt.Printf("Failed to revalidate tile (%d, %d, %d), keeping cached copy: %s\n", x, y, z, msg)
+		return cached, nil
+	} else {
+
+		/*
+		 * Remember entity tag for next revalidation.
+		 */
+		if etagNew != "" {
+			etags[id] = etagNew
+		}
+
+		t := time.Now()
+		timestampNow := t.UnixMilli()
+		idxdb := this.indexDatabase
+
+		/*
+		 * If tile was not modified, only update its timestamp.
+		 */
+		if !modified {
+			handle := metadata.Handle()
+			metadataNew := tiledb.CreateTileMetadata(timestampNow, handle)
+			err := idxdb.Insert(id, metadataNew)
+
+			/*
+			 * Check if tile was updated in index database.
 			 */
 			if err != nil {
+				cached.Close()
 				msg := err.Error()
-				errResult = fmt.Errorf("Failed to insert tile into image database: %s", msg)
+				return nil, fmt.Errorf("Failed to update tile in index database: %s", msg)
 			} else {
-				t := time.Now()
-				timestamp := t.UnixMilli()
-				metadata := tiledb.CreateTileMetadata(timestamp, handle)
-				idxdb := this.indexDatabase
-				err := idxdb.Insert(id, metadata)
+				return cached, nil
+			}
+
+		} else {
+
+			/*
+			 * The cached image keeps the image database locked, so close it
+			 * before inserting the new one.
+			 */
+			cached.Close()
+			content, err := io.ReadAll(img)
+
+			/*
+			 * Check if tile content could be read.
+			 */
+			if err != nil {
+				msg := err.Error()
+				return nil, fmt.Errorf("Failed to read tile content: %s", msg)
+			} else {
+				imgdb := this.imageDatabase
+				handle, err := imgdb.Insert(content)
 
 				/*
-				 * Check if tile was inserted into index database.
+				 * Check if tile was inserted into image database.
 				 */
 				if err != nil {
 					msg := err.Error()
-					errResult = fmt.Errorf("Failed to insert tile into index database: %s", msg)
+					return nil, fmt.Errorf("Failed to insert tile into image database: %s", msg)
+				} else {
+					metadataNew := tiledb.CreateTileMetadata(timestampNow, handle)
+					err := idxdb.Insert(id, metadataNew)
+
+					/*
+					 * Check if tile was inserted into index database.
+					 */
+					if err != nil {
+						msg := err.Error()
+						return nil, fmt.Errorf("Failed to insert tile into index database: %s", msg)
+					} else {
+						img.Seek(0, io.SeekStart)
+						return img, nil
+					}
+
 				}
 
 			}
@@ -427,11 +893,12 @@ func (this *tileUtilStruct) fetchFromServer(server tileserver.OSMTileServer, id
 
 	}
 
-	return result, errResult
 }
 
 /*
  * Lookup tile in cache or fetch it from server and store it in cache.
+ *
+ * If server is nil, tiles are only looked up in cache and never revalidated.
  */
 func (this *tileUtilStruct) fetch(server tileserver.OSMTileServer, id tile.Id, forceUpdate bool) (tile.Image, error) {
 	result := tile.Image(nil)
@@ -441,27 +908,52 @@ func (this *tileUtilStruct) fetch(server tileserver.OSMTileServer, id tile.Id, f
 	 * Check if we shall perform a forced update.
 	 */
 	if forceUpdate {
-		result, errResult = this.fetchFromServer(server, id)
+		result, errResult = this.fetchFromServer(server, id, true)
 	} else {
 		this.mutex.RLock()
-		result, errResult = this.fetchFromCache(id)
+		metadata := tiledb.TileMetadata{}
+		result, metadata, errResult = this.fetchFromCache(id)
+		hasServer := server != nil
+		stale := (errResult == nil) && hasServer && this.isStale(metadata)
 		this.mutex.RUnlock()
 
+		/*
+		 * Count cache hits and misses.
+		 */
+		if errResult != nil {
+			atomic.AddUint64(&this.stats.misses, 1)
+		} else {
+			atomic.AddUint64(&this.stats.hits, 1)
+		}
+
 		/*
 		 * If tile could not be loaded from cache, fetch it from server.
+		 * If it is too old, revalidate it with the server.
 		 */
 		if errResult != nil {
+			result, errResult = this.fetchFromServer(server, id, false)
+		} else if stale {
+			result.Close()
 			this.mutex.Lock()
-			result, errResult = this.fetchFromCache(id)
+			result, metadata, errResult = this.fetchFromCache(id)
+			missing := errResult != nil
 
 			/*
-			 * Verify that we still have a cache miss, since we re-acquired the lock.
+			 * Verify that tile is still too old, since we re-acquired the lock.
 			 */
-			if errResult != nil {
-				result, errResult = this.fetchFromServer(server, id)
+			if !missing && this.isStale(metadata) {
+				result, errResult = this.revalidate(server, id, result, metadata)
 			}
 
 			this.mutex.Unlock()
+
+			/*
+			 * Fetch tile from server if it vanished from the cache.
+			 */
+			if missing {
+				result, errResult = this.fetchFromServer(server, id, false)
+			}
+
 		}
 
 	}
@@ -603,29 +1095,341 @@ func (this *tileUtilStruct) Prefetch(server tileserver.OSMTileServer, zoomLevel
 		zoomLevel = MAX_ZOOM_LEVEL
 	}
 
+	total := uint64(0)
+
 	/*
-	 * Fetch tiles for every zoom level.
+	 * Count tiles for every zoom level.
 	 */
 	for z := uint8(0); z <= zoomLevel; z++ {
-		tilesPerAxis := uint32(1) << z
+		tilesPerAxis := uint64(1) << z
+		total += tilesPerAxis * tilesPerAxis
+	}
+
+	/*
+	 * Enumerate tiles up to the zoom level.
+	 */
+	enumerate := func(ids chan<- tile.Id) {
 
 		/*
-		 * Fetch every row of tiles.
+		 * Enumerate tiles for every zoom level.
 		 */
-		for y := uint32(0); y < tilesPerAxis; y++ {
+		for z := uint8(0); z <= zoomLevel; z++ {
+			tilesPerAxis := uint32(1) << z
 
 			/*
-			 * Fetch every tile in the row.
+			 * Enumerate every row of tiles.
 			 */
-			for x := uint32(0); x < tilesPerAxis; x++ {
-				id := tile.CreateId(z, x, y)
-				this.fetch(server, id, false)
+			for y := uint32(0); y < tilesPerAxis; y++ {
+
+				/*
+				 * Enumerate every tile in the row.
+				 */
+				for x := uint32(0); x < tilesPerAxis; x++ {
+					id := tile.CreateId(z, x, y)
+					ids <- id
+				}
+
 			}
 
 		}
 
 	}
 
+	this.prefetchTiles(server, total, enumerate)
+}
+
+/*
+ * Prefetch tiles covering a bounding box from server for a range of zoom
+ * levels, both inclusive.
+ *
+ * The bounding box is given in degrees and must not cross the antimeridian.
+ * Tiles which are already cached are not fetched again, unless they are stale.
+ */
+func (this *tileUtilStruct) PrefetchRegion(server tileserver.OSMTileServer, minZoom uint8, maxZoom uint8, south float64, west float64, north float64, east float64) {
+
+	/*
+	 * Limit zoom level to allowed maximum.
+	 */
+	if maxZoom > MAX_ZOOM_LEVEL {
+		maxZoom = MAX_ZOOM_LEVEL
+	}
+
+	total := uint64(0)
+
+	/*
+	 * Count tiles for every zoom level.
+	 */
+	for z := minZoom; z <= maxZoom; z++ {
+		total += RegionTileCount(z, south, west, north, east)
+	}
+
+	/*
+	 * Enumerate tiles within the bounding box.
+	 */
+	enumerate := func(ids chan<- tile.Id) {
+
+		/*
+		 * Enumerate tiles for every zoom level.
+		 */
+		for z := minZoom; z <= maxZoom; z++ {
+			minX, maxX, minY, maxY := RegionBounds(z, south, west, north, east)
+
+			/*
+			 * Enumerate every row of tiles within the bounding box.
+			 */
+			for y := minY; y <= maxY; y++ {
+
+				/*
+				 * Enumerate every tile in the row within the bounding box.
+				 */
+				for x := minX; x <= maxX; x++ {
+					id := tile.CreateId(z, x, y)
+					ids <- id
+				}
+
+			}
+
+		}
+
+	}
+
+	this.prefetchTiles(server, total, enumerate)
+}
+
+/*
+ * Prefetch tiles from server using a pool of workers.
+ *
+ * The enumeration function writes the IDs of the tiles to fetch into the
+ * channel passed to it. Each worker waits for at least the pre-fetch delay
+ * between two requests to the server. Tiles which are already cached are
+ * skipped without delay. The progress is printed periodically.
+ */
+func (this *tileUtilStruct) prefetchTiles(server tileserver.OSMTileServer, total uint64, enumerate func(ids chan<- tile.Id)) {
+	this.mutex.RLock()
+	numWorkers := this.prefetchWorkers
+	minDelay := this.prefetchDelay
+	this.mutex.RUnlock()
+
+	/*
+	 * Use at least one worker.
+	 */
+	if numWorkers < 1 {
+		numWorkers = 1
+	}
+
+	ids := make(chan tile.Id, numWorkers)
+	finished := make(chan struct{})
+	done := uint64(0)
+	wg := sync.WaitGroup{}
+
+	/*
+	 * Enumerate tiles in the background.
+	 */
+	go func() {
+		enumerate(ids)
+		close(ids)
+	}()
+
+	/*
+	 * Start workers.
+	 */
+	for i := uint32(0); i < numWorkers; i++ {
+		wg.Add(1)
+
+		/*
+		 * Fetch tiles until there are none left.
+		 */
+		go func() {
+			lastRequest := time.Time{}
+
+			/*
+			 * Fetch each tile.
+			 */
+			for id := range ids {
+				cached := this.isCached(id)
+
+				/*
+				 * Wait before sending another request to the server.
+				 */
+				if !cached {
+					elapsed := time.Since(lastRequest)
+
+					/*
+					 * Check if the minimum delay has not passed yet.
+					 */
+					if elapsed < minDelay {
+						remaining := minDelay - elapsed
+						time.Sleep(remaining)
+					}
+
+					lastRequest = time.Now()
+				}
+
+				img, err := this.fetch(server, id, false)
+
+				/*
+				 * Release the image, since it holds a lock on
+				 * the image database.
+				 */
+				if err == nil {
+					img.Close()
+				}
+
+				atomic.AddUint64(&done, 1)
+			}
+
+			wg.Done()
+		}()
+
+	}
+
+	/*
+	 * Signal when all workers are finished.
+	 */
+	go func() {
+		wg.Wait()
+		close(finished)
+	}()
+
+	ticker := time.NewTicker(PREFETCH_PROGRESS_INTERVAL)
+	running := true
+
+	/*
+	 * Print progress until all workers are finished.
+	 */
+	for running {
+
+		/*
+		 * Wait for next progress report or completion.
+		 */
+		select {
+		case <-ticker.C:
+			numDone := atomic.LoadUint64(&done)
+			fmt.Printf("Pre-fetched %d of %d tiles.\n", numDone, total)
+		case <-finished:
+			running = false
+		}
+
+	}
+
+	ticker.Stop()
+	numDone := atomic.LoadUint64(&done)
+	fmt.Printf("Pre-fetched %d of %d tiles.\n", numDone, total)
+}
+
+/*
+ * Set the maximum age of cached tiles.
+ *
+ * Tiles older than this are revalidated with the server when they are
+ * fetched. A maximum age of zero means that cached tiles never expire.
+ */
+func (this *tileUtilStruct) SetMaxAge(maxAge time.Duration) {
+	this.mutex.Lock()
+	this.maxAge = maxAge
+	this.mutex.Unlock()
+}
+
+/*
+ * Set the number of workers used to pre-fetch tiles and the minimum delay
+ * between two requests of each worker.
+ */
+func (this *tileUtilStruct) SetPrefetchLimits(workers uint32, minDelay time.Duration) {
+	this.mutex.Lock()
+	this.prefetchWorkers = workers
+	this.prefetchDelay = minDelay
+	this.mutex.Unlock()
+}
+
+/*
+ * Returns statistics on the usage of the tile cache since the util was
+ * created.
+ */
+func (this *tileUtilStruct) Stats() Stats {
+	stats := &this.stats
+	fetchErrors := atomic.LoadUint64(&stats.fetchErrors)
+	fetches := atomic.LoadUint64(&stats.fetches)
+	hits := atomic.LoadUint64(&stats.hits)
+	misses := atomic.LoadUint64(&stats.misses)
+
+	/*
+	 * Create a snapshot of the statistics.
+	 */
+	result := statsStruct{
+		fetchErrors: fetchErrors,
+		fetches:     fetches,
+		hits:        hits,
+		misses:      misses,
+	}
+
+	return &result
+}
+
+/*
+ * Limits a tile coordinate to the tiles available at a zoom level.
+ */
+func clampTileCoordinate(value float64, tilesPerAxis float64) uint32 {
+	value = math.Floor(value)
+	maxValue := tilesPerAxis - 1.0
+
+	/*
+	 * Limit value to valid range.
+	 */
+	if value < 0.0 {
+		value = 0.0
+	} else if value > maxValue {
+		value = maxValue
+	}
+
+	result := uint32(value)
+	return result
+}
+
+/*
+ * Computes the range of tiles covering a bounding box at a zoom level.
+ *
+ * The bounding box is given in degrees. Latitudes beyond the range covered by
+ * the Web Mercator projection are limited to that range.
+ *
+ * Returns the minimum and maximum X and Y coordinates of the tiles, both
+ * inclusive.
+ */
+func RegionBounds(zoomLevel uint8, south float64, west float64, north float64, east float64) (uint32, uint32, uint32, uint32) {
+	tilesPerAxis64 := uint64(1) << zoomLevel
+	tilesPerAxis := float64(tilesPerAxis64)
+	southLimited := math.Max(south, -MAX_LATITUDE)
+	northLimited := math.Min(north, MAX_LATITUDE)
+	southRad := southLimited * (math.Pi / 180.0)
+	northRad := northLimited * (math.Pi / 180.0)
+	southTan := math.Tan(southRad)
+	northTan := math.Tan(northRad)
+	southMercator := math.Asinh(southTan)
+	northMercator := math.Asinh(northTan)
+	westX := ((west + 180.0) / 360.0) * tilesPerAxis
+	eastX := ((east + 180.0) / 360.0) * tilesPerAxis
+	northY := (0.5 - (northMercator / (2.0 * math.Pi))) * tilesPerAxis
+	southY := (0.5 - (southMercator / (2.0 * math.Pi))) * tilesPerAxis
+	minX := clampTileCoordinate(westX, tilesPerAxis)
+	maxX := clampTileCoordinate(eastX, tilesPerAxis)
+	minY := clampTileCoordinate(northY, tilesPerAxis)
+	maxY := clampTileCoordinate(southY, tilesPerAxis)
+	return minX, maxX, minY, maxY
+}
+
+/*
+ * Computes the number of tiles covering a bounding box at a zoom level.
+ *
+ * The bounding box is given in degrees.
+ */
+func RegionTileCount(zoomLevel uint8, south float64, west float64, north float64, east float64) uint64 {
+	minX, maxX, minY, maxY := RegionBounds(zoomLevel, south, west, north, east)
+	minX64 := uint64(minX)
+	maxX64 := uint64(maxX)
+	minY64 := uint64(minY)
+	maxY64 := uint64(maxY)
+	numX := (maxX64 - minX64) + 1
+	numY := (maxY64 - minY64) + 1
+	result := numX * numY
+	return result
 }
 
 /*
@@ -637,6 +1441,7 @@ func CreateTileUtil(idxdb tiledb.IndexDatabase, imgdb tiledb.ImageDatabase) Tile
 	 * Create util.
 	 */
 	util := tileUtilStruct{
+		etags:         map[tile.Id]string{},
 		imageDatabase: imgdb,
 		indexDatabase: idxdb,
 	}
@@ -1404,7 +1404,79 @@ function UI() {
 		const importArea = document.createElement('textarea');
 		importArea.className = 'textarea';
 		innerDiv.appendChild(importArea);
+		const previewDiv = document.createElement('div');
+		innerDiv.appendChild(previewDiv);
 		const buttonsDiv = document.createElement('div');
+		const buttonPreview = document.createElement('button');
+		buttonPreview.className = 'button';
+		const buttonPreviewCaption = document.createTextNode('Preview');
+		buttonPreview.appendChild(buttonPreviewCaption);
+
+		/*
+		 * This is called when the user clicks on the 'Preview' button.
+		 */
+		buttonPreview.onclick = function(e) {
+			const cgi = globals.cgi;
+			const request = new Request();
+			request.append('cgi', 'import-activity-csv');
+			request.append('preview', 'true');
+			const importData = importArea.value;
+			request.append('data', importData);
+			const cvs = document.getElementById('map_canvas');
+			const token = storage.get(cvs, 'token');
+			request.append('token', token);
+			const data = request.getData();
+			const mime = globals.mimeDefault;
+
+			/*
+			 * This is called when the server returns a response.
+			 */
+			const callback = function(content) {
+				const response = helper.parseJSON(content);
+				helper.clearElement(previewDiv);
+
+				/*
+				 * Check if preview was successful.
+				 */
+				if (response.Success !== true) {
+					const reason = response.Reason;
+					const reasonNode = document.createTextNode(reason);
+					previewDiv.appendChild(reasonNode);
+				} else {
+					const summaryDiv = document.createElement('div');
+					const summary = response.New + ' new, ' + response.Collisions + ' colliding, ' + response.Malformed + ' malformed';
+					const summaryNode = document.createTextNode(summary);
+					summaryDiv.appendChild(summaryNode);
+					previewDiv.appendChild(summaryDiv);
+					const rows = response.Rows;
+
+					/*
+					 * List all rows which would not be imported cleanly.
+					 */
+					for (let i = 0; i < rows.length; i++) {
+						const row = rows[i];
+
+						/*
+						 * Only list colliding and malformed rows.
+						 */
+						if (row.Status !== 'new') {
+							const rowDiv = document.createElement('div');
+							const rowText = 'Row ' + row.Row + ' (' + row.Status + '): ' + row.Reason;
+							const rowNode = document.createTextNode(rowText);
+							rowDiv.appendChild(rowNode);
+							previewDiv.appendChild(rowDiv);
+						}
+
+					}
+
+				}
+
+			};
+
+			ajax.request('POST', cgi, data, mime, callback, false);
+		};
+
+		buttonsDiv.appendChild(buttonPreview);
 		const buttonImport = document.createElement('button');
 		buttonImport.className = 'button';
 		const buttonImportCaption = document.createTextNode('Import');
@@ -1712,6 +1784,45 @@ function UI() {
 
 			table.appendChild(body);
 			tableDiv.appendChild(table);
+			const compressed = response.Compressed;
+
+			/*
+			 * Report if file was decompressed before import.
+			 */
+			if (compressed) {
+				const compressedDiv = document.createElement('div');
+				const compressedNode = document.createTextNode('File was gzip-compressed and has been decompressed.');
+				compressedDiv.appendChild(compressedNode);
+				tableDiv.appendChild(compressedDiv);
+			}
+
+			const formatDetected = response.FormatDetected;
+
+			/*
+			 * Report format if it was detected automatically.
+			 */
+			if (formatDetected) {
+				const formatDiv = document.createElement('div');
+				const format = response.Format;
+				const formatText = 'Detected format: ' + format;
+				const formatNode = document.createTextNode(formatText);
+				formatDiv.appendChild(formatNode);
+				tableDiv.appendChild(formatDiv);
+			}
+
+			const skipped = response.Skipped;
+
+			/*
+			 * Report records which could not be parsed.
+			 */
+			if (skipped > 0) {
+				const skippedDiv = document.createElement('div');
+				const skippedText = skipped.toString() + ' records could not be parsed and were skipped.';
+				const skippedNode = document.createTextNode(skippedText);
+				skippedDiv.appendChild(skippedNode);
+				tableDiv.appendChild(skippedDiv);
+			}
+
 		}
 
 		contentDiv.appendChild(tableDiv);
@@ -1738,9 +1849,10 @@ function UI() {
 	}
 
 	/*
-	 * Parse GeoDB stats and display them as a table inside a div element.
+	 * Parse GeoDB stats and distance and display them as a table inside a div
+	 * element.
 	 */
-	this.displayGeoDBStats = function(div, response) {
+	this.displayGeoDBStats = function(div, response, responseDistance) {
 		const cgi = globals.cgi;
 		const cvs = document.getElementById('map_canvas');
 		const token = storage.get(cvs, 'token');
@@ -1749,7 +1861,7 @@ function UI() {
 		const table = document.createElement('table');
 		table.className = 'geodbtable';
 		const body = document.createElement('tbody');
-		const labels = ['Location count', 'Ordered', 'Ordered strict', 'Timestamp earliest', 'Timestamp latest'];
+		const labels = ['Location count', 'Ordered', 'Ordered strict', 'Timestamp earliest', 'Timestamp latest', 'Distance'];
 		const locationCount = response.LocationCount;
 		const locationCountString = locationCount.toString();
 		const ordered = response.Ordered;
@@ -1760,7 +1872,17 @@ function UI() {
 		const timestampEarliestString = timestampEarliest.toString();
 		const timestampLatest = response.TimestampLatest;
 		const timestampLatestString = timestampLatest.toString();
-		const values = [locationCountString, orderedString, orderedStrictString, timestampEarliestString, timestampLatestString];
+		let distanceString = 'unknown';
+
+		/*
+		 * Only display distance if it could be determined.
+		 */
+		if ((responseDistance !== null) && (responseDistance.Success === true)) {
+			const distanceKM = responseDistance.DistanceKM;
+			distanceString = distanceKM.toFixed(1) + ' km';
+		}
+
+		const values = [locationCountString, orderedString, orderedStrictString, timestampEarliestString, timestampLatestString, distanceString];
 
 		/*
 		 * Iterate over all labels and values and add them to table.
@@ -1879,19 +2001,49 @@ function UI() {
 		downloadLinkJSONPretty.appendChild(downloadLinkJSONPrettyNode);
 		downloadLinkJSONPrettyDiv.appendChild(downloadLinkJSONPretty);
 		downloadLinksDiv.appendChild(downloadLinkJSONPrettyDiv);
+		const downloadLinkGeoJSONDiv = document.createElement('div');
+		const downloadLinkGeoJSON = document.createElement('a');
+		downloadLinkGeoJSON.className = 'link';
+		const requestDownloadGeoJSON = new Request();
+		requestDownloadGeoJSON.append('cgi', cgiDownloadGeoDBContent);
+		requestDownloadGeoJSON.append('format', 'geojson');
+		requestDownloadGeoJSON.append('token', token);
+		const requestDownloadGeoJSONData = requestDownloadGeoJSON.getData();
+		const downloadLinkGeoJSONHref = document.createAttribute('href');
+		downloadLinkGeoJSONHref.value = cgi + '?' + requestDownloadGeoJSONData;
+		downloadLinkGeoJSON.setAttributeNode(downloadLinkGeoJSONHref);
+		const downloadLinkGeoJSONNode = document.createTextNode('Download GeoJSON feature collection (*.geojson)');
+		downloadLinkGeoJSON.appendChild(downloadLinkGeoJSONNode);
+		downloadLinkGeoJSONDiv.appendChild(downloadLinkGeoJSON);
+		downloadLinksDiv.appendChild(downloadLinkGeoJSONDiv);
+		const downloadLinkKMLDiv = document.createElement('div');
+		const downloadLinkKML = document.createElement('a');
+		downloadLinkKML.className = 'link';
+		const requestDownloadKML = new Request();
+		requestDownloadKML.append('cgi', cgiDownloadGeoDBContent);
+		requestDownloadKML.append('format', 'kml');
+		requestDownloadKML.append('token', token);
+		const requestDownloadKMLData = requestDownloadKML.getData();
+		const downloadLinkKMLHref = document.createAttribute('href');
+		downloadLinkKMLHref.value = cgi + '?' + requestDownloadKMLData;
+		downloadLinkKML.setAttributeNode(downloadLinkKMLHref);
+		const downloadLinkKMLNode = document.createTextNode('Download Keyhole Markup Language track (*.kml)');
+		downloadLinkKML.appendChild(downloadLinkKMLNode);
+		downloadLinkKMLDiv.appendChild(downloadLinkKML);
+		downloadLinksDiv.appendChild(downloadLinkKMLDiv);
 		div.appendChild(downloadLinksDiv);
 		const spacerDivB = document.createElement('div');
 		spacerDivB.className = 'vspace';
 		div.appendChild(spacerDivB);
 		const importPropertiesDiv = document.createElement('div');
 		const importPropertiesDescriptionDiv = document.createElement('div');
-		const importPropertiesDescriptionNode = document.createTextNode('Drop OpenGeoDB, CSV, GPX or JSON file to import location data into geographical database.');
+		const importPropertiesDescriptionNode = document.createTextNode('Drop OpenGeoDB, CSV, GPX, JSON or KML file to import location data into geographical database.');
 		importPropertiesDescriptionDiv.appendChild(importPropertiesDescriptionNode);
 		importPropertiesDiv.appendChild(importPropertiesDescriptionDiv);
 		const importFormatElem = this.createElement('Format', '180px');
-		const importFormatLabels = ['OpenGeoDB (*.geodb)', 'CSV / RFC 4180 (*.csv)', 'GPS Exchange (*.gpx)', 'Records JSON (*.json)'];
-		const importFormatValues = ['binary', 'csv', 'gpx', 'json'];
-		const importFormatDefault = importFormatValues[3];
+		const importFormatLabels = ['Detect automatically', 'OpenGeoDB (*.geodb)', 'CSV / RFC 4180 (*.csv)', 'GPS Exchange (*.gpx)', 'Records JSON (*.json)', 'Keyhole Markup Language (*.kml)'];
+		const importFormatValues = ['auto', 'binary', 'csv', 'gpx', 'json', 'kml'];
+		const importFormatDefault = importFormatValues[0];
 		const fieldImportFormat = document.createElement('select');
 
 		/*
@@ -1944,7 +2096,7 @@ function UI() {
 		actionPropertiesDescriptionDiv.appendChild(actionPropertiesDescriptionNode);
 		actionPropertiesDiv.appendChild(actionPropertiesDescriptionDiv);
 		const actionElem = this.createElement('Action', '180px');
-		const actionValues = ['(none)', 'sort entries', 'deduplicate entries', 'clear database'];
+		const actionValues = ['(none)', 'sort entries', 'deduplicate entries', 'repair timestamps', 'thin entries', 'clear database'];
 		const actionDefault = actionValues[0];
 		const fieldAction = document.createElement('select');
 
@@ -1972,6 +2124,14 @@ function UI() {
 		fieldHash.setAttribute('type', 'text');
 		hashElem.appendChild(fieldHash);
 		actionPropertiesDiv.appendChild(hashElem);
+		const thinElem = this.createElement('Keep every', '180px');
+		const fieldThin = document.createElement('input');
+		fieldThin.className = 'textfield';
+		fieldThin.setAttribute('id', 'geodb_thin_field');
+		fieldThin.setAttribute('type', 'text');
+		fieldThin.value = '10';
+		thinElem.appendChild(fieldThin);
+		actionPropertiesDiv.appendChild(thinElem);
 		div.appendChild(actionPropertiesDiv);
 		const spacerDivD = document.createElement('div');
 		spacerDivD.className = 'vspace';
@@ -1990,6 +2150,8 @@ function UI() {
 			const actionValue = actionField.value;
 			const hashField = document.getElementById('geodb_hash_field');
 			const hashValue = hashField.value;
+			const thinField = document.getElementById('geodb_thin_field');
+			const thinValue = thinField.value;
 			let actionString = null;
 
 			/*
@@ -1999,6 +2161,10 @@ function UI() {
 				actionString = 'sort';
 			} else if (actionValue === 'deduplicate entries') {
 				actionString = 'deduplicate';
+			} else if (actionValue === 'repair timestamps') {
+				actionString = 'repair-timestamps';
+			} else if (actionValue === 'thin entries') {
+				actionString = 'thin';
 			} else if (actionValue === 'clear database') {
 				actionString = 'clear';
 			}
@@ -2020,6 +2186,22 @@ function UI() {
 					request.append('hash', hashValue);
 				}
 
+				/*
+				 * Sort the database after repairing timestamps, so that
+				 * time filtering works afterwards.
+				 */
+				if (actionString === 'repair-timestamps') {
+					request.append('sort', 'true');
+				}
+
+				/*
+				 * When thinning the database, provide the number of
+				 * entries of which one is kept.
+				 */
+				if (actionString === 'thin') {
+					request.append('n', thinValue);
+				}
+
 				const cvs = document.getElementById('map_canvas');
 				const token = storage.get(cvs, 'token');
 				request.append('token', token);
@@ -2156,6 +2338,27 @@ function UI() {
 
 		elemColorMapping.appendChild(fieldColorMapping);
 		sidebar.appendChild(elemColorMapping);
+		const elemMode = this.createElement('Mode', null);
+		const fieldMode = document.createElement('select');
+		fieldMode.className = 'textfield';
+		const modeValues = ['', 'points', 'lines'];
+		const modeCaptions = ['(default)', 'points', 'lines'];
+
+		/*
+		 * Iterate over the render modes and add them to dropdown.
+		 */
+		for (let i = 0; i < modeValues.length; i++) {
+			const modeValue = modeValues[i];
+			const modeCaption = modeCaptions[i];
+			const option = document.createElement('option');
+			option.setAttribute('value', modeValue);
+			const optionNode = document.createTextNode(modeCaption);
+			option.appendChild(optionNode);
+			fieldMode.appendChild(option);
+		}
+
+		elemMode.appendChild(fieldMode);
+		sidebar.appendChild(elemMode);
 		const elemButtonsA = this.createElement('', null);
 		const buttonApply = document.createElement('button');
 		buttonApply.className = 'button';
@@ -2171,10 +2374,12 @@ function UI() {
 			const valueMapIntensity = helper.cleanValue(fieldMapIntensity.value);
 			const valueSpread = helper.cleanValue(fieldSpread.value);
 			const valueFgColor = helper.cleanValue(fieldColorMapping.value);
+			const valueMode = helper.cleanValue(fieldMode.value);
 			const cvs = document.getElementById('map_canvas');
 			storage.put(cvs, 'colorScale', valueMapIntensity);
 			storage.put(cvs, 'spread', valueSpread);
 			storage.put(cvs, 'fgColor', valueFgColor);
+			storage.put(cvs, 'mode', valueMode);
 			storage.put(cvs, 'minTime', valueFrom);
 			storage.put(cvs, 'maxTime', valueTo);
 			handler.refresh();
@@ -2352,6 +2557,14 @@ function UI() {
 		fieldPassword.setAttribute('autocomplete', 'current-password');
 		elemPassword.appendChild(fieldPassword);
 		loginContent.appendChild(elemPassword);
+		const elemCode = this.createElement('Code', null);
+		const fieldCode = document.createElement('input');
+		fieldCode.className = 'textfield';
+		fieldCode.setAttribute('type', 'text');
+		fieldCode.setAttribute('inputmode', 'numeric');
+		fieldCode.setAttribute('autocomplete', 'one-time-code');
+		elemCode.appendChild(fieldCode);
+		loginContent.appendChild(elemCode);
 		const elemButtons = this.createElement('', null);
 		const buttonLogin = document.createElement('button');
 		buttonLogin.className = 'button';
@@ -2388,6 +2601,20 @@ function UI() {
 
 		};
 
+		/*
+		 * This is called when the user types in the one-time code field.
+		 */
+		fieldCode.onkeyup = function(e) {
+
+			/*
+			 * On Enter, perform login sequence.
+			 */
+			if (e.key === 'Enter') {
+				buttonLogin.click();
+			}
+
+		};
+
 		/*
 		 * This is called when the user clicks on the 'Login' button.
 		 */
@@ -2395,6 +2622,7 @@ function UI() {
 			const cgi = globals.cgi;
 			const valueUser = helper.cleanValue(fieldUser.value);
 			const valuePassword = fieldPassword.value;
+			const valueCode = helper.cleanValue(fieldCode.value);
 			const rqChallenge = new Request();
 			rqChallenge.append('cgi', 'auth-request');
 			rqChallenge.append('name', valueUser);
@@ -2443,6 +2671,7 @@ function UI() {
 					rqResponse.append('cgi', 'auth-response');
 					rqResponse.append('name', valueUser);
 					rqResponse.append('hash', outerHash);
+					rqResponse.append('code', valueCode);
 					const dataResponse = rqResponse.getData();
 
 					/*
@@ -2457,6 +2686,7 @@ function UI() {
 						 */
 						if (tokenSuccess === true) {
 							fieldPassword.value = '';
+							fieldCode.value = '';
 							const tokenData = token.Token;
 							callback(tokenData);
 						}
@@ -2835,7 +3065,7 @@ function UI() {
 	/*
 	 * Updates the image element with a new view of the map.
 	 */
-	this.updateMap = function(token, xres, yres, xpos, ypos, zoom, mintime, maxtime, colorScale, spread, fgColor) {
+	this.updateMap = function(token, xres, yres, xpos, ypos, zoom, mintime, maxtime, colorScale, spread, fgColor, mode) {
 		/* Earth circumference at the equator. */
 		const circ = 40074;
 		const rq = new Request();
@@ -2902,6 +3132,14 @@ function UI() {
 			rq.append('fgcolor', fgColorString);
 		}
 
+		/*
+		 * Use render mode.
+		 */
+		if (mode !== null) {
+			const modeString = mode.toString();
+			rq.append('mode', modeString);
+		}
+
 		/*
 		 * Use session token.
 		 */
@@ -3002,7 +3240,8 @@ function Handler() {
 		const colorScale = storage.get(cvs, 'colorScale');
 		const spread = storage.get(cvs, 'spread');
 		const fgColor = storage.get(cvs, 'fgColor');
-		ui.updateMap(token, width, height, posX, posY, zoom, timeMin, timeMax, colorScale, spread, fgColor);
+		const mode = storage.get(cvs, 'mode');
+		ui.updateMap(token, width, height, posX, posY, zoom, timeMin, timeMax, colorScale, spread, fgColor, mode);
 	};
 
 	/*
@@ -3520,12 +3759,25 @@ function Handler() {
 		const mime = globals.mimeDefault;
 
 		/*
-		 * This is called when the server returns activities.
+		 * This is called when the server returns GeoDB stats.
 		 */
 		const callback = function(content) {
 			const response = helper.parseJSON(content);
-			const div = document.getElementById('geodb');
-			ui.displayGeoDBStats(div, response);
+			const requestDistance = new Request();
+			requestDistance.append('cgi', 'get-geodb-distance');
+			requestDistance.append('token', token);
+			const dataDistance = requestDistance.getData();
+
+			/*
+			 * This is called when the server returns the distance.
+			 */
+			const callbackDistance = function(contentDistance) {
+				const responseDistance = helper.parseJSON(contentDistance);
+				const div = document.getElementById('geodb');
+				ui.displayGeoDBStats(div, response, responseDistance);
+			};
+
+			ajax.request('POST', cgi, dataDistance, mime, callbackDistance, false);
 		};
 
 		ajax.request('POST', cgi, data, mime, callback, false);
@@ -3580,6 +3832,7 @@ function Handler() {
 		storage.put(cvs, 'spread', '0');
 		storage.put(cvs, 'colorScale', '5');
 		storage.put(cvs, 'fgColor', null);
+		storage.put(cvs, 'mode', null);
 		storage.put(cvs, 'minTime', null);
 		storage.put(cvs, 'maxTime', null);
 		storage.put(cvs, 'imageRequestId', 0);
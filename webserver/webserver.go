@@ -1,30 +1,47 @@
 package webserver
 
 import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"crypto/sha256"
 	"crypto/tls"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"log"
 	"mime/multipart"
+	"net"
 	"net/http"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
 const (
+	ENCODING_DEFLATE        = "deflate"
+	ENCODING_GZIP           = "gzip"
+	ENCODING_IDENTITY       = ""
 	MAX_REQUEST_SIZE_MEMORY = 1 << 20
 	MAX_REQUEST_SIZE_TOTAL  = 1 << 30
+	STATIC_CACHE_CONTROL    = "public, max-age=31536000, immutable"
+	STATIC_HASH_LENGTH      = 16
 )
 
 /*
  * Exchange format for HTTP requests.
  */
 type HttpRequest struct {
+	Context  context.Context
 	Protocol string
 	Method   string
 	Path     string
 	Host     string
+	Header   map[string]string
 	Params   map[string]string
 	Files    map[string][]multipart.File
 	Respond  chan<- HttpResponse
@@ -32,12 +49,16 @@ type HttpRequest struct {
 
 /*
  * Exchange format for HTTP responses.
+ *
+ * If Status is non-zero, it is sent as the status code of a response with a
+ * Body. Otherwise, the status code is 200 (OK).
  */
 type HttpResponse struct {
 	Header                map[string]string
 	Body                  []byte
 	ContentReadCloser     io.ReadCloser
 	ContentReadSeekCloser io.ReadSeekCloser
+	Status                int
 }
 
 /*
@@ -66,26 +87,52 @@ type Timeouts struct {
  * Data structure for web server configuration.
  */
 type Config struct {
-	Name          string
-	Port          string
-	TLSDisabled   bool
-	TLSPort       string
-	TLSPrivateKey string
-	TLSPublicKey  string
-	WebRoot       string
-	Index         string
-	MimeTypes     map[string]string
-	DefaultMime   string
-	ErrorMime     string
-	Timeouts      Timeouts
+	Name                string
+	Port                string
+	TLSDisabled         bool
+	TLSPort             string
+	TLSPrivateKey       string
+	TLSPublicKey        string
+	TLSMinVersion       string
+	TLSCipherSuites     []string
+	HTTP2Disabled       bool
+	WebRoot             string
+	Index               string
+	StaticAssets        bool
+	MimeTypes           map[string]string
+	DefaultMime         string
+	ErrorMime           string
+	MaxConnectionsPerIP uint32
+	Timeouts            Timeouts
+}
+
+/*
+ * Data structure representing a static asset held in memory.
+ *
+ * Compressed representations are nil if compression does not reduce the size
+ * of the asset.
+ */
+type staticAssetStruct struct {
+	content  []byte
+	deflate  []byte
+	gzip     []byte
+	hash     string
+	mimetype string
 }
 
 /*
  * Data structure holding the web server's internal state.
  */
 type webServerStruct struct {
-	cgis   map[string]chan<- HttpRequest
-	config Config
+	cgis             map[string]chan<- HttpRequest
+	config           Config
+	connections      map[string]uint32
+	connectionsMutex sync.Mutex
+	staticAssets     map[string]*staticAssetStruct
+	staticIndex      *staticAssetStruct
+	staticPath       string
+	tlsCipherSuites  []uint16
+	tlsMinVersion    uint16
 }
 
 /*
@@ -93,6 +140,7 @@ type webServerStruct struct {
  */
 type WebServer interface {
 	RegisterCgi(path string) <-chan HttpRequest
+	RegisterStatic(path string) error
 	GetCgis() []string
 	RemoveCgi(path string)
 	Run()
@@ -134,9 +182,19 @@ func (this *webServerStruct) cgiHandler(writer http.ResponseWriter, request *htt
 	url := request.URL
 	path := url.Path
 	host := request.Host
+	ctx := request.Context()
+	header := make(map[string]string)
 	params := make(map[string]string)
 	files := make(map[string][]multipart.File)
 
+	/*
+	 * Iterate over all request headers.
+	 */
+	for key, values := range request.Header {
+		hs := strings.Join(values, ", ")
+		header[key] = hs
+	}
+
 	/*
 	 * Iterate over all form values and parse parameters.
 	 */
@@ -209,10 +267,12 @@ func (this *webServerStruct) cgiHandler(writer http.ResponseWriter, request *htt
 	 * The parsed HTTP request.
 	 */
 	hrequest := HttpRequest{
+		Context:  ctx,
 		Protocol: protocol,
 		Method:   method,
 		Path:     path,
 		Host:     host,
+		Header:   header,
 		Params:   params,
 		Files:    files,
 		Respond:  responseChannel,
@@ -239,7 +299,27 @@ func (this *webServerStruct) cgiHandler(writer http.ResponseWriter, request *htt
 	contentReadCloser := response.ContentReadCloser
 	contentReadSeekCloser := response.ContentReadSeekCloser
 
+	/*
+	 * Deliver response content.
+	 *
+	 * The size of a body is known in advance, so we announce it to allow
+	 * clients to show progress. When serving a ReadSeekCloser, the size is
+	 * determined by seeking to its end. Content provided by a ReadCloser
+	 * is generated on-the-fly and therefore delivered in chunks.
+	 */
 	if body != nil {
+		bodyLength := len(body)
+		bodyLengthString := strconv.Itoa(bodyLength)
+		hdr.Set("Content-length", bodyLengthString)
+		status := response.Status
+
+		/*
+		 * Send status code if one was provided.
+		 */
+		if status != 0 {
+			writer.WriteHeader(status)
+		}
+
 		writer.Write(body)
 	} else if contentReadSeekCloser != nil {
 		modTime := time.Time{}
@@ -252,6 +332,61 @@ func (this *webServerStruct) cgiHandler(writer http.ResponseWriter, request *htt
 
 }
 
+/*
+ * Tracks the number of connections from each IP address.
+ *
+ * If a client exceeds the maximum number of simultaneous connections, its
+ * new connection is closed right away.
+ */
+func (this *webServerStruct) connState(conn net.Conn, state http.ConnState) {
+	addr := conn.RemoteAddr()
+	addrString := addr.String()
+	host, _, err := net.SplitHostPort(addrString)
+
+	/*
+	 * Fall back to the entire address if it has no port.
+	 */
+	if err != nil {
+		host = addrString
+	}
+
+	cfg := this.config
+	maxConnections := cfg.MaxConnectionsPerIP
+	this.connectionsMutex.Lock()
+	connections := this.connections
+
+	/*
+	 * Decide whether a connection was opened or closed.
+	 */
+	switch state {
+	case http.StateNew:
+		numConnections := connections[host] + 1
+		connections[host] = numConnections
+
+		/*
+		 * Reject connection if client has too many connections already.
+		 */
+		if numConnections > maxConnections {
+			conn.Close()
+		}
+
+	case http.StateClosed, http.StateHijacked:
+		numConnections := connections[host]
+
+		/*
+		 * Forget about clients without connections.
+		 */
+		if numConnections <= 1 {
+			delete(connections, host)
+		} else {
+			connections[host] = numConnections - 1
+		}
+
+	}
+
+	this.connectionsMutex.Unlock()
+}
+
 /*
  * A handler for file requests. This allows, e. g. (X)HTML, CSS, JavaScript
  * content and images to be served.
@@ -271,45 +406,257 @@ func (this *webServerStruct) fileHandler(writer http.ResponseWriter, request *ht
 		hdr.Set("Location", indexFile)
 		writer.WriteHeader(http.StatusFound)
 	} else {
-		dotPos := strings.LastIndex(path, ".")
-		extension := ""
+		indexFile := cfg.Index
+		staticIndex := this.staticIndex
 
 		/*
-		 * Check for file extension.
+		 * If static assets are served, the index file refers to them,
+		 * so serve the rewritten index file from memory.
 		 */
-		if dotPos != -1 {
-			dotPosInc := dotPos + 1
-			extension = path[dotPosInc:]
+		if (path == indexFile) && (staticIndex != nil) {
+			this.serveStaticAsset(writer, request, staticIndex, false)
+		} else {
+			mimetype := this.mimeType(path)
+			webRoot := cfg.WebRoot
+			filePath := webRoot + path
+			fd, err := os.Open(filePath)
+			hdr := writer.Header()
+
+			/*
+			 * Check if file exists in web root.
+			 */
+			if err != nil {
+				errorMime := cfg.ErrorMime
+				hdr.Set("Content-type", errorMime)
+				fmt.Fprintf(writer, "[ERROR] - '%s' does not exist!\n", path)
+			} else {
+				hdr.Set("Content-type", mimetype)
+				modTime := time.Time{}
+				http.ServeContent(writer, request, path, modTime, fd)
+			}
+
+			fd.Close()
 		}
 
-		mimetype, present := cfg.MimeTypes[extension]
+	}
+
+}
+
+/*
+ * Returns the MIME type registered for the extension of a file.
+ */
+func (this *webServerStruct) mimeType(path string) string {
+	cfg := this.config
+	dotPos := strings.LastIndex(path, ".")
+	extension := ""
+
+	/*
+	 * Check for file extension.
+	 */
+	if dotPos != -1 {
+		dotPosInc := dotPos + 1
+		extension = path[dotPosInc:]
+	}
+
+	mimetype, present := cfg.MimeTypes[extension]
+
+	/*
+	 * Check if a MIME type is registered for this extension.
+	 */
+	if !present {
+		mimetype = cfg.DefaultMime
+	}
+
+	return mimetype
+}
+
+/*
+ * Chooses the content encoding for a response based on the Accept-Encoding
+ * header of a request.
+ *
+ * Prefers gzip over deflate. Falls back to the identity encoding if the
+ * client accepts neither.
+ */
+func (this *webServerStruct) negotiateEncoding(request *http.Request) string {
+	hdr := request.Header
+	acceptEncoding := hdr.Get("Accept-Encoding")
+	codings := strings.Split(acceptEncoding, ",")
+	acceptDeflate := false
+	acceptGzip := false
+
+	/*
+	 * Check each coding accepted by the client.
+	 */
+	for _, coding := range codings {
+		parts := strings.Split(coding, ";")
+		name := strings.TrimSpace(parts[0])
+		name = strings.ToLower(name)
+		acceptable := true
 
 		/*
-		 * Check if a MIME type is registered for this extension.
+		 * Check parameters for a quality value of zero, which marks
+		 * the coding as not acceptable.
 		 */
-		if !present {
-			mimetype = cfg.DefaultMime
+		for _, param := range parts[1:] {
+			param = strings.TrimSpace(param)
+
+			/*
+			 * Check if this is a quality value.
+			 */
+			if strings.HasPrefix(param, "q=") {
+				value := strings.TrimPrefix(param, "q=")
+				q, err := strconv.ParseFloat(value, 64)
+
+				/*
+				 * Invalid or zero quality values are not acceptable.
+				 */
+				if (err != nil) || (q <= 0.0) {
+					acceptable = false
+				}
+
+			}
+
 		}
 
-		webRoot := cfg.WebRoot
-		filePath := webRoot + path
-		fd, err := os.Open(filePath)
-		hdr := writer.Header()
+		/*
+		 * Check which coding the client accepts.
+		 */
+		switch name {
+		case ENCODING_DEFLATE:
+			acceptDeflate = acceptable
+		case ENCODING_GZIP:
+			acceptGzip = acceptable
+		case "*":
+			acceptGzip = acceptGzip || acceptable
+		}
+
+	}
+
+	/*
+	 * Choose the preferred encoding.
+	 */
+	if acceptGzip {
+		return ENCODING_GZIP
+	} else if acceptDeflate {
+		return ENCODING_DEFLATE
+	} else {
+		return ENCODING_IDENTITY
+	}
+
+}
+
+/*
+ * Serves a static asset from memory.
+ *
+ * If the asset is immutable, clients and proxies may cache it for a year,
+ * since its content hash is part of its name. Otherwise, the default headers
+ * prevent caching.
+ */
+func (this *webServerStruct) serveStaticAsset(writer http.ResponseWriter, request *http.Request, asset *staticAssetStruct, immutable bool) {
+	encoding := this.negotiateEncoding(request)
+	content := asset.content
+
+	/*
+	 * Use the compressed representation, if available.
+	 */
+	switch encoding {
+	case ENCODING_DEFLATE:
+		content = asset.deflate
+	case ENCODING_GZIP:
+		content = asset.gzip
+	}
+
+	/*
+	 * Fall back to the uncompressed representation if compression would
+	 * not reduce the size of the asset.
+	 */
+	if content == nil {
+		encoding = ENCODING_IDENTITY
+		content = asset.content
+	}
+
+	hash := asset.hash
+	etag := fmt.Sprintf("\"%s\"", hash)
+
+	/*
+	 * Each representation needs its own entity tag.
+	 */
+	if encoding != ENCODING_IDENTITY {
+		etag = fmt.Sprintf("\"%s-%s\"", hash, encoding)
+	}
+
+	hdr := writer.Header()
+	mimetype := asset.mimetype
+	hdr.Set("Content-type", mimetype)
+	hdr.Set("ETag", etag)
+	hdr.Set("Vary", "Accept-Encoding")
+
+	/*
+	 * Allow immutable assets to be cached.
+	 */
+	if immutable {
+		hdr.Set("Cache-control", STATIC_CACHE_CONTROL)
+		hdr.Del("Pragma")
+	}
+
+	requestHeader := request.Header
+	ifNoneMatch := requestHeader.Get("If-None-Match")
+	method := request.Method
+
+	/*
+	 * Check if the client already has this representation.
+	 */
+	if strings.Contains(ifNoneMatch, etag) {
+		writer.WriteHeader(http.StatusNotModified)
+	} else {
 
 		/*
-		 * Check if file exists in web root.
+		 * Indicate compressed representations.
 		 */
-		if err != nil {
-			errorMime := cfg.ErrorMime
-			hdr.Set("Content-type", errorMime)
-			fmt.Fprintf(writer, "[ERROR] - '%s' does not exist!\n", path)
-		} else {
-			hdr.Set("Content-type", mimetype)
-			modTime := time.Time{}
-			http.ServeContent(writer, request, path, modTime, fd)
+		if encoding != ENCODING_IDENTITY {
+			hdr.Set("Content-encoding", encoding)
+		}
+
+		size := len(content)
+		sizeString := strconv.Itoa(size)
+		hdr.Set("Content-length", sizeString)
+		writer.WriteHeader(http.StatusOK)
+
+		/*
+		 * Responses to HEAD requests have no body.
+		 */
+		if method != http.MethodHead {
+			writer.Write(content)
 		}
 
-		fd.Close()
+	}
+
+}
+
+/*
+ * A handler for static assets, which are served from memory under names
+ * containing a hash of their content.
+ */
+func (this *webServerStruct) staticHandler(writer http.ResponseWriter, request *http.Request) {
+	url := request.URL
+	path := url.Path
+	this.setDefaultHeaders(writer)
+	staticPath := this.staticPath
+	name := strings.TrimPrefix(path, staticPath)
+	asset, found := this.staticAssets[name]
+
+	/*
+	 * Check if asset exists.
+	 */
+	if !found {
+		cfg := this.config
+		hdr := writer.Header()
+		errorMime := cfg.ErrorMime
+		hdr.Set("Content-type", errorMime)
+		writer.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(writer, "[ERROR] - '%s' does not exist!\n", path)
+	} else {
+		this.serveStaticAsset(writer, request, asset, true)
 	}
 
 }
@@ -357,6 +704,236 @@ func (this *webServerStruct) RegisterCgi(path string) <-chan HttpRequest {
 	return requests
 }
 
+/*
+ * Compresses content using gzip or deflate.
+ *
+ * Returns nil if compression does not reduce the size of the content.
+ */
+func compress(content []byte, encoding string) ([]byte, error) {
+	buf := bytes.Buffer{}
+	w := io.WriteCloser(nil)
+	err := error(nil)
+
+	/*
+	 * Create writer for requested encoding.
+	 */
+	switch encoding {
+	case ENCODING_DEFLATE:
+		w, err = zlib.NewWriterLevel(&buf, zlib.BestCompression)
+	case ENCODING_GZIP:
+		w, err = gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	default:
+		err = fmt.Errorf("Unsupported encoding '%s'.", encoding)
+	}
+
+	/*
+	 * Check if writer could be created.
+	 */
+	if err != nil {
+		return nil, err
+	} else {
+		_, err = w.Write(content)
+
+		/*
+		 * Close writer to flush remaining data.
+		 */
+		if err == nil {
+			err = w.Close()
+		}
+
+		compressedSize := buf.Len()
+		size := len(content)
+
+		/*
+		 * Check if compression was successful and reduces size.
+		 */
+		if err != nil {
+			return nil, err
+		} else if compressedSize >= size {
+			return nil, nil
+		} else {
+			result := buf.Bytes()
+			return result, nil
+		}
+
+	}
+
+}
+
+/*
+ * Creates a static asset, including its compressed representations.
+ */
+func (this *webServerStruct) createStaticAsset(path string, content []byte) (*staticAssetStruct, error) {
+	hashBytes := sha256.Sum256(content)
+	hashString := hex.EncodeToString(hashBytes[:])
+	hash := hashString[:STATIC_HASH_LENGTH]
+	mimetype := this.mimeType(path)
+	contentDeflate, errDeflate := compress(content, ENCODING_DEFLATE)
+	contentGzip, errGzip := compress(content, ENCODING_GZIP)
+
+	/*
+	 * Check if content could be compressed.
+	 */
+	if errDeflate != nil {
+		msg := errDeflate.Error()
+		return nil, fmt.Errorf("Failed to compress '%s' using deflate: %s", path, msg)
+	} else if errGzip != nil {
+		msg := errGzip.Error()
+		return nil, fmt.Errorf("Failed to compress '%s' using gzip: %s", path, msg)
+	} else {
+
+		/*
+		 * Create static asset.
+		 */
+		asset := staticAssetStruct{
+			content:  content,
+			deflate:  contentDeflate,
+			gzip:     contentGzip,
+			hash:     hash,
+			mimetype: mimetype,
+		}
+
+		return &asset, nil
+	}
+
+}
+
+/*
+ * Inserts a hash into a file name, right before its extension.
+ */
+func hashedName(name string, hash string) string {
+	extension := filepath.Ext(name)
+	base := strings.TrimSuffix(name, extension)
+	result := fmt.Sprintf("%s.%s%s", base, hash, extension)
+	return result
+}
+
+/*
+ * Serves all files in the web root from memory under the given path.
+ *
+ * The content hash of each file becomes part of its name, so that clients can
+ * cache them indefinitely. The index file is rewritten to refer to these
+ * names and served with compression, but is never cached, so that clients
+ * notice when assets change. Files are read once, so changes to the web root
+ * only take effect after a restart.
+ */
+func (this *webServerStruct) RegisterStatic(path string) error {
+	cfg := this.config
+	webRoot := cfg.WebRoot
+	indexFile := cfg.Index
+	indexName := strings.TrimPrefix(indexFile, "/")
+	assets := make(map[string]*staticAssetStruct)
+	replacements := []string{}
+	indexContent := []byte(nil)
+
+	/*
+	 * Load every regular file in the web root.
+	 */
+	err := filepath.Walk(webRoot, func(filePath string, info os.FileInfo, err error) error {
+		regular := false
+
+		/*
+		 * Information is unavailable if an error occurred.
+		 */
+		if info != nil {
+			mode := info.Mode()
+			regular = mode.IsRegular()
+		}
+
+		/*
+		 * Check if an error occurred or this is not a regular file.
+		 */
+		if err != nil {
+			return err
+		} else if !regular {
+			return nil
+		} else {
+			rel, err := filepath.Rel(webRoot, filePath)
+
+			/*
+			 * Check if path could be made relative to web root.
+			 */
+			if err != nil {
+				return err
+			} else {
+				name := filepath.ToSlash(rel)
+				content, err := os.ReadFile(filePath)
+
+				/*
+				 * Check if file could be read.
+				 */
+				if err != nil {
+					return err
+				} else if name == indexName {
+					indexContent = content
+					return nil
+				} else {
+					asset, err := this.createStaticAsset(name, content)
+
+					/*
+					 * Check if asset could be created.
+					 */
+					if err != nil {
+						return err
+					} else {
+						hash := asset.hash
+						hashed := hashedName(name, hash)
+						assets[hashed] = asset
+						reference := path + hashed
+						quotes := []string{"\"", "'"}
+
+						/*
+						 * Replace references in both types of quotes.
+						 */
+						for _, quote := range quotes {
+							original := quote + name + quote
+							replacement := quote + reference + quote
+							replacements = append(replacements, original, replacement)
+						}
+
+						return nil
+					}
+
+				}
+
+			}
+
+		}
+
+	})
+
+	/*
+	 * Check if web root could be loaded.
+	 */
+	if err != nil {
+		msg := err.Error()
+		return fmt.Errorf("Failed to load static assets: %s", msg)
+	} else if indexContent == nil {
+		return fmt.Errorf("Failed to load static assets: Index file '%s' not found in web root.", indexFile)
+	} else {
+		replacer := strings.NewReplacer(replacements...)
+		indexString := string(indexContent)
+		indexRewritten := replacer.Replace(indexString)
+		indexBytes := []byte(indexRewritten)
+		index, err := this.createStaticAsset(indexName, indexBytes)
+
+		/*
+		 * Check if index could be created.
+		 */
+		if err != nil {
+			msg := err.Error()
+			return fmt.Errorf("Failed to load static assets: %s", msg)
+		} else {
+			this.staticAssets = assets
+			this.staticIndex = index
+			this.staticPath = path
+			return nil
+		}
+
+	}
+
+}
+
 /*
  * Returns a list of the URLs of all currently registered CGIs.
  */
@@ -394,6 +971,8 @@ func (this *webServerStruct) Run() {
 	cgis := this.cgis
 	cgiHandler := this.cgiHandler
 	fileHandler := this.fileHandler
+	staticHandler := this.staticHandler
+	staticPath := this.staticPath
 
 	/*
 	 * If TLS is disabled by configuration, handle requests via unencrypted
@@ -408,6 +987,13 @@ func (this *webServerStruct) Run() {
 			httpMux.HandleFunc(path, cgiHandler)
 		}
 
+		/*
+		 * Register static assets to HTTP server.
+		 */
+		if staticPath != "" {
+			httpMux.HandleFunc(staticPath, staticHandler)
+		}
+
 		httpMux.HandleFunc("/", fileHandler)
 	} else {
 		redirectHandler := this.redirect
@@ -416,6 +1002,17 @@ func (this *webServerStruct) Run() {
 
 	discard := io.Discard
 	logger := log.New(discard, "", log.LstdFlags)
+	maxConnections := cfg.MaxConnectionsPerIP
+	connState := (func(net.Conn, http.ConnState))(nil)
+
+	/*
+	 * Only track connections if their number is limited.
+	 */
+	if maxConnections > 0 {
+		this.connections = make(map[string]uint32)
+		connState = this.connState
+	}
+
 	httpPort := cfg.Port
 	httpAddr := fmt.Sprintf(":%s", httpPort)
 	timeouts := cfg.Timeouts
@@ -438,6 +1035,7 @@ func (this *webServerStruct) Run() {
 	 */
 	httpServer := http.Server{
 		Addr:              httpAddr,
+		ConnState:         connState,
 		ErrorLog:          logger,
 		Handler:           httpMux,
 		IdleTimeout:       httpTimeoutIdle,
@@ -461,24 +1059,19 @@ func (this *webServerStruct) Run() {
 			tlsMux.HandleFunc(path, cgiHandler)
 		}
 
+		/*
+		 * Register static assets to TLS server.
+		 */
+		if staticPath != "" {
+			tlsMux.HandleFunc(staticPath, staticHandler)
+		}
+
 		tlsMux.HandleFunc("/", fileHandler)
 		tlsPort := cfg.TLSPort
 		tlsAddr := fmt.Sprintf(":%s", tlsPort)
 
-		/*
-		 * TLS cipher suites to use.
-		 */
-		ciphersuites := []uint16{
-			tls.TLS_CHACHA20_POLY1305_SHA256,
-			tls.TLS_AES_256_GCM_SHA384,
-			tls.TLS_AES_128_GCM_SHA256,
-			tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305,
-			tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305,
-			tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
-			tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
-			tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
-			tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
-		}
+		ciphersuites := this.tlsCipherSuites
+		minVersion := this.tlsMinVersion
 
 		/*
 		 * Curves to use for elliptic curve cryptography.
@@ -488,12 +1081,13 @@ func (this *webServerStruct) Run() {
 		}
 
 		/*
-		 * Use at least TLS 1.2 and Curve25519 (no NIST-Curves!).
+		 * Use configured TLS version and cipher suites (at least TLS 1.2)
+		 * and Curve25519 (no NIST-Curves!).
 		 */
 		tlsConfig := tls.Config{
 			CipherSuites:             ciphersuites,
 			CurvePreferences:         curves,
-			MinVersion:               tls.VersionTLS12,
+			MinVersion:               minVersion,
 			PreferServerCipherSuites: true,
 		}
 
@@ -511,17 +1105,35 @@ func (this *webServerStruct) Run() {
 		tlsTimeoutIdleDur := time.Duration(tlsTimeoutIdleSec)
 		tlsTimeoutIdle := tlsTimeoutIdleDur * time.Second
 
+		http2Disabled := cfg.HTTP2Disabled
+		nextProto := (map[string]func(*http.Server, *tls.Conn, http.Handler))(nil)
+
+		/*
+		 * A non-nil, empty map prevents the server from negotiating HTTP/2.
+		 *
+		 * Note that HTTP/2 only multiplexes requests over a single
+		 * connection. Each stream is still handled by its own goroutine,
+		 * which hands the request to the CGI channel and blocks until one
+		 * of the workers responds. Concurrency is therefore still limited
+		 * by the number of workers, regardless of the protocol version.
+		 */
+		if http2Disabled {
+			nextProto = make(map[string]func(*http.Server, *tls.Conn, http.Handler))
+		}
+
 		/*
 		 * The TLS server.
 		 */
 		tlsServer := http.Server{
 			Addr:              tlsAddr,
+			ConnState:         connState,
 			ErrorLog:          logger,
 			Handler:           tlsMux,
 			IdleTimeout:       tlsTimeoutIdle,
 			ReadHeaderTimeout: tlsTimeoutHeader,
 			ReadTimeout:       tlsTimeoutRead,
 			TLSConfig:         &tlsConfig,
+			TLSNextProto:      nextProto,
 			WriteTimeout:      tlsTimeoutWrite,
 		}
 
@@ -532,10 +1144,180 @@ func (this *webServerStruct) Run() {
 
 }
 
+/*
+ * Parses the minimum TLS version from the configuration.
+ *
+ * An empty string selects TLS 1.2.
+ */
+func parseTLSMinVersion(version string) (uint16, error) {
+	version = strings.TrimSpace(version)
+
+	/*
+	 * Decide on TLS version.
+	 */
+	switch version {
+	case "", "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	default:
+		return 0, fmt.Errorf("Unsupported minimum TLS version '%s'. (Supported: '1.2', '1.3')", version)
+	}
+
+}
+
+/*
+ * Parses the TLS cipher suites from the configuration.
+ *
+ * An empty list selects a default set of secure cipher suites. Cipher suites
+ * considered insecure are rejected.
+ */
+func parseTLSCipherSuites(names []string) ([]uint16, error) {
+	numNames := len(names)
+
+	/*
+	 * If no cipher suites are configured, use the default ones.
+	 */
+	if numNames == 0 {
+
+		/*
+		 * Default TLS cipher suites to use.
+		 */
+		ciphersuites := []uint16{
+			tls.TLS_CHACHA20_POLY1305_SHA256,
+			tls.TLS_AES_256_GCM_SHA384,
+			tls.TLS_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305,
+			tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305,
+			tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+		}
+
+		return ciphersuites, nil
+	} else {
+		secure := make(map[string]uint16)
+		secureSuites := tls.CipherSuites()
+
+		/*
+		 * Collect all cipher suites considered secure.
+		 */
+		for _, suite := range secureSuites {
+			name := suite.Name
+			id := suite.ID
+			secure[name] = id
+		}
+
+		insecure := make(map[string]bool)
+		insecureSuites := tls.InsecureCipherSuites()
+
+		/*
+		 * Collect all cipher suites considered insecure.
+		 */
+		for _, suite := range insecureSuites {
+			name := suite.Name
+			insecure[name] = true
+		}
+
+		ciphersuites := make([]uint16, numNames)
+
+		/*
+		 * Look up each configured cipher suite.
+		 */
+		for i, name := range names {
+			name = strings.TrimSpace(name)
+			id, found := secure[name]
+
+			/*
+			 * Check if cipher suite is known and secure.
+			 */
+			if found {
+				ciphersuites[i] = id
+			} else if insecure[name] {
+				return nil, fmt.Errorf("Cipher suite '%s' is considered insecure.", name)
+			} else {
+				return nil, fmt.Errorf("Unknown cipher suite '%s'.", name)
+			}
+
+		}
+
+		return ciphersuites, nil
+	}
+
+}
+
 /*
  * Creates a new web server.
+ *
+ * Fails if the TLS configuration is invalid.
  */
-func CreateWebServer(cfg Config) WebServer {
-	server := webServerStruct{config: cfg}
-	return &server
+func CreateWebServer(cfg Config) (WebServer, error) {
+	tlsMinVersion := cfg.TLSMinVersion
+	minVersion, err := parseTLSMinVersion(tlsMinVersion)
+
+	/*
+	 * Check if minimum TLS version is valid.
+	 */
+	if err != nil {
+		msg := err.Error()
+		return nil, fmt.Errorf("Invalid TLS configuration: %s", msg)
+	} else {
+		tlsCipherSuites := cfg.TLSCipherSuites
+		ciphersuites, err := parseTLSCipherSuites(tlsCipherSuites)
+
+		/*
+		 * Check if cipher suites are valid.
+		 */
+		if err != nil {
+			msg := err.Error()
+			return nil, fmt.Errorf("Invalid TLS configuration: %s", msg)
+		} else {
+			http2Disabled := cfg.HTTP2Disabled
+			tlsDisabled := cfg.TLSDisabled
+
+			/*
+			 * Cipher suites do not matter without TLS. With TLS 1.3,
+			 * HTTP/2 is negotiated regardless of the configured
+			 * cipher suites, since they only apply to TLS 1.2.
+			 */
+			http2Capable := tlsDisabled || (minVersion == tls.VersionTLS13)
+
+			/*
+			 * Otherwise, HTTP/2 requires one of these cipher suites to
+			 * be enabled.
+			 */
+			for _, id := range ciphersuites {
+
+				/*
+				 * Check if this is a cipher suite required by HTTP/2.
+				 */
+				switch id {
+				case tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256:
+					http2Capable = true
+				}
+
+			}
+
+			/*
+			 * Fail if HTTP/2 is enabled, but cannot be negotiated.
+			 */
+			if !http2Disabled && !http2Capable {
+				return nil, fmt.Errorf("%s", "Invalid TLS configuration: HTTP/2 requires TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256 or TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256 to be enabled.")
+			}
+
+			/*
+			 * Create web server.
+			 */
+			server := webServerStruct{
+				config:          cfg,
+				tlsCipherSuites: ciphersuites,
+				tlsMinVersion:   minVersion,
+			}
+
+			return &server, nil
+		}
+
+	}
+
 }